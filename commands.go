@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/bridge"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+// loadConfig reads the config named by the --config flag. It isn't validated,
+// as commands that only talk to a running bridge don't need most of it.
+func loadConfig(cmd *cobra.Command) (bridge.Config, error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return bridge.Config{}, err
+	}
+	return bridge.LoadConfig(path)
+}
+
+// addressFlag adds the --address flag to a command that talks to a running
+// bridge, and returns a function that connects to it.
+func addressFlag(cmd *cobra.Command) func() (*bridge.AdminClient, error) {
+	var addr string
+	cmd.Flags().StringVar(&addr, "address", "", "the `address` the bridge is serving HTTP on (default from METRICS_ADDRESS)")
+
+	return func() (*bridge.AdminClient, error) {
+		config, err := loadConfig(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if addr == "" {
+			addr = config.Server.MetricsAddress
+		}
+		return bridge.NewAdminClient(addr, config.Server.AdminToken)
+	}
+}
+
+func statusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Check whether the bridge is running and healthy",
+		Args:  cobra.NoArgs,
+	}
+	connect := addressFlag(cmd)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		client, err := connect()
+		if err != nil {
+			return err
+		}
+		if err = client.Health(cmd.Context()); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "ok")
+		return nil
+	}
+	return cmd
+}
+
+func ordersCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "orders",
+		Short: "Look at and manage the orders the bridge has seen",
+	}
+	cmd.AddCommand(ordersListCommand(), ordersCancelCommand())
+	return cmd
+}
+
+func ordersListCommand() *cobra.Command {
+	var state string
+	var filter bridge.OrderFilter
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the most recently changed orders",
+		Args:  cobra.NoArgs,
+	}
+	cmd.Flags().StringVar(&state, "state", "", "only list orders in this `state`")
+	cmd.Flags().UintVar(&filter.Limit, "limit", 20, "list at most this many orders")
+	cmd.Flags().UintVar(&filter.Offset, "offset", 0, "skip this many orders")
+	connect := addressFlag(cmd)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if state != "" {
+			parsed, err := bridge.ParseOrderState(state)
+			if err != nil {
+				return err
+			}
+			filter.State = &parsed
+		}
+
+		client, err := connect()
+		if err != nil {
+			return err
+		}
+		orders, err := client.Orders(cmd.Context(), filter)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATE\tJOB\tUPDATED")
+		for _, order := range orders {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", order.ID, order.State, order.JobID, order.UpdatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	}
+	return cmd
+}
+
+func ordersCancelCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cancel <order id>",
+		Short: "Cancel the running job for an order and refund it",
+		Args:  cobra.ExactArgs(1),
+	}
+	connect := addressFlag(cmd)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		client, err := connect()
+		if err != nil {
+			return err
+		}
+		if err = client.CancelOrder(cmd.Context(), args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Cancelled order %s\n", args[0])
+		return nil
+	}
+	return cmd
+}
+
+func jobCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "job",
+		Short: "Look at the Bacalhau jobs run for orders",
+	}
+
+	logs := &cobra.Command{
+		Use:   "logs <job id>",
+		Short: "Print the output of a job",
+		Args:  cobra.ExactArgs(1),
+	}
+	connect := addressFlag(logs)
+	logs.RunE = func(cmd *cobra.Command, args []string) error {
+		client, err := connect()
+		if err != nil {
+			return err
+		}
+		executions, err := client.JobLogs(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		for _, execution := range executions {
+			fmt.Fprintf(cmd.OutOrStdout(), "=== %s (%s, exit code %d)\n", execution.NodeID, execution.State, execution.ExitCode)
+			fmt.Fprint(cmd.OutOrStdout(), execution.Stdout)
+			fmt.Fprint(cmd.ErrOrStderr(), execution.Stderr)
+		}
+		return nil
+	}
+
+	cmd.AddCommand(logs)
+	return cmd
+}
+
+func configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the bridge's settings",
+	}
+
+	reload := &cobra.Command{
+		Use:   "reload",
+		Short: "Make a running bridge reload its config file",
+		Long: "Make a running bridge reload its config file. Poll intervals, timeouts, " +
+			"rate limits, the job policy and the log level take effect straight away; " +
+			"other settings need the bridge to be restarted.",
+		Args: cobra.NoArgs,
+	}
+	connect := addressFlag(reload)
+	reload.RunE = func(cmd *cobra.Command, args []string) error {
+		client, err := connect()
+		if err != nil {
+			return err
+		}
+		if err = client.Reload(cmd.Context()); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Config reloaded")
+		return nil
+	}
+	cmd.AddCommand(reload)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the config without running the bridge",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err = config.Validate(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Config is valid")
+			return nil
+		},
+	})
+	return cmd
+}
+
+func hashOutputCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hash-output <path>",
+		Short: "Work out the canonical hash of a downloaded job output",
+		Long: "Work out the canonical hash of a job output downloaded to a directory, " +
+			"to check it against the hash attested on-chain with the job's result. " +
+			"Only the names, types and contents of the files are hashed.",
+		Args: cobra.ExactArgs(1),
+	}
+	archive := cmd.Flags().Bool("tar", false, "read the output from a tar archive, such as one made by ipfs get --archive")
+	root := cmd.Flags().String("root", "", "with --tar, the top-level `directory` that the output is under, usually its CID")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var hash common.Hash
+		var err error
+		if *archive {
+			var file *os.File
+			if file, err = os.Open(args[0]); err != nil {
+				return err
+			}
+			defer file.Close()
+			hash, err = bridge.HashOutputTar(file, *root)
+		} else {
+			hash, err = bridge.HashOutputDir(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), hash.Hex())
+		return nil
+	}
+	return cmd
+}
+
+func decryptResultCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decrypt-result <encrypted file> <output tar file>",
+		Short: "Decrypt a job output that was encrypted for the order's creator",
+		Long: "Decrypt a job output that was encrypted with the public key passed to " +
+			"runLilypadJobEncrypted, after downloading it from IPFS by the CID returned " +
+			"on-chain. The decrypted output is a tar archive.",
+		Args: cobra.ExactArgs(2),
+	}
+	keyFile := cmd.Flags().String("key-file", "", "read the hex-encoded private key from this `file`")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		key, err := crypto.LoadECDSA(*keyFile)
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		if err = bridge.DecryptResult(out, in, key); err != nil {
+			out.Close()
+			os.Remove(args[1])
+			return err
+		}
+		return out.Close()
+	}
+	return cmd
+}
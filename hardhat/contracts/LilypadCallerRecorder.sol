@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: MIT
+pragma solidity >=0.8.4;
+import "./LilypadCallerInterface.sol";
+
+interface LilypadEventsInterface {
+    function runLilypadJob(address _from, string memory _spec, uint8 _resultType) external payable returns (uint);
+}
+
+/**
+    @notice A caller that records whatever the bridge returns, so that the bridge can be tested end to end on a devnet
+*/
+contract LilypadCallerRecorder is LilypadCallerInterface {
+    address public lilypad;
+    mapping(uint => string) public results;
+    mapping(uint => string) public errors;
+
+    event JobFulfilled(uint id, string result);
+    event JobCancelled(uint id, string errorMsg);
+
+    constructor(address _lilypad) {
+        lilypad = _lilypad;
+    }
+
+    // orders the job, passing on what was paid for it
+    function run(string calldata _spec, uint8 _resultType) public payable returns (uint) {
+        return LilypadEventsInterface(lilypad).runLilypadJob{value: msg.value}(address(this), _spec, _resultType);
+    }
+
+    function lilypadFulfilled(address _from, uint _jobId, LilypadResultType, string calldata _result) external override {
+        require(_from == lilypad && msg.sender == lilypad, "Results must come from the Lilypad contract");
+        results[_jobId] = _result;
+        emit JobFulfilled(_jobId, _result);
+    }
+
+    function lilypadCancelled(address _from, uint _jobId, string calldata _errorMsg) external override {
+        require(_from == lilypad && msg.sender == lilypad, "Errors must come from the Lilypad contract");
+        errors[_jobId] = _errorMsg;
+        emit JobCancelled(_jobId, _errorMsg);
+    }
+}
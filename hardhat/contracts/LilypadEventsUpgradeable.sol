@@ -5,6 +5,7 @@ import "@openzeppelin/contracts/utils/Counters.sol";
 import "@openzeppelin/contracts-upgradeable/proxy/utils/Initializable.sol";
 import "@openzeppelin/contracts-upgradeable/access/AccessControlUpgradeable.sol";
 import "@openzeppelin/contracts-upgradeable/proxy/utils/UUPSUpgradeable.sol";
+import "@openzeppelin/contracts/token/ERC20/IERC20.sol";
 import "./LilypadCallerInterface.sol";
 
 error LilypadEventsUpgradeableError();
@@ -46,6 +47,35 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
         override
     {}
 
+    // results can be posted through the trusted EIP-2771 forwarder, so that a relayer pays for the gas.
+    // the forwarder is set once, when upgrading to version 2, and the zero address means no forwarder
+    function initializeForwarder(address _trustedForwarder) public reinitializer(2) onlyRole(UPGRADER_ROLE) {
+        trustedForwarder = _trustedForwarder;
+    }
+
+    function isTrustedForwarder(address _forwarder) public view returns (bool) {
+        return _forwarder != address(0) && _forwarder == trustedForwarder;
+    }
+
+    // calls through the forwarder have the address of whoever signed them appended to the call data
+    function _msgSender() internal view override returns (address sender) {
+        if (isTrustedForwarder(msg.sender) && msg.data.length >= 20) {
+            assembly {
+                sender := shr(96, calldataload(sub(calldatasize(), 20)))
+            }
+        } else {
+            return super._msgSender();
+        }
+    }
+
+    function _msgData() internal view override returns (bytes calldata) {
+        if (isTrustedForwarder(msg.sender) && msg.data.length >= 20) {
+            return msg.data[:msg.data.length - 20];
+        } else {
+            return super._msgData();
+        }
+    }
+
     struct LilypadJob {
         address requestor;
         uint id; //jobID
@@ -63,11 +93,30 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
     }
     LilypadJobResult[] public lilypadJobResultHistory;
     mapping(address => LilypadJobResult[]) lilypadJobResultByAddress; // jobs by requestor
+    mapping(uint => bytes32) public lilypadOutputHashes; // canonical hash of each job's output, if attested
+    mapping(uint => uint256) public lilypadJobPayments; // what was paid for each job, until it is refunded
+    mapping(uint => address) private lilypadJobPayers; // who paid for each job, and so gets any refund
+    mapping(uint => address) public lilypadJobTokens; // the ERC-20 token each job was paid in, or 0 for the native token
+    mapping(uint => bool) public lilypadJobSettled; // whether each job's result or error has been returned
+    // new state must go after everything above, so that upgrading doesn't move what is already stored
+    address private trustedForwarder; // the EIP-2771 forwarder that relayed calls are trusted from
+    mapping(address => uint256) private tokenEscrowAmounts; // what has been earned in each ERC-20 token, until it is withdrawn
 
     /** Events **/
     event NewLilypadJobSubmitted(LilypadJob job);
     event LilypadJobResultsReturned(LilypadJobResult result);
     event LilypadEscrowPaid(address, uint256);
+    event MediationRequested(address requestor, uint id, string reason);
+    event MediationVerdictReturned(address requestor, uint id, bool upheld, string result);
+    event LilypadResultDisputed(address requestor, uint id, string result, string expectedResult);
+    event LilypadResultAttested(address requestor, uint id, string result, bytes32 outputHash);
+    event LilypadEncryptedJobSubmitted(address requestor, uint id, bytes publicKey);
+    event LilypadDurableJobSubmitted(address requestor, uint id);
+    event LilypadJobPriceOffered(address requestor, uint id, uint256 price);
+    event LilypadJobDeclined(address requestor, uint id, string reason);
+    event LilypadJobRefunded(address requestor, uint id, uint256 amount);
+    event LilypadJobTokenPriceOffered(address requestor, uint id, address token, uint256 amount);
+    event LilypadEscrowTokenPaid(address, address token, uint256);
 
     /** Escrow/ Balance functions **/
     function getEscrowAddress()public view onlyRole(UPGRADER_ROLE) returns(address) {
@@ -96,6 +145,22 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
         emit LilypadEscrowPaid(recipient, amount);
     }
 
+    // sends what has been earned in an ERC-20 token to the escrow address. payments for jobs that haven't
+    // had a result returned are kept, so that they can still be refunded
+    function withdrawTokenBalanceToEscrowAddress(address _token) public onlyRole(UPGRADER_ROLE) {
+        uint256 amount = tokenEscrowAmounts[_token];
+        require(amount > 0, "No tokens in contract able to be withdrawn");
+        tokenEscrowAmounts[_token] = 0;
+        require(IERC20(_token).transfer(escrowAddress, amount), "Tokens could not be withdrawn");
+        emit LilypadEscrowTokenPaid(escrowAddress, _token, amount);
+    }
+
+    // the version of the events and methods the bridge uses, so that a bridge can tell which it can rely on
+    // bump it whenever one of them is added or changed
+    function version() public pure returns (uint256) {
+        return 5;
+    }
+
     function getLilypadFee() public view returns (uint256) {
         return LILYPAD_FEE;
     }
@@ -122,7 +187,10 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
         });
 
         lilypadJobHistory.push(jobCalled);
+        lilypadJobPayments[thisJobId] = msg.value;
+        lilypadJobPayers[thisJobId] = _msgSender();
         emit NewLilypadJobSubmitted(jobCalled);
+        emit LilypadJobPriceOffered(_from, thisJobId, msg.value);
         _jobIds.increment();
 
         escrowAmount += msg.value; 
@@ -140,8 +208,60 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
         return thisJobId;
     }
 
-    // this should really be owner only - our admin contract should be the only one able to call it
-    function returnLilypadResults(address _to, uint _jobId, LilypadResultType _resultType, string memory _result) public {
+    // like runLilypadJob, but paid for with _amount of an ERC-20 token rather than the native token. the
+    // contract must have been approved to transfer the amount from the sender first
+    function runLilypadJobWithToken(address _from, string memory _spec, uint8 _resultType, address _token, uint256 _amount) public returns (uint) {
+        require(_token != address(0), "Token must be an ERC-20 contract");
+        require(_amount > 0, "Not enough payment sent to cover job fee");
+        require(IERC20(_token).transferFrom(_msgSender(), address(this), _amount), "Payment could not be transferred");
+
+        uint thisJobId = _jobIds.current();
+        LilypadJob memory jobCalled = LilypadJob({
+            requestor: _from,
+            id: thisJobId,
+            spec: _spec,
+            resultType: LilypadResultType(_resultType)
+        });
+
+        lilypadJobHistory.push(jobCalled);
+        lilypadJobPayments[thisJobId] = _amount;
+        lilypadJobPayers[thisJobId] = _msgSender();
+        lilypadJobTokens[thisJobId] = _token;
+        emit NewLilypadJobSubmitted(jobCalled);
+        emit LilypadJobTokenPriceOffered(_from, thisJobId, _token, _amount);
+        _jobIds.increment();
+
+        return thisJobId;
+    }
+
+    // like runLilypadJob, but the results are encrypted with the passed secp256k1 public key and only the CID
+    // of the encrypted results is returned, whatever result type is asked for
+    function runLilypadJobEncrypted(address _from, string memory _spec, uint8 _resultType, bytes memory _publicKey) public payable returns (uint) {
+        require(_publicKey.length == 33 || _publicKey.length == 65, "Public key must be a secp256k1 public key");
+        uint thisJobId = runLilypadJob(_from, _spec, _resultType);
+        emit LilypadEncryptedJobSubmitted(_from, thisJobId, _publicKey);
+        return thisJobId;
+    }
+
+    // like runLilypadJob, but a Filecoin storage deal is made for the result so that it is kept for the long term
+    function runLilypadJobDurable(address _from, string memory _spec, uint8 _resultType) public payable returns (uint) {
+        uint thisJobId = runLilypadJob(_from, _spec, _resultType);
+        emit LilypadDurableJobSubmitted(_from, thisJobId);
+        return thisJobId;
+    }
+
+    // only the bridge can return results, errors and refunds, as each settles the job for good
+    function returnLilypadResults(address _to, uint _jobId, LilypadResultType _resultType, string memory _result) public onlyRole(UPGRADER_ROLE) {
+        require(!lilypadJobSettled[_jobId], "Job has already been settled");
+        lilypadJobSettled[_jobId] = true;
+
+        // a job paid for in a token has earned its payment once its result is returned
+        address token = lilypadJobTokens[_jobId];
+        if (token != address(0)) {
+            tokenEscrowAmounts[token] += lilypadJobPayments[_jobId];
+            lilypadJobPayments[_jobId] = 0;
+        }
+
         LilypadJobResult memory jobResult = LilypadJobResult({
             requestor: _to,
             id: _jobId,
@@ -156,7 +276,34 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
         LilypadCallerInterface(_to).lilypadFulfilled(address(this), _jobId, _resultType, _result);
     }
 
+    // returns the results of several jobs in one transaction, which is much cheaper than one each
+    // if any of the callbacks revert then none of the results are returned
+    function returnLilypadResultsBatch(address[] memory _to, uint[] memory _jobIds, LilypadResultType[] memory _resultTypes, string[] memory _results) public onlyRole(UPGRADER_ROLE) {
+        require(_to.length == _jobIds.length && _to.length == _resultTypes.length && _to.length == _results.length, "Batch arrays must be the same length");
+        for (uint i = 0; i < _to.length; i++) {
+            returnLilypadResults(_to[i], _jobIds[i], _resultTypes[i], _results[i]);
+        }
+    }
+
+    // returns the result along with the canonical hash of the job's output directory, so that anyone
+    // downloading the output can check it is what the bridge saw
+    function returnLilypadAttestedResults(address _to, uint _jobId, LilypadResultType _resultType, string memory _result, bytes32 _outputHash) public onlyRole(UPGRADER_ROLE) {
+        lilypadOutputHashes[_jobId] = _outputHash;
+        emit LilypadResultAttested(_to, _jobId, _result, _outputHash);
+        returnLilypadResults(_to, _jobId, _resultType, _result);
+    }
+
+    function returnLilypadAttestedResultsBatch(address[] memory _to, uint[] memory _jobIds, LilypadResultType[] memory _resultTypes, string[] memory _results, bytes32[] memory _outputHashes) public onlyRole(UPGRADER_ROLE) {
+        require(_to.length == _jobIds.length && _to.length == _resultTypes.length && _to.length == _results.length && _to.length == _outputHashes.length, "Batch arrays must be the same length");
+        for (uint i = 0; i < _to.length; i++) {
+            returnLilypadAttestedResults(_to[i], _jobIds[i], _resultTypes[i], _results[i], _outputHashes[i]);
+        }
+    }
+
     function returnLilypadError(address _to, uint _jobId, string memory _errorMsg) public onlyRole(UPGRADER_ROLE) {
+        require(!lilypadJobSettled[_jobId], "Job has already been settled");
+        lilypadJobSettled[_jobId] = true;
+
         LilypadJobResult memory jobResult = LilypadJobResult({
             requestor: _to,
             id: _jobId,
@@ -171,6 +318,49 @@ contract LilypadEventsUpgradeable is Initializable, AccessControlUpgradeable, UU
         LilypadCallerInterface(_to).lilypadCancelled(address(this), _jobId, _errorMsg);
     }
 
+    // like returnLilypadError, but also gives what was paid for the job back to whoever paid for it, for jobs
+    // that failed through no fault of the requestor. a job's payment can only be refunded once
+    function refundLilypadJob(address _to, uint _jobId, string memory _errorMsg) public onlyRole(UPGRADER_ROLE) {
+        uint256 amount = lilypadJobPayments[_jobId];
+        address token = lilypadJobTokens[_jobId];
+        if (amount > 0 && token != address(0)) {
+            lilypadJobPayments[_jobId] = 0;
+            require(IERC20(token).transfer(lilypadJobPayers[_jobId], amount), "Refund could not be sent");
+            emit LilypadJobRefunded(_to, _jobId, amount);
+        } else if (amount > 0) {
+            require(address(this).balance >= amount, "Not enough balance to refund the job");
+            lilypadJobPayments[_jobId] = 0;
+            escrowAmount = escrowAmount > amount ? escrowAmount - amount : 0;
+
+            (bool sent, ) = payable(lilypadJobPayers[_jobId]).call{value: amount}("");
+            require(sent, "Refund could not be sent");
+            emit LilypadJobRefunded(_to, _jobId, amount);
+        }
+        returnLilypadError(_to, _jobId, _errorMsg);
+    }
+
+    // like returnLilypadError, but says that the job was not run because the price paid for it was too low,
+    // so that the requestor can offer more
+    function declineLilypadJob(address _to, uint _jobId, string memory _reason) public onlyRole(UPGRADER_ROLE) {
+        emit LilypadJobDeclined(_to, _jobId, _reason);
+        returnLilypadError(_to, _jobId, _reason);
+    }
+
+    /** Mediation: a failed or disputed job is run again by a mediator, whose verdict is posted here **/
+    function requestMediation(address _to, uint _jobId, string memory _reason) public onlyRole(UPGRADER_ROLE) {
+        emit MediationRequested(_to, _jobId, _reason);
+    }
+
+    // upheld is true if the mediator's job had the same outcome as the original job
+    function returnMediationVerdict(address _to, uint _jobId, bool _upheld, string memory _result) public onlyRole(UPGRADER_ROLE) {
+        emit MediationVerdictReturned(_to, _jobId, _upheld, _result);
+    }
+
+    // emitted instead of returning a result when running the job again gave a different one
+    function disputeLilypadResult(address _to, uint _jobId, string memory _result, string memory _expected) public onlyRole(UPGRADER_ROLE) {
+        emit LilypadResultDisputed(_to, _jobId, _result, _expected);
+    }
+
     function fetchAllJobs() public view returns (LilypadJob[] memory) {
         return lilypadJobHistory;
     }
@@ -17,6 +17,11 @@ const config: HardhatUserConfig = {
   defaultNetwork: 'filecoinHyperspace',
   networks: {
     hardhat: {},
+    // A local chain such as anvil or geth --dev, for end-to-end tests.
+    devnet: {
+      url: process.env.DEVNET_RPC_URL || 'http://127.0.0.1:8545',
+      accounts: [walletPrivateKey],
+    },
     filecoinHyperspace: {
       url: 'https://api.hyperspace.node.glif.io/rpc/v1', //https://filecoin-hyperspace.chainstacklabs.com/rpc/v1
       chainId: 3141,
@@ -23,6 +23,13 @@ async function main() {
   });
 
   await lilypadEventsUpgradeable.deployed();
+
+  // The EIP-2771 forwarder that relayed results are trusted from, if any.
+  await (
+    await lilypadEventsUpgradeable.initializeForwarder(
+      process.env.TRUSTED_FORWARDER_ADDRESS || ethers.constants.AddressZero
+    )
+  ).wait();
   console.log(
     'LilypadEventsUpgradeable deployed to ',
     lilypadEventsUpgradeable.address
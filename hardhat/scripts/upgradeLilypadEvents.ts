@@ -1,13 +1,22 @@
 const { ethers, upgrades } = require('hardhat');
 
-//EXAMPLE ONLY
+// Upgrades the proxy at PROXY_ADDRESS to the current LilypadEventsUpgradeable.
+// The plugin checks that the new storage layout is compatible with the
+// deployed one, and refuses to upgrade if it isn't.
 async function main() {
-  const LilypadEvents = await ethers.getContractFactory('LilypadEvents'); //<- this should be the new contract
-  const lilypadEventsUpgradeable = await upgrades.upgradeProxy(
-    0xdc7612fa94f098f1d7bb40e0f4f4db8ff0bc8820, //proxy address
-    LilypadEvents
-  );
+  const LilypadEvents = await ethers.getContractFactory('LilypadEventsUpgradeable');
+  await upgrades.upgradeProxy(process.env.PROXY_ADDRESS, LilypadEvents, {
+    kind: 'uups',
+    // The EIP-2771 forwarder that relayed results are trusted from, if any.
+    call: {
+      fn: 'initializeForwarder',
+      args: [process.env.TRUSTED_FORWARDER_ADDRESS || ethers.constants.AddressZero],
+    },
+  });
   console.log('LilypadEvents upgraded');
 }
 
-main();
+main().catch((error) => {
+  console.error(error);
+  process.exitCode = 1;
+});
@@ -0,0 +1,111 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A Payment is what a settled order offered to pay for its job.
+type Payment struct {
+	OrderID string
+	State   OrderState
+
+	// The ERC-20 token the order was paid for in, or the zero address for
+	// the chain's native token, and the amount in its smallest unit.
+	Token  common.Address
+	Amount *big.Int
+}
+
+// A PaymentStore returns what settled orders paid.
+type PaymentStore interface {
+	// Payments returns what every order that was paid for or refunded since
+	// the passed time offered to pay, for the orders whose price is known.
+	Payments(ctx context.Context, since time.Time) ([]Payment, error)
+}
+
+// A TokenAccount is what the orders paid for in one token came to.
+type TokenAccount struct {
+	Token  common.Address `json:"token"`
+	Symbol string         `json:"symbol,omitempty"`
+
+	// The orders whose result was returned, and what they paid.
+	PaidOrders int      `json:"paidOrders"`
+	Paid       *big.Int `json:"paid"`
+
+	// The orders whose error was returned, and what they had offered.
+	RefundedOrders int      `json:"refundedOrders"`
+	Refunded       *big.Int `json:"refunded"`
+
+	// What the amounts are worth in the report's currency, if the token has
+	// a price.
+	PaidValue     *float64 `json:"paidValue,omitempty"`
+	RefundedValue *float64 `json:"refundedValue,omitempty"`
+}
+
+// An AccountingReport sums up what the orders settled since a time paid, by
+// the token they were paid in.
+type AccountingReport struct {
+	Since  time.Time      `json:"since"`
+	Tokens []TokenAccount `json:"tokens"`
+
+	// The currency that values are estimated in, and the totals across the
+	// tokens that have a price, if there is a price oracle.
+	Currency      string  `json:"currency,omitempty"`
+	PaidValue     float64 `json:"paidValue,omitempty"`
+	RefundedValue float64 `json:"refundedValue,omitempty"`
+}
+
+// Accounting reports on what the orders settled since the passed time paid.
+// Amounts are valued by the oracle, which may be nil if they shouldn't be.
+func Accounting(ctx context.Context, store PaymentStore, oracle PriceOracle, since time.Time) (AccountingReport, error) {
+	report := AccountingReport{Since: since.UTC(), Tokens: []TokenAccount{}}
+	payments, err := store.Payments(ctx, since)
+	if err != nil {
+		return report, err
+	}
+
+	accounts := map[common.Address]*TokenAccount{}
+	for _, payment := range payments {
+		account, found := accounts[payment.Token]
+		if !found {
+			account = &TokenAccount{Token: payment.Token, Paid: new(big.Int), Refunded: new(big.Int)}
+			accounts[payment.Token] = account
+		}
+		if payment.State == OrderStatePaid {
+			account.PaidOrders++
+			account.Paid.Add(account.Paid, payment.Amount)
+		} else {
+			account.RefundedOrders++
+			account.Refunded.Add(account.Refunded, payment.Amount)
+		}
+	}
+
+	if oracle != nil {
+		report.Currency = oracle.Currency()
+	}
+	for _, account := range accounts {
+		if oracle != nil {
+			price, err := oracle.TokenPrice(ctx, account.Token)
+			if err != nil && !errors.Is(err, ErrUnknownToken) {
+				return report, err
+			} else if err == nil {
+				paid, refunded := price.Value(account.Paid), price.Value(account.Refunded)
+				account.Symbol, account.PaidValue, account.RefundedValue = price.Symbol, &paid, &refunded
+				report.PaidValue += paid
+				report.RefundedValue += refunded
+			}
+		}
+		report.Tokens = append(report.Tokens, *account)
+	}
+
+	// The native token has the zero address, so comes first.
+	sort.Slice(report.Tokens, func(i, j int) bool {
+		return report.Tokens[i].Token.Hex() < report.Tokens[j].Token.Hex()
+	})
+	return report, nil
+}
@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountingSumsPaymentsByToken(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	usdc := common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	unknown := common.HexToAddress("0x01")
+
+	settle := func(id byte, token common.Address, price string, paid bool) {
+		e := walEvent(id)
+		e.orderPrice = price
+		if token != (common.Address{}) {
+			e.orderToken = token.Bytes()
+		}
+		running := e.JobCreated(model.NewJob())
+		if paid {
+			require.NoError(t, repo.Save(running.Completed(cid.Cid{}, "out", "", 0).PaidIn(common.Hash{id})))
+		} else {
+			require.NoError(t, repo.Save(running.Failed("failed").RefundedIn(common.Hash{id})))
+		}
+	}
+	settle(0x01, common.Address{}, ether(0.01).String(), true)
+	settle(0x02, common.Address{}, ether(0.02).String(), true)
+	settle(0x03, usdc, "5000000", true)
+	settle(0x04, usdc, "2500000", false)
+	settle(0x05, unknown, "7", true)
+	// Orders that didn't say what they paid, or haven't been settled, aren't
+	// counted.
+	settle(0x06, common.Address{}, "", true)
+	require.NoError(t, repo.Save(walEvent(0x07)))
+
+	oracle := &StaticPriceOracle{CurrencyCode: "USD", Tokens: []TokenPrice{
+		{Symbol: "ETH", Decimals: 18, Price: 2000},
+		{Symbol: "USDC", Address: usdc, Decimals: 6, Price: 1},
+	}}
+	report, err := Accounting(ctx, repo.(PaymentStore), oracle, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, "USD", report.Currency)
+	require.Len(t, report.Tokens, 3)
+
+	native := report.Tokens[0]
+	require.Equal(t, common.Address{}, native.Token)
+	require.Equal(t, "ETH", native.Symbol)
+	require.Equal(t, 2, native.PaidOrders)
+	require.Equal(t, 0, ether(0.03).Cmp(native.Paid))
+	require.InDelta(t, 60, *native.PaidValue, 0.001)
+
+	stablecoin := report.Tokens[1]
+	require.Equal(t, usdc, stablecoin.Token)
+	require.Equal(t, 1, stablecoin.PaidOrders)
+	require.Equal(t, 1, stablecoin.RefundedOrders)
+	require.Equal(t, big.NewInt(2500000), stablecoin.Refunded)
+	require.InDelta(t, 2.5, *stablecoin.RefundedValue, 0.001)
+
+	require.Equal(t, unknown, report.Tokens[2].Token)
+	require.Nil(t, report.Tokens[2].PaidValue, "tokens without a price aren't valued")
+	require.InDelta(t, 65, report.PaidValue, 0.001)
+	require.InDelta(t, 2.5, report.RefundedValue, 0.001)
+
+	report, err = Accounting(ctx, repo.(PaymentStore), nil, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, report.Tokens)
+	require.Empty(t, report.Currency)
+
+	rec := httptest.NewRecorder()
+	AccountingHandler(repo.(PaymentStore), oracle).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, AccountingPath+"?since=yesterday", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var ErrAdminTokenRequired = errors.New("ADMIN_TOKEN must be set to manage the bridge remotely")
+
+// authorized returns whether the value of an Authorization header carries the
+// admin token. An empty token authorizes nothing.
+func authorized(token, header string) bool {
+	if token == "" || !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	presented := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// AdminAuth returns a handler that only passes requests with the admin token
+// as a bearer token on to next, and refuses the rest with 401.
+func AdminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(token, r.Header.Get("Authorization")) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkControlToken returns an Unauthenticated error unless the gRPC call
+// carries the admin token as a bearer token in its authorization metadata.
+func checkControlToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range md.Get("authorization") {
+		if authorized(token, header) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "admin token required")
+}
+
+// controlAuth returns the options that make a gRPC server refuse calls without
+// the admin token.
+func controlAuth(token string) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkControlToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkControlToken(stream.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
+
+// loopbackDefault returns the address to listen on for the passed address,
+// which listens on the loopback interface rather than every interface if it
+// doesn't name a host.
+func loopbackDefault(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("localhost", port)
+}
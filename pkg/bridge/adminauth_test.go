@@ -0,0 +1,31 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdminTokens(t *testing.T) {
+	require.True(t, authorized("secret", "Bearer secret"))
+	require.False(t, authorized("secret", "Bearer guess"))
+	require.False(t, authorized("secret", "secret"))
+	require.False(t, authorized("", "Bearer "), "an empty token should authorize nothing")
+
+	ctx := context.Background()
+	require.Equal(t, codes.Unauthenticated, status.Code(checkControlToken(ctx, "secret")))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer secret"))
+	require.NoError(t, checkControlToken(ctx, "secret"))
+}
+
+func TestControlIsServedLocallyByDefault(t *testing.T) {
+	require.Equal(t, "localhost:9090", loopbackDefault(":9090"))
+	require.Equal(t, "0.0.0.0:9090", loopbackDefault("0.0.0.0:9090"))
+
+	err := ServeControl(context.Background(), ":0", "", nil, nil)
+	require.ErrorIs(t, err, ErrAdminTokenRequired)
+}
@@ -0,0 +1,294 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// An Alert is a condition that the operator of the bridge should know about.
+// Each alert is sent once when it starts firing, and again when it resolves.
+type Alert struct {
+	// Identifies the condition, so that the same alert firing and resolving
+	// can be matched up, such as "stuck_order:0x…".
+	Key string `json:"key"`
+
+	// What kind of condition it is: one of the Alert* names.
+	Name     string    `json:"name"`
+	Summary  string    `json:"summary"`
+	Resolved bool      `json:"resolved"`
+	Time     time.Time `json:"time"`
+}
+
+// The names of the alerts that the Alerter fires.
+const (
+	AlertBacalhauFailures = "bacalhau_api_failures"
+	AlertLowBalance       = "low_balance"
+	AlertStuckOrder       = "stuck_order"
+)
+
+// An AlertNotifier delivers alerts to the operator.
+type AlertNotifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// A BalanceReporter can say how much of the chain's native token the bridge's
+// wallet holds.
+type BalanceReporter interface {
+	// Balance returns the wallet's balance in wei.
+	Balance(ctx context.Context) (*big.Int, error)
+}
+
+var _ BalanceReporter = (*realContract)(nil)
+
+// consecutiveAPIFailures counts the calls to the Bacalhau API that have
+// failed since the last one that succeeded.
+var consecutiveAPIFailures atomic.Int64
+
+// AlertRules say when the Alerter fires. Rules that are zero are not checked.
+type AlertRules struct {
+	// How many calls to the Bacalhau API in a row must fail.
+	APIFailures int64
+
+	// The least the wallet should hold, in wei.
+	MinBalance *big.Int
+
+	// How long an order can stay in the same state before it is stuck.
+	StuckAfter time.Duration
+}
+
+// The most orders in each state that are checked for being stuck.
+const stuckOrderLimit = 1000
+
+// An Alerter checks the bridge for conditions that need an operator, and
+// tells the notifiers when they start and stop.
+type Alerter struct {
+	Rules     AlertRules
+	Notifiers []AlertNotifier
+
+	// Where to look for stuck orders and the wallet's balance. Rules that
+	// need one that isn't set are not checked.
+	Orders OrderStore
+	Wallet BalanceReporter
+
+	interval time.Duration
+
+	mu     sync.Mutex
+	firing map[string]Alert
+}
+
+// NewAlerter returns an Alerter that checks the rules every interval.
+func NewAlerter(rules AlertRules, interval time.Duration, notifiers ...AlertNotifier) *Alerter {
+	return &Alerter{
+		Rules:     rules,
+		Notifiers: notifiers,
+		interval:  interval,
+		firing:    map[string]Alert{},
+	}
+}
+
+// Run checks the rules until the context is cancelled.
+func (a *Alerter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.Check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Check checks every rule once, firing the alerts that have started and
+// resolving those that have stopped.
+func (a *Alerter) Check(ctx context.Context) {
+	active := map[string]Alert{}
+	fire := func(key, name, format string, args ...any) {
+		active[key] = Alert{Key: key, Name: name, Summary: fmt.Sprintf(format, args...)}
+	}
+
+	if limit := a.Rules.APIFailures; limit > 0 {
+		if failures := consecutiveAPIFailures.Load(); failures >= limit {
+			fire(AlertBacalhauFailures, AlertBacalhauFailures, "The last %d calls to the Bacalhau API failed", failures)
+		}
+	}
+
+	if minimum := a.Rules.MinBalance; minimum != nil && minimum.Sign() > 0 && a.Wallet != nil {
+		balance, err := a.Wallet.Balance(ctx)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to check wallet balance")
+		} else if balance.Cmp(minimum) < 0 {
+			fire(AlertLowBalance, AlertLowBalance, "Wallet holds %s wei, less than the minimum of %s wei", balance, minimum)
+		}
+	}
+
+	if after := a.Rules.StuckAfter; after > 0 && a.Orders != nil {
+		for _, state := range []OrderState{OrderStateSubmitted, OrderStateRunning, OrderStateCompleted, OrderStateJobError, OrderStateFailed} {
+			state := state
+			orders, err := a.Orders.Orders(ctx, OrderFilter{State: &state, Limit: stuckOrderLimit})
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Stringer("state", state).Msg("Unable to check for stuck orders")
+				continue
+			}
+			for _, order := range orders {
+				if stuck := time.Since(order.UpdatedAt); !order.UpdatedAt.IsZero() && stuck > after {
+					fire(AlertStuckOrder+":"+order.ID, AlertStuckOrder, "Order %s has been %s for %s", order.ID, order.State, stuck.Round(time.Second))
+				}
+			}
+		}
+	}
+
+	a.mu.Lock()
+	var changed []Alert
+	for key, alert := range active {
+		if _, ok := a.firing[key]; !ok {
+			alert.Time = time.Now().UTC()
+			a.firing[key] = alert
+			changed = append(changed, alert)
+		}
+	}
+	for key, alert := range a.firing {
+		if _, ok := active[key]; !ok {
+			delete(a.firing, key)
+			alert.Resolved, alert.Time = true, time.Now().UTC()
+			changed = append(changed, alert)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, alert := range changed {
+		a.notify(ctx, alert)
+	}
+}
+
+// Firing returns the alerts that are firing.
+func (a *Alerter) Firing() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(a.firing))
+	for _, alert := range a.firing {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func (a *Alerter) notify(ctx context.Context, alert Alert) {
+	log.Ctx(ctx).Warn().Str("alert", alert.Key).Bool("resolved", alert.Resolved).Msg(alert.Summary)
+	alertsTotal.WithLabelValues(alert.Name, fmt.Sprint(alert.Resolved)).Inc()
+	for _, notifier := range a.Notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("alert", alert.Key).Msg("Unable to deliver alert")
+		}
+	}
+}
+
+// postAlert sends the body as JSON to the URL, expecting any 2xx response.
+func postAlert(ctx context.Context, client *http.Client, url string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+type webhookAlerter struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookAlerter returns a notifier that posts each alert as JSON to the
+// URL.
+func NewWebhookAlerter(url string) AlertNotifier {
+	return &webhookAlerter{client: http.DefaultClient, url: url}
+}
+
+// Notify implements AlertNotifier
+func (w *webhookAlerter) Notify(ctx context.Context, alert Alert) error {
+	return postAlert(ctx, w.client, w.url, alert)
+}
+
+type slackAlerter struct {
+	client *http.Client
+	url    string
+}
+
+// NewSlackAlerter returns a notifier that posts each alert to a Slack
+// incoming webhook URL.
+func NewSlackAlerter(url string) AlertNotifier {
+	return &slackAlerter{client: http.DefaultClient, url: url}
+}
+
+// Notify implements AlertNotifier
+func (s *slackAlerter) Notify(ctx context.Context, alert Alert) error {
+	prefix := ":rotating_light: *Firing*"
+	if alert.Resolved {
+		prefix = ":white_check_mark: *Resolved*"
+	}
+	return postAlert(ctx, s.client, s.url, map[string]string{
+		"text": fmt.Sprintf("%s `%s`: %s", prefix, alert.Key, alert.Summary),
+	})
+}
+
+// Where PagerDuty alerts are sent by default.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+type pagerDutyAlerter struct {
+	client     *http.Client
+	url        string
+	routingKey string
+}
+
+// NewPagerDutyAlerter returns a notifier that triggers and resolves PagerDuty
+// incidents through the Events API v2, using the integration's routing key.
+func NewPagerDutyAlerter(routingKey string) AlertNotifier {
+	return &pagerDutyAlerter{client: http.DefaultClient, url: pagerDutyEventsURL, routingKey: routingKey}
+}
+
+// Notify implements AlertNotifier
+func (p *pagerDutyAlerter) Notify(ctx context.Context, alert Alert) error {
+	action := "trigger"
+	if alert.Resolved {
+		action = "resolve"
+	}
+	return postAlert(ctx, p.client, p.url, map[string]any{
+		"routing_key":  p.routingKey,
+		"event_action": action,
+		"dedup_key":    "lilypad:" + alert.Key,
+		"payload": map[string]any{
+			"summary":   alert.Summary,
+			"source":    "lilypad",
+			"severity":  "error",
+			"timestamp": alert.Time.Format(time.RFC3339),
+			"component": alert.Name,
+		},
+	})
+}
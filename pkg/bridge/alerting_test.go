@@ -0,0 +1,137 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type staticBalance struct{ wei *big.Int }
+
+func (s *staticBalance) Balance(context.Context) (*big.Int, error) {
+	return s.wei, nil
+}
+
+type staticOrders []Order
+
+func (s staticOrders) Orders(ctx context.Context, filter OrderFilter) ([]Order, error) {
+	orders := []Order{}
+	for _, order := range s {
+		if order.State == filter.State.String() {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (s staticOrders) Order(context.Context, common.Hash) (Order, error) {
+	return Order{}, ErrOrderNotFound
+}
+
+func TestAlertsFireOnceAndResolve(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	received := []Alert{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		mu.Lock()
+		received = append(received, alert)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	sent := func() []Alert {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Alert{}, received...)
+	}
+
+	wallet := &staticBalance{wei: big.NewInt(10)}
+	alerter := NewAlerter(AlertRules{
+		APIFailures: 3,
+		MinBalance:  big.NewInt(100),
+		StuckAfter:  time.Hour,
+	}, time.Minute, NewWebhookAlerter(server.URL))
+	alerter.Wallet = wallet
+	alerter.Orders = staticOrders{
+		{ID: "0x01", State: OrderStateRunning.String(), UpdatedAt: time.Now().Add(-2 * time.Hour)},
+		{ID: "0x02", State: OrderStateRunning.String(), UpdatedAt: time.Now()},
+		{ID: "0x03", State: OrderStatePaid.String(), UpdatedAt: time.Now().Add(-2 * time.Hour)},
+	}
+
+	consecutiveAPIFailures.Store(0)
+	for i := 0; i < 3; i++ {
+		observeAPICall("list", errors.New("unreachable"))
+	}
+
+	alerter.Check(ctx)
+	alerter.Check(ctx)
+	require.Len(t, sent(), 3)
+	firing := map[string]bool{}
+	for _, alert := range sent() {
+		require.False(t, alert.Resolved)
+		firing[alert.Key] = true
+	}
+	require.Equal(t, map[string]bool{
+		AlertBacalhauFailures:     true,
+		AlertLowBalance:           true,
+		AlertStuckOrder + ":0x01": true,
+	}, firing)
+
+	// Once the conditions clear, each alert is resolved once.
+	observeAPICall("list", nil)
+	wallet.wei = big.NewInt(1000)
+	alerter.Check(ctx)
+	alerter.Check(ctx)
+	require.Len(t, sent(), 5)
+	for _, alert := range sent()[3:] {
+		require.True(t, alert.Resolved)
+		require.NotEqual(t, AlertStuckOrder+":0x01", alert.Key)
+	}
+	require.Len(t, alerter.Firing(), 1)
+}
+
+func TestSlackAndPagerDutyAlerts(t *testing.T) {
+	ctx := context.Background()
+
+	bodies := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies <- body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	alert := Alert{Key: AlertLowBalance, Name: AlertLowBalance, Summary: "Wallet is empty", Time: time.Now()}
+
+	slack := NewSlackAlerter(server.URL)
+	require.NoError(t, slack.Notify(ctx, alert))
+	body := <-bodies
+	require.Contains(t, body["text"], "Wallet is empty")
+
+	pagerDuty := NewPagerDutyAlerter("routing-key").(*pagerDutyAlerter)
+	pagerDuty.url = server.URL
+	alert.Resolved = true
+	require.NoError(t, pagerDuty.Notify(ctx, alert))
+	body = <-bodies
+	require.Equal(t, "routing-key", body["routing_key"])
+	require.Equal(t, "resolve", body["event_action"])
+	require.Equal(t, "lilypad:"+AlertLowBalance, body["dedup_key"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	require.Error(t, NewWebhookAlerter(failing.URL).Notify(ctx, alert))
+}
@@ -0,0 +1,203 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// An AuditAction is a decision the bridge made about an order.
+//
+//go:generate stringer -type=AuditAction --trimprefix=AuditAction
+type AuditAction int
+
+const (
+	// The order was read from the contract and accepted for processing.
+	AuditActionAccepted AuditAction = iota
+	// The order was refused, by policy or for its price, without running.
+	AuditActionRejected
+	// A job was submitted to Bacalhau for the order.
+	AuditActionSubmitted
+	// Something that went wrong with the order is being tried again.
+	AuditActionRetried
+	// A job that failed is being submitted again.
+	AuditActionResubmitted
+	// The job completed.
+	AuditActionCompleted
+	// The job failed.
+	AuditActionJobFailed
+	// The order was given up on and will be refunded.
+	AuditActionFailed
+	// The result was returned on-chain.
+	AuditActionPosted
+	// The error was returned on-chain, refunding the order.
+	AuditActionRefunded
+)
+
+// AuditActions returns every AuditAction.
+func AuditActions() [10]AuditAction {
+	return [10]AuditAction{
+		AuditActionAccepted,
+		AuditActionRejected,
+		AuditActionSubmitted,
+		AuditActionRetried,
+		AuditActionResubmitted,
+		AuditActionCompleted,
+		AuditActionJobFailed,
+		AuditActionFailed,
+		AuditActionPosted,
+		AuditActionRefunded,
+	}
+}
+
+// ParseAuditAction returns the audit action with the passed name, ignoring
+// case.
+func ParseAuditAction(name string) (AuditAction, error) {
+	for _, action := range AuditActions() {
+		if strings.EqualFold(name, action.String()) {
+			return action, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown audit action %q", name)
+}
+
+// An AuditEntry records one decision the bridge made about an order, with
+// what it was based on, so that disputes can be settled from the operator's
+// own records.
+type AuditEntry struct {
+	ID      int64       `json:"id"`
+	OrderID string      `json:"orderId"`
+	Action  AuditAction `json:"action"`
+	Time    time.Time   `json:"time"`
+
+	// The Bacalhau job the decision was about, if any.
+	JobID string `json:"jobId,omitempty"`
+
+	// The transaction that returned the result or error, for Posted and
+	// Refunded entries.
+	TxHash string `json:"txHash,omitempty"`
+
+	// Why the order was rejected, retried or failed.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// The results of a completed job.
+	Results []string `json:"results,omitempty"`
+}
+
+// An AuditFilter selects entries from an AuditLog. Filters that are not set
+// match everything.
+type AuditFilter struct {
+	OrderID string
+	Action  *AuditAction
+	Since   time.Time
+	Until   time.Time
+
+	Limit  uint
+	Offset uint
+}
+
+// An AuditLog keeps an append-only record of every decision the bridge makes.
+// Entries are never changed or removed.
+type AuditLog interface {
+	// RecordAudit appends the entry to the log. Its ID is assigned by the
+	// log.
+	RecordAudit(ctx context.Context, entry AuditEntry) error
+
+	// AuditEntries returns the matching entries, oldest first.
+	AuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// WithAuditLog makes the workflow record every decision it makes about an
+// order in the log.
+func WithAuditLog(log AuditLog) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Audit = log
+	}
+}
+
+// audit records the entry in the workflow's audit log, if it has one. Failing
+// to record an entry doesn't stop the order, but is logged loudly.
+func (workflow *Workflow) audit(ctx context.Context, entry AuditEntry) {
+	if workflow.Audit == nil {
+		return
+	}
+
+	entry.Time = time.Now().UTC()
+	if err := workflow.Audit.RecordAudit(ctx, entry); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", entry.OrderID).Stringer("action", entry.Action).Msg("Unable to record audit entry")
+		auditErrors.Inc()
+	}
+}
+
+// auditEvent records the decision that moved an order from the passed state
+// into the state of the event.
+func (workflow *Workflow) auditEvent(ctx context.Context, from OrderState, e Event) {
+	entry := AuditEntry{OrderID: e.OrderId().Hex()}
+	if running, ok := e.(BacalhauJobRunningEvent); ok && e.OrderState() != OrderStateSubmitted {
+		entry.JobID = running.JobID()
+	}
+
+	switch e.OrderState() {
+	case OrderStateSubmitted:
+		entry.Action = AuditActionAccepted
+		if from == OrderStateJobError {
+			entry.Action = AuditActionResubmitted
+		}
+	case OrderStateRunning:
+		entry.Action = AuditActionSubmitted
+	case OrderStateCompleted:
+		entry.Action = AuditActionCompleted
+		for _, result := range e.(BacalhauJobCompletedEvent).Results(ctx) {
+			entry.Results = append(entry.Results, result.String())
+		}
+	case OrderStateJobError:
+		failed := e.(BacalhauJobFailedEvent)
+		entry.Action = AuditActionJobFailed
+		entry.Reason, entry.Error = failed.FailureReason().String(), failed.Error()
+	case OrderStateFailed:
+		failed := e.(ContractFailedEvent)
+		entry.Action = AuditActionFailed
+		switch failed.FailureReason() {
+		case FailureReasonRejected, FailureReasonUnderpriced, FailureReasonBlocked:
+			entry.Action = AuditActionRejected
+		}
+		entry.Reason, entry.Error = failed.FailureReason().String(), failed.Error()
+	case OrderStatePaid:
+		entry.Action = AuditActionPosted
+		entry.TxHash = transactionHex(e.(ContractPaidEvent).Transaction())
+		for _, result := range e.(BacalhauJobCompletedEvent).Results(ctx) {
+			entry.Results = append(entry.Results, result.String())
+		}
+	case OrderStateRefunded:
+		refunded := e.(ContractRefundedEvent)
+		entry.Action = AuditActionRefunded
+		entry.TxHash = transactionHex(refunded.Transaction())
+		entry.Reason, entry.Error = refunded.FailureReason().String(), refunded.Error()
+	default:
+		return
+	}
+	workflow.audit(ctx, entry)
+}
+
+// auditRetry records that the action on the event failed and will be tried
+// again.
+func (workflow *Workflow) auditRetry(ctx context.Context, e Event, err error) {
+	workflow.audit(ctx, AuditEntry{
+		OrderID: e.OrderId().Hex(),
+		Action:  AuditActionRetried,
+		Reason:  e.OrderState().String(),
+		Error:   err.Error(),
+	})
+}
+
+func transactionHex(txn common.Hash) string {
+	if txn == (common.Hash{}) {
+		return ""
+	}
+	return txn.Hex()
+}
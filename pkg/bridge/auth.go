@@ -0,0 +1,131 @@
+package bridge
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ClientAuth holds the credentials used to talk to a permissioned Bacalhau
+// cluster. The zero value sends no credentials, which is what the public
+// network expects.
+type ClientAuth struct {
+	// Sent as a bearer token with every request, if set.
+	Token string
+
+	// Paths to a PEM encoded client certificate and its private key, presented
+	// to clusters that require mutual TLS. Either both or neither must be set.
+	CertFile string
+	KeyFile  string
+}
+
+// header returns the headers that authenticate a request.
+func (auth ClientAuth) header() http.Header {
+	header := http.Header{}
+	if auth.Token != "" {
+		header.Set("Authorization", "Bearer "+auth.Token)
+	}
+	return header
+}
+
+// certificates loads the client certificate, if one is configured.
+func (auth ClientAuth) certificates() ([]tls.Certificate, error) {
+	if auth.CertFile == "" && auth.KeyFile == "" {
+		return nil, nil
+	} else if auth.CertFile == "" || auth.KeyFile == "" {
+		return nil, fmt.Errorf("both a client certificate and key must be given")
+	}
+
+	cert, err := tls.LoadX509KeyPair(auth.CertFile, auth.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading client certificate")
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+// clientAuthFromEnv reads the credentials from BACALHAU_API_TOKEN,
+// BACALHAU_CLIENT_CERT and BACALHAU_CLIENT_KEY.
+func clientAuthFromEnv() ClientAuth {
+	return ClientAuth{
+		Token:    os.Getenv("BACALHAU_API_TOKEN"),
+		CertFile: os.Getenv("BACALHAU_CLIENT_CERT"),
+		KeyFile:  os.Getenv("BACALHAU_CLIENT_KEY"),
+	}
+}
+
+// TLSOptions control how the identity of a Bacalhau cluster served over HTTPS
+// is checked.
+type TLSOptions struct {
+	// Path to a PEM bundle of certificate authorities to trust as well as the
+	// system ones, for clusters with self-signed certificates.
+	CAFile string
+
+	// Don't check the cluster's certificate at all. Only for testing.
+	InsecureSkipVerify bool
+}
+
+// tlsOptionsFromEnv reads the TLS options from BACALHAU_CA_FILE and
+// BACALHAU_TLS_INSECURE.
+func tlsOptionsFromEnv() (TLSOptions, error) {
+	opts := TLSOptions{CAFile: os.Getenv("BACALHAU_CA_FILE")}
+	if str, found := os.LookupEnv("BACALHAU_TLS_INSECURE"); found && str != "" {
+		insecure, err := strconv.ParseBool(str)
+		if err != nil {
+			return opts, errors.Wrap(err, "BACALHAU_TLS_INSECURE")
+		}
+		opts.InsecureSkipVerify = insecure
+	}
+	return opts, nil
+}
+
+// tlsConfig builds the TLS config used for every connection to the cluster.
+func tlsConfig(opts TLSOptions, auth ClientAuth) (*tls.Config, error) {
+	certificates, err := auth.certificates()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates:       certificates,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading CA bundle")
+		}
+
+		config.RootCAs, err = x509.SystemCertPool()
+		if err != nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CAFile)
+		}
+	}
+	return config, nil
+}
+
+// headerTransport adds fixed headers to every request it sends.
+type headerTransport struct {
+	header http.Header
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+var _ http.RoundTripper = (*headerTransport)(nil)
@@ -0,0 +1,75 @@
+package bridge
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenIsSent(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &headerTransport{
+		header: ClientAuth{Token: "secret"}.header(),
+		base:   http.DefaultTransport,
+	}}
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, "Bearer secret", got)
+}
+
+func TestClientCertificateNeedsKey(t *testing.T) {
+	certificates, err := ClientAuth{}.certificates()
+	require.NoError(t, err)
+	require.Empty(t, certificates)
+
+	_, err = ClientAuth{CertFile: "client.pem"}.certificates()
+	require.Error(t, err)
+
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	_, err = NewJobRunner(WithClientAuth(ClientAuth{KeyFile: "client.key"}))
+	require.Error(t, err)
+}
+
+func TestCustomCABundle(t *testing.T) {
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0600)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		options TLSOptions
+		ok      bool
+	}{
+		{TLSOptions{}, false},
+		{TLSOptions{CAFile: caFile}, true},
+		{TLSOptions{InsecureSkipVerify: true}, true},
+	} {
+		runner, err := NewJobRunner(WithScheme("https"), WithTLS(test.options))
+		require.NoError(t, err)
+
+		res, err := runner.(*bacalhauRunner).endpoints[0].Client.Client.Get(server.URL)
+		if test.ok {
+			require.NoError(t, err)
+			res.Body.Close()
+		} else {
+			require.Error(t, err)
+		}
+	}
+}
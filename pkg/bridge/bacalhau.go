@@ -2,49 +2,183 @@ package bridge
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bacalhau-project/bacalhau/pkg/job"
 	"github.com/bacalhau-project/bacalhau/pkg/model"
-	"github.com/bacalhau-project/bacalhau/pkg/requester/publicapi"
 	"github.com/bacalhau-project/bacalhau/pkg/system"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const LilypadJobAnnotation string = "lilypad-job"
 
+// A namespace is kept in the annotations of the jobs a deployment submits, so
+// that bridges sharing a Bacalhau network only ever see their own jobs. It
+// can't contain dashes, as they separate it from the order ID.
+var validNamespace = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// ValidNamespace returns whether the name can be used as a job namespace.
+func ValidNamespace(namespace string) bool {
+	return validNamespace.MatchString(namespace)
+}
+
+// LilypadAttemptAnnotation prefixes the annotation recording which attempt at
+// running the order a job is, starting from 1.
+const LilypadAttemptAnnotation string = "lilypad-attempt"
+
 func init() {
-	err := system.InitConfig()
-	if err != nil {
-		panic(err)
-	}
+	RegisterRunner(DefaultRunner, func() (JobRunner, error) {
+		return NewJobRunner()
+	})
+}
+
+// SystemConfig says how the Bacalhau client configuration, such as the key
+// used to sign requests to the network, is set up.
+type SystemConfig struct {
+	// Initialized says that the Bacalhau system config has already been set up
+	// by the program embedding the bridge, so that it is used as it is.
+	Initialized bool
+
+	// Init sets up the Bacalhau system config if it hasn't been already.
+	// Defaults to system.InitConfig, which reads or creates the client key
+	// in the Bacalhau config directory.
+	Init func() error
 }
 
-// A JobRunner is a component that converts events into messages into the
-// Bacalhau network.
-type JobRunner interface {
-	// Create starts a new Bacalhau job for the passed contract submission.
-	Create(ctx context.Context, job ContractSubmittedEvent) (BacalhauJobRunningEvent, error)
+var (
+	systemMu          sync.Mutex
+	systemInitialized bool
+)
+
+// Initialize sets up the Bacalhau system config that job runners need to talk
+// to the network. It only has an effect the first time it succeeds, so it can
+// be called by anything that needs the config. NewJobRunner calls it with the
+// defaults if nothing has yet, so programs only need to call it themselves to
+// handle errors early or to use a config they have set up already.
+func Initialize(cfg SystemConfig) error {
+	systemMu.Lock()
+	defer systemMu.Unlock()
 
-	// FindCompleted queries the Bacalhau network for job statuses for the
-	// passed jobs, and returns slices of jobs that have either completed
-	// successfully (according to the network) or have failed.
-	//
-	// Any jobs still in progress are not returned. Any jobs that the network
-	// does not seem to know about are considered failed.
-	FindCompleted(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent)
+	if systemInitialized || cfg.Initialized {
+		systemInitialized = true
+		return nil
+	}
+
+	setup := cfg.Init
+	if setup == nil {
+		setup = system.InitConfig
+	}
+	if err := setup(); err != nil {
+		return errors.Wrap(err, "error initializing Bacalhau system config")
+	}
+	systemInitialized = true
+	return nil
 }
 
 type bacalhauRunner struct {
-	Client *publicapi.RequesterAPIClient
+	submitPolicy BackoffPolicy
+	encrypter    Encrypter
+	watch        bool
+	namespace    string
+
+	// The settings that can be changed whilst running, read with settings.
+	config     RunnerConfig
+	policy     Policy
+	settingsMu sync.RWMutex
+
+	// The clusters that jobs can be submitted to, and how to pick between
+	// them. There is always at least one.
+	endpoints    []*endpoint
+	selection    EndpointSelection
+	nextEndpoint uint32
+
+	// Used to authenticate connections made outside of the API client.
+	tlsConfig *tls.Config
+	header    http.Header
+}
+
+// Health implements HealthChecker
+func (r *bacalhauRunner) Health(ctx context.Context) error {
+	if len(r.candidates()) == 0 {
+		return &bridgeerrors.BacalhauError{Err: fmt.Errorf("circuit breakers for all %d Bacalhau APIs are open", len(r.endpoints))}
+	}
+	return nil
+}
+
+// jobAnnotation returns the annotation that marks a Bacalhau job as being run
+// by this deployment of the bridge.
+func (r *bacalhauRunner) jobAnnotation() string {
+	if r.namespace == "" {
+		return LilypadJobAnnotation
+	}
+	return fmt.Sprintf("%s@%s", LilypadJobAnnotation, r.namespace)
+}
+
+// orderAnnotation returns the annotation that marks a Bacalhau job as being
+// run for the passed order. The order ID is encrypted so that it isn't leaked
+// to everyone else on the network.
+func (r *bacalhauRunner) orderAnnotation(e Event) (string, error) {
+	ciphertext, err := r.encrypter.Encrypt(e.OrderId().Bytes())
+	if err != nil {
+		return "", errors.Wrap(err, "error encrypting order ID")
+	}
+	return fmt.Sprintf("%s-%s", r.jobAnnotation(), hex.EncodeToString(ciphertext)), nil
+}
+
+// orderIdFromAnnotations finds and decrypts the order ID annotation amongst
+// the passed job annotations. Annotations left by other deployments are
+// ignored.
+//
+// Jobs submitted before order IDs were encrypted carry the order ID in the
+// clear, and are still read so that they aren't lost on upgrading. An
+// encrypted order ID is always longer than a plain one, so the two can't be
+// mistaken for each other.
+func (r *bacalhauRunner) orderIdFromAnnotations(annotations []string) (common.Hash, error) {
+	prefix := r.jobAnnotation() + "-"
+	for _, annotation := range annotations {
+		if !strings.HasPrefix(annotation, prefix) {
+			continue
+		}
+
+		suffix := strings.TrimPrefix(strings.TrimPrefix(annotation, prefix), "0x")
+		ciphertext, err := hex.DecodeString(suffix)
+		if err != nil {
+			return common.Hash{}, errors.Wrap(err, "invalid order ID annotation")
+		} else if len(ciphertext) == common.HashLength {
+			return common.BytesToHash(ciphertext), nil
+		}
+
+		plaintext, err := r.encrypter.Decrypt(ciphertext)
+		if err != nil {
+			return common.Hash{}, errors.Wrap(err, "error decrypting order ID")
+		}
+		return common.BytesToHash(plaintext), nil
+	}
+	return common.Hash{}, fmt.Errorf("no order ID annotation found")
 }
 
 // Create implements JobRunner
-func (r *bacalhauRunner) Create(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+func (r *bacalhauRunner) Create(ctx context.Context, e ContractSubmittedEvent) (_ BacalhauJobRunningEvent, err error) {
+	ctx, span := startOrderSpan(ctx, "bacalhau.Submit", e)
+	defer func() { endSpan(span, err) }()
+
 	job, err := model.NewJobWithSaneProductionDefaults()
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating Bacalhau job")
@@ -52,20 +186,163 @@ func (r *bacalhauRunner) Create(ctx context.Context, e ContractSubmittedEvent) (
 
 	job.Spec, err = e.Spec()
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid job spec")
+		return nil, &bridgeerrors.SpecError{Err: err}
+	}
+
+	err = validateSpec(&job.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	_, policy := r.settings()
+	if sanitizer, ok := policy.(Sanitizer); ok {
+		var changes []string
+		changes, err = sanitizer.Sanitize(&job.Spec)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Job refused by sandbox")
+			return nil, err
+		}
+		if len(changes) > 0 {
+			log.Ctx(ctx).Info().Strs("changes", changes).Msg("Rewrote job spec to fit the sandbox")
+			specsRewritten.Inc()
+		}
+	}
+	err = policy.Check(job.Spec)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Job refused by policy")
+		return nil, err
+	}
+
+	annotation, err := r.orderAnnotation(e)
+	if err != nil {
+		return nil, err
 	}
 
 	job.Spec.Annotations = append(job.Spec.Annotations,
-		LilypadJobAnnotation,
-		fmt.Sprintf("%s-%s", LilypadJobAnnotation, e.OrderId()), // TODO do some encryption thing here
+		r.jobAnnotation(),
+		annotation,
+		fmt.Sprintf("%s-%d", LilypadAttemptAnnotation, e.Resubmissions()+1),
 	)
-	job, err = r.Client.Submit(ctx, job)
+	start := time.Now()
+	job, ep, err := r.submit(ctx, e, job)
+	jobSubmitDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		err = errors.Wrap(err, "error submitting Bacalhau job")
+		jobSubmitErrors.Inc()
+		return nil, errors.Wrap(err, "error submitting Bacalhau job")
 	}
 
-	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Str("job", job.Metadata.ID).Msg("Created Bacalhau job")
-	return e.JobCreated(job), err
+	jobsSubmitted.Inc()
+	span.SetAttributes(
+		attribute.String("bacalhau.job_id", job.Metadata.ID),
+		attribute.String("bacalhau.endpoint", ep.URL),
+	)
+
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Str("job", job.Metadata.ID).Str("endpoint", ep.URL).Msg("Created Bacalhau job")
+	return e.JobCreated(job).WithEndpoint(ep.URL), nil
+}
+
+// submit sends the job to the Bacalhau network, retrying according to the
+// runner's submit policy. Before each attempt it checks whether a job for the
+// order is already on the network, so that neither an error on the way back
+// nor the bridge restarting after submission results in the same order being
+// run twice. Each attempt tries every healthy endpoint in turn, returning the
+// one that accepted the job.
+func (r *bacalhauRunner) submit(ctx context.Context, e ContractSubmittedEvent, job *model.Job) (submitted *model.Job, ep *endpoint, err error) {
+	for attempt := uint(0); attempt == 0 || attempt < r.submitPolicy.MaxAttempts; attempt++ {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if attempt > 0 {
+			wait := r.submitPolicy.Wait(attempt)
+			log.Ctx(ctx).Warn().Err(err).Uint("attempt", attempt).Dur("wait", wait).Msg("Retrying Bacalhau job submission")
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+
+		existing, ep, findErr := r.findExisting(ctx, e)
+		if findErr != nil {
+			log.Ctx(ctx).Warn().Err(findErr).Msg("Unable to check for existing Bacalhau job")
+		} else if existing != nil {
+			log.Ctx(ctx).Info().Str("job", existing.Metadata.ID).Str("endpoint", ep.URL).Msg("Resuming previously submitted Bacalhau job")
+			return existing, ep, nil
+		}
+
+		candidates := r.candidates()
+		if len(candidates) == 0 {
+			return nil, nil, &bridgeerrors.BacalhauError{Err: ErrCircuitOpen}
+		}
+
+		for _, ep = range candidates {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			config, _ := r.settings()
+			submitCtx, cancel := context.WithTimeout(ctx, config.SubmitTimeout)
+			err = ep.call(ctx, "submit", func() (err error) {
+				submitted, err = ep.Client.Submit(submitCtx, job)
+				return err
+			})
+			cancel()
+			if err == nil {
+				return submitted, ep, nil
+			} else if bridgeerrors.Classify(err) == bridgeerrors.Terminal {
+				return nil, nil, err
+			}
+			log.Ctx(ctx).Warn().Err(err).Str("endpoint", ep.URL).Msg("Unable to submit to Bacalhau endpoint")
+		}
+	}
+
+	return nil, nil, err
+}
+
+// findExisting returns the Bacalhau job already submitted for the passed
+// order and the endpoint it was found on, or nil if there isn't one. The job
+// the event was last running is not returned, as if we are submitting again
+// that job must have failed.
+func (r *bacalhauRunner) findExisting(ctx context.Context, e ContractSubmittedEvent) (*model.Job, *endpoint, error) {
+	annotation, err := r.orderAnnotation(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Look everywhere, as the job may have been submitted to an endpoint that
+	// has failed since. The order annotation includes the namespace, so jobs
+	// submitted by other deployments are never found. Each endpoint gets its
+	// own deadline, so that one slow endpoint can't use up the time of the
+	// others.
+	config, _ := r.settings()
+	var listErr error
+	tags := []model.IncludedTag{model.IncludedTag(annotation)}
+	for _, ep := range r.endpoints {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+
+		var bacjobs []*model.JobWithInfo
+		listCtx, cancel := context.WithTimeout(ctx, config.ListTimeout)
+		err = ep.call(listCtx, "list", func() (err error) {
+			bacjobs, err = ep.Client.List(listCtx, "", tags, nil, 1, false, "created_at", true)
+			return err
+		})
+		cancel()
+		if err != nil {
+			listErr = err
+			continue
+		} else if len(bacjobs) == 0 {
+			continue
+		}
+
+		existing := &bacjobs[0].Job
+		if running, ok := e.(BacalhauJobRunningEvent); ok && existing.Metadata.ID == running.JobID() {
+			continue
+		}
+		return existing, ep, nil
+	}
+	return nil, nil, listErr
 }
 
 // FindCompleted implements JobRunner
@@ -78,71 +355,281 @@ func (runner *bacalhauRunner) FindCompleted(ctx context.Context, jobs []Bacalhau
 		return completed, failed
 	}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	// TODO: don't limit to 100 jobs...
-	bacjobs, err := runner.Client.List(timeoutCtx, "", []model.IncludedTag{model.IncludedTag(LilypadJobAnnotation)}, nil, 100, false, "created_at", true)
-	if err != nil {
-		log.Ctx(ctx).Error().Err(err).Send()
+	// Don't bother asking about every job if we already know the APIs are down.
+	if len(runner.candidates()) == 0 {
+		log.Ctx(ctx).Debug().Msg("Bacalhau circuit breakers are open, skipping job checks")
 		return completed, failed
 	}
 
+	config, _ := runner.settings()
+	timer := prometheus.NewTimer(findCompletedDuration)
+	defer timer.ObserveDuration()
+
+	// There is no deadline for the whole check, as each request about a job
+	// has its own, so that however many jobs are running, the last ones
+	// checked get as long as the first.
+	spanCtx, span := tracer.Start(ctx, "bacalhau.FindCompleted", trace.WithAttributes(
+		attribute.Int("lilypad.jobs", len(jobs)),
+	))
+	defer span.End()
+
+	// Check jobs concurrently, but only so many at once, so that a large
+	// number of running jobs neither takes forever nor floods the API.
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	concurrency := config.CheckConcurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	workers := make(chan struct{}, concurrency)
+
 	for _, j := range jobs {
-		ctx := log.Ctx(ctx).With().Stringer("id", j.OrderId()).Str("job", j.JobID()).Logger().WithContext(ctx)
-		found := false
+		ctx := log.Ctx(ctx).With().Stringer("id", j.OrderId()).Str("job", j.JobID()).Logger().WithContext(spanCtx)
 
-		for _, bacjob := range bacjobs {
-			if bacjob.Job.Metadata.ID != j.JobID() {
-				continue
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			log.Ctx(ctx).Warn().Err(ctx.Err()).Msg("Stopped checking Bacalhau jobs")
+			wg.Wait()
+			return completed, failed
+		}
+
+		wg.Add(1)
+		go func(j BacalhauJobRunningEvent) {
+			defer func() { <-workers; wg.Done() }()
+			if ctx.Err() != nil {
+				return
 			}
 
-			found = true
-			jobStillRunning := job.WaitForTerminalStates()
-			jobHasErrors := job.WaitExecutionsThrowErrors([]model.ExecutionStateType{model.ExecutionStateFailed})
-			jobComplete := job.WaitForSuccessfulCompletion()
-
-			if ok, err := jobStillRunning(bacjob.State); !ok || err != nil {
-				log.Ctx(ctx).Debug().Err(err).Msg("Bacalhau job still in progress")
-			} else if ok, err := jobComplete(bacjob.State); ok && err == nil {
-				found, cid, stdout, stderr, exitcode := getResult(ctx, bacjob.State, model.JobStateCompleted)
-				if found {
-					log.Ctx(ctx).Info().Err(err).Msg("Bacalhau job completed")
-					completed = append(completed, j.Completed(cid, stdout, stderr, exitcode))
-				} else {
-					log.Ctx(ctx).Error().Msg("No reuslts found for completed job")
-					failed = append(failed, j.JobError("No results found for completed job"))
-				}
-			} else if ok, err := jobHasErrors(bacjob.State); !ok || err != nil {
-				found, _, _, stderr, _ := getResult(ctx, bacjob.State, model.JobStateCompleted)
-				if !found {
-					stderr = "Bacalhau job failed"
-				}
-
-				log.Ctx(ctx).Info().Err(err).Msg("Bacalhau job failed")
-				failed = append(failed, j.JobError(stderr))
-			} else {
-				// This would be a programming error – we haven't taken account
-				// of the states properly.
-				log.Ctx(ctx).Warn().Msg("Bacalhau job in unknown state")
+			done, jobErr, err := runner.checkJob(ctx, j)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Unable to check Bacalhau job")
+			} else if done != nil {
+				jobsCompleted.Inc()
+				completed = append(completed, done)
+			} else if jobErr != nil {
+				jobsFailed.Inc()
+				failed = append(failed, jobErr)
 			}
+		}(j)
+	}
+
+	wg.Wait()
+	return completed, failed
+}
 
-			break
+// jobTimeLimit returns how long a job with the passed spec may run for: the
+// timeout the order asked for, or the runner's limit if that is shorter or the
+// order didn't ask for one. Zero means that the job may run forever.
+func jobTimeLimit(spec model.Spec, limit time.Duration) time.Duration {
+	timeout := time.Duration(spec.Timeout * float64(time.Second))
+	if timeout > 0 && (limit == 0 || timeout < limit) {
+		return timeout
+	}
+	return limit
+}
+
+// timedOut returns the failed event of a job created at the passed time that
+// has run for longer than it may, or nil if it still has time.
+func timedOut(ctx context.Context, j BacalhauJobRunningEvent, spec model.Spec, created time.Time, limit time.Duration, message string) BacalhauJobFailedEvent {
+	limit = jobTimeLimit(spec, limit)
+	age := now().Sub(created)
+	if limit == 0 || age <= limit {
+		return nil
+	}
+
+	expired := &bridgeerrors.ExpiredError{Limit: limit}
+	log.Ctx(ctx).Warn().Err(expired).Dur("age", age).Msg("Bacalhau job timed out")
+	return j.JobFailed(FailureReasonTimeout, expired.Error(), message)
+}
+
+// checkJob looks up the passed job on the Bacalhau network. If the job has
+// finished, it returns either a completed or a failed event. If the job is
+// still in progress, both events are nil.
+func (runner *bacalhauRunner) checkJob(ctx context.Context, j BacalhauJobRunningEvent) (_ BacalhauJobCompletedEvent, _ BacalhauJobFailedEvent, err error) {
+	ctx, span := startOrderSpan(ctx, "bacalhau.CheckJob", j)
+	span.SetAttributes(attribute.String("bacalhau.job_id", j.JobID()))
+	defer func() { endSpan(span, err) }()
+
+	// Each request gets its own deadline within that of the whole check, so
+	// that one slow job doesn't hold up checking the rest.
+	config, _ := runner.settings()
+	ep := runner.endpointFor(j)
+	var bacjob *model.JobWithInfo
+	var found bool
+	getCtx, cancel := stepContext(ctx, config.CheckTimeout)
+	err = ep.call(getCtx, "get", func() (err error) {
+		bacjob, found, err = ep.Client.Get(getCtx, j.JobID())
+		return err
+	})
+	cancel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The job was not seen on the network. This is bad! It may have run but
+	// we just can't be sure. So we will have to treat it as failed. It will
+	// be retried and someone else may run it again. At least this way the
+	// user gets a result – if we just errored out here and refunded the
+	// user, someone may still have done some work and we still wouldn't be
+	// paying them...
+	if !found {
+		log.Ctx(ctx).Error().Msg("Bacalhau job not found")
+		// return nil, j.JobError("Bacalhau job not found"), nil
+		return nil, nil, nil
+	}
+
+	// Make sure the job we found really was submitted for this order, and not
+	// for some other order or by somebody else entirely.
+	orderId, err := runner.orderIdFromAnnotations(bacjob.Job.Spec.Annotations)
+	if err != nil {
+		return nil, nil, err
+	} else if orderId != j.OrderId() {
+		return nil, nil, fmt.Errorf("Bacalhau job belongs to order %s", orderId)
+	}
+	j = j.WithExecutions(executions(bacjob.State))
+	message := stateMessage(bacjob.State)
+
+	jobStillRunning := job.WaitForTerminalStates()
+	jobHasErrors := job.WaitExecutionsThrowErrors([]model.ExecutionStateType{model.ExecutionStateFailed})
+	jobComplete := job.WaitForSuccessfulCompletion()
+
+	if ok, err := jobStillRunning(bacjob.State); !ok || err != nil {
+		// Give up on jobs that have been running for too long. The workflow
+		// will cancel the job on the network when it processes the error.
+		if failed := timedOut(ctx, j, bacjob.Job.Spec, bacjob.Job.Metadata.CreatedAt, config.MaxJobDuration, message); failed != nil {
+			return nil, failed, nil
+		}
+
+		log.Ctx(ctx).Debug().Err(err).Msg("Bacalhau job still in progress")
+	} else if ok, err := jobComplete(bacjob.State); ok && err == nil {
+		found, result, stdout, stderr, exitcode := getResult(ctx, bacjob.State, model.JobStateCompleted)
+
+		resultsCtx, cancel := stepContext(ctx, config.CheckTimeout)
+		results, resultsErr := runner.publishedResults(resultsCtx, ep, j.JobID())
+		cancel()
+		if resultsErr != nil {
+			log.Ctx(ctx).Warn().Err(resultsErr).Msg("Unable to fetch published results")
+		}
+		if !found && len(results) > 0 {
+			found, result = true, results[0]
+		} else if found && len(results) == 0 {
+			results = []cid.Cid{result}
 		}
 
-		// The job was not seen on the network. This is bad! It may have run but
-		// we just can't be sure. So we will have to treat it as failed. It will
-		// be retried and someone else may run it again. At least this way the
-		// user gets a result – if we just errored out here and refunded the
-		// user, someone may still have done some work and we still wouldn't be
-		// paying them...
+		if found {
+			log.Ctx(ctx).Info().Err(err).Int("results", len(results)).Msg("Bacalhau job completed")
+			return j.Completed(result, stdout, stderr, exitcode).WithResults(results), nil, nil
+		} else {
+			log.Ctx(ctx).Error().Msg("No reuslts found for completed job")
+			return nil, j.JobFailed(FailureReasonVerificationFailure, "No results found for completed job", message), nil
+		}
+	} else if bacjob.State.State == model.JobStateCancelled {
+		log.Ctx(ctx).Info().Str("state", message).Msg("Bacalhau job cancelled")
+		return nil, j.JobFailed(FailureReasonCancelled, "Bacalhau job was cancelled", message), nil
+	} else if ok, err := jobHasErrors(bacjob.State); !ok || err != nil {
+		found, _, _, stderr, _ := getResult(ctx, bacjob.State, model.JobStateCompleted)
 		if !found {
-			log.Ctx(ctx).Error().Msg("Bacalhau job not found")
-			// failed = append(failed, j.JobError("Bacalhau job not found"))
+			stderr = "Bacalhau job failed"
 		}
+
+		log.Ctx(ctx).Info().Err(err).Str("state", message).Msg("Bacalhau job failed")
+		return nil, j.JobFailed(FailureReasonExecutionError, stderr, message), nil
+	} else {
+		// This would be a programming error – we haven't taken account
+		// of the states properly.
+		log.Ctx(ctx).Warn().Msg("Bacalhau job in unknown state")
 	}
 
-	return completed, failed
+	return nil, nil, nil
+}
+
+// stepContext returns a context for a single request made as part of a larger
+// operation, which ends after the timeout if that is set, or otherwise with the
+// operation.
+func stepContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Cancel implements JobRunner
+func (runner *bacalhauRunner) Cancel(ctx context.Context, e BacalhauJobRunningEvent) error {
+	if e.JobID() == "" {
+		return nil
+	}
+
+	ep := runner.endpointFor(e)
+	err := ep.call(ctx, "cancel", func() error {
+		_, err := ep.Client.Cancel(ctx, e.JobID(), fmt.Sprintf("Lilypad order %s cancelled", e.OrderId()))
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "error cancelling Bacalhau job")
+	}
+
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Str("job", e.JobID()).Msg("Cancelled Bacalhau job")
+	return nil
+}
+
+// publishedResults asks the network for the CIDs of every result published by
+// the passed job.
+func (runner *bacalhauRunner) publishedResults(ctx context.Context, ep *endpoint, jobID string) ([]cid.Cid, error) {
+	var published []model.PublishedResult
+	err := ep.call(ctx, "results", func() (err error) {
+		published, err = ep.Client.GetResults(ctx, jobID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]cid.Cid, 0, len(published))
+	for _, p := range published {
+		result, err := cid.Parse(p.Data.CID)
+		if err != nil {
+			log.Ctx(ctx).Warn().Str("cid", p.Data.CID).Err(err).Msg("Unable to parse result CID")
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// executions summarises what happened on each node that ran the job.
+func executions(state model.JobState) []Execution {
+	executions := make([]Execution, 0, len(state.Executions))
+	for _, execution := range state.Executions {
+		e := Execution{
+			NodeID: execution.NodeID,
+			State:  execution.State.String(),
+			Status: execution.Status,
+			Result: execution.PublishedResult.CID,
+		}
+		if execution.RunOutput != nil {
+			e.ExitCode = execution.RunOutput.ExitCode
+		}
+		executions = append(executions, e)
+	}
+	return executions
+}
+
+// stateMessage describes the state of the job and of each of its executions,
+// as reported by the network.
+func stateMessage(state model.JobState) string {
+	parts := []string{state.State.String()}
+	for _, execution := range state.Executions {
+		part := fmt.Sprintf("%s: %s", execution.NodeID, execution.State)
+		if execution.Status != "" {
+			part += " (" + execution.Status + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
 }
 
 func getResult(
@@ -176,14 +663,394 @@ func getResult(
 }
 
 var _ JobRunner = (*bacalhauRunner)(nil)
+var _ HealthChecker = (*bacalhauRunner)(nil)
+
+const (
+	defaultAPIHost   string = "35.245.115.191"
+	defaultAPIPort   uint16 = 1234
+	defaultAPIScheme string = "http"
+)
+
+// RunnerConfig holds the settings that control how often and for how long the
+// bridge talks to the Bacalhau network.
+type RunnerConfig struct {
+	// How often to check the state of running jobs.
+	PollInterval time.Duration
+
+	// How long to wait for a single job submission to be accepted.
+	SubmitTimeout time.Duration
+
+	// How long to wait for each endpoint to say whether an order has already
+	// been submitted.
+	ListTimeout time.Duration
+
+	// How long to wait for each request about the state of a single job.
+	CheckTimeout time.Duration
+
+	// How long a job may run for before it is given up on, if the order
+	// didn't ask for less. Zero means that jobs may run for as long as their
+	// order asks, or forever if it doesn't say.
+	MaxJobDuration time.Duration
+
+	// How many jobs to check the state of at the same time.
+	CheckConcurrency uint
+}
+
+var DefaultRunnerConfig = RunnerConfig{
+	PollInterval:     defaultJobCheckInterval,
+	SubmitTimeout:    30 * time.Second,
+	ListTimeout:      5 * time.Second,
+	CheckTimeout:     2 * time.Second,
+	MaxJobDuration:   0,
+	CheckConcurrency: 8,
+}
+
+// RunnerConfigFromEnv returns the default runner config, overridden by any of
+// BACALHAU_POLL_INTERVAL, BACALHAU_SUBMIT_TIMEOUT, BACALHAU_LIST_TIMEOUT,
+// BACALHAU_CHECK_TIMEOUT, BACALHAU_MAX_JOB_DURATION and
+// BACALHAU_CHECK_CONCURRENCY.
+func RunnerConfigFromEnv() (RunnerConfig, error) {
+	config := DefaultRunnerConfig
+	for env, value := range map[string]*time.Duration{
+		"BACALHAU_POLL_INTERVAL":    &config.PollInterval,
+		"BACALHAU_SUBMIT_TIMEOUT":   &config.SubmitTimeout,
+		"BACALHAU_LIST_TIMEOUT":     &config.ListTimeout,
+		"BACALHAU_CHECK_TIMEOUT":    &config.CheckTimeout,
+		"BACALHAU_MAX_JOB_DURATION": &config.MaxJobDuration,
+	} {
+		str, found := os.LookupEnv(env)
+		if !found || str == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(str)
+		if err != nil {
+			return config, errors.Wrap(err, env)
+		} else if duration < 0 || (duration == 0 && value != &config.MaxJobDuration) {
+			return config, fmt.Errorf("%s must be positive", env)
+		}
+		*value = duration
+	}
+
+	if str, found := os.LookupEnv("BACALHAU_CHECK_CONCURRENCY"); found && str != "" {
+		concurrency, err := strconv.ParseUint(str, 10, 32)
+		if err != nil {
+			return config, errors.Wrap(err, "BACALHAU_CHECK_CONCURRENCY")
+		} else if concurrency == 0 {
+			return config, fmt.Errorf("BACALHAU_CHECK_CONCURRENCY must be positive")
+		}
+		config.CheckConcurrency = uint(concurrency)
+	}
+	return config, nil
+}
+
+type runnerOptions struct {
+	host         string
+	port         uint16
+	scheme       string
+	config       RunnerConfig
+	submitPolicy BackoffPolicy
+	encrypter    Encrypter
+	watch        bool
+	namespace    string
+	policy       Policy
+	auth         ClientAuth
+	tls          TLSOptions
+
+	endpoints        []string
+	selection        EndpointSelection
+	breakerThreshold uint
+	breakerCooldown  time.Duration
+}
+
+// A RunnerOption configures the job runner returned by NewJobRunner.
+type RunnerOption func(*runnerOptions)
+
+// WithAPIHost sets the host of the Bacalhau requester API to submit jobs to.
+func WithAPIHost(host string) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.host = host
+	}
+}
+
+// WithAPIPort sets the port of the Bacalhau requester API to submit jobs to.
+func WithAPIPort(port uint16) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.port = port
+	}
+}
+
+// WithScheme sets the URL scheme used to talk to the Bacalhau requester API.
+func WithScheme(scheme string) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.scheme = scheme
+	}
+}
+
+// WithRunnerConfig sets the timeouts used when talking to the Bacalhau network.
+func WithRunnerConfig(config RunnerConfig) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.config = config
+	}
+}
+
+// WithSubmitRetry sets how many times and how often job submissions to the
+// Bacalhau network are retried before the submission is considered failed.
+func WithSubmitRetry(policy BackoffPolicy) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.submitPolicy = policy
+	}
+}
+
+// WithEncrypter sets how the order ID is hidden in the annotations of jobs
+// submitted to the Bacalhau network.
+func WithEncrypter(encrypter Encrypter) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.encrypter = encrypter
+	}
+}
+
+// WithWatch sets whether the runner subscribes to job events from Bacalhau so
+// that finished jobs are noticed before the next poll.
+func WithWatch(watch bool) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.watch = watch
+	}
+}
+
+// WithNamespace sets the namespace kept in the annotations of submitted jobs,
+// so that deployments sharing a Bacalhau network don't see each other's jobs.
+// Jobs submitted under one namespace are not found under any other.
+func WithNamespace(namespace string) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.namespace = namespace
+	}
+}
+
+// WithPolicy sets the policy that decides which jobs the runner will submit.
+func WithPolicy(policy Policy) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.policy = policy
+	}
+}
+
+// WithCircuitBreaker sets how many requests to a Bacalhau API can fail in a row
+// before no more are made to it, and for how long. A threshold of zero means
+// requests are always made.
+func WithCircuitBreaker(threshold uint, cooldown time.Duration) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.breakerThreshold = threshold
+		opts.breakerCooldown = cooldown
+	}
+}
+
+// WithEndpoints sets the URLs of the requester APIs of one or more Bacalhau
+// clusters to submit jobs to, overriding the host, port and scheme.
+func WithEndpoints(urls ...string) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.endpoints = urls
+	}
+}
+
+// WithEndpointSelection sets how the endpoint to submit each job to is picked.
+func WithEndpointSelection(selection EndpointSelection) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.selection = selection
+	}
+}
+
+// WithClientAuth sets the credentials used to talk to a permissioned Bacalhau
+// cluster.
+func WithClientAuth(auth ClientAuth) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.auth = auth
+	}
+}
+
+// WithTLS sets how the certificate of a Bacalhau API served over HTTPS is
+// checked.
+func WithTLS(options TLSOptions) RunnerOption {
+	return func(opts *runnerOptions) {
+		opts.tls = options
+	}
+}
 
-// Returns a real job runner that will make real requests against the Bacalhau network.
-func NewJobRunner() JobRunner {
-	apiPort := uint16(1234)
-	apiHost := os.Getenv("BACALHAU_API_HOST")
-	if apiHost == "" {
-		apiHost = "35.245.115.191"
+// defaultRunnerOptions returns the runner options configured by the
+// environment, falling back to the public Bacalhau network if nothing is set.
+func defaultRunnerOptions() (runnerOptions, error) {
+	opts := runnerOptions{
+		host:         defaultAPIHost,
+		port:         defaultAPIPort,
+		scheme:       defaultAPIScheme,
+		submitPolicy: defaultSubmitPolicy,
+		selection:    EndpointSelectionPriority,
 	}
-	client := publicapi.NewRequesterAPIClient(apiHost, apiPort)
-	return &bacalhauRunner{Client: client}
+
+	if host, found := os.LookupEnv("BACALHAU_API_HOST"); found && host != "" {
+		opts.host = host
+	}
+
+	if portStr, found := os.LookupEnv("BACALHAU_API_PORT"); found && portStr != "" {
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return opts, errors.Wrap(err, "BACALHAU_API_PORT")
+		}
+		opts.port = uint16(port)
+	}
+
+	if scheme, found := os.LookupEnv("BACALHAU_API_SCHEME"); found && scheme != "" {
+		opts.scheme = scheme
+	}
+
+	if endpoints, found := os.LookupEnv("BACALHAU_API_ENDPOINTS"); found && endpoints != "" {
+		opts.endpoints = parseEndpoints(endpoints)
+	}
+
+	if selection, found := os.LookupEnv("BACALHAU_ENDPOINT_SELECTION"); found && selection != "" {
+		opts.selection = EndpointSelection(selection)
+	}
+
+	config, err := RunnerConfigFromEnv()
+	if err != nil {
+		return opts, err
+	}
+	opts.config = config
+
+	if watchStr, found := os.LookupEnv("BACALHAU_WATCH_EVENTS"); found && watchStr != "" {
+		watch, err := strconv.ParseBool(watchStr)
+		if err != nil {
+			return opts, errors.Wrap(err, "BACALHAU_WATCH_EVENTS")
+		}
+		opts.watch = watch
+	}
+
+	if namespace, found := os.LookupEnv("BACALHAU_NAMESPACE"); found {
+		opts.namespace = namespace
+	}
+
+	opts.auth = clientAuthFromEnv()
+	opts.tls, err = tlsOptionsFromEnv()
+	if err != nil {
+		return opts, err
+	}
+
+	opts.breakerThreshold, opts.breakerCooldown, err = breakerFromEnv()
+	if err != nil {
+		return opts, err
+	}
+
+	policy, err := policyFromEnv()
+	if err != nil {
+		return opts, err
+	}
+	opts.policy = policy
+
+	encrypter, err := encrypterFromEnv()
+	if err != nil {
+		return opts, err
+	}
+	opts.encrypter = encrypter
+
+	return opts, nil
+}
+
+// Returns a real job runner that will make real requests against the Bacalhau
+// network. Any options passed take precedence over the environment.
+func NewJobRunner(options ...RunnerOption) (JobRunner, error) {
+	if err := Initialize(SystemConfig{}); err != nil {
+		return nil, err
+	}
+
+	opts, err := defaultRunnerOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if opts.selection != EndpointSelectionPriority && opts.selection != EndpointSelectionRoundRobin {
+		return nil, fmt.Errorf("unknown Bacalhau endpoint selection %q", opts.selection)
+	}
+
+	if opts.namespace != "" && !ValidNamespace(opts.namespace) {
+		return nil, fmt.Errorf("invalid Bacalhau namespace %q", opts.namespace)
+	}
+
+	// Without a key, anyone on the network could tell which order each of
+	// our jobs is for.
+	if opts.encrypter == nil {
+		return nil, fmt.Errorf("ANNOTATION_ENCRYPTION_KEY must be set to a hex-encoded 16, 24 or 32 byte key")
+	}
+
+	config, err := tlsConfig(opts.tls, opts.auth)
+	if err != nil {
+		return nil, err
+	}
+	if config.InsecureSkipVerify {
+		log.Warn().Msg("Not verifying the TLS certificate of the Bacalhau API")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	header := opts.auth.header()
+
+	client := &http.Client{Transport: &headerTransport{header: header, base: transport}}
+
+	urls := opts.endpoints
+	if len(urls) == 0 {
+		urls = []string{fmt.Sprintf("%s://%s", opts.scheme, net.JoinHostPort(opts.host, strconv.FormatUint(uint64(opts.port), 10)))}
+	}
+
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, url := range urls {
+		ep, err := newEndpoint(url, client, NewCircuitBreaker(opts.breakerThreshold, opts.breakerCooldown))
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, ep)
+	}
+
+	return &bacalhauRunner{
+		config:       opts.config,
+		submitPolicy: opts.submitPolicy,
+		encrypter:    opts.encrypter,
+		watch:        opts.watch,
+		namespace:    opts.namespace,
+		policy:       opts.policy,
+		endpoints:    endpoints,
+		selection:    opts.selection,
+		tlsConfig:    transport.TLSClientConfig,
+		header:       header,
+	}, nil
+}
+
+const (
+	defaultBreakerThreshold uint          = 5
+	defaultBreakerCooldown  time.Duration = 30 * time.Second
+)
+
+// breakerFromEnv returns the circuit breaker settings: the breaker opens after
+// BACALHAU_BREAKER_THRESHOLD consecutive failures and probes again after
+// BACALHAU_BREAKER_COOLDOWN. A threshold of zero disables the breaker.
+func breakerFromEnv() (threshold uint, cooldown time.Duration, err error) {
+	threshold, cooldown = defaultBreakerThreshold, defaultBreakerCooldown
+
+	if str, found := os.LookupEnv("BACALHAU_BREAKER_THRESHOLD"); found && str != "" {
+		value, err := strconv.ParseUint(str, 10, 32)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "BACALHAU_BREAKER_THRESHOLD")
+		}
+		threshold = uint(value)
+	}
+
+	if str, found := os.LookupEnv("BACALHAU_BREAKER_COOLDOWN"); found && str != "" {
+		cooldown, err = time.ParseDuration(str)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "BACALHAU_BREAKER_COOLDOWN")
+		}
+	}
+
+	return threshold, cooldown, nil
 }
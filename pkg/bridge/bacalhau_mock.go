@@ -2,6 +2,9 @@ package bridge
 
 import (
 	"context"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/bacalhau-project/bacalhau/pkg/model"
 	"github.com/ipfs/go-cid"
@@ -10,6 +13,7 @@ import (
 
 type RunnerCreateHandler func(context.Context, ContractSubmittedEvent) (BacalhauJobRunningEvent, error)
 type RunnerFindCompletedHandler func(context.Context, []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent)
+type RunnerCancelHandler func(context.Context, BacalhauJobRunningEvent) error
 
 var SuccessfulCreate RunnerCreateHandler = func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
 	return cse.JobCreated(model.NewJob()), nil
@@ -35,15 +39,140 @@ var FailedFind RunnerFindCompletedHandler = func(ctx context.Context, jobs []Bac
 	return nil, failed
 }
 
+// ErrInjectedFault is returned by a mockRunner that was told to fail a call.
+var ErrInjectedFault = errors.New("injected fault")
+
+// RunnerFaults are faults that a mockRunner injects around its handlers, to
+// test that the workflow copes with a compute network that is slow, flaky and
+// inconsistent. Chances are between 0 and 1.
+type RunnerFaults struct {
+	// How long each call takes, plus a random amount up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// The chance that creating a job fails with ErrInjectedFault.
+	CreateFailureRate float64
+
+	// The chance that a job that completed is reported as having failed.
+	JobFailureRate float64
+
+	// The chance that a job that finished isn't reported until a later call,
+	// so that each call only returns some of the results.
+	HoldBackRate float64
+
+	// Whether finished jobs are reported in a random order rather than the
+	// order they were asked about.
+	Reorder bool
+
+	// The chance that a call acts as if its context was cancelled partway
+	// through, returning context.Canceled or nothing.
+	CancelRate float64
+
+	// Where chances are drawn from, so that runs can be repeated. If nil, it
+	// is seeded from the time.
+	Rand *rand.Rand
+
+	mu sync.Mutex
+}
+
+// wait waits for the latency of a call, returning early with the context's
+// error if it is cancelled.
+func (f *RunnerFaults) wait(ctx context.Context) error {
+	latency := f.Latency
+	if f.Jitter > 0 {
+		latency += time.Duration(f.float() * float64(f.Jitter))
+	}
+	if latency <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chance returns true with the passed probability.
+func (f *RunnerFaults) chance(probability float64) bool {
+	return probability > 0 && f.float() < probability
+}
+
+func (f *RunnerFaults) float() (value float64) {
+	f.random(func(r *rand.Rand) { value = r.Float64() })
+	return value
+}
+
+func (f *RunnerFaults) shuffle(n int, swap func(i, j int)) {
+	f.random(func(r *rand.Rand) { r.Shuffle(n, swap) })
+}
+
+// random calls the function with the source of chances, which can't be used
+// by more than one goroutine at a time.
+func (f *RunnerFaults) random(use func(*rand.Rand)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Rand == nil {
+		f.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	use(f.Rand)
+}
+
+// finished injects faults into the jobs that a handler found had finished.
+func (f *RunnerFaults) finished(completed []BacalhauJobCompletedEvent, failed []BacalhauJobFailedEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+	keptCompleted := []BacalhauJobCompletedEvent{}
+	keptFailed := []BacalhauJobFailedEvent{}
+	for _, job := range completed {
+		if f.chance(f.HoldBackRate) {
+			continue
+		} else if f.chance(f.JobFailureRate) {
+			keptFailed = append(keptFailed, job.JobError(ErrInjectedFault.Error()))
+		} else {
+			keptCompleted = append(keptCompleted, job)
+		}
+	}
+	for _, job := range failed {
+		if !f.chance(f.HoldBackRate) {
+			keptFailed = append(keptFailed, job)
+		}
+	}
+
+	if f.Reorder {
+		f.shuffle(len(keptCompleted), func(i, j int) {
+			keptCompleted[i], keptCompleted[j] = keptCompleted[j], keptCompleted[i]
+		})
+		f.shuffle(len(keptFailed), func(i, j int) {
+			keptFailed[i], keptFailed[j] = keptFailed[j], keptFailed[i]
+		})
+	}
+	return keptCompleted, keptFailed
+}
+
 // A JobRunner that won't make real requests and instead just runs the supplied
-// functions when its methods are called.
+// functions when its methods are called, with any Faults injected around them.
 type mockRunner struct {
 	CreateHandler        RunnerCreateHandler
 	FindCompletedHandler RunnerFindCompletedHandler
+	CancelHandler        RunnerCancelHandler
+
+	Faults *RunnerFaults
 }
 
 // Create implements JobRunner
 func (mock *mockRunner) Create(ctx context.Context, job ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+	if faults := mock.Faults; faults != nil {
+		if err := faults.wait(ctx); err != nil {
+			return nil, err
+		} else if faults.chance(faults.CancelRate) {
+			return nil, context.Canceled
+		} else if faults.chance(faults.CreateFailureRate) {
+			return nil, ErrInjectedFault
+		}
+	}
+
 	if mock.CreateHandler != nil {
 		return mock.CreateHandler(ctx, job)
 	} else {
@@ -53,11 +182,40 @@ func (mock *mockRunner) Create(ctx context.Context, job ContractSubmittedEvent)
 
 // FindCompleted implements JobRunner
 func (mock *mockRunner) FindCompleted(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+	if faults := mock.Faults; faults != nil {
+		if faults.wait(ctx) != nil || faults.chance(faults.CancelRate) {
+			return nil, nil
+		}
+	}
+
+	var completed []BacalhauJobCompletedEvent
+	var failed []BacalhauJobFailedEvent
 	if mock.FindCompletedHandler != nil {
-		return mock.FindCompletedHandler(ctx, jobs)
+		completed, failed = mock.FindCompletedHandler(ctx, jobs)
 	} else {
-		return SuccssfulFind(ctx, jobs)
+		completed, failed = SuccssfulFind(ctx, jobs)
+	}
+
+	if mock.Faults != nil {
+		return mock.Faults.finished(completed, failed)
+	}
+	return completed, failed
+}
+
+// Cancel implements JobRunner
+func (mock *mockRunner) Cancel(ctx context.Context, job BacalhauJobRunningEvent) error {
+	if faults := mock.Faults; faults != nil {
+		if err := faults.wait(ctx); err != nil {
+			return err
+		} else if faults.chance(faults.CancelRate) {
+			return context.Canceled
+		}
+	}
+
+	if mock.CancelHandler != nil {
+		return mock.CancelHandler(ctx, job)
 	}
+	return nil
 }
 
 var _ JobRunner = (*mockRunner)(nil)
@@ -0,0 +1,207 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerOptionsFromEnvironment(t *testing.T) {
+	t.Setenv("BACALHAU_API_HOST", "localhost")
+	t.Setenv("BACALHAU_API_PORT", "4321")
+	t.Setenv("BACALHAU_API_SCHEME", "https")
+
+	opts, err := defaultRunnerOptions()
+	require.NoError(t, err)
+	require.Equal(t, "localhost", opts.host)
+	require.Equal(t, uint16(4321), opts.port)
+	require.Equal(t, "https", opts.scheme)
+}
+
+func TestRunnerOptionsOverrideEnvironment(t *testing.T) {
+	t.Setenv("BACALHAU_API_HOST", "localhost")
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+
+	runner, err := NewJobRunner(WithAPIHost("example.com"), WithAPIPort(80), WithScheme("http"))
+	require.NoError(t, err)
+	require.Equal(t, "http://example.com:80", runner.(*bacalhauRunner).endpoints[0].Client.BaseURI)
+}
+
+func TestInvalidRunnerOptions(t *testing.T) {
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	t.Setenv("BACALHAU_API_PORT", "not-a-port")
+	_, err := NewJobRunner()
+	require.Error(t, err)
+
+	t.Setenv("BACALHAU_API_PORT", "")
+	_, err = NewJobRunner(WithScheme("ftp"))
+	require.Error(t, err)
+}
+
+func TestInitializeReturnsErrors(t *testing.T) {
+	systemMu.Lock()
+	initialized := systemInitialized
+	systemInitialized = false
+	systemMu.Unlock()
+	t.Cleanup(func() {
+		systemMu.Lock()
+		systemInitialized = initialized
+		systemMu.Unlock()
+	})
+
+	err := Initialize(SystemConfig{Init: func() error { return fmt.Errorf("no config directory") }})
+	require.ErrorContains(t, err, "no config directory")
+
+	calls := 0
+	require.NoError(t, Initialize(SystemConfig{Initialized: true}))
+	require.NoError(t, Initialize(SystemConfig{Init: func() error { calls++; return nil }}))
+	require.Zero(t, calls, "an injected config shouldn't be set up again")
+}
+
+func TestOrderAnnotationRoundTrip(t *testing.T) {
+	enc, err := NewAESEncrypter([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	runner := &bacalhauRunner{encrypter: enc}
+	e := exampleEvent()
+
+	annotation, err := runner.orderAnnotation(e)
+	require.NoError(t, err)
+	require.NotContains(t, annotation, e.OrderId().Hex()[2:])
+
+	orderId, err := runner.orderIdFromAnnotations([]string{LilypadJobAnnotation, annotation})
+	require.NoError(t, err)
+	require.Equal(t, e.OrderId(), orderId)
+}
+
+func TestLegacyOrderAnnotations(t *testing.T) {
+	enc, err := NewAESEncrypter([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	runner := &bacalhauRunner{encrypter: enc}
+	e := exampleEvent()
+
+	legacy := fmt.Sprintf("%s-%s", LilypadJobAnnotation, e.OrderId())
+	orderId, err := runner.orderIdFromAnnotations([]string{LilypadJobAnnotation, legacy})
+	require.NoError(t, err, "jobs submitted before order IDs were encrypted should still be read")
+	require.Equal(t, e.OrderId(), orderId)
+}
+
+func TestNamespacedRunnersIgnoreEachOthersJobs(t *testing.T) {
+	enc, err := NewAESEncrypter([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	e := exampleEvent()
+
+	production := &bacalhauRunner{encrypter: enc, namespace: "production"}
+	annotation, err := production.orderAnnotation(e)
+	require.NoError(t, err)
+	orderId, err := production.orderIdFromAnnotations([]string{production.jobAnnotation(), annotation})
+	require.NoError(t, err)
+	require.Equal(t, e.OrderId(), orderId)
+
+	for _, other := range []*bacalhauRunner{
+		{encrypter: enc},
+		{encrypter: enc, namespace: "staging"},
+		{encrypter: enc, namespace: "prod"},
+	} {
+		_, err = other.orderIdFromAnnotations([]string{production.jobAnnotation(), annotation})
+		require.Error(t, err, other.namespace)
+	}
+
+	require.False(t, ValidNamespace("prod-eu"))
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	_, err = NewJobRunner(WithNamespace("prod-eu"))
+	require.Error(t, err)
+}
+
+func TestRunnerGivesUpOnSlowRequests(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+	}))
+	defer server.Close()
+
+	runner := testEndpoints(t, EndpointSelectionPriority, server.URL)
+	runner.encrypter = plaintextEncrypter{}
+	runner.endpoints[0].breaker = nil
+	runner.config = RunnerConfig{ListTimeout: 60 * time.Millisecond, CheckTimeout: 50 * time.Millisecond, CheckConcurrency: 1}
+
+	jobs := []BacalhauJobRunningEvent{}
+	for i := 0; i < 3; i++ {
+		job := model.NewJob()
+		job.Metadata.ID = fmt.Sprint(i)
+		jobs = append(jobs, exampleEvent().JobCreated(job))
+	}
+
+	// One slow job shouldn't use up the time to check the others, and
+	// checking them all may take longer than listing would be given.
+	start := time.Now()
+	completed, failed := runner.FindCompleted(context.Background(), jobs)
+	require.Empty(t, completed)
+	require.Empty(t, failed)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Equal(t, int64(3), requests.Load())
+
+	// Nothing is sent once the context has been cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := runner.submit(ctx, exampleEvent(), model.NewJob())
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int64(3), requests.Load())
+}
+
+func TestRunnerConfigFromEnvironment(t *testing.T) {
+	t.Setenv("BACALHAU_POLL_INTERVAL", "1m")
+	t.Setenv("BACALHAU_LIST_TIMEOUT", "")
+
+	config, err := RunnerConfigFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, config.PollInterval)
+	require.Equal(t, DefaultRunnerConfig.SubmitTimeout, config.SubmitTimeout)
+	require.Equal(t, DefaultRunnerConfig.ListTimeout, config.ListTimeout)
+	require.Equal(t, DefaultRunnerConfig.CheckConcurrency, config.CheckConcurrency)
+
+	t.Setenv("BACALHAU_CHECK_CONCURRENCY", "32")
+	config, err = RunnerConfigFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, uint(32), config.CheckConcurrency)
+
+	t.Setenv("BACALHAU_CHECK_CONCURRENCY", "0")
+	_, err = RunnerConfigFromEnv()
+	require.Error(t, err)
+	t.Setenv("BACALHAU_CHECK_CONCURRENCY", "")
+
+	t.Setenv("BACALHAU_SUBMIT_TIMEOUT", "-1s")
+	_, err = RunnerConfigFromEnv()
+	require.Error(t, err)
+}
+
+func TestJobsTimeOut(t *testing.T) {
+	ctx := context.Background()
+	job := func() BacalhauJobRunningEvent { return exampleEvent().JobCreated(model.NewJob()) }
+	started := now().Add(-2 * time.Hour)
+
+	require.Nil(t, timedOut(ctx, job(), model.Spec{}, started, 0, ""), "jobs shouldn't time out without a limit")
+	require.Nil(t, timedOut(ctx, job(), model.Spec{Timeout: 3 * 60 * 60}, started, 0, ""))
+	require.Nil(t, timedOut(ctx, job(), model.Spec{}, started, 3*time.Hour, ""))
+
+	failed := timedOut(ctx, job(), model.Spec{Timeout: 60 * 60}, started, 0, "InProgress")
+	require.NotNil(t, failed, "the order's own timeout should be kept to")
+	require.Equal(t, FailureReasonTimeout, failed.FailureReason())
+	require.Contains(t, failed.Error(), "1h0m0s")
+
+	failed = timedOut(ctx, job(), model.Spec{Timeout: 3 * 60 * 60}, started, time.Hour, "InProgress")
+	require.NotNil(t, failed, "orders shouldn't run for longer than the runner allows")
+	require.Equal(t, OrderStateJobError, failed.OrderState())
+}
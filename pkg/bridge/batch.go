@@ -0,0 +1,115 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A BatchCompleter is a SmartContract that can return the results of several
+// orders in a single transaction, which costs much less gas than sending a
+// transaction for each.
+type BatchCompleter interface {
+	// CompleteBatch returns the results of every passed event, returning the
+	// paid events in the same order. Either every result is returned or none
+	// of them are.
+	CompleteBatch(context.Context, []BacalhauJobCompletedEvent) ([]ContractPaidEvent, error)
+}
+
+// WithResultBatching makes the workflow return up to size results in each
+// transaction, waiting up to window after a job completes for others to send
+// with it. Batching only happens if the contract is a BatchCompleter, and a
+// size of one or less means every result is returned on its own.
+func WithResultBatching(window time.Duration, size int) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.batchWindow = window
+		workflow.batchSize = size
+	}
+}
+
+// runBatches gathers completed events into batches and returns their results,
+// using workCtx for the transactions themselves. It will block until the
+// passed context is cancelled and any batch being sent has finished. Events
+// still waiting for a batch are left alone, as they have been saved and will
+// be reloaded when the workflow next starts.
+func (workflow *Workflow) runBatches(ctx, workCtx context.Context, batcher BatchCompleter, completions <-chan Event, processedEvents chan<- Event) {
+	for {
+		var batch []BacalhauJobCompletedEvent
+		select {
+		case event := <-completions:
+			batch = append(batch, event.(BacalhauJobCompletedEvent))
+		case <-ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(workflow.batchWindow)
+	gather:
+		for len(batch) < workflow.batchSize {
+			select {
+			case event := <-completions:
+				batch = append(batch, event.(BacalhauJobCompletedEvent))
+			case <-timer.C:
+				break gather
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+		timer.Stop()
+
+		results, waits := workflow.completeBatch(workCtx, batcher, batch)
+		if ctx.Err() != nil {
+			return
+		}
+		for i, result := range results {
+			workflow.requeue(ctx, result, waits[i], processedEvents)
+		}
+	}
+}
+
+// completeBatch returns the results of the passed events in one transaction,
+// and saves them as paid. If the batch can't be sent, for instance because one
+// of the requestors rejects its result, each result is returned on its own so
+// that one bad order can't hold up the others.
+func (workflow *Workflow) completeBatch(ctx context.Context, batcher BatchCompleter, batch []BacalhauJobCompletedEvent) ([]Event, []time.Duration) {
+	results := make([]Event, len(batch))
+	waits := make([]time.Duration, len(batch))
+
+	var paid []ContractPaidEvent
+	var err error
+	if len(batch) > 1 {
+		// If any order can't be claimed, every order is claimed and settled
+		// one at a time below instead.
+		claimed := make([]Event, 0, len(batch))
+		for _, event := range batch {
+			claimed = append(claimed, event)
+		}
+		var release func(posted bool)
+		release, err = workflow.claimPostings(ctx, claimed...)
+		if err == nil {
+			for _, event := range batch {
+				intended := workflow.writeIntent(ctx, event.Paid())
+				defer intended()
+			}
+			paid, err = batcher.CompleteBatch(ctx, batch)
+			release(paid != nil)
+			resultBatchSize.Observe(float64(len(batch)))
+		}
+		log.Ctx(ctx).WithLevel(level(err)).Err(err).Int("count", len(batch)).Msg("Returning results in a batch")
+	}
+
+	for i, event := range batch {
+		if paid == nil {
+			results[i], waits[i] = workflow.ProcessEvent(ctx, event)
+			continue
+		}
+
+		eventCtx := orderContext(ctx, event)
+		eventsProcessed.WithLabelValues(event.OrderState().String()).Inc()
+		workflow.fetchResults(eventCtx, event)
+		workflow.pinResults(eventCtx, event)
+		results[i], waits[i] = workflow.settle(eventCtx, event, paid[i], 0, nil)
+	}
+	return results, waits
+}
@@ -0,0 +1,36 @@
+package bridge
+
+import (
+	"fmt"
+
+	"github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadEventsUpgradeable.sol"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The contract's typed bindings are generated by abigen from the ABI that
+// hardhat compiles, as part of building the bridge. Regenerate them whenever
+// the contract's events or methods change.
+//
+//go:generate make -C ../.. bindings
+
+// orderFromLog turns an order decoded from the contract's logs into a
+// submitted event. The details that contracts emit in events of their own,
+// such as the price, are filled in by the caller.
+func orderFromLog(job *LilypadEventsUpgradeable.LilypadEventsUpgradeableNewLilypadJobSubmitted, contract common.Address) (*event, error) {
+	if !ResultType(job.Job.ResultType).Valid() {
+		return nil, fmt.Errorf("%w: result type %d", ErrInvalidOrder, job.Job.ResultType)
+	} else if job.Job.Id == nil || !job.Job.Id.IsInt64() {
+		return nil, fmt.Errorf("%w: order number %v", ErrInvalidOrder, job.Job.Id)
+	}
+
+	return &event{
+		orderId:         job.Raw.TxHash.Bytes(),
+		orderOwner:      job.Job.Requestor.Bytes(),
+		orderNumber:     job.Job.Id.Int64(),
+		orderResultType: job.Job.ResultType,
+		state:           OrderStateSubmitted,
+		jobSpec:         []byte(job.Job.Spec),
+		timeline:        Timeline{Observed: now()},
+		orderContract:   contract.Bytes(),
+	}, nil
+}
@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadEventsUpgradeable.sol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedLog reads a log, as returned by an RPC endpoint, from testdata/logs.
+func recordedLog(t *testing.T, name string) types.Log {
+	data, err := os.ReadFile(filepath.Join("testdata", "logs", name+".json"))
+	require.NoError(t, err)
+
+	var log types.Log
+	require.NoError(t, json.Unmarshal(data, &log))
+	return log
+}
+
+func TestOrdersAreDecodedFromRecordedLogs(t *testing.T) {
+	contract := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	filterer, err := LilypadEventsUpgradeable.NewLilypadEventsUpgradeableFilterer(contract, nil)
+	require.NoError(t, err)
+
+	submitted := recordedLog(t, "NewLilypadJobSubmitted")
+	job, err := filterer.ParseNewLilypadJobSubmitted(submitted)
+	require.NoError(t, err)
+
+	order, err := orderFromLog(job, contract)
+	require.NoError(t, err)
+	require.Equal(t, OrderStateSubmitted, order.OrderState())
+	require.Equal(t, submitted.TxHash, order.OrderId())
+	require.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), order.OrderRequestor())
+	require.Equal(t, int64(1), order.OrderNumber())
+	require.Equal(t, ResultTypeStdOut, order.OrderResultType())
+	require.Contains(t, string(order.jobSpec), `"Image":"ubuntu"`)
+	require.Equal(t, contract, order.SourceContract())
+
+	price, err := filterer.ParseLilypadJobPriceOffered(recordedLog(t, "LilypadJobPriceOffered"))
+	require.NoError(t, err)
+	require.Equal(t, order.OrderNumber(), price.Id.Int64())
+	require.Equal(t, "10000000000000000", price.Price.String())
+
+	// The price is a different event, so isn't decoded as an order.
+	_, err = filterer.ParseNewLilypadJobSubmitted(recordedLog(t, "LilypadJobPriceOffered"))
+	require.Error(t, err)
+
+	job, err = filterer.ParseNewLilypadJobSubmitted(recordedLog(t, "NewLilypadJobSubmitted-invalid"))
+	require.NoError(t, err)
+	_, err = orderFromLog(job, contract)
+	require.True(t, errors.Is(err, ErrInvalidOrder))
+}
@@ -0,0 +1,142 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// An AddressList decides which clients, by the wallet that made an order, the
+// bridge takes orders from. Orders from a blocked address are refused, and if
+// any addresses are allowed, so are orders from every other address. The lists
+// can be changed whilst the bridge is running.
+type AddressList struct {
+	mu      sync.RWMutex
+	blocked map[common.Address]bool
+	allowed map[common.Address]bool
+
+	// Whether refused orders are rejected on-chain, which refunds them, or
+	// only recorded as failed and left alone, so that abusive clients aren't
+	// given anything back for gas.
+	RejectOnChain bool
+}
+
+// AddressLists are the blocked and allowed addresses of an AddressList.
+type AddressLists struct {
+	Blocked []common.Address `json:"blocked"`
+	Allowed []common.Address `json:"allowed"`
+}
+
+// NewAddressList returns a list that blocks and allows the passed addresses.
+func NewAddressList(lists AddressLists, rejectOnChain bool) *AddressList {
+	list := &AddressList{
+		blocked:       map[common.Address]bool{},
+		allowed:       map[common.Address]bool{},
+		RejectOnChain: rejectOnChain,
+	}
+	for _, address := range lists.Blocked {
+		list.blocked[address] = true
+	}
+	for _, address := range lists.Allowed {
+		list.allowed[address] = true
+	}
+	return list
+}
+
+// ParseAddresses reads hex addresses.
+func ParseAddresses(entries []string) ([]common.Address, error) {
+	addresses := make([]common.Address, 0, len(entries))
+	for _, entry := range entries {
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("%q is not an address", entry)
+		}
+		addresses = append(addresses, common.HexToAddress(entry))
+	}
+	return addresses, nil
+}
+
+// WithAddressList makes the workflow refuse orders from clients that the list
+// doesn't permit.
+func WithAddressList(list *AddressList) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Addresses = list
+	}
+}
+
+// Permits returns whether orders from the address are taken.
+func (l *AddressList) Permits(address common.Address) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.blocked[address] {
+		return false
+	}
+	return len(l.allowed) == 0 || l.allowed[address]
+}
+
+// Block adds the address to the blocked list, or takes it off if blocked is
+// false.
+func (l *AddressList) Block(address common.Address, blocked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if blocked {
+		l.blocked[address] = true
+	} else {
+		delete(l.blocked, address)
+	}
+}
+
+// Allow adds the address to the allowed list, or takes it off if allowed is
+// false. Taking the last address off the allowed list allows every address
+// that isn't blocked.
+func (l *AddressList) Allow(address common.Address, allowed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if allowed {
+		l.allowed[address] = true
+	} else {
+		delete(l.allowed, address)
+	}
+}
+
+// Lists returns the blocked and allowed addresses, in order.
+func (l *AddressList) Lists() AddressLists {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return AddressLists{Blocked: sortedAddresses(l.blocked), Allowed: sortedAddresses(l.allowed)}
+}
+
+func sortedAddresses(set map[common.Address]bool) []common.Address {
+	addresses := make([]common.Address, 0, len(set))
+	for address := range set {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	return addresses
+}
+
+// rejectsOnChain returns whether orders the list refuses should be refunded.
+func (l *AddressList) rejectsOnChain() bool {
+	return l != nil && l.RejectOnChain
+}
+
+// checkClient returns a *Rejection if the order was made by a client that the
+// workflow doesn't take orders from.
+func (workflow *Workflow) checkClient(ctx context.Context, e ContractSubmittedEvent) error {
+	if workflow.Addresses == nil || workflow.Addresses.Permits(e.OrderRequestor()) {
+		return nil
+	}
+
+	log.Ctx(ctx).Warn().Stringer("client", e.OrderRequestor()).Msg("Refusing order from blocked address")
+	ordersBlocked.Inc()
+	return &Rejection{
+		Reason:        fmt.Sprintf("orders from %s are not accepted", e.OrderRequestor()),
+		FailureReason: FailureReasonBlocked,
+	}
+}
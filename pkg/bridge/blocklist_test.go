@@ -0,0 +1,81 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersFromBlockedAddressesAreRefused(t *testing.T) {
+	ctx := context.Background()
+	for _, rejectOnChain := range []bool{false, true} {
+		list := NewAddressList(AddressLists{Blocked: []common.Address{busyClient}}, rejectOnChain)
+		workflow := NewWorkflow(&mockRunner{CreateHandler: SuccessfulCreate}, &mockContract{}, repository(t), WithAddressList(list))
+
+		refused, _ := workflow.ProcessEvent(ctx, clientEvent(0x01, busyClient))
+		require.Equal(t, OrderStateFailed, refused.OrderState())
+		require.Equal(t, FailureReasonBlocked, refused.(ContractFailedEvent).FailureReason())
+
+		result, _ := workflow.ProcessEvent(ctx, refused)
+		if rejectOnChain {
+			require.Equal(t, OrderStateRefunded, result.OrderState())
+		} else {
+			require.Nil(t, result, "the order should only be recorded")
+		}
+
+		taken, _ := workflow.ProcessEvent(ctx, clientEvent(0x02, quietClient))
+		require.Equal(t, OrderStateRunning, taken.OrderState())
+	}
+}
+
+func TestAddressListAllowsOnlyListedAddresses(t *testing.T) {
+	list := NewAddressList(AddressLists{}, false)
+	require.True(t, list.Permits(busyClient))
+
+	list.Allow(quietClient, true)
+	require.False(t, list.Permits(busyClient))
+	require.True(t, list.Permits(quietClient))
+
+	list.Block(quietClient, true)
+	require.False(t, list.Permits(quietClient), "blocking wins over allowing")
+
+	list.Block(quietClient, false)
+	list.Allow(quietClient, false)
+	require.True(t, list.Permits(busyClient))
+}
+
+func TestAddressesHandler(t *testing.T) {
+	list := NewAddressList(AddressLists{}, false)
+	handler := AddressesHandler(list)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, AddressesPath+"blocked/"+busyClient.Hex(), nil))
+	require.Equal(t, http.StatusNoContent, res.Code)
+	require.False(t, list.Permits(busyClient))
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, AddressesPath, nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	var lists AddressLists
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &lists))
+	require.Equal(t, []common.Address{busyClient}, lists.Blocked)
+	require.Empty(t, lists.Allowed)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodDelete, AddressesPath+"blocked/"+busyClient.Hex(), nil))
+	require.Equal(t, http.StatusNoContent, res.Code)
+	require.True(t, list.Permits(busyClient))
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, AddressesPath+"blocked/0x1234", nil))
+	require.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, AddressesPath+"other/"+busyClient.Hex(), nil))
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,100 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//go:generate stringer -type=BreakerState --trimprefix=BreakerState
+type BreakerState int
+
+const (
+	BreakerStateClosed BreakerState = iota
+	BreakerStateHalfOpen
+	BreakerStateOpen
+)
+
+// ErrCircuitOpen is returned instead of making a call while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// A CircuitBreaker stops calls being made to a service that is failing. After
+// a number of consecutive failures the breaker opens and calls fail straight
+// away. Once the cooldown has passed, a single probe call is let through: if it
+// succeeds the breaker closes again, else it stays open for another cooldown.
+type CircuitBreaker struct {
+	threshold uint
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures uint
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(threshold uint, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// state must be called with the lock held.
+func (b *CircuitBreaker) state() BreakerState {
+	switch {
+	case b.threshold == 0 || b.failures < b.threshold:
+		return BreakerStateClosed
+	case b.probing || time.Since(b.openedAt) >= b.cooldown:
+		return BreakerStateHalfOpen
+	default:
+		return BreakerStateOpen
+	}
+}
+
+// State returns whether calls are currently being allowed through. A nil
+// breaker is always closed.
+func (b *CircuitBreaker) State() BreakerState {
+	if b == nil {
+		return BreakerStateClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state()
+}
+
+// Do calls the passed function if the breaker allows it, recording whether the
+// call failed.
+func (b *CircuitBreaker) Do(fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+
+	b.mu.Lock()
+	switch b.state() {
+	case BreakerStateOpen:
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	case BreakerStateHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	b.mu.Unlock()
+
+	err := fn()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil || errors.Is(err, context.Canceled) {
+		b.failures = 0
+	} else {
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = time.Now()
+		}
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(2, 10*time.Millisecond)
+	failure := errors.New("failed")
+	fail := func() error { return failure }
+	succeed := func() error { return nil }
+
+	require.Equal(t, failure, breaker.Do(fail))
+	require.Equal(t, BreakerStateClosed, breaker.State())
+	require.Equal(t, failure, breaker.Do(fail))
+	require.Equal(t, BreakerStateOpen, breaker.State())
+	require.Equal(t, ErrCircuitOpen, breaker.Do(succeed))
+
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, BreakerStateHalfOpen, breaker.State())
+	require.Equal(t, failure, breaker.Do(fail))
+	require.Equal(t, BreakerStateOpen, breaker.State())
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, breaker.Do(succeed))
+	require.Equal(t, BreakerStateClosed, breaker.State())
+}
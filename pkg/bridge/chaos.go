@@ -0,0 +1,137 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrChaosDisconnected is returned for calls to Bacalhau that chaos mode
+// pretends couldn't be made because the connection was lost. Like a real lost
+// connection, it matches bridgeerrors.ErrBacalhauUnavailable.
+var ErrChaosDisconnected error = &bridgeerrors.BacalhauError{Err: errors.New("chaos: connection lost")}
+
+// Chaos abuses a running bridge, so that soak tests can show that it recovers
+// from the faults that real networks and machines have. It delays polls for
+// running jobs, drops events from the work queue, cuts the bridge off from
+// Bacalhau for a while, and skews the clock of the whole process.
+//
+// Dropped events are left in the state they were last saved in, as if the
+// bridge had crashed whilst they were queued. It must never be used on a
+// bridge that takes real orders.
+type Chaos struct {
+	config ChaosConfig
+
+	mu                sync.Mutex
+	random            *rand.Rand
+	disconnectedUntil time.Time
+}
+
+// NewChaos returns chaos that abuses the bridge as often as the config says.
+// A seed of zero means a different run each time.
+func NewChaos(config ChaosConfig) *Chaos {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Chaos{config: config, random: rand.New(rand.NewSource(seed))}
+}
+
+// WithChaos makes the workflow abuse itself with the passed chaos.
+func WithChaos(chaos *Chaos) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Chaos = chaos
+	}
+}
+
+func (c *Chaos) float() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.random.Float64()
+}
+
+// delayPoll waits for up to the configured poll delay before the running jobs
+// are checked.
+func (c *Chaos) delayPoll(ctx context.Context) {
+	if c == nil || c.config.PollDelay <= 0 {
+		return
+	}
+
+	delay := time.Duration(c.float() * float64(c.config.PollDelay))
+	log.Ctx(ctx).Warn().Dur("delay", delay).Msg("Chaos: delaying poll")
+	chaosFaults.WithLabelValues("poll_delay").Inc()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// dropEvent returns whether the event should be dropped from the queue.
+func (c *Chaos) dropEvent(ctx context.Context, event Event) bool {
+	if c == nil || c.config.DropRate <= 0 || c.float() >= c.config.DropRate {
+		return false
+	}
+
+	log.Ctx(ctx).Warn().Stringer("id", event.OrderId()).Stringer("state", event.OrderState()).Msg("Chaos: dropping event")
+	chaosFaults.WithLabelValues("drop").Inc()
+	return true
+}
+
+// disconnected returns ErrChaosDisconnected if the connection to Bacalhau
+// has been lost, which happens by chance each time it is used.
+func (c *Chaos) disconnected(ctx context.Context) error {
+	if c == nil || c.config.DisconnectRate <= 0 {
+		return nil
+	}
+
+	chance := c.float()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Before(c.disconnectedUntil) {
+		return ErrChaosDisconnected
+	} else if chance >= c.config.DisconnectRate {
+		return nil
+	}
+
+	c.disconnectedUntil = now.Add(c.config.DisconnectDuration)
+	log.Ctx(ctx).Warn().Dur("duration", c.config.DisconnectDuration).Msg("Chaos: disconnecting from Bacalhau")
+	chaosFaults.WithLabelValues("disconnect").Inc()
+	return ErrChaosDisconnected
+}
+
+// skew returns how far the clock is out each time it is read, which is
+// anywhere up to the configured skew either way.
+func (c *Chaos) skew() time.Duration {
+	return time.Duration((2*c.float() - 1) * float64(c.config.ClockSkew))
+}
+
+// skewClock skews the clock of the whole process until the returned function
+// is called.
+func (c *Chaos) skewClock() (restore func()) {
+	if c == nil || c.config.ClockSkew <= 0 {
+		return func() {}
+	}
+	skewedClock.Store(c)
+	return func() { skewedClock.CompareAndSwap(c, nil) }
+}
+
+// skewedClock is the chaos that is skewing the clock, if any.
+var skewedClock atomic.Pointer[Chaos]
+
+// now returns the current time, which is skewed in chaos mode.
+func now() time.Time {
+	t := time.Now()
+	if chaos := skewedClock.Load(); chaos != nil {
+		t = t.Add(chaos.skew())
+	}
+	return t
+}
@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosDisconnectsForAWhile(t *testing.T) {
+	ctx := context.Background()
+	chaos := NewChaos(ChaosConfig{DisconnectRate: 1, DisconnectDuration: 50 * time.Millisecond, Seed: 1})
+	require.ErrorIs(t, chaos.disconnected(ctx), ErrChaosDisconnected)
+	require.ErrorIs(t, chaos.disconnected(ctx), bridgeerrors.ErrBacalhauUnavailable)
+
+	// Whilst disconnected, every call fails whatever the chance.
+	chaos.config.DisconnectRate = 1e-9
+	require.ErrorIs(t, chaos.disconnected(ctx), ErrChaosDisconnected)
+	require.Eventually(t, func() bool {
+		return chaos.disconnected(ctx) == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestChaosDropsEvents(t *testing.T) {
+	ctx := context.Background()
+	chaos := NewChaos(ChaosConfig{DropRate: 0.5, Seed: 1})
+
+	dropped := 0
+	for i := 0; i < 1000; i++ {
+		if chaos.dropEvent(ctx, exampleEvent()) {
+			dropped++
+		}
+	}
+	require.InDelta(t, 500, dropped, 100)
+
+	var off *Chaos
+	require.False(t, off.dropEvent(ctx, exampleEvent()))
+	require.NoError(t, off.disconnected(ctx))
+}
+
+func TestChaosSkewsTheClock(t *testing.T) {
+	chaos := NewChaos(ChaosConfig{ClockSkew: time.Hour, Seed: 1})
+	restore := chaos.skewClock()
+
+	skewed := false
+	for i := 0; i < 10 && !skewed; i++ {
+		skew := now().Sub(time.Now())
+		require.LessOrEqual(t, skew, time.Hour)
+		require.GreaterOrEqual(t, skew, -time.Hour-time.Second)
+		skewed = skew > time.Minute || skew < -time.Minute
+	}
+	require.True(t, skewed)
+
+	restore()
+	require.WithinDuration(t, time.Now(), now(), time.Second)
+}
@@ -0,0 +1,154 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An AdminClient talks to the HTTP server of a running bridge, so that it can
+// be managed from the command line.
+type AdminClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewAdminClient returns a client for the bridge serving HTTP on the passed
+// address, which may be a URL or just a host and port, that authenticates with
+// the bridge's admin token.
+func NewAdminClient(addr, token string) (*AdminClient, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	base, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	} else if base.Host == "" {
+		return nil, fmt.Errorf("%q is missing a host", addr)
+	}
+
+	// Listening on all interfaces means it can be reached locally.
+	if strings.HasPrefix(base.Host, ":") {
+		base.Host = "localhost" + base.Host
+	}
+
+	return &AdminClient{
+		baseURL: strings.TrimSuffix(base.String(), "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Health returns nil if the bridge reports that it is healthy, or an error
+// with the reasons if it is not.
+func (c *AdminClient) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/healthz", nil)
+}
+
+// Reload asks the bridge to reload its config, and returns the reason if the
+// config couldn't be reloaded.
+func (c *AdminClient) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, ReloadPath, nil)
+}
+
+// Orders returns the orders matching the filter, most recently changed first.
+func (c *AdminClient) Orders(ctx context.Context, filter OrderFilter) ([]Order, error) {
+	query := url.Values{}
+	if filter.State != nil {
+		query.Set("state", filter.State.String())
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.FormatUint(uint64(filter.Limit), 10))
+	}
+	if filter.Offset != 0 {
+		query.Set("offset", strconv.FormatUint(uint64(filter.Offset), 10))
+	}
+
+	path := OrdersPath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var orders []Order
+	err := c.do(ctx, http.MethodGet, path, &orders)
+	return orders, err
+}
+
+// Order returns a single order and its history, or ErrOrderNotFound.
+func (c *AdminClient) Order(ctx context.Context, orderID string) (Order, error) {
+	var order Order
+	err := c.do(ctx, http.MethodGet, OrdersPath+"/"+url.PathEscape(orderID), &order)
+	if errors.Is(err, errNotFound) {
+		err = ErrOrderNotFound
+	}
+	return order, err
+}
+
+// CancelOrder cancels the running job for an order. It returns
+// ErrOrderNotRunning if the order doesn't have a running job.
+func (c *AdminClient) CancelOrder(ctx context.Context, orderID string) error {
+	err := c.do(ctx, http.MethodPost, OrdersPath+"/"+url.PathEscape(orderID)+"/cancel", nil)
+	if errors.Is(err, errNotFound) {
+		err = ErrOrderNotFound
+	} else if errors.Is(err, errConflict) {
+		err = ErrOrderNotRunning
+	}
+	return err
+}
+
+// JobLogs returns the output of a job, or ErrJobNotFound.
+func (c *AdminClient) JobLogs(ctx context.Context, jobID string) ([]ExecutionLogs, error) {
+	var logs []ExecutionLogs
+	err := c.do(ctx, http.MethodGet, JobLogsPath+url.PathEscape(jobID)+"/logs", &logs)
+	if errors.Is(err, errNotFound) {
+		err = ErrJobNotFound
+	}
+	return logs, err
+}
+
+var (
+	errNotFound = errors.New("not found")
+	errConflict = errors.New("conflict")
+)
+
+func (c *AdminClient) do(ctx context.Context, method, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		msg := strings.TrimSpace(string(body))
+		switch res.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", errNotFound, msg)
+		case http.StatusConflict:
+			return fmt.Errorf("%w: %s", errConflict, msg)
+		default:
+			return fmt.Errorf("%s %s: %s: %s", method, path, res.Status, msg)
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
@@ -0,0 +1,75 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminClient(t *testing.T) {
+	repo := repository(t)
+	runner := &mockRunner{CancelHandler: func(ctx context.Context, e BacalhauJobRunningEvent) error {
+		return nil
+	}}
+	workflow := NewWorkflow(runner, mockContract{}, repo)
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", HealthHandler())
+	mux.Handle(JobLogsPath, LogsHandler(staticLogger{"job": {{NodeID: "node", Stdout: "hello"}}}))
+	mux.Handle(OrdersPath, OrdersHandler(repo.(OrderStore), workflow))
+	mux.Handle(OrdersPath+"/", OrdersHandler(repo.(OrderStore), workflow))
+	server := httptest.NewServer(AdminAuth("secret", mux))
+	defer server.Close()
+
+	client, err := NewAdminClient(server.URL, "secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	stranger, err := NewAdminClient(server.URL, "guess")
+	require.NoError(t, err)
+	require.ErrorContains(t, stranger.Health(ctx), "401", "requests without the admin token should be refused")
+
+	job := model.NewJob()
+	job.Metadata.ID = "job"
+	e := walEvent(1).JobCreated(job)
+	require.NoError(t, repo.Save(e))
+
+	require.NoError(t, client.Health(ctx))
+
+	running := OrderStateRunning
+	orders, err := client.Orders(ctx, OrderFilter{State: &running, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	order, err := client.Order(ctx, e.OrderId().Hex())
+	require.NoError(t, err)
+	require.Equal(t, "job", order.JobID)
+	_, err = client.Order(ctx, walEvent(2).OrderId().Hex())
+	require.ErrorIs(t, err, ErrOrderNotFound)
+
+	logs, err := client.JobLogs(ctx, "job")
+	require.NoError(t, err)
+	require.Equal(t, "hello", logs[0].Stdout)
+	_, err = client.JobLogs(ctx, "other")
+	require.ErrorIs(t, err, ErrJobNotFound)
+
+	require.NoError(t, client.CancelOrder(ctx, e.OrderId().Hex()))
+	<-workflow.injected
+	require.ErrorIs(t, client.CancelOrder(ctx, e.OrderId().Hex()), ErrOrderNotRunning)
+}
+
+func TestNewAdminClient(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"localhost:2112":          "http://localhost:2112",
+		":2112":                   "http://localhost:2112",
+		"https://bridge.example/": "https://bridge.example",
+	} {
+		client, err := NewAdminClient(addr, "")
+		require.NoError(t, err)
+		require.Equal(t, expected, client.baseURL)
+	}
+}
@@ -0,0 +1,115 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// jobSlots limits how many jobs the bridge has running on Bacalhau at once.
+// Each running order holds a slot, and orders wait to be submitted until one
+// is free.
+type jobSlots struct {
+	mu sync.Mutex
+
+	// The most jobs that can run at once, in all and for any one client, or
+	// zero for no limit.
+	max          int
+	maxPerClient int
+
+	// The slot held by each running order.
+	held map[common.Hash]heldSlot
+}
+
+type heldSlot struct {
+	// When the slot was taken.
+	taken time.Time
+
+	// Who made the order.
+	client common.Address
+}
+
+// WithMaxRunningJobs stops the workflow having more than max jobs running on
+// Bacalhau at once, so that a small cluster isn't overwhelmed. Orders wait to
+// be submitted until a job finishes. Zero means there is no limit.
+func WithMaxRunningJobs(max int) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.slots.setMax(max)
+	}
+}
+
+func (s *jobSlots) setMax(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = max
+}
+
+func (s *jobSlots) setMaxPerClient(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxPerClient = max
+}
+
+// take gives the order made by the client a slot, returning false if there
+// are none free, or the client has as many as it is allowed. An order that
+// already has a slot keeps it.
+func (s *jobSlots) take(orderID common.Hash, client common.Address) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held == nil {
+		s.held = map[common.Hash]heldSlot{}
+	}
+	if _, held := s.held[orderID]; held {
+		return true
+	} else if s.max > 0 && len(s.held) >= s.max {
+		return false
+	} else if s.maxPerClient > 0 && s.heldBy(client) >= s.maxPerClient {
+		clientQuotaWaits.WithLabelValues("running").Inc()
+		return false
+	}
+	s.held[orderID] = heldSlot{taken: now(), client: client}
+	runningJobSlots.Set(float64(len(s.held)))
+	return true
+}
+
+// heldBy returns how many slots are held by the client's orders. It must be
+// called with the lock held.
+func (s *jobSlots) heldBy(client common.Address) int {
+	count := 0
+	for _, slot := range s.held {
+		if slot.client == client {
+			count++
+		}
+	}
+	return count
+}
+
+// free gives up the order's slot, if it has one.
+func (s *jobSlots) free(orderID common.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.held, orderID)
+	runningJobSlots.Set(float64(len(s.held)))
+}
+
+// sync gives slots to exactly the passed running jobs, which were loaded at
+// the passed time, and to any orders that took a slot since then. This
+// frees the slots of jobs that have finished, and takes slots for jobs that
+// were already running when the bridge started.
+func (s *jobSlots) sync(running []BacalhauJobRunningEvent, loaded time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	held := make(map[common.Hash]heldSlot, len(running))
+	for _, job := range running {
+		held[job.OrderId()] = heldSlot{taken: loaded, client: job.OrderRequestor()}
+	}
+	for orderID, slot := range s.held {
+		if _, found := held[orderID]; found || slot.taken.After(loaded) {
+			held[orderID] = slot
+		}
+	}
+	s.held = held
+	runningJobSlots.Set(float64(len(s.held)))
+}
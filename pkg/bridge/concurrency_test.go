@@ -0,0 +1,45 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersWaitForARunningJobSlot(t *testing.T) {
+	ctx := context.Background()
+	runner := &mockRunner{CreateHandler: SuccessfulCreate}
+	workflow := NewWorkflow(runner, &mockContract{}, repository(t), WithMaxRunningJobs(1), WithJobCheckInterval(time.Minute))
+
+	first, wait := workflow.ProcessEvent(ctx, walEvent(0x01))
+	require.Equal(t, OrderStateRunning, first.OrderState())
+	require.Zero(t, wait)
+
+	held, wait := workflow.ProcessEvent(ctx, walEvent(0x02))
+	require.Equal(t, OrderStateSubmitted, held.OrderState(), "the order should wait for the running job")
+	require.Equal(t, time.Minute, wait)
+
+	// Once the first job is no longer running, its slot is free.
+	workflow.slots.sync(nil, now())
+	second, _ := workflow.ProcessEvent(ctx, held)
+	require.Equal(t, OrderStateRunning, second.OrderState())
+}
+
+func TestJobSlotsKeepSlotsTakenSinceJobsWereLoaded(t *testing.T) {
+	var slots jobSlots
+	slots.setMax(2)
+
+	loaded := now()
+	running := walEvent(0x01)
+	require.True(t, slots.take(walEvent(0x02).OrderId(), common.Address{}))
+	slots.sync([]BacalhauJobRunningEvent{running}, loaded)
+	require.Len(t, slots.held, 2)
+	require.False(t, slots.take(walEvent(0x03).OrderId(), common.Address{}))
+
+	// Orders that failed to submit give their slot back.
+	slots.free(walEvent(0x02).OrderId())
+	require.True(t, slots.take(walEvent(0x03).OrderId(), common.Address{}))
+}
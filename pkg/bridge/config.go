@@ -0,0 +1,1210 @@
+package bridge
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/logger"
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// A Config holds the settings of the bridge. Settings are read from a YAML or
+// TOML config file using the names in their config tags, and each can be
+// overridden by the environment variable named in its env tag.
+type Config struct {
+	Chain        ChainConfig        `config:"chain"`
+	Gas          GasConfig          `config:"gas"`
+	Signer       SignerConfig       `config:"signer"`
+	Relayer      RelayerConfig      `config:"relayer"`
+	Bacalhau     BacalhauConfig     `config:"bacalhau"`
+	Mediation    MediationConfig    `config:"mediation"`
+	Verification VerificationConfig `config:"verification"`
+	Pinning      PinningConfig      `config:"pinning"`
+	Storage      StorageConfig      `config:"storage"`
+	Limits       LimitsConfig       `config:"limits"`
+	Pricing      PricingConfig      `config:"pricing"`
+	Cache        CacheConfig        `config:"cache"`
+	Chaos        ChaosConfig        `config:"chaos"`
+	Alerts       AlertsConfig       `config:"alerts"`
+	SLA          SLAConfig          `config:"sla"`
+	Server       ServerConfig       `config:"server"`
+	Log          LogConfig          `config:"log"`
+}
+
+type ChainConfig struct {
+	RPCEndpoint       string   `config:"rpcEndpoint" env:"RPC_ENDPOINT"`
+	WebsocketEndpoint string   `config:"websocketEndpoint" env:"WEBSOCKET_RPC_ENDPOINT"`
+	ChainID           int64    `config:"chainId" env:"CHAIN_ID"`
+	ContractAddress   string   `config:"contractAddress" env:"DEPLOYED_CONTRACT_ADDRESS"`
+	Contracts         []string `config:"contracts" env:"CONTRACT_ADDRESSES"`
+	WalletPrivateKey  string   `config:"walletPrivateKey" env:"WALLET_PRIVATE_KEY"`
+	StartBlock        uint64   `config:"startBlock" env:"START_BLOCK"`
+	Confirmations     uint64   `config:"confirmations" env:"CONFIRMATIONS"`
+
+	// If set, the transactions that return results and errors are followed
+	// until they are this many blocks deep.
+	ResultConfirmations uint64 `config:"resultConfirmations" env:"RESULT_CONFIRMATIONS"`
+}
+
+// Fees are in gwei per unit of gas, and the daily budget and minimum balance
+// are in the chain's native token.
+type GasConfig struct {
+	FeeStrategy          string        `config:"feeStrategy" env:"GAS_FEE_STRATEGY"`
+	MaxFeePerGas         float64       `config:"maxFeePerGas" env:"GAS_MAX_FEE_PER_GAS"`
+	MaxPriorityFeePerGas float64       `config:"maxPriorityFeePerGas" env:"GAS_MAX_PRIORITY_FEE_PER_GAS"`
+	FeePercentile        float64       `config:"feePercentile" env:"GAS_FEE_PERCENTILE"`
+	FeeCap               float64       `config:"feeCap" env:"GAS_FEE_CAP"`
+	ReplaceAfter         time.Duration `config:"replaceAfter" env:"GAS_REPLACE_AFTER"`
+	MaxReplacements      uint          `config:"maxReplacements" env:"GAS_MAX_REPLACEMENTS"`
+	FeeBump              float64       `config:"feeBump" env:"GAS_FEE_BUMP"`
+	BatchWindow          time.Duration `config:"batchWindow" env:"GAS_BATCH_WINDOW"`
+	BatchSize            int           `config:"batchSize" env:"GAS_BATCH_SIZE"`
+	DailyBudget          float64       `config:"dailyBudget" env:"GAS_DAILY_BUDGET"`
+	MinBalance           float64       `config:"minBalance" env:"GAS_MIN_BALANCE"`
+	BalanceCheckInterval time.Duration `config:"balanceCheckInterval" env:"GAS_BALANCE_CHECK_INTERVAL"`
+}
+
+// FundsMonitor returns a monitor of the wallet's balance that pauses posting
+// whilst it is below the minimum.
+func (gas GasConfig) FundsMonitor() *FundsMonitor {
+	return NewFundsMonitor(ether(gas.MinBalance), gas.BalanceCheckInterval)
+}
+
+// Settings for the signing backends other than the default, which signs with
+// chain.walletPrivateKey.
+type SignerConfig struct {
+	Backend          string        `config:"backend" env:"SIGNER"`
+	KeystoreFile     string        `config:"keystoreFile" env:"KEYSTORE_FILE"`
+	KeystorePassword string        `config:"keystorePassword" env:"KEYSTORE_PASSWORD"`
+	KMSKey           string        `config:"kmsKey" env:"KMS_KEY"`
+	AWSRegion        string        `config:"awsRegion" env:"AWS_REGION"`
+	VaultAddress     string        `config:"vaultAddress" env:"VAULT_ADDR"`
+	VaultToken       string        `config:"vaultToken" env:"VAULT_TOKEN"`
+	VaultPath        string        `config:"vaultPath" env:"VAULT_KEY_PATH"`
+	VaultField       string        `config:"vaultField" env:"VAULT_KEY_FIELD"`
+	LedgerPath       string        `config:"ledgerPath" env:"LEDGER_DERIVATION_PATH"`
+	LedgerTimeout    time.Duration `config:"ledgerTimeout" env:"LEDGER_CONFIRM_TIMEOUT"`
+}
+
+// Settings for sending transactions as meta-transactions through a relayer,
+// which pays for their gas.
+type RelayerConfig struct {
+	URL              string `config:"url" env:"RELAYER_URL"`
+	APIKey           string `config:"apiKey" env:"RELAYER_API_KEY"`
+	Forwarder        string `config:"forwarder" env:"FORWARDER_ADDRESS"`
+	ForwarderName    string `config:"forwarderName" env:"FORWARDER_NAME"`
+	ForwarderVersion string `config:"forwarderVersion" env:"FORWARDER_VERSION"`
+}
+
+type BacalhauConfig struct {
+	Runner            string        `config:"runner" env:"JOB_RUNNER"`
+	Endpoints         []string      `config:"endpoints" env:"BACALHAU_API_ENDPOINTS"`
+	EndpointSelection string        `config:"endpointSelection" env:"BACALHAU_ENDPOINT_SELECTION"`
+	PollInterval      time.Duration `config:"pollInterval" env:"BACALHAU_POLL_INTERVAL"`
+	SubmitTimeout     time.Duration `config:"submitTimeout" env:"BACALHAU_SUBMIT_TIMEOUT"`
+	ListTimeout       time.Duration `config:"listTimeout" env:"BACALHAU_LIST_TIMEOUT"`
+	CheckTimeout      time.Duration `config:"checkTimeout" env:"BACALHAU_CHECK_TIMEOUT"`
+	HeartbeatInterval time.Duration `config:"heartbeatInterval" env:"BACALHAU_HEARTBEAT_INTERVAL"`
+	MaxJobDuration    time.Duration `config:"maxJobDuration" env:"BACALHAU_MAX_JOB_DURATION"`
+	CheckConcurrency  uint          `config:"checkConcurrency" env:"BACALHAU_CHECK_CONCURRENCY"`
+	MaxRunningJobs    int           `config:"maxRunningJobs" env:"BACALHAU_MAX_RUNNING_JOBS"`
+	CheckInputs       bool          `config:"checkInputs" env:"BACALHAU_CHECK_INPUTS"`
+	InputCheckTimeout time.Duration `config:"inputCheckTimeout" env:"BACALHAU_INPUT_CHECK_TIMEOUT"`
+	TemplatesDir      string        `config:"templatesDir" env:"JOB_TEMPLATES_DIR"`
+
+	// Kept in the annotations of submitted jobs, so that deployments sharing a
+	// Bacalhau network only see their own jobs. Changing it loses track of
+	// jobs that are still running.
+	Namespace string `config:"namespace" env:"BACALHAU_NAMESPACE"`
+
+	// If set, job templates are loaded from templatesDir within this Git
+	// repository at the commit, rather than from the local filesystem.
+	TemplatesRepo   string `config:"templatesRepo" env:"JOB_TEMPLATES_REPO"`
+	TemplatesCommit string `config:"templatesCommit" env:"JOB_TEMPLATES_COMMIT"`
+	TemplatesCache  string `config:"templatesCache" env:"JOB_TEMPLATES_CACHE"`
+}
+
+// Settings for running failed or disputed orders again on a separate Bacalhau
+// cluster, and posting the verdict on-chain. Mediation is off unless the
+// mediator's endpoints are set.
+type MediationConfig struct {
+	Endpoints    []string      `config:"endpoints" env:"MEDIATOR_API_ENDPOINTS"`
+	FailedJobs   bool          `config:"failedJobs" env:"MEDIATE_FAILED_JOBS"`
+	PollInterval time.Duration `config:"pollInterval" env:"MEDIATION_POLL_INTERVAL"`
+	MaxAttempts  uint          `config:"maxAttempts" env:"MEDIATION_MAX_ATTEMPTS"`
+}
+
+// Settings for running a sample of completed jobs again on a second Bacalhau
+// cluster, and disputing results that don't match. Verification is off unless
+// the percentage is set.
+type VerificationConfig struct {
+	Endpoints []string `config:"endpoints" env:"VERIFIER_API_ENDPOINTS"`
+	Percent   float64  `config:"percent" env:"VERIFICATION_PERCENT"`
+}
+
+// Settings for pinning the results of completed jobs so that they stay
+// available on IPFS. Pinning is off unless the service is set.
+type PinningConfig struct {
+	Service     string `config:"service" env:"PINNING_SERVICE"`
+	Endpoint    string `config:"endpoint" env:"PINNING_ENDPOINT"`
+	Token       string `config:"token" env:"PINNING_TOKEN"`
+	MaxAttempts uint   `config:"maxAttempts" env:"PINNING_MAX_ATTEMPTS"`
+}
+
+type StorageConfig struct {
+	SQLiteFile     string `config:"sqliteFile" env:"SQLITE_FILE_LOCATION"`
+	PostgresDSN    string `config:"postgresDsn" env:"POSTGRES_DSN"`
+	LeaderElection bool   `config:"leaderElection" env:"LEADER_ELECTION"`
+	Partitions     uint   `config:"partitions" env:"PARTITIONS"`
+	WALFile        string `config:"walFile" env:"WAL_FILE"`
+	ResultsDir     string `config:"resultsDir" env:"RESULTS_DIR"`
+	ResultsBucket  string `config:"resultsBucket" env:"RESULTS_S3_BUCKET"`
+	ResultsRegion  string `config:"resultsRegion" env:"RESULTS_S3_REGION"`
+	IPFSGateway    string `config:"ipfsGateway" env:"IPFS_GATEWAY"`
+	HashOutputs    bool   `config:"hashOutputs" env:"HASH_OUTPUTS"`
+	IPFSAPI        string `config:"ipfsApi" env:"IPFS_API_URL"`
+
+	// Where Filecoin storage deals are made for orders that ask for durable
+	// storage, which are rejected if it isn't set.
+	DealAPI      string        `config:"dealApi" env:"FILECOIN_DEAL_API_URL"`
+	DealToken    string        `config:"dealToken" env:"FILECOIN_DEAL_TOKEN"`
+	DealDuration time.Duration `config:"dealDuration" env:"FILECOIN_DEAL_DURATION"`
+}
+
+type LimitsConfig struct {
+	SubmitRateLimit     float64       `config:"submitRateLimit" env:"SUBMIT_RATE_LIMIT"`
+	SubmitBurst         int           `config:"submitBurst" env:"SUBMIT_BURST"`
+	ShutdownGracePeriod time.Duration `config:"shutdownGracePeriod" env:"SHUTDOWN_GRACE_PERIOD"`
+	PolicyFile          string        `config:"policyFile" env:"POLICY_FILE"`
+	MaxCPU              string        `config:"maxCpu" env:"BACALHAU_MAX_CPU"`
+	MaxMemory           string        `config:"maxMemory" env:"BACALHAU_MAX_MEMORY"`
+	MaxDisk             string        `config:"maxDisk" env:"BACALHAU_MAX_DISK"`
+	MaxGPU              string        `config:"maxGpu" env:"BACALHAU_MAX_GPU"`
+
+	// The prices, in the chain's native token, at or above which orders are
+	// high priority and below which they are low priority, or zero for
+	// none, and how long lower priority orders wait at most.
+	PriorityHighPrice float64       `config:"priorityHighPrice" env:"PRIORITY_HIGH_PRICE"`
+	PriorityLowPrice  float64       `config:"priorityLowPrice" env:"PRIORITY_LOW_PRICE"`
+	PriorityMaxWait   time.Duration `config:"priorityMaxWait" env:"PRIORITY_MAX_WAIT"`
+
+	// How many jobs each client, by wallet address, can have running and
+	// submit an hour, or zero for no limit, and the share of submissions
+	// clients get whilst orders wait, written as address=weight.
+	ClientMaxRunning int      `config:"clientMaxRunning" env:"CLIENT_MAX_RUNNING_JOBS"`
+	ClientMaxPerHour int      `config:"clientMaxPerHour" env:"CLIENT_MAX_JOBS_PER_HOUR"`
+	ClientWeights    []string `config:"clientWeights" env:"CLIENT_WEIGHTS"`
+
+	// The wallet addresses that orders are refused from, and if any are
+	// listed, the only addresses orders are taken from, and whether refused
+	// orders are rejected on-chain rather than only recorded.
+	BlockedAddresses []string `config:"blockedAddresses" env:"BLOCKED_ADDRESSES"`
+	AllowedAddresses []string `config:"allowedAddresses" env:"ALLOWED_ADDRESSES"`
+	RejectBlocked    bool     `config:"rejectBlocked" env:"REJECT_BLOCKED_ORDERS"`
+}
+
+// AddressList returns the list of addresses orders are taken from.
+func (limits LimitsConfig) AddressList() (*AddressList, error) {
+	blocked, err := ParseAddresses(limits.BlockedAddresses)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := ParseAddresses(limits.AllowedAddresses)
+	if err != nil {
+		return nil, err
+	}
+	return NewAddressList(AddressLists{Blocked: blocked, Allowed: allowed}, limits.RejectBlocked), nil
+}
+
+// ClientQuotas returns how much of the bridge each client can use.
+func (limits LimitsConfig) ClientQuotas() (ClientQuotas, error) {
+	weights, err := ParseClientWeights(limits.ClientWeights)
+	if err != nil {
+		return ClientQuotas{}, err
+	}
+	return ClientQuotas{
+		MaxRunning: limits.ClientMaxRunning,
+		MaxPerHour: limits.ClientMaxPerHour,
+		Weights:    weights,
+	}, nil
+}
+
+// Resources returns the most of each resource that a job may ask for.
+func (limits LimitsConfig) Resources() model.ResourceUsageConfig {
+	return model.ResourceUsageConfig{
+		CPU:    limits.MaxCPU,
+		Memory: limits.MaxMemory,
+		Disk:   limits.MaxDisk,
+		GPU:    limits.MaxGPU,
+	}
+}
+
+// Priorities returns how orders are prioritised whilst they wait to be
+// submitted.
+func (limits LimitsConfig) Priorities() PriorityPolicy {
+	policy := PriorityPolicy{MaxWait: limits.PriorityMaxWait}
+	if limits.PriorityHighPrice > 0 {
+		policy.HighPrice = ether(limits.PriorityHighPrice)
+	}
+	if limits.PriorityLowPrice > 0 {
+		policy.LowPrice = ether(limits.PriorityLowPrice)
+	}
+	return policy
+}
+
+// What resource providers charge for running jobs, in the chain's native
+// token, which is used to estimate the cost of orders.
+type PricingConfig struct {
+	CPUHour    float64 `config:"cpuHour" env:"PRICE_CPU_HOUR"`
+	MemoryHour float64 `config:"memoryHour" env:"PRICE_MEMORY_GB_HOUR"`
+	DiskHour   float64 `config:"diskHour" env:"PRICE_DISK_GB_HOUR"`
+	GPUHour    float64 `config:"gpuHour" env:"PRICE_GPU_HOUR"`
+	Job        float64 `config:"job" env:"PRICE_JOB"`
+
+	// If set, orders that offer less than the minimum price in this file for
+	// the resources their job asks for are declined.
+	ProfilesFile string `config:"profilesFile" env:"PRICING_PROFILES_FILE"`
+
+	// If set, what the tokens that orders are paid in are worth, which lets
+	// orders paid in an ERC-20 token be priced and is used to estimate
+	// earnings in a common currency.
+	TokensFile string `config:"tokensFile" env:"PRICING_TOKENS_FILE"`
+
+	// If set, a URL that returns the latest price of each token in the tokens
+	// file by symbol, which is fetched at most once per interval. Prices from
+	// it older than the maximum age aren't used.
+	OracleURL      string        `config:"oracleUrl" env:"PRICE_ORACLE_URL"`
+	OracleInterval time.Duration `config:"oracleInterval" env:"PRICE_ORACLE_INTERVAL"`
+	OracleMaxAge   time.Duration `config:"oracleMaxAge" env:"PRICE_ORACLE_MAX_AGE"`
+}
+
+// Prices returns the configured prices.
+func (pricing PricingConfig) Prices() ResourcePrices {
+	return ResourcePrices{
+		CPUHour:    pricing.CPUHour,
+		MemoryHour: pricing.MemoryHour,
+		DiskHour:   pricing.DiskHour,
+		GPUHour:    pricing.GPUHour,
+		Job:        pricing.Job,
+	}
+}
+
+// Oracle returns the oracle that prices the tokens in the tokens file, or nil
+// if there isn't one.
+func (pricing PricingConfig) Oracle() (PriceOracle, error) {
+	if pricing.TokensFile == "" {
+		return nil, nil
+	}
+	tokens, err := LoadTokenPrices(pricing.TokensFile)
+	if err != nil {
+		return nil, err
+	} else if pricing.OracleURL == "" {
+		return tokens, nil
+	}
+	return NewFeedPriceOracle(pricing.OracleURL, pricing.OracleInterval, pricing.OracleMaxAge, tokens), nil
+}
+
+// Where the results of completed jobs are cached, so that orders for the same
+// spec within the TTL are given the cached result rather than run again. The
+// cache is off unless the TTL is set.
+type CacheConfig struct {
+	Backend    string        `config:"backend" env:"JOB_CACHE"`
+	TTL        time.Duration `config:"ttl" env:"JOB_CACHE_TTL"`
+	Dir        string        `config:"dir" env:"JOB_CACHE_DIR"`
+	MaxEntries int           `config:"maxEntries" env:"JOB_CACHE_MAX_ENTRIES"`
+	RedisURL   string        `config:"redisUrl" env:"JOB_CACHE_REDIS_URL"`
+}
+
+// JobCache returns the configured job cache, which is kept in the repository
+// if it is the database.
+func (cache CacheConfig) JobCache(repo Repository) (JobCache, error) {
+	switch cache.Backend {
+	case JobCacheDatabase:
+		store, ok := repo.(JobCache)
+		if !ok {
+			return nil, fmt.Errorf("%T can't cache jobs", repo)
+		}
+		return store, nil
+	case JobCacheDisk:
+		return NewDiskJobCache(cache.Dir, cache.MaxEntries)
+	case JobCacheRedis:
+		return NewRedisJobCache(cache.RedisURL, cache.TTL)
+	default:
+		return nil, fmt.Errorf("unknown job cache %q", cache.Backend)
+	}
+}
+
+// How often chaos mode abuses the bridge, which it only does if the bridge is
+// started with --chaos. Rates are the chance of each fault, between 0 and 1.
+type ChaosConfig struct {
+	PollDelay          time.Duration `config:"pollDelay" env:"CHAOS_POLL_DELAY"`
+	DropRate           float64       `config:"dropRate" env:"CHAOS_DROP_RATE"`
+	DisconnectRate     float64       `config:"disconnectRate" env:"CHAOS_DISCONNECT_RATE"`
+	DisconnectDuration time.Duration `config:"disconnectDuration" env:"CHAOS_DISCONNECT_DURATION"`
+	ClockSkew          time.Duration `config:"clockSkew" env:"CHAOS_CLOCK_SKEW"`
+	Seed               int64         `config:"seed" env:"CHAOS_SEED"`
+}
+
+// Where alerts for the operator are sent, and when they fire. The minimum
+// balance is in the chain's native token, and rules that are zero are off.
+type AlertsConfig struct {
+	WebhookURL          string        `config:"webhookUrl" env:"ALERT_WEBHOOK_URL"`
+	SlackWebhookURL     string        `config:"slackWebhookUrl" env:"ALERT_SLACK_WEBHOOK_URL"`
+	PagerDutyRoutingKey string        `config:"pagerDutyRoutingKey" env:"ALERT_PAGERDUTY_ROUTING_KEY"`
+	CheckInterval       time.Duration `config:"checkInterval" env:"ALERT_CHECK_INTERVAL"`
+	APIFailures         int64         `config:"apiFailures" env:"ALERT_API_FAILURES"`
+	MinBalance          float64       `config:"minBalance" env:"ALERT_MIN_BALANCE"`
+	StuckAfter          time.Duration `config:"stuckAfter" env:"ALERT_STUCK_AFTER"`
+}
+
+// Notifiers returns a notifier for each place alerts are configured to go.
+func (alerts AlertsConfig) Notifiers() []AlertNotifier {
+	notifiers := []AlertNotifier{}
+	if alerts.WebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookAlerter(alerts.WebhookURL))
+	}
+	if alerts.SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackAlerter(alerts.SlackWebhookURL))
+	}
+	if alerts.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, NewPagerDutyAlerter(alerts.PagerDutyRoutingKey))
+	}
+	return notifiers
+}
+
+// Rules returns the rules for when alerts fire.
+func (alerts AlertsConfig) Rules() AlertRules {
+	return AlertRules{
+		APIFailures: alerts.APIFailures,
+		MinBalance:  ether(alerts.MinBalance),
+		StuckAfter:  alerts.StuckAfter,
+	}
+}
+
+// How the end-to-end latency of orders is reported on. Windows are durations
+// such as 24h, and the target and report directory are off if empty.
+type SLAConfig struct {
+	Windows        []string      `config:"windows" env:"SLA_WINDOWS"`
+	Target         time.Duration `config:"target" env:"SLA_TARGET"`
+	ReportDir      string        `config:"reportDir" env:"SLA_REPORT_DIR"`
+	ReportInterval time.Duration `config:"reportInterval" env:"SLA_REPORT_INTERVAL"`
+}
+
+// WindowDurations returns the rolling windows that latency is reported over.
+func (sla SLAConfig) WindowDurations() ([]time.Duration, error) {
+	windows := make([]time.Duration, 0, len(sla.Windows))
+	for _, str := range sla.Windows {
+		window, err := time.ParseDuration(str)
+		if err != nil {
+			return nil, err
+		} else if window <= 0 {
+			return nil, fmt.Errorf("window %q must be positive", str)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+type ServerConfig struct {
+	MetricsAddress string `config:"metricsAddress" env:"METRICS_ADDRESS"`
+	GRPCAddress    string `config:"grpcAddress" env:"GRPC_ADDRESS"`
+
+	// The bearer token that the admin endpoints and the gRPC control service
+	// require. Without it, the admin endpoints aren't served and the control
+	// service can't be started.
+	AdminToken string `config:"adminToken" env:"ADMIN_TOKEN"`
+
+	// How often the orders in each state are counted for the queue metrics.
+	QueueMetricsInterval time.Duration `config:"queueMetricsInterval" env:"QUEUE_METRICS_INTERVAL"`
+}
+
+type LogConfig struct {
+	Mode  string `config:"mode" env:"LOG_MODE"`
+	Level string `config:"level" env:"LOG_LEVEL"`
+}
+
+// DefaultConfig returns the settings used when neither the config file nor the
+// environment says otherwise.
+func DefaultConfig() Config {
+	return Config{
+		Signer: SignerConfig{
+			Backend:       SignerKey,
+			VaultField:    "privateKey",
+			LedgerPath:    defaultLedgerPath,
+			LedgerTimeout: defaultLedgerTimeout,
+		},
+		Gas: GasConfig{
+			FeeStrategy:          FeeStrategySuggested,
+			FeePercentile:        50,
+			ReplaceAfter:         DefaultReplacementPolicy.After,
+			MaxReplacements:      DefaultReplacementPolicy.MaxReplacements,
+			FeeBump:              DefaultReplacementPolicy.FeeBump,
+			BatchWindow:          10 * time.Second,
+			BatchSize:            1,
+			BalanceCheckInterval: time.Minute,
+		},
+		Bacalhau: BacalhauConfig{
+			Runner:            DefaultRunner,
+			EndpointSelection: string(EndpointSelectionPriority),
+			PollInterval:      DefaultRunnerConfig.PollInterval,
+			SubmitTimeout:     DefaultRunnerConfig.SubmitTimeout,
+			ListTimeout:       DefaultRunnerConfig.ListTimeout,
+			CheckTimeout:      DefaultRunnerConfig.CheckTimeout,
+			HeartbeatInterval: time.Minute,
+			MaxJobDuration:    DefaultRunnerConfig.MaxJobDuration,
+			CheckConcurrency:  DefaultRunnerConfig.CheckConcurrency,
+			InputCheckTimeout: defaultInputCheckTimeout,
+			TemplatesCache:    "templates-cache",
+		},
+		Mediation: MediationConfig{
+			PollInterval: defaultMediationPollInterval,
+			MaxAttempts:  defaultMediationMaxAttempts,
+		},
+		Pinning: PinningConfig{
+			MaxAttempts: defaultPinAttempts,
+		},
+		Storage: StorageConfig{
+			SQLiteFile:   "lilypad.sqlite",
+			IPFSGateway:  "https://ipfs.io",
+			DealDuration: MinDealDuration,
+		},
+		Limits: LimitsConfig{
+			SubmitBurst:         1,
+			ShutdownGracePeriod: defaultShutdownGracePeriod,
+			PriorityMaxWait:     5 * time.Minute,
+		},
+		Cache: CacheConfig{
+			Backend:    JobCacheDatabase,
+			Dir:        "job-cache",
+			MaxEntries: 10000,
+		},
+		Chaos: ChaosConfig{
+			PollDelay:          10 * time.Second,
+			DropRate:           0.05,
+			DisconnectRate:     0.01,
+			DisconnectDuration: 30 * time.Second,
+			ClockSkew:          time.Minute,
+		},
+		Alerts: AlertsConfig{
+			CheckInterval: time.Minute,
+			APIFailures:   5,
+			StuckAfter:    time.Hour,
+		},
+		SLA: SLAConfig{
+			Windows:        []string{"1h", "24h", "168h"},
+			ReportInterval: 24 * time.Hour,
+		},
+		Server: ServerConfig{
+			MetricsAddress:       "localhost:2112",
+			QueueMetricsInterval: 15 * time.Second,
+		},
+		Log: LogConfig{
+			Mode:  "default",
+			Level: "INFO",
+		},
+	}
+}
+
+// Contract returns the address of the contract on the configured chain, which
+// is contractAddress if it is set, or else the address for the chain ID in
+// contracts. If there are several, it returns the first.
+func (chain ChainConfig) Contract() (common.Address, error) {
+	addresses, err := chain.ContractAddresses()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return addresses[0], nil
+}
+
+// ContractAddresses returns the addresses of every contract the bridge watches
+// on the configured chain: those in contractAddress if it is set, or else
+// those for the chain ID in contracts. Several addresses are separated by
+// commas.
+func (chain ChainConfig) ContractAddresses() ([]common.Address, error) {
+	var listed []string
+	if chain.ContractAddress != "" {
+		listed = strings.Split(chain.ContractAddress, ",")
+	} else {
+		prefix := strconv.FormatInt(chain.ChainID, 10) + "="
+		for _, entry := range chain.Contracts {
+			if strings.HasPrefix(entry, prefix) {
+				listed = append(listed, strings.Split(strings.TrimPrefix(entry, prefix), ",")...)
+			}
+		}
+		if len(listed) == 0 {
+			return nil, fmt.Errorf("no contract address for chain %d", chain.ChainID)
+		}
+	}
+
+	addresses := make([]common.Address, 0, len(listed))
+	for _, address := range listed {
+		address = strings.TrimSpace(address)
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("%q must be a hex address", address)
+		}
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+	return addresses, nil
+}
+
+// LoadConfig returns the default config, overridden by the settings in the
+// config file at the passed path, if there is one, and then by the
+// environment. Files ending in .toml are read as TOML, and anything else as
+// YAML.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+
+		file := map[string]any{}
+		if strings.EqualFold(filepath.Ext(path), ".toml") {
+			err = toml.Unmarshal(contents, &file)
+		} else {
+			err = yaml.Unmarshal(contents, &file)
+		}
+		if err != nil {
+			return config, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+
+		if err = config.readFile(file); err != nil {
+			return config, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	exportedMu.Lock()
+	defer exportedMu.Unlock()
+
+	err := config.settings(func(field reflect.Value, key, env string) error {
+		if str, found := os.LookupEnv(env); found && str != "" && !exported[env] {
+			if err := parseSetting(field, str); err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+		}
+		return nil
+	})
+	return config, err
+}
+
+// readFile sets each setting found in the passed decoded config file, and
+// returns an error if the file contains settings that don't exist.
+func (config *Config) readFile(file map[string]any) error {
+	unknown := []string{}
+	for section, value := range file {
+		if _, isSection := value.(map[string]any); !isSection {
+			unknown = append(unknown, section)
+		}
+	}
+
+	err := config.settings(func(field reflect.Value, key, env string) error {
+		sectionName, name, _ := strings.Cut(key, ".")
+		section, _ := file[sectionName].(map[string]any)
+		value, found := section[name]
+		if !found {
+			return nil
+		}
+		delete(section, name)
+
+		if err := parseSetting(field, formatFileValue(value)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for sectionName, value := range file {
+		if section, isSection := value.(map[string]any); isSection {
+			for name := range section {
+				unknown = append(unknown, sectionName+"."+name)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// settings calls fn for each setting in the config, with the name of the
+// setting in a config file and the environment variable that overrides it.
+func (config *Config) settings(fn func(field reflect.Value, key, env string) error) error {
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		sectionName := sections.Type().Field(i).Tag.Get("config")
+
+		for j := 0; j < section.NumField(); j++ {
+			tag := section.Type().Field(j).Tag
+			key := sectionName + "." + tag.Get("config")
+			if err := fn(section.Field(j), key, tag.Get("env")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parseSetting sets the field to the value written in the passed string, in
+// the same format as would be used in an environment variable.
+func parseSetting(field reflect.Value, str string) error {
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(str)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(parseEndpoints(str)))
+	case reflect.Int, reflect.Int64:
+		value, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(value)
+	case reflect.Uint:
+		value, err := strconv.ParseUint(str, 10, 32)
+		if err != nil {
+			return err
+		}
+		field.SetUint(value)
+	case reflect.Uint64:
+		value, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(value)
+	case reflect.Float64:
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(value)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// formatFileValue writes a value decoded from a config file in the same format
+// as would be used in an environment variable.
+func formatFileValue(value any) string {
+	switch value := value.(type) {
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		items := make([]string, 0, len(value))
+		for key, item := range value {
+			items = append(items, fmt.Sprintf("%s=%v", key, item))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	case map[any]any:
+		// YAML decodes maps with keys that aren't strings, such as chain IDs,
+		// like this.
+		items := make([]string, 0, len(value))
+		for key, item := range value {
+			items = append(items, fmt.Sprintf("%v=%v", key, item))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// formatSetting writes the value of the field in the same format as would be
+// used in an environment variable.
+func formatSetting(field reflect.Value) string {
+	switch value := field.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case []string:
+		return strings.Join(value, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// The environment variables set by Export, which hold values from the config
+// file rather than overriding it, so are replaced when the config is loaded
+// again.
+var (
+	exported   = map[string]bool{}
+	exportedMu sync.Mutex
+)
+
+// Export sets the environment variable of each setting that has a value and
+// isn't already set in the environment, so that the parts of the bridge that
+// read their settings from the environment see the values from the config
+// file.
+func (config *Config) Export() error {
+	exportedMu.Lock()
+	defer exportedMu.Unlock()
+
+	return config.settings(func(field reflect.Value, key, env string) error {
+		str := formatSetting(field)
+		if _, found := os.LookupEnv(env); found && !exported[env] {
+			return nil
+		} else if str == "" {
+			delete(exported, env)
+			return os.Unsetenv(env)
+		}
+		exported[env] = true
+		return os.Setenv(env, str)
+	})
+}
+
+// Validate checks that every setting has a usable value, returning an error
+// that describes all of the problems found.
+func (config *Config) Validate() error {
+	problems := []string{}
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if config.Chain.RPCEndpoint == "" {
+		problem("chain.rpcEndpoint is required")
+	} else if err := validateURL(config.Chain.RPCEndpoint, "http", "https", "ws", "wss"); err != nil {
+		problem("chain.rpcEndpoint: %s", err)
+	}
+	if config.Chain.WebsocketEndpoint != "" {
+		if err := validateURL(config.Chain.WebsocketEndpoint, "ws", "wss"); err != nil {
+			problem("chain.websocketEndpoint: %s", err)
+		}
+	}
+	if config.Chain.ChainID <= 0 {
+		problem("chain.chainId must be positive")
+	}
+	for _, entry := range config.Chain.Contracts {
+		chainID, addresses, _ := strings.Cut(entry, "=")
+		if _, err := strconv.ParseInt(chainID, 10, 64); err != nil {
+			problem("chain.contracts: %q must be a chain ID and hex addresses", entry)
+			continue
+		}
+		for _, address := range strings.Split(addresses, ",") {
+			if !common.IsHexAddress(strings.TrimSpace(address)) {
+				problem("chain.contracts: %q must be a chain ID and hex addresses", entry)
+				break
+			}
+		}
+	}
+	if _, err := config.Chain.ContractAddresses(); err != nil {
+		problem("chain.contractAddress: %s", err)
+	}
+	switch config.Signer.Backend {
+	case SignerKey:
+		if _, err := crypto.HexToECDSA(config.Chain.WalletPrivateKey); err != nil {
+			problem("chain.walletPrivateKey: %s", err)
+		}
+	case SignerKeystore:
+		if config.Signer.KeystoreFile == "" {
+			problem("signer.keystoreFile is required for the keystore signer")
+		}
+	case SignerAWSKMS:
+		if config.Signer.KMSKey == "" || config.Signer.AWSRegion == "" {
+			problem("signer.kmsKey and signer.awsRegion are required for the awskms signer")
+		}
+	case SignerGCPKMS:
+		if config.Signer.KMSKey == "" {
+			problem("signer.kmsKey is required for the gcpkms signer")
+		}
+	case SignerVault:
+		if err := validateURL(config.Signer.VaultAddress, "http", "https"); err != nil {
+			problem("signer.vaultAddress: %s", err)
+		}
+		if config.Signer.VaultToken == "" || config.Signer.VaultPath == "" {
+			problem("signer.vaultToken and signer.vaultPath are required for the vault signer")
+		}
+	case SignerLedger:
+		if _, err := accounts.ParseDerivationPath(config.Signer.LedgerPath); err != nil {
+			problem("signer.ledgerPath: %s", err)
+		}
+		if config.Signer.LedgerTimeout <= 0 {
+			problem("signer.ledgerTimeout must be positive")
+		}
+	default:
+		problem("signer.backend must be one of %v", SignerNames())
+	}
+
+	if config.Relayer.URL != "" {
+		if err := validateURL(config.Relayer.URL, "http", "https"); err != nil {
+			problem("relayer.url: %s", err)
+		}
+		if !common.IsHexAddress(config.Relayer.Forwarder) {
+			problem("relayer.forwarder must be a hex address to use a relayer")
+		}
+		if config.Signer.Backend == SignerLedger {
+			problem("the ledger signer can't sign meta-transactions for a relayer")
+		}
+	}
+
+	if !contains(FeeStrategyNames(), config.Gas.FeeStrategy) {
+		problem("gas.feeStrategy must be one of %v", FeeStrategyNames())
+	}
+	if config.Gas.FeeStrategy == FeeStrategyStatic {
+		if config.Gas.MaxFeePerGas <= 0 {
+			problem("gas.maxFeePerGas must be positive for the static fee strategy")
+		}
+		if config.Gas.MaxPriorityFeePerGas < 0 || config.Gas.MaxPriorityFeePerGas > config.Gas.MaxFeePerGas {
+			problem("gas.maxPriorityFeePerGas must be between zero and gas.maxFeePerGas")
+		}
+	}
+	if config.Gas.FeePercentile < 0 || config.Gas.FeePercentile > 100 {
+		problem("gas.feePercentile must be between 0 and 100")
+	}
+	if config.Gas.FeeCap < 0 {
+		problem("gas.feeCap can't be negative")
+	}
+	if config.Gas.ReplaceAfter < 0 {
+		problem("gas.replaceAfter can't be negative")
+	}
+	if config.Gas.FeeBump < 10 {
+		problem("gas.feeBump must be at least 10, as nodes refuse smaller increases")
+	}
+	if config.Gas.DailyBudget < 0 {
+		problem("gas.dailyBudget can't be negative")
+	}
+	if config.Gas.MinBalance < 0 {
+		problem("gas.minBalance can't be negative")
+	}
+	if config.Gas.BalanceCheckInterval <= 0 {
+		problem("gas.balanceCheckInterval must be positive")
+	}
+	if config.Gas.BatchSize > 1 && config.Gas.BatchWindow <= 0 {
+		problem("gas.batchWindow must be positive to return results in batches")
+	}
+
+	if !contains(RunnerNames(), config.Bacalhau.Runner) {
+		problem("bacalhau.runner must be one of %v", RunnerNames())
+	}
+	for _, endpoint := range config.Bacalhau.Endpoints {
+		if err := validateURL(endpoint, "http", "https"); err != nil {
+			problem("bacalhau.endpoints: %s", err)
+		}
+	}
+	switch EndpointSelection(config.Bacalhau.EndpointSelection) {
+	case EndpointSelectionPriority, EndpointSelectionRoundRobin:
+	default:
+		problem("bacalhau.endpointSelection must be %q or %q", EndpointSelectionPriority, EndpointSelectionRoundRobin)
+	}
+	for key, duration := range map[string]time.Duration{
+		"bacalhau.pollInterval":  config.Bacalhau.PollInterval,
+		"bacalhau.submitTimeout": config.Bacalhau.SubmitTimeout,
+		"bacalhau.listTimeout":   config.Bacalhau.ListTimeout,
+		"bacalhau.checkTimeout":  config.Bacalhau.CheckTimeout,
+	} {
+		if duration <= 0 {
+			problem("%s must be positive", key)
+		}
+	}
+	if config.Bacalhau.HeartbeatInterval < 0 {
+		problem("bacalhau.heartbeatInterval must not be negative")
+	}
+	if config.Bacalhau.MaxJobDuration < 0 {
+		problem("bacalhau.maxJobDuration must not be negative")
+	}
+
+	if config.Bacalhau.CheckConcurrency == 0 {
+		problem("bacalhau.checkConcurrency must be positive")
+	}
+	if config.Bacalhau.MaxRunningJobs < 0 {
+		problem("bacalhau.maxRunningJobs must not be negative")
+	}
+	if config.Bacalhau.Namespace != "" && !ValidNamespace(config.Bacalhau.Namespace) {
+		problem("bacalhau.namespace may only contain letters, digits, underscores and dots")
+	}
+	if config.Bacalhau.CheckInputs && config.Bacalhau.InputCheckTimeout <= 0 {
+		problem("bacalhau.inputCheckTimeout must be positive")
+	}
+	if config.Bacalhau.TemplatesRepo != "" {
+		if !commitHash.MatchString(config.Bacalhau.TemplatesCommit) {
+			problem("bacalhau.templatesCommit must be a full commit hash")
+		}
+		if config.Bacalhau.TemplatesCache == "" {
+			problem("bacalhau.templatesRepo needs bacalhau.templatesCache")
+		}
+	} else if config.Bacalhau.TemplatesDir != "" {
+		if _, err := LoadTemplates(config.Bacalhau.TemplatesDir); err != nil {
+			problem("bacalhau.templatesDir: %s", err)
+		}
+	}
+
+	for _, endpoint := range config.Mediation.Endpoints {
+		if err := validateURL(endpoint, "http", "https"); err != nil {
+			problem("mediation.endpoints: %s", err)
+		}
+	}
+	if config.Mediation.FailedJobs && len(config.Mediation.Endpoints) == 0 {
+		problem("mediation.failedJobs needs mediation.endpoints")
+	}
+	if config.Mediation.PollInterval <= 0 {
+		problem("mediation.pollInterval must be positive")
+	}
+	if config.Mediation.MaxAttempts == 0 {
+		problem("mediation.maxAttempts must be positive")
+	}
+
+	for _, endpoint := range config.Verification.Endpoints {
+		if err := validateURL(endpoint, "http", "https"); err != nil {
+			problem("verification.endpoints: %s", err)
+		}
+	}
+	if config.Verification.Percent < 0 || config.Verification.Percent > 100 {
+		problem("verification.percent must be between 0 and 100")
+	} else if config.Verification.Percent > 0 && len(config.Verification.Endpoints) == 0 {
+		problem("verification.percent needs verification.endpoints")
+	}
+
+	if config.Pinning.Service != "" {
+		if _, err := NewPinner(config.Pinning.Service, config.Pinning.Endpoint, config.Pinning.Token); err != nil {
+			problem("pinning.service: %s", err)
+		}
+		if config.Pinning.Endpoint != "" {
+			if err := validateURL(config.Pinning.Endpoint, "http", "https"); err != nil {
+				problem("pinning.endpoint: %s", err)
+			}
+		}
+		if config.Pinning.Token == "" && !strings.EqualFold(config.Pinning.Service, "ipfs") {
+			problem("pinning.token is required for pinning service %s", config.Pinning.Service)
+		}
+		if config.Pinning.MaxAttempts == 0 {
+			problem("pinning.maxAttempts must be positive")
+		}
+	}
+
+	if config.Storage.HashOutputs || config.Storage.IPFSAPI != "" || config.Bacalhau.CheckInputs {
+		if err := validateURL(config.Storage.IPFSGateway, "http", "https"); err != nil {
+			problem("storage.ipfsGateway: %s", err)
+		}
+	}
+	if config.Storage.IPFSAPI != "" {
+		if err := validateURL(config.Storage.IPFSAPI, "http", "https"); err != nil {
+			problem("storage.ipfsApi: %s", err)
+		}
+	}
+	if config.Storage.DealAPI != "" {
+		if err := validateURL(config.Storage.DealAPI, "http", "https"); err != nil {
+			problem("storage.dealApi: %s", err)
+		}
+		if config.Storage.DealDuration < MinDealDuration || config.Storage.DealDuration > MaxDealDuration {
+			problem("storage.dealDuration must be between %s and %s", MinDealDuration, MaxDealDuration)
+		}
+	}
+	if config.Storage.ResultsDir != "" && config.Storage.ResultsBucket != "" {
+		problem("only one of storage.resultsDir and storage.resultsBucket can be set")
+	}
+	if config.Storage.ResultsBucket != "" && config.Storage.ResultsRegion == "" {
+		problem("storage.resultsBucket needs storage.resultsRegion")
+	}
+	if config.Storage.SQLiteFile == "" && config.Storage.PostgresDSN == "" {
+		problem("one of storage.sqliteFile or storage.postgresDsn is required")
+	}
+	if config.Storage.LeaderElection && config.Storage.PostgresDSN == "" {
+		problem("storage.leaderElection needs storage.postgresDsn")
+	}
+	if config.Storage.Partitions > 0 && config.Storage.PostgresDSN == "" {
+		problem("storage.partitions needs storage.postgresDsn")
+	}
+	if config.Storage.Partitions > 0 && config.Storage.LeaderElection {
+		problem("only one of storage.leaderElection or storage.partitions can be used")
+	}
+
+	if config.Limits.SubmitRateLimit < 0 {
+		problem("limits.submitRateLimit must not be negative")
+	}
+	if config.Limits.SubmitBurst < 1 {
+		problem("limits.submitBurst must be at least 1")
+	}
+	if config.Limits.ShutdownGracePeriod < 0 {
+		problem("limits.shutdownGracePeriod must not be negative")
+	}
+	if config.Limits.PriorityHighPrice < 0 || config.Limits.PriorityLowPrice < 0 || config.Limits.PriorityMaxWait < 0 {
+		problem("limits.priorityHighPrice, limits.priorityLowPrice and limits.priorityMaxWait must not be negative")
+	}
+	if high, low := config.Limits.PriorityHighPrice, config.Limits.PriorityLowPrice; high > 0 && low > high {
+		problem("limits.priorityLowPrice must not be more than limits.priorityHighPrice")
+	}
+	if config.Limits.ClientMaxRunning < 0 || config.Limits.ClientMaxPerHour < 0 {
+		problem("limits.clientMaxRunning and limits.clientMaxPerHour must not be negative")
+	}
+	if _, err := config.Limits.ClientQuotas(); err != nil {
+		problem("limits.clientWeights: %s", err)
+	}
+	if _, err := config.Limits.AddressList(); err != nil {
+		problem("limits.blockedAddresses and limits.allowedAddresses: %s", err)
+	}
+	if config.Limits.PolicyFile != "" {
+		if _, err := LoadPolicy(config.Limits.PolicyFile); err != nil {
+			problem("limits.policyFile: %s", err)
+		}
+	}
+	if err := checkResourceLimits(config.Limits.Resources()); err != nil {
+		problem("limits: %s", err)
+	}
+
+	for key, price := range map[string]float64{
+		"pricing.cpuHour":    config.Pricing.CPUHour,
+		"pricing.memoryHour": config.Pricing.MemoryHour,
+		"pricing.diskHour":   config.Pricing.DiskHour,
+		"pricing.gpuHour":    config.Pricing.GPUHour,
+		"pricing.job":        config.Pricing.Job,
+	} {
+		if price < 0 {
+			problem("%s must not be negative", key)
+		}
+	}
+	if config.Pricing.ProfilesFile != "" {
+		if policy, err := LoadPricingPolicy(config.Pricing.ProfilesFile); err != nil {
+			problem("pricing.profilesFile: %s", err)
+		} else if config.Pricing.TokensFile == "" {
+			for _, profile := range policy.Profiles {
+				if profile.MinFiatPrice > 0 {
+					problem("pricing.profilesFile: minFiatPrice of profile %s needs pricing.tokensFile to price orders", profile.Name)
+				}
+			}
+		}
+	}
+	if config.Pricing.TokensFile != "" {
+		if _, err := LoadTokenPrices(config.Pricing.TokensFile); err != nil {
+			problem("pricing.tokensFile: %s", err)
+		}
+	}
+	if config.Pricing.OracleURL != "" {
+		if config.Pricing.TokensFile == "" {
+			problem("pricing.oracleUrl needs pricing.tokensFile to say which tokens to price")
+		} else if err := validateURL(config.Pricing.OracleURL, "http", "https"); err != nil {
+			problem("pricing.oracleUrl: %s", err)
+		}
+	}
+	if config.Pricing.OracleInterval < 0 || config.Pricing.OracleMaxAge < 0 {
+		problem("pricing.oracleInterval and pricing.oracleMaxAge must not be negative")
+	}
+
+	if !contains(JobCacheNames(), config.Cache.Backend) {
+		problem("cache.backend must be one of %v", JobCacheNames())
+	}
+	if config.Cache.TTL < 0 || config.Cache.MaxEntries < 0 {
+		problem("cache.ttl and cache.maxEntries must not be negative")
+	}
+	if config.Cache.Backend == JobCacheRedis && config.Cache.TTL > 0 {
+		if err := validateURL(config.Cache.RedisURL, "redis"); err != nil {
+			problem("cache.redisUrl: %s", err)
+		}
+	}
+
+	for key, rate := range map[string]float64{
+		"chaos.dropRate":       config.Chaos.DropRate,
+		"chaos.disconnectRate": config.Chaos.DisconnectRate,
+	} {
+		if rate < 0 || rate > 1 {
+			problem("%s must be between 0 and 1", key)
+		}
+	}
+	if config.Chaos.PollDelay < 0 || config.Chaos.DisconnectDuration < 0 || config.Chaos.ClockSkew < 0 {
+		problem("chaos.pollDelay, chaos.disconnectDuration and chaos.clockSkew must not be negative")
+	}
+
+	for key, endpoint := range map[string]string{
+		"alerts.webhookUrl":      config.Alerts.WebhookURL,
+		"alerts.slackWebhookUrl": config.Alerts.SlackWebhookURL,
+	} {
+		if endpoint != "" {
+			if err := validateURL(endpoint, "http", "https"); err != nil {
+				problem("%s: %s", key, err)
+			}
+		}
+	}
+	if config.Alerts.CheckInterval <= 0 {
+		problem("alerts.checkInterval must be positive")
+	}
+	if config.Alerts.APIFailures < 0 || config.Alerts.MinBalance < 0 || config.Alerts.StuckAfter < 0 {
+		problem("alerts.apiFailures, alerts.minBalance and alerts.stuckAfter must not be negative")
+	}
+
+	if _, err := config.SLA.WindowDurations(); err != nil {
+		problem("sla.windows: %s", err)
+	}
+	if config.SLA.Target < 0 {
+		problem("sla.target must not be negative")
+	}
+	if config.SLA.ReportDir != "" && config.SLA.ReportInterval <= 0 {
+		problem("sla.reportInterval must be positive")
+	}
+
+	if config.Server.GRPCAddress != "" && config.Server.AdminToken == "" {
+		problem("server.grpcAddress needs server.adminToken, so that only the operator can control the bridge")
+	}
+	if config.Server.QueueMetricsInterval <= 0 {
+		problem("server.queueMetricsInterval must be positive")
+	}
+
+	if _, err := logger.ParseLogMode(config.Log.Mode); err != nil {
+		problem("log.mode: %s", err)
+	}
+	if _, err := zerolog.ParseLevel(config.Log.Level); err != nil {
+		problem("log.level: %s", err)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func validateURL(str string, schemes ...string) error {
+	parsed, err := url.Parse(str)
+	if err != nil {
+		return err
+	} else if !contains(schemes, parsed.Scheme) || parsed.Host == "" {
+		return fmt.Errorf("%q must be a %s URL", str, strings.Join(schemes, " or "))
+	}
+	return nil
+}
+
+func contains(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrivateKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func writeConfig(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestLoadYAMLConfig(t *testing.T) {
+	path := writeConfig(t, "lilypad.yaml", `
+chain:
+  rpcEndpoint: ws://localhost:8545
+  chainId: 31337
+bacalhau:
+  endpoints:
+    - http://one:1234
+    - http://two:1234
+  pollInterval: 10s
+limits:
+  submitRateLimit: 0.5
+`)
+	t.Setenv("BACALHAU_POLL_INTERVAL", "1m")
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, "ws://localhost:8545", config.Chain.RPCEndpoint)
+	require.Equal(t, int64(31337), config.Chain.ChainID)
+	require.Equal(t, []string{"http://one:1234", "http://two:1234"}, config.Bacalhau.Endpoints)
+	require.Equal(t, time.Minute, config.Bacalhau.PollInterval, "the environment should override the file")
+	require.Equal(t, 0.5, config.Limits.SubmitRateLimit)
+	require.Equal(t, DefaultConfig().Bacalhau.SubmitTimeout, config.Bacalhau.SubmitTimeout)
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+	path := writeConfig(t, "lilypad.toml", `
+[chain]
+chainId = 314159
+
+[bacalhau]
+endpoints = ["http://one:1234"]
+maxJobDuration = "2h"
+`)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(314159), config.Chain.ChainID)
+	require.Equal(t, []string{"http://one:1234"}, config.Bacalhau.Endpoints)
+	require.Equal(t, 2*time.Hour, config.Bacalhau.MaxJobDuration)
+}
+
+func TestConfigRejectsUnknownSettings(t *testing.T) {
+	path := writeConfig(t, "lilypad.yaml", "chain:\n  rpcEndpiont: ws://localhost:8545\nextra: true\n")
+	_, err := LoadConfig(path)
+	require.ErrorContains(t, err, "chain.rpcEndpiont")
+	require.ErrorContains(t, err, "extra")
+
+	path = writeConfig(t, "lilypad.yaml", "bacalhau:\n  pollInterval: soon\n")
+	_, err = LoadConfig(path)
+	require.ErrorContains(t, err, "bacalhau.pollInterval")
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Chain = ChainConfig{
+		RPCEndpoint:      "ws://localhost:8545",
+		ChainID:          31337,
+		ContractAddress:  "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		WalletPrivateKey: testPrivateKey,
+	}
+	require.NoError(t, config.Validate())
+
+	config.Chain.RPCEndpoint = "localhost:8545"
+	config.Chain.WebsocketEndpoint = "http://localhost:8545"
+	config.Bacalhau.PollInterval = 0
+	config.Limits.SubmitBurst = 0
+	err := config.Validate()
+	require.Error(t, err)
+	require.Len(t, strings.Split(err.Error(), "\n"), 5, "every problem should be reported")
+}
+
+func TestContractForChain(t *testing.T) {
+	path := writeConfig(t, "lilypad.yaml", `
+chain:
+  chainId: 314159
+  contracts:
+    31337: "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+    314159: "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"
+`)
+	clearSettings(t)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	address, err := config.Chain.Contract()
+	require.NoError(t, err)
+	require.Equal(t, "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512", address.Hex())
+
+	config.Chain.ContractAddress = "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+	address, err = config.Chain.Contract()
+	require.NoError(t, err)
+	require.Equal(t, config.Chain.ContractAddress, address.Hex(), "contractAddress should take precedence")
+
+	config.Chain.ContractAddress = ""
+	config.Chain.ChainID = 1
+	_, err = config.Chain.Contract()
+	require.Error(t, err)
+}
+
+func TestSeveralContractAddresses(t *testing.T) {
+	chain := ChainConfig{ChainID: 31337, Contracts: []string{
+		"31337=0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		"314159=0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512",
+		"31337=0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0,0xCf7Ed3AccA5a467e9e704C703E8D87F634fB0Fc9",
+	}}
+	addresses, err := chain.ContractAddresses()
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{
+		common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"),
+		common.HexToAddress("0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0"),
+		common.HexToAddress("0xCf7Ed3AccA5a467e9e704C703E8D87F634fB0Fc9"),
+	}, addresses)
+
+	first, err := chain.Contract()
+	require.NoError(t, err)
+	require.Equal(t, addresses[0], first)
+
+	chain.ContractAddress = "0x5FbDB2315678afecb367f032d93F642f64180aa3, nonsense"
+	_, err = chain.ContractAddresses()
+	require.Error(t, err)
+}
+
+// clearSettings unsets the environment variable of every setting, and makes
+// sure that everything exported is put back after the test.
+func clearSettings(t *testing.T) {
+	config := DefaultConfig()
+	require.NoError(t, config.settings(func(field reflect.Value, key, env string) error {
+		t.Setenv(env, "")
+		return os.Unsetenv(env)
+	}))
+	t.Cleanup(func() { exported = map[string]bool{} })
+}
+
+func TestExportConfig(t *testing.T) {
+	config := DefaultConfig()
+	clearSettings(t)
+	t.Setenv("LOG_LEVEL", "DEBUG")
+
+	config.Server.MetricsAddress = "localhost:9999"
+	config.Bacalhau.Endpoints = []string{"http://one:1234", "http://two:1234"}
+	require.NoError(t, config.Export())
+
+	require.Equal(t, "localhost:9999", os.Getenv("METRICS_ADDRESS"))
+	require.Equal(t, "http://one:1234,http://two:1234", os.Getenv("BACALHAU_API_ENDPOINTS"))
+	require.Equal(t, "DEBUG", os.Getenv("LOG_LEVEL"), "the environment should not be overwritten")
+}
+
+func TestReloadExportedConfig(t *testing.T) {
+	clearSettings(t)
+
+	path := writeConfig(t, "lilypad.yaml", "bacalhau:\n  pollInterval: 10s\n")
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.NoError(t, config.Export())
+
+	// Values exported from the file shouldn't stop the file being reloaded.
+	require.NoError(t, os.WriteFile(path, []byte("bacalhau:\n  pollInterval: 20s\n"), 0600))
+	config, err = LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, 20*time.Second, config.Bacalhau.PollInterval)
+	require.NoError(t, config.Export())
+	require.Equal(t, "20s", os.Getenv("BACALHAU_POLL_INTERVAL"))
+}
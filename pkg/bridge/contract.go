@@ -2,19 +2,21 @@ package bridge
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadEventsUpgradeable.sol"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/go-co-op/gocron"
 	"github.com/rs/zerolog/log"
 )
 
@@ -26,154 +28,550 @@ type SmartContract interface {
 	Refund(context.Context, ContractFailedEvent) (ContractRefundedEvent, error)
 }
 
+// A BlockCheckpointStore remembers how far through the chain the events of a
+// contract have been read, so that a restarted bridge can carry on from there.
+type BlockCheckpointStore interface {
+	// SaveCheckpoint records that every event up to and including the passed
+	// block has been read.
+	SaveCheckpoint(ctx context.Context, contract common.Address, block uint64) error
+
+	// Checkpoint returns the last block saved for the contract, if any.
+	Checkpoint(ctx context.Context, contract common.Address) (uint64, bool, error)
+}
+
+// An OrderAcknowledger is told when an order it read has been saved, so that
+// it never checkpoints past an order that would be lost if the bridge stopped.
+type OrderAcknowledger interface {
+	Acknowledge(e ContractSubmittedEvent)
+}
+
+var (
+	ErrWrongChain = errors.New("RPC endpoint is on the wrong chain")
+	ErrNoContract = errors.New("no contract deployed")
+)
+
 type realContract struct {
-	client     *ethclient.Client
-	contract   *LilypadEventsUpgradeable.LilypadEventsUpgradeable
-	privateKey *ecdsa.PrivateKey
+	client   *ethclient.Client
+	chainID  *big.Int
+	address  common.Address
+	contract *LilypadEventsUpgradeable.LilypadEventsUpgradeable
+	signer   Signer
+
+	// Which of the contract's events and methods can be used.
+	version ContractVersion
+
+	fees        FeeStrategy
+	nonces      *NonceManager
+	replacement ReplacementPolicy
+	pending     *pendingTransactions
+	budget      *GasBudget
+	funds       *FundsMonitor
+
+	// If set, transactions are relayed as meta-transactions rather than sent
+	// from the bridge's wallet.
+	relay *relay
+
+	maxSeenBlock  uint64
+	checkpoints   BlockCheckpointStore
+	confirmations uint64
+
+	// The orders that have been read but not yet saved, and the blocks they
+	// were in, which the checkpoint must not pass.
+	unsavedMu sync.Mutex
+	unsaved   map[common.Hash]uint64
+
+	// The transactions of recently read events and the blocks they were in,
+	// which are checked to make sure they haven't been removed by a reorg.
+	recent  map[common.Hash]uint64
+	removed chan common.Hash
+
+	// If set, new events are subscribed to over this websocket endpoint, and
+	// whether the subscription is currently live.
+	websocket  string
+	subscribed atomic.Bool
+
+	// Whether the wallet, and so its nonces and pending transactions, is
+	// shared with another contract that keeps track of the transactions.
+	sharesWallet bool
+}
 
-	maxSeenBlock uint64
+// The most blocks asked for in one request for events, as RPC providers limit
+// how many they will search at once.
+const maxLogRange uint64 = 2000
+
+// How many blocks an event is watched for after it has been read, in case it
+// is removed from the chain by a reorg.
+const reorgDepth uint64 = 64
+
+type contractOptions struct {
+	checkpoints   BlockCheckpointStore
+	startBlock    uint64
+	confirmations uint64
+	websocket     string
+	fees          FeeStrategy
+	replacement   *ReplacementPolicy
+	budget        *GasBudget
+	funds         *FundsMonitor
+	relay         *relayOptions
 }
 
-func (r *realContract) publicKey() *ecdsa.PublicKey {
-	return r.privateKey.Public().(*ecdsa.PublicKey)
+// A ContractOption configures the contract returned by NewContract.
+type ContractOption func(*contractOptions)
+
+// WithBlockCheckpoints sets where the contract records the last block it has
+// read events from. If a checkpoint has been saved, events are read from the
+// block after it, so that orders made whilst the bridge was down are seen.
+func WithBlockCheckpoints(store BlockCheckpointStore) ContractOption {
+	return func(opts *contractOptions) {
+		opts.checkpoints = store
+	}
 }
 
-func (r *realContract) wallet() common.Address {
-	return crypto.PubkeyToAddress(*r.publicKey())
+// WithStartBlock sets the block to start reading events from if there is no
+// checkpoint. Zero means the latest block.
+func WithStartBlock(block uint64) ContractOption {
+	return func(opts *contractOptions) {
+		opts.startBlock = block
+	}
 }
 
-func (r *realContract) pendingNonce(ctx context.Context) (uint64, error) {
-	return r.client.PendingNonceAt(ctx, r.wallet())
+// WithConfirmations makes the contract wait until an event is the passed
+// number of blocks deep before reading it, so that orders are unlikely to be
+// removed by a reorg after their job has been submitted.
+func WithConfirmations(confirmations uint64) ContractOption {
+	return func(opts *contractOptions) {
+		opts.confirmations = confirmations
+	}
 }
 
-func (r *realContract) prepareTransaction(ctx context.Context) (*bind.TransactOpts, error) {
-	nonce, err := r.pendingNonce(ctx)
-	if err != nil {
-		return nil, err
+// WithWebsocket makes the contract subscribe to new events over the passed
+// websocket endpoint, so that orders are seen as soon as they are made rather
+// than at the next poll. Polling carries on, less often, in case the
+// subscription misses anything, and as before whilst it is reconnecting.
+func WithWebsocket(endpoint string) ContractOption {
+	return func(opts *contractOptions) {
+		opts.websocket = endpoint
 	}
+}
 
-	chainIdStr, found := os.LookupEnv("CHAIN_ID")
-	if !found {
-		return nil, fmt.Errorf("CHAIN_ID env var must be set")
+// WithFeeStrategy sets how the contract decides the fees to offer for its
+// transactions, rather than reading the strategy from the environment.
+func WithFeeStrategy(strategy FeeStrategy) ContractOption {
+	return func(opts *contractOptions) {
+		opts.fees = strategy
 	}
+}
 
-	chainId, err := strconv.ParseInt(chainIdStr, 10, 32)
-	if err != nil {
-		return nil, err
+// WithReplacementPolicy sets when transactions that haven't been mined are
+// sent again with higher fees, rather than reading the policy from the
+// environment.
+func WithReplacementPolicy(policy ReplacementPolicy) ContractOption {
+	return func(opts *contractOptions) {
+		opts.replacement = &policy
 	}
+}
+
+// WithGasBudget makes the contract record what its transactions cost, and stop
+// sending them once the budget for the day has been spent.
+func WithGasBudget(budget *GasBudget) ContractOption {
+	return func(opts *contractOptions) {
+		opts.budget = budget
+	}
+}
+
+func (r *realContract) wallet() common.Address {
+	return r.signer.Address()
+}
 
-	opts, err := bind.NewKeyedTransactorWithChainID(r.privateKey, big.NewInt(chainId))
+// Balance implements BalanceReporter
+func (r *realContract) Balance(ctx context.Context) (*big.Int, error) {
+	return r.client.BalanceAt(ctx, r.wallet(), nil)
+}
+
+// transact sends the transaction made by the passed function with the next
+// nonce and the configured fees, unless the day's gas budget has been spent or
+// the wallet is low on funds, and returns its hash. If there is a relayer, the relayer sends it and pays
+// for the gas instead. Transactions are simulated first, and aren't sent if
+// they would revert.
+func (r *realContract) transact(ctx context.Context, send func(*bind.TransactOpts) (*types.Transaction, error)) (common.Hash, error) {
+	if err := r.simulate(ctx, send); err != nil {
+		return common.Hash{}, err
+	}
+	if r.relay != nil {
+		return r.relayTransaction(ctx, send)
+	}
+	if r.budget != nil {
+		if err := r.budget.Check(ctx); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if err := r.funds.Check(); err != nil {
+		return common.Hash{}, err
+	}
+
+	var txn *types.Transaction
+	err := r.nonces.Send(ctx, func(nonce uint64) error {
+		opts, err := r.prepareTransaction(ctx, nonce)
+		if err != nil {
+			return err
+		}
+		txn, err = send(opts)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return common.Hash{}, err
+	}
+	r.pending.track(txn)
+	return txn.Hash(), nil
+}
+
+func (r *realContract) prepareTransaction(ctx context.Context, nonce uint64) (*bind.TransactOpts, error) {
+	opts := &bind.TransactOpts{
+		From: r.wallet(),
+		Signer: func(address common.Address, txn *types.Transaction) (*types.Transaction, error) {
+			if address != r.wallet() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return r.signer.SignTransaction(ctx, txn, r.chainID)
+		},
 	}
 
-	opts.Nonce = big.NewInt(int64(nonce))
+	opts.Nonce = new(big.Int).SetUint64(nonce)
 	opts.Value = big.NewInt(0)
 	opts.Context = ctx
 
+	if r.fees != nil {
+		var err error
+		opts.GasFeeCap, opts.GasTipCap, err = r.fees.Fees(ctx, r.client)
+		if err != nil {
+			return nil, err
+		}
+		log.Ctx(ctx).Debug().
+			Stringer("maxFee", opts.GasFeeCap).
+			Stringer("tip", opts.GasTipCap).
+			Msg("Offering gas fees")
+	}
+
 	return opts, nil
 }
 
 // Complete implements SmartContract
-func (r *realContract) Complete(ctx context.Context, event BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
-	opts, err := r.prepareTransaction(ctx)
+func (r *realContract) Complete(ctx context.Context, event BacalhauJobCompletedEvent) (_ ContractPaidEvent, err error) {
+	ctx, span := startOrderSpan(ctx, "contract.Complete", event)
+	defer func() { endSpan(span, err) }()
+
+	if err = checkSealed(ctx, event); err != nil {
+		return nil, err
+	}
+
+	attest := event.OutputHash() != (common.Hash{})
+	if attest && r.supports(ContractVersion2, "attested results") != nil {
+		log.Ctx(ctx).Warn().Msg("Contract can't take attested results, returning the result without its output hash")
+		attest = false
+	}
+
+	hash, err := r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		if attest {
+			return r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadAttestedResults(
+				opts,
+				event.OrderRequestor(),
+				big.NewInt(event.OrderNumber()),
+				uint8(event.OrderResultType()),
+				contractResult(ctx, event),
+				event.OutputHash(),
+			)
+		}
+		return r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadResults(
+			opts,
+			event.OrderRequestor(),
+			big.NewInt(event.OrderNumber()),
+			uint8(event.OrderResultType()),
+			contractResult(ctx, event),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var result string
+	log.Ctx(ctx).Info().Stringer("txn", hash).Msg("Results returned")
+	return event.PaidIn(hash), nil
+}
+
+// CompleteBatch implements BatchCompleter
+func (r *realContract) CompleteBatch(ctx context.Context, events []BacalhauJobCompletedEvent) ([]ContractPaidEvent, error) {
+	if err := r.supports(ContractVersion2, "batched results"); err != nil {
+		return nil, err
+	}
+
+	requestors := make([]common.Address, len(events))
+	numbers := make([]*big.Int, len(events))
+	resultTypes := make([]uint8, len(events))
+	results := make([]string, len(events))
+	outputHashes := make([][32]byte, len(events))
+	attested := false
+	for i, event := range events {
+		if err := checkSealed(ctx, event); err != nil {
+			return nil, err
+		}
+		requestors[i] = event.OrderRequestor()
+		numbers[i] = big.NewInt(event.OrderNumber())
+		resultTypes[i] = uint8(event.OrderResultType())
+		results[i] = contractResult(ctx, event)
+		outputHashes[i] = event.OutputHash()
+		attested = attested || outputHashes[i] != common.Hash{}
+	}
+
+	hash, err := r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		if attested {
+			return r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadAttestedResultsBatch(
+				opts,
+				requestors,
+				numbers,
+				resultTypes,
+				results,
+				outputHashes,
+			)
+		}
+		return r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadResultsBatch(
+			opts,
+			requestors,
+			numbers,
+			resultTypes,
+			results,
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paid := make([]ContractPaidEvent, len(events))
+	for i, event := range events {
+		log.Ctx(ctx).Info().Stringer("id", event.OrderId()).Stringer("txn", hash).Msg("Results returned")
+		paid[i] = event.PaidIn(hash)
+	}
+	return paid, nil
+}
+
+// contractResult returns the result of the job in the form the order asked for.
+// Orders that asked for their results to be encrypted only ever get the CID of
+// the encrypted result.
+func contractResult(ctx context.Context, event BacalhauJobCompletedEvent) string {
+	if len(event.EncryptionKey()) > 0 {
+		if encrypted := event.EncryptedResult(ctx); encrypted.Defined() {
+			return encrypted.String()
+		}
+		return ""
+	}
+
 	switch event.OrderResultType() {
 	case ResultTypeCID:
-		result = event.Result().String()
+		return event.Result(ctx).String()
 	case ResultTypeStdOut:
-		result = event.StdOut()
+		return event.StdOut()
 	case ResultTypeStdErr:
-		result = event.StdErr()
+		return event.StdErr()
 	case ResultTypeExitCode:
-		result = fmt.Sprint(event.ExitCode())
+		return fmt.Sprint(event.ExitCode())
+	default:
+		return ""
 	}
+}
 
-	txn, err := r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadResults(
-		opts,
-		event.OrderRequestor(),
-		big.NewInt(event.OrderNumber()),
-		uint8(event.OrderResultType()),
-		result,
-	)
+// checkSealed makes sure that a result that should be encrypted isn't returned
+// before it has been.
+func checkSealed(ctx context.Context, event BacalhauJobCompletedEvent) error {
+	if len(event.EncryptionKey()) > 0 && !event.EncryptedResult(ctx).Defined() {
+		return errors.New("result must be encrypted before it is returned")
+	}
+	return nil
+}
+
+// Refund implements SmartContract. Contracts that keep what was paid for each
+// order give it back along with the error. If the payment can't be given back,
+// such as when the contract is too old to keep it or has already paid it into
+// escrow, only the error is returned.
+func (r *realContract) Refund(ctx context.Context, event ContractFailedEvent) (_ ContractRefundedEvent, err error) {
+	ctx, span := startOrderSpan(ctx, "contract.Refund", event)
+	defer func() { endSpan(span, err) }()
+
+	hash, err := r.refundPayment(ctx, event)
+	if err == nil {
+		paymentsRefunded.Inc()
+		log.Ctx(ctx).Info().Stringer("txn", hash).Msg("Payment refunded")
+		return event.RefundedIn(hash), nil
+	} else if errors.Is(err, bridgeerrors.ErrTxReverted) {
+		log.Ctx(ctx).Warn().Err(err).Msg("Unable to refund payment, returning the error without it")
+	} else if !errors.Is(err, ErrNotSupportedByContract) {
+		return nil, err
+	}
+
+	hash, err = r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadError(
+			opts,
+			event.OrderRequestor(),
+			big.NewInt(event.OrderNumber()),
+			event.Error(),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Ctx(ctx).Info().Stringer("txn", txn.Hash()).Msg("Results returned")
-	return event.Paid(), nil
+	log.Ctx(ctx).Info().Stringer("txn", hash).Msg("Error returned")
+	return event.RefundedIn(hash), nil
 }
 
-// Refund implements SmartContract
-func (r *realContract) Refund(ctx context.Context, event ContractFailedEvent) (ContractRefundedEvent, error) {
-	opts, err := r.prepareTransaction(ctx)
-	if err != nil {
-		return nil, err
+// refundPayment gives back what was paid for the order along with its error,
+// returning an error wrapping ErrNotSupportedByContract if the contract is too
+// old to keep payments.
+func (r *realContract) refundPayment(ctx context.Context, event ContractFailedEvent) (common.Hash, error) {
+	if err := r.supports(ContractVersion3, "refunding payments"); err != nil {
+		return common.Hash{}, err
+	}
+	return r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return r.contract.LilypadEventsUpgradeableTransactor.RefundLilypadJob(
+			opts,
+			event.OrderRequestor(),
+			big.NewInt(event.OrderNumber()),
+			event.Error(),
+		)
+	})
+}
+
+// Decline implements DecliningContract
+func (r *realContract) Decline(ctx context.Context, event ContractFailedEvent) (_ ContractRefundedEvent, err error) {
+	if r.supports(ContractVersion2, "declining orders") != nil {
+		// Older contracts can only be told that the order failed.
+		return r.Refund(ctx, event)
 	}
 
-	txn, err := r.contract.LilypadEventsUpgradeableTransactor.ReturnLilypadError(
-		opts,
-		event.OrderRequestor(),
-		big.NewInt(event.OrderNumber()),
-		event.Error(),
-	)
+	ctx, span := startOrderSpan(ctx, "contract.Decline", event)
+	defer func() { endSpan(span, err) }()
+
+	hash, err := r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return r.contract.LilypadEventsUpgradeableTransactor.DeclineLilypadJob(
+			opts,
+			event.OrderRequestor(),
+			big.NewInt(event.OrderNumber()),
+			event.Error(),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Ctx(ctx).Info().Stringer("txn", txn.Hash()).Msg("Error returned")
-	return event.Refunded(), nil
+	log.Ctx(ctx).Info().Stringer("txn", hash).Msg("Job declined")
+	return event.RefundedIn(hash), nil
 }
 
 // Listen implements SmartContract
 func (r *realContract) Listen(ctx context.Context, out chan<- ContractSubmittedEvent) error {
-	scheduler := gocron.NewScheduler(time.UTC)
-	_, err := scheduler.Every(15*time.Second).SingletonMode().Do(r.ReadLogs, ctx, out)
-	if err != nil {
-		return err
+	triggered := make(chan struct{}, 1)
+	if r.websocket != "" {
+		go r.subscribe(ctx, triggered)
+	}
+	if !r.sharesWallet {
+		go r.monitorTransactions(ctx)
 	}
 
-	scheduler.StartAsync()
-	defer scheduler.Stop()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-triggered:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-ctx.Done():
+			return nil
+		}
 
-	<-ctx.Done()
-	return nil
+		r.ReadLogs(ctx, out)
+		timer.Reset(r.pollInterval())
+	}
 }
 
 func (r *realContract) ReadLogs(ctx context.Context, out chan<- ContractSubmittedEvent) {
 	log.Ctx(ctx).Debug().Uint64("fromBlock", r.maxSeenBlock+1).Msg("Polling for smart contract events")
 
+	// The blocks whose events have all been saved don't need to be read
+	// again after a restart.
+	if checkpoint, ok := r.checkpoint(); ok && r.checkpoints != nil {
+		err := r.checkpoints.SaveCheckpoint(ctx, r.address, checkpoint)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to save block checkpoint")
+		}
+	}
+
 	// We deliberately ask for the current block *before* we make the events
-	// call. It's possible that a block will be written between the two calls:
-	//
-	//    FilterNewJobs(block: #1) -> seen block #1
-	//    block #2 gets written
-	//    BlockNumber() -> block #3
-	//    ...
-	//    FilterNewJobs(block: #3)
-	//
-	// In this case we would never see any events in block #2. So we instead
-	// remember the block number before the events call, and if a block is
-	// written between them, we will get it again next time we ask for events.
+	// call, and only ask for events up to it. A block written after that will
+	// be read next time we ask for events.
 	currentBlock, err := r.client.BlockNumber(ctx)
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Send()
+		return
+	}
+
+	r.checkRecent(ctx, currentBlock)
+	if currentBlock < r.confirmations {
+		return
+	}
+	currentBlock -= r.confirmations
+
+	// A bridge catching up after being down may have many blocks to read, so
+	// read them in ranges that RPC providers will accept.
+	for from := r.maxSeenBlock + 1; from <= currentBlock; from += maxLogRange {
+		to := from + maxLogRange - 1
+		if to > currentBlock {
+			to = currentBlock
+		}
+
+		if err := r.readRange(ctx, from, to, out); err != nil {
+			log.Ctx(ctx).Error().Err(err).Uint64("fromBlock", from).Uint64("toBlock", to).Send()
+			return
+		}
+		r.maxSeenBlock = to
+	}
+}
+
+// readRange sends on the events submitted between the passed blocks inclusive.
+func (r *realContract) readRange(ctx context.Context, from, to uint64, out chan<- ContractSubmittedEvent) error {
+	opts := bind.FilterOpts{Start: from, End: &to, Context: ctx}
+
+	// Older contracts don't emit the events that go with an order, so don't
+	// waste requests looking for them.
+	keys, durable, prices := map[int64][]byte{}, map[int64]bool{}, map[int64]string{}
+	if r.supports(ContractVersion2, "order details") == nil {
+		var err error
+		keys, err = r.encryptionKeys(&opts)
+		if err != nil {
+			return err
+		}
+		durable, err = r.durableOrders(&opts)
+		if err != nil {
+			return err
+		}
+		prices, err = r.offeredPrices(&opts)
+		if err != nil {
+			return err
+		}
+	}
+	tokens := map[int64]tokenPayment{}
+	if r.supports(ContractVersion4, "token payments") == nil {
+		var err error
+		tokens, err = r.tokenPayments(&opts)
+		if err != nil {
+			return err
+		}
 	}
 
-	opts := bind.FilterOpts{Start: uint64(r.maxSeenBlock + 1), Context: ctx}
 	logs, err := r.contract.LilypadEventsUpgradeableFilterer.FilterNewLilypadJobSubmitted(&opts)
 	if err != nil {
-		log.Ctx(ctx).Error().Err(err).Send()
-		return
+		return err
 	}
 	defer logs.Close()
 
-	r.maxSeenBlock = currentBlock
-
 	for logs.Next() {
 		recvEvent := logs.Event
 		log.Ctx(ctx).Debug().
@@ -187,45 +585,335 @@ func (r *realContract) ReadLogs(ctx context.Context, out chan<- ContractSubmitte
 			continue
 		}
 
-		if !ResultType(recvEvent.Job.ResultType).Valid() {
-			log.Ctx(ctx).Warn().Uint8("resultType", recvEvent.Job.ResultType).Msg("invalid ResultType")
+		order, err := orderFromLog(recvEvent, r.address)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Stringer("txn", recvEvent.Raw.TxHash).Msg("Skipping order")
+			continue
+		}
+		order.encryptionKey = keys[order.orderNumber]
+		order.durableStorage = durable[order.orderNumber]
+		order.orderPrice = prices[order.orderNumber]
+		if payment, found := tokens[order.orderNumber]; found {
+			order.orderPrice = payment.amount
+			order.orderToken = payment.token.Bytes()
+		}
+
+		r.recent[recvEvent.Raw.TxHash] = recvEvent.Raw.BlockNumber
+		r.track(order, recvEvent.Raw.BlockNumber)
+
+		select {
+		case out <- order:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return logs.Error()
+}
+
+// track notes that the order, read from the passed block, hasn't been saved.
+func (r *realContract) track(e Event, block uint64) {
+	r.unsavedMu.Lock()
+	defer r.unsavedMu.Unlock()
+	if r.unsaved == nil {
+		r.unsaved = map[common.Hash]uint64{}
+	}
+	r.unsaved[e.OrderId()] = block
+}
+
+// Acknowledge implements OrderAcknowledger
+func (r *realContract) Acknowledge(e ContractSubmittedEvent) {
+	r.unsavedMu.Lock()
+	defer r.unsavedMu.Unlock()
+	delete(r.unsaved, e.OrderId())
+}
+
+// checkpoint returns the last block whose events have all been saved, or false
+// if there isn't one.
+func (r *realContract) checkpoint() (uint64, bool) {
+	r.unsavedMu.Lock()
+	defer r.unsavedMu.Unlock()
+
+	checkpoint := r.maxSeenBlock
+	for _, block := range r.unsaved {
+		if block == 0 {
+			return 0, false
+		} else if block <= checkpoint {
+			checkpoint = block - 1
+		}
+	}
+	return checkpoint, true
+}
+
+// encryptionKeys returns the public keys that orders in the range asked for
+// their results to be encrypted with, by order number. The key is emitted in
+// its own event, in the same transaction as the order.
+func (r *realContract) encryptionKeys(opts *bind.FilterOpts) (map[int64][]byte, error) {
+	logs, err := r.contract.LilypadEventsUpgradeableFilterer.FilterLilypadEncryptedJobSubmitted(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	keys := map[int64][]byte{}
+	for logs.Next() {
+		if !logs.Event.Raw.Removed {
+			keys[logs.Event.Id.Int64()] = logs.Event.PublicKey
+		}
+	}
+	return keys, logs.Error()
+}
+
+// durableOrders returns the orders in the range that asked for a Filecoin
+// storage deal to be made for their results, by order number. The request is
+// emitted in its own event, in the same transaction as the order.
+func (r *realContract) durableOrders(opts *bind.FilterOpts) (map[int64]bool, error) {
+	logs, err := r.contract.LilypadEventsUpgradeableFilterer.FilterLilypadDurableJobSubmitted(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	durable := map[int64]bool{}
+	for logs.Next() {
+		if !logs.Event.Raw.Removed {
+			durable[logs.Event.Id.Int64()] = true
+		}
+	}
+	return durable, logs.Error()
+}
+
+// offeredPrices returns the prices in wei that orders in the range offered to
+// pay, by order number. The price is emitted in its own event, in the same
+// transaction as the order, by contracts that say what was paid.
+func (r *realContract) offeredPrices(opts *bind.FilterOpts) (map[int64]string, error) {
+	logs, err := r.contract.LilypadEventsUpgradeableFilterer.FilterLilypadJobPriceOffered(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	prices := map[int64]string{}
+	for logs.Next() {
+		if !logs.Event.Raw.Removed {
+			prices[logs.Event.Id.Int64()] = logs.Event.Price.String()
+		}
+	}
+	return prices, logs.Error()
+}
+
+// A tokenPayment is what an order paid for its job in an ERC-20 token.
+type tokenPayment struct {
+	token  common.Address
+	amount string
+}
+
+// tokenPayments returns what orders in the range that were paid for in an
+// ERC-20 token paid, by order number. The payment is emitted in its own
+// event, in the same transaction as the order, in place of the price offered
+// in the native token.
+func (r *realContract) tokenPayments(opts *bind.FilterOpts) (map[int64]tokenPayment, error) {
+	logs, err := r.contract.LilypadEventsUpgradeableFilterer.FilterLilypadJobTokenPriceOffered(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	payments := map[int64]tokenPayment{}
+	for logs.Next() {
+		if !logs.Event.Raw.Removed {
+			payments[logs.Event.Id.Int64()] = tokenPayment{token: logs.Event.Token, amount: logs.Event.Amount.String()}
+		}
+	}
+	return payments, logs.Error()
+}
+
+// checkRecent looks for the transactions of recently read events that are no
+// longer on the chain. Transactions that have gone back to the mempool are
+// expected to be mined again, so only those that have disappeared completely
+// are reported as removed.
+func (r *realContract) checkRecent(ctx context.Context, currentBlock uint64) {
+	for txHash, block := range r.recent {
+		if block+reorgDepth < currentBlock {
+			delete(r.recent, txHash)
+			continue
+		}
+
+		receipt, err := r.client.TransactionReceipt(ctx, txHash)
+		if err == nil {
+			r.recent[txHash] = receipt.BlockNumber.Uint64()
+			continue
+		} else if errors.Is(err, ethereum.NotFound) {
+			_, _, err = r.client.TransactionByHash(ctx, txHash)
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			log.Ctx(ctx).WithLevel(level(err)).Err(err).Stringer("txn", txHash).Msg("Checking for reorg")
 			continue
 		}
 
-		out <- &event{
-			orderId:         recvEvent.Raw.TxHash.Bytes(),
-			orderOwner:      recvEvent.Job.Requestor.Bytes(),
-			orderNumber:     recvEvent.Job.Id.Int64(),
-			orderResultType: recvEvent.Job.ResultType,
-			state:           OrderStateSubmitted,
-			jobSpec:         []byte(recvEvent.Job.Spec),
+		log.Ctx(ctx).Warn().Stringer("txn", txHash).Uint64("block#", block).Msg("Event removed by reorg")
+		delete(r.recent, txHash)
+		select {
+		case r.removed <- txHash:
+		default:
+			log.Ctx(ctx).Error().Stringer("txn", txHash).Msg("Reorg queue full, order will not be cancelled")
 		}
+	}
+}
 
-		r.maxSeenBlock = recvEvent.Raw.BlockNumber
+// WatchReorgs implements ReorgWatcher
+func (r *realContract) WatchReorgs(ctx context.Context, removed chan<- common.Hash) error {
+	for {
+		select {
+		case orderID := <-r.removed:
+			select {
+			case removed <- orderID:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
 	}
 }
 
-func NewContract(contractAddr common.Address, privateKey *ecdsa.PrivateKey) (SmartContract, error) {
+var _ ReorgWatcher = (*realContract)(nil)
+var _ BatchCompleter = (*realContract)(nil)
+var _ OrderAcknowledger = (*realContract)(nil)
+
+func NewContract(contractAddr common.Address, signer Signer, options ...ContractOption) (SmartContract, error) {
+	opts := contractOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	rpcEndpoint, found := os.LookupEnv("RPC_ENDPOINT")
 	if !found {
 		return nil, fmt.Errorf("RPC_ENDPOINT env var must be specified")
 	}
 
+	if opts.fees == nil {
+		fees, err := feeStrategyFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		opts.fees = fees
+	}
+
+	if opts.replacement == nil {
+		policy, err := replacementPolicyFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		opts.replacement = &policy
+	}
+
+	chainIDStr, found := os.LookupEnv("CHAIN_ID")
+	if !found {
+		return nil, fmt.Errorf("CHAIN_ID env var must be set")
+	}
+	chainID, ok := new(big.Int).SetString(chainIDStr, 10)
+	if !ok {
+		return nil, fmt.Errorf("CHAIN_ID: %q is not a number", chainIDStr)
+	}
+
 	log.Debug().Str("endpoint", rpcEndpoint).Msg("Dial")
 	client, err := ethclient.Dial(rpcEndpoint)
 	if err != nil {
 		return nil, err
 	}
 
-	contract, err := LilypadEventsUpgradeable.NewLilypadEventsUpgradeable(contractAddr, client)
+	// Make sure that the endpoint, chain and contract all agree, as sending
+	// transactions to the wrong chain wastes gas at best.
+	ctx := context.Background()
+	actualChainID, err := client.ChainID(ctx)
 	if err != nil {
 		return nil, err
+	} else if actualChainID.Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("%w: expected chain %s but found %s", ErrWrongChain, chainID, actualChainID)
 	}
 
-	number, err := client.BlockNumber(context.Background())
+	var relaying *relay
+	if opts.relay != nil {
+		relaying, err = newRelay(ctx, client, chainID, signer, opts.relay)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Stringer("forwarder", opts.relay.forwarder).Msg("Relaying transactions")
+	}
+
+	r, err := newRealContract(ctx, client, chainID, contractAddr, signer, opts)
 	if err != nil {
 		return nil, err
 	}
+	r.relay = relaying
+	r.nonces = NewNonceManager(client, r.wallet())
+	r.pending = new(pendingTransactions)
+	return r, nil
+}
+
+// newRealContract connects to the contract at the passed address, and works
+// out which block to read its events from. The wallet's nonces and pending
+// transactions are left for the caller to set.
+func newRealContract(ctx context.Context, client *ethclient.Client, chainID *big.Int, contractAddr common.Address, signer Signer, opts contractOptions) (*realContract, error) {
+	code, err := client.CodeAt(ctx, contractAddr, nil)
+	if err != nil {
+		return nil, err
+	} else if len(code) == 0 {
+		return nil, fmt.Errorf("%w at %s on chain %s", ErrNoContract, contractAddr, chainID)
+	}
+	log.Info().Stringer("chain", chainID).Stringer("contract", contractAddr).Msg("Connected to contract")
+
+	contract, err := LilypadEventsUpgradeable.NewLilypadEventsUpgradeable(contractAddr, client)
+	if err != nil {
+		return nil, err
+	}
+	version, err := detectContractVersion(ctx, contract)
+	if err != nil {
+		return nil, fmt.Errorf("contract %s: %w", contractAddr, err)
+	}
+	log.Info().Stringer("contract", contractAddr).Uint64("version", uint64(version)).Msg("Detected contract version")
+	contractVersion.WithLabelValues(contractAddr.Hex()).Set(float64(version))
+
+	// Carry on from the last checkpoint if there is one, or else from the
+	// start block, or else from now.
+	var number uint64
+	var checkpointed bool
+	if opts.checkpoints != nil {
+		number, checkpointed, err = opts.checkpoints.Checkpoint(ctx, contractAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if checkpointed {
+		log.Info().Uint64("block", number).Msg("Reading contract events from checkpoint")
+	} else if opts.startBlock > 0 {
+		number = opts.startBlock - 1
+	} else {
+		number, err = client.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if number > opts.confirmations {
+			number -= opts.confirmations
+		}
+	}
 
-	return &realContract{client, contract, privateKey, number}, nil
+	return &realContract{
+		client:        client,
+		chainID:       chainID,
+		fees:          opts.fees,
+		replacement:   *opts.replacement,
+		budget:        opts.budget,
+		funds:         opts.funds,
+		address:       contractAddr,
+		contract:      contract,
+		version:       version,
+		signer:        signer,
+		websocket:     opts.websocket,
+		maxSeenBlock:  number,
+		checkpoints:   opts.checkpoints,
+		confirmations: opts.confirmations,
+		recent:        map[common.Hash]uint64{},
+		removed:       make(chan common.Hash, 256),
+	}, nil
 }
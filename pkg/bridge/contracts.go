@@ -0,0 +1,249 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.ptx.dk/multierrgroup"
+)
+
+// ErrUnknownContract is returned for orders made on a contract the bridge
+// isn't watching. It is terminal, as the bridge can never settle them.
+var ErrUnknownContract = bridgeerrors.MarkTerminal(errors.New("order was made on a contract the bridge isn't watching"))
+
+// NewContracts connects to every passed deployment of the contract on the same
+// chain, such as the marketplaces of different modules, and returns a
+// SmartContract that reads orders from all of them. Each order is tagged with
+// the contract it was made on, and its result or error is returned there.
+// Orders saved before the bridge kept track of their contract are returned to
+// the first.
+//
+// Every deployment is sent transactions from the same wallet, which keeps a
+// single sequence of nonces. With one address, this is the same as
+// NewContract.
+func NewContracts(addresses []common.Address, signer Signer, options ...ContractOption) (SmartContract, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("no contract addresses")
+	}
+	first, err := NewContract(addresses[0], signer, options...)
+	if err != nil || len(addresses) == 1 {
+		return first, err
+	}
+
+	primary := first.(*realContract)
+	opts := contractOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	opts.fees = primary.fees
+	opts.replacement = &primary.replacement
+
+	contracts := &multiContract{
+		primary:   primary,
+		byAddress: map[common.Address]*realContract{primary.address: primary},
+		all:       []*realContract{primary},
+	}
+	for _, address := range addresses[1:] {
+		if _, found := contracts.byAddress[address]; found {
+			return nil, fmt.Errorf("contract %s is listed twice", address)
+		}
+
+		r, err := newRealContract(context.Background(), primary.client, primary.chainID, address, signer, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.relay = primary.relay
+		r.nonces = primary.nonces
+		r.pending = primary.pending
+		r.sharesWallet = true
+		contracts.byAddress[address] = r
+		contracts.all = append(contracts.all, r)
+	}
+	return contracts, nil
+}
+
+// A multiContract reads orders from several deployments of the contract and
+// routes what the bridge posts about each order back to the deployment it
+// came from.
+type multiContract struct {
+	// The contract given first, which keeps track of the wallet's
+	// transactions and gets the orders whose contract isn't known.
+	primary   *realContract
+	byAddress map[common.Address]*realContract
+	all       []*realContract
+}
+
+var (
+	_ SmartContract     = (*multiContract)(nil)
+	_ BatchCompleter    = (*multiContract)(nil)
+	_ DecliningContract = (*multiContract)(nil)
+	_ ReorgWatcher      = (*multiContract)(nil)
+	_ ResultDisputer    = (*multiContract)(nil)
+	_ MediationContract = (*multiContract)(nil)
+	_ BalanceReporter   = (*multiContract)(nil)
+	_ ReceiptReader     = (*multiContract)(nil)
+	_ SettlementChecker = (*multiContract)(nil)
+	_ OrderAcknowledger = (*multiContract)(nil)
+)
+
+// sourceContract returns the contract the order was made on, or the zero
+// address if it isn't known.
+func sourceContract(e Event) common.Address {
+	if sourced, ok := e.(interface{ SourceContract() common.Address }); ok {
+		return sourced.SourceContract()
+	}
+	return common.Address{}
+}
+
+// route returns the deployment that the order was made on.
+func (m *multiContract) route(e Event) (*realContract, error) {
+	address := sourceContract(e)
+	if address == (common.Address{}) {
+		return m.primary, nil
+	}
+	if r, found := m.byAddress[address]; found {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownContract, address)
+}
+
+// Listen implements SmartContract
+func (m *multiContract) Listen(ctx context.Context, out chan<- ContractSubmittedEvent) error {
+	wg := multierrgroup.Group{}
+	for _, r := range m.all {
+		r := r
+		wg.Go(func() error { return r.Listen(ctx, out) })
+	}
+	return wg.Wait()
+}
+
+// Complete implements SmartContract
+func (m *multiContract) Complete(ctx context.Context, event BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+	r, err := m.route(event)
+	if err != nil {
+		return nil, err
+	}
+	return r.Complete(ctx, event)
+}
+
+// CompleteBatch implements BatchCompleter. Results can only be returned in one
+// transaction if every order in the batch was made on the same contract;
+// otherwise no result is returned, so that the workflow returns them one by
+// one.
+func (m *multiContract) CompleteBatch(ctx context.Context, events []BacalhauJobCompletedEvent) ([]ContractPaidEvent, error) {
+	var batcher *realContract
+	for _, event := range events {
+		r, err := m.route(event)
+		if err != nil {
+			return nil, err
+		} else if batcher != nil && r != batcher {
+			return nil, errors.New("batch has results for more than one contract")
+		}
+		batcher = r
+	}
+	if batcher == nil {
+		return nil, nil
+	}
+	return batcher.CompleteBatch(ctx, events)
+}
+
+// Refund implements SmartContract
+func (m *multiContract) Refund(ctx context.Context, event ContractFailedEvent) (ContractRefundedEvent, error) {
+	r, err := m.route(event)
+	if err != nil {
+		return nil, err
+	}
+	return r.Refund(ctx, event)
+}
+
+// Decline implements DecliningContract
+func (m *multiContract) Decline(ctx context.Context, event ContractFailedEvent) (ContractRefundedEvent, error) {
+	r, err := m.route(event)
+	if err != nil {
+		return nil, err
+	}
+	return r.Decline(ctx, event)
+}
+
+// DisputeResult implements ResultDisputer
+func (m *multiContract) DisputeResult(ctx context.Context, event BacalhauJobCompletedEvent, expected string) error {
+	r, err := m.route(event)
+	if err != nil {
+		return err
+	}
+	return r.DisputeResult(ctx, event, expected)
+}
+
+// routeMediation returns the deployment that the mediated order was made on.
+func (m *multiContract) routeMediation(mediation Mediation) (*realContract, error) {
+	order, err := UnmarshalEvent(mediation.Event)
+	if err != nil {
+		return nil, err
+	}
+	return m.route(order)
+}
+
+// RequestMediation implements MediationContract
+func (m *multiContract) RequestMediation(ctx context.Context, mediation Mediation) error {
+	r, err := m.routeMediation(mediation)
+	if err != nil {
+		return err
+	}
+	return r.RequestMediation(ctx, mediation)
+}
+
+// ReturnVerdict implements MediationContract
+func (m *multiContract) ReturnVerdict(ctx context.Context, mediation Mediation) error {
+	r, err := m.routeMediation(mediation)
+	if err != nil {
+		return err
+	}
+	return r.ReturnVerdict(ctx, mediation)
+}
+
+// WatchReorgs implements ReorgWatcher
+func (m *multiContract) WatchReorgs(ctx context.Context, removed chan<- common.Hash) error {
+	wg := multierrgroup.Group{}
+	for _, r := range m.all {
+		r := r
+		wg.Go(func() error { return r.WatchReorgs(ctx, removed) })
+	}
+	return wg.Wait()
+}
+
+// Balance implements BalanceReporter
+func (m *multiContract) Balance(ctx context.Context) (*big.Int, error) {
+	return m.primary.Balance(ctx)
+}
+
+// Settled implements SettlementChecker
+func (m *multiContract) Settled(ctx context.Context, e Event) (bool, error) {
+	r, err := m.route(e)
+	if err != nil {
+		return false, err
+	}
+	return r.Settled(ctx, e)
+}
+
+// Acknowledge implements OrderAcknowledger
+func (m *multiContract) Acknowledge(e ContractSubmittedEvent) {
+	if r, err := m.route(e); err == nil {
+		r.Acknowledge(e)
+	}
+}
+
+// Receipt implements ReceiptReader. Every deployment is on the same chain and
+// sends from the same wallet, so the first knows about every transaction.
+func (m *multiContract) Receipt(ctx context.Context, txn common.Hash) (*types.Receipt, error) {
+	return m.primary.Receipt(ctx, txn)
+}
+
+// BlockNumber implements ReceiptReader
+func (m *multiContract) BlockNumber(ctx context.Context) (uint64, error) {
+	return m.primary.BlockNumber(ctx)
+}
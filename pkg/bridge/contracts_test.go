@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func contractEvent(id byte, contract common.Address) *event {
+	e := walEvent(id)
+	e.orderContract = contract.Bytes()
+	return e
+}
+
+func TestOrdersAreRoutedToTheirContract(t *testing.T) {
+	primary := &realContract{address: common.HexToAddress("0xa")}
+	other := &realContract{address: common.HexToAddress("0xb")}
+	contracts := &multiContract{
+		primary:   primary,
+		byAddress: map[common.Address]*realContract{primary.address: primary, other.address: other},
+		all:       []*realContract{primary, other},
+	}
+
+	r, err := contracts.route(contractEvent(0x01, other.address))
+	require.NoError(t, err)
+	require.Same(t, other, r)
+
+	r, err = contracts.route(walEvent(0x02))
+	require.NoError(t, err)
+	require.Same(t, primary, r, "orders from before contracts were tracked go to the first")
+
+	_, err = contracts.route(contractEvent(0x03, common.HexToAddress("0xc")))
+	require.ErrorIs(t, err, ErrUnknownContract)
+
+	_, err = contracts.CompleteBatch(context.Background(), []BacalhauJobCompletedEvent{
+		contractEvent(0x04, primary.address),
+		contractEvent(0x05, other.address),
+	})
+	require.Error(t, err, "results for different contracts can't share a transaction")
+}
+
+func TestSourceContractSurvivesEncoding(t *testing.T) {
+	contract := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	data, err := MarshalEvent(contractEvent(0x01, contract))
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalEvent(data)
+	require.NoError(t, err)
+	require.Equal(t, contract, sourceContract(decoded))
+
+	data, err = MarshalEvent(walEvent(0x01))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `"contract"`)
+}
+
+func TestCheckpointStopsBeforeUnsavedOrders(t *testing.T) {
+	primary := &realContract{address: common.HexToAddress("0xa"), maxSeenBlock: 100}
+	other := &realContract{address: common.HexToAddress("0xb"), maxSeenBlock: 100}
+	contracts := &multiContract{
+		primary:   primary,
+		byAddress: map[common.Address]*realContract{primary.address: primary, other.address: other},
+		all:       []*realContract{primary, other},
+	}
+
+	first, second := contractEvent(0x01, other.address), contractEvent(0x02, other.address)
+	other.track(first, 40)
+	other.track(second, 70)
+
+	checkpoint, ok := other.checkpoint()
+	require.True(t, ok)
+	require.Equal(t, uint64(39), checkpoint, "blocks with unsaved orders should be read again")
+
+	contracts.Acknowledge(first)
+	checkpoint, _ = other.checkpoint()
+	require.Equal(t, uint64(69), checkpoint)
+
+	contracts.Acknowledge(second)
+	checkpoint, _ = other.checkpoint()
+	require.Equal(t, uint64(100), checkpoint)
+
+	checkpoint, _ = primary.checkpoint()
+	require.Equal(t, uint64(100), checkpoint)
+}
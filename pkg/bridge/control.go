@@ -0,0 +1,351 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/bacalhau-project/lilypad/pkg/bridge/controlpb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrInvalidOrder    = errors.New("invalid order")
+	ErrOrderNotRunning = errors.New("order does not have a running job")
+)
+
+// The order number given to synthetic orders, which no order made on the
+// contract can have.
+const syntheticOrderNumber = -1
+
+// synthetic returns whether the order was submitted to the bridge directly
+// rather than made on the contract. Nothing about synthetic orders is ever
+// posted on-chain, as the contract doesn't know about them.
+func synthetic(e Event) bool {
+	order, ok := e.(ContractSubmittedEvent)
+	return ok && order.OrderNumber() == syntheticOrderNumber
+}
+
+// SubmitOrder adds an order to the workflow as if it had been made on the
+// smart contract, and returns it. Synthetic orders have no requestor, and
+// their results and errors are only saved rather than returned to the
+// contract, so they are meant for testing a deployment rather than for real
+// work.
+func (workflow *Workflow) SubmitOrder(ctx context.Context, spec []byte, resultType ResultType) (ContractSubmittedEvent, error) {
+	if !resultType.Valid() {
+		return nil, fmt.Errorf("%w: result type %d", ErrInvalidOrder, resultType)
+	}
+
+	var parsed model.Spec
+	if err := json.Unmarshal(spec, &parsed); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, err.Error())
+	}
+	if err := validateSpec(&parsed); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, err.Error())
+	}
+
+	// Pick an order ID that this bridge will work on itself.
+	orderID := make([]byte, common.HashLength)
+	for attempt := 0; ; attempt++ {
+		if _, err := rand.Read(orderID); err != nil {
+			return nil, err
+		}
+		if workflow.owns(common.BytesToHash(orderID)) {
+			break
+		} else if attempt >= 1000 {
+			return nil, ErrPartitionNotOwned
+		}
+	}
+
+	e := &event{
+		orderId:         orderID,
+		orderOwner:      common.Address{}.Bytes(),
+		orderNumber:     syntheticOrderNumber,
+		orderResultType: uint8(resultType),
+		state:           OrderStateSubmitted,
+		jobSpec:         spec,
+		timeline:        Timeline{Observed: now()},
+	}
+	if err := workflow.inject(ctx, e); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Msg("Submitted synthetic order")
+	return e, nil
+}
+
+// CancelOrder cancels the running job for the passed order, and fails the
+// order so that it is refunded. It returns ErrOrderNotRunning if the order
+// doesn't have a running job, and ErrPartitionNotOwned if the order is being
+// worked on by another bridge.
+func (workflow *Workflow) CancelOrder(ctx context.Context, orderID common.Hash) error {
+	if !workflow.owns(orderID) {
+		return ErrPartitionNotOwned
+	}
+
+	// Stop the job being found to have finished whilst it is being cancelled.
+	workflow.checkMu.Lock()
+	defer workflow.checkMu.Unlock()
+
+	jobs, err := Reload[BacalhauJobRunningEvent](workflow.Repo, OrderStateRunning)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.OrderId() != orderID {
+			continue
+		}
+
+		if err := workflow.Bacalhau.Cancel(ctx, job); err != nil {
+			return err
+		}
+
+		log.Ctx(ctx).Warn().Stringer("id", orderID).Str("job", job.JobID()).Msg("Cancelled order")
+		return workflow.inject(ctx, job.FailedWith(FailureReasonCancelled, "cancelled by operator"))
+	}
+	return ErrOrderNotRunning
+}
+
+// inject saves and publishes an event that didn't come from the contract, and
+// puts it on the queue to be processed. Orders owned by another bridge are
+// refused, as this bridge wouldn't work on them.
+func (workflow *Workflow) inject(ctx context.Context, e Event) error {
+	if !workflow.owns(e.OrderId()) {
+		return ErrPartitionNotOwned
+	} else if err := transitionError(e); err != nil {
+		return err
+	}
+
+	done := workflow.writeAhead(ctx, e)
+	if err := workflow.Repo.Save(e); err != nil {
+		return err
+	}
+	done()
+	workflow.Events.Publish(ctx, e)
+	workflow.auditEvent(ctx, e.OrderState(), e)
+
+	select {
+	case workflow.injected <- e:
+		return nil
+	default:
+		// The order has been saved, so it will be picked up on restart.
+		return fmt.Errorf("queue is full, order %s will be processed on restart", e.OrderId())
+	}
+}
+
+// ServeControl serves the gRPC control service on the passed address until the
+// context is cancelled, at which point the server is gracefully shut down.
+// Every call must carry the admin token, and an address without a host is
+// only served on the loopback interface.
+func ServeControl(ctx context.Context, addr, token string, workflow *Workflow, orders OrderStore) error {
+	if token == "" {
+		return ErrAdminTokenRequired
+	}
+	addr = loopbackDefault(addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(controlAuth(token)...)
+	controlpb.RegisterControlServer(server, NewControlServer(workflow, orders))
+
+	go func() {
+		<-ctx.Done()
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		// Streams watching orders never finish by themselves, so only wait
+		// so long for them.
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			server.Stop()
+		}
+	}()
+
+	log.Ctx(ctx).Info().Str("addr", addr).Msg("Serving gRPC")
+	return server.Serve(listener)
+}
+
+type controlServer struct {
+	controlpb.UnimplementedControlServer
+
+	workflow *Workflow
+	orders   OrderStore
+}
+
+// NewControlServer returns a gRPC service that lets other programs submit
+// synthetic orders to the workflow, look up and cancel orders, and watch
+// orders change state. If orders is nil, orders can't be looked up.
+func NewControlServer(workflow *Workflow, orders OrderStore) controlpb.ControlServer {
+	return &controlServer{workflow: workflow, orders: orders}
+}
+
+// SubmitOrder implements controlpb.ControlServer
+func (s *controlServer) SubmitOrder(ctx context.Context, req *controlpb.SubmitOrderRequest) (*controlpb.SubmitOrderResponse, error) {
+	if req.ResultType > uint32(ResultTypeExitCode) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid result type %d", req.ResultType)
+	}
+
+	e, err := s.workflow.SubmitOrder(ctx, req.Spec, ResultType(req.ResultType))
+	if errors.Is(err, ErrInvalidOrder) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &controlpb.SubmitOrderResponse{OrderId: e.OrderId().Hex()}, nil
+}
+
+// GetOrder implements controlpb.ControlServer
+func (s *controlServer) GetOrder(ctx context.Context, req *controlpb.GetOrderRequest) (*controlpb.Order, error) {
+	if s.orders == nil {
+		return nil, status.Error(codes.Unimplemented, "orders can't be looked up")
+	}
+
+	orderID, ok := parseOrderID(req.OrderId)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid order ID %q", req.OrderId)
+	}
+
+	order, err := s.orders.Order(ctx, orderID)
+	if errors.Is(err, ErrOrderNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return orderToProto(order), nil
+}
+
+// ListOrders implements controlpb.ControlServer
+func (s *controlServer) ListOrders(ctx context.Context, req *controlpb.ListOrdersRequest) (*controlpb.ListOrdersResponse, error) {
+	if s.orders == nil {
+		return nil, status.Error(codes.Unimplemented, "orders can't be looked up")
+	}
+
+	filter := OrderFilter{Limit: 100, Offset: uint(req.Offset)}
+	if req.Limit != 0 {
+		filter.Limit = uint(req.Limit)
+	}
+	if filter.Limit > maxOrdersLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxOrdersLimit)
+	}
+	if req.State != "" {
+		state, err := ParseOrderState(req.State)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter.State = &state
+	}
+
+	orders, err := s.orders.Orders(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &controlpb.ListOrdersResponse{Orders: make([]*controlpb.Order, 0, len(orders))}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, orderToProto(order))
+	}
+	return resp, nil
+}
+
+// CancelJob implements controlpb.ControlServer
+func (s *controlServer) CancelJob(ctx context.Context, req *controlpb.CancelJobRequest) (*controlpb.CancelJobResponse, error) {
+	orderID, ok := parseOrderID(req.OrderId)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid order ID %q", req.OrderId)
+	}
+
+	err := s.workflow.CancelOrder(ctx, orderID)
+	if errors.Is(err, ErrOrderNotRunning) || errors.Is(err, ErrPartitionNotOwned) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &controlpb.CancelJobResponse{}, nil
+}
+
+// WatchOrders implements controlpb.ControlServer
+func (s *controlServer) WatchOrders(req *controlpb.WatchOrdersRequest, stream controlpb.Control_WatchOrdersServer) error {
+	if s.workflow.Events == nil {
+		return status.Error(codes.Unavailable, "order changes are not being published")
+	}
+
+	states := map[string]bool{}
+	for _, name := range req.States {
+		state, err := ParseOrderState(name)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		states[state.String()] = true
+	}
+
+	notifications, unsubscribe := s.workflow.Events.Channel()
+	defer unsubscribe()
+
+	for {
+		select {
+		case n := <-notifications:
+			if len(states) > 0 && !states[n.State] {
+				continue
+			}
+			if err := stream.Send(notificationToProto(n)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+var _ controlpb.ControlServer = (*controlServer)(nil)
+
+func orderToProto(order Order) *controlpb.Order {
+	pb := &controlpb.Order{
+		Id:            order.ID,
+		State:         order.State,
+		Requestor:     order.Requestor,
+		OrderNumber:   order.OrderNumber,
+		Resubmissions: uint32(order.Resubmissions),
+		JobId:         order.JobID,
+		Endpoint:      order.Endpoint,
+		Results:       order.Results,
+		Error:         order.Error,
+		FailureReason: order.FailureReason,
+		UpdatedAt:     order.UpdatedAt.Format(time.RFC3339Nano),
+	}
+	for _, t := range order.Transitions {
+		pb.Transitions = append(pb.Transitions, &controlpb.Transition{
+			State: t.State,
+			Time:  t.Time.Format(time.RFC3339Nano),
+			JobId: t.JobID,
+		})
+	}
+	return pb
+}
+
+func notificationToProto(n Notification) *controlpb.OrderNotification {
+	return &controlpb.OrderNotification{
+		OrderId: n.OrderID,
+		State:   n.State,
+		Time:    n.Time.Format(time.RFC3339Nano),
+		JobId:   n.JobID,
+		Results: n.Results,
+		Error:   n.Error,
+		Reason:  n.Reason,
+	}
+}
@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/bacalhau-project/lilypad/pkg/bridge/controlpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestControlSubmitOrder(t *testing.T) {
+	repo := repository(t)
+	workflow := NewWorkflow(&mockRunner{}, mockContract{}, repo)
+	server := NewControlServer(workflow, repo.(OrderStore))
+	ctx := context.Background()
+
+	spec, err := json.Marshal(fastSpec)
+	require.NoError(t, err)
+
+	resp, err := server.SubmitOrder(ctx, &controlpb.SubmitOrderRequest{Spec: spec, ResultType: uint32(ResultTypeStdOut)})
+	require.NoError(t, err)
+
+	injected := <-workflow.injected
+	require.Equal(t, resp.OrderId, injected.OrderId().Hex())
+	require.Equal(t, OrderStateSubmitted, injected.OrderState())
+	require.True(t, synthetic(injected), "orders submitted to the bridge should never be settled on-chain")
+
+	order, err := server.GetOrder(ctx, &controlpb.GetOrderRequest{OrderId: resp.OrderId})
+	require.NoError(t, err)
+	require.Equal(t, "Submitted", order.State)
+	require.Len(t, order.Transitions, 1)
+
+	list, err := server.ListOrders(ctx, &controlpb.ListOrdersRequest{State: "submitted"})
+	require.NoError(t, err)
+	require.Len(t, list.Orders, 1)
+
+	_, err = server.SubmitOrder(ctx, &controlpb.SubmitOrderRequest{Spec: []byte("not json")})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.SubmitOrder(ctx, &controlpb.SubmitOrderRequest{Spec: spec, ResultType: 256})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.GetOrder(ctx, &controlpb.GetOrderRequest{OrderId: walEvent(2).OrderId().Hex()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = server.ListOrders(ctx, &controlpb.ListOrdersRequest{State: "Finished"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestControlCancelJob(t *testing.T) {
+	repo := repository(t)
+	cancelled := ""
+	runner := &mockRunner{CancelHandler: func(ctx context.Context, e BacalhauJobRunningEvent) error {
+		cancelled = e.JobID()
+		return nil
+	}}
+	workflow := NewWorkflow(runner, mockContract{}, repo)
+	server := NewControlServer(workflow, repo.(OrderStore))
+	ctx := context.Background()
+
+	job := model.NewJob()
+	job.Metadata.ID = "running-job"
+	e := walEvent(1).JobCreated(job)
+	require.NoError(t, repo.Save(e))
+
+	_, err := server.CancelJob(ctx, &controlpb.CancelJobRequest{OrderId: e.OrderId().Hex()})
+	require.NoError(t, err)
+	require.Equal(t, "running-job", cancelled)
+
+	injected := <-workflow.injected
+	require.Equal(t, OrderStateFailed, injected.OrderState())
+	require.Equal(t, FailureReasonCancelled, injected.(ContractFailedEvent).FailureReason())
+
+	_, err = server.CancelJob(ctx, &controlpb.CancelJobRequest{OrderId: e.OrderId().Hex()})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = server.CancelJob(ctx, &controlpb.CancelJobRequest{OrderId: "not-an-id"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
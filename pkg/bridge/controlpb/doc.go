@@ -0,0 +1,4 @@
+// Package controlpb holds the protobuf messages and gRPC service used to
+// control the bridge from other programs. The Go files are generated from
+// control.proto by running `make proto`.
+package controlpb
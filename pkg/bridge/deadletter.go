@@ -0,0 +1,167 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// A DeadLetterStatus describes what has been done about a dead-lettered order.
+//
+//go:generate stringer -type=DeadLetterStatus --trimprefix=DeadLetterStatus
+type DeadLetterStatus int
+
+const (
+	// The order is waiting for someone to decide what to do with it.
+	DeadLetterStatusQueued DeadLetterStatus = iota
+	// The order has been put back into the workflow.
+	DeadLetterStatusRequeued
+	// The order has been given up on for good.
+	DeadLetterStatusRejected
+)
+
+// A DeadLetter is an order that the workflow has given up on, because even the
+// last resort of refunding it has repeatedly failed.
+type DeadLetter struct {
+	OrderID  string           `json:"orderId"`
+	State    string           `json:"state"`
+	Attempts uint             `json:"attempts"`
+	Error    string           `json:"error"`
+	Status   DeadLetterStatus `json:"status"`
+	Time     time.Time        `json:"time"`
+
+	// The order as it was when it was given up on, encoded by MarshalEvent.
+	Event json.RawMessage `json:"event"`
+}
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrDeadLetterResolved = errors.New("dead letter has already been requeued or rejected")
+)
+
+// A DeadLetterQueue keeps orders that the workflow could not process, so that
+// they can be looked at and dealt with by hand.
+type DeadLetterQueue interface {
+	// AddDeadLetter saves the dead letter, replacing any for the same order.
+	AddDeadLetter(ctx context.Context, d DeadLetter) error
+
+	// DeadLetter returns the dead letter for the passed order, or
+	// ErrDeadLetterNotFound.
+	DeadLetter(ctx context.Context, orderID string) (DeadLetter, error)
+
+	// DeadLetters returns every dead letter with the passed status.
+	DeadLetters(ctx context.Context, status DeadLetterStatus) ([]DeadLetter, error)
+
+	SetDeadLetterStatus(ctx context.Context, orderID string, status DeadLetterStatus) error
+}
+
+// retryOrDeadLetter schedules the event to be tried again if it has attempts
+// left and the error could be retried, and otherwise moves it to the
+// dead-letter queue.
+func (workflow *Workflow) retryOrDeadLetter(ctx context.Context, e Retryable, err error) (Event, time.Duration) {
+	if bridgeerrors.Classify(err) == bridgeerrors.Retryable && ShouldRetry(e) {
+		e.AddAttempt()
+		return e, workflow.getRetryTime(e)
+	}
+
+	data, marshalErr := MarshalEvent(e)
+	if marshalErr != nil {
+		log.Ctx(ctx).Error().Err(marshalErr).Msg("Unable to encode dead letter")
+		return nil, 0
+	}
+
+	addErr := workflow.DeadLetters.AddDeadLetter(ctx, DeadLetter{
+		OrderID:  e.OrderId().Hex(),
+		State:    e.OrderState().String(),
+		Attempts: e.Attempts(),
+		Error:    err.Error(),
+		Status:   DeadLetterStatusQueued,
+		Time:     time.Now().UTC(),
+		Event:    data,
+	})
+	if addErr == nil {
+		ordersDeadLettered.Inc()
+	}
+	log.Ctx(ctx).WithLevel(level(addErr)).Err(addErr).Str("error", err.Error()).Msg("Moving order to dead-letter queue")
+	return nil, 0
+}
+
+// deadLettered returns whether the passed event belongs to an order that is
+// waiting in the dead-letter queue or has been rejected, and so shouldn't be
+// processed.
+func (workflow *Workflow) deadLettered(ctx context.Context, e Event) bool {
+	if workflow.DeadLetters == nil {
+		return false
+	}
+
+	d, err := workflow.DeadLetters.DeadLetter(ctx, e.OrderId().Hex())
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		return false
+	} else if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to check dead-letter queue")
+		return false
+	}
+	return d.Status != DeadLetterStatusRequeued
+}
+
+// Requeue puts the dead-lettered order back into the workflow, in the state it
+// was given up in and with its attempts reset.
+func (workflow *Workflow) Requeue(ctx context.Context, orderID string) error {
+	d, err := workflow.queuedDeadLetter(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	e, err := UnmarshalEvent(d.Event)
+	if err != nil {
+		return err
+	}
+	if retryable, ok := e.(Retryable); ok {
+		retryable.ResetAttempts()
+	}
+
+	if err = workflow.DeadLetters.SetDeadLetterStatus(ctx, orderID, DeadLetterStatusRequeued); err != nil {
+		return err
+	}
+	if err = workflow.Repo.Save(e); err != nil {
+		return err
+	}
+
+	select {
+	case workflow.injected <- e:
+		log.Ctx(ctx).Info().Str("id", orderID).Stringer("state", e.OrderState()).Msg("Requeued dead letter")
+		return nil
+	default:
+		// The order has been saved, so it will be picked up on restart.
+		return fmt.Errorf("requeue queue is full, order %s will be processed on restart", orderID)
+	}
+}
+
+// Reject permanently gives up on the dead-lettered order.
+func (workflow *Workflow) Reject(ctx context.Context, orderID string) error {
+	if _, err := workflow.queuedDeadLetter(ctx, orderID); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Warn().Str("id", orderID).Msg("Rejected dead letter")
+	return workflow.DeadLetters.SetDeadLetterStatus(ctx, orderID, DeadLetterStatusRejected)
+}
+
+func (workflow *Workflow) queuedDeadLetter(ctx context.Context, orderID string) (DeadLetter, error) {
+	if workflow.DeadLetters == nil {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+
+	d, err := workflow.DeadLetters.DeadLetter(ctx, orderID)
+	if err != nil {
+		return d, err
+	} else if d.Status != DeadLetterStatusQueued {
+		return d, ErrDeadLetterResolved
+	}
+	return d, nil
+}
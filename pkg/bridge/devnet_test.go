@@ -0,0 +1,319 @@
+//go:build integration
+
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	LilypadCallerRecorder "github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadCallerRecorder.sol"
+	LilypadEventsUpgradeable "github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadEventsUpgradeable.sol"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+)
+
+// These tests run the bridge against a real chain and Bacalhau network on this
+// machine, and are only built with
+//
+//	go test -tags=integration ./pkg/bridge -run Devnet
+//
+// By default they start anvil for the chain and a Bacalhau devstack for the
+// jobs, which need anvil, bacalhau and Docker. The contract is deployed with
+// the hardhat deploy script, so hardhat/node_modules must be installed. An
+// existing chain such as geth --dev can be used instead by setting
+// DEVNET_RPC_URL and DEVNET_PRIVATE_KEY, and an existing Bacalhau network by
+// setting DEVNET_BACALHAU_ENDPOINT.
+
+// The first of the accounts that anvil funds.
+const anvilPrivateKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+// What the contract charges for each job when it is deployed.
+var devnetFee = ether(0.03)
+
+// A devnet is a local chain with the Lilypad contract deployed on it, and a
+// Bacalhau network to run jobs on.
+type devnet struct {
+	rpc      string
+	chainID  *big.Int
+	key      *ecdsa.PrivateKey
+	client   *ethclient.Client
+	contract common.Address
+	bacalhau string
+}
+
+func startDevnet(t *testing.T) *devnet {
+	d := &devnet{rpc: os.Getenv("DEVNET_RPC_URL")}
+	privateKey := strings.TrimPrefix(os.Getenv("DEVNET_PRIVATE_KEY"), "0x")
+	if d.rpc == "" {
+		requireCommand(t, "anvil")
+		port := freePort(t)
+		startProcess(t, "anvil", "--port", port, "--silent")
+		d.rpc = "http://127.0.0.1:" + port
+		privateKey = anvilPrivateKey
+	} else if privateKey == "" {
+		t.Fatal("DEVNET_PRIVATE_KEY must be a funded key when DEVNET_RPC_URL is set")
+	}
+
+	var err error
+	d.key, err = crypto.HexToECDSA(privateKey)
+	require.NoError(t, err)
+	d.client = waitForChain(t, d.rpc)
+	d.chainID, err = d.client.ChainID(context.Background())
+	require.NoError(t, err)
+	d.contract = deployContract(t, d.rpc, privateKey)
+
+	d.bacalhau = os.Getenv("DEVNET_BACALHAU_ENDPOINT")
+	if d.bacalhau == "" {
+		d.bacalhau = startDevstack(t)
+	}
+	return d
+}
+
+// requireCommand skips the test if the command isn't installed.
+func requireCommand(t *testing.T, name string) {
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is needed to run the devnet", name)
+	}
+}
+
+func freePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// startProcess runs the command until the test ends, returning what it prints
+// line by line. Lines that aren't read are dropped rather than blocking the
+// process.
+func startProcess(t *testing.T, name string, args ...string) <-chan string {
+	output, writer := io.Pipe()
+	cmd := exec.Command(name, args...)
+	cmd.Stdout, cmd.Stderr = writer, writer
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+		writer.Close()
+	})
+
+	lines := make(chan string, 1000)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			default:
+			}
+		}
+	}()
+	return lines
+}
+
+// waitForLine returns the submatches of the first line that matches the
+// pattern.
+func waitForLine(t *testing.T, lines <-chan string, pattern *regexp.Regexp, timeout time.Duration) []string {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case line, ok := <-lines:
+			require.True(t, ok, "process exited before printing %s", pattern)
+			if match := pattern.FindStringSubmatch(line); match != nil {
+				return match
+			}
+		case <-deadline:
+			t.Fatalf("nothing matching %s was printed within %s", pattern, timeout)
+		}
+	}
+}
+
+func waitForChain(t *testing.T, rpc string) *ethclient.Client {
+	var client *ethclient.Client
+	require.Eventually(t, func() bool {
+		var err error
+		if client == nil {
+			client, err = ethclient.Dial(rpc)
+		}
+		if err == nil {
+			_, err = client.BlockNumber(context.Background())
+		}
+		return err == nil
+	}, 30*time.Second, 100*time.Millisecond, "chain did not start")
+	return client
+}
+
+var deployedAddress = regexp.MustCompile(`deployed to\s+(0x[0-9a-fA-F]{40})`)
+
+// deployContract deploys the Lilypad contract behind its proxy, as it is on
+// the real chains.
+func deployContract(t *testing.T, rpc, privateKey string) common.Address {
+	hardhat := filepath.Join("..", "..", "hardhat")
+	if _, err := os.Stat(filepath.Join(hardhat, "node_modules")); err != nil {
+		t.Skip("hardhat/node_modules is needed to deploy the contract")
+	}
+
+	cmd := exec.Command("npx", "hardhat", "run", "scripts/deployUpgradeable.ts", "--network", "devnet")
+	cmd.Dir = hardhat
+	cmd.Env = append(os.Environ(), "DEVNET_RPC_URL="+rpc, "WALLET_PRIVATE_KEY="+privateKey)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	match := deployedAddress.FindSubmatch(output)
+	require.NotNil(t, match, "no contract address in %s", output)
+	return common.HexToAddress(string(match[1]))
+}
+
+var devstackPort = regexp.MustCompile(`BACALHAU_API_PORT=(\d+)`)
+
+// startDevstack starts a Bacalhau devstack, returning its API endpoint.
+func startDevstack(t *testing.T) string {
+	requireCommand(t, "bacalhau")
+	requireCommand(t, "docker")
+	lines := startProcess(t, "bacalhau", "devstack")
+	port := waitForLine(t, lines, devstackPort, 2*time.Minute)[1]
+	return "http://127.0.0.1:" + port
+}
+
+// order pays for the caller to order a job, returning the order's
+// transaction and job number.
+func (d *devnet) order(t *testing.T, caller *LilypadCallerRecorder.LilypadCallerRecorder, spec model.Spec, resultType ResultType) (common.Hash, *big.Int) {
+	ctx := context.Background()
+	specJSON, err := json.Marshal(spec)
+	require.NoError(t, err)
+
+	auth, err := bind.NewKeyedTransactorWithChainID(d.key, d.chainID)
+	require.NoError(t, err)
+	auth.Value = devnetFee
+	tx, err := caller.Run(auth, string(specJSON), uint8(resultType))
+	require.NoError(t, err)
+	receipt, err := bind.WaitMined(ctx, d.client, tx)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	events, err := LilypadEventsUpgradeable.NewLilypadEventsUpgradeableFilterer(d.contract, d.client)
+	require.NoError(t, err)
+	for _, entry := range receipt.Logs {
+		if submitted, err := events.ParseNewLilypadJobSubmitted(*entry); err == nil {
+			return tx.Hash(), submitted.Job.Id
+		}
+	}
+	t.Fatal("order did not emit NewLilypadJobSubmitted")
+	return common.Hash{}, nil
+}
+
+func TestDevnetOrderLifecycle(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	auth, err := bind.NewKeyedTransactorWithChainID(d.key, d.chainID)
+	require.NoError(t, err)
+	_, tx, caller, err := LilypadCallerRecorder.DeployLilypadCallerRecorder(auth, d.client, d.contract)
+	require.NoError(t, err)
+	_, err = bind.WaitDeployed(ctx, d.client, tx)
+	require.NoError(t, err)
+
+	t.Setenv("RPC_ENDPOINT", d.rpc)
+	t.Setenv("CHAIN_ID", d.chainID.String())
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	contract, err := NewContract(d.contract, NewPrivateKeySigner(d.key))
+	require.NoError(t, err)
+	runner, err := NewJobRunner(WithEndpoints(d.bacalhau))
+	require.NoError(t, err)
+	repo := repository(t)
+
+	workflowCtx, stop := context.WithCancel(ctx)
+	stopped := make(chan error, 1)
+	go func() { stopped <- NewWorkflow(runner, contract, repo).Start(workflowCtx) }()
+	defer func() {
+		stop()
+		<-stopped
+	}()
+
+	spec := fastSpec
+	spec.Publisher = model.PublisherIpfs
+	spec.Docker.Entrypoint = []string{"echo", "hello from the devnet"}
+	orderID, jobNumber := d.order(t, caller, spec, ResultTypeStdOut)
+
+	var result string
+	require.Eventually(t, func() bool {
+		result, err = caller.Results(&bind.CallOpts{Context: ctx}, jobNumber)
+		return err == nil && result != ""
+	}, 5*time.Minute, time.Second, "result was not returned on-chain")
+	require.Equal(t, "hello from the devnet", strings.TrimSpace(result))
+
+	// The result is returned before the order is saved as paid.
+	require.Eventually(t, func() bool {
+		order, err := repo.(OrderStore).Order(ctx, orderID)
+		return err == nil && order.State == OrderStatePaid.String()
+	}, 10*time.Second, 100*time.Millisecond, "order was not saved as paid")
+}
+
+func TestDevnetFailedOrdersAreRefunded(t *testing.T) {
+	d := startDevnet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	auth, err := bind.NewKeyedTransactorWithChainID(d.key, d.chainID)
+	require.NoError(t, err)
+	_, tx, caller, err := LilypadCallerRecorder.DeployLilypadCallerRecorder(auth, d.client, d.contract)
+	require.NoError(t, err)
+	_, err = bind.WaitDeployed(ctx, d.client, tx)
+	require.NoError(t, err)
+
+	t.Setenv("RPC_ENDPOINT", d.rpc)
+	t.Setenv("CHAIN_ID", d.chainID.String())
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	contract, err := NewContract(d.contract, NewPrivateKeySigner(d.key))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, contract.(*realContract).version, ContractVersion3)
+	runner, err := NewJobRunner(WithEndpoints(d.bacalhau))
+	require.NoError(t, err)
+	repo := repository(t)
+
+	workflowCtx, stop := context.WithCancel(ctx)
+	stopped := make(chan error, 1)
+	go func() { stopped <- NewWorkflow(runner, contract, repo).Start(workflowCtx) }()
+	defer func() {
+		stop()
+		<-stopped
+	}()
+
+	// The spec is rejected, so the order fails without running. The caller
+	// can't be paid back, so the contract refuses the refund and only the
+	// error is returned.
+	spec := fastSpec
+	spec.Docker.Image = ""
+	orderID, jobNumber := d.order(t, caller, spec, ResultTypeStdOut)
+
+	var message string
+	require.Eventually(t, func() bool {
+		message, err = caller.Errors(&bind.CallOpts{Context: ctx}, jobNumber)
+		return err == nil && message != ""
+	}, 5*time.Minute, time.Second, "error was not returned on-chain")
+	require.Contains(t, message, "Docker jobs must specify an image")
+
+	require.Eventually(t, func() bool {
+		order, err := repo.(OrderStore).Order(ctx, orderID)
+		return err == nil && order.State == OrderStateRefunded.String()
+	}, 10*time.Second, 100*time.Millisecond, "order was not saved as refunded")
+}
@@ -0,0 +1,118 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The name of the runner that checks jobs without ever submitting them.
+const DryRunRunner string = "dry-run"
+
+func init() {
+	RegisterRunner(DryRunRunner, NewDryRunRunner)
+}
+
+type dryRunRunner struct {
+	policy   Policy
+	policyMu sync.RWMutex
+}
+
+// NewDryRunRunner returns a JobRunner that validates and logs the jobs it is
+// asked to create, and checks them against the policy from the environment,
+// but never submits anything. Valid jobs stay in the submitted state.
+func NewDryRunRunner() (JobRunner, error) {
+	policy, err := policyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunRunner{policy: policy}, nil
+}
+
+// Create implements JobRunner
+func (r *dryRunRunner) Create(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+	spec, err := e.Spec()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateSpec(&spec); err != nil {
+		return nil, err
+	}
+
+	r.policyMu.RLock()
+	policy := r.policy
+	r.policyMu.RUnlock()
+
+	if err = policy.Check(spec); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().
+		Stringer("id", e.OrderId()).
+		Stringer("engine", spec.Engine).
+		Str("image", spec.Docker.Image).
+		Strs("entrypoint", spec.Docker.Entrypoint).
+		Str("cpu", spec.Resources.CPU).
+		Str("memory", spec.Resources.Memory).
+		Str("gpu", spec.Resources.GPU).
+		Msg("Dry run: would have submitted Bacalhau job")
+	return nil, nil
+}
+
+// FindCompleted implements JobRunner
+func (r *dryRunRunner) FindCompleted(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+	return nil, nil
+}
+
+// Cancel implements JobRunner
+func (r *dryRunRunner) Cancel(ctx context.Context, e BacalhauJobRunningEvent) error {
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Str("job", e.JobID()).Msg("Dry run: would have cancelled Bacalhau job")
+	return nil
+}
+
+var _ JobRunner = (*dryRunRunner)(nil)
+
+type dryRunContract struct {
+	SmartContract
+}
+
+// NewDryRunContract returns a SmartContract that listens for events using the
+// passed contract, but only logs the transactions it would have sent.
+func NewDryRunContract(contract SmartContract) SmartContract {
+	return &dryRunContract{SmartContract: contract}
+}
+
+// Complete implements SmartContract
+func (c *dryRunContract) Complete(ctx context.Context, e BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Stringer("result", e.Result(ctx)).Msg("Dry run: would have returned results")
+	return e.Paid(), nil
+}
+
+// CompleteBatch implements BatchCompleter
+func (c *dryRunContract) CompleteBatch(ctx context.Context, events []BacalhauJobCompletedEvent) ([]ContractPaidEvent, error) {
+	paid := make([]ContractPaidEvent, len(events))
+	for i, e := range events {
+		log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Stringer("result", e.Result(ctx)).Msg("Dry run: would have returned results in a batch")
+		paid[i] = e.Paid()
+	}
+	return paid, nil
+}
+
+// Refund implements SmartContract
+func (c *dryRunContract) Refund(ctx context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Str("error", e.Error()).Msg("Dry run: would have returned error")
+	return e.Refunded(), nil
+}
+
+// Acknowledge implements OrderAcknowledger
+func (c *dryRunContract) Acknowledge(e ContractSubmittedEvent) {
+	if acknowledger, ok := c.SmartContract.(OrderAcknowledger); ok {
+		acknowledger.Acknowledge(e)
+	}
+}
+
+var _ SmartContract = (*dryRunContract)(nil)
+var _ BatchCompleter = (*dryRunContract)(nil)
+var _ OrderAcknowledger = (*dryRunContract)(nil)
@@ -0,0 +1,47 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunDoesNotSubmit(t *testing.T) {
+	runner, err := NewRunner(DryRunRunner)
+	require.NoError(t, err)
+
+	running, err := runner.Create(context.Background(), exampleEvent())
+	require.NoError(t, err)
+	require.Nil(t, running)
+
+	spec := fastSpec
+	spec.Docker.Image = ""
+	invalid := exampleEvent().(*event)
+	invalid.jobSpec, err = json.Marshal(spec)
+	require.NoError(t, err)
+
+	_, err = runner.Create(context.Background(), invalid)
+	require.Error(t, err)
+}
+
+func TestDryRunDoesNotTransact(t *testing.T) {
+	fail := errors.New("should not have sent a transaction")
+	contract := NewDryRunContract(&mockContract{
+		CompleteHandler: func(context.Context, BacalhauJobCompletedEvent) (ContractPaidEvent, error) { return nil, fail },
+		RefundHandler:   func(context.Context, ContractFailedEvent) (ContractRefundedEvent, error) { return nil, fail },
+	})
+
+	running := exampleEvent().JobCreated(model.NewJob())
+	paid, err := contract.Complete(context.Background(), running.Completed(cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"), "", "", 0))
+	require.NoError(t, err)
+	require.Equal(t, OrderStatePaid, paid.OrderState())
+
+	refunded, err := contract.Refund(context.Background(), exampleEvent().Failed("failed"))
+	require.NoError(t, err)
+	require.Equal(t, OrderStateRefunded, refunded.OrderState())
+}
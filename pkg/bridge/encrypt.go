@@ -0,0 +1,83 @@
+package bridge
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// An Encrypter hides data that the bridge needs to publish on the Bacalhau
+// network but doesn't want anyone else to be able to read, such as the order
+// ID that a job is being run for.
+type Encrypter interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+type aesEncrypter struct {
+	aead cipher.AEAD
+	key  []byte
+}
+
+// NewAESEncrypter returns an Encrypter that uses AES-GCM with the passed key,
+// which must be 16, 24 or 32 bytes long.
+//
+// The nonce is derived from the plaintext rather than chosen at random, so the
+// same plaintext always encrypts to the same ciphertext. This leaks whether two
+// annotations are equal, but means that we can still search for the jobs we
+// have submitted by their encrypted annotation.
+func NewAESEncrypter(key []byte) (Encrypter, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesEncrypter{aead: aead, key: key}, nil
+}
+
+// Encrypt implements Encrypter
+func (enc *aesEncrypter) Encrypt(plaintext []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, enc.key)
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:enc.aead.NonceSize()]
+	return enc.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt implements Encrypter
+func (enc *aesEncrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	size := enc.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return enc.aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+}
+
+var _ Encrypter = (*aesEncrypter)(nil)
+
+// encrypterFromEnv returns an AES encrypter using the hex-encoded key in
+// ANNOTATION_ENCRYPTION_KEY, or nil if it isn't set.
+func encrypterFromEnv() (Encrypter, error) {
+	keyStr, found := os.LookupEnv("ANNOTATION_ENCRYPTION_KEY")
+	if !found || keyStr == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(keyStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "ANNOTATION_ENCRYPTION_KEY")
+	}
+
+	enc, err := NewAESEncrypter(key)
+	return enc, errors.Wrap(err, "ANNOTATION_ENCRYPTION_KEY")
+}
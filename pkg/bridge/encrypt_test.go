@@ -0,0 +1,75 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A key for tests that make runners through NewJobRunner, which won't start
+// without one.
+const testEncryptionKey = "000102030405060708090a0b0c0d0e0f"
+
+type plaintextEncrypter struct{}
+
+// Encrypt implements Encrypter
+func (plaintextEncrypter) Encrypt(plaintext []byte) ([]byte, error) {
+	return plaintext, nil
+}
+
+// Decrypt implements Encrypter
+func (plaintextEncrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	return ciphertext, nil
+}
+
+var _ Encrypter = plaintextEncrypter{}
+
+func TestAESEncrypterRoundTrip(t *testing.T) {
+	enc, err := NewAESEncrypter([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	plaintext := []byte("order-id")
+	ciphertext, err := enc.Encrypt(plaintext)
+	require.NoError(t, err)
+	require.NotContains(t, string(ciphertext), string(plaintext))
+
+	again, err := enc.Encrypt(plaintext)
+	require.NoError(t, err)
+	require.Equal(t, ciphertext, again, "encryption should be deterministic")
+
+	decrypted, err := enc.Decrypt(ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+}
+
+func TestAESEncrypterRejectsTampering(t *testing.T) {
+	enc, err := NewAESEncrypter([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	ciphertext, err := enc.Encrypt([]byte("order-id"))
+	require.NoError(t, err)
+
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	_, err = enc.Decrypt(ciphertext)
+	require.Error(t, err)
+}
+
+func TestInvalidAESKey(t *testing.T) {
+	_, err := NewAESEncrypter([]byte("too short"))
+	require.Error(t, err)
+}
+
+func TestRunnerNeedsEncryptionKey(t *testing.T) {
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", "")
+	_, err := NewJobRunner()
+	require.ErrorContains(t, err, "ANNOTATION_ENCRYPTION_KEY")
+
+	enc, err := NewAESEncrypter([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	_, err = NewJobRunner(WithEncrypter(enc))
+	require.NoError(t, err)
+
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+	_, err = NewJobRunner()
+	require.NoError(t, err)
+}
@@ -0,0 +1,146 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bacalhau-project/bacalhau/pkg/requester/publicapi"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// An EndpointSelection decides which of several Bacalhau clusters a new job is
+// submitted to.
+type EndpointSelection string
+
+const (
+	// Submit to the first healthy endpoint in the order they were given.
+	EndpointSelectionPriority EndpointSelection = "priority"
+
+	// Spread submissions evenly across all of the healthy endpoints.
+	EndpointSelectionRoundRobin EndpointSelection = "round-robin"
+)
+
+// An endpoint is the requester API of one Bacalhau cluster.
+type endpoint struct {
+	URL    string
+	Client *publicapi.RequesterAPIClient
+
+	breaker *CircuitBreaker
+}
+
+// newEndpoint returns an endpoint for the requester API at the passed URL,
+// which must be of the form scheme://host[:port].
+func newEndpoint(rawURL string, client *http.Client, breaker *CircuitBreaker) (*endpoint, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Bacalhau API endpoint")
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported Bacalhau API scheme %q", u.Scheme)
+	}
+
+	portStr := u.Port()
+	if portStr == "" && u.Scheme == "https" {
+		portStr = "443"
+	} else if portStr == "" {
+		portStr = "80"
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Bacalhau API port")
+	}
+
+	api := publicapi.NewRequesterAPIClient(u.Hostname(), uint16(port))
+	api.BaseURI = fmt.Sprintf("%s://%s", u.Scheme, net.JoinHostPort(u.Hostname(), portStr))
+	api.Client = client
+	return &endpoint{URL: api.BaseURI, Client: api, breaker: breaker}, nil
+}
+
+// call makes a request to the endpoint through its circuit breaker, recording
+// the request and any change in the state of the breaker.
+func (ep *endpoint) call(ctx context.Context, name string, fn func() error) error {
+	// Don't start requests that can't finish in time, nor count them against
+	// the endpoint.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	before := ep.breaker.State()
+	err := ep.breaker.Do(fn)
+	if !errors.Is(err, ErrCircuitOpen) {
+		observeAPICall(name, err)
+	}
+	// A request cut short by the caller's own deadline says nothing about
+	// whether the endpoint can be reached.
+	if unreachable(err) && ctx.Err() == nil {
+		err = &bridgeerrors.BacalhauError{Endpoint: ep.URL, Err: err}
+	}
+
+	after := ep.breaker.State()
+	bacalhauCircuitState.WithLabelValues(ep.URL).Set(float64(after))
+	if after != before {
+		log.Ctx(ctx).Warn().Err(err).Str("endpoint", ep.URL).Stringer("state", after).Msg("Bacalhau circuit breaker changed state")
+	}
+	return err
+}
+
+// unreachable returns whether the error from a request means that the endpoint
+// couldn't be reached, rather than that it refused the request.
+func unreachable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// healthy returns whether requests are currently being let through.
+func (ep *endpoint) healthy() bool {
+	return ep.breaker.State() != BreakerStateOpen
+}
+
+// candidates returns the healthy endpoints in the order that submissions
+// should try them.
+func (r *bacalhauRunner) candidates() []*endpoint {
+	start := 0
+	if r.selection == EndpointSelectionRoundRobin && len(r.endpoints) > 0 {
+		start = int(atomic.AddUint32(&r.nextEndpoint, 1)-1) % len(r.endpoints)
+	}
+
+	candidates := make([]*endpoint, 0, len(r.endpoints))
+	for i := range r.endpoints {
+		ep := r.endpoints[(start+i)%len(r.endpoints)]
+		if ep.healthy() {
+			candidates = append(candidates, ep)
+		}
+	}
+	return candidates
+}
+
+// endpointFor returns the endpoint that the passed job was submitted to. Jobs
+// submitted before endpoints were recorded are assumed to be on the first.
+func (r *bacalhauRunner) endpointFor(j BacalhauJobRunningEvent) *endpoint {
+	for _, ep := range r.endpoints {
+		if ep.URL == j.Endpoint() {
+			return ep
+		}
+	}
+	return r.endpoints[0]
+}
+
+// parseEndpoints splits a comma separated list of endpoint URLs.
+func parseEndpoints(str string) []string {
+	endpoints := []string{}
+	for _, endpoint := range strings.Split(str, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
@@ -0,0 +1,118 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func testEndpoints(t *testing.T, selection EndpointSelection, urls ...string) *bacalhauRunner {
+	runner := &bacalhauRunner{selection: selection}
+	for _, url := range urls {
+		ep, err := newEndpoint(url, http.DefaultClient, NewCircuitBreaker(1, time.Hour))
+		require.NoError(t, err)
+		runner.endpoints = append(runner.endpoints, ep)
+	}
+	return runner
+}
+
+func urls(endpoints []*endpoint) []string {
+	urls := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		urls = append(urls, ep.URL)
+	}
+	return urls
+}
+
+func TestEndpointURLs(t *testing.T) {
+	runner := testEndpoints(t, EndpointSelectionPriority, "http://a", "https://b", "http://c:1234")
+	require.Equal(t, []string{"http://a:80", "https://b:443", "http://c:1234"}, urls(runner.endpoints))
+
+	_, err := newEndpoint("ftp://a", http.DefaultClient, nil)
+	require.Error(t, err)
+}
+
+func TestPriorityEndpointSelection(t *testing.T) {
+	runner := testEndpoints(t, EndpointSelectionPriority, "http://a:1", "http://b:1")
+	require.Equal(t, []string{"http://a:1", "http://b:1"}, urls(runner.candidates()))
+	require.Equal(t, []string{"http://a:1", "http://b:1"}, urls(runner.candidates()))
+
+	// Failing endpoints are skipped until they recover.
+	_ = runner.endpoints[0].breaker.Do(func() error { return errors.New("down") })
+	require.Equal(t, []string{"http://b:1"}, urls(runner.candidates()))
+	require.NoError(t, runner.Health(context.Background()))
+
+	_ = runner.endpoints[1].breaker.Do(func() error { return errors.New("down") })
+	require.Empty(t, runner.candidates())
+	require.Error(t, runner.Health(context.Background()))
+}
+
+func TestRoundRobinEndpointSelection(t *testing.T) {
+	runner := testEndpoints(t, EndpointSelectionRoundRobin, "http://a:1", "http://b:1")
+	require.Equal(t, []string{"http://a:1", "http://b:1"}, urls(runner.candidates()))
+	require.Equal(t, []string{"http://b:1", "http://a:1"}, urls(runner.candidates()))
+	require.Equal(t, []string{"http://a:1", "http://b:1"}, urls(runner.candidates()))
+}
+
+func TestJobsAreCheckedOnTheirEndpoint(t *testing.T) {
+	runner := testEndpoints(t, EndpointSelectionPriority, "http://a:1", "http://b:1")
+	e := exampleEvent().JobCreated(model.NewJob())
+	require.Equal(t, runner.endpoints[0], runner.endpointFor(e))
+	require.Equal(t, runner.endpoints[1], runner.endpointFor(e.WithEndpoint("http://b:1")))
+}
+
+func TestEndpointsFromEnvironment(t *testing.T) {
+	t.Setenv("BACALHAU_API_ENDPOINTS", "http://a:1, https://b:2")
+	t.Setenv("BACALHAU_ENDPOINT_SELECTION", "round-robin")
+	t.Setenv("ANNOTATION_ENCRYPTION_KEY", testEncryptionKey)
+
+	runner, err := NewJobRunner()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://a:1", "https://b:2"}, urls(runner.(*bacalhauRunner).endpoints))
+	require.Equal(t, EndpointSelectionRoundRobin, runner.(*bacalhauRunner).selection)
+
+	t.Setenv("BACALHAU_ENDPOINT_SELECTION", "random")
+	_, err = NewJobRunner()
+	require.Error(t, err)
+}
+
+func TestUnreachableEndpointsAreUnavailable(t *testing.T) {
+	ctx := context.Background()
+	endpoints := testEndpoints(t, EndpointSelectionPriority, "http://localhost:1234", "http://localhost:1235", "http://localhost:1236").endpoints
+
+	err := endpoints[0].call(ctx, "test", func() error {
+		return errors.New("job spec rejected by the requester")
+	})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, bridgeerrors.ErrBacalhauUnavailable)
+
+	err = endpoints[1].call(ctx, "test", func() error {
+		return &net.DNSError{Err: "no such host", Name: "localhost"}
+	})
+	require.ErrorIs(t, err, bridgeerrors.ErrBacalhauUnavailable)
+
+	err = endpoints[2].call(ctx, "test", func() error {
+		return context.DeadlineExceeded
+	})
+	require.ErrorIs(t, err, bridgeerrors.ErrBacalhauUnavailable, "the endpoint's own request timing out")
+}
+
+func TestCallerDeadlinesAreNotUnavailability(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	endpoint := testEndpoints(t, EndpointSelectionPriority, "http://localhost:1234").endpoints[0]
+
+	err := endpoint.call(ctx, "test", func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotErrorIs(t, err, bridgeerrors.ErrBacalhauUnavailable)
+}
@@ -0,0 +1,63 @@
+package errors
+
+import (
+	"context"
+	"errors"
+)
+
+// A Class says whether trying again could succeed where an error failed.
+type Class string
+
+const (
+	// Trying again may succeed, such as after Bacalhau couldn't be reached
+	// for a moment.
+	Retryable Class = "retryable"
+
+	// Trying again will fail in the same way, such as for a job spec that
+	// can't be run, so the order should fail straight away.
+	Terminal Class = "terminal"
+)
+
+// The kinds of error that are always terminal. Any kind not listed here,
+// including ErrBacalhauUnavailable, is retryable. ErrOrderExpired isn't
+// listed, as jobs that run out of time fail with it rather than return it.
+var terminalKinds = []error{
+	ErrSpecInvalid,
+	ErrOrderRejected,
+	ErrTxReverted,
+}
+
+// Classify returns whether the error is worth retrying. Errors of the terminal
+// kinds and errors marked with MarkTerminal are terminal. Any other error is
+// retryable, as an error the bridge doesn't know about could be down to
+// something that will pass.
+func Classify(err error) Class {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return Retryable
+	}
+	var terminal *terminalError
+	if errors.As(err, &terminal) {
+		return Terminal
+	}
+	for _, kind := range terminalKinds {
+		if errors.Is(err, kind) {
+			return Terminal
+		}
+	}
+	return Retryable
+}
+
+// MarkTerminal returns an error that is classified as terminal, but otherwise
+// reads and matches just like the passed error. It is meant for errors that
+// aren't one of the kinds here but that trying again can't fix, such as an
+// order made on a contract the bridge doesn't watch.
+func MarkTerminal(err error) error {
+	return &terminalError{err}
+}
+
+type terminalError struct {
+	err error
+}
+
+func (e *terminalError) Error() string { return e.err.Error() }
+func (e *terminalError) Unwrap() error { return e.err }
@@ -0,0 +1,35 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	notWatched := MarkTerminal(errors.New("order was made on a contract the bridge isn't watching"))
+	testCases := map[error]Class{
+		&BacalhauError{Err: &net.DNSError{Err: "no such host", Name: "bacalhau"}}: Retryable,
+		context.DeadlineExceeded:                                Retryable,
+		errors.New("something the bridge doesn't know about"):   Retryable,
+		&SpecError{Err: errors.New("a CID or URL is required")}: Terminal,
+		fmt.Errorf("%w: too expensive", ErrOrderRejected):       Terminal,
+		&RevertError{Reason: "execution reverted"}:              Terminal,
+		fmt.Errorf("completing order: %w", notWatched):          Terminal,
+	}
+
+	for err, class := range testCases {
+		require.Equal(t, class, Classify(err), err.Error())
+	}
+}
+
+func TestMarkedErrorsStillMatch(t *testing.T) {
+	sentinel := errors.New("not supported by the deployed contract version")
+	marked := MarkTerminal(sentinel)
+	require.Equal(t, sentinel.Error(), marked.Error())
+	require.ErrorIs(t, fmt.Errorf("checking settlement: %w", marked), sentinel)
+}
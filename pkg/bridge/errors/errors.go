@@ -0,0 +1,102 @@
+// Package errors defines the kinds of error that the bridge returns, so that
+// callers and tests can tell what went wrong with errors.Is and errors.As
+// rather than by matching messages.
+//
+// Each kind is a sentinel that errors of that kind match, whether they wrap
+// the sentinel or are one of the typed errors here, which carry the details.
+// Classify says whether an error is worth retrying.
+//
+// It is usually imported as bridgeerrors, to keep it apart from the standard
+// library's errors package.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// Bacalhau couldn't be reached, or every endpoint is refusing requests.
+	ErrBacalhauUnavailable = errors.New("Bacalhau is unavailable")
+
+	// The job spec of an order can't be read, or is missing something the
+	// job needs to run.
+	ErrSpecInvalid = errors.New("invalid job spec")
+
+	// The bridge refused to run the job of an order.
+	ErrOrderRejected = errors.New("order rejected")
+
+	// The job of an order ran for longer than it is allowed to.
+	ErrOrderExpired = errors.New("order expired")
+
+	// The contract reverted a transaction, or would revert it if it were
+	// sent.
+	ErrTxReverted = errors.New("transaction reverted")
+)
+
+// A BacalhauError is a request to Bacalhau that failed because Bacalhau
+// couldn't be reached, rather than because it refused the request. It matches
+// ErrBacalhauUnavailable.
+type BacalhauError struct {
+	// The endpoint the request was made to, if it was made to one.
+	Endpoint string
+	Err      error
+}
+
+func (e *BacalhauError) Error() string {
+	if e.Endpoint == "" {
+		return fmt.Sprintf("%s: %s", ErrBacalhauUnavailable, e.Err)
+	}
+	return fmt.Sprintf("%s at %s: %s", ErrBacalhauUnavailable, e.Endpoint, e.Err)
+}
+
+func (e *BacalhauError) Is(target error) bool { return target == ErrBacalhauUnavailable }
+func (e *BacalhauError) Unwrap() error        { return e.Err }
+
+// A SpecError is a job spec that can't be run. It matches ErrSpecInvalid.
+type SpecError struct {
+	Err error
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSpecInvalid, e.Err)
+}
+
+func (e *SpecError) Is(target error) bool { return target == ErrSpecInvalid }
+func (e *SpecError) Unwrap() error        { return e.Err }
+
+// An ExpiredError is a job that ran for longer than the limit. It matches
+// ErrOrderExpired.
+type ExpiredError struct {
+	Limit time.Duration
+}
+
+func (e *ExpiredError) Error() string {
+	return fmt.Sprintf("Bacalhau job timed out after %s", e.Limit)
+}
+
+func (e *ExpiredError) Is(target error) bool { return target == ErrOrderExpired }
+
+// A RevertError is a transaction that the contract reverted, or would revert
+// if it were sent. It matches ErrTxReverted.
+type RevertError struct {
+	// The hash of the transaction, or empty if it wasn't sent.
+	Transaction string
+
+	// Why the contract reverted it, if known.
+	Reason string
+}
+
+func (e *RevertError) Error() string {
+	message := "transaction would revert"
+	if e.Transaction != "" {
+		message = fmt.Sprintf("transaction %s reverted", e.Transaction)
+	}
+	if e.Reason != "" {
+		message += ": " + e.Reason
+	}
+	return message
+}
+
+func (e *RevertError) Is(target error) bool { return target == ErrTxReverted }
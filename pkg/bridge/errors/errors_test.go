@@ -0,0 +1,44 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedErrorsMatchTheirKind(t *testing.T) {
+	kinds := []error{ErrBacalhauUnavailable, ErrSpecInvalid, ErrOrderRejected, ErrOrderExpired, ErrTxReverted}
+	typed := map[error]error{
+		&BacalhauError{Endpoint: "http://localhost:1234", Err: context.DeadlineExceeded}: ErrBacalhauUnavailable,
+		&SpecError{Err: errors.New("Docker jobs must specify an image")}:                 ErrSpecInvalid,
+		&ExpiredError{Limit: time.Hour}:                                                  ErrOrderExpired,
+		&RevertError{Reason: "execution reverted: Job has already been settled"}:         ErrTxReverted,
+	}
+
+	for err, kind := range typed {
+		wrapped := fmt.Errorf("processing order: %w", err)
+		for _, other := range kinds {
+			require.Equal(t, other == kind, errors.Is(wrapped, other), "%q matching %q", err, other)
+		}
+	}
+}
+
+func TestTypedErrorsKeepTheirCause(t *testing.T) {
+	err := fmt.Errorf("submitting job: %w", &BacalhauError{Err: context.DeadlineExceeded})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var unavailable *BacalhauError
+	require.ErrorAs(t, err, &unavailable)
+	require.Empty(t, unavailable.Endpoint)
+}
+
+func TestRevertMessages(t *testing.T) {
+	require.Equal(t, "transaction would revert: execution reverted",
+		(&RevertError{Reason: "execution reverted"}).Error())
+	require.Equal(t, "transaction 0x01 reverted",
+		(&RevertError{Transaction: "0x01"}).Error())
+}
@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/compute/capacity"
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+)
+
+// ResourcePrices are what a resource provider charges for running jobs, in
+// the chain's native token. Memory and disk are priced per gigabyte of 2^30
+// bytes.
+type ResourcePrices struct {
+	CPUHour    float64 `json:"cpuHour"`
+	MemoryHour float64 `json:"memoryHour"`
+	DiskHour   float64 `json:"diskHour"`
+	GPUHour    float64 `json:"gpuHour"`
+	Job        float64 `json:"job"`
+}
+
+const gigabyte = 1 << 30
+
+// The resources that Bacalhau gives jobs that don't ask for any.
+var defaultJobResources = model.ResourceUsageConfig{CPU: "100m", Memory: "100Mb"}
+
+// An Estimate is the resources that a job is expected to use and what they
+// will cost.
+type Estimate struct {
+	CPU    float64 `json:"cpu"`
+	Memory uint64  `json:"memory"`
+	Disk   uint64  `json:"disk"`
+	GPU    uint64  `json:"gpu"`
+
+	// How long the job is expected to run for, in seconds.
+	Duration float64 `json:"duration"`
+
+	Cost float64 `json:"cost"`
+}
+
+// Covers returns whether the passed price, in the chain's native token, pays
+// for the job.
+func (e Estimate) Covers(price float64) bool {
+	return price >= e.Cost
+}
+
+// An Estimator predicts the resources that a job will use and what it will
+// cost to run, so that resource providers can decide whether the price paid
+// on-chain covers it before the job is accepted.
+//
+// Jobs are assumed to use everything they ask for, for as long as they are
+// allowed to run: their timeout, or the longest that the bridge waits for a
+// job if that is shorter or they have none.
+type Estimator struct {
+	prices      ResourcePrices
+	maxDuration time.Duration
+	templates   TemplateSource
+}
+
+// How long jobs are taken to run for when the bridge doesn't limit them and
+// they don't have a timeout.
+const defaultEstimatedDuration = time.Hour
+
+// NewEstimator returns an Estimator that charges the passed prices for jobs
+// that run for at most maxDuration, or for as long as they ask if it is zero.
+// Orders for templates are estimated from the passed source, which may be nil
+// if they can't be run.
+func NewEstimator(prices ResourcePrices, maxDuration time.Duration, templates TemplateSource) *Estimator {
+	return &Estimator{prices: prices, maxDuration: maxDuration, templates: templates}
+}
+
+// Estimate returns the estimate for a job with the passed spec.
+func (e *Estimator) Estimate(spec model.Spec) Estimate {
+	resources := spec.Resources
+	if resources.CPU == "" {
+		resources.CPU = defaultJobResources.CPU
+	}
+	if resources.Memory == "" {
+		resources.Memory = defaultJobResources.Memory
+	}
+	usage := capacity.ParseResourceUsageConfig(resources)
+
+	duration := jobTimeLimit(spec, e.maxDuration)
+	if duration == 0 {
+		duration = defaultEstimatedDuration
+	}
+
+	hours := duration.Hours()
+	cost := e.prices.Job +
+		hours*usage.CPU*e.prices.CPUHour +
+		hours*float64(usage.Memory)/gigabyte*e.prices.MemoryHour +
+		hours*float64(usage.Disk)/gigabyte*e.prices.DiskHour +
+		hours*float64(usage.GPU)*e.prices.GPUHour
+
+	return Estimate{
+		CPU:      usage.CPU,
+		Memory:   usage.Memory,
+		Disk:     usage.Disk,
+		GPU:      usage.GPU,
+		Duration: duration.Seconds(),
+		Cost:     cost,
+	}
+}
+
+// EstimateOrder returns the estimate for the spec of an order as it is sent
+// on-chain, which is either a whole job spec or a request for a template. A
+// *Rejection is returned for templates that can't be rendered.
+func (e *Estimator) EstimateOrder(spec []byte) (Estimate, error) {
+	var parsed model.Spec
+	if request, ok := parseTemplateRequest(spec); ok {
+		rendered, err := renderTemplateRequest(e.templates, request)
+		if err != nil {
+			return Estimate{}, err
+		}
+		parsed = rendered
+	} else if err := json.Unmarshal(spec, &parsed); err != nil {
+		return Estimate{}, err
+	}
+	return e.Estimate(parsed), nil
+}
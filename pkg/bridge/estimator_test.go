@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testPrices = ResourcePrices{CPUHour: 0.01, MemoryHour: 0.002, DiskHour: 0.0001, GPUHour: 0.5, Job: 0.001}
+
+func TestEstimate(t *testing.T) {
+	estimator := NewEstimator(testPrices, time.Hour, nil)
+
+	estimate := estimator.Estimate(model.Spec{
+		Resources: model.ResourceUsageConfig{CPU: "2", Memory: "4Gb", GPU: "1"},
+		Timeout:   30 * 60,
+	})
+	require.Equal(t, 2.0, estimate.CPU)
+	require.Equal(t, uint64(4*gigabyte), estimate.Memory)
+	require.Equal(t, uint64(1), estimate.GPU)
+	require.Equal(t, 1800.0, estimate.Duration)
+	require.InDelta(t, 0.001+0.5*(2*0.01+4*0.002+0.5), estimate.Cost, 1e-9)
+	require.True(t, estimate.Covers(0.3))
+	require.False(t, estimate.Covers(0.2))
+
+	// Jobs that ask for nothing get Bacalhau's defaults for the longest time.
+	estimate = estimator.Estimate(model.Spec{})
+	require.Equal(t, 0.1, estimate.CPU)
+	require.Equal(t, 3600.0, estimate.Duration)
+	require.Greater(t, estimate.Cost, testPrices.Job)
+}
+
+func TestEstimateHandler(t *testing.T) {
+	library := &TemplateLibrary{templates: map[string][]*JobTemplate{}}
+	require.NoError(t, library.Add(&JobTemplate{
+		Name:       "gpu",
+		Version:    "1",
+		Parameters: []TemplateParameter{{Name: "image"}},
+		Spec: map[string]any{
+			"Engine":    "docker",
+			"Docker":    map[string]any{"Image": "${image}"},
+			"Resources": map[string]any{"GPU": "2"},
+		},
+	}))
+	handler := EstimateHandler(NewEstimator(testPrices, time.Hour, library))
+
+	estimate := func(spec, price string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, EstimatePath+price, strings.NewReader(spec))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var body map[string]any
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		}
+		return rec, body
+	}
+
+	rec, body := estimate(`{"template": "gpu", "parameters": {"image": "ubuntu"}}`, "?price=0.5")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 2.0, body["gpu"])
+	require.Equal(t, false, body["covered"])
+
+	rec, body = estimate(`{"Engine": "docker", "Docker": {"Image": "ubuntu"}}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, body, "covered")
+
+	rec, _ = estimate(`{"template": "missing"}`, "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = estimate(`{}`, "?price=lots")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
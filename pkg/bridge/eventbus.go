@@ -0,0 +1,211 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A Notification describes an order moving into a new state. Unlike events,
+// notifications are plain values that are safe to hand to other goroutines and
+// other systems.
+type Notification struct {
+	OrderID string    `json:"orderId"`
+	State   string    `json:"state"`
+	Time    time.Time `json:"time"`
+	JobID   string    `json:"jobId,omitempty"`
+	Results []string  `json:"results,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+
+	// Set on the heartbeats of running jobs, which report how far the job
+	// has got rather than a change of state.
+	Progress *JobProgress `json:"progress,omitempty"`
+
+	// Set once the order has reached the end of its lifecycle, to say when
+	// it reached each milestone on the way.
+	Timeline *Timeline `json:"timeline,omitempty"`
+
+	// Set when the transaction that settled the order has become final or
+	// been reverted, to say which transaction and in which block.
+	Transaction string `json:"transaction,omitempty"`
+	Block       uint64 `json:"block,omitempty"`
+}
+
+func newNotification(ctx context.Context, e Event) Notification {
+	n := Notification{
+		OrderID: e.OrderId().Hex(),
+		State:   e.OrderState().String(),
+		Time:    time.Now().UTC(),
+	}
+
+	switch e.OrderState() {
+	case OrderStateRunning, OrderStateCompleted, OrderStatePaid, OrderStateJobError:
+		n.JobID = e.(BacalhauJobRunningEvent).JobID()
+	}
+
+	switch e.OrderState() {
+	case OrderStateCompleted, OrderStatePaid:
+		for _, result := range e.(BacalhauJobCompletedEvent).Results(ctx) {
+			n.Results = append(n.Results, result.String())
+		}
+	case OrderStateJobError:
+		failed := e.(BacalhauJobFailedEvent)
+		n.Error, n.Reason = failed.Error(), failed.FailureReason().String()
+	case OrderStateFailed, OrderStateRefunded:
+		failed := e.(ContractFailedEvent)
+		n.Error, n.Reason = failed.Error(), failed.FailureReason().String()
+	}
+
+	if e.OrderState().Terminal() {
+		if submitted, ok := e.(ContractSubmittedEvent); ok {
+			timeline := submitted.Timeline()
+			n.Timeline = &timeline
+		}
+	}
+	return n
+}
+
+// A Subscriber is told about every notification published on an EventBus.
+type Subscriber interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// How many notifications can wait for a slow subscriber before more are dropped.
+const subscriberQueueSize = 256
+
+// An EventBus passes notifications of order state changes to any number of
+// subscribers. Each subscriber is notified in its own goroutine, so a slow
+// subscriber never holds up the workflow or the other subscribers.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan Notification
+	nextID      int
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[int]chan Notification{}}
+}
+
+// Subscribe starts passing notifications to the subscriber, until the returned
+// function is called.
+func (bus *EventBus) Subscribe(sub Subscriber) (unsubscribe func()) {
+	queue, unsubscribe := bus.Channel()
+	go func() {
+		for n := range queue {
+			if err := sub.Notify(context.Background(), n); err != nil {
+				log.Error().Err(err).Str("id", n.OrderID).Str("state", n.State).Msg("Unable to notify subscriber")
+			}
+		}
+	}()
+	return unsubscribe
+}
+
+// Channel returns a channel that receives every notification, for use by Go
+// code embedding the bridge, and a function that closes it. Notifications are
+// dropped if the channel is not read from quickly enough.
+func (bus *EventBus) Channel() (<-chan Notification, func()) {
+	queue := make(chan Notification, subscriberQueueSize)
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	id := bus.nextID
+	bus.nextID++
+	bus.subscribers[id] = queue
+
+	var once sync.Once
+	return queue, func() {
+		once.Do(func() {
+			bus.mu.Lock()
+			defer bus.mu.Unlock()
+			delete(bus.subscribers, id)
+			close(queue)
+		})
+	}
+}
+
+// Publish notifies all subscribers that the passed event is in a new state. A
+// nil bus publishes nothing.
+func (bus *EventBus) Publish(ctx context.Context, e Event) {
+	if bus == nil {
+		return
+	}
+
+	bus.send(ctx, newNotification(ctx, e))
+}
+
+// PublishProgress notifies all subscribers of how far a running job has got.
+// A nil bus publishes nothing.
+func (bus *EventBus) PublishProgress(ctx context.Context, progress JobProgress) {
+	if bus == nil {
+		return
+	}
+
+	bus.send(ctx, Notification{
+		OrderID:  progress.OrderID,
+		State:    OrderStateRunning.String(),
+		Time:     progress.Time,
+		JobID:    progress.JobID,
+		Progress: &progress,
+	})
+}
+
+// PublishPosting notifies all subscribers that the transaction that settled an
+// order has become final or been reverted. A nil bus publishes nothing.
+func (bus *EventBus) PublishPosting(ctx context.Context, posting Posting) {
+	if bus == nil {
+		return
+	}
+
+	state := NotificationResultConfirmed
+	if posting.Status == PostingStatusReverted {
+		state = NotificationResultPostFailed
+	}
+	bus.send(ctx, Notification{
+		OrderID:     posting.OrderID,
+		State:       state,
+		Time:        posting.Time,
+		Transaction: posting.Transaction,
+		Block:       posting.Block,
+	})
+}
+
+func (bus *EventBus) send(ctx context.Context, n Notification) {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	for _, queue := range bus.subscribers {
+		select {
+		case queue <- n:
+		default:
+			log.Ctx(ctx).Warn().Str("state", n.State).Msg("Subscriber queue full, dropping notification")
+		}
+	}
+}
+
+// A PublishFunc sends a message on a subject of a message broker. It has the
+// same signature as the Publish method of a NATS connection.
+type PublishFunc func(subject string, data []byte) error
+
+type publishSubscriber struct {
+	prefix  string
+	publish PublishFunc
+}
+
+// NewPublishSubscriber returns a Subscriber that publishes each notification
+// as JSON using the passed function, on a subject made from the prefix and the
+// order state, e.g. "lilypad.orders.Completed".
+func NewPublishSubscriber(prefix string, publish PublishFunc) Subscriber {
+	return &publishSubscriber{prefix: prefix, publish: publish}
+}
+
+// Notify implements Subscriber
+func (p *publishSubscriber) Notify(ctx context.Context, n Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return p.publish(p.prefix+"."+n.State, data)
+}
@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, c <-chan Notification) Notification {
+	select {
+	case n := <-c:
+		return n
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for notification")
+		return Notification{}
+	}
+}
+
+func TestEventBusChannel(t *testing.T) {
+	bus := NewEventBus()
+	c, unsubscribe := bus.Channel()
+
+	e := exampleEvent()
+	bus.Publish(context.Background(), e)
+	bus.Publish(context.Background(), e.JobCreated(model.NewJob()).JobFailed(FailureReasonTimeout, "too slow", ""))
+
+	n := receive(t, c)
+	require.Equal(t, e.OrderId().Hex(), n.OrderID)
+	require.Equal(t, "Submitted", n.State)
+
+	n = receive(t, c)
+	require.Equal(t, "JobError", n.State)
+	require.Equal(t, "too slow", n.Error)
+	require.Equal(t, "Timeout", n.Reason)
+
+	unsubscribe()
+	bus.Publish(context.Background(), e)
+	_, open := <-c
+	require.False(t, open)
+}
+
+func TestPublishSubscriber(t *testing.T) {
+	subjects := make(chan string, 1)
+	sub := NewPublishSubscriber("lilypad.orders", func(subject string, data []byte) error {
+		subjects <- subject
+		return nil
+	})
+
+	require.NoError(t, sub.Notify(context.Background(), newNotification(context.Background(), exampleEvent())))
+	require.Equal(t, "lilypad.orders.Submitted", <-subjects)
+}
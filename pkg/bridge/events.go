@@ -1,12 +1,17 @@
 package bridge
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
 	"time"
 
 	"github.com/bacalhau-project/bacalhau/pkg/model"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
 )
 
 //go:generate stringer -type=OrderState --trimprefix=OrderState
@@ -34,6 +39,58 @@ func OrderStates() [7]OrderState {
 	}
 }
 
+// ParseOrderState returns the order state with the passed name, ignoring case.
+func ParseOrderState(name string) (OrderState, error) {
+	for _, state := range OrderStates() {
+		if strings.EqualFold(name, state.String()) {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown order state %q", name)
+}
+
+// A FailureReason classifies why an order could not be completed.
+//
+//go:generate stringer -type=FailureReason --trimprefix=FailureReason
+type FailureReason int
+
+const (
+	FailureReasonUnknown FailureReason = iota
+	// The job could not be submitted to the compute network.
+	FailureReasonSubmitError
+	// The job ran but did not succeed.
+	FailureReasonExecutionError
+	// The job said it succeeded but its results could not be found or trusted.
+	FailureReasonVerificationFailure
+	// The job took too long to finish.
+	FailureReasonTimeout
+	// The job was cancelled on the compute network.
+	FailureReasonCancelled
+	// The bridge refused to run the job.
+	FailureReasonRejected
+	// The order was removed from the chain by a reorg.
+	FailureReasonReorged
+	// An input of the job could not be retrieved from IPFS.
+	FailureReasonInputUnavailable
+	// The price offered for the job was less than the bridge charges.
+	FailureReasonUnderpriced
+	// The order was made from an address the bridge doesn't take orders from.
+	FailureReasonBlocked
+	// The order was resolved on chain by something other than the bridge,
+	// such as mediation, so its result can't be returned.
+	FailureReasonResolved
+)
+
+// parseFailureReason returns the failure reason with the passed name.
+func parseFailureReason(name string) (FailureReason, error) {
+	for reason := FailureReasonUnknown; reason <= FailureReasonResolved; reason++ {
+		if name == reason.String() {
+			return reason, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown failure reason %q", name)
+}
+
 type ResultType uint8
 
 const (
@@ -47,6 +104,15 @@ func (r ResultType) Valid() bool {
 	return r >= ResultTypeCID && r <= ResultTypeExitCode
 }
 
+// An Execution is the outcome of running a job on a single Bacalhau node.
+type Execution struct {
+	NodeID   string `json:"nodeId"`
+	State    string `json:"state"`
+	Status   string `json:"status,omitempty"`
+	Result   string `json:"result,omitempty"`
+	ExitCode int    `json:"exitCode"`
+}
+
 type Event interface {
 	OrderId() common.Hash
 	OrderState() OrderState
@@ -57,6 +123,7 @@ type Retryable interface {
 
 	Attempts() uint
 	AddAttempt() uint
+	ResetAttempts()
 	LastAttempt() time.Time
 }
 
@@ -69,7 +136,40 @@ type ContractSubmittedEvent interface {
 	OrderRequestor() common.Address
 	Spec() (model.Spec, error)
 
+	// The contract that the order was made on, or the zero address for
+	// orders read before the bridge kept track.
+	SourceContract() common.Address
+
+	// The spec exactly as the order passed it, which may be a request for a
+	// job template rather than a whole spec.
+	RawSpec() []byte
+	WithSpec(spec []byte) ContractSubmittedEvent
+
+	// How many times a job for the order has been submitted again after an
+	// earlier job failed.
+	Resubmissions() uint
+
+	// The public key that the order asked for its results to be encrypted
+	// with, or nil if they don't need to be.
+	EncryptionKey() []byte
+
+	// Whether the order asked for a Filecoin storage deal to be made for its
+	// result, so that it is kept for the long term.
+	DurableStorage() bool
+
+	// The price that the order offered to pay for the job, in the smallest
+	// unit of its payment token, or nil if the contract didn't say.
+	OfferedPrice() *big.Int
+
+	// The ERC-20 token that the order was paid for in, or the zero address
+	// if it was paid for in the chain's native token.
+	PaymentToken() common.Address
+
+	// When the order reached each milestone on its way through the bridge.
+	Timeline() Timeline
+
 	Failed(err string) ContractFailedEvent
+	FailedWith(reason FailureReason, err string) ContractFailedEvent
 	JobCreated(*model.Job) BacalhauJobRunningEvent
 }
 
@@ -81,8 +181,19 @@ type BacalhauJobRunningEvent interface {
 
 	JobID() string
 
+	// The URL of the Bacalhau cluster that the job was submitted to, or empty
+	// if it was submitted before this was recorded.
+	Endpoint() string
+	WithEndpoint(url string) BacalhauJobRunningEvent
+
+	// What happened on each of the nodes that ran the job, so that orders
+	// that only partly completed can be dealt with fairly.
+	Executions() []Execution
+	WithExecutions(executions []Execution) BacalhauJobRunningEvent
+
 	Completed(result cid.Cid, stdout, stderr string, exitcode int) BacalhauJobCompletedEvent
 	JobError(err string) BacalhauJobFailedEvent
+	JobFailed(reason FailureReason, err, stateMessage string) BacalhauJobFailedEvent
 }
 
 type BacalhauJobCompletedEvent interface {
@@ -91,12 +202,34 @@ type BacalhauJobCompletedEvent interface {
 
 	BacalhauJobRunningEvent
 
-	Result() cid.Cid
+	Result(ctx context.Context) cid.Cid
 	StdOut() string
 	StdErr() string
 	ExitCode() int
 
+	// All of the results published by the job, of which Result is the first.
+	Results(ctx context.Context) []cid.Cid
+	WithResults(results []cid.Cid) BacalhauJobCompletedEvent
+
+	// The canonical hash of the job's output, if it has been worked out, or
+	// the zero hash.
+	OutputHash() common.Hash
+	WithOutputHash(hash common.Hash) BacalhauJobCompletedEvent
+
+	// The CID of the encrypted copy of the result, if the order asked for its
+	// results to be encrypted and they have been, or cid.Undef.
+	EncryptedResult(ctx context.Context) cid.Cid
+	WithEncryptedResult(result cid.Cid) BacalhauJobCompletedEvent
+
+	// The ID of the Filecoin storage deal made for the result, if the order
+	// asked for durable storage and the deal has been made, or empty.
+	DealID() string
+	WithDealID(id string) BacalhauJobCompletedEvent
+
 	Paid() ContractPaidEvent
+
+	// Records that the result was returned in the passed transaction.
+	PaidIn(txn common.Hash) ContractPaidEvent
 }
 
 type BacalhauJobFailedEvent interface {
@@ -106,6 +239,10 @@ type BacalhauJobFailedEvent interface {
 	BacalhauJobRunningEvent
 
 	Error() string
+	FailureReason() FailureReason
+
+	// The state of the job as reported by the compute network, if known.
+	StateMessage() string
 
 	Retry() ContractSubmittedEvent
 }
@@ -117,20 +254,33 @@ type ContractFailedEvent interface {
 	ContractSubmittedEvent
 
 	Error() string
+	FailureReason() FailureReason
+	StateMessage() string
 
 	Refunded() ContractRefundedEvent
+
+	// Records that the error was returned in the passed transaction.
+	RefundedIn(txn common.Hash) ContractRefundedEvent
 }
 
 type ContractPaidEvent interface {
 	Event
 
 	BacalhauJobCompletedEvent
+
+	// The transaction that returned the result, if it was sent by this run
+	// of the bridge, or the zero hash.
+	Transaction() common.Hash
 }
 
 type ContractRefundedEvent interface {
 	Event
 
 	ContractFailedEvent
+
+	// The transaction that returned the error, if it was sent by this run of
+	// the bridge, or the zero hash.
+	Transaction() common.Hash
 }
 
 type event struct {
@@ -148,6 +298,46 @@ type event struct {
 	jobStdout       string
 	jobStderr       string
 	jobExitcode     int
+	jobResults      []string
+	resubmissions   uint
+	jobExecutions   []Execution
+	jobEndpoint     string
+	failureReason   FailureReason
+	stateMessage    string
+	jobOutputHash   string
+
+	// The public key that results must be encrypted with, if any, and where
+	// the encrypted copy of the result was uploaded.
+	encryptionKey      []byte
+	jobEncryptedResult string
+
+	// Whether the result must be kept in a Filecoin storage deal, and the ID
+	// of the deal once it has been made.
+	durableStorage bool
+	jobDealId      string
+
+	// The price offered for the job in the smallest unit of its payment
+	// token, in decimal, or empty if unknown.
+	orderPrice string
+
+	// The address of the ERC-20 token the order was paid for in, or empty if
+	// it was paid for in the native token.
+	orderToken []byte
+
+	// When the order reached each milestone.
+	timeline Timeline
+
+	// The address of the contract the order was made on, if known.
+	orderContract []byte
+
+	// When the event was saved, if it was loaded from a repository.
+	savedAt time.Time
+
+	// The transaction that settled the order on-chain, which isn't saved.
+	txHash common.Hash
+
+	// The first transition the event was refused, which stops it being saved.
+	transitionErr error
 }
 
 // The smart contract order ID.
@@ -173,18 +363,28 @@ func (e *event) OrderRequestor() common.Address {
 	return common.BytesToAddress(e.orderOwner)
 }
 
+// SourceContract implements ContractSubmittedEvent
+func (e *event) SourceContract() common.Address {
+	return common.BytesToAddress(e.orderContract)
+}
+
 // Result implements BacalhauJobCompletedEvent
-func (e *event) Result() cid.Cid {
-	return cid.MustParse(e.jobResult)
+func (e *event) Result(ctx context.Context) cid.Cid {
+	return e.parseResult(ctx, e.jobResult)
 }
 
 // Log the event as being retried.
 func (e *event) AddAttempt() uint {
 	e.attempts += 1
-	e.lastAttempt = time.Now()
+	e.lastAttempt = now()
 	return e.attempts
 }
 
+// Forgets about previous attempts, so that the event can be tried afresh.
+func (e *event) ResetAttempts() {
+	e.attempts = 0
+}
+
 // Returns the number of times we have tried to process the event and move it
 // into the next state.
 func (e *event) Attempts() uint {
@@ -196,6 +396,144 @@ func (e *event) LastAttempt() time.Time {
 	return e.lastAttempt
 }
 
+// Results implements BacalhauJobCompletedEvent
+func (e *event) Results(ctx context.Context) []cid.Cid {
+	results := make([]cid.Cid, 0, len(e.jobResults))
+	for _, result := range e.jobResults {
+		if parsed := e.parseResult(ctx, result); parsed.Defined() {
+			results = append(results, parsed)
+		}
+	}
+	return results
+}
+
+// parseResult returns the result CID stored in the event, or cid.Undef if
+// there isn't one or it can't be read. Events are checked as they are loaded,
+// so a result that can't be read here was never loaded that way.
+func (e *event) parseResult(ctx context.Context, result string) cid.Cid {
+	if result == "" {
+		return cid.Undef
+	}
+	parsed, err := cid.Parse(result)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Stringer("id", e.OrderId()).Str("cid", result).Msg("Invalid result CID")
+		return cid.Undef
+	}
+	return parsed
+}
+
+// checkResults returns an error if any of the result CIDs stored in the event
+// can't be read, such as from a corrupted database or WAL.
+func (e *event) checkResults() error {
+	results := append([]string{e.jobResult, e.jobEncryptedResult}, e.jobResults...)
+	for _, result := range results {
+		if result == "" {
+			continue
+		}
+		if _, err := cid.Parse(result); err != nil {
+			return fmt.Errorf("invalid result CID %q: %w", result, err)
+		}
+	}
+	return nil
+}
+
+// Records all of the results published by a completed Bacalhau job.
+func (e *event) WithResults(results []cid.Cid) BacalhauJobCompletedEvent {
+	e.jobResults = make([]string, 0, len(results))
+	for _, result := range results {
+		e.jobResults = append(e.jobResults, result.String())
+	}
+	e.timeline.ResultFetched = now()
+	return e
+}
+
+// OutputHash implements BacalhauJobCompletedEvent
+func (e *event) OutputHash() common.Hash {
+	if e.jobOutputHash == "" {
+		return common.Hash{}
+	}
+	return common.HexToHash(e.jobOutputHash)
+}
+
+// Records the canonical hash of the output of a completed Bacalhau job.
+func (e *event) WithOutputHash(hash common.Hash) BacalhauJobCompletedEvent {
+	e.jobOutputHash = hash.Hex()
+	return e
+}
+
+// EncryptionKey implements ContractSubmittedEvent
+func (e *event) EncryptionKey() []byte {
+	return e.encryptionKey
+}
+
+// EncryptedResult implements BacalhauJobCompletedEvent
+func (e *event) EncryptedResult(ctx context.Context) cid.Cid {
+	return e.parseResult(ctx, e.jobEncryptedResult)
+}
+
+// Records where the encrypted copy of the result of a completed Bacalhau job
+// was uploaded.
+func (e *event) WithEncryptedResult(result cid.Cid) BacalhauJobCompletedEvent {
+	e.jobEncryptedResult = result.String()
+	return e
+}
+
+// DurableStorage implements ContractSubmittedEvent
+func (e *event) DurableStorage() bool {
+	return e.durableStorage
+}
+
+// OfferedPrice implements ContractSubmittedEvent
+func (e *event) OfferedPrice() *big.Int {
+	price, ok := new(big.Int).SetString(e.orderPrice, 10)
+	if !ok {
+		return nil
+	}
+	return price
+}
+
+// PaymentToken implements ContractSubmittedEvent
+func (e *event) PaymentToken() common.Address {
+	return common.BytesToAddress(e.orderToken)
+}
+
+// DealID implements BacalhauJobCompletedEvent
+func (e *event) DealID() string {
+	return e.jobDealId
+}
+
+// Records the Filecoin storage deal made for the result of a completed
+// Bacalhau job.
+func (e *event) WithDealID(id string) BacalhauJobCompletedEvent {
+	e.jobDealId = id
+	return e
+}
+
+// Endpoint implements BacalhauJobRunningEvent
+func (e *event) Endpoint() string {
+	return e.jobEndpoint
+}
+
+// Records which Bacalhau cluster the job was submitted to.
+func (e *event) WithEndpoint(url string) BacalhauJobRunningEvent {
+	e.jobEndpoint = url
+	return e
+}
+
+// Executions implements BacalhauJobRunningEvent
+func (e *event) Executions() []Execution {
+	return e.jobExecutions
+}
+
+// Records what happened on each node that ran the Bacalhau job.
+func (e *event) WithExecutions(executions []Execution) BacalhauJobRunningEvent {
+	e.jobExecutions = executions
+	if e.timeline.Started.IsZero() && executing(executions) {
+		e.timeline.Started = now()
+	}
+	return e
+}
+
 // ExitCode implements BacalhauJobCompletedEvent
 func (e *event) ExitCode() int {
 	return e.jobExitcode
@@ -219,62 +557,148 @@ func (e *event) Error() string {
 // Records that a ContractSubmittedEvent has been sent to the Bacalhau network
 // as a job.
 func (e *event) JobCreated(job *model.Job) BacalhauJobRunningEvent {
-	e.state = OrderStateRunning
+	if !e.transition(OrderStateRunning) {
+		return e
+	}
 	e.jobId = job.Metadata.ID
+	e.jobExecutions = nil
+	e.jobEndpoint = ""
+	e.jobOutputHash = ""
+	e.jobEncryptedResult = ""
+	e.jobDealId = ""
+	e.timeline.Submitted = now()
+	e.timeline.Started = time.Time{}
+	e.timeline.Completed = time.Time{}
+	e.timeline.ResultFetched = time.Time{}
 	return e
 }
 
 // Records that a BacalhauJobCompletedEvent has been successfully sent to the
 // smart contract for payment.
 func (e *event) Paid() ContractPaidEvent {
-	e.state = OrderStatePaid
+	e.transition(OrderStatePaid)
 	return e
 }
 
 // Records that an Event has been successfully returned to the smart contract
 // for a refund.
 func (e *event) Refunded() ContractRefundedEvent {
-	e.state = OrderStateRefunded
+	e.transition(OrderStateRefunded)
 	return e
 }
 
+// Records that a BacalhauJobCompletedEvent was sent to the smart contract for
+// payment in the passed transaction.
+func (e *event) PaidIn(txn common.Hash) ContractPaidEvent {
+	e.txHash = txn
+	e.timeline.TxPosted = now()
+	return e.Paid()
+}
+
+// Records that an Event was returned to the smart contract for a refund in the
+// passed transaction.
+func (e *event) RefundedIn(txn common.Hash) ContractRefundedEvent {
+	e.txHash = txn
+	e.timeline.TxPosted = now()
+	return e.Refunded()
+}
+
+// Transaction implements ContractPaidEvent and ContractRefundedEvent
+func (e *event) Transaction() common.Hash {
+	return e.txHash
+}
+
 // The Bacalhau job spec that the contract is asking us to run.
 func (e *event) Spec() (spec model.Spec, err error) {
 	err = json.Unmarshal(e.jobSpec, &spec)
 	return
 }
 
+// RawSpec implements ContractSubmittedEvent
+func (e *event) RawSpec() []byte {
+	return e.jobSpec
+}
+
+// Replaces the spec of the job that a ContractSubmittedEvent asks for.
+func (e *event) WithSpec(spec []byte) ContractSubmittedEvent {
+	e.jobSpec = spec
+	return e
+}
+
 // Records that a running Bacalhau job has completed.
 func (e *event) Completed(result cid.Cid, stdout, stderr string, exitcode int) BacalhauJobCompletedEvent {
-	e.state = OrderStateCompleted
+	if !e.transition(OrderStateCompleted) {
+		return e
+	}
 	e.jobResult = result.String()
 	e.jobStdout = stdout
 	e.jobStderr = stderr
 	e.jobExitcode = exitcode
+	e.timeline.Completed = now()
 	return e
 }
 
-// Records that a running Bacalhau job has failed.
+// Records that a running Bacalhau job has failed to execute.
 func (e *event) JobError(err string) BacalhauJobFailedEvent {
-	e.state = OrderStateJobError
+	return e.JobFailed(FailureReasonExecutionError, err, "")
+}
+
+// Records that a running Bacalhau job has failed for the passed reason.
+func (e *event) JobFailed(reason FailureReason, err, stateMessage string) BacalhauJobFailedEvent {
+	if !e.transition(OrderStateJobError) {
+		return e
+	}
 	e.jobStderr = err
+	e.failureReason = reason
+	e.stateMessage = stateMessage
 	return e
 }
 
+// FailureReason implements BacalhauJobFailedEvent
+func (e *event) FailureReason() FailureReason {
+	return e.failureReason
+}
+
+// StateMessage implements BacalhauJobFailedEvent
+func (e *event) StateMessage() string {
+	return e.stateMessage
+}
+
 // Records that an errored Bacalhau job is being retried.
 func (e *event) Retry() ContractSubmittedEvent {
-	e.state = OrderStateSubmitted
+	if !e.transition(OrderStateSubmitted) {
+		return e
+	}
+	e.resubmissions += 1
+	e.failureReason = FailureReasonUnknown
+	e.stateMessage = ""
 	e.AddAttempt()
 	return e
 }
 
+// Resubmissions implements ContractSubmittedEvent
+func (e *event) Resubmissions() uint {
+	return e.resubmissions
+}
+
 // Records that a contract has failed permanently.
 func (e *event) Failed(err string) ContractFailedEvent {
-	e.state = OrderStateFailed
+	if !e.transition(OrderStateFailed) {
+		return e
+	}
 	e.jobStderr = err
 	return e
 }
 
+// Records that a contract has failed permanently for the passed reason.
+func (e *event) FailedWith(reason FailureReason, err string) ContractFailedEvent {
+	failed := e.Failed(err)
+	if e.state == OrderStateFailed {
+		e.failureReason = reason
+	}
+	return failed
+}
+
 // The ID of the job on the Bacalhau network.
 func (e *event) JobID() string {
 	return e.jobId
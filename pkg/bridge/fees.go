@@ -0,0 +1,234 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// A FeeReader reads what has recently been paid for gas. An *ethclient.Client
+// is a FeeReader.
+type FeeReader interface {
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// A FeeStrategy decides the EIP-1559 fees to offer for a transaction.
+type FeeStrategy interface {
+	// Fees returns the most to pay per unit of gas, and how much of that is a
+	// tip for the block producer.
+	Fees(ctx context.Context, chain FeeReader) (maxFee, tip *big.Int, err error)
+}
+
+var ErrFeeTooHigh = errors.New("gas fees are above the cap")
+
+const (
+	FeeStrategySuggested  = "suggested"
+	FeeStrategyStatic     = "static"
+	FeeStrategyPercentile = "percentile"
+)
+
+// FeeStrategyNames returns the fee strategies that can be configured.
+func FeeStrategyNames() []string {
+	return []string{FeeStrategySuggested, FeeStrategyStatic, FeeStrategyPercentile}
+}
+
+// The base fee can rise by 12.5% each block, so offering twice the base fee of
+// the next block keeps a transaction minable for at least six blocks.
+const baseFeeMultiplier = 2
+
+// How many recent blocks the percentile strategy looks at.
+const defaultFeeHistoryBlocks = 20
+
+// nextBaseFee returns the base fee of the block after the latest one.
+func nextBaseFee(ctx context.Context, chain FeeReader) (*big.Int, error) {
+	history, err := chain.FeeHistory(ctx, 1, nil, nil)
+	if err != nil {
+		return nil, err
+	} else if len(history.BaseFee) == 0 {
+		return nil, fmt.Errorf("no base fee in fee history")
+	}
+	return history.BaseFee[len(history.BaseFee)-1], nil
+}
+
+// maxFeeFor returns the most to pay per unit of gas for the passed base fee
+// and tip.
+func maxFeeFor(baseFee, tip *big.Int) *big.Int {
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(baseFeeMultiplier))
+	return maxFee.Add(maxFee, tip)
+}
+
+type suggestedFees struct{}
+
+// SuggestedFees tips what the RPC endpoint suggests, which is the same as the
+// fees used when no strategy is set.
+var SuggestedFees FeeStrategy = suggestedFees{}
+
+// Fees implements FeeStrategy
+func (suggestedFees) Fees(ctx context.Context, chain FeeReader) (*big.Int, *big.Int, error) {
+	tip, err := chain.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	baseFee, err := nextBaseFee(ctx, chain)
+	if err != nil {
+		return nil, nil, err
+	}
+	return maxFeeFor(baseFee, tip), tip, nil
+}
+
+// StaticFees always offers the same fees.
+type StaticFees struct {
+	MaxFee *big.Int
+	Tip    *big.Int
+}
+
+// Fees implements FeeStrategy
+func (s StaticFees) Fees(ctx context.Context, chain FeeReader) (*big.Int, *big.Int, error) {
+	return new(big.Int).Set(s.MaxFee), new(big.Int).Set(s.Tip), nil
+}
+
+// PercentileFees tips the median, over recent blocks, of the passed percentile
+// of the tips paid in each block. Higher percentiles get transactions mined
+// sooner but cost more.
+type PercentileFees struct {
+	Percentile float64
+	Blocks     uint64
+}
+
+// Fees implements FeeStrategy
+func (s PercentileFees) Fees(ctx context.Context, chain FeeReader) (*big.Int, *big.Int, error) {
+	blocks := s.Blocks
+	if blocks == 0 {
+		blocks = defaultFeeHistoryBlocks
+	}
+
+	history, err := chain.FeeHistory(ctx, blocks, nil, []float64{s.Percentile})
+	if err != nil {
+		return nil, nil, err
+	} else if len(history.BaseFee) == 0 {
+		return nil, nil, fmt.Errorf("no base fee in fee history")
+	}
+
+	tips := make([]*big.Int, 0, len(history.Reward))
+	for _, rewards := range history.Reward {
+		if len(rewards) > 0 && rewards[0] != nil {
+			tips = append(tips, rewards[0])
+		}
+	}
+	if len(tips) == 0 {
+		return SuggestedFees.Fees(ctx, chain)
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	tip := new(big.Int).Set(tips[len(tips)/2])
+
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+	return maxFeeFor(baseFee, tip), tip, nil
+}
+
+type cappedFees struct {
+	FeeStrategy
+	limit *big.Int
+}
+
+// WithFeeCap returns a strategy that never offers more than limit per unit of
+// gas. If the base fee is already over the cap, ErrFeeTooHigh is returned
+// rather than sending a transaction that can't be mined, so that it is tried
+// again later.
+func WithFeeCap(strategy FeeStrategy, limit *big.Int) FeeStrategy {
+	return cappedFees{FeeStrategy: strategy, limit: limit}
+}
+
+// Fees implements FeeStrategy
+func (s cappedFees) Fees(ctx context.Context, chain FeeReader) (*big.Int, *big.Int, error) {
+	maxFee, tip, err := s.FeeStrategy.Fees(ctx, chain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	baseFee, err := nextBaseFee(ctx, chain)
+	if err != nil {
+		return nil, nil, err
+	} else if baseFee.Cmp(s.limit) > 0 {
+		return nil, nil, fmt.Errorf("%w: base fee %s wei, cap %s wei", ErrFeeTooHigh, baseFee, s.limit)
+	}
+
+	if maxFee.Cmp(s.limit) > 0 {
+		maxFee = new(big.Int).Set(s.limit)
+	}
+	if tip.Cmp(maxFee) > 0 {
+		tip = new(big.Int).Set(maxFee)
+	}
+	return maxFee, tip, nil
+}
+
+// gwei returns the passed number of gwei in wei.
+func gwei(amount float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), big.NewFloat(1e9)).Int(nil)
+	return wei
+}
+
+// ether returns the passed amount of the chain's native token in wei.
+func ether(amount float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), big.NewFloat(1e18)).Int(nil)
+	return wei
+}
+
+// feeStrategyFromEnv returns the fee strategy named by GAS_FEE_STRATEGY, capped
+// at GAS_FEE_CAP gwei if it is set. A nil strategy means that the fees are left
+// to the transaction library, as they were before fees were configurable.
+func feeStrategyFromEnv() (FeeStrategy, error) {
+	var strategy FeeStrategy
+	switch name := os.Getenv("GAS_FEE_STRATEGY"); name {
+	case "", FeeStrategySuggested:
+	case FeeStrategyStatic:
+		maxFee, err := floatFromEnv("GAS_MAX_FEE_PER_GAS")
+		if err != nil {
+			return nil, err
+		}
+		tip, err := floatFromEnv("GAS_MAX_PRIORITY_FEE_PER_GAS")
+		if err != nil {
+			return nil, err
+		}
+		strategy = StaticFees{MaxFee: gwei(maxFee), Tip: gwei(tip)}
+	case FeeStrategyPercentile:
+		percentile, err := floatFromEnv("GAS_FEE_PERCENTILE")
+		if err != nil {
+			return nil, err
+		}
+		strategy = PercentileFees{Percentile: percentile}
+	default:
+		return nil, fmt.Errorf("GAS_FEE_STRATEGY: unknown strategy %q", name)
+	}
+
+	limit, err := floatFromEnv("GAS_FEE_CAP")
+	if err != nil {
+		return nil, err
+	} else if limit > 0 {
+		if strategy == nil {
+			strategy = SuggestedFees
+		}
+		strategy = WithFeeCap(strategy, gwei(limit))
+	}
+	return strategy, nil
+}
+
+// floatFromEnv returns the number in the passed environment variable, or zero
+// if it isn't set.
+func floatFromEnv(env string) (float64, error) {
+	str := os.Getenv(env)
+	if str == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", env, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFeeReader struct {
+	baseFee *big.Int
+	tips    []int64
+}
+
+func (m mockFeeReader) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(2), nil
+}
+
+func (m mockFeeReader) FeeHistory(ctx context.Context, blocks uint64, last *big.Int, percentiles []float64) (*ethereum.FeeHistory, error) {
+	history := &ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(1), m.baseFee}}
+	if len(percentiles) > 0 {
+		for _, tip := range m.tips {
+			history.Reward = append(history.Reward, []*big.Int{big.NewInt(tip)})
+		}
+	}
+	return history, nil
+}
+
+func TestFeeStrategies(t *testing.T) {
+	ctx := context.Background()
+	chain := mockFeeReader{baseFee: big.NewInt(100), tips: []int64{5, 1, 9, 3, 7}}
+
+	maxFee, tip, err := SuggestedFees.Fees(ctx, chain)
+	require.NoError(t, err)
+	require.Equal(t, int64(202), maxFee.Int64())
+	require.Equal(t, int64(2), tip.Int64())
+
+	maxFee, tip, err = PercentileFees{Percentile: 50}.Fees(ctx, chain)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), tip.Int64(), "the median tip should be offered")
+	require.Equal(t, int64(205), maxFee.Int64())
+
+	maxFee, tip, err = StaticFees{MaxFee: gwei(30), Tip: gwei(1.5)}.Fees(ctx, chain)
+	require.NoError(t, err)
+	require.Equal(t, int64(30_000_000_000), maxFee.Int64())
+	require.Equal(t, int64(1_500_000_000), tip.Int64())
+}
+
+func TestFeeCap(t *testing.T) {
+	ctx := context.Background()
+	chain := mockFeeReader{baseFee: big.NewInt(100)}
+
+	maxFee, tip, err := WithFeeCap(StaticFees{MaxFee: big.NewInt(500), Tip: big.NewInt(400)}, big.NewInt(150)).Fees(ctx, chain)
+	require.NoError(t, err)
+	require.Equal(t, int64(150), maxFee.Int64())
+	require.Equal(t, int64(150), tip.Int64())
+
+	_, _, err = WithFeeCap(SuggestedFees, big.NewInt(50)).Fees(ctx, chain)
+	require.ErrorIs(t, err, ErrFeeTooHigh)
+}
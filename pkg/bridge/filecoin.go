@@ -0,0 +1,142 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// A DealMaker makes Filecoin storage deals, so that results are kept for the
+// long term rather than only for as long as someone pins them.
+type DealMaker interface {
+	// MakeDeal proposes a storage deal for the CID, returning the ID of the
+	// deal.
+	MakeDeal(ctx context.Context, result cid.Cid) (string, error)
+}
+
+// Filecoin measures time in epochs of thirty seconds.
+const filecoinEpoch = 30 * time.Second
+
+// The shortest and longest deals that Filecoin storage providers accept.
+const (
+	MinDealDuration = 180 * 24 * time.Hour
+	MaxDealDuration = 540 * 24 * time.Hour
+)
+
+var defaultDealTimeout = 5 * time.Minute
+
+type dealMakingAPI struct {
+	endpoint string
+	token    string
+	epochs   int64
+	client   *http.Client
+}
+
+// NewDealMakingAPI returns a DealMaker that proposes deals lasting for the
+// passed duration through the deal-making API at the endpoint, using the
+// passed access token. The API is sent
+//
+//	POST <endpoint>/deals {"cid": "<cid>", "duration": <epochs>}
+//
+// and must reply with {"dealId": "<id>"}.
+func NewDealMakingAPI(endpoint, token string, duration time.Duration) DealMaker {
+	return &dealMakingAPI{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+		epochs:   int64(duration / filecoinEpoch),
+		client:   http.DefaultClient,
+	}
+}
+
+// MakeDeal implements DealMaker
+func (d *dealMakingAPI) MakeDeal(ctx context.Context, result cid.Cid) (string, error) {
+	body, err := json.Marshal(map[string]any{"cid": result.String(), "duration": d.epochs})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint+"/deals", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.token)
+	}
+
+	var response struct {
+		DealID json.Number `json:"dealId"`
+	}
+	if err = doJSONRequest(d.client, req, &response); err != nil {
+		return "", fmt.Errorf("deal-making API: %w", err)
+	} else if response.DealID == "" {
+		return "", errors.New("deal-making API did not return a deal ID")
+	}
+	return response.DealID.String(), nil
+}
+
+var _ DealMaker = (*dealMakingAPI)(nil)
+
+// WithDealMaker makes the workflow make a Filecoin storage deal for the result
+// of each order that asks for durable storage. Otherwise such orders are
+// rejected.
+func WithDealMaker(deals DealMaker) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Deals = deals
+	}
+}
+
+// checkDurability rejects orders that ask for durable storage, unless the
+// workflow can make storage deals.
+func (workflow *Workflow) checkDurability(e ContractSubmittedEvent) error {
+	if e.DurableStorage() && workflow.Deals == nil {
+		return reject("storage deals can't be made by this bridge")
+	}
+	return nil
+}
+
+// makeDeal makes a storage deal for the result of the completed order, if it
+// asked for durable storage and the deal hasn't been made already. Encrypted
+// results are stored as the encrypted copy, which is what the order's creator
+// is given. If the deal can't be made the order is held, and the result is the
+// event to carry on with, if any.
+func (workflow *Workflow) makeDeal(ctx context.Context, event BacalhauJobCompletedEvent) (result Event, wait time.Duration, held bool) {
+	if !event.DurableStorage() || event.DealID() != "" {
+		return nil, 0, false
+	}
+
+	stored := event.Result(ctx)
+	if encrypted := event.EncryptedResult(ctx); encrypted.Defined() {
+		stored = encrypted
+	}
+
+	dealID, err := workflow.proposeDeal(ctx, stored)
+	if err != nil {
+		err = fmt.Errorf("making storage deal: %w", err)
+		result, wait = workflow.settle(ctx, event, nil, 0, err)
+		return result, wait, true
+	}
+
+	event.WithDealID(dealID)
+	dealsTotal.Inc()
+	log.Ctx(ctx).Info().Stringer("cid", stored).Str("deal", dealID).Msg("Made storage deal for result")
+	return nil, 0, false
+}
+
+func (workflow *Workflow) proposeDeal(ctx context.Context, result cid.Cid) (string, error) {
+	if workflow.Deals == nil {
+		return "", errors.New("storage deals can't be made by this bridge")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultDealTimeout)
+	defer cancel()
+	return workflow.Deals.MakeDeal(ctx, result)
+}
@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var ErrLowBalance = errors.New("wallet balance below minimum")
+
+// A FundsMonitor keeps track of the bridge wallet's balance, and pauses
+// on-chain posting whilst it is below the minimum so that the last of the
+// funds aren't spent on transactions that run out of gas. Jobs keep running
+// whilst posting is paused, and their results are posted once the wallet is
+// topped up.
+type FundsMonitor struct {
+	minimum  *big.Int
+	interval time.Duration
+
+	mu      sync.Mutex
+	balance *big.Int
+	paused  bool
+}
+
+// NewFundsMonitor returns a FundsMonitor that checks the balance every
+// interval, and pauses posting whilst it is below minimum wei. If minimum is
+// nil, the balance is tracked but posting is never paused.
+func NewFundsMonitor(minimum *big.Int, interval time.Duration) *FundsMonitor {
+	if minimum != nil && minimum.Sign() <= 0 {
+		minimum = nil
+	}
+	return &FundsMonitor{minimum: minimum, interval: interval}
+}
+
+// WithFundsMonitor makes the contract stop sending transactions whilst the
+// monitor says the wallet is low on funds.
+func WithFundsMonitor(funds *FundsMonitor) ContractOption {
+	return func(opts *contractOptions) {
+		opts.funds = funds
+	}
+}
+
+// Run checks the wallet's balance until the context is cancelled.
+func (m *FundsMonitor) Run(ctx context.Context, wallet BalanceReporter) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		balance, err := wallet.Balance(ctx)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to check wallet balance")
+		} else {
+			m.Update(ctx, balance)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Update records the wallet's balance, pausing or resuming posting if it has
+// crossed the minimum.
+func (m *FundsMonitor) Update(ctx context.Context, balance *big.Int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.balance = balance
+	tokens, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(1e18)).Float64()
+	walletBalance.Set(tokens)
+
+	paused := m.minimum != nil && balance.Cmp(m.minimum) < 0
+	if paused && !m.paused {
+		log.Ctx(ctx).Error().
+			Stringer("balance", balance).
+			Stringer("minimum", m.minimum).
+			Msg("Wallet balance below minimum, pausing on-chain posting until it is topped up")
+	} else if !paused && m.paused {
+		log.Ctx(ctx).Info().Stringer("balance", balance).Msg("Wallet topped up, resuming on-chain posting")
+	}
+	m.paused = paused
+	if paused {
+		postingPausedForFunds.Set(1)
+	} else {
+		postingPausedForFunds.Set(0)
+	}
+}
+
+// Balance returns the wallet's balance when it was last checked, or nil if it
+// hasn't been yet.
+func (m *FundsMonitor) Balance() *big.Int {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.balance
+}
+
+// Check returns ErrLowBalance if the wallet's balance was below the minimum
+// when it was last checked, and so no transactions should be sent.
+func (m *FundsMonitor) Check() error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.paused {
+		return nil
+	}
+	return fmt.Errorf("%w: %s of %s wei", ErrLowBalance, m.balance, m.minimum)
+}
+
+// postingPaused returns whether the error means that on-chain posting is
+// paused rather than broken, so that the order should wait without using up
+// any of its attempts.
+func postingPaused(err error) bool {
+	return errors.Is(err, ErrGasBudgetExceeded) || errors.Is(err, ErrLowBalance)
+}
@@ -0,0 +1,31 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFundsMonitorPausesPostingWhilstLow(t *testing.T) {
+	ctx := context.Background()
+	funds := NewFundsMonitor(big.NewInt(100), time.Minute)
+	require.NoError(t, funds.Check(), "an unchecked balance shouldn't pause posting")
+
+	funds.Update(ctx, big.NewInt(99))
+	require.ErrorIs(t, funds.Check(), ErrLowBalance)
+	require.True(t, postingPaused(funds.Check()))
+	require.Equal(t, big.NewInt(99), funds.Balance())
+
+	funds.Update(ctx, big.NewInt(100))
+	require.NoError(t, funds.Check())
+
+	untracked := NewFundsMonitor(nil, time.Minute)
+	untracked.Update(ctx, big.NewInt(0))
+	require.NoError(t, untracked.Check(), "no minimum should mean never pausing")
+
+	var off *FundsMonitor
+	require.NoError(t, off.Check())
+}
@@ -0,0 +1,203 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// A GasSpend is what the bridge's transactions cost on one day.
+type GasSpend struct {
+	// The day, in UTC, as YYYY-MM-DD.
+	Day          string   `json:"day"`
+	Transactions int      `json:"transactions"`
+	GasUsed      uint64   `json:"gasUsed"`
+	Cost         *big.Int `json:"cost"`
+
+	// The most that can be spent on the day, if there is a budget.
+	Budget *big.Int `json:"budget,omitempty"`
+}
+
+// A GasSpendStore records what each of the bridge's transactions cost, so that
+// the daily spend survives a restart.
+type GasSpendStore interface {
+	// RecordGasSpend records what a mined transaction cost, in wei. Recording
+	// a transaction a second time does nothing.
+	RecordGasSpend(ctx context.Context, txn common.Hash, day string, gasUsed uint64, cost *big.Int) error
+
+	// GasSpend returns the total cost of the transactions mined on the day.
+	GasSpend(ctx context.Context, day string) (GasSpend, error)
+}
+
+var ErrGasBudgetExceeded = errors.New("daily gas budget exceeded")
+
+// A GasBudget keeps track of what the bridge spends on gas each day, and stops
+// it sending transactions once a day's spending reaches the daily limit.
+type GasBudget struct {
+	daily *big.Int
+	store GasSpendStore
+
+	mu       sync.Mutex
+	today    GasSpend
+	exceeded bool
+}
+
+// NewGasBudget returns a GasBudget that allows up to daily wei to be spent each
+// day, or any amount if daily is nil. If the store is nil, the day's spending
+// is only kept in memory.
+func NewGasBudget(daily *big.Int, store GasSpendStore) *GasBudget {
+	return &GasBudget{daily: daily, store: store}
+}
+
+// GasBudgetFromEnv returns a GasBudget that allows GAS_DAILY_BUDGET of the
+// chain's native token to be spent each day, or any amount if it isn't set.
+func GasBudgetFromEnv(store GasSpendStore) (*GasBudget, error) {
+	daily, err := floatFromEnv("GAS_DAILY_BUDGET")
+	if err != nil {
+		return nil, err
+	} else if daily <= 0 {
+		return NewGasBudget(nil, store), nil
+	}
+	return NewGasBudget(ether(daily), store), nil
+}
+
+func gasDay(at time.Time) string {
+	return at.UTC().Format("2006-01-02")
+}
+
+// load makes sure that today's spending is the one being kept track of,
+// reading what has already been spent from the store on a new day. It must be
+// called with the lock held.
+func (b *GasBudget) load(ctx context.Context) error {
+	day := gasDay(time.Now())
+	if b.today.Day == day {
+		return nil
+	}
+
+	spend := GasSpend{Day: day, Cost: new(big.Int)}
+	if b.store != nil {
+		var err error
+		if spend, err = b.store.GasSpend(ctx, day); err != nil {
+			return err
+		}
+	}
+	b.today = spend
+	b.exceeded = false
+	b.updateMetrics()
+	return nil
+}
+
+func (b *GasBudget) updateMetrics() {
+	spent, _ := new(big.Float).SetInt(b.today.Cost).Float64()
+	gasSpentToday.Set(spent)
+	if b.daily != nil && b.today.Cost.Cmp(b.daily) >= 0 {
+		gasBudgetExceeded.Set(1)
+	} else {
+		gasBudgetExceeded.Set(0)
+	}
+}
+
+// Check returns ErrGasBudgetExceeded if today's spending has reached the daily
+// budget, and so no more transactions should be sent until tomorrow.
+func (b *GasBudget) Check(ctx context.Context) error {
+	if b.daily == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.load(ctx); err != nil {
+		return err
+	}
+
+	if b.today.Cost.Cmp(b.daily) < 0 {
+		return nil
+	}
+	if !b.exceeded {
+		// Only shout about it once a day, as every order waiting to be
+		// posted will be told the same thing.
+		b.exceeded = true
+		log.Ctx(ctx).Error().
+			Stringer("spent", b.today.Cost).
+			Stringer("budget", b.daily).
+			Msg("Daily gas budget exceeded, pausing on-chain posting until tomorrow")
+	}
+	return fmt.Errorf("%w: spent %s of %s wei today", ErrGasBudgetExceeded, b.today.Cost, b.daily)
+}
+
+// Record adds what the transaction with the passed receipt cost to the
+// spending of the day it was mined, which is taken to be today.
+func (b *GasBudget) Record(ctx context.Context, txn *types.Transaction, receipt *types.Receipt) error {
+	price := receipt.EffectiveGasPrice
+	if price == nil {
+		// The most the transaction could have cost.
+		price = txn.GasPrice()
+	}
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), price)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.load(ctx); err != nil {
+		return err
+	}
+	if b.store != nil {
+		err := b.store.RecordGasSpend(ctx, receipt.TxHash, b.today.Day, receipt.GasUsed, cost)
+		if err != nil {
+			return err
+		}
+	}
+
+	b.today.Transactions++
+	b.today.GasUsed += receipt.GasUsed
+	b.today.Cost = new(big.Int).Add(b.today.Cost, cost)
+	gasUsed.Add(float64(receipt.GasUsed))
+	b.updateMetrics()
+	return nil
+}
+
+// Today returns what has been spent so far today.
+func (b *GasBudget) Today(ctx context.Context) (GasSpend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.load(ctx); err != nil {
+		return GasSpend{}, err
+	}
+
+	spend := b.today
+	spend.Cost = new(big.Int).Set(b.today.Cost)
+	if b.daily != nil {
+		spend.Budget = new(big.Int).Set(b.daily)
+	}
+	return spend, nil
+}
+
+// recordSpend adds what a mined transaction cost to the gas budget. Any of the
+// versions of the transaction that were sent might be the one that was mined.
+func (r *realContract) recordSpend(ctx context.Context, pending *pendingTransaction) {
+	if r.budget == nil {
+		return
+	}
+
+	for i := len(pending.sent) - 1; i >= 0; i-- {
+		txn := pending.sent[i]
+		receipt, err := r.client.TransactionReceipt(ctx, txn.Hash())
+		if err != nil {
+			continue
+		}
+
+		err = r.budget.Record(ctx, txn, receipt)
+		log.Ctx(ctx).WithLevel(level(err)).Err(err).
+			Stringer("txn", txn.Hash()).
+			Uint64("gasUsed", receipt.GasUsed).
+			Msg("Recording gas spent")
+		return
+	}
+	log.Ctx(ctx).Warn().Uint64("nonce", pending.txn.Nonce()).Msg("Unable to find which transaction was mined, its gas won't be counted")
+}
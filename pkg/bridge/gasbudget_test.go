@@ -0,0 +1,47 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasBudget(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(GasSpendStore)
+	budget := NewGasBudget(big.NewInt(1_000_000), store)
+	txn := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(20)})
+
+	require.NoError(t, budget.Check(ctx))
+	require.NoError(t, budget.Record(ctx, txn, &types.Receipt{
+		TxHash:            common.HexToHash("0x01"),
+		GasUsed:           21000,
+		EffectiveGasPrice: big.NewInt(10),
+	}))
+	require.NoError(t, budget.Check(ctx), "spending under the budget should be allowed")
+
+	require.NoError(t, budget.Record(ctx, txn, &types.Receipt{
+		TxHash:  common.HexToHash("0x02"),
+		GasUsed: 50000,
+	}))
+	require.ErrorIs(t, budget.Check(ctx), ErrGasBudgetExceeded)
+
+	spend, err := budget.Today(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, spend.Transactions)
+	require.Equal(t, uint64(71000), spend.GasUsed)
+	require.Equal(t, big.NewInt(1_210_000), spend.Cost, "receipts without a price should be charged at the most they could cost")
+	require.Equal(t, big.NewInt(1_000_000), spend.Budget)
+
+	restarted := NewGasBudget(big.NewInt(2_000_000), store)
+	require.NoError(t, restarted.Check(ctx))
+	spend, err = restarted.Today(ctx)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1_210_000), spend.Cost, "spending should survive a restart")
+
+	require.NoError(t, NewGasBudget(nil, nil).Check(ctx), "no budget should mean no limit")
+}
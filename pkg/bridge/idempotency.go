@@ -0,0 +1,135 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// A Submission records the job that was created for one attempt at an order.
+type Submission struct {
+	OrderID       string
+	Resubmissions uint
+	JobID         string
+	Endpoint      string
+	Time          time.Time
+}
+
+// A SubmissionStore remembers which jobs have been created for which orders,
+// so that an order that is seen more than once is only ever submitted once.
+type SubmissionStore interface {
+	// RecordSubmission saves the submission. If one has already been saved for
+	// the same attempt at the order, the existing one is kept.
+	RecordSubmission(ctx context.Context, s Submission) error
+
+	// Submission returns the submission made for the passed attempt at the
+	// order, if there has been one.
+	Submission(ctx context.Context, orderID string, resubmissions uint) (Submission, bool, error)
+}
+
+// orderLocks serialises work on the same order, whilst allowing different
+// orders to proceed in parallel.
+type orderLocks struct {
+	mu    sync.Mutex
+	locks map[string]*orderLock
+}
+
+type orderLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// lock waits until no one else is working on the passed order, and returns a
+// function that must be called once the work is finished.
+func (l *orderLocks) lock(orderID string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*orderLock{}
+	}
+	lock, found := l.locks[orderID]
+	if !found {
+		lock = &orderLock{}
+		l.locks[orderID] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(l.locks, orderID)
+		}
+	}
+}
+
+// create submits a job for the passed order, unless one has already been
+// submitted for this attempt at the order, in which case the existing job is
+// returned instead. If an identical job has completed recently, the order is
+// returned completed with its result.
+func (workflow *Workflow) create(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+	if err := workflow.expandTemplate(e); err != nil {
+		return nil, err
+	}
+	if err := workflow.checkEncryption(e); err != nil {
+		return nil, err
+	}
+	if err := workflow.checkDurability(e); err != nil {
+		return nil, err
+	}
+	if err := workflow.checkPrice(ctx, e); err != nil {
+		return nil, err
+	}
+	if err := workflow.checkInputs(ctx, e); err != nil {
+		return nil, err
+	}
+	if cached := workflow.cachedJob(ctx, e); cached != nil {
+		return cached, nil
+	}
+	if err := workflow.Chaos.disconnected(ctx); err != nil {
+		return nil, err
+	}
+
+	if workflow.Submissions == nil {
+		return workflow.Bacalhau.Create(ctx, e)
+	}
+
+	orderID := e.OrderId().Hex()
+	defer workflow.orderLocks.lock(orderID)()
+
+	existing, found, err := workflow.Submissions.Submission(ctx, orderID, e.Resubmissions())
+	if err != nil {
+		return nil, err
+	} else if found {
+		log.Ctx(ctx).Warn().Str("job", existing.JobID).Msg("Order already submitted, skipping duplicate")
+		duplicateSubmissions.Inc()
+
+		job := model.NewJob()
+		job.Metadata.ID = existing.JobID
+		return e.JobCreated(job).WithEndpoint(existing.Endpoint), nil
+	}
+
+	result, err := workflow.Bacalhau.Create(ctx, e)
+	if err != nil || result == nil {
+		return result, err
+	}
+
+	// The job has been created whether or not we manage to record it, so
+	// failing here isn't a reason to fail the submission.
+	recordErr := workflow.Submissions.RecordSubmission(ctx, Submission{
+		OrderID:       orderID,
+		Resubmissions: result.Resubmissions(),
+		JobID:         result.JobID(),
+		Endpoint:      result.Endpoint(),
+		Time:          time.Now().UTC(),
+	})
+	log.Ctx(ctx).WithLevel(level(recordErr)).Err(recordErr).Str("job", result.JobID()).Msg("Recording submission")
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// An InputChecker finds out whether the inputs of a job can be retrieved
+// before it is submitted, as jobs whose inputs can't be found only fail once
+// they have waited for them on the compute network.
+type InputChecker interface {
+	// Available returns ErrInputUnavailable if the CID can't be retrieved, or
+	// another error if it couldn't be found out.
+	Available(ctx context.Context, input cid.Cid) error
+}
+
+var ErrInputUnavailable = errors.New("input is not available on IPFS")
+
+var defaultInputCheckTimeout = 30 * time.Second
+
+type gatewayInputChecker struct {
+	gateway string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewGatewayInputChecker returns an InputChecker that asks the passed IPFS
+// HTTP gateway for the root of each input. Inputs that the gateway can't find
+// within the timeout are taken to be unavailable.
+func NewGatewayInputChecker(gateway string, timeout time.Duration) InputChecker {
+	if timeout <= 0 {
+		timeout = defaultInputCheckTimeout
+	}
+	return &gatewayInputChecker{gateway: gateway, timeout: timeout, client: http.DefaultClient}
+}
+
+// Available implements InputChecker
+func (g *gatewayInputChecker) Available(ctx context.Context, input cid.Cid) error {
+	probeCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	// Only the root block is needed to know that the input can be found, and
+	// asking for it raw stops the gateway from walking a directory.
+	url := fmt.Sprintf("%s/ipfs/%s?format=raw", g.gateway, input)
+	req, err := http.NewRequestWithContext(probeCtx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+	res, err := g.client.Do(req)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("%s: %w: not found within %s", input, ErrInputUnavailable, g.timeout)
+	} else if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return nil
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("%s: %w: gateway returned %s", input, ErrInputUnavailable, res.Status)
+	default:
+		return fmt.Errorf("checking %s: gateway returned %s", input, res.Status)
+	}
+}
+
+var _ InputChecker = (*gatewayInputChecker)(nil)
+
+// WithInputChecker makes the workflow check that the IPFS inputs of each job
+// can be retrieved before submitting it, failing orders whose inputs can't be.
+func WithInputChecker(checker InputChecker) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Inputs = checker
+	}
+}
+
+// inputCIDs returns the CIDs of everything the job will fetch from IPFS.
+func inputCIDs(spec model.Spec) ([]cid.Cid, error) {
+	storage := spec.Inputs
+	if spec.Engine == model.EngineWasm {
+		storage = append(storage, spec.Wasm.EntryModule)
+		storage = append(storage, spec.Wasm.ImportModules...)
+	}
+
+	inputs := make([]cid.Cid, 0, len(storage))
+	for _, s := range storage {
+		if s.CID == "" {
+			continue
+		}
+		input, err := cid.Parse(s.CID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CID %q: %w", s.CID, err)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// checkInputs rejects orders with inputs that can't be retrieved from IPFS,
+// if the workflow has been configured to check them.
+func (workflow *Workflow) checkInputs(ctx context.Context, e ContractSubmittedEvent) error {
+	if workflow.Inputs == nil {
+		return nil
+	}
+
+	spec, err := e.Spec()
+	if err != nil {
+		// The runner refuses specs it can't read, with a better error.
+		return nil
+	}
+	inputs, err := inputCIDs(spec)
+	if err != nil {
+		return nil
+	}
+
+	for _, input := range inputs {
+		err := workflow.Inputs.Available(ctx, input)
+		if errors.Is(err, ErrInputUnavailable) {
+			log.Ctx(ctx).Warn().Err(err).Stringer("cid", input).Msg("Job input is not available")
+			inputsUnavailable.Inc()
+			return &Rejection{Reason: err.Error(), FailureReason: FailureReasonInputUnavailable}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayInputChecker(t *testing.T) {
+	available := exampleResult.String()
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ipfs/"+available {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer gateway.Close()
+
+	ctx := context.Background()
+	checker := NewGatewayInputChecker(gateway.URL, 100*time.Millisecond)
+	require.NoError(t, checker.Available(ctx, exampleResult))
+
+	missing, err := exampleResult.Prefix().Sum([]byte("missing"))
+	require.NoError(t, err)
+	require.ErrorIs(t, checker.Available(ctx, missing), ErrInputUnavailable)
+}
+
+func TestOrdersWithUnavailableInputsAreRejected(t *testing.T) {
+	gateway := httptest.NewServer(http.NotFoundHandler())
+	defer gateway.Close()
+
+	spec := fastSpec
+	spec.Inputs = []model.StorageSpec{{StorageSource: model.StorageSourceIPFS, CID: exampleResult.String(), Path: "/inputs"}}
+	specJSON, err := json.Marshal(spec)
+	require.NoError(t, err)
+	e := exampleEvent()
+	e.(*event).jobSpec = specJSON
+
+	workflow := NewWorkflow(&mockRunner{CreateHandler: SuccessfulCreate}, &mockContract{}, repository(t),
+		WithInputChecker(NewGatewayInputChecker(gateway.URL, time.Second)),
+	)
+	_, err = workflow.create(context.Background(), e)
+
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	require.Equal(t, FailureReasonInputUnavailable, rejection.FailureReason)
+	require.Contains(t, err.Error(), exampleResult.String())
+}
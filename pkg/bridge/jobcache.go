@@ -0,0 +1,147 @@
+package bridge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// LilypadNoCacheAnnotation, if amongst the annotations of an order's spec,
+// makes the order always run its own job rather than being served the result
+// of an identical one.
+const LilypadNoCacheAnnotation string = "lilypad-no-cache"
+
+// A CachedJob is a job that completed, remembered so that identical orders
+// that arrive soon after can be given its result rather than run again.
+type CachedJob struct {
+	// The hash of the spec that the job ran, which includes its inputs.
+	SpecHash string
+	JobID    string
+	Endpoint string
+	Results  []string
+	StdOut   string
+	StdErr   string
+	ExitCode int
+	Time     time.Time
+}
+
+// A JobCache remembers the most recent completed job for each spec.
+type JobCache interface {
+	// CacheJob saves the job, replacing any saved for the same spec.
+	CacheJob(ctx context.Context, job CachedJob) error
+
+	// CachedJob returns the job saved for the spec, if it finished at or
+	// after the passed time.
+	CachedJob(ctx context.Context, specHash string, since time.Time) (CachedJob, bool, error)
+}
+
+// WithJobCache makes the workflow serve orders for the same spec as a job that
+// completed within the TTL from that job's result, rather than running it
+// again.
+func WithJobCache(cache JobCache, ttl time.Duration) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.JobCache = cache
+		workflow.jobCacheTTL = ttl
+	}
+}
+
+// specHash returns the hash of the order's spec, or false if the order opted
+// out of the cache or its spec can't be read.
+func specHash(e ContractSubmittedEvent) (string, bool) {
+	spec, err := e.Spec()
+	if err != nil {
+		return "", false
+	}
+	for _, annotation := range spec.Annotations {
+		if annotation == LilypadNoCacheAnnotation {
+			return "", false
+		}
+	}
+
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256(encoded)
+	return hex.EncodeToString(hash[:]), true
+}
+
+// cachedJob returns the order completed with the result of an identical job
+// that completed within the TTL, or nil if there isn't one.
+func (workflow *Workflow) cachedJob(ctx context.Context, e ContractSubmittedEvent) BacalhauJobCompletedEvent {
+	if workflow.JobCache == nil {
+		return nil
+	}
+	hash, ok := specHash(e)
+	if !ok {
+		return nil
+	}
+
+	cached, found, err := workflow.JobCache.CachedJob(ctx, hash, time.Now().Add(-workflow.jobCacheTTL))
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to look up cached job")
+		jobCacheLookups.WithLabelValues("error").Inc()
+		return nil
+	} else if !found || len(cached.Results) == 0 {
+		jobCacheLookups.WithLabelValues("miss").Inc()
+		return nil
+	}
+
+	results := make([]cid.Cid, 0, len(cached.Results))
+	for _, result := range cached.Results {
+		parsed, err := cid.Parse(result)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("job", cached.JobID).Msg("Ignoring cached job with an invalid result")
+			return nil
+		}
+		results = append(results, parsed)
+	}
+
+	log.Ctx(ctx).Info().Str("job", cached.JobID).Time("finished", cached.Time).Msg("Serving order from the result of an identical job")
+	jobCacheLookups.WithLabelValues("hit").Inc()
+
+	job := model.NewJob()
+	job.Metadata.ID = cached.JobID
+	return e.JobCreated(job).
+		WithEndpoint(cached.Endpoint).
+		Completed(results[0], cached.StdOut, cached.StdErr, cached.ExitCode).
+		WithResults(results)
+}
+
+// cacheJob remembers the completed job, so that identical orders can be
+// served its result.
+func (workflow *Workflow) cacheJob(ctx context.Context, e BacalhauJobCompletedEvent) {
+	if workflow.JobCache == nil {
+		return
+	}
+	hash, ok := specHash(e)
+	if !ok {
+		return
+	}
+
+	published := e.Results(ctx)
+	if len(published) == 0 {
+		published = []cid.Cid{e.Result(ctx)}
+	}
+	results := make([]string, 0, len(published))
+	for _, result := range published {
+		results = append(results, result.String())
+	}
+	err := workflow.JobCache.CacheJob(ctx, CachedJob{
+		SpecHash: hash,
+		JobID:    e.JobID(),
+		Endpoint: e.Endpoint(),
+		Results:  results,
+		StdOut:   e.StdOut(),
+		StdErr:   e.StdErr(),
+		ExitCode: e.ExitCode(),
+		Time:     time.Now().UTC(),
+	})
+	log.Ctx(ctx).WithLevel(level(err)).Err(err).Str("job", e.JobID()).Msg("Caching completed job")
+}
@@ -0,0 +1,364 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where the job cache can be kept.
+const (
+	JobCacheDatabase = "database"
+	JobCacheDisk     = "disk"
+	JobCacheRedis    = "redis"
+)
+
+// JobCacheNames returns the names of every place the job cache can be kept.
+func JobCacheNames() []string {
+	return []string{JobCacheDatabase, JobCacheDisk, JobCacheRedis}
+}
+
+type diskJobCache struct {
+	dir        string
+	maxEntries int
+
+	mu sync.Mutex
+}
+
+// NewDiskJobCache returns a JobCache that keeps each job in a file in the
+// directory. Once there are more than maxEntries, the jobs that were least
+// recently served or cached are evicted. Jobs older than the TTL they are
+// asked for with are evicted when they are next looked up.
+func NewDiskJobCache(dir string, maxEntries int) (JobCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &diskJobCache{dir: dir, maxEntries: maxEntries}, nil
+}
+
+func (d *diskJobCache) path(specHash string) (string, error) {
+	if _, err := hex.DecodeString(specHash); err != nil || specHash == "" {
+		return "", fmt.Errorf("invalid spec hash %q", specHash)
+	}
+	return filepath.Join(d.dir, specHash+".json"), nil
+}
+
+// CacheJob implements JobCache
+func (d *diskJobCache) CacheJob(ctx context.Context, job CachedJob) error {
+	path, err := d.path(job.SpecHash)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	temp, err := os.CreateTemp(d.dir, ".job-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+	return d.evict()
+}
+
+// CachedJob implements JobCache
+func (d *diskJobCache) CachedJob(ctx context.Context, specHash string, since time.Time) (CachedJob, bool, error) {
+	path, err := d.path(specHash)
+	if err != nil {
+		return CachedJob{}, false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return CachedJob{}, false, nil
+	} else if err != nil {
+		return CachedJob{}, false, err
+	}
+
+	var job CachedJob
+	if err = json.Unmarshal(data, &job); err != nil {
+		return CachedJob{}, false, err
+	}
+	if job.Time.Before(since) {
+		jobCacheEvictions.WithLabelValues("expired").Inc()
+		return CachedJob{}, false, os.Remove(path)
+	}
+
+	// Touch the file so that jobs that are being served are evicted last.
+	now := time.Now()
+	return job, true, os.Chtimes(path, now, now)
+}
+
+// evict removes the least recently used jobs until there are no more than the
+// most allowed. It must be called with the lock held.
+func (d *diskJobCache) evict() error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+
+	type cached struct {
+		name    string
+		touched time.Time
+	}
+	jobs := make([]cached, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, cached{name: entry.Name(), touched: info.ModTime()})
+	}
+
+	if d.maxEntries > 0 && len(jobs) > d.maxEntries {
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].touched.Before(jobs[j].touched) })
+		for _, job := range jobs[:len(jobs)-d.maxEntries] {
+			if err := os.Remove(filepath.Join(d.dir, job.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			jobCacheEvictions.WithLabelValues("size").Inc()
+		}
+		jobs = jobs[len(jobs)-d.maxEntries:]
+	}
+	jobCacheEntries.Set(float64(len(jobs)))
+	return nil
+}
+
+// The prefix of the keys that jobs are cached under in Redis.
+const redisJobCachePrefix = "lilypad:job-cache:"
+
+type redisJobCache struct {
+	addr     string
+	username string
+	password string
+	db       int
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisJobCache returns a JobCache that keeps jobs in the Redis server at
+// the URL, written as redis://[[user]:password@]host[:port][/db]. Jobs expire
+// from Redis after the TTL, and are otherwise evicted by the server's own
+// maxmemory policy.
+func NewRedisJobCache(redisURL string, ttl time.Duration) (JobCache, error) {
+	u, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "redis" {
+		return nil, fmt.Errorf("%q must be a redis:// URL", redisURL)
+	}
+
+	cache := &redisJobCache{addr: u.Host, ttl: ttl}
+	if u.Port() == "" {
+		cache.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		cache.username = u.User.Username()
+		cache.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if cache.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+	return cache, nil
+}
+
+// CacheJob implements JobCache
+func (r *redisJobCache) CacheJob(ctx context.Context, job CachedJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", redisJobCachePrefix + job.SpecHash, string(data)}
+	if r.ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(r.ttl.Milliseconds(), 10))
+	}
+	_, err = r.do(ctx, args...)
+	return err
+}
+
+// CachedJob implements JobCache
+func (r *redisJobCache) CachedJob(ctx context.Context, specHash string, since time.Time) (CachedJob, bool, error) {
+	reply, err := r.do(ctx, "GET", redisJobCachePrefix+specHash)
+	if err != nil || reply == nil {
+		return CachedJob{}, false, err
+	}
+
+	var job CachedJob
+	if err = json.Unmarshal([]byte(reply.(string)), &job); err != nil {
+		return CachedJob{}, false, err
+	}
+	return job, !job.Time.Before(since), nil
+}
+
+// Close implements io.Closer
+func (r *redisJobCache) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.reader = nil, nil
+	return err
+}
+
+// do sends the command to Redis, connecting first if need be, and returns its
+// reply. Any error other than one sent by Redis closes the connection, so
+// that the next command starts afresh.
+func (r *redisJobCache) do(ctx context.Context, args ...string) (reply any, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err = r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err = r.command(ctx, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		r.conn.Close()
+		r.conn, r.reader = nil, nil
+	}
+	return reply, err
+}
+
+func (r *redisJobCache) connect(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	r.conn, r.reader = conn, bufio.NewReader(conn)
+
+	if r.password != "" {
+		auth := []string{"AUTH", r.password}
+		if r.username != "" {
+			auth = []string{"AUTH", r.username, r.password}
+		}
+		_, err = r.command(ctx, auth...)
+	}
+	if err == nil && r.db != 0 {
+		_, err = r.command(ctx, "SELECT", strconv.Itoa(r.db))
+	}
+	if err != nil {
+		conn.Close()
+		r.conn, r.reader = nil, nil
+	}
+	return err
+}
+
+// command writes the command in the Redis protocol and reads the reply.
+func (r *redisJobCache) command(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := r.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var request strings.Builder
+	fmt.Fprintf(&request, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, request.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(r.reader)
+}
+
+// A redisError is an error reply sent by Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRedisReply reads one reply in the Redis protocol. Strings are returned
+// as strings, integers as int64s, arrays as []any and nil replies as nil.
+func readRedisReply(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("invalid Redis reply")
+	}
+
+	switch kind, rest := line[0], line[1:]; kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(rest)
+		if err != nil || length < 0 {
+			return nil, err
+		}
+		data := make([]byte, length+2)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(rest)
+		if err != nil || length < 0 {
+			return nil, err
+		}
+		items := make([]any, 0, length)
+		for i := 0; i < length; i++ {
+			item, err := readRedisReply(reader)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("invalid Redis reply %q", line)
+	}
+}
@@ -0,0 +1,186 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	cache := repository(t).(JobCache)
+
+	finished := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, cache.CacheJob(ctx, CachedJob{SpecHash: "abc", JobID: "first", Results: []string{exampleResult.String()}, Time: finished}))
+	require.NoError(t, cache.CacheJob(ctx, CachedJob{SpecHash: "abc", JobID: "second", Results: []string{exampleResult.String()}, ExitCode: 1, Time: finished}))
+
+	job, found, err := cache.CachedJob(ctx, "abc", finished.Add(-time.Minute))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "second", job.JobID)
+	require.Equal(t, 1, job.ExitCode)
+	require.Equal(t, []string{exampleResult.String()}, job.Results)
+
+	_, found, err = cache.CachedJob(ctx, "abc", finished.Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, found, "jobs older than the TTL shouldn't be served")
+}
+
+func TestIdenticalOrdersAreServedFromTheJobCache(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+
+	created := 0
+	runner := &mockRunner{
+		CreateHandler: func(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+			created++
+			return SuccessfulCreate(ctx, e)
+		},
+	}
+	workflow := NewWorkflow(runner, &mockContract{}, repo, WithJobCache(repo.(JobCache), time.Hour))
+
+	first, err := workflow.create(ctx, exampleEvent())
+	require.NoError(t, err)
+	require.Equal(t, OrderStateRunning, first.OrderState())
+	workflow.cacheJob(ctx, first.Completed(exampleResult, "out", "", 0))
+
+	second, err := workflow.create(ctx, exampleEvent())
+	require.NoError(t, err)
+	require.Equal(t, 1, created)
+	require.Equal(t, OrderStateCompleted, second.OrderState())
+	completed := second.(BacalhauJobCompletedEvent)
+	require.Equal(t, first.JobID(), completed.JobID())
+	require.Equal(t, exampleResult, completed.Result(context.Background()))
+	require.Equal(t, "out", completed.StdOut())
+
+	// Orders can opt out of being served someone else's result.
+	optOut := exampleEvent()
+	spec, err := optOut.Spec()
+	require.NoError(t, err)
+	spec.Annotations = append(spec.Annotations, LilypadNoCacheAnnotation)
+	raw, err := json.Marshal(spec)
+	require.NoError(t, err)
+	third, err := workflow.create(ctx, optOut.WithSpec(raw))
+	require.NoError(t, err)
+	require.Equal(t, 2, created)
+	require.Equal(t, OrderStateRunning, third.OrderState())
+}
+
+func TestDiskJobCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewDiskJobCache(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	since := time.Now().Add(-time.Hour)
+	for _, hash := range []string{"aa", "bb"} {
+		require.NoError(t, cache.CacheJob(ctx, CachedJob{SpecHash: hash, JobID: hash, Time: time.Now()}))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Serving aa makes bb the least recently used.
+	_, found, err := cache.CachedJob(ctx, "aa", since)
+	require.NoError(t, err)
+	require.True(t, found)
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, cache.CacheJob(ctx, CachedJob{SpecHash: "cc", JobID: "cc", Time: time.Now()}))
+
+	for hash, cached := range map[string]bool{"aa": true, "bb": false, "cc": true} {
+		_, found, err := cache.CachedJob(ctx, hash, since)
+		require.NoError(t, err)
+		require.Equal(t, cached, found, hash)
+	}
+
+	_, found, err = cache.CachedJob(ctx, "cc", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, found, "expired jobs shouldn't be served")
+
+	_, _, err = cache.CachedJob(ctx, "../secrets", since)
+	require.Error(t, err)
+}
+
+// fakeRedis serves GET and SET from a map, remembering the last SET.
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	lastSet []string
+}
+
+func (f *fakeRedis) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				request, err := readRedisReply(reader)
+				if err != nil {
+					return
+				}
+				args := []string{}
+				for _, arg := range request.([]any) {
+					args = append(args, arg.(string))
+				}
+
+				f.mu.Lock()
+				switch strings.ToUpper(args[0]) {
+				case "SET":
+					f.values[args[1]] = args[2]
+					f.lastSet = args
+					fmt.Fprint(conn, "+OK\r\n")
+				case "GET":
+					if value, ok := f.values[args[1]]; ok {
+						fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+					} else {
+						fmt.Fprint(conn, "$-1\r\n")
+					}
+				default:
+					fmt.Fprint(conn, "-ERR unknown command\r\n")
+				}
+				f.mu.Unlock()
+			}
+		}()
+	}
+}
+
+func TestRedisJobCache(t *testing.T) {
+	ctx := context.Background()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	server := &fakeRedis{values: map[string]string{}}
+	go server.serve(listener)
+
+	cache, err := NewRedisJobCache("redis://"+listener.Addr().String(), time.Minute)
+	require.NoError(t, err)
+	defer cache.(*redisJobCache).Close()
+
+	_, found, err := cache.CachedJob(ctx, "abc", time.Time{})
+	require.NoError(t, err)
+	require.False(t, found)
+
+	finished := time.Now().UTC()
+	require.NoError(t, cache.CacheJob(ctx, CachedJob{SpecHash: "abc", JobID: "job", Results: []string{exampleResult.String()}, Time: finished}))
+	server.mu.Lock()
+	require.Equal(t, []string{"PX", "60000"}, server.lastSet[3:])
+	server.mu.Unlock()
+
+	job, found, err := cache.CachedJob(ctx, "abc", finished.Add(-time.Second))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "job", job.JobID)
+
+	_, found, err = cache.CachedJob(ctx, "abc", finished.Add(time.Second))
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -0,0 +1,252 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// NewAWSKMSSigner returns a Signer that signs with an ECC_SECG_P256K1 key held
+// in AWS KMS, so that the key never leaves it. Credentials are read from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func NewAWSKMSSigner(ctx context.Context, keyID, region string) (Signer, error) {
+	credentials := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	endpoint := fmt.Sprintf("https://kms.%s.amazonaws.com/", region)
+	return newAWSKMSSigner(ctx, http.DefaultClient, endpoint, keyID, region, credentials)
+}
+
+func newAWSKMSSigner(ctx context.Context, client *http.Client, endpoint, keyID, region string, credentials awsCredentials) (Signer, error) {
+	call := func(ctx context.Context, action string, request, response any) error {
+		body, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService."+action)
+		signAWSRequest(req, body, region, "kms", credentials, time.Now())
+		return doJSONRequest(client, req, response)
+	}
+
+	var key struct{ PublicKey []byte }
+	if err := call(ctx, "GetPublicKey", map[string]string{"KeyId": keyID}, &key); err != nil {
+		return nil, err
+	}
+	publicKey, err := parsePublicKey(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteSigner{
+		address: crypto.PubkeyToAddress(*publicKey),
+		sign: func(ctx context.Context, digest []byte) ([]byte, error) {
+			var signed struct{ Signature []byte }
+			err := call(ctx, "Sign", map[string]any{
+				"KeyId":            keyID,
+				"Message":          digest,
+				"MessageType":      "DIGEST",
+				"SigningAlgorithm": "ECDSA_SHA_256",
+			}, &signed)
+			return signed.Signature, err
+		},
+	}, nil
+}
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signAWSRequest adds an AWS Signature Version 4 to the passed request.
+func signAWSRequest(req *http.Request, body []byte, region, service string, credentials awsCredentials, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	signAWSRequestHash(req, hex.EncodeToString(payloadHash[:]), region, service, credentials, now)
+}
+
+// signAWSRequestHash adds an AWS Signature Version 4 to the passed request,
+// whose body has the passed hex-encoded SHA-256 hash, so that large bodies
+// don't have to be held in memory to be signed.
+func signAWSRequestHash(req *http.Request, payloadHash string, region, service string, credentials awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := strings.Join([]string{amzDate[:8], region, service, "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	mac := func(key []byte, data string) []byte {
+		hash := hmac.New(sha256.New, key)
+		hash.Write([]byte(data))
+		return hash.Sum(nil)
+	}
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = mac(key, part)
+	}
+	signature := hex.EncodeToString(mac(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// The metadata server that gives the access token of a GCP service account.
+const gcpTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// NewGCPKMSSigner returns a Signer that signs with an EC_SIGN_SECP256K1_SHA256
+// key version held in GCP Cloud KMS, passed by its full resource name, so that
+// the key never leaves it. The access token is read from GCP_ACCESS_TOKEN, or
+// else from the metadata server of the instance the bridge is running on.
+func NewGCPKMSSigner(ctx context.Context, keyVersion string) (Signer, error) {
+	return newGCPKMSSigner(ctx, http.DefaultClient, "https://cloudkms.googleapis.com/v1/", keyVersion, gcpAccessToken)
+}
+
+func newGCPKMSSigner(ctx context.Context, client *http.Client, endpoint, keyVersion string, token func(context.Context, *http.Client) (string, error)) (Signer, error) {
+	call := func(ctx context.Context, method, path string, request, response any) error {
+		var body io.Reader
+		if request != nil {
+			encoded, err := json.Marshal(request)
+			if err != nil {
+				return err
+			}
+			body = bytes.NewReader(encoded)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, endpoint+path, body)
+		if err != nil {
+			return err
+		}
+		accessToken, err := token(ctx, client)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		return doJSONRequest(client, req, response)
+	}
+
+	var key struct{ PEM string }
+	if err := call(ctx, http.MethodGet, keyVersion+"/publicKey", nil, &key); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(key.PEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key for %s", keyVersion)
+	}
+	publicKey, err := parsePublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteSigner{
+		address: crypto.PubkeyToAddress(*publicKey),
+		sign: func(ctx context.Context, digest []byte) ([]byte, error) {
+			var signed struct{ Signature []byte }
+			request := map[string]any{"digest": map[string]string{"sha256": base64.StdEncoding.EncodeToString(digest)}}
+			err := call(ctx, http.MethodPost, keyVersion+":asymmetricSign", request, &signed)
+			return signed.Signature, err
+		},
+	}, nil
+}
+
+// gcpAccessToken returns GCP_ACCESS_TOKEN if it is set, or else the token of
+// the instance's service account.
+func gcpAccessToken(ctx context.Context, client *http.Client) (string, error) {
+	if token := os.Getenv("GCP_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = doJSONRequest(client, req, &token)
+	return token.AccessToken, err
+}
+
+// doJSONRequest sends the request and decodes the JSON response into the passed
+// value, returning the body of any error response as the error.
+func doJSONRequest(client *http.Client, req *http.Request, response any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL, resp.Status, bytes.TrimSpace(message))
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -0,0 +1,169 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// A LeaderElector decides which of several bridges sharing a database is the
+// leader, which is the only one that submits jobs and sends transactions.
+type LeaderElector interface {
+	// Lead blocks until this bridge becomes the leader, and then returns a
+	// context that is cancelled as soon as it stops being the leader, and a
+	// function to call to give up leadership once the work is done.
+	Lead(ctx context.Context) (context.Context, func(), error)
+}
+
+var ErrLeadershipLost = errors.New("no longer the leader")
+
+// RunAsLeader waits for this bridge to become the leader and then runs fn
+// until the passed context is cancelled. If leadership is lost before then, fn
+// is cancelled and ErrLeadershipLost is returned, so that the bridge can be
+// restarted as a standby rather than carrying on alongside the new leader.
+func RunAsLeader(ctx context.Context, elector LeaderElector, fn func(context.Context) error) error {
+	log.Ctx(ctx).Info().Msg("Waiting to become the leader")
+	leadCtx, resign, err := elector.Lead(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer resign()
+
+	log.Ctx(ctx).Info().Msg("Became the leader")
+	isLeader.Set(1)
+	defer isLeader.Set(0)
+
+	err = fn(leadCtx)
+	if ctx.Err() == nil && leadCtx.Err() != nil {
+		log.Ctx(ctx).Error().Msg("Lost leadership, stopping")
+		return ErrLeadershipLost
+	}
+	return err
+}
+
+type alwaysLeader struct{}
+
+// AlwaysLeader is the LeaderElector of a bridge that runs on its own, which is
+// always the leader.
+var AlwaysLeader LeaderElector = alwaysLeader{}
+
+// Lead implements LeaderElector
+func (alwaysLeader) Lead(ctx context.Context) (context.Context, func(), error) {
+	return ctx, func() {}, nil
+}
+
+// How often a standby tries to become the leader, and how often the leader
+// checks that it still is.
+var defaultLeaderInterval = 2 * time.Second
+
+type postgresElector struct {
+	db       *sql.DB
+	key      int64
+	interval time.Duration
+}
+
+// NewPostgresLeaderElector returns a LeaderElector that makes the leader the
+// bridge holding a Postgres advisory lock. The lock is specific to the passed
+// contract, so bridges for different contracts can share a database. The lock
+// is held for as long as the leader's database session, so if the leader dies
+// a standby takes over within a few seconds.
+func NewPostgresLeaderElector(dsn string, contract common.Address) (LeaderElector, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock belongs to a session, so make sure that closing a connection
+	// really does end its session rather than returning it to the pool.
+	db.SetMaxIdleConns(0)
+
+	hash := crypto.Keccak256(contract.Bytes())
+	return &postgresElector{
+		db:       db,
+		key:      int64(binary.BigEndian.Uint64(hash)),
+		interval: defaultLeaderInterval,
+	}, nil
+}
+
+// Lead implements LeaderElector
+func (e *postgresElector) Lead(ctx context.Context) (context.Context, func(), error) {
+	conn, err := e.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	checking := make(chan struct{})
+	go func() {
+		defer close(checking)
+		defer cancel()
+
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pingCtx, cancelPing := context.WithTimeout(leadCtx, e.interval)
+				err := conn.PingContext(pingCtx)
+				cancelPing()
+				if err != nil && leadCtx.Err() == nil {
+					log.Ctx(ctx).Error().Err(err).Msg("Lost connection holding the leader lock")
+					return
+				}
+			case <-leadCtx.Done():
+				return
+			}
+		}
+	}()
+
+	resign := func() {
+		cancel()
+		<-checking
+
+		// Let a standby take over straight away rather than when the
+		// session ends.
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", e.key)
+		conn.Close()
+	}
+	return leadCtx, resign, nil
+}
+
+// acquire returns a connection once it holds the advisory lock.
+func (e *postgresElector) acquire(ctx context.Context) (*sql.Conn, error) {
+	for {
+		conn, err := e.db.Conn(ctx)
+		if err == nil {
+			var locked bool
+			err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&locked)
+			if err == nil && locked {
+				return conn, nil
+			}
+			conn.Close()
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Unable to try for the leader lock")
+		}
+
+		select {
+		case <-time.After(e.interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close implements io.Closer
+func (e *postgresElector) Close() error {
+	return e.db.Close()
+}
+
+var _ LeaderElector = (*postgresElector)(nil)
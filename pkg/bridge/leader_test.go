@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type deposedLeader struct {
+	resigned bool
+}
+
+func (l *deposedLeader) Lead(ctx context.Context) (context.Context, func(), error) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	return leadCtx, func() { l.resigned = true }, nil
+}
+
+func TestRunAsLeader(t *testing.T) {
+	ran := false
+	err := RunAsLeader(context.Background(), AlwaysLeader, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, ran)
+
+	elector := &deposedLeader{}
+	err = RunAsLeader(context.Background(), elector, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	require.ErrorIs(t, err, ErrLeadershipLost)
+	require.True(t, elector.resigned)
+}
+
+func TestPostgresLeaderElection(t *testing.T) {
+	dsn, found := os.LookupEnv("POSTGRES_TEST_DSN")
+	if !found {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+
+	defaultLeaderInterval = 100 * time.Millisecond
+	contract := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	ctx := context.Background()
+
+	leader, err := NewPostgresLeaderElector(dsn, contract)
+	require.NoError(t, err)
+	standby, err := NewPostgresLeaderElector(dsn, contract)
+	require.NoError(t, err)
+
+	_, resign, err := leader.Lead(ctx)
+	require.NoError(t, err)
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, _, err = standby.Lead(waitCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "only one bridge should lead at once")
+
+	resign()
+	_, resign, err = standby.Lead(ctx)
+	require.NoError(t, err)
+	resign()
+}
@@ -0,0 +1,142 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// The account Ledger Live uses for the first Ethereum address on a device.
+const defaultLedgerPath = "m/44'/60'/0'/0/0"
+
+// How long a transaction waits for someone to confirm it on the device.
+var defaultLedgerTimeout = 2 * time.Minute
+
+var (
+	ErrLedgerNotFound    = errors.New("no Ledger is connected")
+	ErrLedgerUnavailable = errors.New("unable to use the Ledger, check it is unlocked with the Ethereum app open")
+	ErrLedgerTimeout     = errors.New("transaction was not confirmed on the Ledger in time")
+	ErrLedgerRejected    = errors.New("transaction was rejected on the Ledger")
+)
+
+type ledgerSigner struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+	timeout time.Duration
+
+	// The device shows one prompt at a time, so a transaction whose prompt
+	// timed out holds it until someone answers.
+	busy chan struct{}
+}
+
+// NewLedgerSigner returns a Signer that signs with the account at the passed
+// derivation path on the first Ledger connected over USB. Every transaction
+// has to be confirmed on the device within the passed timeout.
+func NewLedgerSigner(ctx context.Context, path string, timeout time.Duration) (Signer, error) {
+	derivation, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	hub, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLedgerUnavailable, err)
+	}
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, ErrLedgerNotFound
+	}
+
+	wallet := wallets[0]
+	if err := wallet.Open(""); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLedgerUnavailable, err)
+	}
+	account, err := wallet.Derive(derivation, true)
+	if err != nil {
+		wallet.Close()
+		return nil, fmt.Errorf("%w: %s", ErrLedgerUnavailable, err)
+	}
+
+	log.Ctx(ctx).Info().
+		Stringer("address", account.Address).
+		Str("path", path).
+		Str("device", wallet.URL().String()).
+		Msg("Signing with Ledger")
+	return newLedgerSigner(wallet, account, timeout), nil
+}
+
+func newLedgerSigner(wallet accounts.Wallet, account accounts.Account, timeout time.Duration) *ledgerSigner {
+	return &ledgerSigner{
+		wallet:  wallet,
+		account: account,
+		timeout: timeout,
+		busy:    make(chan struct{}, 1),
+	}
+}
+
+// Address implements Signer
+func (s *ledgerSigner) Address() common.Address {
+	return s.account.Address
+}
+
+// SignTransaction implements Signer
+func (s *ledgerSigner) SignTransaction(ctx context.Context, txn *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	select {
+	case s.busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, s.waitError(ctx)
+	}
+
+	type result struct {
+		txn *types.Transaction
+		err error
+	}
+	signed := make(chan result, 1)
+	go func() {
+		defer func() { <-s.busy }()
+		txn, err := s.wallet.SignTx(s.account, txn, chainID)
+		signed <- result{txn: txn, err: err}
+	}()
+
+	log.Ctx(ctx).Info().Stringer("txn", txn.Hash()).Msg("Waiting for the transaction to be confirmed on the Ledger")
+	select {
+	case result := <-signed:
+		if result.err != nil {
+			return nil, ledgerError(result.err)
+		}
+		return result.txn, nil
+	case <-ctx.Done():
+		return nil, s.waitError(ctx)
+	}
+}
+
+func (s *ledgerSigner) waitError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrLedgerTimeout, s.timeout)
+	}
+	return ctx.Err()
+}
+
+// ledgerError explains the errors the Ledger driver returns, which are mostly
+// about the state of the device rather than the transaction.
+func ledgerError(err error) error {
+	// A rejected transaction gets a status code but no signature.
+	if strings.Contains(err.Error(), "reply lacks signature") {
+		return ErrLedgerRejected
+	}
+	return fmt.Errorf("%w: %s", ErrLedgerUnavailable, err)
+}
+
+var _ Signer = (*ledgerSigner)(nil)
@@ -0,0 +1,60 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+)
+
+// A JobLogger is a JobRunner that can fetch the output of a job, so that
+// operators can see why an order failed without using the network's own tools.
+type JobLogger interface {
+	// Logs returns the output of each execution of the job with the passed ID.
+	Logs(ctx context.Context, jobID string) ([]ExecutionLogs, error)
+}
+
+// ExecutionLogs is the output of a job from a single node that ran it.
+type ExecutionLogs struct {
+	NodeID   string `json:"nodeId"`
+	State    string `json:"state"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// ErrJobNotFound is returned when no compute network knows about a job.
+var ErrJobNotFound = errors.New("job not found")
+
+// Logs implements JobLogger
+func (runner *bacalhauRunner) Logs(ctx context.Context, jobID string) ([]ExecutionLogs, error) {
+	// We only have the job ID, so ask every endpoint until one knows the job.
+	var lastErr error = ErrJobNotFound
+	for _, ep := range runner.endpoints {
+		var bacjob *model.JobWithInfo
+		var found bool
+		err := ep.call(ctx, "get", func() (err error) {
+			bacjob, found, err = ep.Client.Get(ctx, jobID)
+			return err
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		} else if !found {
+			continue
+		}
+
+		logs := make([]ExecutionLogs, 0, len(bacjob.State.Executions))
+		for _, execution := range bacjob.State.Executions {
+			l := ExecutionLogs{NodeID: execution.NodeID, State: execution.State.String()}
+			if output := execution.RunOutput; output != nil {
+				l.Stdout, l.Stderr, l.ExitCode = output.STDOUT, output.STDERR, output.ExitCode
+			}
+			logs = append(logs, l)
+		}
+		return logs, nil
+	}
+	return nil, lastErr
+}
+
+var _ JobLogger = (*bacalhauRunner)(nil)
@@ -0,0 +1,46 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// How long to wait before looking again at an order waiting to be submitted
+// whilst the bridge is in maintenance mode.
+var maintenanceRetryTime = 30 * time.Second
+
+// MaintenanceStatus says whether the bridge is in maintenance mode, and since
+// when.
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// SetMaintenance puts the workflow into or takes it out of maintenance mode.
+// In maintenance mode, new orders are still read from the contract and saved,
+// but no jobs are submitted to Bacalhau until maintenance mode is left, so
+// that the cluster can be upgraded without losing orders. Jobs that are
+// already running are still checked, and their results posted.
+func (workflow *Workflow) SetMaintenance(ctx context.Context, enabled bool) {
+	if !enabled {
+		if workflow.maintenance.Swap(nil) != nil {
+			log.Ctx(ctx).Info().Msg("Leaving maintenance mode, resuming submissions")
+		}
+		maintenanceMode.Set(0)
+		return
+	}
+
+	since := time.Now().UTC()
+	if workflow.maintenance.CompareAndSwap(nil, &since) {
+		log.Ctx(ctx).Warn().Msg("Entering maintenance mode, holding new submissions")
+	}
+	maintenanceMode.Set(1)
+}
+
+// Maintenance returns whether the workflow is in maintenance mode.
+func (workflow *Workflow) Maintenance() MaintenanceStatus {
+	since := workflow.maintenance.Load()
+	return MaintenanceStatus{Enabled: since != nil, Since: since}
+}
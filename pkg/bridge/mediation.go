@@ -0,0 +1,497 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// A MediationState is how far the mediation of an order has got.
+//
+//go:generate stringer -type=MediationState --trimprefix=MediationState
+type MediationState int
+
+const (
+	// Mediation has been asked for, but nothing has been done about it yet.
+	MediationStateRequested MediationState = iota
+	// The contract has announced that the order is being mediated.
+	MediationStateAnnounced
+	// The job is being run again by the mediator.
+	MediationStateRunning
+	// The mediator's job has finished and a verdict has been reached.
+	MediationStateDecided
+	// The verdict has been posted to the contract.
+	MediationStatePosted
+	// The mediation was given up on after repeatedly failing.
+	MediationStateFailed
+)
+
+func MediationStates() [6]MediationState {
+	return [6]MediationState{
+		MediationStateRequested,
+		MediationStateAnnounced,
+		MediationStateRunning,
+		MediationStateDecided,
+		MediationStatePosted,
+		MediationStateFailed,
+	}
+}
+
+// ParseMediationState returns the mediation state with the passed name,
+// ignoring case.
+func ParseMediationState(name string) (MediationState, error) {
+	for _, state := range MediationStates() {
+		if strings.EqualFold(name, state.String()) {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mediation state %q", name)
+}
+
+// A MediationVerdict is whether the mediator agreed with the original outcome
+// of an order.
+type MediationVerdict string
+
+const (
+	// The mediator's job had the same outcome as the original job.
+	MediationVerdictUpheld MediationVerdict = "upheld"
+	// The mediator's job had a different outcome to the original job.
+	MediationVerdictOverturned MediationVerdict = "overturned"
+)
+
+// A Mediation is the re-running of an order's job on a cluster trusted to
+// settle disputes, whose outcome decides whether the original outcome stands.
+type Mediation struct {
+	OrderID     string           `json:"orderId"`
+	Requestor   common.Address   `json:"requestor"`
+	OrderNumber int64            `json:"orderNumber"`
+	State       MediationState   `json:"state"`
+	Reason      string           `json:"reason"`
+	JobID       string           `json:"jobId,omitempty"`
+	Verdict     MediationVerdict `json:"verdict,omitempty"`
+	Attempts    uint             `json:"attempts"`
+	Error       string           `json:"error,omitempty"`
+	Time        time.Time        `json:"time"`
+
+	// The outcome of the original job and of the mediator's job: the result
+	// as it would be returned to the contract, or the error if the job failed.
+	OriginalFailed bool   `json:"originalFailed"`
+	OriginalResult string `json:"originalResult"`
+	MediatorFailed bool   `json:"mediatorFailed"`
+	MediatorResult string `json:"mediatorResult,omitempty"`
+
+	// The order, encoded by MarshalEvent, as it was when mediation was asked
+	// for and then as the mediator's job once that is running.
+	Event json.RawMessage `json:"event"`
+}
+
+var (
+	ErrMediationNotFound = errors.New("mediation not found")
+	ErrMediationExists   = errors.New("order is already being mediated")
+	ErrOrderNotFinished  = errors.New("only orders that have been paid, refunded or failed can be mediated")
+)
+
+// A MediationStore keeps track of the orders being mediated.
+type MediationStore interface {
+	// SaveMediation saves the mediation, replacing any for the same order.
+	SaveMediation(ctx context.Context, m Mediation) error
+
+	// Mediation returns the mediation of the passed order, or
+	// ErrMediationNotFound.
+	Mediation(ctx context.Context, orderID string) (Mediation, error)
+
+	// Mediations returns every mediation in the passed state.
+	Mediations(ctx context.Context, state MediationState) ([]Mediation, error)
+}
+
+// A MediationContract is a SmartContract that can take part in mediation,
+// announcing that an order is being mediated and posting the verdict.
+type MediationContract interface {
+	// RequestMediation emits a MediationRequested event for the order.
+	RequestMediation(ctx context.Context, m Mediation) error
+
+	// ReturnVerdict posts the verdict of the mediation and the mediator's
+	// result.
+	ReturnVerdict(ctx context.Context, m Mediation) error
+}
+
+// A Mediator sends orders whose job failed or whose result is disputed to a
+// separate cluster to be run again, and posts on-chain whether the mediator's
+// job agreed with the original.
+//
+// Each mediation is moved through its own state machine, separate from that
+// of the order, which is left as it was.
+type Mediator struct {
+	// Runs jobs on the mediator's cluster, which must not be one the
+	// workflow submits jobs to.
+	Runner   JobRunner
+	Contract MediationContract
+	Repo     Repository
+	Store    MediationStore
+
+	pollInterval time.Duration
+	maxAttempts  uint
+}
+
+var (
+	defaultMediationPollInterval      = 30 * time.Second
+	defaultMediationMaxAttempts  uint = 3
+)
+
+// A MediatorOption configures the mediator returned by NewMediator.
+type MediatorOption func(*Mediator)
+
+// WithMediationPollInterval sets how often the mediator moves mediations on
+// and checks the mediator's jobs.
+func WithMediationPollInterval(interval time.Duration) MediatorOption {
+	return func(m *Mediator) {
+		m.pollInterval = interval
+	}
+}
+
+// WithMediationAttempts sets how many times each step of a mediation is tried
+// before the mediation is given up on.
+func WithMediationAttempts(attempts uint) MediatorOption {
+	return func(m *Mediator) {
+		m.maxAttempts = attempts
+	}
+}
+
+func NewMediator(runner JobRunner, contract MediationContract, repo Repository, store MediationStore, opts ...MediatorOption) *Mediator {
+	mediator := &Mediator{
+		Runner:       runner,
+		Contract:     contract,
+		Repo:         repo,
+		Store:        store,
+		pollInterval: defaultMediationPollInterval,
+		maxAttempts:  defaultMediationMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(mediator)
+	}
+	return mediator
+}
+
+// WithMediator makes the workflow run the mediator alongside it, and if
+// failedJobs is set, send the orders whose jobs have failed for good to it.
+func WithMediator(mediator *Mediator, failedJobs bool) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Mediator = mediator
+		workflow.mediateFailures = failedJobs
+	}
+}
+
+// Request asks for the order, as of the passed event, to be mediated for the
+// passed reason. An order can only be mediated again if its last mediation
+// failed.
+func (m *Mediator) Request(ctx context.Context, e Event, reason string) error {
+	order, ok := e.(BacalhauJobCompletedEvent)
+	if !ok {
+		return fmt.Errorf("don't know how to mediate event of type %T", e)
+	}
+
+	existing, err := m.Store.Mediation(ctx, e.OrderId().Hex())
+	if err == nil && existing.State != MediationStateFailed {
+		return ErrMediationExists
+	} else if err != nil && !errors.Is(err, ErrMediationNotFound) {
+		return err
+	}
+
+	data, err := MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+
+	mediation := Mediation{
+		OrderID:     e.OrderId().Hex(),
+		Requestor:   order.OrderRequestor(),
+		OrderNumber: order.OrderNumber(),
+		State:       MediationStateRequested,
+		Reason:      reason,
+		Time:        time.Now().UTC(),
+		Event:       data,
+	}
+	switch e.OrderState() {
+	case OrderStateCompleted, OrderStatePaid:
+		mediation.OriginalResult = contractResult(ctx, order)
+	default:
+		mediation.OriginalFailed = true
+		if failed, ok := e.(ContractFailedEvent); ok {
+			mediation.OriginalResult = failed.Error()
+		}
+	}
+
+	if err = m.Store.SaveMediation(ctx, mediation); err != nil {
+		return err
+	}
+	mediationsTotal.WithLabelValues(mediation.State.String()).Inc()
+	log.Ctx(ctx).Info().Stringer("id", e.OrderId()).Str("reason", reason).Msg("Mediation requested")
+	return nil
+}
+
+// Dispute asks for the finished order with the passed ID to be mediated, for
+// instance because its requestor doesn't believe its result.
+func (m *Mediator) Dispute(ctx context.Context, orderID common.Hash, reason string) error {
+	for _, state := range []OrderState{OrderStatePaid, OrderStateRefunded, OrderStateFailed} {
+		events, err := m.Repo.Reload(state)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if e.OrderId() == orderID {
+				return m.Request(ctx, e, reason)
+			}
+		}
+	}
+	return ErrOrderNotFinished
+}
+
+// Run moves every mediation on through its states, every poll interval, until
+// the passed context is cancelled.
+func (m *Mediator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.advance(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// advance takes the next step of every mediation that is waiting for one, and
+// decides those whose mediator job has finished.
+func (m *Mediator) advance(ctx context.Context) {
+	for _, state := range []MediationState{MediationStateRequested, MediationStateAnnounced, MediationStateDecided} {
+		mediations, err := m.Store.Mediations(ctx, state)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Stringer("state", state).Msg("Unable to reload mediations")
+			continue
+		}
+
+		for _, mediation := range mediations {
+			if ctx.Err() != nil {
+				return
+			}
+			m.step(mediationContext(ctx, mediation), mediation)
+		}
+	}
+	m.checkRunning(ctx)
+}
+
+func mediationContext(ctx context.Context, mediation Mediation) context.Context {
+	return log.Ctx(ctx).With().
+		Str("id", mediation.OrderID).
+		Stringer("mediation", mediation.State).
+		Logger().WithContext(ctx)
+}
+
+// step takes the action that moves the mediation into its next state.
+func (m *Mediator) step(ctx context.Context, mediation Mediation) {
+	var next MediationState
+	var err error
+	switch mediation.State {
+	case MediationStateRequested:
+		next = MediationStateAnnounced
+		err = m.Contract.RequestMediation(ctx, mediation)
+	case MediationStateAnnounced:
+		next = MediationStateRunning
+		err = m.submit(ctx, &mediation)
+	case MediationStateDecided:
+		next = MediationStatePosted
+		err = m.Contract.ReturnVerdict(ctx, mediation)
+	default:
+		return
+	}
+	m.settle(ctx, mediation, next, err)
+}
+
+// submit runs the order's job again on the mediator's cluster.
+func (m *Mediator) submit(ctx context.Context, mediation *Mediation) error {
+	e, err := UnmarshalEvent(mediation.Event)
+	if err != nil {
+		return err
+	}
+
+	running, err := m.Runner.Create(ctx, e.(ContractSubmittedEvent))
+	if err != nil {
+		return err
+	} else if running == nil {
+		return errors.New("mediator runner did not submit a job")
+	}
+
+	data, err := MarshalEvent(running)
+	if err != nil {
+		return err
+	}
+	mediation.JobID = running.JobID()
+	mediation.Event = data
+	log.Ctx(ctx).Info().Str("job", mediation.JobID).Msg("Submitted mediation job")
+	return nil
+}
+
+// checkRunning finds the mediator jobs that have finished and reaches a verdict
+// on each of their mediations.
+func (m *Mediator) checkRunning(ctx context.Context) {
+	mediations, err := m.Store.Mediations(ctx, MediationStateRunning)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to reload running mediations")
+		return
+	} else if len(mediations) == 0 {
+		return
+	}
+
+	byOrder := make(map[common.Hash]Mediation, len(mediations))
+	jobs := make([]BacalhauJobRunningEvent, 0, len(mediations))
+	for _, mediation := range mediations {
+		e, err := UnmarshalEvent(mediation.Event)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", mediation.OrderID).Msg("Unable to decode mediation job")
+			continue
+		}
+		byOrder[e.OrderId()] = mediation
+		jobs = append(jobs, e.(BacalhauJobRunningEvent))
+	}
+
+	completed, failed := m.Runner.FindCompleted(ctx, jobs)
+	for _, e := range completed {
+		m.decide(ctx, byOrder[e.OrderId()], false, contractResult(ctx, e))
+	}
+	for _, e := range failed {
+		m.decide(ctx, byOrder[e.OrderId()], true, e.Error())
+	}
+}
+
+// decide records the outcome of the mediator's job and compares it with the
+// original outcome to reach a verdict.
+func (m *Mediator) decide(ctx context.Context, mediation Mediation, failed bool, result string) {
+	ctx = mediationContext(ctx, mediation)
+	mediation.MediatorFailed = failed
+	mediation.MediatorResult = result
+
+	// Failed jobs agree with each other however they failed.
+	if failed == mediation.OriginalFailed && (failed || result == mediation.OriginalResult) {
+		mediation.Verdict = MediationVerdictUpheld
+	} else {
+		mediation.Verdict = MediationVerdictOverturned
+	}
+
+	mediationVerdicts.WithLabelValues(string(mediation.Verdict)).Inc()
+	log.Ctx(ctx).Info().Str("verdict", string(mediation.Verdict)).Msg("Mediation decided")
+	m.settle(ctx, mediation, MediationStateDecided, nil)
+}
+
+// settle saves the mediation in its next state, or counts a failed attempt at
+// getting there, giving up on the mediation once it has run out of attempts.
+func (m *Mediator) settle(ctx context.Context, mediation Mediation, next MediationState, err error) {
+	if postingPaused(err) {
+		log.Ctx(ctx).Debug().Err(err).Msg("Waiting for on-chain posting to resume")
+		return
+	} else if err != nil {
+		mediation.Attempts++
+		mediation.Error = err.Error()
+		log.Ctx(ctx).Error().Err(err).Uint("attempt", mediation.Attempts).Msg("Error processing mediation")
+		if mediation.Attempts >= m.maxAttempts {
+			next = MediationStateFailed
+		} else {
+			next = mediation.State
+		}
+	} else {
+		mediation.Attempts = 0
+		mediation.Error = ""
+	}
+
+	old := mediation.State
+	mediation.State = next
+	mediation.Time = time.Now().UTC()
+	saveErr := m.Store.SaveMediation(ctx, mediation)
+	if saveErr == nil && next != old {
+		mediationsTotal.WithLabelValues(next.String()).Inc()
+	}
+	log.Ctx(ctx).WithLevel(level(saveErr)).
+		Err(saveErr).
+		Stringer("old", old).
+		Stringer("new", next).
+		Msg("Saving mediation")
+}
+
+// mediable returns whether an order whose job failed for the passed reason
+// should be sent for mediation. Only failures that a different cluster might
+// not have had are worth running again.
+func mediable(reason FailureReason) bool {
+	switch reason {
+	case FailureReasonExecutionError, FailureReasonVerificationFailure, FailureReasonTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// mediate sends the failed order to the mediator, if there is one. The order
+// is refunded whatever the mediator decides.
+func (workflow *Workflow) mediate(ctx context.Context, e ContractFailedEvent, reason FailureReason) {
+	if workflow.Mediator == nil || !workflow.mediateFailures || !mediable(reason) || synthetic(e) {
+		return
+	}
+
+	err := workflow.Mediator.Request(ctx, e, "job failed: "+e.Error())
+	log.Ctx(ctx).WithLevel(level(err)).Err(err).Msg("Sending failed order for mediation")
+}
+
+// RequestMediation implements MediationContract
+func (r *realContract) RequestMediation(ctx context.Context, m Mediation) error {
+	if err := r.supports(ContractVersion2, "mediation"); err != nil {
+		return err
+	}
+	hash, err := r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return r.contract.LilypadEventsUpgradeableTransactor.RequestMediation(
+			opts,
+			m.Requestor,
+			big.NewInt(m.OrderNumber),
+			m.Reason,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Stringer("txn", hash).Msg("Mediation announced")
+	return nil
+}
+
+// ReturnVerdict implements MediationContract
+func (r *realContract) ReturnVerdict(ctx context.Context, m Mediation) error {
+	if err := r.supports(ContractVersion2, "mediation"); err != nil {
+		return err
+	}
+	hash, err := r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return r.contract.LilypadEventsUpgradeableTransactor.ReturnMediationVerdict(
+			opts,
+			m.Requestor,
+			big.NewInt(m.OrderNumber),
+			m.Verdict == MediationVerdictUpheld,
+			m.MediatorResult,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Stringer("txn", hash).Str("verdict", string(m.Verdict)).Msg("Mediation verdict returned")
+	return nil
+}
+
+var _ MediationContract = (*realContract)(nil)
@@ -0,0 +1,97 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+// mediationContract records the mediation transactions it is asked to send.
+type mediationContract struct {
+	requested []Mediation
+	verdicts  []Mediation
+}
+
+func (c *mediationContract) RequestMediation(ctx context.Context, m Mediation) error {
+	c.requested = append(c.requested, m)
+	return nil
+}
+
+func (c *mediationContract) ReturnVerdict(ctx context.Context, m Mediation) error {
+	c.verdicts = append(c.verdicts, m)
+	return nil
+}
+
+func TestMediationReachesVerdict(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	store := repo.(MediationStore)
+	contract := &mediationContract{}
+	mediator := NewMediator(&mockRunner{}, contract, repo, store)
+
+	paid := walEvent(1)
+	paid.JobCreated(model.NewJob()).Completed(cid.Cid{}, "", "", 0).Paid()
+	require.NoError(t, repo.Save(paid))
+	failed := walEvent(2)
+	failed.JobCreated(model.NewJob()).JobError("out of memory").Failed("out of memory")
+	require.NoError(t, repo.Save(failed))
+
+	require.NoError(t, mediator.Dispute(ctx, paid.OrderId(), "wrong result"))
+	require.ErrorIs(t, mediator.Dispute(ctx, paid.OrderId(), "wrong result"), ErrMediationExists)
+	require.ErrorIs(t, mediator.Dispute(ctx, common.Hash{3}, "unknown"), ErrOrderNotFinished)
+	require.NoError(t, mediator.Request(ctx, failed, "job failed"))
+
+	mediator.advance(ctx)
+	require.Len(t, contract.requested, 2)
+	require.Empty(t, contract.verdicts)
+
+	mediator.advance(ctx)
+	require.Len(t, contract.verdicts, 2)
+
+	upheld, err := store.Mediation(ctx, paid.OrderId().Hex())
+	require.NoError(t, err)
+	require.Equal(t, MediationStatePosted, upheld.State)
+	require.Equal(t, MediationVerdictUpheld, upheld.Verdict)
+	require.Equal(t, "wrong result", upheld.Reason)
+	require.NotEmpty(t, upheld.JobID)
+
+	overturned, err := store.Mediation(ctx, failed.OrderId().Hex())
+	require.NoError(t, err)
+	require.Equal(t, MediationStatePosted, overturned.State)
+	require.Equal(t, MediationVerdictOverturned, overturned.Verdict)
+	require.True(t, overturned.OriginalFailed)
+	require.Equal(t, "out of memory", overturned.OriginalResult)
+	require.False(t, overturned.MediatorFailed)
+}
+
+func TestMediationIsGivenUpOn(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	store := repo.(MediationStore)
+	contract := &mediationContract{}
+	mediator := NewMediator(&mockRunner{CreateHandler: ErrorCreate}, contract, repo, store, WithMediationAttempts(2))
+
+	failed := walEvent(1)
+	failed.JobCreated(model.NewJob()).JobError("out of memory").Failed("out of memory")
+	require.NoError(t, mediator.Request(ctx, failed, "job failed"))
+
+	mediator.advance(ctx)
+	mediation, err := store.Mediation(ctx, failed.OrderId().Hex())
+	require.NoError(t, err)
+	require.Equal(t, MediationStateAnnounced, mediation.State)
+	require.Equal(t, uint(1), mediation.Attempts)
+
+	mediator.advance(ctx)
+	mediation, err = store.Mediation(ctx, failed.OrderId().Hex())
+	require.NoError(t, err)
+	require.Equal(t, MediationStateFailed, mediation.State)
+	require.Equal(t, "error creating job", mediation.Error)
+	require.Empty(t, contract.verdicts)
+
+	// A failed mediation can be asked for again.
+	require.NoError(t, mediator.Request(ctx, failed, "job failed"))
+}
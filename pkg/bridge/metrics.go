@@ -0,0 +1,290 @@
+package bridge
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "lilypad"
+
+var (
+	jobsSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "jobs_submitted_total",
+		Help:      "Number of jobs successfully submitted to Bacalhau.",
+	})
+	jobSubmitErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_submit_errors_total",
+		Help:      "Number of jobs that could not be submitted to Bacalhau.",
+	})
+	jobsCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "jobs_completed_total",
+		Help:      "Number of Bacalhau jobs seen to complete successfully.",
+	})
+	jobsFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "jobs_failed_total",
+		Help:      "Number of Bacalhau jobs seen to fail.",
+	})
+	transactionsNotSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "transactions_not_sent_total",
+		Help:      "Number of transactions not sent because simulating them showed the contract would revert them.",
+	})
+	postingClaims = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "posting_claims_total",
+		Help:      "Number of attempts to claim orders before settling them on-chain, by whether the claim was taken, or the order was locked or settled by another bridge.",
+	}, []string{"outcome"})
+	paymentsRefunded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "payments_refunded_total",
+		Help:      "Number of failed orders whose payment was given back by the contract.",
+	})
+	transactionsReplaced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "transactions_replaced_total",
+		Help:      "Number of transactions sent again with higher fees because they weren't mined in time.",
+	})
+	transactionsStuck = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "transactions_stuck",
+		Help:      "Number of transactions that have waited longer than allowed to be mined.",
+	})
+	ordersReorged = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "orders_reorged_total",
+		Help:      "Number of orders abandoned because their event was removed from the chain by a reorg.",
+	})
+	gasUsed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "gas_used_total",
+		Help:      "Gas used by the bridge's mined transactions.",
+	})
+	gasSpentToday = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "gas_spent_today_wei",
+		Help:      "What the bridge's transactions have cost today (UTC), in wei.",
+	})
+	gasBudgetExceeded = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "gas_budget_exceeded",
+		Help:      "Whether today's gas budget has been spent, pausing on-chain posting.",
+	})
+	walletBalance = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "wallet_balance",
+		Help:      "The bridge wallet's balance when it was last checked, in the chain's native token.",
+	})
+	postingPausedForFunds = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "posting_paused_low_balance",
+		Help:      "Whether the wallet's balance is below the minimum, pausing on-chain posting.",
+	})
+	resultBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "result_batch_size",
+		Help:      "Number of results returned to the contract in each batched transaction.",
+		Buckets:   []float64{2, 5, 10, 20, 50, 100},
+	})
+	jobSubmitDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_submit_duration_seconds",
+		Help:      "Time taken to submit a job to Bacalhau, including retries.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	findCompletedDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "find_completed_duration_seconds",
+		Help:      "Time taken to poll Bacalhau for the status of all running jobs.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	bacalhauAPIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "bacalhau_api_requests_total",
+		Help:      "Number of requests made to the Bacalhau API, by call.",
+	}, []string{"call"})
+	bacalhauAPIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "bacalhau_api_errors_total",
+		Help:      "Number of requests to the Bacalhau API that returned an error, by call.",
+	}, []string{"call"})
+	bacalhauCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bacalhau_circuit_state",
+		Help:      "State of the circuit breaker for each Bacalhau API: 0 closed, 1 half-open, 2 open.",
+	}, []string{"endpoint"})
+	eventsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "events_processed_total",
+		Help:      "Number of events processed by the workflow, by the state they were in.",
+	}, []string{"state"})
+	eventErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "event_errors_total",
+		Help:      "Number of events whose processing failed, by the state they were in and whether the error could be retried.",
+	}, []string{"state", "class"})
+	illegalTransitions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "illegal_transitions_total",
+		Help:      "Number of orders that were refused a move between states that their lifecycle doesn't allow.",
+	})
+	ordersDeadLettered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "orders_dead_lettered_total",
+		Help:      "Number of orders given up on and moved to the dead-letter queue.",
+	})
+	duplicateSubmissions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "duplicate_submissions_total",
+		Help:      "Number of orders seen again after a job had already been submitted for them.",
+	})
+	mediationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "mediations_total",
+		Help:      "Number of mediations that have moved into each state.",
+	}, []string{"state"})
+	mediationVerdicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "mediation_verdicts_total",
+		Help:      "Number of mediations decided, by whether the original outcome was upheld or overturned.",
+	}, []string{"verdict"})
+	verificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "verifications_total",
+		Help:      "Number of results verified by running the job again, by what was found.",
+	}, []string{"status"})
+	dealsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "storage_deals_total",
+		Help:      "Number of Filecoin storage deals made for results.",
+	})
+	inputsUnavailable = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "inputs_unavailable_total",
+		Help:      "Number of orders failed because an input of their job could not be retrieved from IPFS.",
+	})
+	ordersDeclined = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "orders_declined_total",
+		Help:      "Number of orders declined because they offered less than the minimum price for their job.",
+	})
+	specsRewritten = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "specs_rewritten_total",
+		Help:      "Number of job specs rewritten to fit the sandbox policy.",
+	})
+	contractVersion = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "contract_version",
+		Help:      "Version of the events and methods offered by each contract the bridge watches.",
+	}, []string{"contract"})
+	ordersBlocked = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "orders_blocked_total",
+		Help:      "Number of orders refused because they were made from an address the bridge doesn't take orders from.",
+	})
+	pinsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pins_total",
+		Help:      "Number of changes in the pinning status of results, by the status changed to.",
+	}, []string{"status"})
+	postingsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "postings_total",
+		Help:      "Number of transactions settling orders that were sent, confirmed or reverted, by status.",
+	}, []string{"status"})
+	isLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "is_leader",
+		Help:      "Whether this bridge is the leader that submits jobs and sends transactions: 1 if it is, 0 if it is a standby.",
+	})
+	partitionsOwned = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "partitions_owned",
+		Help:      "Number of partitions of the orders this bridge currently owns.",
+	})
+	auditErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "audit_errors_total",
+		Help:      "Number of decisions about orders that could not be recorded in the audit log.",
+	})
+	chaosFaults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "chaos_faults_total",
+		Help:      "Number of faults injected by chaos mode, by the kind of fault.",
+	}, []string{"fault"})
+	maintenanceMode = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "maintenance_mode",
+		Help:      "Whether the bridge is in maintenance mode, holding new submissions to Bacalhau.",
+	})
+	jobCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_cache_lookups_total",
+		Help:      "Number of orders looked up in the job cache, by whether an identical job was found.",
+	}, []string{"result"})
+	jobCacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_cache_evictions_total",
+		Help:      "Number of jobs evicted from the job cache, by whether they expired or the cache was full.",
+	}, []string{"reason"})
+	jobCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_cache_entries",
+		Help:      "Number of jobs in the job cache, if it is kept on disk.",
+	})
+	runningJobSlots = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "running_job_slots",
+		Help:      "Number of slots for running jobs that are taken, which is limited if there is a cap on running jobs.",
+	})
+	clientQuotaWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "client_quota_waits_total",
+		Help:      "Number of times an order was held because its client had used up a quota, by quota.",
+	}, []string{"quota"})
+	submissionQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "submission_queue_depth",
+		Help:      "Number of orders waiting for the submission rate limit, by priority.",
+	}, []string{"priority"})
+	ordersByState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "orders",
+		Help:      "Number of orders in each state.",
+	}, []string{"state"})
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_depth",
+		Help:      "Number of orders waiting for their job to be submitted.",
+	})
+	queueOldestAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_oldest_age_seconds",
+		Help:      "How long ago the longest waiting order still to be submitted was first seen.",
+	})
+	slaLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "sla_latency_seconds",
+		Help:      "End-to-end latency of the orders settled in each rolling window, at each percentile, as of the last SLA report.",
+	}, []string{"window", "percentile"})
+	alertsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "alerts_total",
+		Help:      "Number of alerts sent to the operator, by alert and whether it was resolving.",
+	}, []string{"alert", "resolved"})
+)
+
+// observeAPICall records a request to the Bacalhau API and whether it failed.
+func observeAPICall(call string, err error) {
+	bacalhauAPIRequests.WithLabelValues(call).Inc()
+	if err != nil {
+		bacalhauAPIErrors.WithLabelValues(call).Inc()
+		consecutiveAPIFailures.Add(1)
+	} else {
+		consecutiveAPIFailures.Store(0)
+	}
+}
@@ -0,0 +1,136 @@
+package bridge
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// A NonceReader reads the next nonce of an account, counting transactions
+// that are still pending. An *ethclient.Client is a NonceReader.
+type NonceReader interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// A NonceManager hands out the nonces of the bridge's transactions. Asking the
+// chain for the pending nonce before every transaction gives the same nonce to
+// transactions sent at the same time, and so the manager keeps count itself
+// and sends one transaction at a time.
+type NonceManager struct {
+	chain   NonceReader
+	account common.Address
+
+	// How long the chain can be behind the manager's count before the
+	// transactions in between are taken to have been dropped.
+	dropTimeout time.Duration
+
+	mu       sync.Mutex
+	next     uint64
+	synced   bool
+	lastSent time.Time
+}
+
+// How many times a transaction is sent again with a new nonce when the nonce
+// it was given turns out to be used already.
+const maxNonceRetries = 3
+
+var defaultNonceDropTimeout = 5 * time.Minute
+
+// NewNonceManager returns a NonceManager for the passed account.
+func NewNonceManager(chain NonceReader, account common.Address) *NonceManager {
+	return &NonceManager{
+		chain:       chain,
+		account:     account,
+		dropTimeout: defaultNonceDropTimeout,
+	}
+}
+
+// Send calls send with the next nonce, holding back every other transaction
+// until it returns. The nonce is only used up if send succeeds. If the nonce
+// was already used, by another wallet sharing the account or a transaction
+// the manager doesn't know about, send is called again with a new one. If the
+// node already has the very same transaction, such as after a send that timed
+// out, it has been sent and is not sent again.
+func (m *NonceManager) Send(ctx context.Context, send func(nonce uint64) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.sync(ctx); err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := send(m.next)
+		if err != nil && isAlreadyKnown(err) {
+			log.Ctx(ctx).Info().Err(err).Uint64("nonce", m.next).Msg("Transaction already sent")
+			err = nil
+		}
+		if err == nil {
+			m.next++
+			m.lastSent = time.Now()
+			return nil
+		} else if !isNonceUsed(err) || attempt >= maxNonceRetries {
+			// The nonce may or may not have been used, so ask the chain
+			// again next time.
+			m.synced = false
+			return err
+		}
+
+		log.Ctx(ctx).Warn().Err(err).Uint64("nonce", m.next).Msg("Nonce already used, trying the next one")
+		pending, err := m.chain.PendingNonceAt(ctx, m.account)
+		if err != nil {
+			m.synced = false
+			return err
+		}
+		m.next++
+		if pending > m.next {
+			m.next = pending
+		}
+	}
+}
+
+// sync makes sure that the next nonce agrees with the chain. The chain may be
+// ahead if transactions were sent from elsewhere, or behind if transactions
+// the manager sent have been dropped, in which case their nonces are reused so
+// that later transactions aren't stuck behind the gap.
+func (m *NonceManager) sync(ctx context.Context) error {
+	pending, err := m.chain.PendingNonceAt(ctx, m.account)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !m.synced:
+		m.next = pending
+		m.synced = true
+	case pending > m.next:
+		m.next = pending
+	case pending < m.next && time.Since(m.lastSent) > m.dropTimeout:
+		// The chain is often a little behind straight after a transaction is
+		// sent, but not for this long.
+		log.Ctx(ctx).Warn().
+			Uint64("from", pending).
+			Uint64("to", m.next).
+			Msg("Transactions were dropped, reusing their nonces")
+		m.next = pending
+	}
+	return nil
+}
+
+// isNonceUsed returns whether the error from sending a transaction says that
+// its nonce has already been used.
+func isNonceUsed(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "nonce too low") ||
+		strings.Contains(message, "replacement transaction underpriced")
+}
+
+// isAlreadyKnown returns whether the error from sending a transaction says
+// that the node already has that same transaction in its pool.
+func isAlreadyKnown(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "already known")
+}
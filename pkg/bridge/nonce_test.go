@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type mockNonceReader struct {
+	pending atomic.Uint64
+}
+
+func (m *mockNonceReader) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return m.pending.Load(), nil
+}
+
+func TestNoncesAreNotShared(t *testing.T) {
+	chain := &mockNonceReader{}
+	chain.pending.Store(5)
+	manager := NewNonceManager(chain, common.Address{})
+
+	var mu sync.Mutex
+	used := map[uint64]bool{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, manager.Send(context.Background(), func(nonce uint64) error {
+				mu.Lock()
+				defer mu.Unlock()
+				require.False(t, used[nonce], "nonce %d used twice", nonce)
+				used[nonce] = true
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+
+	for nonce := uint64(5); nonce < 15; nonce++ {
+		require.True(t, used[nonce])
+	}
+}
+
+func TestNonceTooLow(t *testing.T) {
+	chain := &mockNonceReader{}
+	manager := NewNonceManager(chain, common.Address{})
+	ctx := context.Background()
+
+	// Another wallet uses nonces 0 to 2 behind the manager's back.
+	sent := []uint64{}
+	err := manager.Send(ctx, func(nonce uint64) error {
+		sent = append(sent, nonce)
+		if nonce < 3 {
+			chain.pending.Store(3)
+			return errors.New("nonce too low")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 3}, sent)
+
+	err = manager.Send(ctx, func(nonce uint64) error { return errors.New("insufficient funds") })
+	require.Error(t, err)
+}
+
+func TestAlreadyKnownTransactionsWereSent(t *testing.T) {
+	chain := &mockNonceReader{}
+	manager := NewNonceManager(chain, common.Address{})
+	ctx := context.Background()
+
+	// The node already has the transaction, say from a send that timed out.
+	sent := []uint64{}
+	err := manager.Send(ctx, func(nonce uint64) error {
+		sent = append(sent, nonce)
+		return errors.New("already known")
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, sent, "the transaction shouldn't be sent again with another nonce")
+
+	err = manager.Send(ctx, func(nonce uint64) error {
+		sent = append(sent, nonce)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1}, sent)
+}
+
+func TestDroppedNoncesAreReused(t *testing.T) {
+	chain := &mockNonceReader{}
+	manager := NewNonceManager(chain, common.Address{})
+	ctx := context.Background()
+
+	send := func() (sent uint64) {
+		require.NoError(t, manager.Send(ctx, func(nonce uint64) error {
+			sent = nonce
+			return nil
+		}))
+		return
+	}
+
+	// The chain hasn't seen the first transaction yet, which is normal.
+	require.Equal(t, uint64(0), send())
+	require.Equal(t, uint64(1), send())
+
+	// But after a while it must have been dropped.
+	manager.dropTimeout = 0
+	time.Sleep(time.Millisecond)
+	require.Equal(t, uint64(0), send())
+}
@@ -0,0 +1,252 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownToken = errors.New("no price is known for the token")
+
+// A TokenPrice is what one whole unit of a token that orders can be paid in is
+// worth, in the currency that the bridge reports in.
+type TokenPrice struct {
+	Symbol string `yaml:"symbol" json:"symbol"`
+
+	// The address of the ERC-20 token, or the zero address for the chain's
+	// native token.
+	Address common.Address `yaml:"address" json:"address"`
+
+	// How many decimal places amounts of the token have. The native token
+	// always has 18.
+	Decimals uint8 `yaml:"decimals" json:"decimals"`
+
+	Price float64 `yaml:"price" json:"price"`
+}
+
+// Value returns what the passed amount of the token, in its smallest unit, is
+// worth.
+func (p TokenPrice) Value(amount *big.Int) float64 {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(p.Decimals)), nil)
+	whole, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(unit)).Float64()
+	return whole * p.Price
+}
+
+// A PriceOracle says what the tokens that orders are paid in are worth, so
+// that orders paid in different tokens can be priced and accounted for in a
+// common currency.
+type PriceOracle interface {
+	// Currency returns the currency that prices are in, such as USD.
+	Currency() string
+
+	// TokenPrice returns the price of the ERC-20 token with the passed
+	// address, or of the native token for the zero address. Tokens without a
+	// price return an error wrapping ErrUnknownToken.
+	TokenPrice(ctx context.Context, token common.Address) (TokenPrice, error)
+}
+
+// A StaticPriceOracle prices tokens at the fixed prices it was configured
+// with.
+type StaticPriceOracle struct {
+	// The currency that prices are in, such as USD.
+	CurrencyCode string       `yaml:"currency"`
+	Tokens       []TokenPrice `yaml:"tokens"`
+}
+
+// LoadTokenPrices reads a StaticPriceOracle from the YAML file at the passed
+// path.
+func LoadTokenPrices(path string) (*StaticPriceOracle, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	oracle := new(StaticPriceOracle)
+	if err = yaml.Unmarshal(contents, oracle); err != nil {
+		return nil, fmt.Errorf("invalid tokens file %s: %w", path, err)
+	}
+	// A token left without decimals would be valued as if it had none, and
+	// so be worth far more than it is.
+	var listed struct {
+		Tokens []struct {
+			Decimals *uint8 `yaml:"decimals"`
+		} `yaml:"tokens"`
+	}
+	if err = yaml.Unmarshal(contents, &listed); err != nil {
+		return nil, fmt.Errorf("invalid tokens file %s: %w", path, err)
+	}
+	seen := map[common.Address]bool{}
+	for i, token := range oracle.Tokens {
+		if token.Symbol == "" {
+			return nil, fmt.Errorf("invalid tokens file %s: every token needs a symbol", path)
+		} else if token.Address != (common.Address{}) && listed.Tokens[i].Decimals == nil {
+			return nil, fmt.Errorf("invalid tokens file %s: decimals of %s must be set", path, token.Symbol)
+		} else if token.Price < 0 {
+			return nil, fmt.Errorf("invalid tokens file %s: price of %s must not be negative", path, token.Symbol)
+		} else if seen[token.Address] {
+			return nil, fmt.Errorf("invalid tokens file %s: token %s is listed twice", path, token.Address)
+		}
+		seen[token.Address] = true
+		if token.Address == (common.Address{}) {
+			oracle.Tokens[i].Decimals = 18
+		}
+	}
+	return oracle, nil
+}
+
+// Currency implements PriceOracle
+func (o *StaticPriceOracle) Currency() string {
+	return o.CurrencyCode
+}
+
+// TokenPrice implements PriceOracle
+func (o *StaticPriceOracle) TokenPrice(ctx context.Context, token common.Address) (TokenPrice, error) {
+	for _, price := range o.Tokens {
+		if price.Address == token {
+			return price, nil
+		}
+	}
+	return TokenPrice{}, fmt.Errorf("%w: %s", ErrUnknownToken, token)
+}
+
+var _ PriceOracle = (*StaticPriceOracle)(nil)
+
+// The longest that a FeedPriceOracle goes between fetching prices, unless it
+// is told otherwise.
+const defaultOracleInterval = 5 * time.Minute
+
+// The oldest that prices from a feed can be and still be used, unless a
+// FeedPriceOracle is told otherwise.
+const defaultOracleMaxAge = time.Hour
+
+var ErrStalePrice = errors.New("the latest price of the token is too old to use")
+
+// A FeedPriceOracle prices tokens at the prices returned by a URL, as a JSON
+// object of prices by symbol, such as {"ETH": 1850.2, "USDC": 1}. The tokens
+// it knows about, and the prices used until the feed has first been fetched,
+// come from a StaticPriceOracle. Once the feed has given a price for a token,
+// the price is refused if the feed can't be fetched for longer than the
+// maximum age, rather than orders being priced on what the token used to be
+// worth.
+type FeedPriceOracle struct {
+	url      string
+	interval time.Duration
+	maxAge   time.Duration
+	tokens   *StaticPriceOracle
+	client   *http.Client
+
+	mu      sync.Mutex
+	prices  map[string]float64
+	fetched time.Time
+	updated time.Time
+}
+
+// NewFeedPriceOracle returns a FeedPriceOracle that fetches the prices of the
+// passed tokens from the URL at most once per interval, or every five minutes
+// if the interval is zero, and refuses prices older than the maximum age, or
+// an hour if it is zero.
+func NewFeedPriceOracle(url string, interval, maxAge time.Duration, tokens *StaticPriceOracle) *FeedPriceOracle {
+	if interval <= 0 {
+		interval = defaultOracleInterval
+	}
+	if maxAge <= 0 {
+		maxAge = defaultOracleMaxAge
+	}
+	return &FeedPriceOracle{
+		url:      url,
+		interval: interval,
+		maxAge:   maxAge,
+		tokens:   tokens,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Currency implements PriceOracle
+func (o *FeedPriceOracle) Currency() string {
+	return o.tokens.Currency()
+}
+
+// TokenPrice implements PriceOracle. Prices from the feed that are older than
+// the maximum age return an error wrapping ErrStalePrice.
+func (o *FeedPriceOracle) TokenPrice(ctx context.Context, token common.Address) (TokenPrice, error) {
+	price, err := o.tokens.TokenPrice(ctx, token)
+	if err != nil {
+		return price, err
+	}
+
+	o.refresh(ctx)
+	o.mu.Lock()
+	prices, updated := o.prices, o.updated
+	o.mu.Unlock()
+
+	for symbol, latest := range prices {
+		if !strings.EqualFold(symbol, price.Symbol) {
+			continue
+		} else if age := time.Since(updated); age > o.maxAge {
+			return price, fmt.Errorf("%w: %s was last fetched %s ago", ErrStalePrice, price.Symbol, age.Round(time.Second))
+		}
+		price.Price = latest
+	}
+	return price, nil
+}
+
+// refresh fetches the latest prices if they haven't been fetched within the
+// interval. The lock isn't held whilst fetching, so that a slow feed doesn't
+// hold up pricing with the prices already fetched; only one caller fetches at
+// a time.
+func (o *FeedPriceOracle) refresh(ctx context.Context) {
+	o.mu.Lock()
+	due := time.Since(o.fetched) >= o.interval
+	if due {
+		// Try again after the interval whether or not this works, so that a
+		// feed that is down isn't asked for every order.
+		o.fetched = time.Now()
+	}
+	o.mu.Unlock()
+	if !due {
+		return
+	}
+
+	prices, err := o.fetch(ctx)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("url", o.url).Msg("Unable to fetch token prices")
+		return
+	}
+	o.mu.Lock()
+	o.prices, o.updated = prices, time.Now()
+	o.mu.Unlock()
+}
+
+func (o *FeedPriceOracle) fetch(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price feed returned %s", resp.Status)
+	}
+
+	prices := map[string]float64{}
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return nil, fmt.Errorf("invalid price feed: %w", err)
+	}
+	return prices, nil
+}
+
+var _ PriceOracle = (*FeedPriceOracle)(nil)
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenPricesAreLoadedFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+currency: USD
+tokens:
+  - symbol: ETH
+    price: 2000
+  - symbol: USDC
+    address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+    decimals: 6
+    price: 1
+`), 0644))
+	oracle, err := LoadTokenPrices(path)
+	require.NoError(t, err)
+	require.Equal(t, "USD", oracle.Currency())
+
+	ctx := context.Background()
+	native, err := oracle.TokenPrice(ctx, common.Address{})
+	require.NoError(t, err)
+	require.Equal(t, uint8(18), native.Decimals, "the native token always has 18 decimals")
+	require.InDelta(t, 3000, native.Value(ether(1.5)), 0.001)
+
+	usdc, err := oracle.TokenPrice(ctx, common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"))
+	require.NoError(t, err)
+	require.InDelta(t, 12.5, usdc.Value(big.NewInt(12_500_000)), 0.001)
+
+	_, err = oracle.TokenPrice(ctx, common.HexToAddress("0x01"))
+	require.ErrorIs(t, err, ErrUnknownToken)
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+tokens:
+  - symbol: ETH
+  - symbol: WETH
+`), 0644))
+	_, err = LoadTokenPrices(path)
+	require.Error(t, err, "tokens can't be listed twice")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+tokens:
+  - symbol: USDC
+    address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+    price: 1
+`), 0644))
+	_, err = LoadTokenPrices(path)
+	require.ErrorContains(t, err, "decimals", "ERC-20 tokens must say how many decimals they have")
+}
+
+func TestFeedPriceOracleCachesPrices(t *testing.T) {
+	var fetches atomic.Int32
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"eth": %d}`, 2000+fetches.Add(1))
+	}))
+	defer feed.Close()
+
+	tokens := &StaticPriceOracle{CurrencyCode: "USD", Tokens: []TokenPrice{{Symbol: "ETH", Decimals: 18, Price: 1800}}}
+	oracle := NewFeedPriceOracle(feed.URL, time.Hour, 0, tokens)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		price, err := oracle.TokenPrice(ctx, common.Address{})
+		require.NoError(t, err)
+		require.Equal(t, 2001.0, price.Price)
+	}
+	require.Equal(t, int32(1), fetches.Load(), "prices should be fetched once per interval")
+
+	feed.Close()
+	oracle = NewFeedPriceOracle(feed.URL, time.Hour, 0, tokens)
+	price, err := oracle.TokenPrice(ctx, common.Address{})
+	require.NoError(t, err)
+	require.Equal(t, 1800.0, price.Price, "the configured price is used when the feed is down")
+}
+
+func TestFeedPriceOracleRefusesStalePrices(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ETH": 2000}`)
+	}))
+	defer feed.Close()
+
+	tokens := &StaticPriceOracle{CurrencyCode: "USD", Tokens: []TokenPrice{{Symbol: "ETH", Decimals: 18, Price: 1800}}}
+	oracle := NewFeedPriceOracle(feed.URL, time.Millisecond, 50*time.Millisecond, tokens)
+
+	ctx := context.Background()
+	price, err := oracle.TokenPrice(ctx, common.Address{})
+	require.NoError(t, err)
+	require.Equal(t, 2000.0, price.Price)
+
+	feed.Close()
+	time.Sleep(100 * time.Millisecond)
+	_, err = oracle.TokenPrice(ctx, common.Address{})
+	require.ErrorIs(t, err, ErrStalePrice, "the last price from the feed is too old to use")
+}
@@ -0,0 +1,131 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// An Order is a summary of where an order has got to, for showing to people.
+type Order struct {
+	ID            string    `json:"id"`
+	State         string    `json:"state"`
+	Requestor     string    `json:"requestor"`
+	Contract      string    `json:"contract,omitempty"`
+	OrderNumber   int64     `json:"orderNumber"`
+	Resubmissions uint      `json:"resubmissions"`
+	JobID         string    `json:"jobId,omitempty"`
+	Endpoint      string    `json:"endpoint,omitempty"`
+	Results       []string  `json:"results,omitempty"`
+	OutputHash    string    `json:"outputHash,omitempty"`
+	Encrypted     string    `json:"encryptedResult,omitempty"`
+	DealID        string    `json:"dealId,omitempty"`
+	OfferedPrice  string    `json:"offeredPrice,omitempty"`
+	PaymentToken  string    `json:"paymentToken,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	FailureReason string    `json:"failureReason,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	Timeline      Timeline  `json:"timeline"`
+
+	// Every state the order has been in, in order. Only filled in when a
+	// single order is asked for.
+	Transitions []Transition `json:"transitions,omitempty"`
+
+	// The transactions that returned the order's result or error, and how
+	// final they are. Only filled in when a single order is asked for.
+	Postings []Posting `json:"postings,omitempty"`
+}
+
+// A Transition records when an order moved into a state.
+type Transition struct {
+	State string    `json:"state"`
+	Time  time.Time `json:"time"`
+	JobID string    `json:"jobId,omitempty"`
+}
+
+// An OrderFilter picks which orders are returned by OrderStore.Orders.
+type OrderFilter struct {
+	// If set, only orders currently in this state are returned.
+	State *OrderState
+
+	Limit  uint
+	Offset uint
+}
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// An OrderStore can be asked about the orders the bridge has seen.
+type OrderStore interface {
+	// Orders returns the matching orders, most recently changed first.
+	Orders(ctx context.Context, filter OrderFilter) ([]Order, error)
+
+	// Order returns the order with the passed ID and its history, or
+	// ErrOrderNotFound.
+	Order(ctx context.Context, orderID common.Hash) (Order, error)
+}
+
+// parseOrderID returns the order ID written as a 0x-prefixed hex string, or
+// false if the string isn't one.
+func parseOrderID(str string) (common.Hash, bool) {
+	orderID, err := hexutil.Decode(str)
+	if err != nil || len(orderID) != common.HashLength {
+		return common.Hash{}, false
+	}
+	return common.BytesToHash(orderID), true
+}
+
+// newOrder summarises the order as of the passed event.
+func newOrder(e *event) Order {
+	order := Order{
+		ID:            e.OrderId().Hex(),
+		State:         e.state.String(),
+		Requestor:     e.OrderRequestor().Hex(),
+		OrderNumber:   e.orderNumber,
+		Resubmissions: e.resubmissions,
+		JobID:         e.jobId,
+		Endpoint:      e.jobEndpoint,
+		Results:       e.jobResults,
+		OutputHash:    e.jobOutputHash,
+		Encrypted:     e.jobEncryptedResult,
+		DealID:        e.jobDealId,
+		OfferedPrice:  e.orderPrice,
+		UpdatedAt:     e.savedAt,
+		Timeline:      e.timeline,
+	}
+	if len(e.orderContract) > 0 {
+		order.Contract = e.SourceContract().Hex()
+	}
+	if len(e.orderToken) > 0 {
+		order.PaymentToken = e.PaymentToken().Hex()
+	}
+	if len(order.Results) == 0 && e.jobResult != "" {
+		order.Results = []string{e.jobResult}
+	}
+	if isFailedState(e.state) {
+		order.Error = e.jobStderr
+		order.FailureReason = e.failureReason.String()
+	}
+	return order
+}
+
+// orderHistory summarises the order described by the passed events, which
+// must be in the order they were saved.
+func orderHistory(events []*event) Order {
+	order := newOrder(events[len(events)-1])
+	for i, e := range events {
+		// Retries save the event again in the same state, which isn't a
+		// transition.
+		if i > 0 && events[i-1].state == e.state && events[i-1].jobId == e.jobId {
+			continue
+		}
+		order.Transitions = append(order.Transitions, Transition{
+			State: e.state.String(),
+			Time:  e.savedAt,
+			JobID: e.jobId,
+		})
+	}
+	return order
+}
@@ -0,0 +1,261 @@
+package bridge
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// An OutputHasher works out the canonical hash of the output of a job, which
+// is returned on-chain with the result so that anyone who downloads the
+// result can check that they got what was attested.
+//
+// The canonical hash only depends on the names, types and contents of the
+// files in the output. Their order, timestamps, owners and permissions are
+// ignored, so the output hashes the same however it was downloaded.
+type OutputHasher interface {
+	HashOutput(ctx context.Context, result cid.Cid) (common.Hash, error)
+}
+
+// Changes whenever the canonical encoding changes, so that hashes made one
+// way are never mistaken for hashes made another.
+const outputHashVersion = "lilypad-output-v1"
+
+// The kinds of entry in an output.
+const (
+	outputDirectory byte = 'd'
+	outputFile      byte = 'f'
+	outputSymlink   byte = 'l'
+)
+
+var defaultOutputHashTimeout = 5 * time.Minute
+
+type outputEntry struct {
+	path    string
+	kind    byte
+	content []byte
+}
+
+// outputDigest collects the entries of an output and works out their
+// canonical hash.
+type outputDigest struct {
+	entries []outputEntry
+}
+
+// add records an entry, whose path is relative to the root of the output and
+// separated by slashes. The content of a file is the SHA-256 of its data and
+// that of a symlink is its target.
+func (d *outputDigest) add(name string, kind byte, content []byte) {
+	d.entries = append(d.entries, outputEntry{path: name, kind: kind, content: content})
+}
+
+// addFile records a regular file, hashing its data.
+func (d *outputDigest) addFile(name string, data io.Reader) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, data); err != nil {
+		return err
+	}
+	d.add(name, outputFile, h.Sum(nil))
+	return nil
+}
+
+// sum returns the Keccak-256 hash of the entries sorted by path, each written
+// as its kind, then its path and content prefixed with their lengths.
+func (d *outputDigest) sum() common.Hash {
+	sort.Slice(d.entries, func(i, j int) bool {
+		return d.entries[i].path < d.entries[j].path
+	})
+
+	h := crypto.NewKeccakState()
+	h.Write([]byte(outputHashVersion))
+	length := make([]byte, 8)
+	for _, entry := range d.entries {
+		h.Write([]byte{entry.kind})
+		binary.BigEndian.PutUint64(length, uint64(len(entry.path)))
+		h.Write(length)
+		h.Write([]byte(entry.path))
+		binary.BigEndian.PutUint64(length, uint64(len(entry.content)))
+		h.Write(length)
+		h.Write(entry.content)
+	}
+
+	var hash common.Hash
+	h.Read(hash[:])
+	return hash
+}
+
+// HashOutputDir returns the canonical hash of the output downloaded to the
+// passed directory, or of the passed file if the output was a single file.
+func HashOutputDir(dir string) (common.Hash, error) {
+	var digest outputDigest
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			digest.add(rel, outputSymlink, []byte(filepath.ToSlash(target)))
+		case entry.IsDir():
+			if rel != "" {
+				digest.add(rel, outputDirectory, nil)
+			}
+		case entry.Type().IsRegular():
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return digest.addFile(rel, file)
+		default:
+			return fmt.Errorf("%s: unsupported file type %s", rel, entry.Type())
+		}
+		return nil
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return digest.sum(), nil
+}
+
+// HashOutputTar returns the canonical hash of the output in the passed tar
+// archive. If root isn't empty, every entry must be under a top-level
+// directory of that name, which is not part of the output, as in the archives
+// made by IPFS gateways and `ipfs get --archive`.
+func HashOutputTar(r io.Reader, root string) (common.Hash, error) {
+	var digest outputDigest
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return common.Hash{}, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." {
+			name = ""
+		}
+		if root != "" {
+			if name == root {
+				name = ""
+			} else if strings.HasPrefix(name, root+"/") {
+				name = strings.TrimPrefix(name, root+"/")
+			} else {
+				return common.Hash{}, fmt.Errorf("%s: not under %s", header.Name, root)
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if name != "" {
+				digest.add(name, outputDirectory, nil)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err = digest.addFile(name, archive); err != nil {
+				return common.Hash{}, err
+			}
+		case tar.TypeSymlink:
+			digest.add(name, outputSymlink, []byte(header.Linkname))
+		default:
+			return common.Hash{}, fmt.Errorf("%s: unsupported tar entry type %q", header.Name, header.Typeflag)
+		}
+	}
+	return digest.sum(), nil
+}
+
+type gatewayHasher struct {
+	gateway string
+	client  *http.Client
+}
+
+// NewGatewayHasher returns an OutputHasher that downloads outputs as tar
+// archives from the passed IPFS HTTP gateway. The gateway must be trusted to
+// serve the right content, as a tar archive can't be checked against its CID.
+func NewGatewayHasher(gateway string) OutputHasher {
+	return &gatewayHasher{gateway: gateway, client: http.DefaultClient}
+}
+
+// HashOutput implements OutputHasher
+func (g *gatewayHasher) HashOutput(ctx context.Context, result cid.Cid) (common.Hash, error) {
+	url := fmt.Sprintf("%s/ipfs/%s?format=tar", g.gateway, result)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	req.Header.Set("Accept", "application/x-tar")
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return common.Hash{}, fmt.Errorf("hashing %s: gateway returned %s", result, res.Status)
+	}
+	return HashOutputTar(res.Body, result.String())
+}
+
+var _ OutputHasher = (*gatewayHasher)(nil)
+
+// WithOutputHasher makes the workflow work out the canonical hash of the
+// output of each completed job, and return it on-chain with the result.
+func WithOutputHasher(hasher OutputHasher) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Hasher = hasher
+	}
+}
+
+// hashOutput works out the canonical hash of the output of the completed
+// order, if it hasn't been already. If the output can't be hashed the order is
+// held, and the result is the event to carry on with, if any.
+func (workflow *Workflow) hashOutput(ctx context.Context, event BacalhauJobCompletedEvent) (result Event, wait time.Duration, held bool) {
+	if event.OutputHash() != (common.Hash{}) {
+		return nil, 0, false
+	}
+
+	hashCtx, cancel := context.WithTimeout(ctx, defaultOutputHashTimeout)
+	defer cancel()
+
+	hash, err := workflow.Hasher.HashOutput(hashCtx, event.Result(ctx))
+	if err != nil {
+		err = fmt.Errorf("hashing output: %w", err)
+		result, wait = workflow.settle(ctx, event, nil, 0, err)
+		return result, wait, true
+	}
+
+	event.WithOutputHash(hash)
+	log.Ctx(ctx).Info().Stringer("cid", event.Result(ctx)).Stringer("hash", hash).Msg("Hashed job output")
+	return nil, 0, false
+}
@@ -0,0 +1,101 @@
+package bridge
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var exampleOutput = map[string]string{
+	"stdout":            "hello\n",
+	"stderr":            "",
+	"outputs/a.txt":     "a",
+	"outputs/sub/b.txt": "b",
+}
+
+func writeOutputDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func writeOutputTar(t *testing.T, root string, names []string, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	require.NoError(t, archive.WriteHeader(&tar.Header{Name: root + "/", Typeflag: tar.TypeDir, Mode: 0700}))
+	for _, dir := range []string{"outputs", "outputs/sub"} {
+		require.NoError(t, archive.WriteHeader(&tar.Header{
+			Name:     root + "/" + dir + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0700,
+			ModTime:  time.Unix(1, 0),
+		}))
+	}
+	for _, name := range names {
+		content := files[name]
+		require.NoError(t, archive.WriteHeader(&tar.Header{
+			Name:     root + "/" + name,
+			Typeflag: tar.TypeReg,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			ModTime:  time.Now(),
+			Uname:    "someone",
+		}))
+		_, err := archive.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	return &buf
+}
+
+func TestOutputHashIgnoresOrderAndMetadata(t *testing.T) {
+	fromDir, err := HashOutputDir(writeOutputDir(t, exampleOutput))
+	require.NoError(t, err)
+	require.NotEqual(t, common.Hash{}, fromDir)
+
+	root := "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+	forwards := []string{"outputs/a.txt", "outputs/sub/b.txt", "stderr", "stdout"}
+	backwards := []string{"stdout", "stderr", "outputs/sub/b.txt", "outputs/a.txt"}
+
+	fromTar, err := HashOutputTar(writeOutputTar(t, root, forwards, exampleOutput), root)
+	require.NoError(t, err)
+	require.Equal(t, fromDir, fromTar)
+
+	reordered, err := HashOutputTar(writeOutputTar(t, root, backwards, exampleOutput), root)
+	require.NoError(t, err)
+	require.Equal(t, fromDir, reordered)
+
+	_, err = HashOutputTar(writeOutputTar(t, root, forwards, exampleOutput), "QmOther")
+	require.Error(t, err)
+}
+
+func TestOutputHashDependsOnContent(t *testing.T) {
+	original, err := HashOutputDir(writeOutputDir(t, exampleOutput))
+	require.NoError(t, err)
+
+	changed := map[string]string{}
+	for name, content := range exampleOutput {
+		changed[name] = content
+	}
+	changed["outputs/a.txt"] = "A"
+	modified, err := HashOutputDir(writeOutputDir(t, changed))
+	require.NoError(t, err)
+	require.NotEqual(t, original, modified)
+
+	delete(changed, "outputs/a.txt")
+	changed["outputs/c.txt"] = "a"
+	renamed, err := HashOutputDir(writeOutputDir(t, changed))
+	require.NoError(t, err)
+	require.NotEqual(t, original, renamed)
+	require.NotEqual(t, modified, renamed)
+}
@@ -0,0 +1,226 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// A PartitionLease records which bridge owns a partition of the orders, and
+// until when.
+type PartitionLease struct {
+	Partition uint
+	Owner     string
+	ExpiresAt time.Time
+}
+
+// A PartitionStore is shared by bridges that split the orders between them,
+// and records which bridges are running and which partitions each one owns.
+type PartitionStore interface {
+	// Heartbeat records that the owner is running until the passed time, and
+	// extends the leases it still holds until then too.
+	Heartbeat(ctx context.Context, owner string, now, until time.Time) error
+
+	// Members returns the owners that are running at the passed time.
+	Members(ctx context.Context, at time.Time) ([]string, error)
+
+	// PartitionLeases returns every lease that has been taken on a partition,
+	// including expired ones.
+	PartitionLeases(ctx context.Context) ([]PartitionLease, error)
+
+	// ClaimPartition takes the lease on the partition until the passed time,
+	// unless another owner holds an unexpired lease on it. It returns whether
+	// the partition was claimed.
+	ClaimPartition(ctx context.Context, partition uint, owner string, now, until time.Time) (bool, error)
+
+	// ReleasePartition gives up the owner's lease on the partition.
+	ReleasePartition(ctx context.Context, partition uint, owner string) error
+}
+
+var ErrPartitionNotOwned = errors.New("order belongs to a partition owned by another bridge")
+
+// PartitionOf returns which of count partitions an order belongs to.
+func PartitionOf(orderID common.Hash, count uint) uint {
+	hash := fnv.New64a()
+	_, _ = hash.Write(orderID.Bytes())
+	return uint(hash.Sum64() % uint64(count))
+}
+
+// How long a bridge's claim on its partitions lasts without being renewed, and
+// so how long its orders wait to be picked up by another bridge if it dies.
+// Leases are renewed three times as often.
+var defaultPartitionLeaseTime = 15 * time.Second
+
+// A Partitioner splits the orders into a fixed number of partitions, and
+// claims a fair share of them for this bridge, so that several bridges can
+// share the work of a busy contract.
+type Partitioner struct {
+	store     PartitionStore
+	owner     string
+	count     uint
+	leaseTime time.Duration
+
+	mu         sync.RWMutex
+	owned      map[uint]bool
+	validUntil time.Time
+}
+
+// NewPartitioner returns a Partitioner that splits orders into count
+// partitions, coordinating with other bridges through the passed store. Every
+// bridge sharing the store must use the same number of partitions.
+func NewPartitioner(store PartitionStore, count uint) *Partitioner {
+	return &Partitioner{
+		store:     store,
+		owner:     replicaName(),
+		count:     count,
+		leaseTime: defaultPartitionLeaseTime,
+		owned:     map[uint]bool{},
+	}
+}
+
+// replicaName returns a name for this bridge that no other bridge sharing a
+// store will have, even one on the same host.
+func replicaName() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%x", host, os.Getpid(), suffix)
+}
+
+// Owns returns whether the order is in a partition this bridge holds a lease
+// on.
+func (p *Partitioner) Owns(orderID common.Hash) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return time.Now().Before(p.validUntil) && p.owned[PartitionOf(orderID, p.count)]
+}
+
+// Run keeps this bridge's share of the partitions claimed, calling claimed
+// with any partitions it takes on, until the passed context is cancelled, at
+// which point every partition is released for other bridges to claim.
+func (p *Partitioner) Run(ctx context.Context, claimed func(partitions []uint)) error {
+	ticker := time.NewTicker(p.leaseTime / 3)
+	defer ticker.Stop()
+
+	for {
+		gained, err := p.balance(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Unable to renew partition leases")
+		} else if len(gained) > 0 {
+			log.Ctx(ctx).Info().Uints("partitions", gained).Msg("Claimed partitions")
+			claimed(gained)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			p.release(ctx)
+			return nil
+		}
+	}
+}
+
+// balance renews this bridge's leases, gives up any partitions over its share
+// and claims free partitions up to its share. It returns the partitions that
+// were claimed.
+func (p *Partitioner) balance(ctx context.Context) ([]uint, error) {
+	now := time.Now()
+	until := now.Add(p.leaseTime)
+	if err := p.store.Heartbeat(ctx, p.owner, now, until); err != nil {
+		return nil, err
+	}
+
+	members, err := p.store.Members(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := p.store.PartitionLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	share := p.count
+	if len(members) > 1 {
+		share = (p.count + uint(len(members)) - 1) / uint(len(members))
+	}
+
+	owned, taken := map[uint]bool{}, map[uint]bool{}
+	for _, lease := range leases {
+		if lease.Partition >= p.count || !lease.ExpiresAt.After(now) {
+			continue
+		} else if lease.Owner == p.owner {
+			owned[lease.Partition] = true
+		} else {
+			taken[lease.Partition] = true
+		}
+	}
+
+	// Stop working on partitions over our share before letting them go, so
+	// that no order is ever worked on by two bridges.
+	released := []uint{}
+	for partition := p.count; partition > 0 && uint(len(owned)) > share; partition-- {
+		if owned[partition-1] {
+			delete(owned, partition-1)
+			released = append(released, partition-1)
+		}
+	}
+	p.setOwned(owned, until)
+	for _, partition := range released {
+		if err := p.store.ReleasePartition(ctx, partition, p.owner); err != nil {
+			return nil, err
+		}
+	}
+	if len(released) > 0 {
+		log.Ctx(ctx).Info().Uints("partitions", released).Msg("Released partitions")
+	}
+
+	gained := []uint{}
+	for partition := uint(0); partition < p.count && uint(len(owned)) < share; partition++ {
+		if owned[partition] || taken[partition] {
+			continue
+		}
+
+		ok, err := p.store.ClaimPartition(ctx, partition, p.owner, now, until)
+		if err != nil {
+			return gained, err
+		} else if ok {
+			owned[partition] = true
+			gained = append(gained, partition)
+		}
+	}
+
+	p.setOwned(owned, until)
+	partitionsOwned.Set(float64(len(owned)))
+	return gained, nil
+}
+
+func (p *Partitioner) setOwned(owned map[uint]bool, until time.Time) {
+	copied := make(map[uint]bool, len(owned))
+	for partition := range owned {
+		copied[partition] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.owned = copied
+	p.validUntil = until
+}
+
+// release stops working on every partition and gives them all up.
+func (p *Partitioner) release(ctx context.Context) {
+	p.setOwned(nil, time.Time{})
+	partitionsOwned.Set(0)
+
+	// Expiring the leases now lets other bridges claim them straight away.
+	now := time.Now()
+	err := p.store.Heartbeat(detachedContext{parent: ctx}, p.owner, now, now)
+	log.Ctx(ctx).WithLevel(level(err)).Err(err).Msg("Released all partitions")
+}
@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionOf(t *testing.T) {
+	counts := make([]int, 4)
+	for i := 0; i < 1000; i++ {
+		partition := PartitionOf(common.BigToHash(big.NewInt(int64(i))), 4)
+		require.Less(t, partition, uint(4))
+		counts[partition]++
+	}
+	for _, count := range counts {
+		require.Greater(t, count, 150, "orders should be spread between partitions")
+	}
+}
+
+func TestPartitionsAreShared(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(PartitionStore)
+	first, second := NewPartitioner(store, 8), NewPartitioner(store, 8)
+
+	gained, err := first.balance(ctx)
+	require.NoError(t, err)
+	require.Len(t, gained, 8, "a bridge on its own should own everything")
+
+	gained, err = second.balance(ctx)
+	require.NoError(t, err)
+	require.Empty(t, gained, "partitions with a lease shouldn't be taken")
+
+	_, err = first.balance(ctx)
+	require.NoError(t, err)
+	require.Len(t, first.owned, 4, "partitions over the fair share should be released")
+
+	gained, err = second.balance(ctx)
+	require.NoError(t, err)
+	require.Len(t, gained, 4)
+
+	for i := int64(0); i < 100; i++ {
+		orderID := common.BigToHash(big.NewInt(i))
+		require.NotEqual(t, first.Owns(orderID), second.Owns(orderID), "every order should have exactly one owner")
+	}
+
+	first.release(ctx)
+	require.Empty(t, first.owned)
+	gained, err = second.balance(ctx)
+	require.NoError(t, err)
+	require.Len(t, gained, 4, "released partitions should be taken over")
+}
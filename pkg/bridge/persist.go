@@ -4,13 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math/big"
+	"path"
+	"sort"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	_ "github.com/lib/pq"
 	_ "modernc.org/sqlite"
 )
 
-//go:embed sql/*.sql
+//go:embed sql
 var sqlFiles embed.FS
 
 func Query(name string) string {
@@ -32,23 +40,98 @@ type Repository interface {
 type sqlRepository struct {
 	db *sql.DB
 
-	insertEvent    *sql.Stmt
-	eventExists    *sql.Stmt
-	retrieveEvents *sql.Stmt
+	// The connection the statements are prepared on.
+	conn *sql.Conn
+
+	// Whether the database driver understands named query parameters. If not,
+	// parameters are passed in the order they are named in.
+	named bool
+
+	insertEvent        *sql.Stmt
+	eventExists        *sql.Stmt
+	retrieveEvents     *sql.Stmt
+	recordDelivery     *sql.Stmt
+	retrieveDeliveries *sql.Stmt
+	addDeadLetter      *sql.Stmt
+	retrieveDeadLetter *sql.Stmt
+	listDeadLetters    *sql.Stmt
+	updateDeadLetter   *sql.Stmt
+	recordSubmission   *sql.Stmt
+	retrieveSubmission *sql.Stmt
+	listOrders         *sql.Stmt
+	retrieveOrder      *sql.Stmt
+	countOrders        *sql.Stmt
+	retrieveTimelines  *sql.Stmt
+	retrievePayments   *sql.Stmt
+
+	upsertPartitionMember *sql.Stmt
+	listPartitionMembers  *sql.Stmt
+	renewPartitionLeases  *sql.Stmt
+	listPartitionLeases   *sql.Stmt
+	claimPartition        *sql.Stmt
+	releasePartition      *sql.Stmt
+
+	lockPosting         *sql.Stmt
+	retrievePostingLock *sql.Stmt
+	unlockPosting       *sql.Stmt
+
+	saveCheckpoint     *sql.Stmt
+	retrieveCheckpoint *sql.Stmt
+
+	recordGasSpend   *sql.Stmt
+	retrieveGasSpend *sql.Stmt
+
+	saveMediation      *sql.Stmt
+	retrieveMediation  *sql.Stmt
+	retrieveMediations *sql.Stmt
+
+	saveVerification      *sql.Stmt
+	retrieveVerification  *sql.Stmt
+	retrieveVerifications *sql.Stmt
+
+	savePin      *sql.Stmt
+	retrievePin  *sql.Stmt
+	retrievePins *sql.Stmt
+
+	savePosting           *sql.Stmt
+	retrievePostings      *sql.Stmt
+	retrieveOrderPostings *sql.Stmt
+
+	recordAudit   *sql.Stmt
+	retrieveAudit *sql.Stmt
+
+	cacheJob          *sql.Stmt
+	retrieveCachedJob *sql.Stmt
 }
 
 // Reload implements Repository
 func (repo *sqlRepository) Reload(state OrderState) ([]Event, error) {
-	rows, err := repo.retrieveEvents.Query(sql.Named("state", state))
+	rows, err := repo.retrieveEvents.Query(repo.args(sql.Named("state", state))...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	events := make([]Event, 0)
+	scanned, err := scanEvents(rows)
+	events := make([]Event, 0, len(scanned))
+	for _, e := range scanned {
+		events = append(events, e)
+	}
+	return events, err
+}
+
+// scanEvents reads every event from rows selected from the events table,
+// returning those read before any error.
+func scanEvents(rows *sql.Rows) ([]*event, error) {
+	var err error
+	events := make([]*event, 0)
 	for rows.Next() {
 		var e event
 		var lastAttemptString string
+		var jobResultsString string
+		var jobExecutionsString string
+		var savedAtString string
+		var timelineString string
 		err = rows.Scan(
 			&e.eventId,
 			&e.orderId,
@@ -64,17 +147,58 @@ func (repo *sqlRepository) Reload(state OrderState) ([]Event, error) {
 			&e.jobStdout,
 			&e.jobStderr,
 			&e.jobExitcode,
+			&jobResultsString,
+			&e.resubmissions,
+			&jobExecutionsString,
+			&e.jobEndpoint,
+			&e.failureReason,
+			&e.stateMessage,
+			&savedAtString,
+			&e.jobOutputHash,
+			&e.encryptionKey,
+			&e.jobEncryptedResult,
+			&e.durableStorage,
+			&e.jobDealId,
+			&e.orderPrice,
+			&timelineString,
+			&e.orderContract,
+			&e.orderToken,
 		)
 		if err != nil {
 			break
 		}
+		err = json.Unmarshal([]byte(jobResultsString), &e.jobResults)
+		if err != nil {
+			break
+		}
+		err = e.checkResults()
+		if err != nil {
+			break
+		}
+		err = json.Unmarshal([]byte(jobExecutionsString), &e.jobExecutions)
+		if err != nil {
+			break
+		}
+		err = json.Unmarshal([]byte(timelineString), &e.timeline)
+		if err != nil {
+			break
+		}
 		e.lastAttempt, err = time.Parse(time.RFC3339, lastAttemptString)
 		if err != nil {
 			break
 		}
+		// Events saved before the time was recorded have no time.
+		if savedAtString != "" {
+			e.savedAt, err = time.Parse(sortableTimeFormat, savedAtString)
+			if err != nil {
+				break
+			}
+		}
 		events = append(events, &e)
 	}
-
+	if err == nil {
+		err = rows.Err()
+	}
 	return events, err
 }
 
@@ -84,7 +208,20 @@ func (repo *sqlRepository) Save(in Event) error {
 	if !ok {
 		return fmt.Errorf("don't know how to save event of type %T", in)
 	}
-	_, err := repo.insertEvent.Exec(
+	jobResults, err := json.Marshal(e.jobResults)
+	if err != nil {
+		return err
+	}
+	jobExecutions, err := json.Marshal(e.jobExecutions)
+	if err != nil {
+		return err
+	}
+	timeline, err := json.Marshal(e.timeline)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.insertEvent.Exec(repo.args(
 		sql.Named("orderId", e.orderId),
 		sql.Named("orderOwner", e.orderOwner),
 		sql.Named("orderNumber", e.orderNumber),
@@ -98,77 +235,1387 @@ func (repo *sqlRepository) Save(in Event) error {
 		sql.Named("jobStdout", e.jobStdout),
 		sql.Named("jobStderr", e.jobStderr),
 		sql.Named("jobExitcode", e.jobExitcode),
-	)
+		sql.Named("jobResults", string(jobResults)),
+		sql.Named("resubmissions", e.resubmissions),
+		sql.Named("jobExecutions", string(jobExecutions)),
+		sql.Named("jobEndpoint", e.jobEndpoint),
+		sql.Named("failureReason", e.failureReason),
+		sql.Named("stateMessage", e.stateMessage),
+		sql.Named("savedAt", time.Now().UTC().Format(sortableTimeFormat)),
+		sql.Named("jobOutputHash", e.jobOutputHash),
+		sql.Named("encryptionKey", e.encryptionKey),
+		sql.Named("jobEncryptedResult", e.jobEncryptedResult),
+		sql.Named("durableStorage", e.durableStorage),
+		sql.Named("jobDealId", e.jobDealId),
+		sql.Named("orderPrice", e.orderPrice),
+		sql.Named("timeline", string(timeline)),
+		sql.Named("orderContract", e.orderContract),
+		sql.Named("orderToken", e.orderToken),
+	)...)
 	return err
 }
 
 func (repo *sqlRepository) Exists(in Event) (bool, error) {
-	res, err := repo.eventExists.Query(sql.Named("orderId", in.OrderId()))
+	res, err := repo.eventExists.Query(repo.args(sql.Named("orderId", in.OrderId()))...)
 	if err != nil {
 		return false, err
 	}
-	return res.Next(), nil
+	defer res.Close()
+	return res.Next(), res.Err()
 }
 
-func NewSQLiteRepository(ctx context.Context, path string) (Repository, error) {
-	db, err := sql.Open("sqlite", path)
+// Times that are sorted on are stored with a fixed width so that they sort
+// correctly as strings.
+const sortableTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// RecordDelivery implements DeliveryStore
+func (repo *sqlRepository) RecordDelivery(ctx context.Context, d Delivery) error {
+	_, err := repo.recordDelivery.ExecContext(ctx, repo.args(
+		sql.Named("deliveryId", d.ID),
+		sql.Named("url", d.URL),
+		sql.Named("orderId", d.OrderID),
+		sql.Named("state", d.State),
+		sql.Named("status", d.Status),
+		sql.Named("attempts", d.Attempts),
+		sql.Named("responseCode", d.ResponseCode),
+		sql.Named("error", d.Error),
+		sql.Named("updatedAt", d.Time.UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// Deliveries implements DeliveryStore
+func (repo *sqlRepository) Deliveries(ctx context.Context, orderID string) ([]Delivery, error) {
+	rows, err := repo.retrieveDeliveries.QueryContext(ctx, repo.args(sql.Named("orderId", orderID))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		var d Delivery
+		var updatedAtString string
+		err = rows.Scan(
+			&d.ID,
+			&d.URL,
+			&d.OrderID,
+			&d.State,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseCode,
+			&d.Error,
+			&updatedAtString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.Time, err = time.Parse(sortableTimeFormat, updatedAtString)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+var _ DeliveryStore = (*sqlRepository)(nil)
+
+// RecordAudit implements AuditLog
+func (repo *sqlRepository) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	results, err := json.Marshal(entry.Results)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.recordAudit.ExecContext(ctx, repo.args(
+		sql.Named("orderId", entry.OrderID),
+		sql.Named("action", entry.Action),
+		sql.Named("jobId", entry.JobID),
+		sql.Named("txHash", entry.TxHash),
+		sql.Named("reason", entry.Reason),
+		sql.Named("error", entry.Error),
+		sql.Named("results", string(results)),
+		sql.Named("recordedAt", entry.Time.UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// AuditEntries implements AuditLog
+func (repo *sqlRepository) AuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	action := -1
+	if filter.Action != nil {
+		action = int(*filter.Action)
+	}
+	var since, until string
+	if !filter.Since.IsZero() {
+		since = filter.Since.UTC().Format(sortableTimeFormat)
+	}
+	if !filter.Until.IsZero() {
+		until = filter.Until.UTC().Format(sortableTimeFormat)
+	}
+
+	rows, err := repo.retrieveAudit.QueryContext(ctx, repo.args(
+		sql.Named("orderId", filter.OrderID),
+		sql.Named("action", action),
+		sql.Named("since", since),
+		sql.Named("until", until),
+		sql.Named("limit", filter.Limit),
+		sql.Named("offset", filter.Offset),
+	)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var entry AuditEntry
+		var resultsString, recordedAtString string
+		err = rows.Scan(
+			&entry.ID,
+			&entry.OrderID,
+			&entry.Action,
+			&entry.JobID,
+			&entry.TxHash,
+			&entry.Reason,
+			&entry.Error,
+			&resultsString,
+			&recordedAtString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(resultsString), &entry.Results); err != nil {
+			return nil, err
+		}
+		entry.Time, err = time.Parse(sortableTimeFormat, recordedAtString)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+var _ AuditLog = (*sqlRepository)(nil)
+
+// CacheJob implements JobCache
+func (repo *sqlRepository) CacheJob(ctx context.Context, job CachedJob) error {
+	results, err := json.Marshal(job.Results)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.cacheJob.ExecContext(ctx, repo.args(
+		sql.Named("specHash", job.SpecHash),
+		sql.Named("jobId", job.JobID),
+		sql.Named("endpoint", job.Endpoint),
+		sql.Named("results", string(results)),
+		sql.Named("stdout", job.StdOut),
+		sql.Named("stderr", job.StdErr),
+		sql.Named("exitCode", job.ExitCode),
+		sql.Named("finishedAt", job.Time.UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// CachedJob implements JobCache
+func (repo *sqlRepository) CachedJob(ctx context.Context, specHash string, since time.Time) (CachedJob, bool, error) {
+	rows, err := repo.retrieveCachedJob.QueryContext(ctx, repo.args(
+		sql.Named("specHash", specHash),
+		sql.Named("since", since.UTC().Format(sortableTimeFormat)),
+	)...)
+	if err != nil {
+		return CachedJob{}, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return CachedJob{}, false, rows.Err()
+	}
+
+	var job CachedJob
+	var resultsString, finishedAtString string
+	err = rows.Scan(&job.SpecHash, &job.JobID, &job.Endpoint, &resultsString, &job.StdOut, &job.StdErr, &job.ExitCode, &finishedAtString)
+	if err != nil {
+		return CachedJob{}, false, err
+	}
+	if err = json.Unmarshal([]byte(resultsString), &job.Results); err != nil {
+		return CachedJob{}, false, err
+	}
+	job.Time, err = time.Parse(sortableTimeFormat, finishedAtString)
+	return job, err == nil, err
+}
+
+var _ JobCache = (*sqlRepository)(nil)
+
+// AddDeadLetter implements DeadLetterQueue
+func (repo *sqlRepository) AddDeadLetter(ctx context.Context, d DeadLetter) error {
+	_, err := repo.addDeadLetter.ExecContext(ctx, repo.args(
+		sql.Named("orderId", d.OrderID),
+		sql.Named("state", d.State),
+		sql.Named("attempts", d.Attempts),
+		sql.Named("error", d.Error),
+		sql.Named("status", d.Status),
+		sql.Named("deadAt", d.Time.UTC().Format(sortableTimeFormat)),
+		sql.Named("event", string(d.Event)),
+	)...)
+	return err
+}
+
+// DeadLetter implements DeadLetterQueue
+func (repo *sqlRepository) DeadLetter(ctx context.Context, orderID string) (DeadLetter, error) {
+	rows, err := repo.retrieveDeadLetter.QueryContext(ctx, repo.args(sql.Named("orderId", orderID))...)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	defer rows.Close()
+
+	deadLetters, err := scanDeadLetters(rows)
+	if err != nil {
+		return DeadLetter{}, err
+	} else if len(deadLetters) == 0 {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+	return deadLetters[0], nil
+}
 
-	_, err = db.Exec(Query("init"))
+// DeadLetters implements DeadLetterQueue
+func (repo *sqlRepository) DeadLetters(ctx context.Context, status DeadLetterStatus) ([]DeadLetter, error) {
+	rows, err := repo.listDeadLetters.QueryContext(ctx, repo.args(sql.Named("status", status))...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+	return scanDeadLetters(rows)
+}
 
-	conn, err := db.Conn(ctx)
+// SetDeadLetterStatus implements DeadLetterQueue
+func (repo *sqlRepository) SetDeadLetterStatus(ctx context.Context, orderID string, status DeadLetterStatus) error {
+	res, err := repo.updateDeadLetter.ExecContext(ctx, repo.args(
+		sql.Named("status", status),
+		sql.Named("orderId", orderID),
+	)...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := res.RowsAffected()
+	if err == nil && updated == 0 {
+		err = ErrDeadLetterNotFound
+	}
+	return err
+}
+
+func scanDeadLetters(rows *sql.Rows) ([]DeadLetter, error) {
+	deadLetters := make([]DeadLetter, 0)
+	for rows.Next() {
+		var d DeadLetter
+		var deadAtString, eventString string
+		err := rows.Scan(
+			&d.OrderID,
+			&d.State,
+			&d.Attempts,
+			&d.Error,
+			&d.Status,
+			&deadAtString,
+			&eventString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.Time, err = time.Parse(sortableTimeFormat, deadAtString)
+		if err != nil {
+			return nil, err
+		}
+		d.Event = json.RawMessage(eventString)
+		deadLetters = append(deadLetters, d)
+	}
+	return deadLetters, rows.Err()
+}
+
+var _ DeadLetterQueue = (*sqlRepository)(nil)
+
+// RecordSubmission implements SubmissionStore
+func (repo *sqlRepository) RecordSubmission(ctx context.Context, s Submission) error {
+	_, err := repo.recordSubmission.ExecContext(ctx, repo.args(
+		sql.Named("orderId", s.OrderID),
+		sql.Named("resubmissions", s.Resubmissions),
+		sql.Named("jobId", s.JobID),
+		sql.Named("endpoint", s.Endpoint),
+		sql.Named("submittedAt", s.Time.UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// Submission implements SubmissionStore
+func (repo *sqlRepository) Submission(ctx context.Context, orderID string, resubmissions uint) (Submission, bool, error) {
+	rows, err := repo.retrieveSubmission.QueryContext(ctx, repo.args(
+		sql.Named("orderId", orderID),
+		sql.Named("resubmissions", resubmissions),
+	)...)
+	if err != nil {
+		return Submission{}, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Submission{}, false, rows.Err()
+	}
+
+	var s Submission
+	var submittedAtString string
+	err = rows.Scan(&s.OrderID, &s.Resubmissions, &s.JobID, &s.Endpoint, &submittedAtString)
+	if err != nil {
+		return Submission{}, false, err
+	}
+	s.Time, err = time.Parse(sortableTimeFormat, submittedAtString)
+	return s, err == nil, err
+}
+
+var _ SubmissionStore = (*sqlRepository)(nil)
+
+// Orders implements OrderStore
+func (repo *sqlRepository) Orders(ctx context.Context, filter OrderFilter) ([]Order, error) {
+	state := -1
+	if filter.State != nil {
+		state = int(*filter.State)
+	}
+
+	rows, err := repo.listOrders.QueryContext(ctx, repo.args(
+		sql.Named("state", state),
+		sql.Named("limit", filter.Limit),
+		sql.Named("offset", filter.Offset),
+	)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	insertEvent, err := conn.PrepareContext(ctx, Query("insert_event"))
+	events, err := scanEvents(rows)
 	if err != nil {
 		return nil, err
 	}
 
-	eventExists, err := conn.PrepareContext(ctx, Query("event_exists"))
+	orders := make([]Order, 0, len(events))
+	for _, e := range events {
+		orders = append(orders, newOrder(e))
+	}
+	return orders, nil
+}
+
+// Order implements OrderStore
+func (repo *sqlRepository) Order(ctx context.Context, orderID common.Hash) (Order, error) {
+	rows, err := repo.retrieveOrder.QueryContext(ctx, repo.args(sql.Named("orderId", orderID.Bytes()))...)
+	if err != nil {
+		return Order{}, err
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return Order{}, err
+	} else if len(events) == 0 {
+		return Order{}, ErrOrderNotFound
+	}
+
+	order := orderHistory(events)
+	order.Postings, err = repo.OrderPostings(ctx, order.ID)
+	return order, err
+}
+
+var _ OrderStore = (*sqlRepository)(nil)
+
+// OrderCounts implements OrderCountStore
+func (repo *sqlRepository) OrderCounts(ctx context.Context) ([]OrderCount, error) {
+	rows, err := repo.countOrders.QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	counts := []OrderCount{}
+	for rows.Next() {
+		var count OrderCount
+		var oldestString string
+		if err = rows.Scan(&count.State, &count.Count, &oldestString); err != nil {
+			return nil, err
+		}
+		if oldestString != "" {
+			count.Oldest, err = time.Parse(sortableTimeFormat, oldestString)
+			if err != nil {
+				return nil, err
+			}
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+var _ OrderCountStore = (*sqlRepository)(nil)
 
-	retrieveEvents, err := conn.PrepareContext(ctx, Query("retrieve_events"))
+// Timelines implements TimelineStore
+func (repo *sqlRepository) Timelines(ctx context.Context, since time.Time) ([]Timeline, error) {
+	rows, err := repo.retrieveTimelines.QueryContext(ctx, repo.args(
+		sql.Named("paid", OrderStatePaid),
+		sql.Named("refunded", OrderStateRefunded),
+		sql.Named("since", since.UTC().Format(sortableTimeFormat)),
+	)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &sqlRepository{
-		db:             db,
-		insertEvent:    insertEvent,
-		eventExists:    eventExists,
-		retrieveEvents: retrieveEvents,
-	}, nil
+	timelines := []Timeline{}
+	for rows.Next() {
+		var timelineString string
+		if err = rows.Scan(&timelineString); err != nil {
+			return nil, err
+		}
+		var timeline Timeline
+		if err = json.Unmarshal([]byte(timelineString), &timeline); err != nil {
+			return nil, err
+		}
+		timelines = append(timelines, timeline)
+	}
+	return timelines, rows.Err()
 }
 
-func Reload[E Event](repo Repository, state OrderState) ([]E, error) {
-	events, err := repo.Reload(state)
+var _ TimelineStore = (*sqlRepository)(nil)
+
+// Payments implements PaymentStore
+func (repo *sqlRepository) Payments(ctx context.Context, since time.Time) ([]Payment, error) {
+	rows, err := repo.retrievePayments.QueryContext(ctx, repo.args(
+		sql.Named("paid", OrderStatePaid),
+		sql.Named("refunded", OrderStateRefunded),
+		sql.Named("since", since.UTC().Format(sortableTimeFormat)),
+	)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	casts := make([]E, 0, len(events))
-	for _, event := range events {
-		casts = append(casts, event.(E))
+	payments := []Payment{}
+	for rows.Next() {
+		var orderID, token []byte
+		var price string
+		payment := Payment{}
+		if err = rows.Scan(&orderID, &payment.State, &token, &price); err != nil {
+			return nil, err
+		}
+		amount, ok := new(big.Int).SetString(price, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid price %q", price)
+		}
+		payment.OrderID = common.BytesToHash(orderID).Hex()
+		payment.Token = common.BytesToAddress(token)
+		payment.Amount = amount
+		payments = append(payments, payment)
 	}
-	return casts, nil
+	return payments, rows.Err()
 }
 
-func ReloadToChan[E Event](repo Repository, state OrderState, out chan<- Event) error {
-	events, err := Reload[E](repo, state)
+var _ PaymentStore = (*sqlRepository)(nil)
+
+// Heartbeat implements PartitionStore
+func (repo *sqlRepository) Heartbeat(ctx context.Context, owner string, now, until time.Time) error {
+	_, err := repo.upsertPartitionMember.ExecContext(ctx, repo.args(
+		sql.Named("owner", owner),
+		sql.Named("expiresAt", until.UnixMilli()),
+	)...)
 	if err != nil {
 		return err
 	}
-	for _, event := range events {
-		out <- event
+
+	_, err = repo.renewPartitionLeases.ExecContext(ctx, repo.args(
+		sql.Named("expiresAt", until.UnixMilli()),
+		sql.Named("owner", owner),
+		sql.Named("now", now.UnixMilli()),
+	)...)
+	return err
+}
+
+// Members implements PartitionStore
+func (repo *sqlRepository) Members(ctx context.Context, at time.Time) ([]string, error) {
+	rows, err := repo.listPartitionMembers.QueryContext(ctx, repo.args(sql.Named("now", at.UnixMilli()))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []string{}
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+		members = append(members, owner)
+	}
+	return members, rows.Err()
+}
+
+// PartitionLeases implements PartitionStore
+func (repo *sqlRepository) PartitionLeases(ctx context.Context) ([]PartitionLease, error) {
+	rows, err := repo.listPartitionLeases.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leases := []PartitionLease{}
+	for rows.Next() {
+		var lease PartitionLease
+		var expiresAt int64
+		if err := rows.Scan(&lease.Partition, &lease.Owner, &expiresAt); err != nil {
+			return nil, err
+		}
+		lease.ExpiresAt = time.UnixMilli(expiresAt)
+		leases = append(leases, lease)
+	}
+	return leases, rows.Err()
+}
+
+// ClaimPartition implements PartitionStore
+func (repo *sqlRepository) ClaimPartition(ctx context.Context, partition uint, owner string, now, until time.Time) (bool, error) {
+	result, err := repo.claimPartition.ExecContext(ctx, repo.args(
+		sql.Named("partitionId", partition),
+		sql.Named("owner", owner),
+		sql.Named("expiresAt", until.UnixMilli()),
+		sql.Named("now", now.UnixMilli()),
+	)...)
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := result.RowsAffected()
+	return claimed > 0, err
+}
+
+// ReleasePartition implements PartitionStore
+func (repo *sqlRepository) ReleasePartition(ctx context.Context, partition uint, owner string) error {
+	_, err := repo.releasePartition.ExecContext(ctx, repo.args(
+		sql.Named("partitionId", partition),
+		sql.Named("owner", owner),
+	)...)
+	return err
+}
+
+var _ PartitionStore = (*sqlRepository)(nil)
+
+// LockPosting implements PostingLockStore
+func (repo *sqlRepository) LockPosting(ctx context.Context, orderID, owner string, now, until time.Time) (PostingLock, error) {
+	lock := PostingLock{OrderID: orderID}
+	_, err := repo.lockPosting.ExecContext(ctx, repo.args(
+		sql.Named("orderId", orderID),
+		sql.Named("owner", owner),
+		sql.Named("expiresAt", until.UnixMilli()),
+		sql.Named("now", now.UnixMilli()),
+	)...)
+	if err != nil {
+		return lock, err
+	}
+
+	var expiresAt int64
+	row := repo.retrievePostingLock.QueryRowContext(ctx, repo.args(sql.Named("orderId", orderID))...)
+	if err = row.Scan(&lock.Owner, &expiresAt, &lock.Posted); err != nil {
+		return lock, err
+	}
+	lock.ExpiresAt = time.UnixMilli(expiresAt).UTC()
+	return lock, nil
+}
+
+// UnlockPosting implements PostingLockStore
+func (repo *sqlRepository) UnlockPosting(ctx context.Context, orderID, owner string, posted bool) error {
+	_, err := repo.unlockPosting.ExecContext(ctx, repo.args(
+		sql.Named("posted", posted),
+		sql.Named("orderId", orderID),
+		sql.Named("owner", owner),
+	)...)
+	return err
+}
+
+var _ PostingLockStore = (*sqlRepository)(nil)
+
+// SaveCheckpoint implements BlockCheckpointStore
+func (repo *sqlRepository) SaveCheckpoint(ctx context.Context, contract common.Address, block uint64) error {
+	_, err := repo.saveCheckpoint.ExecContext(ctx, repo.args(
+		sql.Named("contract", contract.Hex()),
+		sql.Named("block", int64(block)),
+		sql.Named("updatedAt", time.Now().UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// Checkpoint implements BlockCheckpointStore
+func (repo *sqlRepository) Checkpoint(ctx context.Context, contract common.Address) (uint64, bool, error) {
+	var block int64
+	err := repo.retrieveCheckpoint.QueryRowContext(ctx, repo.args(sql.Named("contract", contract.Hex()))...).Scan(&block)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return uint64(block), err == nil, err
+}
+
+var _ BlockCheckpointStore = (*sqlRepository)(nil)
+
+// RecordGasSpend implements GasSpendStore
+func (repo *sqlRepository) RecordGasSpend(ctx context.Context, txn common.Hash, day string, gasUsed uint64, cost *big.Int) error {
+	_, err := repo.recordGasSpend.ExecContext(ctx, repo.args(
+		sql.Named("txn", txn.Hex()),
+		sql.Named("day", day),
+		sql.Named("gasUsed", int64(gasUsed)),
+		sql.Named("cost", cost.String()),
+	)...)
+	return err
+}
+
+// GasSpend implements GasSpendStore
+func (repo *sqlRepository) GasSpend(ctx context.Context, day string) (GasSpend, error) {
+	spend := GasSpend{Day: day, Cost: new(big.Int)}
+	rows, err := repo.retrieveGasSpend.QueryContext(ctx, repo.args(sql.Named("day", day))...)
+	if err != nil {
+		return spend, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gasUsed int64
+		var cost string
+		if err := rows.Scan(&gasUsed, &cost); err != nil {
+			return spend, err
+		}
+		wei, ok := new(big.Int).SetString(cost, 10)
+		if !ok {
+			return spend, fmt.Errorf("invalid gas cost %q", cost)
+		}
+		spend.Transactions++
+		spend.GasUsed += uint64(gasUsed)
+		spend.Cost.Add(spend.Cost, wei)
+	}
+	return spend, rows.Err()
+}
+
+var _ GasSpendStore = (*sqlRepository)(nil)
+
+// SaveMediation implements MediationStore
+func (repo *sqlRepository) SaveMediation(ctx context.Context, m Mediation) error {
+	_, err := repo.saveMediation.ExecContext(ctx, repo.args(
+		sql.Named("orderId", m.OrderID),
+		sql.Named("requestor", m.Requestor.Hex()),
+		sql.Named("orderNumber", m.OrderNumber),
+		sql.Named("state", m.State),
+		sql.Named("reason", m.Reason),
+		sql.Named("jobId", m.JobID),
+		sql.Named("verdict", string(m.Verdict)),
+		sql.Named("attempts", m.Attempts),
+		sql.Named("error", m.Error),
+		sql.Named("originalFailed", m.OriginalFailed),
+		sql.Named("originalResult", m.OriginalResult),
+		sql.Named("mediatorFailed", m.MediatorFailed),
+		sql.Named("mediatorResult", m.MediatorResult),
+		sql.Named("updatedAt", m.Time.UTC().Format(sortableTimeFormat)),
+		sql.Named("event", string(m.Event)),
+	)...)
+	return err
+}
+
+// Mediation implements MediationStore
+func (repo *sqlRepository) Mediation(ctx context.Context, orderID string) (Mediation, error) {
+	rows, err := repo.retrieveMediation.QueryContext(ctx, repo.args(sql.Named("orderId", orderID))...)
+	if err != nil {
+		return Mediation{}, err
+	}
+	defer rows.Close()
+
+	mediations, err := scanMediations(rows)
+	if err != nil {
+		return Mediation{}, err
+	} else if len(mediations) == 0 {
+		return Mediation{}, ErrMediationNotFound
+	}
+	return mediations[0], nil
+}
+
+// Mediations implements MediationStore
+func (repo *sqlRepository) Mediations(ctx context.Context, state MediationState) ([]Mediation, error) {
+	rows, err := repo.retrieveMediations.QueryContext(ctx, repo.args(sql.Named("state", state))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMediations(rows)
+}
+
+func scanMediations(rows *sql.Rows) ([]Mediation, error) {
+	mediations := make([]Mediation, 0)
+	for rows.Next() {
+		var m Mediation
+		var requestor, verdict, updatedAtString, eventString string
+		err := rows.Scan(
+			&m.OrderID,
+			&requestor,
+			&m.OrderNumber,
+			&m.State,
+			&m.Reason,
+			&m.JobID,
+			&verdict,
+			&m.Attempts,
+			&m.Error,
+			&m.OriginalFailed,
+			&m.OriginalResult,
+			&m.MediatorFailed,
+			&m.MediatorResult,
+			&updatedAtString,
+			&eventString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		m.Time, err = time.Parse(sortableTimeFormat, updatedAtString)
+		if err != nil {
+			return nil, err
+		}
+		m.Requestor = common.HexToAddress(requestor)
+		m.Verdict = MediationVerdict(verdict)
+		m.Event = json.RawMessage(eventString)
+		mediations = append(mediations, m)
+	}
+	return mediations, rows.Err()
+}
+
+var _ MediationStore = (*sqlRepository)(nil)
+
+// SaveVerification implements VerificationStore
+func (repo *sqlRepository) SaveVerification(ctx context.Context, v Verification) error {
+	_, err := repo.saveVerification.ExecContext(ctx, repo.args(
+		sql.Named("orderId", v.OrderID),
+		sql.Named("status", v.Status),
+		sql.Named("jobId", v.JobID),
+		sql.Named("originalResult", v.OriginalResult),
+		sql.Named("verifierResult", v.VerifierResult),
+		sql.Named("error", v.Error),
+		sql.Named("updatedAt", v.Time.UTC().Format(sortableTimeFormat)),
+		sql.Named("event", string(v.Event)),
+	)...)
+	return err
+}
+
+// Verification implements VerificationStore
+func (repo *sqlRepository) Verification(ctx context.Context, orderID string) (Verification, error) {
+	rows, err := repo.retrieveVerification.QueryContext(ctx, repo.args(sql.Named("orderId", orderID))...)
+	if err != nil {
+		return Verification{}, err
+	}
+	defer rows.Close()
+
+	verifications, err := scanVerifications(rows)
+	if err != nil {
+		return Verification{}, err
+	} else if len(verifications) == 0 {
+		return Verification{}, ErrVerificationNotFound
+	}
+	return verifications[0], nil
+}
+
+// Verifications implements VerificationStore
+func (repo *sqlRepository) Verifications(ctx context.Context, status VerificationStatus) ([]Verification, error) {
+	rows, err := repo.retrieveVerifications.QueryContext(ctx, repo.args(sql.Named("status", status))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanVerifications(rows)
+}
+
+func scanVerifications(rows *sql.Rows) ([]Verification, error) {
+	verifications := make([]Verification, 0)
+	for rows.Next() {
+		var v Verification
+		var updatedAtString, eventString string
+		err := rows.Scan(
+			&v.OrderID,
+			&v.Status,
+			&v.JobID,
+			&v.OriginalResult,
+			&v.VerifierResult,
+			&v.Error,
+			&updatedAtString,
+			&eventString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		v.Time, err = time.Parse(sortableTimeFormat, updatedAtString)
+		if err != nil {
+			return nil, err
+		}
+		v.Event = json.RawMessage(eventString)
+		verifications = append(verifications, v)
+	}
+	return verifications, rows.Err()
+}
+
+var _ VerificationStore = (*sqlRepository)(nil)
+
+// SavePin implements PinStore
+func (repo *sqlRepository) SavePin(ctx context.Context, pin Pin) error {
+	_, err := repo.savePin.ExecContext(ctx, repo.args(
+		sql.Named("cid", pin.CID),
+		sql.Named("orderId", pin.OrderID),
+		sql.Named("requestId", pin.RequestID),
+		sql.Named("status", pin.Status),
+		sql.Named("attempts", pin.Attempts),
+		sql.Named("error", pin.Error),
+		sql.Named("updatedAt", pin.Time.UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// Pin implements PinStore
+func (repo *sqlRepository) Pin(ctx context.Context, cid string) (Pin, error) {
+	rows, err := repo.retrievePin.QueryContext(ctx, repo.args(sql.Named("cid", cid))...)
+	if err != nil {
+		return Pin{}, err
+	}
+	defer rows.Close()
+
+	pins, err := scanPins(rows)
+	if err != nil {
+		return Pin{}, err
+	} else if len(pins) == 0 {
+		return Pin{}, ErrPinNotFound
+	}
+	return pins[0], nil
+}
+
+// Pins implements PinStore
+func (repo *sqlRepository) Pins(ctx context.Context, status PinStatus) ([]Pin, error) {
+	rows, err := repo.retrievePins.QueryContext(ctx, repo.args(sql.Named("status", status))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPins(rows)
+}
+
+func scanPins(rows *sql.Rows) ([]Pin, error) {
+	pins := make([]Pin, 0)
+	for rows.Next() {
+		var pin Pin
+		var updatedAtString string
+		err := rows.Scan(
+			&pin.CID,
+			&pin.OrderID,
+			&pin.RequestID,
+			&pin.Status,
+			&pin.Attempts,
+			&pin.Error,
+			&updatedAtString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		pin.Time, err = time.Parse(sortableTimeFormat, updatedAtString)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+	return pins, rows.Err()
+}
+
+var _ PinStore = (*sqlRepository)(nil)
+
+// SavePosting implements PostingStore
+func (repo *sqlRepository) SavePosting(ctx context.Context, posting Posting) error {
+	_, err := repo.savePosting.ExecContext(ctx, repo.args(
+		sql.Named("orderId", posting.OrderID),
+		sql.Named("txHash", posting.Transaction),
+		sql.Named("state", posting.State),
+		sql.Named("status", posting.Status),
+		sql.Named("blockNumber", posting.Block),
+		sql.Named("confirmations", posting.Confirmations),
+		sql.Named("updatedAt", posting.Time.UTC().Format(sortableTimeFormat)),
+	)...)
+	return err
+}
+
+// Postings implements PostingStore
+func (repo *sqlRepository) Postings(ctx context.Context, status PostingStatus) ([]Posting, error) {
+	rows, err := repo.retrievePostings.QueryContext(ctx, repo.args(sql.Named("status", status))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPostings(rows)
+}
+
+// OrderPostings implements PostingStore
+func (repo *sqlRepository) OrderPostings(ctx context.Context, orderID string) ([]Posting, error) {
+	rows, err := repo.retrieveOrderPostings.QueryContext(ctx, repo.args(sql.Named("orderId", orderID))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPostings(rows)
+}
+
+func scanPostings(rows *sql.Rows) ([]Posting, error) {
+	postings := make([]Posting, 0)
+	for rows.Next() {
+		var posting Posting
+		var updatedAtString string
+		err := rows.Scan(
+			&posting.OrderID,
+			&posting.Transaction,
+			&posting.State,
+			&posting.Status,
+			&posting.Block,
+			&posting.Confirmations,
+			&updatedAtString,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posting.Time, err = time.Parse(sortableTimeFormat, updatedAtString)
+		if err != nil {
+			return nil, err
+		}
+		postings = append(postings, posting)
+	}
+	return postings, rows.Err()
+}
+
+var _ PostingStore = (*sqlRepository)(nil)
+
+// args returns the passed parameters in the form the database driver expects.
+func (repo *sqlRepository) args(named ...sql.NamedArg) []any {
+	args := make([]any, 0, len(named))
+	for _, arg := range named {
+		if repo.named {
+			args = append(args, arg)
+		} else {
+			args = append(args, arg.Value)
+		}
+	}
+	return args
+}
+
+func NewSQLiteRepository(ctx context.Context, path string) (Repository, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrate(ctx, db, "sqlite/migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return newSQLRepository(ctx, db, "", true)
+}
+
+// NewPostgresRepository connects to the Postgres database at the passed DSN
+// and brings its schema up to date. Multiple bridges can share one database.
+func NewPostgresRepository(ctx context.Context, dsn string) (Repository, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrate(ctx, db, "postgres/migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return newSQLRepository(ctx, db, "postgres/", false)
+}
+
+// newSQLRepository prepares the queries found in the passed directory of the
+// embedded SQL files against the database.
+func newSQLRepository(ctx context.Context, db *sql.DB, dir string, named bool) (*sqlRepository, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	insertEvent, err := conn.PrepareContext(ctx, Query(dir+"insert_event"))
+	if err != nil {
+		return nil, err
+	}
+
+	eventExists, err := conn.PrepareContext(ctx, Query(dir+"event_exists"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveEvents, err := conn.PrepareContext(ctx, Query(dir+"retrieve_events"))
+	if err != nil {
+		return nil, err
+	}
+
+	recordDelivery, err := conn.PrepareContext(ctx, Query(dir+"record_delivery"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveDeliveries, err := conn.PrepareContext(ctx, Query(dir+"retrieve_deliveries"))
+	if err != nil {
+		return nil, err
+	}
+
+	addDeadLetter, err := conn.PrepareContext(ctx, Query(dir+"add_dead_letter"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveDeadLetter, err := conn.PrepareContext(ctx, Query(dir+"retrieve_dead_letter"))
+	if err != nil {
+		return nil, err
+	}
+
+	listDeadLetters, err := conn.PrepareContext(ctx, Query(dir+"retrieve_dead_letters"))
+	if err != nil {
+		return nil, err
+	}
+
+	updateDeadLetter, err := conn.PrepareContext(ctx, Query(dir+"update_dead_letter"))
+	if err != nil {
+		return nil, err
+	}
+
+	recordSubmission, err := conn.PrepareContext(ctx, Query(dir+"record_submission"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveSubmission, err := conn.PrepareContext(ctx, Query(dir+"retrieve_submission"))
+	if err != nil {
+		return nil, err
+	}
+
+	listOrders, err := conn.PrepareContext(ctx, Query(dir+"list_orders"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveOrder, err := conn.PrepareContext(ctx, Query(dir+"retrieve_order"))
+	if err != nil {
+		return nil, err
+	}
+
+	upsertPartitionMember, err := conn.PrepareContext(ctx, Query(dir+"upsert_partition_member"))
+	if err != nil {
+		return nil, err
+	}
+
+	listPartitionMembers, err := conn.PrepareContext(ctx, Query(dir+"list_partition_members"))
+	if err != nil {
+		return nil, err
+	}
+
+	renewPartitionLeases, err := conn.PrepareContext(ctx, Query(dir+"renew_partition_leases"))
+	if err != nil {
+		return nil, err
+	}
+
+	listPartitionLeases, err := conn.PrepareContext(ctx, Query(dir+"list_partition_leases"))
+	if err != nil {
+		return nil, err
+	}
+
+	claimPartition, err := conn.PrepareContext(ctx, Query(dir+"claim_partition"))
+	if err != nil {
+		return nil, err
+	}
+
+	releasePartition, err := conn.PrepareContext(ctx, Query(dir+"release_partition"))
+	if err != nil {
+		return nil, err
+	}
+
+	lockPosting, err := conn.PrepareContext(ctx, Query(dir+"lock_posting"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrievePostingLock, err := conn.PrepareContext(ctx, Query(dir+"retrieve_posting_lock"))
+	if err != nil {
+		return nil, err
+	}
+
+	unlockPosting, err := conn.PrepareContext(ctx, Query(dir+"unlock_posting"))
+	if err != nil {
+		return nil, err
+	}
+
+	saveCheckpoint, err := conn.PrepareContext(ctx, Query(dir+"save_checkpoint"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveCheckpoint, err := conn.PrepareContext(ctx, Query(dir+"retrieve_checkpoint"))
+	if err != nil {
+		return nil, err
+	}
+
+	recordGasSpend, err := conn.PrepareContext(ctx, Query(dir+"record_gas_spend"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveGasSpend, err := conn.PrepareContext(ctx, Query(dir+"retrieve_gas_spend"))
+	if err != nil {
+		return nil, err
+	}
+
+	saveMediation, err := conn.PrepareContext(ctx, Query(dir+"save_mediation"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveMediation, err := conn.PrepareContext(ctx, Query(dir+"retrieve_mediation"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveMediations, err := conn.PrepareContext(ctx, Query(dir+"retrieve_mediations"))
+	if err != nil {
+		return nil, err
+	}
+
+	saveVerification, err := conn.PrepareContext(ctx, Query(dir+"save_verification"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveVerification, err := conn.PrepareContext(ctx, Query(dir+"retrieve_verification"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveVerifications, err := conn.PrepareContext(ctx, Query(dir+"retrieve_verifications"))
+	if err != nil {
+		return nil, err
+	}
+
+	savePin, err := conn.PrepareContext(ctx, Query(dir+"save_pin"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrievePin, err := conn.PrepareContext(ctx, Query(dir+"retrieve_pin"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrievePins, err := conn.PrepareContext(ctx, Query(dir+"retrieve_pins"))
+	if err != nil {
+		return nil, err
+	}
+
+	savePosting, err := conn.PrepareContext(ctx, Query(dir+"save_posting"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrievePostings, err := conn.PrepareContext(ctx, Query(dir+"retrieve_postings"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveOrderPostings, err := conn.PrepareContext(ctx, Query(dir+"retrieve_order_postings"))
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit, err := conn.PrepareContext(ctx, Query(dir+"record_audit"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveAudit, err := conn.PrepareContext(ctx, Query(dir+"retrieve_audit"))
+	if err != nil {
+		return nil, err
+	}
+
+	cacheJob, err := conn.PrepareContext(ctx, Query(dir+"cache_job"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveCachedJob, err := conn.PrepareContext(ctx, Query(dir+"retrieve_cached_job"))
+	if err != nil {
+		return nil, err
+	}
+
+	countOrders, err := conn.PrepareContext(ctx, Query(dir+"count_orders"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveTimelines, err := conn.PrepareContext(ctx, Query(dir+"retrieve_timelines"))
+	if err != nil {
+		return nil, err
+	}
+
+	retrievePayments, err := conn.PrepareContext(ctx, Query(dir+"retrieve_payments"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlRepository{
+		db:                 db,
+		conn:               conn,
+		named:              named,
+		insertEvent:        insertEvent,
+		eventExists:        eventExists,
+		retrieveEvents:     retrieveEvents,
+		recordDelivery:     recordDelivery,
+		retrieveDeliveries: retrieveDeliveries,
+		addDeadLetter:      addDeadLetter,
+		retrieveDeadLetter: retrieveDeadLetter,
+		listDeadLetters:    listDeadLetters,
+		updateDeadLetter:   updateDeadLetter,
+		recordSubmission:   recordSubmission,
+		retrieveSubmission: retrieveSubmission,
+		listOrders:         listOrders,
+		retrieveOrder:      retrieveOrder,
+		countOrders:        countOrders,
+		retrieveTimelines:  retrieveTimelines,
+		retrievePayments:   retrievePayments,
+
+		upsertPartitionMember: upsertPartitionMember,
+		listPartitionMembers:  listPartitionMembers,
+		renewPartitionLeases:  renewPartitionLeases,
+		listPartitionLeases:   listPartitionLeases,
+		claimPartition:        claimPartition,
+		releasePartition:      releasePartition,
+
+		lockPosting:         lockPosting,
+		retrievePostingLock: retrievePostingLock,
+		unlockPosting:       unlockPosting,
+
+		saveCheckpoint:     saveCheckpoint,
+		retrieveCheckpoint: retrieveCheckpoint,
+
+		recordGasSpend:   recordGasSpend,
+		retrieveGasSpend: retrieveGasSpend,
+
+		saveMediation:      saveMediation,
+		retrieveMediation:  retrieveMediation,
+		retrieveMediations: retrieveMediations,
+
+		saveVerification:      saveVerification,
+		retrieveVerification:  retrieveVerification,
+		retrieveVerifications: retrieveVerifications,
+
+		savePin:      savePin,
+		retrievePin:  retrievePin,
+		retrievePins: retrievePins,
+
+		savePosting:           savePosting,
+		retrievePostings:      retrievePostings,
+		retrieveOrderPostings: retrieveOrderPostings,
+
+		recordAudit:   recordAudit,
+		retrieveAudit: retrieveAudit,
+
+		cacheJob:          cacheJob,
+		retrieveCachedJob: retrieveCachedJob,
+	}, nil
+}
+
+// Close implements io.Closer, closing the database once every statement that
+// has been started has finished.
+func (repo *sqlRepository) Close() error {
+	connErr := repo.conn.Close()
+	if err := repo.db.Close(); err != nil {
+		return err
+	}
+	return connErr
+}
+
+// migrate applies, in name order, each of the migrations in the passed
+// directory of the embedded SQL files that has not already been applied.
+func migrate(ctx context.Context, db *sql.DB, dir string) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (name TEXT PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := fs.Glob(sqlFiles, path.Join("sql", dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(migrations)
+
+	for _, migration := range migrations {
+		name := path.Base(migration)
+
+		var applied bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE name = $1)`, name).Scan(&applied)
+		if err != nil {
+			return err
+		} else if applied {
+			continue
+		}
+
+		contents, err := sqlFiles.ReadFile(migration)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, string(contents)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (name) VALUES ($1)`, name); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Reload[E Event](repo Repository, state OrderState) ([]E, error) {
+	events, err := repo.Reload(state)
+	if err != nil {
+		return nil, err
+	}
+
+	casts := make([]E, 0, len(events))
+	for _, event := range events {
+		casts = append(casts, event.(E))
+	}
+	return casts, nil
+}
+
+// ReloadToChan reloads the events in the passed state onto the channel, until
+// they have all been sent or the context is cancelled.
+func ReloadToChan[E Event](ctx context.Context, repo Repository, state OrderState, out chan<- Event) error {
+	events, err := Reload[E](repo, state)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return nil
+		}
 	}
 	return nil
 }
@@ -2,10 +2,13 @@ package bridge
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/require"
 )
@@ -86,3 +89,201 @@ func TestOldVersionsAreNotReloaded(t *testing.T) {
 	require.NoError(t, err)
 	require.Empty(t, events)
 }
+
+func TestPostgresRepository(t *testing.T) {
+	dsn, found := os.LookupEnv("POSTGRES_TEST_DSN")
+	if !found {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+
+	repo, err := NewPostgresRepository(context.Background(), dsn)
+	require.NoError(t, err)
+
+	e := exampleEvent().JobCreated(model.NewJob())
+	require.NoError(t, repo.Save(e))
+
+	exists, err := repo.Exists(e)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	events, err := repo.Reload(OrderStateRunning)
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+}
+
+func TestResultsAreReloaded(t *testing.T) {
+	repo := repository(t)
+	results := []cid.Cid{cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")}
+	e := exampleEvent().JobCreated(model.NewJob()).Completed(results[0], "", "", 0).WithResults(results)
+	require.NoError(t, repo.Save(e))
+
+	events, err := Reload[BacalhauJobCompletedEvent](repo, OrderStateCompleted)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, results, events[0].Results(context.Background()))
+}
+
+func TestExecutionsAreReloaded(t *testing.T) {
+	repo := repository(t)
+	executions := []Execution{
+		{NodeID: "QmNode1", State: "Completed", Result: "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"},
+		{NodeID: "QmNode2", State: "Failed", Status: "out of memory", ExitCode: 137},
+	}
+	e := exampleEvent().JobCreated(model.NewJob()).WithExecutions(executions).JobError("partly failed")
+	require.NoError(t, repo.Save(e))
+
+	events, err := Reload[BacalhauJobFailedEvent](repo, OrderStateJobError)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, executions, events[0].Executions())
+}
+
+func TestFailureReasonsAreReloaded(t *testing.T) {
+	repo := repository(t)
+	e := exampleEvent().JobCreated(model.NewJob()).JobFailed(FailureReasonTimeout, "timed out", "InProgress; QmNode: Running")
+	require.NoError(t, repo.Save(e.Failed(e.Error())))
+
+	events, err := Reload[ContractFailedEvent](repo, OrderStateFailed)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, FailureReasonTimeout, events[0].FailureReason())
+	require.Equal(t, "InProgress; QmNode: Running", events[0].StateMessage())
+	require.Equal(t, "timed out", events[0].Error())
+}
+
+func TestSubmissionsAreRecordedOnce(t *testing.T) {
+	store := repository(t).(SubmissionStore)
+	ctx := context.Background()
+
+	_, found, err := store.Submission(ctx, "0x01", 0)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, store.RecordSubmission(ctx, Submission{OrderID: "0x01", JobID: "first"}))
+	require.NoError(t, store.RecordSubmission(ctx, Submission{OrderID: "0x01", JobID: "second"}))
+	require.NoError(t, store.RecordSubmission(ctx, Submission{OrderID: "0x01", Resubmissions: 1, JobID: "retry"}))
+
+	submission, found, err := store.Submission(ctx, "0x01", 0)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "first", submission.JobID)
+
+	submission, found, err = store.Submission(ctx, "0x01", 1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "retry", submission.JobID)
+}
+
+func TestOrderHistory(t *testing.T) {
+	repo := repository(t)
+	store := repo.(OrderStore)
+	ctx := context.Background()
+	result := cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
+	first := walEvent(1)
+	require.NoError(t, repo.Save(first))
+	job := model.NewJob()
+	job.Metadata.ID = "job-1"
+	running := first.JobCreated(job)
+	require.NoError(t, repo.Save(running))
+	running.AddAttempt()
+	require.NoError(t, repo.Save(running))
+	require.NoError(t, repo.Save(running.Completed(result, "", "", 0).Paid()))
+
+	second := walEvent(2)
+	require.NoError(t, repo.Save(second))
+
+	order, err := store.Order(ctx, first.OrderId())
+	require.NoError(t, err)
+	require.Equal(t, "Paid", order.State)
+	require.Equal(t, "job-1", order.JobID)
+	require.Equal(t, []string{result.String()}, order.Results)
+	require.Len(t, order.Transitions, 3)
+	require.Equal(t, "Submitted", order.Transitions[0].State)
+	require.Equal(t, "Running", order.Transitions[1].State)
+	require.Equal(t, "Paid", order.Transitions[2].State)
+	require.False(t, order.Transitions[0].Time.IsZero())
+
+	_, err = store.Order(ctx, walEvent(3).OrderId())
+	require.ErrorIs(t, err, ErrOrderNotFound)
+
+	orders, err := store.Orders(ctx, OrderFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, second.OrderId().Hex(), orders[0].ID)
+
+	paid := OrderStatePaid
+	orders, err = store.Orders(ctx, OrderFilter{State: &paid, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, first.OrderId().Hex(), orders[0].ID)
+
+	orders, err = store.Orders(ctx, OrderFilter{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, first.OrderId().Hex(), orders[0].ID)
+}
+
+func TestBlockCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(BlockCheckpointStore)
+	contract := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+
+	_, found, err := store.Checkpoint(ctx, contract)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, store.SaveCheckpoint(ctx, contract, 100))
+	require.NoError(t, store.SaveCheckpoint(ctx, contract, 200))
+	block, found, err := store.Checkpoint(ctx, contract)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(200), block)
+
+	_, found, err = store.Checkpoint(ctx, common.Address{})
+	require.NoError(t, err)
+	require.False(t, found, "checkpoints should be kept per contract")
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	audit := repo.(AuditLog)
+
+	start := time.Now().UTC().Truncate(time.Second)
+	order := common.HexToHash("0x01").Hex()
+	entries := []AuditEntry{
+		{OrderID: order, Action: AuditActionAccepted, Time: start},
+		{OrderID: order, Action: AuditActionSubmitted, JobID: "job-1", Time: start.Add(time.Second)},
+		{OrderID: common.HexToHash("0x02").Hex(), Action: AuditActionRejected, Reason: "Rejected", Time: start.Add(2 * time.Second)},
+		{OrderID: order, Action: AuditActionPosted, JobID: "job-1", TxHash: "0xabc", Results: []string{"QmResult"}, Time: start.Add(3 * time.Second)},
+	}
+	for _, entry := range entries {
+		require.NoError(t, audit.RecordAudit(ctx, entry))
+	}
+
+	all, err := audit.AuditEntries(ctx, AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	require.Equal(t, AuditActionAccepted, all[0].Action)
+	require.Equal(t, []string{"QmResult"}, all[3].Results)
+	require.Equal(t, "0xabc", all[3].TxHash)
+	require.True(t, start.Add(3*time.Second).Equal(all[3].Time))
+
+	forOrder, err := audit.AuditEntries(ctx, AuditFilter{OrderID: order, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, forOrder, 3)
+
+	rejected := AuditActionRejected
+	byAction, err := audit.AuditEntries(ctx, AuditFilter{Action: &rejected, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, byAction, 1)
+
+	between, err := audit.AuditEntries(ctx, AuditFilter{Since: start.Add(time.Second), Until: start.Add(3 * time.Second), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, between, 2)
+
+	// Entries can't be changed once they have been recorded.
+	_, err = repo.(*sqlRepository).db.ExecContext(ctx, `DELETE FROM audit_log`)
+	require.Error(t, err)
+}
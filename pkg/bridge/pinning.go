@@ -0,0 +1,407 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// A PinStatus is how far a pinning service has got with pinning a result.
+//
+//go:generate stringer -type=PinStatus --trimprefix=PinStatus
+type PinStatus int
+
+const (
+	// The result needs to be sent to the pinning service.
+	PinStatusRequested PinStatus = iota
+	// The pinning service has accepted the request but not started on it.
+	PinStatusQueued
+	// The pinning service is fetching the result.
+	PinStatusPinning
+	// The result is pinned and won't be garbage collected.
+	PinStatusPinned
+	// The result couldn't be pinned, and has been given up on.
+	PinStatusFailed
+)
+
+// PinStatuses returns every PinStatus.
+func PinStatuses() [5]PinStatus {
+	return [5]PinStatus{
+		PinStatusRequested,
+		PinStatusQueued,
+		PinStatusPinning,
+		PinStatusPinned,
+		PinStatusFailed,
+	}
+}
+
+// ParsePinStatus returns the pin status with the passed name, ignoring case.
+func ParsePinStatus(name string) (PinStatus, error) {
+	for _, status := range PinStatuses() {
+		if strings.EqualFold(name, status.String()) {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown pin status %q", name)
+}
+
+// A Pin is the pinning of one of the results of a completed order.
+type Pin struct {
+	CID     string `json:"cid"`
+	OrderID string `json:"orderId"`
+
+	// The pinning service's ID for the request, once it has been sent.
+	RequestID string    `json:"requestId,omitempty"`
+	Status    PinStatus `json:"status"`
+	Attempts  uint      `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+var (
+	ErrPinNotFound  = errors.New("pin not found")
+	ErrPinNotFailed = errors.New("pin has not failed")
+)
+
+// A PinStore keeps track of the results being pinned.
+type PinStore interface {
+	// SavePin saves the pin, replacing any for the same CID.
+	SavePin(ctx context.Context, pin Pin) error
+
+	// Pin returns the pin of the passed CID, or ErrPinNotFound.
+	Pin(ctx context.Context, cid string) (Pin, error)
+
+	// Pins returns every pin with the passed status.
+	Pins(ctx context.Context, status PinStatus) ([]Pin, error)
+}
+
+// A Pinner asks something to keep a copy of a result so that it isn't garbage
+// collected from IPFS.
+type Pinner interface {
+	// Pin asks for the CID to be pinned, returning an ID for the request and
+	// how far it has got.
+	Pin(ctx context.Context, result cid.Cid, name string) (requestID string, status PinStatus, err error)
+
+	// PinStatus returns how far an earlier request has got.
+	PinStatus(ctx context.Context, requestID string) (PinStatus, error)
+}
+
+// The well-known pinning services that implement the IPFS Pinning Service API.
+var pinningServices = map[string]string{
+	"pinata":       "https://api.pinata.cloud/psa",
+	"web3.storage": "https://api.web3.storage",
+}
+
+// NewPinner returns a Pinner for the named service, which is one of:
+//
+//	pinata, web3.storage   the well-known pinning service, using the token
+//	psa                    any IPFS Pinning Service API at the endpoint, using the token
+//	ipfs                   the IPFS node with the HTTP RPC API at the endpoint
+//
+// The endpoint of a well-known pinning service can be overridden.
+func NewPinner(service, endpoint, token string) (Pinner, error) {
+	service = strings.ToLower(service)
+	switch {
+	case service == "ipfs" && endpoint != "":
+		return NewIPFSNodePinner(endpoint), nil
+	case service == "psa" && endpoint != "":
+		return NewPinningService(endpoint, token), nil
+	case service == "ipfs" || service == "psa":
+		return nil, fmt.Errorf("pinning service %s needs an endpoint", service)
+	}
+
+	known, ok := pinningServices[service]
+	if !ok {
+		return nil, fmt.Errorf("unknown pinning service %q", service)
+	} else if endpoint == "" {
+		endpoint = known
+	}
+	return NewPinningService(endpoint, token), nil
+}
+
+type pinningService struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// NewPinningService returns a Pinner that uses a remote service implementing
+// the IPFS Pinning Service API, such as Pinata or web3.storage, with the
+// passed access token.
+func NewPinningService(endpoint, token string) Pinner {
+	return &pinningService{endpoint: strings.TrimSuffix(endpoint, "/"), token: token, client: http.DefaultClient}
+}
+
+type pinStatusResponse struct {
+	RequestID string `json:"requestid"`
+	Status    string `json:"status"`
+}
+
+func (response pinStatusResponse) status() (PinStatus, error) {
+	switch response.Status {
+	case "queued":
+		return PinStatusQueued, nil
+	case "pinning":
+		return PinStatusPinning, nil
+	case "pinned":
+		return PinStatusPinned, nil
+	case "failed":
+		return PinStatusFailed, nil
+	default:
+		return 0, fmt.Errorf("pinning service returned unknown status %q", response.Status)
+	}
+}
+
+func (s *pinningService) do(ctx context.Context, method, path string, body any) (pinStatusResponse, error) {
+	var response pinStatusResponse
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return response, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path, reader)
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer res.Body.Close()
+
+	// New pins are accepted rather than created.
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return response, fmt.Errorf("%s %s: %s: %s", method, req.URL, res.Status, bytes.TrimSpace(message))
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	return response, err
+}
+
+// Pin implements Pinner
+func (s *pinningService) Pin(ctx context.Context, result cid.Cid, name string) (string, PinStatus, error) {
+	response, err := s.do(ctx, http.MethodPost, "/pins", map[string]string{"cid": result.String(), "name": name})
+	if err != nil {
+		return "", 0, err
+	}
+	status, err := response.status()
+	return response.RequestID, status, err
+}
+
+// PinStatus implements Pinner
+func (s *pinningService) PinStatus(ctx context.Context, requestID string) (PinStatus, error) {
+	response, err := s.do(ctx, http.MethodGet, "/pins/"+url.PathEscape(requestID), nil)
+	if err != nil {
+		return 0, err
+	}
+	return response.status()
+}
+
+var _ Pinner = (*pinningService)(nil)
+
+type ipfsNodePinner struct {
+	url    string
+	client *http.Client
+}
+
+// NewIPFSNodePinner returns a Pinner that pins results on the IPFS node with
+// the HTTP RPC API at the passed URL. The node fetches the result before it
+// replies, so results are pinned as soon as they are asked for.
+func NewIPFSNodePinner(url string) Pinner {
+	return &ipfsNodePinner{url: strings.TrimSuffix(url, "/"), client: http.DefaultClient}
+}
+
+// Pin implements Pinner
+func (p *ipfsNodePinner) Pin(ctx context.Context, result cid.Cid, name string) (string, PinStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"/api/v0/pin/add?arg="+result.String(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var response struct {
+		Pins []string `json:"Pins"`
+	}
+	if err = doJSONRequest(p.client, req, &response); err != nil {
+		return "", 0, fmt.Errorf("ipfs: %w", err)
+	}
+	return result.String(), PinStatusPinned, nil
+}
+
+// PinStatus implements Pinner
+func (p *ipfsNodePinner) PinStatus(ctx context.Context, requestID string) (PinStatus, error) {
+	return PinStatusPinned, nil
+}
+
+var _ Pinner = (*ipfsNodePinner)(nil)
+
+var (
+	defaultPinAttempts   uint = 5
+	defaultPinRetryDelay      = time.Minute
+	defaultPinTimeout         = 10 * time.Minute
+)
+
+// A PinManager makes sure that the results of completed orders are pinned,
+// checking on requests until they are done and retrying those that fail.
+type PinManager struct {
+	Pinner Pinner
+	Store  PinStore
+
+	maxAttempts uint
+}
+
+// NewPinManager returns a PinManager that uses the passed pinner, giving up on
+// a result after it has failed to be pinned maxAttempts times.
+func NewPinManager(pinner Pinner, store PinStore, maxAttempts uint) *PinManager {
+	if maxAttempts == 0 {
+		maxAttempts = defaultPinAttempts
+	}
+	return &PinManager{Pinner: pinner, Store: store, maxAttempts: maxAttempts}
+}
+
+// WithPinning makes the workflow pin the results of completed orders.
+func WithPinning(pins *PinManager) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Pins = pins
+	}
+}
+
+// pinResults asks for every result of the completed order to be pinned, if
+// the workflow has been configured to pin results. The pins are sent to the
+// pinning service in the background.
+func (workflow *Workflow) pinResults(ctx context.Context, event BacalhauJobCompletedEvent) {
+	if workflow.Pins == nil {
+		return
+	}
+
+	results := event.Results(ctx)
+	if encrypted := event.EncryptedResult(ctx); encrypted.Defined() {
+		results = append(results, encrypted)
+	}
+	for _, result := range results {
+		err := workflow.Pins.request(ctx, event.OrderId().Hex(), result)
+		log.Ctx(ctx).WithLevel(level(err)).Err(err).Stringer("cid", result).Msg("Requesting pin")
+	}
+}
+
+// request records that the result needs pinning, unless it is already being
+// pinned.
+func (m *PinManager) request(ctx context.Context, orderID string, result cid.Cid) error {
+	_, err := m.Store.Pin(ctx, result.String())
+	if !errors.Is(err, ErrPinNotFound) {
+		return err
+	}
+
+	pin := Pin{CID: result.String(), OrderID: orderID, Status: PinStatusRequested, Time: time.Now().UTC()}
+	if err = m.Store.SavePin(ctx, pin); err == nil {
+		pinsTotal.WithLabelValues(pin.Status.String()).Inc()
+	}
+	return err
+}
+
+// Retry asks again for a result that failed to be pinned to be pinned.
+func (m *PinManager) Retry(ctx context.Context, cid string) error {
+	pin, err := m.Store.Pin(ctx, cid)
+	if err != nil {
+		return err
+	} else if pin.Status != PinStatusFailed {
+		return fmt.Errorf("%s: %w", cid, ErrPinNotFailed)
+	}
+
+	pin.Status = PinStatusRequested
+	pin.Attempts = 0
+	pin.Time = time.Now().UTC()
+	if err = m.Store.SavePin(ctx, pin); err == nil {
+		pinsTotal.WithLabelValues(pin.Status.String()).Inc()
+	}
+	return err
+}
+
+// check sends requested pins to the pinning service, and finds out how far
+// the ones already sent have got.
+func (m *PinManager) check(ctx context.Context) {
+	for _, status := range []PinStatus{PinStatusRequested, PinStatusQueued, PinStatusPinning} {
+		pins, err := m.Store.Pins(ctx, status)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Stringer("status", status).Msg("Unable to reload pins")
+			continue
+		}
+
+		for _, pin := range pins {
+			if ctx.Err() != nil {
+				return
+			}
+			m.step(ctx, pin)
+		}
+	}
+}
+
+// step moves the pin on, if it can be.
+func (m *PinManager) step(ctx context.Context, pin Pin) {
+	ctx = log.Ctx(ctx).With().Str("cid", pin.CID).Str("id", pin.OrderID).Logger().WithContext(ctx)
+	pinCtx, cancel := context.WithTimeout(ctx, defaultPinTimeout)
+	defer cancel()
+
+	var status PinStatus
+	var err error
+	if pin.Status == PinStatusRequested {
+		// Wait longer after each failed attempt.
+		if time.Since(pin.Time) < time.Duration(pin.Attempts)*defaultPinRetryDelay {
+			return
+		}
+
+		var result cid.Cid
+		if result, err = cid.Decode(pin.CID); err == nil {
+			pin.RequestID, status, err = m.Pinner.Pin(pinCtx, result, "lilypad-"+pin.OrderID)
+		}
+	} else {
+		status, err = m.Pinner.PinStatus(pinCtx, pin.RequestID)
+	}
+
+	if err == nil && status == PinStatusFailed {
+		err = errors.New("pinning service failed to pin the result")
+	}
+	if err != nil {
+		pin.Attempts++
+		pin.Error = err.Error()
+		pin.Status = PinStatusRequested
+		if pin.Attempts >= m.maxAttempts {
+			pin.Status = PinStatusFailed
+		}
+	} else if status == pin.Status {
+		return
+	} else {
+		pin.Status = status
+		pin.Error = ""
+	}
+
+	pin.Time = time.Now().UTC()
+	if saveErr := m.Store.SavePin(ctx, pin); saveErr != nil {
+		log.Ctx(ctx).Error().Err(saveErr).Msg("Unable to save pin")
+		return
+	}
+	pinsTotal.WithLabelValues(pin.Status.String()).Inc()
+	log.Ctx(ctx).WithLevel(level(err)).
+		Err(err).
+		Stringer("status", pin.Status).
+		Uint("attempts", pin.Attempts).
+		Msg("Pinning result")
+}
@@ -0,0 +1,84 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+// queuedPinner accepts pins into a queue, and reports them with the statuses
+// it is set up to return.
+type queuedPinner struct {
+	pinErr error
+	status PinStatus
+	pinned []cid.Cid
+}
+
+func (p *queuedPinner) Pin(ctx context.Context, result cid.Cid, name string) (string, PinStatus, error) {
+	if p.pinErr != nil {
+		return "", 0, p.pinErr
+	}
+	p.pinned = append(p.pinned, result)
+	return "request-" + result.String(), PinStatusQueued, nil
+}
+
+func (p *queuedPinner) PinStatus(ctx context.Context, requestID string) (PinStatus, error) {
+	return p.status, nil
+}
+
+func TestResultsArePinned(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(PinStore)
+	pinner := &queuedPinner{status: PinStatusPinning}
+	pins := NewPinManager(pinner, store, 3)
+
+	result := exampleResult
+	require.NoError(t, pins.request(ctx, "0x01", result))
+	require.NoError(t, pins.request(ctx, "0x01", result))
+
+	pins.check(ctx)
+	require.Equal(t, []cid.Cid{result}, pinner.pinned)
+	pin, err := store.Pin(ctx, result.String())
+	require.NoError(t, err)
+	require.Equal(t, PinStatusPinning, pin.Status)
+	require.Equal(t, "request-"+result.String(), pin.RequestID)
+
+	pinner.status = PinStatusPinned
+	pins.check(ctx)
+	pin, err = store.Pin(ctx, result.String())
+	require.NoError(t, err)
+	require.Equal(t, PinStatusPinned, pin.Status)
+	require.Len(t, pinner.pinned, 1)
+}
+
+func TestPinsAreGivenUpOn(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(PinStore)
+	pinner := &queuedPinner{pinErr: errors.New("service unavailable")}
+	pins := NewPinManager(pinner, store, 2)
+
+	result := exampleResult
+	require.NoError(t, pins.request(ctx, "0x01", result))
+
+	pins.check(ctx)
+	pin, err := store.Pin(ctx, result.String())
+	require.NoError(t, err)
+	require.Equal(t, PinStatusRequested, pin.Status)
+	require.Equal(t, uint(1), pin.Attempts)
+
+	// Retries wait longer after each attempt.
+	pin.Time = pin.Time.Add(-defaultPinRetryDelay)
+	require.NoError(t, store.SavePin(ctx, pin))
+	pins.check(ctx)
+	pin, err = store.Pin(ctx, result.String())
+	require.NoError(t, err)
+	require.Equal(t, PinStatusFailed, pin.Status)
+	require.Equal(t, "service unavailable", pin.Error)
+
+	require.NoError(t, pins.Retry(ctx, result.String()))
+	require.ErrorIs(t, pins.Retry(ctx, result.String()), ErrPinNotFailed)
+	require.ErrorIs(t, pins.Retry(ctx, "unknown"), ErrPinNotFound)
+}
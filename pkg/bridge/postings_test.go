@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+// chainReceipts is a ReceiptReader for a chain whose head and mined
+// transactions are set by the test.
+type chainReceipts struct {
+	head     uint64
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (c *chainReceipts) Receipt(ctx context.Context, txn common.Hash) (*types.Receipt, error) {
+	if receipt, found := c.receipts[txn]; found {
+		return receipt, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (c *chainReceipts) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.head, nil
+}
+
+func TestPostedTransactionsAreFollowedUntilFinal(t *testing.T) {
+	ctx := context.Background()
+	paidIn, refundedIn := common.Hash{0xaa}, common.Hash{0xbb}
+	contract := &mockContract{
+		CompleteHandler: func(_ context.Context, e BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+			return e.PaidIn(paidIn), nil
+		},
+		RefundHandler: func(_ context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+			return e.RefundedIn(refundedIn), nil
+		},
+	}
+
+	repo := repository(t)
+	chain := &chainReceipts{head: 10, receipts: map[common.Hash]*types.Receipt{}}
+	tracker := NewPostingTracker(repo.(PostingStore), chain, 3)
+	events := NewEventBus()
+	notifications, unsubscribe := events.Channel()
+	defer unsubscribe()
+	workflow := NewWorkflow(&mockRunner{}, contract, repo, WithPostingTracker(tracker), WithEventBus(events))
+
+	completed := walEvent(0x01)
+	completed.JobCreated(model.NewJob()).Completed(cid.Cid{}, "out", "", 0)
+	paid, _ := workflow.ProcessEvent(ctx, completed)
+	require.Equal(t, OrderStatePaid, paid.OrderState())
+
+	failed := walEvent(0x02)
+	failed.Failed("job failed")
+	refunded, _ := workflow.ProcessEvent(ctx, failed)
+	require.Equal(t, OrderStateRefunded, refunded.OrderState())
+
+	pending, err := tracker.Store.Postings(ctx, PostingStatusPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+
+	chain.receipts[paidIn] = &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)}
+	chain.receipts[refundedIn] = &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(10)}
+	tracker.check(ctx, events)
+
+	order, err := repo.(OrderStore).Order(ctx, paid.OrderId())
+	require.NoError(t, err)
+	require.Len(t, order.Postings, 1)
+	require.Equal(t, PostingStatusPending, order.Postings[0].Status)
+	require.Equal(t, uint64(1), order.Postings[0].Confirmations)
+
+	chain.head = 12
+	tracker.check(ctx, events)
+
+	order, err = repo.(OrderStore).Order(ctx, paid.OrderId())
+	require.NoError(t, err)
+	require.Equal(t, PostingStatusConfirmed, order.Postings[0].Status)
+	require.Equal(t, paidIn.Hex(), order.Postings[0].Transaction)
+	require.Equal(t, uint64(10), order.Postings[0].Block)
+
+	pending, err = tracker.Store.Postings(ctx, PostingStatusPending)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	published := map[string]string{}
+	for len(notifications) > 0 {
+		n := <-notifications
+		if n.Transaction != "" {
+			published[n.OrderID] = n.State
+		}
+	}
+	require.Equal(t, map[string]string{
+		paid.OrderId().Hex():     NotificationResultConfirmed,
+		refunded.OrderId().Hex(): NotificationResultPostFailed,
+	}, published)
+}
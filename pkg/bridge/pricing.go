@@ -0,0 +1,197 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// A PriceProfile is the least that the bridge will run jobs for that ask for
+// at most the profile's resources. Resources that aren't set can be asked for
+// in any amount, so a profile that shouldn't cover GPU jobs must set its GPUs
+// to zero.
+type PriceProfile struct {
+	Name      string                    `yaml:"name"`
+	Resources model.ResourceUsageConfig `yaml:"resources"`
+
+	// In the chain's native token.
+	MinPrice float64 `yaml:"minPrice"`
+
+	// In the currency of the policy's price oracle, which is needed to price
+	// orders paid for in an ERC-20 token. Without it, such orders must offer
+	// at least what MinPrice of the native token is worth.
+	MinFiatPrice float64 `yaml:"minFiatPrice"`
+}
+
+// A PricingPolicy declines orders whose offered price is less than the
+// minimum price of the resources their job asks for. Each job is priced by
+// the first profile that covers it, so profiles should be listed from the
+// smallest to the largest. Jobs that no profile covers are rejected.
+//
+// Orders are only checked if the contract says what they offered to pay.
+// Orders paid for in an ERC-20 token can only be checked, and are otherwise
+// rejected, if the policy has a price oracle that knows the token.
+type PricingPolicy struct {
+	Profiles []PriceProfile `yaml:"profiles"`
+
+	// What the tokens that orders are paid in are worth, if known.
+	Oracle PriceOracle `yaml:"-"`
+}
+
+// LoadPricingPolicy reads a PricingPolicy from the YAML file at the passed
+// path.
+func LoadPricingPolicy(path string) (*PricingPolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := new(PricingPolicy)
+	if err = yaml.Unmarshal(contents, policy); err != nil {
+		return nil, errors.Wrapf(err, "invalid pricing file %s", path)
+	}
+	for _, profile := range policy.Profiles {
+		if profile.Name == "" {
+			return nil, fmt.Errorf("invalid pricing file %s: every profile needs a name", path)
+		} else if profile.MinPrice < 0 {
+			return nil, fmt.Errorf("invalid pricing file %s: minPrice of profile %s must not be negative", path, profile.Name)
+		} else if profile.MinFiatPrice < 0 {
+			return nil, fmt.Errorf("invalid pricing file %s: minFiatPrice of profile %s must not be negative", path, profile.Name)
+		}
+	}
+	return policy, nil
+}
+
+// Profile returns the profile that prices jobs with the passed spec.
+func (p *PricingPolicy) Profile(spec model.Spec) (PriceProfile, bool) {
+	for _, profile := range p.Profiles {
+		if checkResources(spec, profile.Resources) == nil {
+			return profile, true
+		}
+	}
+	return PriceProfile{}, false
+}
+
+// Check returns a *Rejection if the offered price, in the smallest unit of the
+// passed token, doesn't cover a job with the passed spec. The zero address is
+// the chain's native token. Offers of nil are always accepted.
+func (p *PricingPolicy) Check(ctx context.Context, spec model.Spec, token common.Address, offered *big.Int) error {
+	if offered == nil {
+		return nil
+	}
+
+	profile, ok := p.Profile(spec)
+	if !ok {
+		return reject("no price profile covers the resources the job asks for")
+	}
+	if token == (common.Address{}) {
+		if minimum := ether(profile.MinPrice); offered.Cmp(minimum) < 0 {
+			return &Rejection{
+				Reason:        fmt.Sprintf("offered %s wei but %s jobs cost at least %s wei", offered, profile.Name, minimum),
+				FailureReason: FailureReasonUnderpriced,
+			}
+		} else if profile.MinFiatPrice == 0 {
+			return nil
+		}
+	}
+
+	minimum, err := p.fiatMinimum(ctx, profile)
+	if err != nil {
+		return reject("unable to price %s jobs: %s", profile.Name, err)
+	} else if minimum == 0 {
+		return nil
+	}
+	value, err := p.fiatValue(ctx, token, offered)
+	if err != nil {
+		return reject("unable to value the payment offered: %s", err)
+	}
+	if value < minimum {
+		currency := p.Oracle.Currency()
+		return &Rejection{
+			Reason:        fmt.Sprintf("offered %.2f %s but %s jobs cost at least %.2f %s", value, currency, profile.Name, minimum, currency),
+			FailureReason: FailureReasonUnderpriced,
+		}
+	}
+	return nil
+}
+
+// fiatMinimum returns the least that jobs priced by the profile cost in the
+// oracle's currency.
+func (p *PricingPolicy) fiatMinimum(ctx context.Context, profile PriceProfile) (float64, error) {
+	if profile.MinFiatPrice > 0 || profile.MinPrice == 0 {
+		return profile.MinFiatPrice, nil
+	}
+	return p.fiatValue(ctx, common.Address{}, ether(profile.MinPrice))
+}
+
+// fiatValue returns what the amount of the token is worth in the oracle's
+// currency.
+func (p *PricingPolicy) fiatValue(ctx context.Context, token common.Address, amount *big.Int) (float64, error) {
+	if p.Oracle == nil {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownToken, token)
+	}
+	price, err := p.Oracle.TokenPrice(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return price.Value(amount), nil
+}
+
+// A DecliningContract can say on-chain that an order was declined because
+// the price it offered was too low, rather than returning an error.
+type DecliningContract interface {
+	// Decline declines the failed order, returning its refunded event.
+	Decline(ctx context.Context, event ContractFailedEvent) (ContractRefundedEvent, error)
+}
+
+var _ DecliningContract = (*realContract)(nil)
+
+// WithPricing makes the workflow decline orders that offer less than the
+// policy's minimum price for their job.
+func WithPricing(policy *PricingPolicy) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Pricing = policy
+	}
+}
+
+// checkPrice declines orders that offered too little for their job, if the
+// workflow has a pricing policy.
+func (workflow *Workflow) checkPrice(ctx context.Context, e ContractSubmittedEvent) error {
+	if workflow.Pricing == nil {
+		return nil
+	}
+
+	spec, err := e.Spec()
+	if err != nil {
+		// The runner refuses specs it can't read, with a better error.
+		return nil
+	}
+	err = workflow.Pricing.Check(ctx, spec, e.PaymentToken(), e.OfferedPrice())
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Stringer("offered", e.OfferedPrice()).Stringer("token", e.PaymentToken()).Msg("Declining order")
+		ordersDeclined.Inc()
+	}
+	return err
+}
+
+// refund returns the error of a failed order on-chain, declining it instead if
+// it offered too little and the contract can say so. Contracts that turn out
+// not to support declining are refunded instead, before the error is ever
+// classified, so that the order isn't dead-lettered for it.
+func (workflow *Workflow) refund(ctx context.Context, event ContractFailedEvent) (ContractRefundedEvent, error) {
+	if declining, ok := workflow.Contract.(DecliningContract); ok && event.FailureReason() == FailureReasonUnderpriced {
+		refunded, err := declining.Decline(ctx, event)
+		if !errors.Is(err, ErrNotSupportedByContract) {
+			return refunded, err
+		}
+		log.Ctx(ctx).Debug().Err(err).Msg("Refunding order that couldn't be declined")
+	}
+	return workflow.Contract.Refund(ctx, event)
+}
@@ -0,0 +1,114 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+profiles:
+  - name: small
+    resources: {CPU: "1", Memory: 2Gb, GPU: "0"}
+    minPrice: 0.03
+  - name: gpu
+    resources: {GPU: "1"}
+    minPrice: 0.5
+`), 0644))
+	policy, err := LoadPricingPolicy(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	small := model.Spec{Resources: model.ResourceUsageConfig{CPU: "500m"}}
+	gpu := model.Spec{Resources: model.ResourceUsageConfig{CPU: "500m", GPU: "1"}}
+	huge := model.Spec{Resources: model.ResourceUsageConfig{GPU: "8"}}
+
+	profile, ok := policy.Profile(small)
+	require.True(t, ok)
+	require.Equal(t, "small", profile.Name)
+	profile, ok = policy.Profile(gpu)
+	require.True(t, ok)
+	require.Equal(t, "gpu", profile.Name)
+
+	native := common.Address{}
+	require.NoError(t, policy.Check(ctx, small, native, ether(0.03)))
+	require.NoError(t, policy.Check(ctx, gpu, native, nil), "orders that don't say what they paid aren't checked")
+
+	var rejection *Rejection
+	err = policy.Check(ctx, gpu, native, ether(0.03))
+	require.True(t, errors.As(err, &rejection))
+	require.Equal(t, FailureReasonUnderpriced, rejection.FailureReason)
+
+	err = policy.Check(ctx, huge, native, big.NewInt(0).Lsh(big.NewInt(1), 100))
+	require.True(t, errors.As(err, &rejection))
+	require.Equal(t, FailureReasonUnknown, rejection.FailureReason)
+}
+
+func TestPricingPolicyValuesTokenPayments(t *testing.T) {
+	ctx := context.Background()
+	usdc := common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	spec := model.Spec{Resources: model.ResourceUsageConfig{CPU: "500m"}}
+
+	policy := &PricingPolicy{Profiles: []PriceProfile{{Name: "small", MinPrice: 0.01}}}
+	var rejection *Rejection
+	err := policy.Check(ctx, spec, usdc, big.NewInt(50_000_000))
+	require.True(t, errors.As(err, &rejection), "tokens can't be priced without an oracle")
+	require.Equal(t, FailureReasonUnknown, rejection.FailureReason)
+
+	policy.Oracle = &StaticPriceOracle{CurrencyCode: "USD", Tokens: []TokenPrice{
+		{Symbol: "ETH", Decimals: 18, Price: 2000},
+		{Symbol: "USDC", Address: usdc, Decimals: 6, Price: 1},
+	}}
+	require.NoError(t, policy.Check(ctx, spec, usdc, big.NewInt(20_010_000)), "20.01 USDC covers 0.01 ETH at 2000 USD")
+	err = policy.Check(ctx, spec, usdc, big.NewInt(19_990_000))
+	require.True(t, errors.As(err, &rejection))
+	require.Equal(t, FailureReasonUnderpriced, rejection.FailureReason)
+
+	policy.Profiles[0].MinFiatPrice = 25
+	require.Error(t, policy.Check(ctx, spec, usdc, big.NewInt(20_010_000)))
+	require.Error(t, policy.Check(ctx, spec, common.Address{}, ether(0.01)), "native payments must cover the fiat minimum too")
+	require.NoError(t, policy.Check(ctx, spec, common.Address{}, ether(0.013)))
+
+	err = policy.Check(ctx, spec, common.HexToAddress("0x01"), big.NewInt(1e18))
+	require.True(t, errors.As(err, &rejection))
+	require.Equal(t, FailureReasonUnknown, rejection.FailureReason)
+}
+
+func TestFiatPricesNeedAnOracle(t *testing.T) {
+	dir := t.TempDir()
+	profiles := filepath.Join(dir, "pricing.yaml")
+	require.NoError(t, os.WriteFile(profiles, []byte(`
+profiles:
+  - name: small
+    minFiatPrice: 25
+`), 0644))
+	tokens := filepath.Join(dir, "tokens.yaml")
+	require.NoError(t, os.WriteFile(tokens, []byte(`
+currency: USD
+tokens:
+  - symbol: ETH
+    price: 2000
+`), 0644))
+
+	config := DefaultConfig()
+	config.Chain = ChainConfig{
+		RPCEndpoint:      "ws://localhost:8545",
+		ChainID:          31337,
+		ContractAddress:  "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		WalletPrivateKey: testPrivateKey,
+	}
+	config.Pricing.ProfilesFile = profiles
+	require.ErrorContains(t, config.Validate(), "minFiatPrice")
+
+	config.Pricing.TokensFile = tokens
+	require.NoError(t, config.Validate())
+}
@@ -0,0 +1,253 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// How urgently an order's job should be submitted when there are more orders
+// waiting than can be submitted at once.
+//
+//go:generate stringer -type=Priority --trimprefix=Priority
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// How many priorities there are, and so how many lanes the submission queue
+// has.
+const priorityLanes = int(PriorityHigh) + 1
+
+// LilypadPriorityAnnotation, with a priority name after it such as
+// "lilypad-priority:low", amongst the annotations of an order's spec asks for
+// the order to be given that priority. Orders can only ask for a lower
+// priority than their price would give them, so that background work can make
+// way for everything else.
+const LilypadPriorityAnnotation string = "lilypad-priority:"
+
+// A PriorityPolicy decides the priority of orders from the price they offered
+// to pay. Orders offering at least the high price are high priority, and
+// those offering less than the low price are low priority. Either price may
+// be nil, and orders whose price isn't known, or that were paid for in an
+// ERC-20 token rather than the native token, are normal priority.
+type PriorityPolicy struct {
+	HighPrice *big.Int
+	LowPrice  *big.Int
+
+	// How long an order can wait behind orders of a higher priority before
+	// it is submitted anyway, so that a steady stream of high priority
+	// orders can't hold up the rest forever. Zero means orders always wait.
+	MaxWait time.Duration
+}
+
+// WithPriorityLanes makes the workflow queue orders waiting to be submitted by
+// priority, so that higher priority orders jump ahead during congestion.
+// Without it, every order is normal priority and is submitted in turn.
+func WithPriorityLanes(policy PriorityPolicy) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.priorities = policy
+	}
+}
+
+// Priority returns the priority of the order.
+func (policy PriorityPolicy) Priority(e ContractSubmittedEvent) Priority {
+	priority := PriorityNormal
+	if price := e.OfferedPrice(); price != nil && e.PaymentToken() == (common.Address{}) {
+		if policy.HighPrice != nil && price.Cmp(policy.HighPrice) >= 0 {
+			priority = PriorityHigh
+		} else if policy.LowPrice != nil && price.Cmp(policy.LowPrice) < 0 {
+			priority = PriorityLow
+		}
+	}
+
+	if spec, err := e.Spec(); err == nil {
+		for _, annotation := range spec.Annotations {
+			if !strings.HasPrefix(annotation, LilypadPriorityAnnotation) {
+				continue
+			}
+			name := strings.TrimPrefix(annotation, LilypadPriorityAnnotation)
+			if asked, err := parsePriority(name); err == nil && asked < priority {
+				priority = asked
+			}
+		}
+	}
+	return priority
+}
+
+// parsePriority returns the priority with the passed name, ignoring case.
+func parsePriority(name string) (Priority, error) {
+	for priority := PriorityLow; priority <= PriorityHigh; priority++ {
+		if strings.EqualFold(name, priority.String()) {
+			return priority, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q", name)
+}
+
+// priority returns the priority that the event should be submitted with.
+func (workflow *Workflow) priority(e Event) Priority {
+	submitted, ok := e.(ContractSubmittedEvent)
+	if !ok {
+		return PriorityNormal
+	}
+	return workflow.priorities.Priority(submitted)
+}
+
+type queuedSubmission struct {
+	event  Event
+	queued time.Time
+
+	// The order's turn in its lane. Orders are taken from a lane in order
+	// of their turns.
+	turn float64
+}
+
+// A submissionQueue holds the orders waiting to be submitted in a lane for
+// each priority. Orders are taken from the highest priority lane that has
+// any, unless an order in a lower lane has waited for longer than allowed.
+//
+// Within a lane, clients take turns in proportion to their weights, so that a
+// client with many orders waiting doesn't hold up one with a few. Each order
+// is given a turn after its client's last order, which is further on for
+// clients with less weight, and no earlier than the turn of the order last
+// taken from the lane.
+type submissionQueue struct {
+	mu      sync.Mutex
+	lanes   [priorityLanes][]queuedSubmission
+	size    int
+	maxSize int
+	maxWait time.Duration
+
+	// The weight of each client, the turn of the order last taken from each
+	// lane, and the turn after each client's last order in each lane.
+	weights map[common.Address]float64
+	turn    [priorityLanes]float64
+	next    [priorityLanes]map[common.Address]float64
+
+	// Has a value whenever an order may have been added since the queue was
+	// last found to be empty.
+	ready chan struct{}
+}
+
+func newSubmissionQueue(maxSize int, maxWait time.Duration, weights map[common.Address]float64) *submissionQueue {
+	q := &submissionQueue{maxSize: maxSize, maxWait: maxWait, weights: weights, ready: make(chan struct{}, 1)}
+	for lane := range q.next {
+		q.next[lane] = map[common.Address]float64{}
+	}
+	return q
+}
+
+// orderClient returns who made the order.
+func orderClient(e Event) common.Address {
+	if submitted, ok := e.(ContractSubmittedEvent); ok {
+		return submitted.OrderRequestor()
+	}
+	return common.Address{}
+}
+
+// push adds the event to the lane for its priority, returning false if the
+// queue is full.
+func (q *submissionQueue) push(e Event, priority Priority) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size >= q.maxSize {
+		return false
+	}
+
+	from, weight := orderClient(e), 1.0
+	if w, ok := q.weights[from]; ok && w > 0 {
+		weight = w
+	}
+	turn := q.turn[priority]
+	if next := q.next[priority][from]; next > turn {
+		turn = next
+	}
+	q.next[priority][from] = turn + 1/weight
+
+	q.lanes[priority] = append(q.lanes[priority], queuedSubmission{event: e, queued: now(), turn: turn})
+	q.size++
+	submissionQueueDepth.WithLabelValues(priority.String()).Set(float64(len(q.lanes[priority])))
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// pop waits for an event to be queued and removes the one to submit next,
+// returning false if the context is cancelled first.
+func (q *submissionQueue) pop(ctx context.Context) (Event, bool) {
+	for {
+		if e, ok := q.take(); ok {
+			return e, true
+		}
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// take removes the event to submit next, if there is one.
+func (q *submissionQueue) take() (Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size == 0 {
+		return nil, false
+	}
+
+	// The order that has waited the longest past the limit goes first,
+	// whatever its priority. Otherwise the next turn in the highest priority
+	// lane does. Lanes are kept in the order that orders were queued.
+	lane, index := -1, 0
+	if q.maxWait > 0 {
+		starved := now().Add(-q.maxWait)
+		for priority, queued := range q.lanes {
+			if len(queued) > 0 && queued[0].queued.Before(starved) &&
+				(lane < 0 || queued[0].queued.Before(q.lanes[lane][0].queued)) {
+				lane = priority
+			}
+		}
+	}
+	for priority := priorityLanes - 1; lane < 0 && priority >= 0; priority-- {
+		if len(q.lanes[priority]) > 0 {
+			lane = priority
+			for i, queued := range q.lanes[lane] {
+				if queued.turn < q.lanes[lane][index].turn {
+					index = i
+				}
+			}
+		}
+	}
+
+	queued := q.lanes[lane]
+	next := queued[index]
+	copy(queued[index:], queued[index+1:])
+	queued[len(queued)-1] = queuedSubmission{}
+	q.lanes[lane] = queued[:len(queued)-1]
+	q.size--
+
+	// Clients whose turn has passed start again from the lane's turn.
+	if next.turn > q.turn[lane] {
+		q.turn[lane] = next.turn
+	}
+	for client, turn := range q.next[lane] {
+		if turn <= q.turn[lane] {
+			delete(q.next[lane], client)
+		}
+	}
+
+	submissionQueueDepth.WithLabelValues(Priority(lane).String()).Set(float64(len(q.lanes[lane])))
+	return next.event, true
+}
@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func pricedEvent(t *testing.T, price string, annotations ...string) ContractSubmittedEvent {
+	e := exampleEvent()
+	e.(*event).orderPrice = price
+	if len(annotations) > 0 {
+		spec, err := e.Spec()
+		require.NoError(t, err)
+		spec.Annotations = append(spec.Annotations, annotations...)
+		raw, err := json.Marshal(spec)
+		require.NoError(t, err)
+		e = e.WithSpec(raw)
+	}
+	return e
+}
+
+func TestOrdersArePrioritisedByPrice(t *testing.T) {
+	policy := PriorityPolicy{HighPrice: big.NewInt(1000), LowPrice: big.NewInt(10)}
+
+	require.Equal(t, PriorityHigh, policy.Priority(pricedEvent(t, "1000")))
+	require.Equal(t, PriorityNormal, policy.Priority(pricedEvent(t, "999")))
+	require.Equal(t, PriorityLow, policy.Priority(pricedEvent(t, "9")))
+	require.Equal(t, PriorityNormal, policy.Priority(pricedEvent(t, "")), "orders without a price are normal priority")
+
+	// Orders can ask to be lower priority, but not higher.
+	require.Equal(t, PriorityLow, policy.Priority(pricedEvent(t, "1000", LilypadPriorityAnnotation+"low")))
+	require.Equal(t, PriorityNormal, policy.Priority(pricedEvent(t, "100", LilypadPriorityAnnotation+"High")))
+
+	require.Equal(t, PriorityNormal, PriorityPolicy{}.Priority(pricedEvent(t, "1000")))
+}
+
+func TestSubmissionQueueServesHigherPrioritiesFirst(t *testing.T) {
+	queue := newSubmissionQueue(3, 0, nil)
+	low, normal, high := exampleEvent(), exampleEvent(), exampleEvent()
+	require.True(t, queue.push(low, PriorityLow))
+	require.True(t, queue.push(normal, PriorityNormal))
+	require.True(t, queue.push(high, PriorityHigh))
+	require.False(t, queue.push(exampleEvent(), PriorityHigh), "the queue should be full")
+
+	for _, expected := range []Event{high, normal, low} {
+		e, ok := queue.take()
+		require.True(t, ok)
+		require.Same(t, expected, e)
+	}
+	_, ok := queue.take()
+	require.False(t, ok)
+}
+
+func TestSubmissionQueueDoesNotStarveLowPriorities(t *testing.T) {
+	queue := newSubmissionQueue(10, 10*time.Millisecond, nil)
+	low := exampleEvent()
+	require.True(t, queue.push(low, PriorityLow))
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, queue.push(exampleEvent(), PriorityHigh))
+
+	e, ok := queue.take()
+	require.True(t, ok)
+	require.Same(t, low, e, "the order that has waited too long should go first")
+}
@@ -0,0 +1,90 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// JobProgress is how far the job for a running order has got, as last seen on
+// the compute network.
+type JobProgress struct {
+	OrderID string `json:"orderId"`
+	JobID   string `json:"jobId"`
+
+	// What is happening on each node running the job, and how many of them
+	// have finished.
+	Executions []Execution `json:"executions"`
+	Completed  int         `json:"completed"`
+
+	// When the job was last checked.
+	Time time.Time `json:"time"`
+}
+
+func newJobProgress(e BacalhauJobRunningEvent) JobProgress {
+	progress := JobProgress{
+		OrderID:    e.OrderId().Hex(),
+		JobID:      e.JobID(),
+		Executions: e.Executions(),
+		Time:       time.Now().UTC(),
+	}
+	for _, execution := range progress.Executions {
+		if execution.State == model.ExecutionStateCompleted.String() {
+			progress.Completed++
+		}
+	}
+	return progress
+}
+
+// jobProgress remembers the progress of each running job, and when it was
+// last published.
+type jobProgress struct {
+	mu        sync.Mutex
+	jobs      map[common.Hash]JobProgress
+	published map[common.Hash]time.Time
+}
+
+// WithHeartbeat makes the workflow publish the progress of each running job on
+// its event bus this often, so that long-running jobs can be watched. Zero
+// means progress is only available from the workflow's Progress method.
+func WithHeartbeat(interval time.Duration) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.heartbeatInterval = interval
+	}
+}
+
+// Progress returns the last seen progress of the job for the order, or false
+// if the order isn't running.
+func (workflow *Workflow) Progress(orderID common.Hash) (JobProgress, bool) {
+	workflow.progress.mu.Lock()
+	defer workflow.progress.mu.Unlock()
+	progress, ok := workflow.progress.jobs[orderID]
+	return progress, ok
+}
+
+// heartbeat records the progress of the jobs that are still running after a
+// check, publishing it for those that haven't had a heartbeat for a while.
+// Jobs that are no longer running are forgotten.
+func (workflow *Workflow) heartbeat(ctx context.Context, running []BacalhauJobRunningEvent) {
+	p := &workflow.progress
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jobs := make(map[common.Hash]JobProgress, len(running))
+	published := make(map[common.Hash]time.Time, len(running))
+	for _, e := range running {
+		progress := newJobProgress(e)
+		jobs[e.OrderId()] = progress
+
+		last, seen := p.published[e.OrderId()]
+		if workflow.heartbeatInterval > 0 && (!seen || progress.Time.Sub(last) >= workflow.heartbeatInterval) {
+			workflow.Events.PublishProgress(ctx, progress)
+			last = progress.Time
+		}
+		published[e.OrderId()] = last
+	}
+	p.jobs, p.published = jobs, published
+}
@@ -0,0 +1,50 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeatsPublishProgress(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus()
+	notifications, unsubscribe := bus.Channel()
+	defer unsubscribe()
+
+	workflow := NewWorkflow(&mockRunner{}, &mockContract{}, repository(t), WithEventBus(bus), WithHeartbeat(time.Hour))
+	running := exampleEvent().JobCreated(model.NewJob()).WithExecutions([]Execution{
+		{NodeID: "QmFirst", State: model.ExecutionStateCompleted.String()},
+		{NodeID: "QmSecond", State: "BidAccepted"},
+	})
+
+	workflow.heartbeat(ctx, []BacalhauJobRunningEvent{running})
+	n := <-notifications
+	require.Equal(t, "Running", n.State)
+	require.NotNil(t, n.Progress)
+	require.Equal(t, 1, n.Progress.Completed)
+	require.Len(t, n.Progress.Executions, 2)
+
+	// Heartbeats are only sent once per interval.
+	workflow.heartbeat(ctx, []BacalhauJobRunningEvent{running})
+	require.Empty(t, notifications)
+
+	handler := OrdersHandler(nil, workflow)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, OrdersPath+"/"+running.OrderId().Hex()+"/progress", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	var progress JobProgress
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&progress))
+	require.Equal(t, "QmSecond", progress.Executions[1].NodeID)
+
+	// Jobs that have finished are forgotten.
+	workflow.heartbeat(ctx, nil)
+	_, found := workflow.Progress(running.OrderId())
+	require.False(t, found)
+}
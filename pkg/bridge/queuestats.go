@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// An OrderCount is how many orders are in a state, and when the one that has
+// been around the longest was first seen.
+type OrderCount struct {
+	State  OrderState
+	Count  int
+	Oldest time.Time
+}
+
+// An OrderCountStore counts the orders in each state.
+type OrderCountStore interface {
+	// OrderCounts returns the number of orders in each state that has any.
+	OrderCounts(ctx context.Context) ([]OrderCount, error)
+}
+
+// A QueueMonitor exports how many orders are in each state, how many are
+// waiting to be submitted, and how long the oldest of those has waited, so
+// that operators can alert on a growing backlog.
+type QueueMonitor struct {
+	store    OrderCountStore
+	interval time.Duration
+}
+
+// NewQueueMonitor returns a QueueMonitor that counts the orders in the store
+// every interval.
+func NewQueueMonitor(store OrderCountStore, interval time.Duration) *QueueMonitor {
+	return &QueueMonitor{store: store, interval: interval}
+}
+
+// Run counts the orders until the context is cancelled.
+func (m *QueueMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Update(ctx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to count orders")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Update counts the orders and sets the queue metrics.
+func (m *QueueMonitor) Update(ctx context.Context) error {
+	counts, err := m.store.OrderCounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	byState := map[OrderState]OrderCount{}
+	for _, count := range counts {
+		byState[count.State] = count
+	}
+	for _, state := range OrderStates() {
+		ordersByState.WithLabelValues(state.String()).Set(float64(byState[state].Count))
+	}
+
+	queued := byState[OrderStateSubmitted]
+	queueDepth.Set(float64(queued.Count))
+	if queued.Count > 0 && !queued.Oldest.IsZero() {
+		queueOldestAge.Set(time.Since(queued.Oldest).Seconds())
+	} else {
+		queueOldestAge.Set(0)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersAreCountedByState(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+
+	submitted := exampleEvent()
+	require.NoError(t, repo.Save(submitted))
+	running := walEvent(1)
+	require.NoError(t, repo.Save(running))
+	require.NoError(t, repo.Save(running.JobCreated(model.NewJob())))
+
+	counts, err := repo.(OrderCountStore).OrderCounts(ctx)
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	for _, count := range counts {
+		require.Contains(t, []OrderState{OrderStateSubmitted, OrderStateRunning}, count.State)
+		require.Equal(t, 1, count.Count)
+		require.WithinDuration(t, time.Now(), count.Oldest, time.Minute)
+	}
+
+	require.NoError(t, NewQueueMonitor(repo.(OrderCountStore), time.Minute).Update(ctx))
+}
@@ -0,0 +1,106 @@
+package bridge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ClientQuotas limit how much of the bridge any one client, the wallet that
+// made an order, can use, so that one busy client can't crowd out the rest.
+// Limits that are zero are off.
+type ClientQuotas struct {
+	// The most jobs a client can have running at once.
+	MaxRunning int
+
+	// The most jobs a client can have submitted in any hour.
+	MaxPerHour int
+
+	// How large a share of the submissions each client gets whilst orders
+	// are waiting to be submitted. Clients that aren't listed have a weight
+	// of one.
+	Weights map[common.Address]float64
+}
+
+// WithClientQuotas makes the workflow hold orders from clients that have used
+// up their quotas until they are under them again, and share submissions
+// fairly between clients whilst orders are waiting.
+func WithClientQuotas(quotas ClientQuotas) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.clientQuotas = quotas
+		workflow.slots.setMaxPerClient(quotas.MaxRunning)
+	}
+}
+
+// ParseClientWeights reads weights written as address=weight.
+func ParseClientWeights(entries []string) (map[common.Address]float64, error) {
+	weights := make(map[common.Address]float64, len(entries))
+	for _, entry := range entries {
+		address, weight, found := strings.Cut(entry, "=")
+		if !found || !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("client weight %q must be written as address=weight", entry)
+		}
+		value, err := strconv.ParseFloat(weight, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("weight of client %s must be a positive number", address)
+		}
+		weights[common.HexToAddress(address)] = value
+	}
+	return weights, nil
+}
+
+// clientRates remembers when each client's orders were submitted in the last
+// hour.
+type clientRates struct {
+	mu        sync.Mutex
+	submitted map[common.Address][]time.Time
+}
+
+// wait returns how long the client must wait before another of its orders can
+// be submitted without going over the hourly limit, or zero if one can be
+// submitted now.
+func (r *clientRates) wait(client common.Address, perHour int) time.Duration {
+	if perHour <= 0 {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	submitted := r.recent(client)
+	if len(submitted) < perHour {
+		return 0
+	}
+	clientQuotaWaits.WithLabelValues("hourly").Inc()
+	return submitted[len(submitted)-perHour].Add(time.Hour).Sub(now())
+}
+
+// record remembers that one of the client's orders was submitted.
+func (r *clientRates) record(client common.Address) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.submitted == nil {
+		r.submitted = map[common.Address][]time.Time{}
+	}
+	r.submitted[client] = append(r.recent(client), now())
+}
+
+// recent returns when the client's orders were submitted in the last hour,
+// forgetting those submitted before then. It must be called with the lock
+// held.
+func (r *clientRates) recent(client common.Address) []time.Time {
+	submitted := r.submitted[client]
+	since := now().Add(-time.Hour)
+	for len(submitted) > 0 && !submitted[0].After(since) {
+		submitted = submitted[1:]
+	}
+	if len(submitted) == 0 {
+		delete(r.submitted, client)
+		return nil
+	}
+	r.submitted[client] = submitted
+	return submitted
+}
@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func clientEvent(id byte, client common.Address) *event {
+	e := walEvent(id)
+	e.orderOwner = client.Bytes()
+	return e
+}
+
+var (
+	busyClient  = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	quietClient = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+func TestClientsAreLimitedToAnHourlyQuota(t *testing.T) {
+	var rates clientRates
+	require.Zero(t, rates.wait(busyClient, 2))
+
+	rates.record(busyClient)
+	rates.record(busyClient)
+	wait := rates.wait(busyClient, 2)
+	require.Greater(t, wait, 59*time.Minute)
+	require.LessOrEqual(t, wait, time.Hour)
+
+	require.Zero(t, rates.wait(quietClient, 2), "other clients have their own quota")
+	require.Zero(t, rates.wait(busyClient, 0), "zero means there is no limit")
+}
+
+func TestClientsAreLimitedInRunningJobs(t *testing.T) {
+	ctx := context.Background()
+	runner := &mockRunner{CreateHandler: SuccessfulCreate}
+	workflow := NewWorkflow(runner, &mockContract{}, repository(t),
+		WithClientQuotas(ClientQuotas{MaxRunning: 1}), WithJobCheckInterval(time.Minute))
+
+	first, _ := workflow.ProcessEvent(ctx, clientEvent(0x01, busyClient))
+	require.Equal(t, OrderStateRunning, first.OrderState())
+
+	held, wait := workflow.ProcessEvent(ctx, clientEvent(0x02, busyClient))
+	require.Equal(t, OrderStateSubmitted, held.OrderState(), "the client already has a job running")
+	require.Equal(t, time.Minute, wait)
+
+	other, _ := workflow.ProcessEvent(ctx, clientEvent(0x03, quietClient))
+	require.Equal(t, OrderStateRunning, other.OrderState(), "other clients can still run jobs")
+}
+
+func TestSubmissionQueueSharesTurnsBetweenClients(t *testing.T) {
+	queue := newSubmissionQueue(10, 0, map[common.Address]float64{quietClient: 2})
+	for id := byte(1); id <= 4; id++ {
+		require.True(t, queue.push(clientEvent(id, busyClient), PriorityNormal))
+	}
+	for id := byte(5); id <= 8; id++ {
+		require.True(t, queue.push(clientEvent(id, quietClient), PriorityNormal))
+	}
+
+	// The quiet client has twice the weight, so gets two turns for each of
+	// the busy client's, even though the busy client's orders came first.
+	var order []common.Address
+	for {
+		e, ok := queue.take()
+		if !ok {
+			break
+		}
+		order = append(order, orderClient(e))
+	}
+	require.Equal(t, []common.Address{
+		busyClient, quietClient, quietClient,
+		busyClient, quietClient, quietClient,
+		busyClient, busyClient,
+	}, order)
+}
+
+func TestParseClientWeights(t *testing.T) {
+	weights, err := ParseClientWeights([]string{busyClient.Hex() + "=0.5"})
+	require.NoError(t, err)
+	require.Equal(t, map[common.Address]float64{busyClient: 0.5}, weights)
+
+	for _, bad := range []string{"0x1234=1", busyClient.Hex(), busyClient.Hex() + "=0", busyClient.Hex() + "=x"} {
+		_, err := ParseClientWeights([]string{bad})
+		require.Error(t, err, bad)
+	}
+}
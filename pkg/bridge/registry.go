@@ -0,0 +1,182 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A TemplateSource is where the workflow finds the job templates that orders
+// ask for.
+type TemplateSource interface {
+	// Template returns the template with the passed name and version, or its
+	// latest version if the version is empty.
+	Template(name, version string) (*JobTemplate, bool)
+
+	// Templates returns every template, by name and then newest version
+	// first.
+	Templates() []*JobTemplate
+}
+
+var _ TemplateSource = (*TemplateLibrary)(nil)
+
+var commitHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// A TemplateRegistry is a TemplateSource that loads job templates from a
+// directory of a Git repository. The repository is always checked out at a
+// commit given by its full hash rather than a branch, so every bridge running
+// the same config runs exactly the same templates. The templates are swapped
+// for those of another commit whilst the bridge is running when the config is
+// reloaded.
+type TemplateRegistry struct {
+	repo  string
+	path  string
+	cache string
+
+	mu      sync.RWMutex
+	commit  string
+	library *TemplateLibrary
+}
+
+// NewTemplateRegistry returns a registry of the templates in the passed
+// directory of the Git repository at the URL, keeping its checkouts in the
+// cache directory. No templates are loaded until Sync is called.
+func NewTemplateRegistry(repo, path, cache string) *TemplateRegistry {
+	return &TemplateRegistry{
+		repo:    repo,
+		path:    path,
+		cache:   cache,
+		library: &TemplateLibrary{templates: map[string][]*JobTemplate{}},
+	}
+}
+
+// Commit returns the commit that the templates were loaded from, or empty if
+// none have been.
+func (r *TemplateRegistry) Commit() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.commit
+}
+
+// Template implements TemplateSource
+func (r *TemplateRegistry) Template(name, version string) (*JobTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.library.Template(name, version)
+}
+
+// Templates implements TemplateSource
+func (r *TemplateRegistry) Templates() []*JobTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.library.Templates()
+}
+
+// Sync loads the templates at the passed commit, fetching it from the
+// repository if it hasn't been already. The templates already loaded are kept
+// if those at the commit can't be loaded.
+func (r *TemplateRegistry) Sync(ctx context.Context, commit string) error {
+	if !commitHash.MatchString(commit) {
+		return fmt.Errorf("templates must be pinned to a full commit hash, not %q", commit)
+	} else if commit == r.Commit() {
+		return nil
+	}
+
+	checkout, err := r.checkout(ctx, commit)
+	if err != nil {
+		return err
+	}
+	library, err := LoadTemplates(filepath.Join(checkout, r.path))
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	previous := r.commit
+	r.commit, r.library = commit, library
+	r.mu.Unlock()
+
+	log.Ctx(ctx).Info().
+		Str("repo", r.repo).
+		Str("from", previous).
+		Str("to", commit).
+		Int("templates", len(library.Templates())).
+		Msg("Loaded job templates")
+	return nil
+}
+
+// checkout returns a directory holding the files of the repository at the
+// commit. Each commit is checked out into its own worktree, which is never
+// changed afterwards.
+func (r *TemplateRegistry) checkout(ctx context.Context, commit string) (string, error) {
+	// Git is run in different directories, so relative paths won't do.
+	cache, err := filepath.Abs(r.cache)
+	if err != nil {
+		return "", err
+	}
+	clone := filepath.Join(cache, "repo.git")
+	worktree := filepath.Join(cache, commit)
+	if _, err := os.Stat(worktree); err == nil {
+		return worktree, nil
+	}
+
+	if _, err := os.Stat(clone); os.IsNotExist(err) {
+		if err = os.MkdirAll(cache, 0755); err != nil {
+			return "", err
+		}
+		if _, err = git(ctx, cache, "clone", "--bare", "--quiet", r.repo, clone); err != nil {
+			return "", err
+		}
+	}
+
+	// A commit that isn't in the clone yet must be newer than it.
+	if _, err := git(ctx, clone, "cat-file", "-e", commit+"^{commit}"); err != nil {
+		if _, err = git(ctx, clone, "fetch", "--quiet", r.repo, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := git(ctx, clone, "worktree", "add", "--detach", "--force", worktree, commit); err != nil {
+		return "", err
+	}
+	return worktree, nil
+}
+
+// git runs git in the passed directory, returning its output or its error
+// message as the error.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Never wait for credentials that nobody is there to type.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// Reload implements Reloader
+func (r *TemplateRegistry) Reload(ctx context.Context, config Config) error {
+	return r.Sync(ctx, config.Bacalhau.TemplatesCommit)
+}
+
+var (
+	_ TemplateSource = (*TemplateRegistry)(nil)
+	_ Reloader       = (*TemplateRegistry)(nil)
+)
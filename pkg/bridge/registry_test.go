@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// commitTemplate commits a template with the passed version to the repository,
+// returning the hash of the commit.
+func commitTemplate(t *testing.T, repo, version string) string {
+	ctx := context.Background()
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "templates", "echo.yaml"), []byte(`
+name: echo
+version: "`+version+`"
+spec: {Engine: docker, Docker: {Image: ubuntu, Entrypoint: [echo, "`+version+`"]}}
+`), 0644))
+
+	_, err := git(ctx, repo, "add", "-A")
+	require.NoError(t, err)
+	_, err = git(ctx, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", version)
+	require.NoError(t, err)
+	commit, err := git(ctx, repo, "rev-parse", "HEAD")
+	require.NoError(t, err)
+	return strings.TrimSpace(commit)
+}
+
+func TestTemplateRegistryIsPinnedToCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := context.Background()
+	repo := t.TempDir()
+	_, err := git(ctx, repo, "init", "--quiet")
+	require.NoError(t, err)
+	first := commitTemplate(t, repo, "1")
+
+	registry := NewTemplateRegistry(repo, "templates", t.TempDir())
+	require.Error(t, registry.Sync(ctx, "main"))
+	require.NoError(t, registry.Sync(ctx, first))
+	template, ok := registry.Template("echo", "")
+	require.True(t, ok)
+	require.Equal(t, "1", template.Version)
+
+	// Newer commits are only loaded once the config asks for them.
+	second := commitTemplate(t, repo, "2")
+	template, _ = registry.Template("echo", "")
+	require.Equal(t, "1", template.Version)
+
+	config := DefaultConfig()
+	config.Bacalhau.TemplatesCommit = second
+	require.NoError(t, registry.Reload(ctx, config))
+	require.Equal(t, second, registry.Commit())
+	template, _ = registry.Template("echo", "")
+	require.Equal(t, "2", template.Version)
+	_, ok = registry.Template("echo", "1")
+	require.False(t, ok)
+
+	// Going back to an earlier commit uses its existing checkout.
+	require.NoError(t, registry.Sync(ctx, first))
+	template, _ = registry.Template("echo", "")
+	require.Equal(t, "1", template.Version)
+}
@@ -0,0 +1,285 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// A ForwardRequest is an EIP-2771 meta-transaction: a call that the bridge's
+// wallet has signed, for a relayer to send through a trusted forwarder
+// contract and pay the gas for.
+type ForwardRequest struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *hexutil.Big   `json:"value"`
+	Gas   *hexutil.Big   `json:"gas"`
+	Nonce *hexutil.Big   `json:"nonce"`
+	Data  hexutil.Bytes  `json:"data"`
+}
+
+// A Relayer sends signed meta-transactions on the bridge's behalf, so that the
+// bridge's wallet doesn't need to hold any of the chain's native token.
+type Relayer interface {
+	// Relay sends the signed request through the passed forwarder, returning
+	// the hash of the transaction that carries it.
+	Relay(ctx context.Context, forwarder common.Address, request ForwardRequest, signature []byte) (common.Hash, error)
+}
+
+// A ForwarderDomain identifies the forwarder contract that requests are signed
+// for, as its EIP-712 domain.
+type ForwarderDomain struct {
+	Name    string
+	Version string
+	ChainID *big.Int
+	Address common.Address
+}
+
+// The domain of OpenZeppelin's MinimalForwarder, the most common forwarder.
+const (
+	defaultForwarderName    = "MinimalForwarder"
+	defaultForwarderVersion = "0.0.1"
+)
+
+var (
+	eip712DomainType   = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	forwardRequestType = crypto.Keccak256([]byte("ForwardRequest(address from,address to,uint256 value,uint256 gas,uint256 nonce,bytes data)"))
+)
+
+// Hash returns the EIP-712 hash of the request, which is what is signed.
+func (d ForwarderDomain) Hash(request ForwardRequest) common.Hash {
+	separator := crypto.Keccak256(
+		eip712DomainType,
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		common.LeftPadBytes(d.ChainID.Bytes(), 32),
+		common.LeftPadBytes(d.Address.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		forwardRequestType,
+		common.LeftPadBytes(request.From.Bytes(), 32),
+		common.LeftPadBytes(request.To.Bytes(), 32),
+		common.LeftPadBytes(request.Value.ToInt().Bytes(), 32),
+		common.LeftPadBytes(request.Gas.ToInt().Bytes(), 32),
+		common.LeftPadBytes(request.Nonce.ToInt().Bytes(), 32),
+		crypto.Keccak256(request.Data),
+	)
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, separator, structHash)
+}
+
+type httpRelayer struct {
+	client *http.Client
+	url    string
+	apiKey string
+}
+
+// NewHTTPRelayer returns a Relayer that POSTs each signed request to the passed
+// URL as JSON, with the API key as a bearer token if there is one:
+//
+//	{"forwarder": "0x...", "request": {"from": "0x...", ...}, "signature": "0x..."}
+//
+// The response must be JSON with the hash of the transaction that was sent,
+// as {"txHash": "0x..."}. An OpenZeppelin Defender autotask behind a webhook,
+// or any service that sends the request to the forwarder's execute function,
+// can be used.
+func NewHTTPRelayer(url, apiKey string) Relayer {
+	return &httpRelayer{client: http.DefaultClient, url: url, apiKey: apiKey}
+}
+
+// Relay implements Relayer
+func (r *httpRelayer) Relay(ctx context.Context, forwarder common.Address, request ForwardRequest, signature []byte) (common.Hash, error) {
+	body, err := json.Marshal(map[string]any{
+		"forwarder": forwarder,
+		"request":   request,
+		"signature": hexutil.Bytes(signature),
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	var response struct {
+		TxHash common.Hash `json:"txHash"`
+	}
+	err = doJSONRequest(r.client, req, &response)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("relayer: %w", err)
+	} else if response.TxHash == (common.Hash{}) {
+		return common.Hash{}, errors.New("relayer: response has no transaction hash")
+	}
+	return response.TxHash, nil
+}
+
+var _ Relayer = (*httpRelayer)(nil)
+
+// Just enough of the forwarder's ABI to read the next nonce of an account.
+const forwarderABI = `[{"inputs":[{"internalType":"address","name":"from","type":"address"}],"name":"getNonce","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+// forwarderNonces reads the next nonce of an account from the forwarder, which
+// counts requests separately from the chain.
+type forwarderNonces struct {
+	client    ethereum.ContractCaller
+	forwarder common.Address
+	abi       abi.ABI
+}
+
+// PendingNonceAt implements NonceReader
+func (f forwarderNonces) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	data, err := f.abi.Pack("getNonce", account)
+	if err != nil {
+		return 0, err
+	}
+	out, err := f.client.CallContract(ctx, ethereum.CallMsg{To: &f.forwarder, Data: data}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	values, err := f.abi.Unpack("getNonce", out)
+	if err != nil {
+		return 0, err
+	}
+	return values[0].(*big.Int).Uint64(), nil
+}
+
+var _ NonceReader = forwarderNonces{}
+
+type relay struct {
+	relayer Relayer
+	domain  ForwarderDomain
+	signer  HashSigner
+	nonces  *NonceManager
+}
+
+type relayOptions struct {
+	relayer   Relayer
+	forwarder common.Address
+	name      string
+	version   string
+}
+
+// WithRelayer makes the contract send its transactions as meta-transactions
+// through the passed relayer and EIP-2771 forwarder, so that the relayer pays
+// for the gas. The contract must trust the forwarder. The name and version of
+// the forwarder's EIP-712 domain default to those of OpenZeppelin's
+// MinimalForwarder.
+func WithRelayer(relayer Relayer, forwarder common.Address, name, version string) ContractOption {
+	return func(opts *contractOptions) {
+		if name == "" {
+			name = defaultForwarderName
+		}
+		if version == "" {
+			version = defaultForwarderVersion
+		}
+		opts.relay = &relayOptions{relayer: relayer, forwarder: forwarder, name: name, version: version}
+	}
+}
+
+// newRelay returns the relay configured by the passed options, checking that
+// the forwarder exists and the signer can sign requests for it.
+func newRelay(ctx context.Context, client bind.ContractBackend, chainID *big.Int, signer Signer, opts *relayOptions) (*relay, error) {
+	hashSigner, ok := signer.(HashSigner)
+	if !ok {
+		return nil, fmt.Errorf("signer %T can't sign meta-transactions", signer)
+	}
+
+	code, err := client.CodeAt(ctx, opts.forwarder, nil)
+	if err != nil {
+		return nil, err
+	} else if len(code) == 0 {
+		return nil, fmt.Errorf("%w: no forwarder at %s", ErrNoContract, opts.forwarder)
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(forwarderABI))
+	if err != nil {
+		return nil, err
+	}
+
+	nonces := forwarderNonces{client: client, forwarder: opts.forwarder, abi: parsed}
+	return &relay{
+		relayer: opts.relayer,
+		domain: ForwarderDomain{
+			Name:    opts.name,
+			Version: opts.version,
+			ChainID: chainID,
+			Address: opts.forwarder,
+		},
+		signer: hashSigner,
+		nonces: NewNonceManager(nonces, signer.Address()),
+	}, nil
+}
+
+// errRelayed stops a contract binding from sending the transaction it has
+// made, so that the call can be relayed instead.
+var errRelayed = errors.New("transaction will be relayed")
+
+// relayTransaction sends the call made by the passed function as a
+// meta-transaction through the relayer, returning the hash of the transaction
+// the relayer sent.
+func (r *realContract) relayTransaction(ctx context.Context, send func(*bind.TransactOpts) (*types.Transaction, error)) (common.Hash, error) {
+	// Let the binding encode the call and estimate its gas, but take the
+	// transaction from it before it is signed and sent. The gas price and
+	// nonce are never used, and setting them saves asking the chain.
+	var call *types.Transaction
+	opts := &bind.TransactOpts{
+		From:     r.wallet(),
+		Nonce:    new(big.Int),
+		Value:    new(big.Int),
+		GasPrice: new(big.Int),
+		Context:  ctx,
+		Signer: func(address common.Address, txn *types.Transaction) (*types.Transaction, error) {
+			call = txn
+			return nil, errRelayed
+		},
+	}
+	if _, err := send(opts); !errors.Is(err, errRelayed) {
+		if err == nil {
+			err = errors.New("binding sent the transaction instead of relaying it")
+		}
+		return common.Hash{}, err
+	}
+
+	var hash common.Hash
+	err := r.relay.nonces.Send(ctx, func(nonce uint64) error {
+		request := ForwardRequest{
+			From:  r.wallet(),
+			To:    r.address,
+			Value: (*hexutil.Big)(new(big.Int)),
+			Gas:   (*hexutil.Big)(new(big.Int).SetUint64(call.Gas())),
+			Nonce: (*hexutil.Big)(new(big.Int).SetUint64(nonce)),
+			Data:  call.Data(),
+		}
+
+		signature, err := r.relay.signer.SignHash(ctx, r.relay.domain.Hash(request).Bytes())
+		if err != nil {
+			return err
+		}
+		hash, err = r.relay.relayer.Relay(ctx, r.relay.domain.Address, request, signature)
+		return err
+	})
+	if err == nil {
+		log.Ctx(ctx).Debug().Stringer("txn", hash).Msg("Relayed transaction")
+	}
+	return hash, err
+}
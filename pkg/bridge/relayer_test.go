@@ -0,0 +1,87 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignForwardRequest(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := NewPrivateKeySigner(key).(HashSigner)
+
+	domain := ForwarderDomain{
+		Name:    defaultForwarderName,
+		Version: defaultForwarderVersion,
+		ChainID: big.NewInt(31337),
+		Address: common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"),
+	}
+	request := ForwardRequest{
+		From:  signer.Address(),
+		To:    common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"),
+		Value: (*hexutil.Big)(big.NewInt(0)),
+		Gas:   (*hexutil.Big)(big.NewInt(100000)),
+		Nonce: (*hexutil.Big)(big.NewInt(7)),
+		Data:  []byte{0x01, 0x02},
+	}
+
+	hash := domain.Hash(request)
+	signature, err := signer.SignHash(context.Background(), hash.Bytes())
+	require.NoError(t, err)
+	require.Contains(t, []byte{27, 28}, signature[64], "contracts expect V to be 27 or 28")
+
+	signature[64] -= 27
+	recovered, err := crypto.SigToPub(hash.Bytes(), signature)
+	require.NoError(t, err)
+	require.Equal(t, signer.Address(), crypto.PubkeyToAddress(*recovered))
+
+	request.Nonce = (*hexutil.Big)(big.NewInt(8))
+	require.NotEqual(t, hash, domain.Hash(request), "every field should be signed")
+	domain.ChainID = big.NewInt(1)
+	require.NotEqual(t, hash, domain.Hash(request), "requests should only be valid on one chain")
+}
+
+func TestHTTPRelayer(t *testing.T) {
+	forwarder := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	txHash := common.HexToHash("0x1234")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var body struct {
+			Forwarder common.Address `json:"forwarder"`
+			Request   ForwardRequest `json:"request"`
+			Signature hexutil.Bytes  `json:"signature"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Forwarder != forwarder || len(body.Signature) != 65 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"txHash": txHash})
+	}))
+	defer server.Close()
+
+	request := ForwardRequest{
+		Value: (*hexutil.Big)(big.NewInt(0)),
+		Gas:   (*hexutil.Big)(big.NewInt(100000)),
+		Nonce: (*hexutil.Big)(big.NewInt(0)),
+	}
+	hash, err := NewHTTPRelayer(server.URL, "key").Relay(context.Background(), forwarder, request, make([]byte, 65))
+	require.NoError(t, err)
+	require.Equal(t, txHash, hash)
+
+	_, err = NewHTTPRelayer(server.URL, "wrong").Relay(context.Background(), forwarder, request, make([]byte, 65))
+	require.Error(t, err)
+}
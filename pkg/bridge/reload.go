@@ -0,0 +1,111 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// A Reloader is a part of the bridge that can take on changed settings whilst
+// it is running, without interrupting the orders it is working on.
+type Reloader interface {
+	Reload(ctx context.Context, config Config) error
+}
+
+var ErrRateLimitNotReloadable = errors.New("a submit rate limit can only be added whilst running if one was set at start")
+
+// Reload changes how often running jobs are checked, how quickly jobs are
+// submitted and how many can run at once, and passes the config on to the job templates and job runner if
+// they can be reloaded. Other settings only take effect when the bridge is restarted.
+func (workflow *Workflow) Reload(ctx context.Context, config Config) error {
+	workflow.reloadMu.Lock()
+	defer workflow.reloadMu.Unlock()
+
+	limits := config.Limits
+	if workflow.submitLimiter == nil && limits.SubmitRateLimit > 0 {
+		return ErrRateLimitNotReloadable
+	}
+
+	if interval := config.Bacalhau.PollInterval; interval != workflow.jobCheckInterval {
+		if workflow.checkJob != nil {
+			_, err := workflow.scheduler.Job(workflow.checkJob).Every(interval).Update()
+			if err != nil {
+				return err
+			}
+		}
+		log.Ctx(ctx).Info().Dur("from", workflow.jobCheckInterval).Dur("to", interval).Msg("Changed job check interval")
+		workflow.jobCheckInterval = interval
+	}
+
+	workflow.slots.setMax(config.Bacalhau.MaxRunningJobs)
+
+	if workflow.submitLimiter != nil {
+		limit, burst := rate.Inf, limits.SubmitBurst
+		if limits.SubmitRateLimit > 0 {
+			limit = rate.Limit(limits.SubmitRateLimit)
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		workflow.submitLimiter.SetLimit(limit)
+		workflow.submitLimiter.SetBurst(burst)
+	}
+
+	if reloader, ok := workflow.Templates.(Reloader); ok {
+		if err := reloader.Reload(ctx, config); err != nil {
+			return err
+		}
+	}
+
+	if reloader, ok := workflow.Bacalhau.(Reloader); ok {
+		return reloader.Reload(ctx, config)
+	}
+	return nil
+}
+
+// Reload implements Reloader
+func (r *bacalhauRunner) Reload(ctx context.Context, config Config) error {
+	policy, err := policyFromConfig(config.Limits)
+	if err != nil {
+		return err
+	}
+
+	r.settingsMu.Lock()
+	defer r.settingsMu.Unlock()
+
+	r.config.SubmitTimeout = config.Bacalhau.SubmitTimeout
+	r.config.ListTimeout = config.Bacalhau.ListTimeout
+	r.config.CheckTimeout = config.Bacalhau.CheckTimeout
+	r.config.MaxJobDuration = config.Bacalhau.MaxJobDuration
+	r.config.CheckConcurrency = config.Bacalhau.CheckConcurrency
+	r.policy = policy
+	return nil
+}
+
+// settings returns the parts of the runner's config that can be reloaded.
+func (r *bacalhauRunner) settings() (RunnerConfig, Policy) {
+	r.settingsMu.RLock()
+	defer r.settingsMu.RUnlock()
+	return r.config, r.policy
+}
+
+// Reload implements Reloader
+func (r *dryRunRunner) Reload(ctx context.Context, config Config) error {
+	policy, err := policyFromConfig(config.Limits)
+	if err != nil {
+		return err
+	}
+
+	r.policyMu.Lock()
+	defer r.policyMu.Unlock()
+	r.policy = policy
+	return nil
+}
+
+var (
+	_ Reloader = (*Workflow)(nil)
+	_ Reloader = (*bacalhauRunner)(nil)
+	_ Reloader = (*dryRunRunner)(nil)
+)
@@ -0,0 +1,53 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestReloadWorkflow(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.Bacalhau.PollInterval = time.Minute
+	config.Limits.SubmitRateLimit = 5
+	config.Limits.SubmitBurst = 3
+
+	workflow := NewWorkflow(&mockRunner{}, mockContract{}, repository(t), WithSubmitRateLimit(1, 1))
+	require.NoError(t, workflow.Reload(ctx, config))
+	require.Equal(t, time.Minute, workflow.jobCheckInterval)
+	require.Equal(t, rate.Limit(5), workflow.submitLimiter.Limit())
+	require.Equal(t, 3, workflow.submitLimiter.Burst())
+
+	config.Limits.SubmitRateLimit = 0
+	require.NoError(t, workflow.Reload(ctx, config))
+	require.Equal(t, rate.Inf, workflow.submitLimiter.Limit())
+
+	unlimited := NewWorkflow(&mockRunner{}, mockContract{}, repository(t))
+	config.Limits.SubmitRateLimit = 1
+	require.ErrorIs(t, unlimited.Reload(ctx, config), ErrRateLimitNotReloadable)
+}
+
+func TestReloadRunnerPolicy(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.Bacalhau.ListTimeout = time.Minute
+	config.Limits.PolicyFile = writeConfig(t, "policy.yaml", "images:\n  allow: [\"ubuntu:*\"]\n")
+
+	runner := &bacalhauRunner{policy: &SpecPolicy{}}
+	require.NoError(t, runner.Reload(ctx, config))
+
+	settings, policy := runner.settings()
+	require.Equal(t, time.Minute, settings.ListTimeout)
+	spec := fastSpec
+	spec.Docker.Image = "alpine"
+	require.IsType(t, &Rejection{}, policy.Check(spec))
+
+	config.Limits.PolicyFile = "does-not-exist.yaml"
+	require.Error(t, runner.Reload(ctx, config))
+	_, unchanged := runner.settings()
+	require.Equal(t, policy, unchanged, "a bad policy shouldn't replace a good one")
+}
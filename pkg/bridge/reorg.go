@@ -0,0 +1,81 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// A ReorgWatcher is a SmartContract that can tell when the event that made an
+// order has been removed from the chain by a reorg. The order no longer exists
+// on the contract, so it can be neither paid nor refunded, and any work on it
+// is abandoned.
+type ReorgWatcher interface {
+	// WatchReorgs sends the ID of each order whose event has been removed to
+	// the passed channel. It blocks until the context is cancelled.
+	WatchReorgs(ctx context.Context, removed chan<- common.Hash) error
+}
+
+// abandonReorgedOrders abandons each order that the contract says has been
+// removed by a reorg, until the passed context is cancelled.
+func (workflow *Workflow) abandonReorgedOrders(ctx context.Context, removed <-chan common.Hash) error {
+	for {
+		select {
+		case orderID := <-removed:
+			err := workflow.abandon(ctx, orderID)
+			log.Ctx(ctx).WithLevel(level(err)).Err(err).Stringer("id", orderID).Msg("Abandoning reorged order")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// abandon stops work on an order that has been removed from the chain,
+// cancelling its job if one is running. The order is failed with a reason that
+// stops it being refunded. Orders that have already been paid or refunded are
+// left alone, as are orders owned by another bridge, which will see the reorg
+// for themselves.
+func (workflow *Workflow) abandon(ctx context.Context, orderID common.Hash) error {
+	if !workflow.owns(orderID) {
+		return nil
+	}
+
+	// Stop the job being found to have finished whilst it is being cancelled.
+	workflow.checkMu.Lock()
+	defer workflow.checkMu.Unlock()
+
+	for _, state := range activeStates() {
+		events, err := workflow.Repo.Reload(state)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if e.OrderId() != orderID {
+				continue
+			}
+
+			if job, ok := e.(BacalhauJobRunningEvent); ok && state == OrderStateRunning {
+				if err := workflow.Bacalhau.Cancel(ctx, job); err != nil {
+					return err
+				}
+			}
+
+			ordersReorged.Inc()
+			abandoned := e.(ContractSubmittedEvent).FailedWith(FailureReasonReorged, "order removed from the chain by a reorg")
+			return workflow.inject(ctx, abandoned)
+		}
+	}
+	return nil
+}
+
+// WatchReorgs implements ReorgWatcher
+func (c *dryRunContract) WatchReorgs(ctx context.Context, removed chan<- common.Hash) error {
+	if watcher, ok := c.SmartContract.(ReorgWatcher); ok {
+		return watcher.WatchReorgs(ctx, removed)
+	}
+	return nil
+}
+
+var _ ReorgWatcher = (*dryRunContract)(nil)
@@ -0,0 +1,44 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorgedOrdersAreAbandoned(t *testing.T) {
+	repo := repository(t)
+	cancelled := ""
+	runner := &mockRunner{CancelHandler: func(ctx context.Context, e BacalhauJobRunningEvent) error {
+		cancelled = e.JobID()
+		return nil
+	}}
+	workflow := NewWorkflow(runner, mockContract{}, repo)
+	ctx := context.Background()
+
+	job := model.NewJob()
+	job.Metadata.ID = "running-job"
+	e := walEvent(1).JobCreated(job)
+	require.NoError(t, repo.Save(e))
+
+	require.NoError(t, workflow.abandon(ctx, e.OrderId()))
+	require.Equal(t, "running-job", cancelled)
+
+	injected := <-workflow.injected
+	require.Equal(t, OrderStateFailed, injected.OrderState())
+	require.Equal(t, FailureReasonReorged, injected.(ContractFailedEvent).FailureReason())
+
+	refunded := false
+	workflow.Contract = mockContract{RefundHandler: func(ctx context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+		refunded = true
+		return e.Refunded(), nil
+	}}
+	result, _ := workflow.ProcessEvent(ctx, injected)
+	require.Nil(t, result)
+	require.False(t, refunded, "orders removed from the chain can't be refunded")
+
+	require.NoError(t, workflow.abandon(ctx, walEvent(2).OrderId()), "unknown orders should be ignored")
+	require.Empty(t, workflow.injected)
+}
@@ -0,0 +1,329 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// A ReplacementPolicy says when a transaction that hasn't been mined is sent
+// again with higher fees, replacing the original.
+type ReplacementPolicy struct {
+	// How long to wait for a transaction to be mined before replacing it.
+	// Zero means transactions are never replaced.
+	After time.Duration
+
+	// The most times a single transaction is replaced.
+	MaxReplacements uint
+
+	// How much fees are raised by each time, as a percentage. Nodes don't
+	// accept replacements that raise fees by less than 10%.
+	FeeBump float64
+
+	// If set, fees are never raised above this, in wei per unit of gas.
+	FeeCap *big.Int
+}
+
+var DefaultReplacementPolicy = ReplacementPolicy{
+	After:           3 * time.Minute,
+	MaxReplacements: 3,
+	FeeBump:         12.5,
+}
+
+// A pendingTransaction is a transaction that has been sent but not yet seen
+// to be mined.
+type pendingTransaction struct {
+	txn          *types.Transaction
+	sentAt       time.Time
+	replacements uint
+
+	// Every version of the transaction that has been sent, any of which
+	// might be the one that is mined.
+	sent []*types.Transaction
+}
+
+// pendingTransactions are the transactions sent by a contract, by nonce.
+// Transactions sent before a restart aren't known about, and so are never
+// replaced.
+type pendingTransactions struct {
+	mu   sync.Mutex
+	txns map[uint64]*pendingTransaction
+
+	// Every version sent of the transactions that have been replaced, by the
+	// hash each was first sent with, so that whichever was mined can be
+	// found. They are forgotten a day after they were last replaced.
+	versionsOf map[common.Hash]replacedTransaction
+}
+
+// A replacedTransaction is every version of a transaction that has been sent,
+// latest first.
+type replacedTransaction struct {
+	hashes     []common.Hash
+	replacedAt time.Time
+}
+
+// How long the versions of a replaced transaction are remembered for.
+const replacedTransactionMemory = 24 * time.Hour
+
+// track records a transaction that has just been sent.
+func (p *pendingTransactions) track(txn *types.Transaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.txns == nil {
+		p.txns = map[uint64]*pendingTransaction{}
+	}
+	p.txns[txn.Nonce()] = &pendingTransaction{txn: txn, sentAt: time.Now(), sent: []*types.Transaction{txn}}
+}
+
+// mined forgets and returns every transaction with a nonce below the passed
+// one, as some version of each of them has been mined.
+func (p *pendingTransactions) mined(nonce uint64) []*pendingTransaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mined := []*pendingTransaction{}
+	for pendingNonce, pending := range p.txns {
+		if pendingNonce < nonce {
+			mined = append(mined, pending)
+			delete(p.txns, pendingNonce)
+		}
+	}
+	return mined
+}
+
+// stuck returns the transactions that were sent longer ago than the passed
+// time, and so should be replaced.
+func (p *pendingTransactions) stuck(after time.Duration) []*pendingTransaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stuck := []*pendingTransaction{}
+	for _, pending := range p.txns {
+		if time.Since(pending.sentAt) > after {
+			stuck = append(stuck, pending)
+		}
+	}
+	return stuck
+}
+
+// replaced records that a transaction has been replaced by the passed one.
+func (p *pendingTransactions) replaced(pending *pendingTransaction, txn *types.Transaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending.txn = txn
+	pending.sentAt = time.Now()
+	pending.replacements++
+	pending.sent = append(pending.sent, txn)
+
+	if p.versionsOf == nil {
+		p.versionsOf = map[common.Hash]replacedTransaction{}
+	}
+	for hash, replaced := range p.versionsOf {
+		if time.Since(replaced.replacedAt) > replacedTransactionMemory {
+			delete(p.versionsOf, hash)
+		}
+	}
+	hashes := make([]common.Hash, 0, len(pending.sent))
+	for i := len(pending.sent) - 1; i >= 0; i-- {
+		hashes = append(hashes, pending.sent[i].Hash())
+	}
+	p.versionsOf[pending.sent[0].Hash()] = replacedTransaction{hashes: hashes, replacedAt: pending.sentAt}
+}
+
+// versions returns the hash of every version of the transaction first sent
+// with the passed hash, latest first.
+func (p *pendingTransactions) versions(hash common.Hash) []common.Hash {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if replaced, found := p.versionsOf[hash]; found {
+		return replaced.hashes
+	}
+	return []common.Hash{hash}
+}
+
+// monitorTransactions keeps track of the transactions that have been sent
+// until the passed context is cancelled, recording the gas spent by those that
+// are mined and replacing those that haven't been mined in the time allowed by
+// the replacement policy.
+func (r *realContract) monitorTransactions(ctx context.Context) {
+	interval := r.replacement.After / 4
+	if r.replacement.After <= 0 {
+		interval = defaultPollInterval
+	} else if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.checkTransactions(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkTransactions records the gas spent by the transactions that have been
+// mined since it was last called, and replaces any that are stuck.
+func (r *realContract) checkTransactions(ctx context.Context) {
+	confirmed, err := r.client.NonceAt(ctx, r.wallet(), nil)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to check for mined transactions")
+		return
+	}
+	for _, pending := range r.pending.mined(confirmed) {
+		r.recordSpend(ctx, pending)
+	}
+
+	if r.replacement.After > 0 {
+		r.replaceStuck(ctx)
+	}
+}
+
+// replaceStuck sends again, with higher fees, each transaction that has taken
+// too long to be mined.
+func (r *realContract) replaceStuck(ctx context.Context) {
+	stuck := r.pending.stuck(r.replacement.After)
+	transactionsStuck.Set(float64(len(stuck)))
+	for _, pending := range stuck {
+		logger := log.Ctx(ctx).With().
+			Stringer("txn", pending.txn.Hash()).
+			Uint64("nonce", pending.txn.Nonce()).
+			Uint("replacements", pending.replacements).
+			Logger()
+
+		if pending.replacements >= r.replacement.MaxReplacements {
+			logger.Error().Msg("Transaction still not mined after every replacement")
+			continue
+		}
+
+		data, err := replacementFor(pending.txn, r.replacement)
+		if err != nil {
+			logger.Error().Err(err).Msg("Unable to replace stuck transaction")
+			continue
+		}
+		txn, err := r.signer.SignTransaction(ctx, types.NewTx(data), r.chainID)
+		if err != nil {
+			logger.Error().Err(err).Msg("Unable to sign replacement transaction")
+			continue
+		}
+
+		err = r.client.SendTransaction(ctx, txn)
+		if err != nil && isAlreadyKnown(err) {
+			// The node already has this replacement.
+			err = nil
+		}
+		if err != nil && isNonceUsed(err) {
+			// The original was mined whilst the replacement was being made.
+			logger.Debug().Err(err).Msg("Stuck transaction has been mined")
+			continue
+		} else if err != nil {
+			logger.Error().Err(err).Msg("Unable to send replacement transaction")
+			continue
+		}
+
+		r.pending.replaced(pending, txn)
+		transactionsReplaced.Inc()
+		logger.Warn().Stringer("replacement", txn.Hash()).Msg("Replaced stuck transaction")
+	}
+}
+
+// bumpFee returns the passed fee raised by the passed percentage, rounded up.
+func bumpFee(fee *big.Int, percent float64) *big.Int {
+	bumped, accuracy := new(big.Float).Mul(new(big.Float).SetInt(fee), big.NewFloat(1+percent/100)).Int(nil)
+	if accuracy == big.Below {
+		bumped.Add(bumped, big.NewInt(1))
+	}
+	return bumped
+}
+
+// replacementFor returns an unsigned copy of the passed transaction with its
+// fees raised as the policy says, or ErrFeeTooHigh if that would take them over
+// the policy's cap.
+func replacementFor(txn *types.Transaction, policy ReplacementPolicy) (types.TxData, error) {
+	overCap := func(fee *big.Int) bool {
+		return policy.FeeCap != nil && fee.Cmp(policy.FeeCap) > 0
+	}
+
+	switch txn.Type() {
+	case types.DynamicFeeTxType:
+		maxFee := bumpFee(txn.GasFeeCap(), policy.FeeBump)
+		if overCap(maxFee) {
+			return nil, fmt.Errorf("%w: replacement would offer %s wei", ErrFeeTooHigh, maxFee)
+		}
+		return &types.DynamicFeeTx{
+			ChainID:    txn.ChainId(),
+			Nonce:      txn.Nonce(),
+			GasTipCap:  bumpFee(txn.GasTipCap(), policy.FeeBump),
+			GasFeeCap:  maxFee,
+			Gas:        txn.Gas(),
+			To:         txn.To(),
+			Value:      txn.Value(),
+			Data:       txn.Data(),
+			AccessList: txn.AccessList(),
+		}, nil
+	case types.LegacyTxType:
+		price := bumpFee(txn.GasPrice(), policy.FeeBump)
+		if overCap(price) {
+			return nil, fmt.Errorf("%w: replacement would offer %s wei", ErrFeeTooHigh, price)
+		}
+		return &types.LegacyTx{
+			Nonce:    txn.Nonce(),
+			GasPrice: price,
+			Gas:      txn.Gas(),
+			To:       txn.To(),
+			Value:    txn.Value(),
+			Data:     txn.Data(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("can't replace transactions of type %d", txn.Type())
+	}
+}
+
+// replacementPolicyFromEnv returns the default replacement policy overridden
+// by GAS_REPLACE_AFTER, GAS_MAX_REPLACEMENTS and GAS_FEE_BUMP, and capped at
+// GAS_FEE_CAP gwei if it is set.
+func replacementPolicyFromEnv() (ReplacementPolicy, error) {
+	policy := DefaultReplacementPolicy
+
+	if str, found := os.LookupEnv("GAS_REPLACE_AFTER"); found && str != "" {
+		after, err := time.ParseDuration(str)
+		if err != nil {
+			return policy, fmt.Errorf("GAS_REPLACE_AFTER: %w", err)
+		}
+		policy.After = after
+	}
+
+	if str, found := os.LookupEnv("GAS_MAX_REPLACEMENTS"); found && str != "" {
+		count, err := strconv.ParseUint(str, 10, 32)
+		if err != nil {
+			return policy, fmt.Errorf("GAS_MAX_REPLACEMENTS: %w", err)
+		}
+		policy.MaxReplacements = uint(count)
+	}
+
+	bump, err := floatFromEnv("GAS_FEE_BUMP")
+	if err != nil {
+		return policy, err
+	} else if bump != 0 && bump < 10 {
+		return policy, fmt.Errorf("GAS_FEE_BUMP must be at least 10%%")
+	} else if bump != 0 {
+		policy.FeeBump = bump
+	}
+
+	limit, err := floatFromEnv("GAS_FEE_CAP")
+	if err != nil {
+		return policy, err
+	} else if limit > 0 {
+		policy.FeeCap = gwei(limit)
+	}
+	return policy, nil
+}
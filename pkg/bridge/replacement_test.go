@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplacementRaisesFees(t *testing.T) {
+	to := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	original := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(31337),
+		Nonce:     7,
+		GasTipCap: big.NewInt(100),
+		GasFeeCap: big.NewInt(1000),
+		Gas:       50000,
+		To:        &to,
+		Data:      []byte("results"),
+	})
+
+	data, err := replacementFor(original, DefaultReplacementPolicy)
+	require.NoError(t, err)
+	replacement := types.NewTx(data)
+	require.Equal(t, uint64(7), replacement.Nonce())
+	require.Equal(t, int64(113), replacement.GasTipCap().Int64(), "fees should be rounded up")
+	require.Equal(t, int64(1125), replacement.GasFeeCap().Int64())
+	require.Equal(t, original.Data(), replacement.Data())
+	require.Equal(t, original.To(), replacement.To())
+
+	policy := DefaultReplacementPolicy
+	policy.FeeCap = big.NewInt(1100)
+	_, err = replacementFor(original, policy)
+	require.ErrorIs(t, err, ErrFeeTooHigh)
+
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(1000), Gas: 50000, To: &to})
+	data, err = replacementFor(legacy, DefaultReplacementPolicy)
+	require.NoError(t, err)
+	require.Equal(t, int64(1125), types.NewTx(data).GasPrice().Int64())
+}
@@ -0,0 +1,303 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// A Recording is what a JobRunner was asked to do and what it answered, as
+// saved by a recording runner and served back by a replay runner.
+type Recording struct {
+	// The jobs created, in the order they were asked for.
+	Creates []RecordedCreate `json:"creates"`
+
+	// How each job that finished ended, by job ID.
+	Outcomes map[string]RecordedOutcome `json:"outcomes"`
+
+	// The error from cancelling each job that was cancelled, by job ID.
+	Cancels map[string]string `json:"cancels,omitempty"`
+}
+
+// A RecordedCreate is a job created for an order with the spec, or the error
+// that stopped it being created.
+type RecordedCreate struct {
+	Spec     json.RawMessage `json:"spec"`
+	JobID    string          `json:"jobId,omitempty"`
+	Endpoint string          `json:"endpoint,omitempty"`
+
+	Error         string `json:"error,omitempty"`
+	Rejected      bool   `json:"rejected,omitempty"`
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// A RecordedOutcome is how a job finished, which is either Completed or
+// JobError.
+type RecordedOutcome struct {
+	State         string      `json:"state"`
+	Result        string      `json:"result,omitempty"`
+	Results       []string    `json:"results,omitempty"`
+	Stdout        string      `json:"stdout,omitempty"`
+	Stderr        string      `json:"stderr,omitempty"`
+	ExitCode      int         `json:"exitCode"`
+	Executions    []Execution `json:"executions,omitempty"`
+	FailureReason string      `json:"failureReason,omitempty"`
+	StateMessage  string      `json:"stateMessage,omitempty"`
+}
+
+// ErrNotRecorded is returned by a replay runner asked to create a job that
+// wasn't created when the recording was made.
+var ErrNotRecorded = errors.New("no recorded job for spec")
+
+// LoadRecording reads the recording saved at the path.
+func LoadRecording(path string) (*Recording, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recording := new(Recording)
+	if err = json.Unmarshal(contents, recording); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", path, err)
+	}
+	return recording, nil
+}
+
+// Save writes the recording to the path, replacing whatever was there.
+func (r *Recording) Save(path string) error {
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to the side and move into place, so that a recording that is
+	// interrupted leaves the last complete one behind.
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(contents); err == nil {
+		err = temp.Close()
+	}
+	if err != nil {
+		temp.Close()
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+type recordingRunner struct {
+	runner    JobRunner
+	path      string
+	mu        sync.Mutex
+	recording *Recording
+}
+
+// NewRecordingRunner returns a JobRunner that passes everything on to the
+// runner, and saves what it was asked and answered to the file at the path
+// after each call, so that it can be played back by NewReplayRunner.
+func NewRecordingRunner(runner JobRunner, path string) JobRunner {
+	return &recordingRunner{
+		runner: runner,
+		path:   path,
+		recording: &Recording{
+			Outcomes: map[string]RecordedOutcome{},
+			Cancels:  map[string]string{},
+		},
+	}
+}
+
+// Create implements JobRunner
+func (r *recordingRunner) Create(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+	// The runner may change the event, so take the spec as it was asked for.
+	created := RecordedCreate{Spec: append(json.RawMessage(nil), e.RawSpec()...)}
+	running, err := r.runner.Create(ctx, e)
+
+	var rejection *Rejection
+	switch {
+	case errors.As(err, &rejection):
+		created.Error, created.Rejected = rejection.Reason, true
+		if rejection.FailureReason != FailureReasonUnknown {
+			created.FailureReason = rejection.FailureReason.String()
+		}
+	case err != nil:
+		created.Error = err.Error()
+	case running != nil:
+		created.JobID, created.Endpoint = running.JobID(), running.Endpoint()
+	}
+
+	r.record(ctx, func(recording *Recording) {
+		recording.Creates = append(recording.Creates, created)
+	})
+	return running, err
+}
+
+// FindCompleted implements JobRunner
+func (r *recordingRunner) FindCompleted(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+	completed, failed := r.runner.FindCompleted(ctx, jobs)
+	if len(completed) == 0 && len(failed) == 0 {
+		return completed, failed
+	}
+
+	r.record(ctx, func(recording *Recording) {
+		for _, job := range completed {
+			e := job.(*event)
+			recording.Outcomes[e.jobId] = RecordedOutcome{
+				State:      OrderStateCompleted.String(),
+				Result:     e.jobResult,
+				Results:    e.jobResults,
+				Stdout:     e.jobStdout,
+				Stderr:     e.jobStderr,
+				ExitCode:   e.jobExitcode,
+				Executions: e.jobExecutions,
+			}
+		}
+		for _, job := range failed {
+			e := job.(*event)
+			recording.Outcomes[e.jobId] = RecordedOutcome{
+				State:         OrderStateJobError.String(),
+				Stderr:        e.jobStderr,
+				Executions:    e.jobExecutions,
+				FailureReason: e.failureReason.String(),
+				StateMessage:  e.stateMessage,
+			}
+		}
+	})
+	return completed, failed
+}
+
+// Cancel implements JobRunner
+func (r *recordingRunner) Cancel(ctx context.Context, job BacalhauJobRunningEvent) error {
+	err := r.runner.Cancel(ctx, job)
+
+	var message string
+	if err != nil {
+		message = err.Error()
+	}
+	r.record(ctx, func(recording *Recording) {
+		recording.Cancels[job.JobID()] = message
+	})
+	return err
+}
+
+// record changes the recording and saves it. Failing to save doesn't change
+// what the runner answers, so it is only logged.
+func (r *recordingRunner) record(ctx context.Context, change func(*Recording)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change(r.recording)
+	if err := r.recording.Save(r.path); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("path", r.path).Msg("Unable to save recording")
+	}
+}
+
+var _ JobRunner = (*recordingRunner)(nil)
+
+type replayRunner struct {
+	mu        sync.Mutex
+	recording *Recording
+	used      []bool
+}
+
+// NewReplayRunner returns a JobRunner that answers with what was recorded by
+// NewRecordingRunner, without talking to a compute network. Each job is
+// created for the first unused recorded job with the same spec, and finishes
+// the first time it is looked for, however long it took when it was recorded.
+// Jobs that hadn't finished when the recording was made never do.
+func NewReplayRunner(recording *Recording) JobRunner {
+	return &replayRunner{recording: recording, used: make([]bool, len(recording.Creates))}
+}
+
+// Create implements JobRunner
+func (r *replayRunner) Create(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+	created, ok := r.next(e.RawSpec())
+	if !ok {
+		return nil, ErrNotRecorded
+	}
+
+	if created.Rejected {
+		reason, _ := parseFailureReason(created.FailureReason)
+		return nil, &Rejection{Reason: created.Error, FailureReason: reason}
+	} else if created.Error != "" {
+		return nil, errors.New(created.Error)
+	} else if created.JobID == "" {
+		return nil, nil
+	}
+
+	job := model.NewJob()
+	job.Metadata.ID = created.JobID
+	return e.JobCreated(job).WithEndpoint(created.Endpoint), nil
+}
+
+// next returns the first unused create that was recorded for the spec.
+func (r *replayRunner) next(spec []byte) (RecordedCreate, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, created := range r.recording.Creates {
+		if !r.used[i] && sameJSON(created.Spec, spec) {
+			r.used[i] = true
+			return created, true
+		}
+	}
+	return RecordedCreate{}, false
+}
+
+// sameJSON returns whether the two documents hold the same JSON value,
+// however they are laid out.
+func sameJSON(a, b []byte) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// FindCompleted implements JobRunner
+func (r *replayRunner) FindCompleted(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+	completed := []BacalhauJobCompletedEvent{}
+	failed := []BacalhauJobFailedEvent{}
+	for _, job := range jobs {
+		outcome, ok := r.recording.Outcomes[job.JobID()]
+		if !ok {
+			continue
+		}
+
+		job.WithExecutions(outcome.Executions)
+		e := job.(*event)
+		switch outcome.State {
+		case OrderStateCompleted.String():
+			e.state = OrderStateCompleted
+			e.jobResult = outcome.Result
+			e.jobResults = outcome.Results
+			e.jobStdout = outcome.Stdout
+			e.jobStderr = outcome.Stderr
+			e.jobExitcode = outcome.ExitCode
+			completed = append(completed, e)
+		default:
+			reason, _ := parseFailureReason(outcome.FailureReason)
+			failed = append(failed, job.JobFailed(reason, outcome.Stderr, outcome.StateMessage))
+		}
+	}
+	return completed, failed
+}
+
+// Cancel implements JobRunner
+func (r *replayRunner) Cancel(ctx context.Context, job BacalhauJobRunningEvent) error {
+	if message := r.recording.Cancels[job.JobID()]; message != "" {
+		return errors.New(message)
+	}
+	return nil
+}
+
+var _ JobRunner = (*replayRunner)(nil)
@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "recording.json")
+
+	var created int
+	live := &mockRunner{
+		CreateHandler: func(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+			if string(e.RawSpec()) == `{"bad": true}` {
+				return nil, &Rejection{Reason: "too cheap", FailureReason: FailureReasonUnderpriced}
+			}
+			created++
+			job := model.NewJob()
+			job.Metadata.ID = fmt.Sprintf("job-%d", created)
+			return e.JobCreated(job).WithEndpoint("http://bacalhau:1234"), nil
+		},
+		FindCompletedHandler: func(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+			completed, _ := findWith(exampleResult)(ctx, jobs[:1])
+			failed := []BacalhauJobFailedEvent{}
+			for _, job := range jobs[1:] {
+				failed = append(failed, job.JobFailed(FailureReasonTimeout, "too slow", "InProgress"))
+			}
+			return completed, failed
+		},
+	}
+
+	// Two orders for the same job, which end differently, and one that is
+	// rejected.
+	run := func(runner JobRunner) ([]BacalhauJobRunningEvent, []BacalhauJobCompletedEvent, []BacalhauJobFailedEvent, error) {
+		first, err := runner.Create(ctx, exampleEvent())
+		require.NoError(t, err)
+		second, err := runner.Create(ctx, exampleEvent())
+		require.NoError(t, err)
+		_, rejected := runner.Create(ctx, exampleEvent().WithSpec([]byte(`{"bad": true}`)))
+
+		jobs := []BacalhauJobRunningEvent{first, second}
+		completed, failed := runner.FindCompleted(ctx, jobs)
+		return jobs, completed, failed, rejected
+	}
+
+	recorder := NewRecordingRunner(live, path)
+	recordedJobs, recordedCompleted, recordedFailed, _ := run(recorder)
+
+	recording, err := LoadRecording(path)
+	require.NoError(t, err)
+	require.Len(t, recording.Creates, 3)
+	require.Len(t, recording.Outcomes, 2)
+
+	jobs, completed, failed, rejected := run(NewReplayRunner(recording))
+	require.Equal(t, recordedJobs[0].JobID(), jobs[0].JobID())
+	require.Equal(t, recordedJobs[1].JobID(), jobs[1].JobID())
+	require.Equal(t, "http://bacalhau:1234", jobs[0].Endpoint())
+
+	require.Len(t, completed, 1)
+	require.Equal(t, recordedCompleted[0].Result(context.Background()), completed[0].Result(context.Background()))
+	require.Len(t, failed, 1)
+	require.Equal(t, recordedFailed[0].FailureReason(), failed[0].FailureReason())
+	require.Equal(t, "too slow", failed[0].Error())
+	require.Equal(t, "InProgress", failed[0].StateMessage())
+
+	var rejection *Rejection
+	require.ErrorAs(t, rejected, &rejection)
+	require.Equal(t, FailureReasonUnderpriced, rejection.FailureReason)
+
+	// Jobs that weren't recorded can't be replayed.
+	_, err = NewReplayRunner(recording).Create(ctx, exampleEvent().WithSpec([]byte(`{"other": true}`)))
+	require.ErrorIs(t, err, ErrNotRecorded)
+}
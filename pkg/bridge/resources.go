@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/bacalhau-project/bacalhau/pkg/compute/capacity"
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+)
+
+// checkResources returns an error if the job spec asks for more of any resource
+// than the passed limits allow. Resources without a limit can be requested in
+// any amount. Requests that can't be read are a *bridgeerrors.SpecError, as
+// Bacalhau would take them to be zero and let them past any limit.
+//
+// Jobs ask for CPU, memory, disk and GPUs in the Resources section of the spec
+// on-chain, which is passed through to Bacalhau unchanged if it is allowed.
+func checkResources(spec model.Spec, limits model.ResourceUsageConfig) error {
+	if err := checkQuantities(spec.Resources, "request"); err != nil {
+		return &bridgeerrors.SpecError{Err: err}
+	}
+
+	requested := capacity.ParseResourceUsageConfig(spec.Resources)
+	allowed := capacity.ParseResourceUsageConfig(limits)
+
+	if limits.CPU != "" && requested.CPU > allowed.CPU {
+		return fmt.Errorf("job requests %s CPU but at most %s is allowed", spec.Resources.CPU, limits.CPU)
+	}
+	if limits.Memory != "" && requested.Memory > allowed.Memory {
+		return fmt.Errorf("job requests %s memory but at most %s is allowed", spec.Resources.Memory, limits.Memory)
+	}
+	if limits.Disk != "" && requested.Disk > allowed.Disk {
+		return fmt.Errorf("job requests %s disk but at most %s is allowed", spec.Resources.Disk, limits.Disk)
+	}
+	if limits.GPU != "" && requested.GPU > allowed.GPU {
+		return fmt.Errorf("job requests %s GPUs but at most %s are allowed", spec.Resources.GPU, limits.GPU)
+	}
+	return nil
+}
+
+var (
+	cpuQuantity  = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?m?$`)
+	sizeQuantity = regexp.MustCompile(`(?i)^[0-9]+\s*[kmgtpe]?b?$`)
+)
+
+// checkResourceLimits returns an error if any of the passed limits can't be
+// read. Bacalhau takes a quantity it can't read to be zero, so a mistyped limit
+// would otherwise refuse every job that asks for any of that resource.
+func checkResourceLimits(limits model.ResourceUsageConfig) error {
+	return checkQuantities(limits, "limit")
+}
+
+// checkQuantities returns an error naming the first of the passed resources
+// that can't be read, described as the passed kind of quantity.
+func checkQuantities(resources model.ResourceUsageConfig, kind string) error {
+	if resources.CPU != "" && !cpuQuantity.MatchString(resources.CPU) {
+		return fmt.Errorf("invalid CPU %s %q, should be a number of cores such as 2 or 500m", kind, resources.CPU)
+	}
+	if resources.Memory != "" && !sizeQuantity.MatchString(resources.Memory) {
+		return fmt.Errorf("invalid memory %s %q, should be a size such as 8Gb", kind, resources.Memory)
+	}
+	if resources.Disk != "" && !sizeQuantity.MatchString(resources.Disk) {
+		return fmt.Errorf("invalid disk %s %q, should be a size such as 100Gb", kind, resources.Disk)
+	}
+	if _, err := strconv.ParseUint(resources.GPU, 10, 64); resources.GPU != "" && err != nil {
+		return fmt.Errorf("invalid GPU %s %q, should be a whole number of GPUs", kind, resources.GPU)
+	}
+	return nil
+}
+
+// resourceLimitsFromEnv reads the maximum resources a job may request from
+// BACALHAU_MAX_CPU, BACALHAU_MAX_MEMORY, BACALHAU_MAX_DISK and BACALHAU_MAX_GPU,
+// returning an error if any of them can't be read.
+func resourceLimitsFromEnv() (model.ResourceUsageConfig, error) {
+	limits := model.ResourceUsageConfig{
+		CPU:    os.Getenv("BACALHAU_MAX_CPU"),
+		Memory: os.Getenv("BACALHAU_MAX_MEMORY"),
+		Disk:   os.Getenv("BACALHAU_MAX_DISK"),
+		GPU:    os.Getenv("BACALHAU_MAX_GPU"),
+	}
+	return limits, checkResourceLimits(limits)
+}
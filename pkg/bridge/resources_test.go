@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckResources(t *testing.T) {
+	limits := model.ResourceUsageConfig{CPU: "2", Memory: "4Gb", GPU: "1"}
+
+	testCases := []struct {
+		resources model.ResourceUsageConfig
+		allowed   bool
+	}{
+		{model.ResourceUsageConfig{}, true},
+		{model.ResourceUsageConfig{CPU: "500m", Memory: "1Gb"}, true},
+		{model.ResourceUsageConfig{CPU: "2", Memory: "4Gb", GPU: "1"}, true},
+		{model.ResourceUsageConfig{Disk: "100Gb"}, true},
+		{model.ResourceUsageConfig{CPU: "4"}, false},
+		{model.ResourceUsageConfig{Memory: "8Gb"}, false},
+		{model.ResourceUsageConfig{GPU: "2"}, false},
+	}
+
+	for _, testCase := range testCases {
+		spec := fastSpec
+		spec.Resources = testCase.resources
+		err := checkResources(spec, limits)
+		if testCase.allowed {
+			require.NoError(t, err, testCase.resources)
+		} else {
+			require.Error(t, err, testCase.resources)
+		}
+	}
+}
+
+func TestUnreadableResourcesAreInvalid(t *testing.T) {
+	for _, resources := range []model.ResourceUsageConfig{
+		{CPU: "lots"},
+		{Memory: "8 gigs"},
+		{Disk: "1.5Tb"},
+		{GPU: "0.5"},
+	} {
+		spec := fastSpec
+		spec.Resources = resources
+		require.ErrorIs(t, checkResources(spec, model.ResourceUsageConfig{}), bridgeerrors.ErrSpecInvalid, resources)
+
+		policy := &SpecPolicy{Resources: model.ResourceUsageConfig{CPU: "2"}}
+		require.ErrorIs(t, policy.Check(spec), bridgeerrors.ErrSpecInvalid, resources)
+	}
+}
+
+func TestResourceLimitsFromEnv(t *testing.T) {
+	t.Setenv("BACALHAU_MAX_CPU", "500m")
+	t.Setenv("BACALHAU_MAX_MEMORY", "8Gb")
+	t.Setenv("BACALHAU_MAX_DISK", "100Gb")
+	t.Setenv("BACALHAU_MAX_GPU", "1")
+	limits, err := resourceLimitsFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, model.ResourceUsageConfig{CPU: "500m", Memory: "8Gb", Disk: "100Gb", GPU: "1"}, limits)
+
+	for env, value := range map[string]string{
+		"BACALHAU_MAX_CPU":    "two",
+		"BACALHAU_MAX_MEMORY": "8 gigs",
+		"BACALHAU_MAX_DISK":   "1.5Tb",
+		"BACALHAU_MAX_GPU":    "0.5",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			_, err := resourceLimitsFromEnv()
+			require.ErrorContains(t, err, value)
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// Encrypted results start with this, so that they can be recognised and the
+// format can be changed later.
+const sealedResultMagic = "LPSEAL1\n"
+
+// Results are encrypted in segments of this size, so that they never have to
+// be held in memory all at once.
+const sealedSegmentSize = 64 * 1024
+
+var defaultSealTimeout = 10 * time.Minute
+
+// ParseEncryptionKey returns the secp256k1 public key that an order asked for
+// its results to be encrypted with, which may be compressed or not.
+func ParseEncryptionKey(key []byte) (*ecdsa.PublicKey, error) {
+	if len(key) == 33 {
+		return crypto.DecompressPubkey(key)
+	}
+	return crypto.UnmarshalPubkey(key)
+}
+
+func newSegmentCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Segments are numbered, so that they can't be reordered, and the last one is
+// marked, so that the result can't be cut short.
+func segmentNonce(aead cipher.AEAD, counter uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+func segmentData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// EncryptResult encrypts everything read from r so that only the holder of the
+// private key matching pub can read it, and writes it to w.
+//
+// A random AES-256 key is encrypted to pub with ECIES and written first, as a
+// 2-byte big-endian length and the encrypted key. The data follows in
+// AES-256-GCM sealed segments of up to 64 KiB, each written as a 4-byte
+// big-endian length and the sealed segment. The nonce of each segment is its
+// number, and its additional data is 1 for the last segment and 0 otherwise.
+func EncryptResult(w io.Writer, r io.Reader, pub *ecdsa.PublicKey) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	wrapped, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), key, nil, nil)
+	if err != nil {
+		return err
+	}
+	aead, err := newSegmentCipher(key)
+	if err != nil {
+		return err
+	}
+
+	header := append([]byte(sealedResultMagic), 0, 0)
+	binary.BigEndian.PutUint16(header[len(sealedResultMagic):], uint16(len(wrapped)))
+	if _, err = w.Write(append(header, wrapped...)); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(r, sealedSegmentSize)
+	plaintext := make([]byte, sealedSegmentSize)
+	length := make([]byte, 4)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(reader, plaintext)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		last := err != nil
+		if !last {
+			if _, err = reader.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		sealed := aead.Seal(nil, segmentNonce(aead, counter), plaintext[:n], segmentData(last))
+		binary.BigEndian.PutUint32(length, uint32(len(sealed)))
+		if _, err = w.Write(length); err != nil {
+			return err
+		}
+		if _, err = w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// DecryptResult decrypts a result written by EncryptResult using the private
+// key it was encrypted for, and writes it to w.
+func DecryptResult(w io.Writer, r io.Reader, prv *ecdsa.PrivateKey) error {
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(sealedResultMagic)+2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	} else if string(header[:len(sealedResultMagic)]) != sealedResultMagic {
+		return errors.New("not an encrypted result")
+	}
+
+	wrapped := make([]byte, binary.BigEndian.Uint16(header[len(sealedResultMagic):]))
+	if _, err := io.ReadFull(reader, wrapped); err != nil {
+		return fmt.Errorf("reading key: %w", err)
+	}
+	key, err := ecies.ImportECDSA(prv).Decrypt(wrapped, nil, nil)
+	if err != nil {
+		return fmt.Errorf("decrypting key: %w", err)
+	}
+	aead, err := newSegmentCipher(key)
+	if err != nil {
+		return err
+	}
+
+	length := make([]byte, 4)
+	for counter := uint64(0); ; counter++ {
+		if _, err = io.ReadFull(reader, length); err != nil {
+			return fmt.Errorf("segment %d: %w", counter, io.ErrUnexpectedEOF)
+		}
+		size := binary.BigEndian.Uint32(length)
+		if size > sealedSegmentSize+uint32(aead.Overhead()) {
+			return fmt.Errorf("segment %d is too long", counter)
+		}
+		sealed := make([]byte, size)
+		if _, err = io.ReadFull(reader, sealed); err != nil {
+			return fmt.Errorf("segment %d: %w", counter, io.ErrUnexpectedEOF)
+		}
+
+		nonce := segmentNonce(aead, counter)
+		last := false
+		plaintext, err := aead.Open(nil, nonce, sealed, segmentData(false))
+		if err != nil {
+			last = true
+			if plaintext, err = aead.Open(nil, nonce, sealed, segmentData(true)); err != nil {
+				return fmt.Errorf("segment %d: %w", counter, err)
+			}
+		}
+		if _, err = w.Write(plaintext); err != nil {
+			return err
+		}
+
+		if last {
+			if _, err = reader.Peek(1); err != io.EOF {
+				return errors.New("data after the last segment")
+			}
+			return nil
+		}
+	}
+}
+
+// An IPFSUploader adds files to IPFS.
+type IPFSUploader interface {
+	// Add uploads everything read from r as a single file, returning its CID.
+	Add(ctx context.Context, r io.Reader) (cid.Cid, error)
+}
+
+type ipfsAPIUploader struct {
+	url    string
+	client *http.Client
+}
+
+// NewIPFSAPIUploader returns an IPFSUploader that adds and pins files using
+// the HTTP RPC API of the IPFS node at the passed URL.
+func NewIPFSAPIUploader(url string) IPFSUploader {
+	return &ipfsAPIUploader{url: url, client: http.DefaultClient}
+}
+
+// Add implements IPFSUploader
+func (u *ipfsAPIUploader) Add(ctx context.Context, r io.Reader) (cid.Cid, error) {
+	body, writer := io.Pipe()
+	defer body.Close()
+
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("file", "result")
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url+"/api/v0/add?cid-version=1&pin=true", body)
+	if err != nil {
+		return cid.Undef, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var response struct {
+		Hash string `json:"Hash"`
+	}
+	if err = doJSONRequest(u.client, req, &response); err != nil {
+		return cid.Undef, fmt.Errorf("ipfs: %w", err)
+	}
+	return cid.Decode(response.Hash)
+}
+
+var _ IPFSUploader = (*ipfsAPIUploader)(nil)
+
+// ResultEncryption encrypts the outputs of jobs whose orders ask for it, so
+// that only the order's creator can read them.
+type ResultEncryption struct {
+	gateway  string
+	client   *http.Client
+	uploader IPFSUploader
+}
+
+// NewResultEncryption returns a ResultEncryption that downloads outputs as tar
+// archives from the passed IPFS HTTP gateway and uploads them again encrypted.
+func NewResultEncryption(gateway string, uploader IPFSUploader) *ResultEncryption {
+	return &ResultEncryption{gateway: gateway, client: http.DefaultClient, uploader: uploader}
+}
+
+// WithResultEncryption lets the workflow take orders that ask for their
+// results to be encrypted. Only the CID of the encrypted output is returned
+// for these orders, whatever result type they asked for. Without it, such
+// orders are rejected.
+func WithResultEncryption(encryption *ResultEncryption) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Encryption = encryption
+	}
+}
+
+// Encrypt downloads the result, encrypts it with the passed public key and
+// uploads it again, returning the CID of the encrypted result.
+func (enc *ResultEncryption) Encrypt(ctx context.Context, result cid.Cid, pub *ecdsa.PublicKey) (cid.Cid, error) {
+	url := fmt.Sprintf("%s/ipfs/%s?format=tar", enc.gateway, result)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+	req.Header.Set("Accept", "application/x-tar")
+
+	res, err := enc.client.Do(req)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return cid.Undef, fmt.Errorf("downloading %s: gateway returned %s", result, res.Status)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(EncryptResult(writer, res.Body, pub))
+	}()
+
+	encrypted, err := enc.uploader.Add(ctx, reader)
+	reader.CloseWithError(err)
+	return encrypted, err
+}
+
+// checkEncryption rejects orders that ask for their results to be encrypted,
+// unless the workflow can encrypt them with the key they passed.
+func (workflow *Workflow) checkEncryption(e ContractSubmittedEvent) error {
+	key := e.EncryptionKey()
+	if len(key) == 0 {
+		return nil
+	} else if workflow.Encryption == nil {
+		return reject("results can't be encrypted by this bridge")
+	} else if _, err := ParseEncryptionKey(key); err != nil {
+		return reject("invalid encryption key: %s", err)
+	}
+	return nil
+}
+
+// encryptResult encrypts the result of the completed order, if it asked for
+// that and it hasn't been already. If the result can't be encrypted the order
+// is held, and the result is the event to carry on with, if any.
+func (workflow *Workflow) encryptResult(ctx context.Context, event BacalhauJobCompletedEvent) (result Event, wait time.Duration, held bool) {
+	key := event.EncryptionKey()
+	if len(key) == 0 || event.EncryptedResult(ctx).Defined() {
+		return nil, 0, false
+	}
+
+	encrypted, err := workflow.sealResult(ctx, event, key)
+	if err != nil {
+		err = fmt.Errorf("encrypting result: %w", err)
+		result, wait = workflow.settle(ctx, event, nil, 0, err)
+		return result, wait, true
+	}
+
+	event.WithEncryptedResult(encrypted)
+	log.Ctx(ctx).Info().Stringer("cid", event.Result(ctx)).Stringer("encrypted", encrypted).Msg("Encrypted result")
+	return nil, 0, false
+}
+
+func (workflow *Workflow) sealResult(ctx context.Context, event BacalhauJobCompletedEvent, key []byte) (cid.Cid, error) {
+	if workflow.Encryption == nil {
+		return cid.Undef, errors.New("results can't be encrypted by this bridge")
+	}
+	pub, err := ParseEncryptionKey(key)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultSealTimeout)
+	defer cancel()
+	return workflow.Encryption.Encrypt(ctx, event.Result(ctx), pub)
+}
@@ -0,0 +1,65 @@
+package bridge
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedResultsRoundTrip(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, sealedSegmentSize, 2*sealedSegmentSize + 100} {
+		plaintext := make([]byte, size)
+		_, err = rand.Read(plaintext)
+		require.NoError(t, err)
+
+		var encrypted bytes.Buffer
+		require.NoError(t, EncryptResult(&encrypted, bytes.NewReader(plaintext), &key.PublicKey))
+
+		var decrypted bytes.Buffer
+		require.NoError(t, DecryptResult(&decrypted, bytes.NewReader(encrypted.Bytes()), key))
+		require.Equal(t, plaintext, decrypted.Bytes(), "size %d", size)
+	}
+}
+
+func TestEncryptedResultsCantBeTamperedWith(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	plaintext := bytes.Repeat([]byte("x"), 2*sealedSegmentSize)
+	var encrypted bytes.Buffer
+	require.NoError(t, EncryptResult(&encrypted, bytes.NewReader(plaintext), &key.PublicKey))
+
+	var decrypted bytes.Buffer
+	require.Error(t, DecryptResult(&decrypted, bytes.NewReader(encrypted.Bytes()), other), "wrong key")
+
+	// Leaving out the last segment must be noticed.
+	lastSegment := 4 + sealedSegmentSize + 16
+	truncated := encrypted.Bytes()[:encrypted.Len()-lastSegment]
+	require.Error(t, DecryptResult(&decrypted, bytes.NewReader(truncated), key), "truncated")
+
+	flipped := append([]byte(nil), encrypted.Bytes()...)
+	flipped[len(flipped)-1] ^= 1
+	require.Error(t, DecryptResult(&decrypted, bytes.NewReader(flipped), key), "modified")
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	for _, encoded := range [][]byte{crypto.FromECDSAPub(&key.PublicKey), crypto.CompressPubkey(&key.PublicKey)} {
+		parsed, err := ParseEncryptionKey(encoded)
+		require.NoError(t, err)
+		require.True(t, parsed.Equal(&key.PublicKey))
+	}
+
+	_, err = ParseEncryptionKey([]byte("not a key"))
+	require.Error(t, err)
+}
@@ -0,0 +1,312 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// A ResultFetcher keeps an off-chain copy of the results of completed jobs.
+type ResultFetcher interface {
+	// Fetch downloads the result with the passed CID, checks that what was
+	// downloaded really does have that CID, and stores it.
+	Fetch(ctx context.Context, result cid.Cid) error
+}
+
+// A ResultStore is somewhere that verified results are kept.
+type ResultStore interface {
+	// Has returns whether the result with the passed CID is already stored.
+	Has(ctx context.Context, result cid.Cid) (bool, error)
+
+	// Put stores the CAR file containing the result with the passed CID.
+	Put(ctx context.Context, result cid.Cid, car io.Reader) error
+}
+
+type gatewayFetcher struct {
+	gateway string
+	client  *http.Client
+	store   ResultStore
+}
+
+// NewGatewayFetcher returns a ResultFetcher that downloads results as CAR files
+// from the passed IPFS HTTP gateway and verifies every block before storing.
+func NewGatewayFetcher(gateway string, store ResultStore) ResultFetcher {
+	return &gatewayFetcher{gateway: gateway, client: http.DefaultClient, store: store}
+}
+
+// Fetch implements ResultFetcher
+func (f *gatewayFetcher) Fetch(ctx context.Context, result cid.Cid) error {
+	if has, err := f.store.Has(ctx, result); err != nil || has {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/ipfs/%s?format=car", f.gateway, result)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.car")
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: gateway returned %s", result, res.Status)
+	}
+
+	// Verify the CAR as it streams into the store. If verification fails, the
+	// pipe is closed with the error so the store sees a failed write.
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(verifyCAR(io.TeeReader(res.Body, writer), result))
+	}()
+
+	err = f.store.Put(ctx, result, reader)
+	reader.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Stringer("cid", result).Msg("Fetched and verified result")
+	return nil
+}
+
+// verifyCAR reads a CARv1 stream, checking that every block hashes to its CID
+// and that the block with the root CID is present.
+func verifyCAR(r io.Reader, root cid.Cid) error {
+	reader := bufio.NewReader(r)
+
+	// Skip the header, which we don't need – we know what root to expect.
+	headerLen, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return errors.Wrap(err, "invalid CAR header")
+	}
+	if _, err = io.CopyN(io.Discard, reader, int64(headerLen)); err != nil {
+		return errors.Wrap(err, "invalid CAR header")
+	}
+
+	foundRoot := false
+	for {
+		sectionLen, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "invalid CAR section")
+		}
+
+		section := make([]byte, sectionLen)
+		if _, err = io.ReadFull(reader, section); err != nil {
+			return errors.Wrap(err, "truncated CAR section")
+		}
+
+		n, blockCid, err := cid.CidFromBytes(section)
+		if err != nil {
+			return errors.Wrap(err, "invalid block CID")
+		}
+
+		actual, err := blockCid.Prefix().Sum(section[n:])
+		if err != nil {
+			return err
+		} else if !actual.Equals(blockCid) {
+			return fmt.Errorf("block %s has hash %s", blockCid, actual)
+		}
+
+		foundRoot = foundRoot || blockCid.Equals(root)
+	}
+
+	if !foundRoot {
+		return fmt.Errorf("CAR does not contain root %s", root)
+	}
+	return nil
+}
+
+var _ ResultFetcher = (*gatewayFetcher)(nil)
+
+type directoryStore struct {
+	dir string
+}
+
+// NewDirectoryStore returns a ResultStore that keeps results as CAR files in
+// the passed local directory.
+func NewDirectoryStore(dir string) (ResultStore, error) {
+	return &directoryStore{dir: dir}, os.MkdirAll(dir, 0755)
+}
+
+func (s *directoryStore) path(result cid.Cid) string {
+	return filepath.Join(s.dir, result.String()+".car")
+}
+
+// Has implements ResultStore
+func (s *directoryStore) Has(ctx context.Context, result cid.Cid) (bool, error) {
+	_, err := os.Stat(s.path(result))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put implements ResultStore
+func (s *directoryStore) Put(ctx context.Context, result cid.Cid, car io.Reader) error {
+	// Write to a temporary file first so that a failed download never leaves
+	// a partial result that looks complete.
+	tmp, err := os.CreateTemp(s.dir, result.String()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, car)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(result))
+}
+
+var _ ResultStore = (*directoryStore)(nil)
+
+type s3Store struct {
+	client      *http.Client
+	endpoint    string
+	region      string
+	credentials awsCredentials
+}
+
+// NewS3Store returns a ResultStore that keeps results as CAR files in the
+// passed S3 bucket. Credentials are read from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func NewS3Store(bucket, region string) (ResultStore, error) {
+	credentials := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	} else if region == "" {
+		return nil, fmt.Errorf("the region of bucket %s must be set", bucket)
+	}
+
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	return newS3Store(http.DefaultClient, endpoint, region, credentials), nil
+}
+
+func newS3Store(client *http.Client, endpoint, region string, credentials awsCredentials) *s3Store {
+	return &s3Store{client: client, endpoint: endpoint, region: region, credentials: credentials}
+}
+
+// request sends a signed request for the object holding the passed result,
+// with a body of the passed size and hex-encoded SHA-256 hash.
+func (s *s3Store) request(ctx context.Context, method string, result cid.Cid, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s.car", s.endpoint, result), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signAWSRequestHash(req, payloadHash, s.region, "s3", s.credentials, time.Now())
+	return s.client.Do(req)
+}
+
+// Has implements ResultStore
+func (s *s3Store) Has(ctx context.Context, result cid.Cid) (bool, error) {
+	empty := sha256.Sum256(nil)
+	res, err := s.request(ctx, http.MethodHead, result, nil, 0, hex.EncodeToString(empty[:]))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("checking for %s: S3 returned %s", result, res.Status)
+	}
+}
+
+// Put implements ResultStore
+func (s *s3Store) Put(ctx context.Context, result cid.Cid, car io.Reader) error {
+	// The whole CAR file is written to disk first, both so that it is signed
+	// with its hash and so that a failed download is never uploaded.
+	tmp, err := os.CreateTemp("", result.String()+".*.car")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), car)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	res, err := s.request(ctx, http.MethodPut, result, io.NopCloser(tmp), size, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("storing %s: S3 returned %s", result, res.Status)
+	}
+	return nil
+}
+
+var _ ResultStore = (*s3Store)(nil)
+
+// ResultFetcherFromEnv returns a fetcher that stores results in RESULTS_DIR or
+// in the S3 bucket RESULTS_S3_BUCKET in RESULTS_S3_REGION, downloading them
+// from IPFS_GATEWAY, or nil if neither is set.
+func ResultFetcherFromEnv() (ResultFetcher, error) {
+	dir := os.Getenv("RESULTS_DIR")
+	bucket := os.Getenv("RESULTS_S3_BUCKET")
+
+	var store ResultStore
+	var err error
+	switch {
+	case dir != "" && bucket != "":
+		return nil, fmt.Errorf("only one of RESULTS_DIR and RESULTS_S3_BUCKET can be set")
+	case dir != "":
+		store, err = NewDirectoryStore(dir)
+		err = errors.Wrap(err, "RESULTS_DIR")
+	case bucket != "":
+		store, err = NewS3Store(bucket, os.Getenv("RESULTS_S3_REGION"))
+		err = errors.Wrap(err, "RESULTS_S3_BUCKET")
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gateway, found := os.LookupEnv("IPFS_GATEWAY")
+	if !found || gateway == "" {
+		gateway = "https://ipfs.io"
+	}
+	return NewGatewayFetcher(gateway, store), nil
+}
@@ -0,0 +1,156 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func rawBlock(t *testing.T, data []byte) cid.Cid {
+	prefix := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: 0x12, MhLength: -1}
+	c, err := prefix.Sum(data)
+	require.NoError(t, err)
+	return c
+}
+
+func carFile(header []byte, blocks map[cid.Cid][]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(binary.AppendUvarint(nil, uint64(len(header))))
+	buf.Write(header)
+	for c, data := range blocks {
+		section := append(c.Bytes(), data...)
+		buf.Write(binary.AppendUvarint(nil, uint64(len(section))))
+		buf.Write(section)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyCAR(t *testing.T) {
+	data := []byte("hello lilypad")
+	root := rawBlock(t, data)
+
+	car := carFile([]byte("header"), map[cid.Cid][]byte{root: data})
+	require.NoError(t, verifyCAR(bytes.NewReader(car), root))
+
+	tampered := carFile([]byte("header"), map[cid.Cid][]byte{root: []byte("goodbye")})
+	require.Error(t, verifyCAR(bytes.NewReader(tampered), root))
+
+	other := rawBlock(t, []byte("other"))
+	require.Error(t, verifyCAR(bytes.NewReader(car), other))
+}
+
+func TestDirectoryStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewDirectoryStore(t.TempDir())
+	require.NoError(t, err)
+
+	result := rawBlock(t, []byte("result"))
+	has, err := store.Has(ctx, result)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, store.Put(ctx, result, bytes.NewReader([]byte("car"))))
+	has, err = store.Has(ctx, result)
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"))
+
+		switch r.Method {
+		case http.MethodHead:
+			if _, ok := objects[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			hash := sha256.Sum256(body)
+			require.Equal(t, hex.EncodeToString(hash[:]), r.Header.Get("X-Amz-Content-Sha256"))
+			objects[r.URL.Path] = body
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	store := newS3Store(server.Client(), server.URL, "eu-west-1", awsCredentials{AccessKeyID: "key", SecretAccessKey: "secret"})
+	result := rawBlock(t, []byte("result"))
+	has, err := store.Has(ctx, result)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, store.Put(ctx, result, bytes.NewReader([]byte("car"))))
+	require.Equal(t, []byte("car"), objects["/"+result.String()+".car"])
+	has, err = store.Has(ctx, result)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	err = store.Put(ctx, rawBlock(t, []byte("other")), iotest.ErrReader(errors.New("verification failed")))
+	require.Error(t, err)
+	require.Len(t, objects, 1, "results that fail to download shouldn't be stored")
+}
+
+// slowFetcher takes a moment over each fetch, and records how many were in
+// progress at once.
+type slowFetcher struct {
+	mu                      sync.Mutex
+	fetching, most, fetched int
+}
+
+func (f *slowFetcher) Fetch(ctx context.Context, result cid.Cid) error {
+	f.mu.Lock()
+	f.fetching++
+	if f.fetching > f.most {
+		f.most = f.fetching
+	}
+	f.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetching--
+	f.fetched++
+	return nil
+}
+
+func TestOnlySoManyResultsAreFetchedAtOnce(t *testing.T) {
+	fetcher := &slowFetcher{}
+	workflow := NewWorkflow(&mockRunner{}, mockContract{}, repository(t), WithResultFetcher(fetcher))
+
+	for i := 0; i < 3; i++ {
+		results := []cid.Cid{}
+		for j := 0; j < 5; j++ {
+			results = append(results, rawBlock(t, []byte{byte(i), byte(j)}))
+		}
+		event := walEvent(byte(i)).JobCreated(model.NewJob()).Completed(cid.Cid{}, "", "", 0).WithResults(results)
+		workflow.fetchResults(context.Background(), event)
+	}
+
+	require.Eventually(t, func() bool {
+		fetcher.mu.Lock()
+		defer fetcher.mu.Unlock()
+		return fetcher.fetched == 15
+	}, time.Second, 5*time.Millisecond)
+	require.LessOrEqual(t, fetcher.most, resultFetchConcurrency)
+}
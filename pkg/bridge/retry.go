@@ -2,6 +2,7 @@ package bridge
 
 import (
 	"math"
+	"math/rand"
 	"time"
 )
 
@@ -34,3 +35,34 @@ var Immediate RetryStrategy = func(e Retryable) time.Duration {
 func ShouldRetry(event Retryable) bool {
 	return event.Attempts() < maxAttemptsByState[event.OrderState()]
 }
+
+// A BackoffPolicy describes how many times a single call should be attempted
+// before giving up, and how long to wait in between attempts.
+type BackoffPolicy struct {
+	// The total number of attempts to make, including the first.
+	MaxAttempts uint
+
+	// How long to wait before the first retry. The wait doubles for each
+	// subsequent retry.
+	Backoff time.Duration
+
+	// The maximum fraction of each wait to add on at random, so that many
+	// callers retrying at once don't all hit the network at the same time.
+	Jitter float64
+}
+
+var defaultSubmitPolicy = BackoffPolicy{
+	MaxAttempts: 3,
+	Backoff:     time.Second,
+	Jitter:      0.2,
+}
+
+// Wait returns how long to wait before making the passed retry attempt, where
+// the first retry is attempt 1.
+func (p BackoffPolicy) Wait(attempt uint) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+	wait := p.Backoff * time.Duration(1<<(attempt-1))
+	return wait + time.Duration(rand.Float64()*p.Jitter*float64(wait))
+}
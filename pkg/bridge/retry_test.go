@@ -1,10 +1,14 @@
 package bridge
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -39,3 +43,63 @@ func TestShouldRetry(t *testing.T) {
 		e.AddAttempt()
 	}
 }
+
+func TestBackoffPolicy(t *testing.T) {
+	policy := BackoffPolicy{MaxAttempts: 4, Backoff: time.Second, Jitter: 0.5}
+	require.Equal(t, time.Duration(0), policy.Wait(0))
+
+	for attempt, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		actual := policy.Wait(uint(attempt + 1))
+		require.GreaterOrEqual(t, actual, wait)
+		require.LessOrEqual(t, actual, wait+wait/2)
+	}
+}
+
+func TestOnlyRetryableErrorsAreRetried(t *testing.T) {
+	ctx := context.Background()
+	var createErr error
+	runner := &mockRunner{
+		CreateHandler: func(context.Context, ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+			return nil, createErr
+		},
+	}
+	workflow := NewWorkflow(runner, &mockContract{}, repository(t))
+
+	createErr = &bridgeerrors.BacalhauError{Err: &net.DNSError{Err: "no such host", Name: "bacalhau"}}
+	result, _ := workflow.ProcessEvent(ctx, exampleEvent())
+	require.Equal(t, OrderStateSubmitted, result.OrderState(), "a DNS failure should be retried")
+	require.Equal(t, uint(1), result.(Retryable).Attempts())
+
+	createErr = &bridgeerrors.SpecError{Err: errors.New("Docker jobs must specify an image")}
+	result, _ = workflow.ProcessEvent(ctx, exampleEvent())
+	require.Equal(t, OrderStateFailed, result.OrderState(), "an invalid spec should fail straight away")
+	require.Equal(t, FailureReasonRejected, result.(ContractFailedEvent).FailureReason())
+}
+
+type undecliningContract struct {
+	mockContract
+}
+
+// Decline implements DecliningContract
+func (undecliningContract) Decline(context.Context, ContractFailedEvent) (ContractRefundedEvent, error) {
+	return nil, fmt.Errorf("declining orders: %w", ErrNotSupportedByContract)
+}
+
+func TestOrdersThatCantBeDeclinedAreRefunded(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	refunds := 0
+	contract := undecliningContract{mockContract{
+		RefundHandler: func(_ context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+			refunds++
+			return e.Refunded(), nil
+		},
+	}}
+	workflow := NewWorkflow(&mockRunner{}, contract, repo, WithDeadLetterQueue(repo.(DeadLetterQueue)))
+
+	failed := exampleEvent().FailedWith(FailureReasonUnderpriced, "offered too little")
+	result, _ := workflow.ProcessEvent(ctx, failed)
+	require.NotNil(t, result)
+	require.Equal(t, OrderStateRefunded, result.OrderState(), "the order should be refunded rather than dead-lettered")
+	require.Equal(t, 1, refunds)
+}
@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A JobRunner is a component that converts events into messages into a compute
+// network. The default runner submits jobs to Bacalhau, but other backends can
+// be made available using RegisterRunner.
+//
+// Whatever the backend, the job spec is always read from the contract event as
+// a Bacalhau spec, and the ID of the job created on the backend is recorded in
+// the Metadata.ID field of the job passed to JobCreated.
+type JobRunner interface {
+	// Create starts a new job for the passed contract submission.
+	Create(ctx context.Context, job ContractSubmittedEvent) (BacalhauJobRunningEvent, error)
+
+	// FindCompleted queries the compute network for job statuses for the
+	// passed jobs, and returns slices of jobs that have either completed
+	// successfully (according to the network) or have failed.
+	//
+	// Any jobs still in progress are not returned. Any jobs that the network
+	// does not seem to know about are considered failed.
+	FindCompleted(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent)
+
+	// Cancel asks the compute network to stop running the job for the passed
+	// event, so that an order we have given up on doesn't keep using compute.
+	Cancel(ctx context.Context, job BacalhauJobRunningEvent) error
+}
+
+// A RunnerFactory builds a new JobRunner, reading any configuration it needs
+// from the environment.
+type RunnerFactory func() (JobRunner, error)
+
+// The name of the runner used if no other is configured.
+const DefaultRunner string = "bacalhau"
+
+var (
+	runnersMu sync.RWMutex
+	runners   = map[string]RunnerFactory{}
+)
+
+// RegisterRunner makes a compute backend available by the passed name. It is
+// intended to be called from the init function of the package implementing the
+// backend. Registering the same name twice replaces the earlier factory.
+func RegisterRunner(name string, factory RunnerFactory) {
+	runnersMu.Lock()
+	defer runnersMu.Unlock()
+	runners[name] = factory
+}
+
+// RunnerNames returns the names of all of the registered compute backends.
+func RunnerNames() []string {
+	runnersMu.RLock()
+	defer runnersMu.RUnlock()
+
+	names := make([]string, 0, len(runners))
+	for name := range runners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewRunner builds the compute backend registered with the passed name.
+func NewRunner(name string) (JobRunner, error) {
+	runnersMu.RLock()
+	factory, found := runners[name]
+	runnersMu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("unknown job runner %q (known runners: %v)", name, RunnerNames())
+	}
+	return factory()
+}
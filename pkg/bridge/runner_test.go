@@ -0,0 +1,26 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRunner(t *testing.T) {
+	mock := &mockRunner{}
+	RegisterRunner("test", func() (JobRunner, error) {
+		return mock, nil
+	})
+
+	require.Contains(t, RunnerNames(), "test")
+	require.Contains(t, RunnerNames(), DefaultRunner)
+
+	runner, err := NewRunner("test")
+	require.NoError(t, err)
+	require.Equal(t, mock, runner)
+}
+
+func TestUnknownRunner(t *testing.T) {
+	_, err := NewRunner("does-not-exist")
+	require.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package bridge
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bacalhau-project/bacalhau/pkg/compute/capacity"
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+)
+
+// A Sanitizer is a Policy that can make a job spec safe to run by rewriting
+// it, rather than only refusing it.
+type Sanitizer interface {
+	// Sanitize rewrites the spec in place, returning what it changed, or a
+	// *Rejection if the spec can't be made safe.
+	Sanitize(spec *model.Spec) ([]string, error)
+}
+
+// A SandboxPolicy scrubs job specs of settings that would let a job reach
+// further out of its container, or take more of the node, than the operator
+// wants. Bacalhau specs can't ask for a privileged container or the host's
+// network namespace, so the settings that come closest are dealt with:
+// network access, environment variables that change how programs are loaded,
+// resources beyond the policy's limits, and entrypoints that smuggle in shell
+// commands.
+type SandboxPolicy struct {
+	// Whether settings that aren't allowed are rewritten to safe values rather
+	// than the order being rejected. Entrypoints are never rewritten, as
+	// changing what a job runs can't make it safe.
+	Rewrite bool `yaml:"rewrite"`
+
+	// The kinds of network access jobs may have, such as None or HTTP. If
+	// empty, jobs may have any. Rewritten jobs get no network.
+	Networks []string `yaml:"networks"`
+
+	// The environment variables jobs may set, by name. Rewritten jobs lose
+	// the variables that aren't allowed.
+	Env PatternList `yaml:"env"`
+
+	// Whether Docker entrypoints that run a command through a shell, or that
+	// chain or substitute commands, are refused.
+	DenyShell bool `yaml:"denyShell"`
+}
+
+// Shells that run the command passed with -c.
+var shells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true, "ksh": true}
+
+// What a raw command line, rather than a program and its arguments, looks
+// like.
+var shellOperators = []string{";", "&&", "||", "|", "`", "$("}
+
+// Sanitize implements Sanitizer
+func (p *SpecPolicy) Sanitize(spec *model.Spec) ([]string, error) {
+	return p.Sandbox.apply(spec, p.Resources)
+}
+
+var _ Sanitizer = (*SpecPolicy)(nil)
+
+// apply makes the spec fit the sandbox, clamping the resources it asks for to
+// the passed limits if rewriting.
+func (s SandboxPolicy) apply(spec *model.Spec, limits model.ResourceUsageConfig) ([]string, error) {
+	if spec.Engine == model.EngineDocker && s.DenyShell {
+		if err := checkEntrypoint(spec.Docker.Entrypoint); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []string
+	if !s.permitsNetwork(spec.Network.Type) {
+		if !s.Rewrite {
+			return nil, reject("network access %s is not allowed", spec.Network.Type)
+		}
+		changes = append(changes, fmt.Sprintf("network %s to %s", spec.Network.Type, model.NetworkNone))
+		spec.Network = model.NetworkConfig{Type: model.NetworkNone}
+	}
+
+	if spec.Engine == model.EngineDocker {
+		env := spec.Docker.EnvironmentVariables[:0:0]
+		for _, variable := range spec.Docker.EnvironmentVariables {
+			name, _, _ := strings.Cut(variable, "=")
+			if s.Env.Permits(name) {
+				env = append(env, variable)
+			} else if !s.Rewrite {
+				return nil, reject("environment variable %q is not allowed", name)
+			} else {
+				changes = append(changes, "removed environment variable "+name)
+			}
+		}
+		spec.Docker.EnvironmentVariables = env
+	}
+
+	if s.Rewrite {
+		changes = append(changes, clampResources(&spec.Resources, limits)...)
+	}
+	return changes, nil
+}
+
+func (s SandboxPolicy) permitsNetwork(network model.Network) bool {
+	if len(s.Networks) == 0 {
+		return true
+	}
+	for _, name := range s.Networks {
+		if strings.EqualFold(name, network.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEntrypoint returns a *Rejection if the entrypoint runs a command through
+// a shell or looks like a raw command line.
+func checkEntrypoint(entrypoint []string) error {
+	if len(entrypoint) > 1 && shells[path.Base(entrypoint[0])] {
+		for _, arg := range entrypoint[1:] {
+			if len(arg) > 1 && arg[0] == '-' && arg[1] != '-' && strings.Contains(arg, "c") {
+				return reject("entrypoint runs a shell command")
+			}
+		}
+	}
+	for _, arg := range entrypoint {
+		for _, operator := range shellOperators {
+			if strings.Contains(arg, operator) {
+				return reject("entrypoint contains shell operator %q", operator)
+			}
+		}
+	}
+	return nil
+}
+
+// clampResources lowers any resource asked for beyond its limit to the limit,
+// returning what it changed.
+func clampResources(resources *model.ResourceUsageConfig, limits model.ResourceUsageConfig) []string {
+	requested := capacity.ParseResourceUsageConfig(*resources)
+	allowed := capacity.ParseResourceUsageConfig(limits)
+
+	var changes []string
+	clamp := func(name string, value *string, limit string, over bool) {
+		if limit != "" && over {
+			changes = append(changes, fmt.Sprintf("%s %s to %s", name, *value, limit))
+			*value = limit
+		}
+	}
+	clamp("CPU", &resources.CPU, limits.CPU, requested.CPU > allowed.CPU)
+	clamp("memory", &resources.Memory, limits.Memory, requested.Memory > allowed.Memory)
+	clamp("disk", &resources.Disk, limits.Disk, requested.Disk > allowed.Disk)
+	clamp("GPU", &resources.GPU, limits.GPU, requested.GPU > allowed.GPU)
+	return changes
+}
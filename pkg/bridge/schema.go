@@ -0,0 +1,196 @@
+package bridge
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The version of the JSON encoding of events written by MarshalEvent. It is
+// increased whenever a change is made that older readers would misunderstand.
+const EventSchemaVersion = 1
+
+// EventSchema is a JSON Schema describing the encoding written by
+// MarshalEvent, for use by consumers written in other languages.
+//
+//go:embed schema/event.v1.json
+var EventSchema []byte
+
+// eventJSON is the stable JSON encoding of an event. Fields that only make
+// sense in some states are left out in the others.
+type eventJSON struct {
+	Version       int             `json:"version"`
+	State         string          `json:"state"`
+	OrderID       common.Hash     `json:"orderId"`
+	Requestor     common.Address  `json:"requestor"`
+	OrderNumber   int64           `json:"orderNumber"`
+	ResultType    ResultType      `json:"resultType"`
+	Attempts      uint            `json:"attempts"`
+	LastAttempt   *time.Time      `json:"lastAttempt,omitempty"`
+	Spec          json.RawMessage `json:"spec,omitempty"`
+	Resubmissions uint            `json:"resubmissions"`
+	JobID         string          `json:"jobId,omitempty"`
+	Endpoint      string          `json:"endpoint,omitempty"`
+	Executions    []Execution     `json:"executions,omitempty"`
+	Result        string          `json:"result,omitempty"`
+	Results       []string        `json:"results,omitempty"`
+	OutputHash    string          `json:"outputHash,omitempty"`
+	EncryptionKey hexutil.Bytes   `json:"encryptionKey,omitempty"`
+	Encrypted     string          `json:"encryptedResult,omitempty"`
+	Durable       bool            `json:"durableStorage,omitempty"`
+	DealID        string          `json:"dealId,omitempty"`
+	Price         string          `json:"offeredPrice,omitempty"`
+	Stdout        string          `json:"stdout,omitempty"`
+	Stderr        string          `json:"stderr,omitempty"`
+	ExitCode      *int            `json:"exitCode,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	FailureReason string          `json:"failureReason,omitempty"`
+	StateMessage  string          `json:"stateMessage,omitempty"`
+	Timeline      *Timeline       `json:"timeline,omitempty"`
+	Contract      *common.Address `json:"contract,omitempty"`
+	PaymentToken  *common.Address `json:"paymentToken,omitempty"`
+}
+
+// isFailedState returns whether events in the passed state record an error
+// rather than the output of a job.
+func isFailedState(state OrderState) bool {
+	return state == OrderStateJobError || state == OrderStateFailed || state == OrderStateRefunded
+}
+
+// MarshalEvent returns the versioned JSON encoding of the passed event.
+func MarshalEvent(in Event) ([]byte, error) {
+	e, ok := in.(*event)
+	if !ok {
+		return nil, fmt.Errorf("don't know how to encode event of type %T", in)
+	}
+
+	j := eventJSON{
+		Version:       EventSchemaVersion,
+		State:         e.state.String(),
+		OrderID:       e.OrderId(),
+		Requestor:     e.OrderRequestor(),
+		OrderNumber:   e.orderNumber,
+		ResultType:    e.OrderResultType(),
+		Attempts:      e.attempts,
+		Spec:          e.jobSpec,
+		Resubmissions: e.resubmissions,
+		JobID:         e.jobId,
+		Endpoint:      e.jobEndpoint,
+		Executions:    e.jobExecutions,
+		Result:        e.jobResult,
+		Results:       e.jobResults,
+		OutputHash:    e.jobOutputHash,
+		EncryptionKey: e.encryptionKey,
+		Encrypted:     e.jobEncryptedResult,
+		Durable:       e.durableStorage,
+		DealID:        e.jobDealId,
+		Price:         e.orderPrice,
+		Stdout:        e.jobStdout,
+	}
+	if !e.lastAttempt.IsZero() {
+		lastAttempt := e.lastAttempt.UTC()
+		j.LastAttempt = &lastAttempt
+	}
+	if e.timeline != (Timeline{}) {
+		timeline := e.timeline
+		j.Timeline = &timeline
+	}
+	if len(e.orderContract) > 0 {
+		contract := e.SourceContract()
+		j.Contract = &contract
+	}
+	if len(e.orderToken) > 0 {
+		token := e.PaymentToken()
+		j.PaymentToken = &token
+	}
+
+	if isFailedState(e.state) {
+		j.Error = e.jobStderr
+		j.FailureReason = e.failureReason.String()
+		j.StateMessage = e.stateMessage
+	} else {
+		j.Stderr = e.jobStderr
+	}
+
+	if e.state == OrderStateCompleted || e.state == OrderStatePaid {
+		exitCode := e.jobExitcode
+		j.ExitCode = &exitCode
+	}
+
+	return json.Marshal(j)
+}
+
+// UnmarshalEvent decodes an event written by MarshalEvent. Events written by a
+// newer version of the schema are refused.
+func UnmarshalEvent(data []byte) (Event, error) {
+	var j eventJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+
+	if j.Version == 0 {
+		return nil, fmt.Errorf("event has no schema version")
+	} else if j.Version > EventSchemaVersion {
+		return nil, fmt.Errorf("event has schema version %d, newer than %d", j.Version, EventSchemaVersion)
+	}
+
+	state, err := ParseOrderState(j.State)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &event{
+		orderId:         j.OrderID.Bytes(),
+		orderOwner:      j.Requestor.Bytes(),
+		orderNumber:     j.OrderNumber,
+		orderResultType: uint8(j.ResultType),
+		attempts:        j.Attempts,
+		state:           state,
+		jobSpec:         j.Spec,
+		jobId:           j.JobID,
+		jobResult:       j.Result,
+		jobStdout:       j.Stdout,
+		jobStderr:       j.Stderr,
+		jobResults:      j.Results,
+		jobOutputHash:   j.OutputHash,
+		encryptionKey:   j.EncryptionKey,
+		resubmissions:   j.Resubmissions,
+		jobExecutions:   j.Executions,
+		jobEndpoint:     j.Endpoint,
+		stateMessage:    j.StateMessage,
+	}
+	e.jobEncryptedResult = j.Encrypted
+	e.durableStorage = j.Durable
+	e.jobDealId = j.DealID
+	e.orderPrice = j.Price
+	if j.LastAttempt != nil {
+		e.lastAttempt = *j.LastAttempt
+	}
+	if j.ExitCode != nil {
+		e.jobExitcode = *j.ExitCode
+	}
+	if j.Timeline != nil {
+		e.timeline = *j.Timeline
+	}
+	if j.Contract != nil {
+		e.orderContract = j.Contract.Bytes()
+	}
+	if j.PaymentToken != nil {
+		e.orderToken = j.PaymentToken.Bytes()
+	}
+
+	if isFailedState(state) {
+		e.jobStderr = j.Error
+		if e.failureReason, err = parseFailureReason(j.FailureReason); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.checkResults(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,125 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+func goldenEvent() *event {
+	return &event{
+		orderId:         bytes.Repeat([]byte{0xab}, 32),
+		orderOwner:      bytes.Repeat([]byte{0xcd}, 20),
+		orderNumber:     42,
+		orderResultType: uint8(ResultTypeStdOut),
+		attempts:        1,
+		lastAttempt:     time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		state:           OrderStateSubmitted,
+		jobSpec:         []byte(`{"Engine":"Docker"}`),
+	}
+}
+
+func goldenRunningEvent() BacalhauJobRunningEvent {
+	job := model.NewJob()
+	job.Metadata.ID = "job-1"
+	return goldenEvent().
+		JobCreated(job).
+		WithEndpoint("http://bacalhau.example.com:1234").
+		WithExecutions([]Execution{{NodeID: "QmNode", State: "Running"}})
+}
+
+func TestEventSchemaGolden(t *testing.T) {
+	result := cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
+	events := map[string]Event{
+		"submitted": goldenEvent(),
+		"running":   goldenRunningEvent(),
+		"completed": goldenRunningEvent().Completed(result, "hello\n", "", 0).WithResults([]cid.Cid{result}).WithOutputHash(common.Hash{0xef}).WithDealID("81234"),
+		"job_error": goldenRunningEvent().JobFailed(FailureReasonTimeout, "timed out", "InProgress; QmNode: Running"),
+		"failed":    goldenEvent().FailedWith(FailureReasonRejected, "not allowed"),
+	}
+
+	// Milestones are recorded when they are reached, so they are pinned to
+	// keep the golden files stable.
+	for name, e := range events {
+		e.(*event).timeline = Timeline{}
+		if name == "completed" {
+			e.(*event).timeline = Timeline{
+				Observed:  time.Date(2023, 1, 2, 3, 4, 0, 0, time.UTC),
+				Submitted: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+				Completed: time.Date(2023, 1, 2, 3, 5, 0, 0, time.UTC),
+			}
+		}
+	}
+
+	for name, e := range events {
+		t.Run(name, func(t *testing.T) {
+			golden := filepath.Join("testdata", "events", name+".json")
+
+			actual, err := MarshalEvent(e)
+			require.NoError(t, err)
+
+			if *update {
+				var indented bytes.Buffer
+				require.NoError(t, json.Indent(&indented, actual, "", "  "))
+				require.NoError(t, os.WriteFile(golden, append(indented.Bytes(), '\n'), 0644))
+			}
+
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(actual))
+
+			decoded, err := UnmarshalEvent(expected)
+			require.NoError(t, err)
+			require.Equal(t, e.OrderId(), decoded.OrderId())
+			require.Equal(t, e.OrderState(), decoded.OrderState())
+
+			again, err := MarshalEvent(decoded)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(again))
+		})
+	}
+}
+
+func TestUnmarshalEventChecksVersion(t *testing.T) {
+	_, err := UnmarshalEvent([]byte(`{"state":"Submitted"}`))
+	require.Error(t, err)
+
+	_, err = UnmarshalEvent([]byte(`{"version":2,"state":"Submitted"}`))
+	require.Error(t, err)
+
+	e, err := UnmarshalEvent([]byte(`{"version":1,"state":"Refunded","error":"oops","failureReason":"Cancelled"}`))
+	require.NoError(t, err)
+	require.Equal(t, FailureReasonCancelled, e.(ContractRefundedEvent).FailureReason())
+	require.Equal(t, "oops", e.(ContractRefundedEvent).Error())
+}
+
+func TestUnmarshalEventChecksResults(t *testing.T) {
+	_, err := UnmarshalEvent([]byte(`{"version":1,"state":"Completed","result":"not-a-cid"}`))
+	require.ErrorContains(t, err, "not-a-cid")
+
+	e, err := UnmarshalEvent([]byte(`{"version":1,"state":"Completed","result":"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"}`))
+	require.NoError(t, err)
+	require.True(t, e.(BacalhauJobCompletedEvent).Result(context.Background()).Defined())
+
+	// Events changed after they were loaded don't bring the bridge down.
+	e.(*event).jobResult = "not-a-cid"
+	require.False(t, e.(BacalhauJobCompletedEvent).Result(context.Background()).Defined())
+}
+
+func TestEventSchemaIsValidJSON(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(EventSchema, &schema))
+}
@@ -0,0 +1,824 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ListenAndServe serves the passed handler on the passed address until the
+// context is cancelled, at which point the server is gracefully shut down.
+func ListenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Ctx(ctx).Info().Str("addr", addr).Msg("Serving HTTP")
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// A HealthChecker is a part of the bridge that can report whether it is
+// currently able to do its job.
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// HealthHandler returns a handler that responds 200 if all of the passed
+// checkers are healthy and 503 with the reasons if any are not.
+func HealthHandler(checkers ...HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var problems []error
+		for _, checker := range checkers {
+			if err := checker.Health(r.Context()); err != nil {
+				problems = append(problems, err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(problems) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, problem := range problems {
+				fmt.Fprintln(w, problem.Error())
+			}
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// The path under which LogsHandler expects to be served.
+const JobLogsPath = "/admin/jobs/"
+
+// LogsHandler returns a handler that responds to GET /admin/jobs/<id>/logs with
+// the output of the job as JSON.
+func LogsHandler(logger JobLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, JobLogsPath)
+		jobID := strings.TrimSuffix(path, "/logs")
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		} else if jobID == path || jobID == "" || strings.Contains(jobID, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		logs, err := logger.Logs(r.Context(), jobID)
+		if errors.Is(err, ErrJobNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("job", jobID).Msg("Unable to fetch job logs")
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(logs)
+	})
+}
+
+// The path under which DeliveriesHandler expects to be served.
+const WebhookDeliveriesPath = "/admin/deliveries/"
+
+// DeliveriesHandler returns a handler that responds to GET
+// /admin/deliveries/<order id> with the webhook deliveries made for the order
+// as JSON.
+func DeliveriesHandler(store DeliveryStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orderID := strings.TrimPrefix(r.URL.Path, WebhookDeliveriesPath)
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		} else if orderID == "" || strings.Contains(orderID, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		deliveries, err := store.Deliveries(r.Context(), orderID)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("id", orderID).Msg("Unable to retrieve webhook deliveries")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(deliveries)
+	})
+}
+
+// The path under which DeadLettersHandler expects to be served.
+const DeadLettersPath = "/admin/dead-letters/"
+
+// DeadLettersHandler returns a handler for the workflow's dead-letter queue:
+//
+//	GET  /admin/dead-letters/                 lists the orders waiting to be dealt with
+//	GET  /admin/dead-letters/<id>             returns a single dead letter
+//	POST /admin/dead-letters/<id>/requeue     puts the order back into the workflow
+//	POST /admin/dead-letters/<id>/reject      gives up on the order for good
+func DeadLettersHandler(workflow *Workflow) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orderID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, DeadLettersPath), "/")
+
+		var result any
+		var err error
+		switch {
+		case workflow.DeadLetters == nil:
+			err = ErrDeadLetterNotFound
+		case r.Method == http.MethodGet && orderID == "" && action == "":
+			result, err = workflow.DeadLetters.DeadLetters(r.Context(), DeadLetterStatusQueued)
+		case r.Method == http.MethodGet && orderID != "" && action == "":
+			result, err = workflow.DeadLetters.DeadLetter(r.Context(), orderID)
+		case r.Method == http.MethodPost && orderID != "" && action == "requeue":
+			err = workflow.Requeue(r.Context(), orderID)
+		case r.Method == http.MethodPost && orderID != "" && action == "reject":
+			err = workflow.Reject(r.Context(), orderID)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if errors.Is(err, ErrDeadLetterResolved) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("id", orderID).Msg("Unable to handle dead letter request")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// The path under which ReloadHandler expects to be served.
+const ReloadPath = "/admin/reload"
+
+// ReloadHandler returns a handler that responds to POST /admin/reload by
+// calling the passed function to reload the config of the bridge, and responds
+// with the error if the config couldn't be reloaded.
+func ReloadHandler(reload func(context.Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := reload(r.Context()); err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Unable to reload config")
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// The path at which MaintenanceHandler expects to be served.
+const MaintenancePath = "/admin/maintenance"
+
+// MaintenanceHandler returns a handler for the workflow's maintenance mode:
+//
+//	GET    /admin/maintenance    returns whether the bridge is in maintenance mode
+//	POST   /admin/maintenance    enters maintenance mode, holding new submissions
+//	DELETE /admin/maintenance    leaves maintenance mode
+func MaintenanceHandler(workflow *Workflow) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			workflow.SetMaintenance(r.Context(), true)
+		case http.MethodDelete:
+			workflow.SetMaintenance(r.Context(), false)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(workflow.Maintenance())
+	})
+}
+
+// The path under which OrdersHandler expects to be served.
+const OrdersPath = "/orders"
+
+// The most orders that can be asked for at once.
+const maxOrdersLimit = 1000
+
+// OrdersHandler returns a handler that responds to GET /orders with the most
+// recently changed orders as JSON, optionally filtered by ?state= and paged by
+// ?limit= and ?offset=, and to GET /orders/<id> with a single order and its
+// history. If a workflow is passed, POST /orders/<id>/cancel cancels the
+// running job for the order and GET /orders/<id>/progress returns how far it
+// has got.
+func OrdersHandler(store OrderStore, workflow *Workflow) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, action, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, OrdersPath), "/"), "/")
+		if r.Method == http.MethodPost && workflow != nil && id != "" && action == "cancel" {
+			cancelOrder(w, r, workflow, id)
+			return
+		} else if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		} else if workflow != nil && id != "" && action == "progress" {
+			orderProgress(w, r, workflow, id)
+			return
+		} else if action != "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var result any
+		var err error
+		if id != "" {
+			orderID, ok := parseOrderID(id)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			result, err = store.Order(r.Context(), orderID)
+		} else {
+			filter, filterErr := orderFilter(r.URL.Query())
+			if filterErr != nil {
+				http.Error(w, filterErr.Error(), http.StatusBadRequest)
+				return
+			}
+			result, err = store.Orders(r.Context(), filter)
+		}
+
+		if errors.Is(err, ErrOrderNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Unable to retrieve orders")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+func cancelOrder(w http.ResponseWriter, r *http.Request, workflow *Workflow, id string) {
+	orderID, ok := parseOrderID(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	err := workflow.CancelOrder(r.Context(), orderID)
+	if errors.Is(err, ErrOrderNotRunning) || errors.Is(err, ErrPartitionNotOwned) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("id", id).Msg("Unable to cancel order")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func orderProgress(w http.ResponseWriter, r *http.Request, workflow *Workflow, id string) {
+	orderID, ok := parseOrderID(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	progress, running := workflow.Progress(orderID)
+	if !running {
+		http.Error(w, ErrOrderNotRunning.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(progress)
+}
+
+func orderFilter(query url.Values) (OrderFilter, error) {
+	filter := OrderFilter{Limit: 100}
+
+	if str := query.Get("state"); str != "" {
+		state, err := ParseOrderState(str)
+		if err != nil {
+			return filter, err
+		}
+		filter.State = &state
+	}
+
+	for name, value := range map[string]*uint{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if str := query.Get(name); str != "" {
+			parsed, err := strconv.ParseUint(str, 10, 32)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+			*value = uint(parsed)
+		}
+	}
+
+	if filter.Limit == 0 || filter.Limit > maxOrdersLimit {
+		return filter, fmt.Errorf("limit must be between 1 and %d", maxOrdersLimit)
+	}
+	return filter, nil
+}
+
+// The path at which GasSpendHandler expects to be served.
+const GasSpendPath = "/admin/gas"
+
+// GasSpendHandler returns a handler that responds to GET /admin/gas with what
+// the bridge's transactions have cost today, and the daily budget, as JSON.
+func GasSpendHandler(budget *GasBudget) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		spend, err := budget.Today(r.Context())
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Unable to retrieve gas spend")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(spend)
+	})
+}
+
+// The path at which AuditHandler expects to be served.
+const AuditPath = "/admin/audit"
+
+// AuditHandler returns a handler that responds to GET /admin/audit with the
+// entries of the audit log as JSON, oldest first. Entries can be filtered by
+// ?order=<id>, ?action=<action>, and ?since= and ?until= in RFC 3339, and are
+// paged by ?limit= and ?offset=.
+func AuditHandler(audit AuditLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filter, err := auditFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries, err := audit.AuditEntries(r.Context(), filter)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Unable to retrieve audit log")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(entries)
+	})
+}
+
+func auditFilter(query url.Values) (AuditFilter, error) {
+	filter := AuditFilter{Limit: 100}
+
+	if str := query.Get("order"); str != "" {
+		orderID, ok := parseOrderID(str)
+		if !ok {
+			return filter, fmt.Errorf("order: %q is not an order ID", str)
+		}
+		filter.OrderID = orderID.Hex()
+	}
+
+	if str := query.Get("action"); str != "" {
+		action, err := ParseAuditAction(str)
+		if err != nil {
+			return filter, err
+		}
+		filter.Action = &action
+	}
+
+	for name, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if str := query.Get(name); str != "" {
+			parsed, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+			*value = parsed
+		}
+	}
+
+	for name, value := range map[string]*uint{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if str := query.Get(name); str != "" {
+			parsed, err := strconv.ParseUint(str, 10, 32)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+			*value = uint(parsed)
+		}
+	}
+
+	if filter.Limit == 0 || filter.Limit > maxOrdersLimit {
+		return filter, fmt.Errorf("limit must be between 1 and %d", maxOrdersLimit)
+	}
+	return filter, nil
+}
+
+// The path under which MediationsHandler expects to be served.
+const MediationsPath = "/admin/mediations/"
+
+// MediationsHandler returns a handler for the mediator:
+//
+//	GET  /admin/mediations/?state=<state>   lists the mediations, optionally only those in a state
+//	GET  /admin/mediations/<id>             returns the mediation of a single order
+//	POST /admin/mediations/<id>             disputes a finished order, sending it for mediation
+//
+// A dispute may have a JSON body giving the reason, as {"reason": "..."}.
+func MediationsHandler(mediator *Mediator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, MediationsPath)
+		if strings.Contains(id, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		var result any
+		var err error
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			all := MediationStates()
+			states := all[:]
+			if str := r.URL.Query().Get("state"); str != "" {
+				state, parseErr := ParseMediationState(str)
+				if parseErr != nil {
+					http.Error(w, parseErr.Error(), http.StatusBadRequest)
+					return
+				}
+				states = []MediationState{state}
+			}
+			result, err = listMediations(r.Context(), mediator.Store, states)
+		case r.Method == http.MethodGet:
+			result, err = mediator.Store.Mediation(r.Context(), id)
+		case r.Method == http.MethodPost && id != "":
+			orderID, ok := parseOrderID(id)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			if r.ContentLength != 0 {
+				if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil {
+					http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if body.Reason == "" {
+				body.Reason = "disputed by operator"
+			}
+			err = mediator.Dispute(r.Context(), orderID, body.Reason)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if errors.Is(err, ErrMediationNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if errors.Is(err, ErrMediationExists) || errors.Is(err, ErrOrderNotFinished) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("id", id).Msg("Unable to handle mediation request")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// listMediations returns every mediation in the passed states.
+func listMediations(ctx context.Context, store MediationStore, states []MediationState) ([]Mediation, error) {
+	mediations := make([]Mediation, 0)
+	for _, state := range states {
+		inState, err := store.Mediations(ctx, state)
+		if err != nil {
+			return nil, err
+		}
+		mediations = append(mediations, inState...)
+	}
+	return mediations, nil
+}
+
+// The path under which PinsHandler expects to be served.
+const PinsPath = "/admin/pins/"
+
+// PinsHandler returns a handler for the pinning of results:
+//
+//	GET  /admin/pins/?status=<status>   lists the pins, optionally only those with a status
+//	GET  /admin/pins/<cid>              returns the pin of a single result
+//	POST /admin/pins/<cid>              tries again to pin a result that failed to be pinned
+func PinsHandler(pins *PinManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cid := strings.TrimPrefix(r.URL.Path, PinsPath)
+		if strings.Contains(cid, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		var result any
+		var err error
+		switch {
+		case r.Method == http.MethodGet && cid == "":
+			all := PinStatuses()
+			statuses := all[:]
+			if str := r.URL.Query().Get("status"); str != "" {
+				status, parseErr := ParsePinStatus(str)
+				if parseErr != nil {
+					http.Error(w, parseErr.Error(), http.StatusBadRequest)
+					return
+				}
+				statuses = []PinStatus{status}
+			}
+			result, err = listPins(r.Context(), pins.Store, statuses)
+		case r.Method == http.MethodGet:
+			result, err = pins.Store.Pin(r.Context(), cid)
+		case r.Method == http.MethodPost && cid != "":
+			err = pins.Retry(r.Context(), cid)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if errors.Is(err, ErrPinNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if errors.Is(err, ErrPinNotFailed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("cid", cid).Msg("Unable to handle pin request")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// listPins returns every pin with the passed statuses.
+func listPins(ctx context.Context, store PinStore, statuses []PinStatus) ([]Pin, error) {
+	pins := make([]Pin, 0)
+	for _, status := range statuses {
+		withStatus, err := store.Pins(ctx, status)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, withStatus...)
+	}
+	return pins, nil
+}
+
+// The path under which TemplatesHandler expects to be served.
+const TemplatesPath = "/admin/templates"
+
+// TemplatesHandler returns a handler that responds to GET /admin/templates with
+// every version of the job templates that orders can ask for, and the
+// parameters each takes.
+func TemplatesHandler(templates TemplateSource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(templates.Templates())
+	})
+}
+
+// The path under which EstimateHandler expects to be served.
+const EstimatePath = "/estimate"
+
+// The largest spec that EstimateHandler reads.
+const maxEstimateSpecSize = 1 << 20
+
+// EstimateHandler returns a handler that responds to POST /estimate, with the
+// spec of an order as the body, with the resources the job is expected to use
+// and what they will cost. If ?price= is passed, in the chain's native token,
+// the response also says whether it covers the job.
+func EstimateHandler(estimator *Estimator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var price *float64
+		if str := r.URL.Query().Get("price"); str != "" {
+			parsed, err := strconv.ParseFloat(str, 64)
+			if err != nil || parsed < 0 {
+				http.Error(w, "price must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+			price = &parsed
+		}
+
+		spec, err := io.ReadAll(io.LimitReader(r.Body, maxEstimateSpecSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		estimate, err := estimator.EstimateOrder(spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result := struct {
+			Estimate
+			Covered *bool `json:"covered,omitempty"`
+		}{Estimate: estimate}
+		if price != nil {
+			covered := estimate.Covers(*price)
+			result.Covered = &covered
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// The path at which SLAHandler expects to be served.
+const SLAPath = "/admin/sla"
+
+// SLAHandler returns a handler that responds to GET /admin/sla with a report
+// of the end-to-end latency of orders over each rolling window, as JSON or as
+// CSV if ?format=csv is passed.
+func SLAHandler(tracker *SLATracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			http.Error(w, "format must be json or csv", http.StatusBadRequest)
+			return
+		}
+
+		report, err := tracker.Report(r.Context())
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Unable to report on SLA")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			_ = report.WriteCSV(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// The path at which AccountingHandler expects to be served.
+const AccountingPath = "/admin/accounting"
+
+// How far back accounting reports go unless they are asked to go further.
+const defaultAccountingPeriod = 30 * 24 * time.Hour
+
+// AccountingHandler returns a handler that responds to GET /admin/accounting
+// with what the orders settled in the last 30 days, or since ?since= in RFC
+// 3339, paid in each token, valued by the oracle if it isn't nil, as JSON.
+func AccountingHandler(store PaymentStore, oracle PriceOracle) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		since := time.Now().Add(-defaultAccountingPeriod)
+		if str := r.URL.Query().Get("since"); str != "" {
+			parsed, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("since: %s", err), http.StatusBadRequest)
+				return
+			}
+			since = parsed
+		}
+
+		report, err := Accounting(r.Context(), store, oracle, since)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Unable to report on payments")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// The path under which AddressesHandler expects to be served.
+const AddressesPath = "/admin/addresses/"
+
+// AddressesHandler returns a handler for the addresses the bridge takes orders
+// from:
+//
+//	GET    /admin/addresses/                     lists the blocked and allowed addresses
+//	PUT    /admin/addresses/blocked/<address>    blocks orders from an address
+//	DELETE /admin/addresses/blocked/<address>    unblocks an address
+//	PUT    /admin/addresses/allowed/<address>    allows orders from an address
+//	DELETE /admin/addresses/allowed/<address>    takes an address off the allowed list
+//
+// Changes last until the bridge is restarted.
+func AddressesHandler(list *AddressList) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, AddressesPath)
+		if path == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(list.Lists())
+			return
+		}
+
+		kind, hex, found := strings.Cut(path, "/")
+		if !found || (kind != "blocked" && kind != "allowed") || strings.Contains(hex, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		addresses, err := ParseAddresses([]string{hex})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var add bool
+		switch r.Method {
+		case http.MethodPut:
+			add = true
+		case http.MethodDelete:
+			add = false
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if kind == "blocked" {
+			list.Block(addresses[0], add)
+		} else {
+			list.Allow(addresses[0], add)
+		}
+		log.Ctx(r.Context()).Info().Str("list", kind).Bool("added", add).Stringer("address", addresses[0]).Msg("Changed address list")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
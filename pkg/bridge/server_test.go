@@ -0,0 +1,159 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+type staticLogger map[string][]ExecutionLogs
+
+func (l staticLogger) Logs(ctx context.Context, jobID string) ([]ExecutionLogs, error) {
+	logs, found := l[jobID]
+	if !found {
+		return nil, ErrJobNotFound
+	}
+	return logs, nil
+}
+
+func TestLogsHandler(t *testing.T) {
+	expected := []ExecutionLogs{{NodeID: "QmNode", State: "Failed", Stderr: "oops", ExitCode: 1}}
+	handler := LogsHandler(staticLogger{"job-1": expected})
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/jobs/job-1/logs", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var logs []ExecutionLogs
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&logs))
+	require.Equal(t, expected, logs)
+
+	for _, path := range []string{"/admin/jobs/job-2/logs", "/admin/jobs/job-1", "/admin/jobs//logs"} {
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusNotFound, res.Code, path)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	runner := testEndpoints(t, EndpointSelectionPriority, "http://a:1")
+
+	res := httptest.NewRecorder()
+	HealthHandler(runner).ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+
+	_ = runner.endpoints[0].breaker.Do(func() error { return context.DeadlineExceeded })
+	res = httptest.NewRecorder()
+	HealthHandler(runner).ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, res.Code)
+}
+
+func TestDeadLettersHandler(t *testing.T) {
+	repo := repository(t)
+	w := NewWorkflow(nil, nil, repo, WithDeadLetterQueue(repo.(DeadLetterQueue)))
+	handler := DeadLettersHandler(w)
+
+	e := walEvent(1).Failed("no funds")
+	data, err := MarshalEvent(e)
+	require.NoError(t, err)
+	orderID := e.OrderId().Hex()
+	require.NoError(t, w.DeadLetters.AddDeadLetter(context.Background(), DeadLetter{
+		OrderID: orderID,
+		State:   e.OrderState().String(),
+		Error:   "no funds",
+		Event:   data,
+	}))
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(method, DeadLettersPath+path, nil))
+		return res
+	}
+
+	res := request(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, res.Code)
+	var deadLetters []DeadLetter
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&deadLetters))
+	require.Len(t, deadLetters, 1)
+	require.Equal(t, orderID, deadLetters[0].OrderID)
+
+	require.Equal(t, http.StatusOK, request(http.MethodGet, orderID).Code)
+	require.Equal(t, http.StatusNotFound, request(http.MethodGet, "0x1234").Code)
+	require.Equal(t, http.StatusNotFound, request(http.MethodPost, orderID+"/explode").Code)
+
+	require.Equal(t, http.StatusNoContent, request(http.MethodPost, orderID+"/reject").Code)
+	require.Equal(t, http.StatusConflict, request(http.MethodPost, orderID+"/requeue").Code)
+
+	res = request(http.MethodGet, "")
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&deadLetters))
+	require.Empty(t, deadLetters)
+}
+
+func TestOrdersHandler(t *testing.T) {
+	repo := repository(t)
+	e := walEvent(1)
+	require.NoError(t, repo.Save(e))
+	handler := OrdersHandler(repo.(OrderStore), nil)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		return res
+	}
+
+	res := request("/orders?state=submitted")
+	require.Equal(t, http.StatusOK, res.Code)
+	var orders []Order
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&orders))
+	require.Len(t, orders, 1)
+
+	res = request("/orders/" + e.OrderId().Hex())
+	require.Equal(t, http.StatusOK, res.Code)
+	var order Order
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&order))
+	require.Equal(t, "Submitted", order.State)
+	require.Len(t, order.Transitions, 1)
+
+	require.Equal(t, http.StatusNotFound, request("/orders/"+walEvent(2).OrderId().Hex()).Code)
+	require.Equal(t, http.StatusNotFound, request("/orders/not-an-id").Code)
+	require.Equal(t, http.StatusBadRequest, request("/orders?state=Finished").Code)
+	require.Equal(t, http.StatusBadRequest, request("/orders?limit=0").Code)
+}
+
+func TestOrdersHandlerCancel(t *testing.T) {
+	repo := repository(t)
+	cancelled := ""
+	runner := &mockRunner{CancelHandler: func(ctx context.Context, e BacalhauJobRunningEvent) error {
+		cancelled = e.JobID()
+		return nil
+	}}
+	workflow := NewWorkflow(runner, mockContract{}, repo)
+	handler := OrdersHandler(repo.(OrderStore), workflow)
+
+	job := model.NewJob()
+	job.Metadata.ID = "running-job"
+	e := walEvent(1).JobCreated(job)
+	require.NoError(t, repo.Save(e))
+
+	cancel := func(id string) int {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, OrdersPath+"/"+id+"/cancel", nil))
+		return res.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, cancel(e.OrderId().Hex()))
+	require.Equal(t, "running-job", cancelled)
+	require.Equal(t, OrderStateFailed, (<-workflow.injected).OrderState())
+
+	require.Equal(t, http.StatusConflict, cancel(e.OrderId().Hex()))
+	require.Equal(t, http.StatusNotFound, cancel("not-an-id"))
+
+	res := httptest.NewRecorder()
+	OrdersHandler(repo.(OrderStore), nil).ServeHTTP(res, httptest.NewRequest(http.MethodPost, OrdersPath+"/"+e.OrderId().Hex()+"/cancel", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, res.Code)
+}
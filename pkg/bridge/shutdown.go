@@ -0,0 +1,51 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// detachedContext carries the values of its parent, such as the logger and the
+// current span, but is never cancelled when its parent is.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (ctx detachedContext) Value(key any) any {
+	return ctx.parent.Value(key)
+}
+
+// drainContext returns a context for processing events that is only cancelled
+// once the shutdown grace period has passed since the passed context was
+// cancelled, so that work in progress when the workflow is stopped has a
+// chance to finish rather than being abandoned mid-transition.
+func (workflow *Workflow) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	workCtx, cancel := context.WithCancel(detachedContext{parent: ctx})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-workCtx.Done():
+			return
+		}
+
+		log.Ctx(ctx).Info().Dur("grace", workflow.shutdownGracePeriod).Msg("Draining in-flight events")
+		timer := time.NewTimer(workflow.shutdownGracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			log.Ctx(ctx).Warn().Msg("Shutdown grace period has passed, cancelling in-flight events")
+			cancel()
+		case <-workCtx.Done():
+		}
+	}()
+
+	return workCtx, cancel
+}
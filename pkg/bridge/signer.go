@@ -0,0 +1,209 @@
+package bridge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A Signer signs the bridge's transactions, so that the key of the account
+// that pays for them can be kept somewhere safer than the config.
+type Signer interface {
+	// Address returns the account that transactions are sent from.
+	Address() common.Address
+
+	// SignTransaction signs the passed transaction for the passed chain.
+	SignTransaction(ctx context.Context, txn *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// A HashSigner is a Signer that can also sign an arbitrary 32 byte hash, such as
+// the EIP-712 hash of a meta-transaction.
+type HashSigner interface {
+	Signer
+
+	// SignHash returns the 65 byte signature of the hash, ending with a V of
+	// 27 or 28 as contracts expect.
+	SignHash(ctx context.Context, hash []byte) ([]byte, error)
+}
+
+const (
+	SignerKey      = "key"
+	SignerKeystore = "keystore"
+	SignerAWSKMS   = "awskms"
+	SignerGCPKMS   = "gcpkms"
+	SignerVault    = "vault"
+	SignerLedger   = "ledger"
+)
+
+// SignerNames returns the signing backends that can be configured.
+func SignerNames() []string {
+	return []string{SignerKey, SignerKeystore, SignerAWSKMS, SignerGCPKMS, SignerVault, SignerLedger}
+}
+
+// NewSigner returns the signer chosen by the passed config.
+func NewSigner(ctx context.Context, config Config) (Signer, error) {
+	settings := config.Signer
+	switch settings.Backend {
+	case SignerKey:
+		key, err := crypto.HexToECDSA(config.Chain.WalletPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("WALLET_PRIVATE_KEY: %w", err)
+		}
+		return NewPrivateKeySigner(key), nil
+	case SignerKeystore:
+		return NewKeystoreSigner(settings.KeystoreFile, settings.KeystorePassword)
+	case SignerAWSKMS:
+		return NewAWSKMSSigner(ctx, settings.KMSKey, settings.AWSRegion)
+	case SignerGCPKMS:
+		return NewGCPKMSSigner(ctx, settings.KMSKey)
+	case SignerVault:
+		return NewVaultSigner(ctx, settings.VaultAddress, settings.VaultToken, settings.VaultPath, settings.VaultField)
+	case SignerLedger:
+		return NewLedgerSigner(ctx, settings.LedgerPath, settings.LedgerTimeout)
+	default:
+		return nil, fmt.Errorf("unknown signer %q", settings.Backend)
+	}
+}
+
+type privateKeySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewPrivateKeySigner returns a Signer that signs with a private key held in
+// memory.
+func NewPrivateKeySigner(key *ecdsa.PrivateKey) Signer {
+	return privateKeySigner{key: key}
+}
+
+// Address implements Signer
+func (s privateKeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+// SignTransaction implements Signer
+func (s privateKeySigner) SignTransaction(ctx context.Context, txn *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(txn, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// SignHash implements HashSigner
+func (s privateKeySigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	signature, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// NewKeystoreSigner returns a Signer that signs with the key in the passed
+// encrypted keystore file, as written by geth and most wallets.
+func NewKeystoreSigner(path, password string) (Signer, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(contents, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewPrivateKeySigner(key.PrivateKey), nil
+}
+
+// A digestSigner signs a 32 byte digest with a key held elsewhere, returning
+// an ASN.1 DER encoded ECDSA signature, as key management services do.
+type digestSigner func(ctx context.Context, digest []byte) ([]byte, error)
+
+type remoteSigner struct {
+	address common.Address
+	sign    digestSigner
+}
+
+// Address implements Signer
+func (s remoteSigner) Address() common.Address {
+	return s.address
+}
+
+// SignTransaction implements Signer
+func (s remoteSigner) SignTransaction(ctx context.Context, txn *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	hash := signer.Hash(txn)
+
+	der, err := s.sign(ctx, hash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	signature, err := ethereumSignature(hash.Bytes(), der, s.address)
+	if err != nil {
+		return nil, err
+	}
+	return txn.WithSignature(signer, signature)
+}
+
+// SignHash implements HashSigner
+func (s remoteSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	der, err := s.sign(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := ethereumSignature(hash, der, s.address)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+var secp256k1HalfN = new(big.Int).Rsh(crypto.S256().Params().N, 1)
+
+// ethereumSignature turns a DER encoded ECDSA signature of the passed digest
+// into the 65 byte form used by Ethereum, which has a low S value and ends with
+// the recovery ID that gives the passed address.
+func ethereumSignature(digest, der []byte, address common.Address) ([]byte, error) {
+	var parsed struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	if parsed.S.Cmp(secp256k1HalfN) > 0 {
+		parsed.S = new(big.Int).Sub(crypto.S256().Params().N, parsed.S)
+	}
+
+	signature := make([]byte, crypto.SignatureLength)
+	parsed.R.FillBytes(signature[0:32])
+	parsed.S.FillBytes(signature[32:64])
+	for v := byte(0); v < 2; v++ {
+		signature[64] = v
+		key, err := crypto.SigToPub(digest, signature)
+		if err == nil && crypto.PubkeyToAddress(*key) == address {
+			return signature, nil
+		}
+	}
+	return nil, fmt.Errorf("signature is not from %s", address)
+}
+
+// parsePublicKey returns the secp256k1 public key in a DER encoded
+// SubjectPublicKeyInfo, which the standard library can't parse as it doesn't
+// support the curve.
+func parsePublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return crypto.UnmarshalPubkey(info.PublicKey.Bytes)
+}
+
+var _ HashSigner = privateKeySigner{}
+var _ HashSigner = remoteSigner{}
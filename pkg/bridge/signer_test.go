@@ -0,0 +1,247 @@
+package bridge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// requireSignedBy signs a transaction with the signer and checks that it was
+// signed by the passed key.
+func requireSignedBy(t *testing.T, signer Signer, key *ecdsa.PrivateKey) {
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	require.Equal(t, address, signer.Address())
+
+	chainID := big.NewInt(31337)
+	txn := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 1, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 21000})
+	signed, err := signer.SignTransaction(context.Background(), txn, chainID)
+	require.NoError(t, err)
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	require.NoError(t, err)
+	require.Equal(t, address, sender)
+}
+
+// signDigest signs the digest with the key, as a key management service would.
+func signDigest(t *testing.T, key *ecdsa.PrivateKey, digest []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	require.NoError(t, err)
+	der, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	require.NoError(t, err)
+	return der
+}
+
+// publicKeyDER encodes the public key of the key as a SubjectPublicKeyInfo.
+func publicKeyDER(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}},
+		PublicKey: asn1.BitString{Bytes: crypto.FromECDSAPub(&key.PublicKey), BitLength: 65 * 8},
+	})
+	require.NoError(t, err)
+	return der
+}
+
+func TestPrivateKeySigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	requireSignedBy(t, NewPrivateKeySigner(key), key)
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	encrypted, err := keystore.EncryptKey(&keystore.Key{
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, "password", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	require.NoError(t, os.WriteFile(path, encrypted, 0600))
+
+	signer, err := NewKeystoreSigner(path, "password")
+	require.NoError(t, err)
+	requireSignedBy(t, signer, key)
+
+	_, err = NewKeystoreSigner(path, "wrong")
+	require.Error(t, err)
+}
+
+func TestRemoteSignatures(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	// Signatures from key management services may have either S value, and
+	// only the low one is valid on Ethereum.
+	for i := 0; i < 10; i++ {
+		signer := remoteSigner{
+			address: crypto.PubkeyToAddress(key.PublicKey),
+			sign: func(ctx context.Context, digest []byte) ([]byte, error) {
+				return signDigest(t, key, digest), nil
+			},
+		}
+		requireSignedBy(t, signer, key)
+	}
+}
+
+func TestAWSKMSSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"))
+
+		var request struct {
+			KeyId   string
+			Message []byte
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		require.Equal(t, "alias/lilypad", request.KeyId)
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			_ = json.NewEncoder(w).Encode(map[string]any{"PublicKey": publicKeyDER(t, key)})
+		case "TrentService.Sign":
+			_ = json.NewEncoder(w).Encode(map[string]any{"Signature": signDigest(t, key, request.Message)})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	signer, err := newAWSKMSSigner(context.Background(), server.Client(), server.URL, "alias/lilypad", "eu-west-1", awsCredentials{
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	requireSignedBy(t, signer, key)
+}
+
+func TestAWSRequestSignature(t *testing.T) {
+	// The example from the AWS Signature Version 4 documentation.
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signAWSRequest(req, nil, "us-east-1", "iam", awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, now)
+	require.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7", req.Header.Get("Authorization"))
+}
+
+func TestGCPKMSSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	name := "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/" + name + "/publicKey":
+			block := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER(t, key)})
+			_ = json.NewEncoder(w).Encode(map[string]any{"pem": string(block)})
+		case "/" + name + ":asymmetricSign":
+			var request struct{ Digest struct{ SHA256 []byte } }
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			_ = json.NewEncoder(w).Encode(map[string]any{"signature": signDigest(t, key, request.Digest.SHA256)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	token := func(context.Context, *http.Client) (string, error) { return "token", nil }
+	signer, err := newGCPKMSSigner(context.Background(), server.Client(), server.URL+"/", name, token)
+	require.NoError(t, err)
+	requireSignedBy(t, signer, key)
+}
+
+func TestVaultSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" || r.URL.Path != "/v1/secret/data/lilypad" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		secret := map[string]any{"privateKey": hex.EncodeToString(crypto.FromECDSA(key))}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": secret}})
+	}))
+	defer server.Close()
+
+	signer, err := newVaultSigner(context.Background(), server.Client(), server.URL, "token", "secret/data/lilypad", "privateKey")
+	require.NoError(t, err)
+	requireSignedBy(t, signer, key)
+
+	_, err = newVaultSigner(context.Background(), server.Client(), server.URL, "wrong", "secret/data/lilypad", "privateKey")
+	require.Error(t, err)
+}
+
+// fakeLedger stands in for a Ledger, confirming or rejecting transactions
+// once something is sent on confirm.
+type fakeLedger struct {
+	accounts.Wallet
+	key     *ecdsa.PrivateKey
+	confirm chan bool
+}
+
+func (l fakeLedger) SignTx(account accounts.Account, txn *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if !<-l.confirm {
+		return nil, errors.New("reply lacks signature")
+	}
+	return types.SignTx(txn, types.LatestSignerForChainID(chainID), l.key)
+}
+
+func TestLedgerSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	ledger := fakeLedger{key: key, confirm: make(chan bool, 1)}
+	account := accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+	signer := newLedgerSigner(ledger, account, 50*time.Millisecond)
+
+	ledger.confirm <- true
+	requireSignedBy(t, signer, key)
+
+	chainID := big.NewInt(31337)
+	txn := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 2, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 21000})
+	ledger.confirm <- false
+	_, err = signer.SignTransaction(context.Background(), txn, chainID)
+	require.ErrorIs(t, err, ErrLedgerRejected)
+
+	_, err = signer.SignTransaction(context.Background(), txn, chainID)
+	require.ErrorIs(t, err, ErrLedgerTimeout)
+	_, err = signer.SignTransaction(context.Background(), txn, chainID)
+	require.ErrorIs(t, err, ErrLedgerTimeout, "an unanswered prompt should hold the device")
+
+	ledger.confirm <- true // answers the prompt that timed out
+	ledger.confirm <- true
+	requireSignedBy(t, signer, key)
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrWouldRevert is matched by the error returned when a transaction isn't
+// sent because simulating it showed that the contract would revert it, such as
+// when the order has already been resolved on chain by mediation. It is the
+// same kind of error as a transaction that was sent and then reverted.
+var ErrWouldRevert = bridgeerrors.ErrTxReverted
+
+// errSimulated stops a contract binding from sending the transaction it has
+// simulated.
+var errSimulated = errors.New("transaction was only simulated")
+
+// simulate runs the call made by the passed function against the latest block
+// without sending it, returning a *bridgeerrors.RevertError if the
+// contract would revert it. Any other error means the simulation couldn't be
+// run, and is returned as it is.
+func (r *realContract) simulate(ctx context.Context, send func(*bind.TransactOpts) (*types.Transaction, error)) error {
+	// The binding simulates the call when it estimates its gas, so stop it
+	// once it comes to sign. A zero gas price means the wallet's balance
+	// doesn't matter, and setting the nonce saves asking the chain.
+	opts := &bind.TransactOpts{
+		From:     r.wallet(),
+		Nonce:    new(big.Int),
+		Value:    new(big.Int),
+		GasPrice: new(big.Int),
+		Context:  ctx,
+		Signer: func(common.Address, *types.Transaction) (*types.Transaction, error) {
+			return nil, errSimulated
+		},
+	}
+
+	_, err := send(opts)
+	switch {
+	case errors.Is(err, errSimulated):
+		return nil
+	case err == nil:
+		return errors.New("binding sent the transaction instead of simulating it")
+	case isRevert(err):
+		transactionsNotSent.Inc()
+		return &bridgeerrors.RevertError{Reason: err.Error()}
+	default:
+		return fmt.Errorf("simulating transaction: %w", err)
+	}
+}
+
+// isRevert returns whether the error came from the contract reverting a call.
+func isRevert(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "execution reverted")
+}
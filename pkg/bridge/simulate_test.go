@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersThatWouldRevertAreNotPosted(t *testing.T) {
+	ctx := context.Background()
+	refunds := 0
+	contract := &mockContract{
+		CompleteHandler: func(context.Context, BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+			return nil, &bridgeerrors.RevertError{Reason: "execution reverted: job already resolved"}
+		},
+		RefundHandler: func(_ context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+			refunds++
+			return e.Refunded(), nil
+		},
+	}
+	workflow := NewWorkflow(&mockRunner{CreateHandler: SuccessfulCreate}, contract, repository(t))
+
+	completed := walEvent(0x01)
+	completed.JobCreated(model.NewJob()).Completed(cid.Cid{}, "out", "", 0)
+	failed, _ := workflow.ProcessEvent(ctx, completed)
+	require.Equal(t, OrderStateFailed, failed.OrderState())
+	require.Equal(t, FailureReasonResolved, failed.(ContractFailedEvent).FailureReason())
+
+	result, _ := workflow.ProcessEvent(ctx, failed)
+	require.Nil(t, result, "nothing more should be posted for the order")
+	require.Zero(t, refunds)
+}
+
+func TestRevertsAreRecognised(t *testing.T) {
+	require.True(t, isRevert(errors.New("failed to estimate gas needed: execution reverted: Job already resolved")))
+	require.False(t, isRevert(errors.New("dial tcp: connection refused")))
+}
@@ -0,0 +1,226 @@
+package bridge
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A TimelineStore returns the timelines of orders that have been settled.
+type TimelineStore interface {
+	// Timelines returns the timeline of every order that was paid for or
+	// refunded since the passed time.
+	Timelines(ctx context.Context, since time.Time) ([]Timeline, error)
+}
+
+// The percentiles of latency that SLA reports include.
+var slaPercentiles = []float64{50, 90, 95, 99}
+
+// An SLAWindow summarises how long the orders settled in a window of time
+// took from being seen on the contract to being settled.
+type SLAWindow struct {
+	// How far back the window goes from when the report was made, such as
+	// 24h0m0s.
+	Window string `json:"window"`
+
+	// How many orders were settled in the window.
+	Orders int `json:"orders"`
+
+	// The latency of the orders in seconds at each percentile, by percentile.
+	Percentiles map[string]float64 `json:"percentileSeconds"`
+
+	// The fraction of the orders that were settled within the target, if
+	// there is one.
+	WithinTarget *float64 `json:"withinTarget,omitempty"`
+}
+
+// An SLAReport summarises the end-to-end latency of orders over each of a
+// number of rolling windows.
+type SLAReport struct {
+	Time time.Time `json:"time"`
+
+	// The latency in seconds that orders are meant to be settled within, if
+	// there is a target.
+	Target float64 `json:"targetSeconds,omitempty"`
+
+	Windows []SLAWindow `json:"windows"`
+}
+
+// WriteCSV writes the report with a row for each window.
+func (report SLAReport) WriteCSV(w io.Writer) error {
+	header := []string{"time", "window", "orders"}
+	for _, p := range slaPercentiles {
+		header = append(header, fmt.Sprintf("p%s_seconds", percentileName(p)))
+	}
+	header = append(header, "within_target")
+
+	out := csv.NewWriter(w)
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, window := range report.Windows {
+		row := []string{
+			report.Time.UTC().Format(time.RFC3339),
+			window.Window,
+			strconv.Itoa(window.Orders),
+		}
+		for _, p := range slaPercentiles {
+			row = append(row, strconv.FormatFloat(window.Percentiles[percentileName(p)], 'f', 3, 64))
+		}
+		if window.WithinTarget != nil {
+			row = append(row, strconv.FormatFloat(*window.WithinTarget, 'f', 4, 64))
+		} else {
+			row = append(row, "")
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func percentileName(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// An SLATracker reports on the end-to-end latency of orders, from when they
+// are seen on the contract to when the transaction that settles them is
+// posted, for solution providers that have agreed to a service level.
+type SLATracker struct {
+	store   TimelineStore
+	windows []time.Duration
+	target  time.Duration
+}
+
+// NewSLATracker returns an SLATracker that reports on each of the windows. If
+// the target isn't zero, reports include how many orders were settled within
+// it.
+func NewSLATracker(store TimelineStore, windows []time.Duration, target time.Duration) *SLATracker {
+	windows = append([]time.Duration(nil), windows...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	return &SLATracker{store: store, windows: windows, target: target}
+}
+
+// Report summarises the latency of the orders settled in each window up to
+// now. Orders that weren't seen on the contract or settled by this bridge
+// aren't counted, as their latency isn't known.
+func (tracker *SLATracker) Report(ctx context.Context) (SLAReport, error) {
+	report := SLAReport{Time: now().UTC(), Target: tracker.target.Seconds(), Windows: []SLAWindow{}}
+	if len(tracker.windows) == 0 {
+		return report, nil
+	}
+
+	longest := tracker.windows[len(tracker.windows)-1]
+	timelines, err := tracker.store.Timelines(ctx, report.Time.Add(-longest))
+	if err != nil {
+		return SLAReport{}, err
+	}
+
+	for _, window := range tracker.windows {
+		since := report.Time.Add(-window)
+		latencies := []time.Duration{}
+		for _, timeline := range timelines {
+			if latency, ok := Between(timeline.Observed, timeline.TxPosted); ok && !timeline.TxPosted.Before(since) {
+				latencies = append(latencies, latency)
+			}
+		}
+		report.Windows = append(report.Windows, tracker.summarise(window, latencies))
+	}
+	return report, nil
+}
+
+func (tracker *SLATracker) summarise(window time.Duration, latencies []time.Duration) SLAWindow {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	summary := SLAWindow{Window: window.String(), Orders: len(latencies), Percentiles: map[string]float64{}}
+	for _, p := range slaPercentiles {
+		latency := percentile(latencies, p).Seconds()
+		summary.Percentiles[percentileName(p)] = latency
+		slaLatency.WithLabelValues(window.String(), percentileName(p)).Set(latency)
+	}
+
+	if tracker.target > 0 && len(latencies) > 0 {
+		within := sort.Search(len(latencies), func(i int) bool { return latencies[i] > tracker.target })
+		fraction := float64(within) / float64(len(latencies))
+		summary.WithinTarget = &fraction
+	}
+	return summary
+}
+
+// percentile returns the latency at the percentile of the sorted latencies,
+// using the nearest rank, or zero if there are none.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Run writes a report in JSON and CSV to the directory every interval until
+// the context is cancelled. Each report is written to files named after when
+// it was made.
+func (tracker *SLATracker) Run(ctx context.Context, dir string, interval time.Duration) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		if err := tracker.WriteReport(ctx, dir); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to write SLA report")
+		}
+	}
+}
+
+// WriteReport writes a report to the directory as sla-<time>.json and
+// sla-<time>.csv.
+func (tracker *SLATracker) WriteReport(ctx context.Context, dir string) error {
+	report, err := tracker.Report(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(dir, "sla-"+report.Time.Format("20060102T150405Z"))
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(name+".json", append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	file, err := os.Create(name + ".csv")
+	if err != nil {
+		return err
+	}
+	if err = report.WriteCSV(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,60 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentileUsesNearestRank(t *testing.T) {
+	latencies := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	require.Equal(t, time.Duration(5), percentile(latencies, 50))
+	require.Equal(t, time.Duration(9), percentile(latencies, 90))
+	require.Equal(t, time.Duration(10), percentile(latencies, 99))
+	require.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+func TestSLAReportSummarisesSettledOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := repository(t)
+	result := cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
+	for _, latency := range []time.Duration{time.Minute, 2 * time.Minute, 20 * time.Minute} {
+		e := exampleEvent().JobCreated(model.NewJob()).Completed(result, "", "", 0).PaidIn(common.Hash{0x01})
+		e.(*event).timeline.Observed = e.Timeline().TxPosted.Add(-latency)
+		require.NoError(t, repo.Save(e))
+	}
+	// Orders that weren't seen on the contract, or are still running, aren't
+	// counted.
+	require.NoError(t, repo.Save(exampleEvent().JobCreated(model.NewJob()).Completed(result, "", "", 0).PaidIn(common.Hash{0x02})))
+	require.NoError(t, repo.Save(exampleEvent().JobCreated(model.NewJob())))
+
+	tracker := NewSLATracker(repo.(TimelineStore), []time.Duration{24 * time.Hour, time.Hour}, 5*time.Minute)
+	report, err := tracker.Report(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 300.0, report.Target)
+	require.Len(t, report.Windows, 2)
+
+	window := report.Windows[0]
+	require.Equal(t, "1h0m0s", window.Window)
+	require.Equal(t, 3, window.Orders)
+	require.InDelta(t, 120, window.Percentiles["50"], 1)
+	require.InDelta(t, 1200, window.Percentiles["99"], 1)
+	require.NotNil(t, window.WithinTarget)
+	require.InDelta(t, 2.0/3.0, *window.WithinTarget, 0.001)
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteCSV(&out))
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, []string{"time", "window", "orders", "p50_seconds", "p90_seconds", "p95_seconds", "p99_seconds", "within_target"}, rows[0])
+	require.Equal(t, "3", rows[1][2])
+}
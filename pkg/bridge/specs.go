@@ -1,6 +1,12 @@
 package bridge
 
-import "github.com/bacalhau-project/bacalhau/pkg/model"
+import (
+	"fmt"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ipfs/go-cid"
+)
 
 var fastSpec = model.Spec{
 	Engine:    model.EngineDocker,
@@ -14,3 +20,56 @@ var fastSpec = model.Spec{
 		Concurrency: 1,
 	},
 }
+
+// The function a WASM module is started from if the spec doesn't say.
+const defaultWasmEntryPoint = "_start"
+
+// validateSpec checks that the spec has everything its engine needs to run,
+// filling in defaults where the spec leaves them out. Specs that can't be run
+// return a *bridgeerrors.SpecError.
+func validateSpec(spec *model.Spec) error {
+	if err := validateEngine(spec); err != nil {
+		return &bridgeerrors.SpecError{Err: err}
+	}
+	return nil
+}
+
+// validateEngine checks the part of the spec that belongs to its engine.
+func validateEngine(spec *model.Spec) error {
+	switch spec.Engine {
+	case model.EngineDocker:
+		if spec.Docker.Image == "" {
+			return fmt.Errorf("Docker jobs must specify an image")
+		}
+	case model.EngineWasm:
+		if err := validateWasmModule(&spec.Wasm.EntryModule); err != nil {
+			return fmt.Errorf("WASM entry module: %w", err)
+		}
+		for i := range spec.Wasm.ImportModules {
+			if err := validateWasmModule(&spec.Wasm.ImportModules[i]); err != nil {
+				return fmt.Errorf("WASM import module %d: %w", i, err)
+			}
+		}
+		if spec.Wasm.EntryPoint == "" {
+			spec.Wasm.EntryPoint = defaultWasmEntryPoint
+		}
+	}
+	return nil
+}
+
+// validateWasmModule checks that a WASM module can be retrieved, either from
+// IPFS by CID or by downloading from a URL.
+func validateWasmModule(module *model.StorageSpec) error {
+	switch {
+	case module.URL != "":
+		module.StorageSource = model.StorageSourceURLDownload
+	case module.CID != "":
+		if _, err := cid.Parse(module.CID); err != nil {
+			return fmt.Errorf("invalid CID %q: %w", module.CID, err)
+		}
+		module.StorageSource = model.StorageSourceIPFS
+	default:
+		return fmt.Errorf("a CID or URL is required")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDockerSpec(t *testing.T) {
+	spec := fastSpec
+	require.NoError(t, validateSpec(&spec))
+
+	spec.Docker.Image = ""
+	require.ErrorIs(t, validateSpec(&spec), bridgeerrors.ErrSpecInvalid)
+}
+
+func TestValidateWasmSpec(t *testing.T) {
+	spec := model.Spec{
+		Engine: model.EngineWasm,
+		Wasm: model.JobSpecWasm{
+			EntryModule: model.StorageSpec{CID: "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"},
+			Parameters:  []string{"hello"},
+		},
+	}
+	require.NoError(t, validateSpec(&spec))
+	require.Equal(t, defaultWasmEntryPoint, spec.Wasm.EntryPoint)
+	require.Equal(t, model.StorageSourceIPFS, spec.Wasm.EntryModule.StorageSource)
+	require.Equal(t, []string{"hello"}, spec.Wasm.Parameters)
+
+	spec.Wasm.EntryModule = model.StorageSpec{CID: "not-a-cid"}
+	require.ErrorIs(t, validateSpec(&spec), bridgeerrors.ErrSpecInvalid)
+
+	spec.Wasm.EntryModule = model.StorageSpec{}
+	require.ErrorIs(t, validateSpec(&spec), bridgeerrors.ErrSpecInvalid)
+}
@@ -0,0 +1,5 @@
+INSERT INTO dead_letters
+	(orderId, state, attempts, error, status, deadAt, event)
+    VALUES (:orderId, :state, :attempts, :error, :status, :deadAt, :event)
+    ON CONFLICT (orderId) DO UPDATE SET
+	state = excluded.state, attempts = excluded.attempts, error = excluded.error, status = excluded.status, deadAt = excluded.deadAt, event = excluded.event;
@@ -0,0 +1,5 @@
+INSERT INTO job_cache
+	(specHash, jobId, endpoint, results, stdout, stderr, exitCode, finishedAt)
+    VALUES (:specHash, :jobId, :endpoint, :results, :stdout, :stderr, :exitCode, :finishedAt)
+    ON CONFLICT (specHash) DO UPDATE SET
+	jobId = excluded.jobId, endpoint = excluded.endpoint, results = excluded.results, stdout = excluded.stdout, stderr = excluded.stderr, exitCode = excluded.exitCode, finishedAt = excluded.finishedAt;
@@ -0,0 +1,6 @@
+INSERT INTO partition_leases
+	(partitionId, owner, expiresAt)
+    VALUES (:partitionId, :owner, :expiresAt)
+    ON CONFLICT (partitionId) DO UPDATE
+    SET owner = excluded.owner, expiresAt = excluded.expiresAt
+    WHERE partition_leases.expiresAt <= :now OR partition_leases.owner = excluded.owner;
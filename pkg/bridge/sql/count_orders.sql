@@ -0,0 +1,9 @@
+SELECT latest.state, COUNT(*), COALESCE(MIN(seen.savedAt), '')
+FROM latest_events AS latest
+LEFT JOIN (
+    SELECT orderId, MIN(savedAt) AS savedAt
+    FROM events
+    WHERE savedAt != ''
+    GROUP BY orderId
+) AS seen ON seen.orderId = latest.orderId
+GROUP BY latest.state;
@@ -1,3 +1,3 @@
 INSERT INTO events
-	(orderId, orderOwner, orderNumber, orderResultType, attempts, lastAttempt, state, jobSpec, jobId, jobResult, jobStdout, jobStderr, jobExitcode)
-    VALUES (:orderId, :orderOwner, :orderNumber, :orderResultType, :attempts, :lastAttempt, :state, :jobSpec, :jobId, :jobResult, :jobStdout, :jobStderr, :jobExitcode);
+	(orderId, orderOwner, orderNumber, orderResultType, attempts, lastAttempt, state, jobSpec, jobId, jobResult, jobStdout, jobStderr, jobExitcode, jobResults, resubmissions, jobExecutions, jobEndpoint, failureReason, stateMessage, savedAt, jobOutputHash, encryptionKey, jobEncryptedResult, durableStorage, jobDealId, orderPrice, timeline, orderContract, orderToken)
+    VALUES (:orderId, :orderOwner, :orderNumber, :orderResultType, :attempts, :lastAttempt, :state, :jobSpec, :jobId, :jobResult, :jobStdout, :jobStderr, :jobExitcode, :jobResults, :resubmissions, :jobExecutions, :jobEndpoint, :failureReason, :stateMessage, :savedAt, :jobOutputHash, :encryptionKey, :jobEncryptedResult, :durableStorage, :jobDealId, :orderPrice, :timeline, :orderContract, :orderToken);
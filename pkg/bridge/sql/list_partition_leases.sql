@@ -0,0 +1,3 @@
+SELECT partitionId, owner, expiresAt
+FROM partition_leases
+ORDER BY partitionId;
@@ -0,0 +1,6 @@
+INSERT INTO posting_locks
+	(orderId, owner, expiresAt, posted)
+    VALUES (:orderId, :owner, :expiresAt, FALSE)
+    ON CONFLICT (orderId) DO UPDATE
+    SET owner = excluded.owner, expiresAt = excluded.expiresAt
+    WHERE NOT posting_locks.posted AND (posting_locks.expiresAt <= :now OR posting_locks.owner = excluded.owner);
@@ -0,0 +1,5 @@
+INSERT INTO dead_letters
+	(orderId, state, attempts, error, status, deadAt, event)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (orderId) DO UPDATE SET
+	state = excluded.state, attempts = excluded.attempts, error = excluded.error, status = excluded.status, deadAt = excluded.deadAt, event = excluded.event;
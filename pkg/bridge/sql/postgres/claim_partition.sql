@@ -0,0 +1,6 @@
+INSERT INTO partition_leases
+	(partitionId, owner, expiresAt)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (partitionId) DO UPDATE
+    SET owner = excluded.owner, expiresAt = excluded.expiresAt
+    WHERE partition_leases.expiresAt <= $4 OR partition_leases.owner = excluded.owner;
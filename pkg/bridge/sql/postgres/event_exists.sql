@@ -0,0 +1 @@
+SELECT 1 FROM events WHERE orderId = $1
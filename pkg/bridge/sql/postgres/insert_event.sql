@@ -0,0 +1,3 @@
+INSERT INTO events
+	(orderId, orderOwner, orderNumber, orderResultType, attempts, lastAttempt, state, jobSpec, jobId, jobResult, jobStdout, jobStderr, jobExitcode, jobResults, resubmissions, jobExecutions, jobEndpoint, failureReason, stateMessage, savedAt, jobOutputHash, encryptionKey, jobEncryptedResult, durableStorage, jobDealId, orderPrice, timeline, orderContract, orderToken)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29);
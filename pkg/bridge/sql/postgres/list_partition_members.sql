@@ -0,0 +1,4 @@
+SELECT owner
+FROM partition_members
+WHERE expiresAt > $1
+ORDER BY owner;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS events (
+    eventId     BIGSERIAL PRIMARY KEY,
+    orderId     BYTEA NOT NULL,
+    orderOwner  BYTEA,
+    orderNumber BIGINT,
+    orderResultType SMALLINT,
+    attempts    INTEGER,
+    lastAttempt VARCHAR(25),
+    state       SMALLINT,
+    jobSpec     BYTEA,
+    jobId       TEXT,
+    jobResult   TEXT,
+    jobStdout   TEXT,
+    jobStderr   TEXT,
+    jobExitcode INTEGER
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_versions ON events (orderId, eventId);
+
+CREATE OR REPLACE VIEW latest_events AS
+    SELECT DISTINCT ON (orderId) *
+    FROM events
+    ORDER BY orderId, eventId DESC;
@@ -0,0 +1,6 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS jobExecutions TEXT NOT NULL DEFAULT '[]';
+
+CREATE OR REPLACE VIEW latest_events AS
+    SELECT DISTINCT ON (orderId) *
+    FROM events
+    ORDER BY orderId, eventId DESC;
@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS failureReason SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS stateMessage TEXT NOT NULL DEFAULT '';
+
+CREATE OR REPLACE VIEW latest_events AS
+    SELECT DISTINCT ON (orderId) *
+    FROM events
+    ORDER BY orderId, eventId DESC;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    orderId  TEXT PRIMARY KEY,
+    state    TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    error    TEXT NOT NULL,
+    status   SMALLINT NOT NULL,
+    deadAt   VARCHAR(35) NOT NULL,
+    event    TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS dead_letters_status ON dead_letters (status);
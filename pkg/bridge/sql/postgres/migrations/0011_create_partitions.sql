@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS partition_members (
+    owner     TEXT PRIMARY KEY,
+    expiresAt BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS partition_leases (
+    partitionId INTEGER PRIMARY KEY,
+    owner       TEXT NOT NULL,
+    expiresAt   BIGINT NOT NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS gas_spend (
+    txn     TEXT PRIMARY KEY,
+    day     VARCHAR(10) NOT NULL,
+    gasUsed BIGINT NOT NULL,
+    cost    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS gas_spend_day ON gas_spend (day);
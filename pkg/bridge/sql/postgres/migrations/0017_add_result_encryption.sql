@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS encryptionKey BYTEA;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS jobEncryptedResult TEXT NOT NULL DEFAULT '';
+
+CREATE OR REPLACE VIEW latest_events AS
+    SELECT DISTINCT ON (orderId) *
+    FROM events
+    ORDER BY orderId, eventId DESC;
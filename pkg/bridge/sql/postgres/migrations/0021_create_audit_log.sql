@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    auditId    BIGSERIAL PRIMARY KEY,
+    orderId    TEXT NOT NULL,
+    action     SMALLINT NOT NULL,
+    jobId      TEXT NOT NULL,
+    txHash     TEXT NOT NULL,
+    reason     TEXT NOT NULL,
+    error      TEXT NOT NULL,
+    results    TEXT NOT NULL,
+    recordedAt VARCHAR(35) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_order ON audit_log (orderId);
+CREATE INDEX IF NOT EXISTS audit_log_recorded ON audit_log (recordedAt);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS job_cache (
+    specHash   TEXT PRIMARY KEY,
+    jobId      TEXT NOT NULL,
+    endpoint   TEXT NOT NULL,
+    results    TEXT NOT NULL,
+    stdout     TEXT NOT NULL,
+    stderr     TEXT NOT NULL,
+    exitCode   INTEGER NOT NULL,
+    finishedAt VARCHAR(35) NOT NULL
+);
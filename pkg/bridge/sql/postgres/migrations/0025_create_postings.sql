@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS postings (
+    orderId       TEXT NOT NULL,
+    txHash        TEXT NOT NULL,
+    state         TEXT NOT NULL,
+    status        SMALLINT NOT NULL,
+    blockNumber   BIGINT NOT NULL,
+    confirmations BIGINT NOT NULL,
+    updatedAt     VARCHAR(35) NOT NULL,
+    PRIMARY KEY (orderId, txHash)
+);
+
+CREATE INDEX IF NOT EXISTS postings_status ON postings (status);
@@ -0,0 +1,3 @@
+INSERT INTO audit_log
+	(orderId, action, jobId, txHash, reason, error, results, recordedAt)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
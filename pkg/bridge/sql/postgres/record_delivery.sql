@@ -0,0 +1,5 @@
+INSERT INTO webhook_deliveries
+	(deliveryId, url, orderId, state, status, attempts, responseCode, error, updatedAt)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    ON CONFLICT (deliveryId) DO UPDATE SET
+	status = excluded.status, attempts = excluded.attempts, responseCode = excluded.responseCode, error = excluded.error, updatedAt = excluded.updatedAt;
@@ -0,0 +1,4 @@
+INSERT INTO gas_spend
+	(txn, day, gasUsed, cost)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (txn) DO NOTHING;
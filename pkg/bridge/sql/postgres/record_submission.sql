@@ -0,0 +1,4 @@
+INSERT INTO submissions
+	(orderId, resubmissions, jobId, endpoint, submittedAt)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (orderId, resubmissions) DO NOTHING;
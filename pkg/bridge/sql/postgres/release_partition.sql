@@ -0,0 +1,3 @@
+UPDATE partition_leases
+SET expiresAt = 0
+WHERE partitionId = $1 AND owner = $2;
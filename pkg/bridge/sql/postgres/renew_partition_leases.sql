@@ -0,0 +1,3 @@
+UPDATE partition_leases
+SET expiresAt = $1
+WHERE owner = $2 AND expiresAt > $3;
@@ -0,0 +1,8 @@
+SELECT auditId, orderId, action, jobId, txHash, reason, error, results, recordedAt
+FROM audit_log
+WHERE ($1 = '' OR orderId = $1)
+	AND ($2 < 0 OR action = $2)
+	AND ($3 = '' OR recordedAt >= $3)
+	AND ($4 = '' OR recordedAt < $4)
+ORDER BY auditId
+LIMIT $5 OFFSET $6;
@@ -0,0 +1,3 @@
+SELECT specHash, jobId, endpoint, results, stdout, stderr, exitCode, finishedAt
+FROM job_cache
+WHERE specHash = $1 AND finishedAt >= $2;
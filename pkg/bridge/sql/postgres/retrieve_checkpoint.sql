@@ -0,0 +1,3 @@
+SELECT block
+FROM block_checkpoints
+WHERE contract = $1;
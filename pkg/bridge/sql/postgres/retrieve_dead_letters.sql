@@ -0,0 +1,4 @@
+SELECT orderId, state, attempts, error, status, deadAt, event
+FROM dead_letters
+WHERE status = $1
+ORDER BY deadAt;
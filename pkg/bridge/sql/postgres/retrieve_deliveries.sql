@@ -0,0 +1,4 @@
+SELECT deliveryId, url, orderId, state, status, attempts, responseCode, error, updatedAt
+FROM webhook_deliveries
+WHERE orderId = $1
+ORDER BY updatedAt;
@@ -0,0 +1,3 @@
+SELECT eventId, orderId, orderOwner, orderNumber, orderResultType, attempts, lastAttempt, state, jobSpec, jobId, jobResult, jobStdout, jobStderr, jobExitcode, jobResults, resubmissions, jobExecutions, jobEndpoint, failureReason, stateMessage, savedAt, jobOutputHash, encryptionKey, jobEncryptedResult, durableStorage, jobDealId, orderPrice, timeline, orderContract, orderToken
+FROM latest_events
+WHERE state = $1;
@@ -0,0 +1,3 @@
+SELECT gasUsed, cost
+FROM gas_spend
+WHERE day = $1;
@@ -0,0 +1,3 @@
+SELECT orderId, requestor, orderNumber, state, reason, jobId, verdict, attempts, error, originalFailed, originalResult, mediatorFailed, mediatorResult, updatedAt, event
+FROM mediations
+WHERE orderId = $1;
@@ -0,0 +1,4 @@
+SELECT orderId, requestor, orderNumber, state, reason, jobId, verdict, attempts, error, originalFailed, originalResult, mediatorFailed, mediatorResult, updatedAt, event
+FROM mediations
+WHERE state = $1
+ORDER BY updatedAt;
@@ -0,0 +1,3 @@
+SELECT orderId, state, orderToken, orderPrice
+FROM latest_events
+WHERE state IN ($1, $2) AND savedAt >= $3 AND orderPrice != '';
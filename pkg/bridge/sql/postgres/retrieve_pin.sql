@@ -0,0 +1,3 @@
+SELECT cid, orderId, requestId, status, attempts, error, updatedAt
+FROM pins
+WHERE cid = $1;
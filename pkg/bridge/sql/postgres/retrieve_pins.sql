@@ -0,0 +1,4 @@
+SELECT cid, orderId, requestId, status, attempts, error, updatedAt
+FROM pins
+WHERE status = $1
+ORDER BY updatedAt;
@@ -0,0 +1,3 @@
+SELECT owner, expiresAt, posted
+FROM posting_locks
+WHERE orderId = $1;
@@ -0,0 +1,4 @@
+SELECT orderId, txHash, state, status, blockNumber, confirmations, updatedAt
+FROM postings
+WHERE status = $1
+ORDER BY updatedAt;
@@ -0,0 +1,3 @@
+SELECT orderId, resubmissions, jobId, endpoint, submittedAt
+FROM submissions
+WHERE orderId = $1 AND resubmissions = $2;
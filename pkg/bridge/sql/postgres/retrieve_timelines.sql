@@ -0,0 +1,3 @@
+SELECT timeline
+FROM latest_events
+WHERE state IN ($1, $2) AND savedAt >= $3;
@@ -0,0 +1,3 @@
+SELECT orderId, status, jobId, originalResult, verifierResult, error, updatedAt, event
+FROM verifications
+WHERE orderId = $1;
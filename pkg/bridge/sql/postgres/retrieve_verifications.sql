@@ -0,0 +1,4 @@
+SELECT orderId, status, jobId, originalResult, verifierResult, error, updatedAt, event
+FROM verifications
+WHERE status = $1
+ORDER BY updatedAt;
@@ -0,0 +1,5 @@
+INSERT INTO block_checkpoints
+	(contract, block, updatedAt)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (contract) DO UPDATE
+    SET block = excluded.block, updatedAt = excluded.updatedAt;
@@ -0,0 +1,5 @@
+INSERT INTO mediations
+	(orderId, requestor, orderNumber, state, reason, jobId, verdict, attempts, error, originalFailed, originalResult, mediatorFailed, mediatorResult, updatedAt, event)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+    ON CONFLICT (orderId) DO UPDATE SET
+	requestor = excluded.requestor, orderNumber = excluded.orderNumber, state = excluded.state, reason = excluded.reason, jobId = excluded.jobId, verdict = excluded.verdict, attempts = excluded.attempts, error = excluded.error, originalFailed = excluded.originalFailed, originalResult = excluded.originalResult, mediatorFailed = excluded.mediatorFailed, mediatorResult = excluded.mediatorResult, updatedAt = excluded.updatedAt, event = excluded.event;
@@ -0,0 +1,5 @@
+INSERT INTO postings
+	(orderId, txHash, state, status, blockNumber, confirmations, updatedAt)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (orderId, txHash) DO UPDATE SET
+	state = excluded.state, status = excluded.status, blockNumber = excluded.blockNumber, confirmations = excluded.confirmations, updatedAt = excluded.updatedAt;
@@ -0,0 +1,5 @@
+INSERT INTO verifications
+	(orderId, status, jobId, originalResult, verifierResult, error, updatedAt, event)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    ON CONFLICT (orderId) DO UPDATE SET
+	status = excluded.status, jobId = excluded.jobId, originalResult = excluded.originalResult, verifierResult = excluded.verifierResult, error = excluded.error, updatedAt = excluded.updatedAt, event = excluded.event;
@@ -0,0 +1,3 @@
+UPDATE posting_locks
+SET expiresAt = 0, posted = (posted OR $1)
+WHERE orderId = $2 AND owner = $3;
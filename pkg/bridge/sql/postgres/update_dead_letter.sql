@@ -0,0 +1,3 @@
+UPDATE dead_letters
+SET status = $1
+WHERE orderId = $2;
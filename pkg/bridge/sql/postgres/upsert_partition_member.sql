@@ -0,0 +1,4 @@
+INSERT INTO partition_members
+	(owner, expiresAt)
+    VALUES ($1, $2)
+    ON CONFLICT (owner) DO UPDATE SET expiresAt = excluded.expiresAt;
@@ -0,0 +1,3 @@
+INSERT INTO audit_log
+	(orderId, action, jobId, txHash, reason, error, results, recordedAt)
+    VALUES (:orderId, :action, :jobId, :txHash, :reason, :error, :results, :recordedAt);
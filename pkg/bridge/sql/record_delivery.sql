@@ -0,0 +1,5 @@
+INSERT INTO webhook_deliveries
+	(deliveryId, url, orderId, state, status, attempts, responseCode, error, updatedAt)
+    VALUES (:deliveryId, :url, :orderId, :state, :status, :attempts, :responseCode, :error, :updatedAt)
+    ON CONFLICT (deliveryId) DO UPDATE SET
+	status = excluded.status, attempts = excluded.attempts, responseCode = excluded.responseCode, error = excluded.error, updatedAt = excluded.updatedAt;
@@ -0,0 +1,4 @@
+INSERT INTO gas_spend
+	(txn, day, gasUsed, cost)
+    VALUES (:txn, :day, :gasUsed, :cost)
+    ON CONFLICT (txn) DO NOTHING;
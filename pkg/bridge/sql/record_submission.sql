@@ -0,0 +1,4 @@
+INSERT INTO submissions
+	(orderId, resubmissions, jobId, endpoint, submittedAt)
+    VALUES (:orderId, :resubmissions, :jobId, :endpoint, :submittedAt)
+    ON CONFLICT (orderId, resubmissions) DO NOTHING;
@@ -0,0 +1,3 @@
+UPDATE partition_leases
+SET expiresAt = 0
+WHERE partitionId = :partitionId AND owner = :owner;
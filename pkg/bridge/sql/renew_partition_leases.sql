@@ -0,0 +1,3 @@
+UPDATE partition_leases
+SET expiresAt = :expiresAt
+WHERE owner = :owner AND expiresAt > :now;
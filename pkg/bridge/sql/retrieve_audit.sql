@@ -0,0 +1,8 @@
+SELECT auditId, orderId, action, jobId, txHash, reason, error, results, recordedAt
+FROM audit_log
+WHERE (:orderId = '' OR orderId = :orderId)
+	AND (:action < 0 OR action = :action)
+	AND (:since = '' OR recordedAt >= :since)
+	AND (:until = '' OR recordedAt < :until)
+ORDER BY auditId
+LIMIT :limit OFFSET :offset;
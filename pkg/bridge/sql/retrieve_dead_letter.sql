@@ -0,0 +1,3 @@
+SELECT orderId, state, attempts, error, status, deadAt, event
+FROM dead_letters
+WHERE orderId = :orderId;
@@ -0,0 +1,4 @@
+SELECT deliveryId, url, orderId, state, status, attempts, responseCode, error, updatedAt
+FROM webhook_deliveries
+WHERE orderId = :orderId
+ORDER BY updatedAt;
@@ -0,0 +1,4 @@
+SELECT orderId, txHash, state, status, blockNumber, confirmations, updatedAt
+FROM postings
+WHERE orderId = :orderId
+ORDER BY updatedAt;
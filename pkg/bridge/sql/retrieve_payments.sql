@@ -0,0 +1,3 @@
+SELECT orderId, state, orderToken, orderPrice
+FROM latest_events
+WHERE state IN (:paid, :refunded) AND savedAt >= :since AND orderPrice != '';
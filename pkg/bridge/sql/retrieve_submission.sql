@@ -0,0 +1,3 @@
+SELECT orderId, resubmissions, jobId, endpoint, submittedAt
+FROM submissions
+WHERE orderId = :orderId AND resubmissions = :resubmissions;
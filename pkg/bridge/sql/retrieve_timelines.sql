@@ -0,0 +1,3 @@
+SELECT timeline
+FROM latest_events
+WHERE state IN (:paid, :refunded) AND savedAt >= :since;
@@ -0,0 +1,5 @@
+INSERT INTO block_checkpoints
+	(contract, block, updatedAt)
+    VALUES (:contract, :block, :updatedAt)
+    ON CONFLICT (contract) DO UPDATE
+    SET block = excluded.block, updatedAt = excluded.updatedAt;
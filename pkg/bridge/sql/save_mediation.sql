@@ -0,0 +1,5 @@
+INSERT INTO mediations
+	(orderId, requestor, orderNumber, state, reason, jobId, verdict, attempts, error, originalFailed, originalResult, mediatorFailed, mediatorResult, updatedAt, event)
+    VALUES (:orderId, :requestor, :orderNumber, :state, :reason, :jobId, :verdict, :attempts, :error, :originalFailed, :originalResult, :mediatorFailed, :mediatorResult, :updatedAt, :event)
+    ON CONFLICT (orderId) DO UPDATE SET
+	requestor = excluded.requestor, orderNumber = excluded.orderNumber, state = excluded.state, reason = excluded.reason, jobId = excluded.jobId, verdict = excluded.verdict, attempts = excluded.attempts, error = excluded.error, originalFailed = excluded.originalFailed, originalResult = excluded.originalResult, mediatorFailed = excluded.mediatorFailed, mediatorResult = excluded.mediatorResult, updatedAt = excluded.updatedAt, event = excluded.event;
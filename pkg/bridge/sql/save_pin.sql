@@ -0,0 +1,5 @@
+INSERT INTO pins
+	(cid, orderId, requestId, status, attempts, error, updatedAt)
+    VALUES (:cid, :orderId, :requestId, :status, :attempts, :error, :updatedAt)
+    ON CONFLICT (cid) DO UPDATE SET
+	orderId = excluded.orderId, requestId = excluded.requestId, status = excluded.status, attempts = excluded.attempts, error = excluded.error, updatedAt = excluded.updatedAt;
@@ -0,0 +1,5 @@
+INSERT INTO verifications
+	(orderId, status, jobId, originalResult, verifierResult, error, updatedAt, event)
+    VALUES (:orderId, :status, :jobId, :originalResult, :verifierResult, :error, :updatedAt, :event)
+    ON CONFLICT (orderId) DO UPDATE SET
+	status = excluded.status, jobId = excluded.jobId, originalResult = excluded.originalResult, verifierResult = excluded.verifierResult, error = excluded.error, updatedAt = excluded.updatedAt, event = excluded.event;
@@ -0,0 +1,11 @@
+ALTER TABLE events ADD COLUMN jobEndpoint TEXT NOT NULL DEFAULT '';
+
+DROP VIEW IF EXISTS latest_events;
+
+CREATE VIEW latest_events AS
+    WITH events_with_max AS (
+        SELECT *, LAST_VALUE(eventId) OVER (PARTITION BY orderId ORDER BY eventId RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) AS maxEventId FROM events
+    )
+    SELECT *
+    FROM events_with_max
+    WHERE eventId = maxEventId;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	deliveryId   VARCHAR(32) PRIMARY KEY,
+	url          TEXT NOT NULL,
+	orderId      TEXT NOT NULL,
+	state        TEXT NOT NULL,
+	status       SMALLINT NOT NULL,
+	attempts     INTEGER NOT NULL,
+	responseCode INTEGER NOT NULL,
+	error        TEXT NOT NULL,
+	updatedAt    VARCHAR(35) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_order ON webhook_deliveries (orderId);
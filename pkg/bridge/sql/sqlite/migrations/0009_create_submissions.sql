@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS submissions (
+	orderId       TEXT NOT NULL,
+	resubmissions INTEGER NOT NULL,
+	jobId         TEXT NOT NULL,
+	endpoint      TEXT NOT NULL,
+	submittedAt   VARCHAR(35) NOT NULL,
+	PRIMARY KEY (orderId, resubmissions)
+);
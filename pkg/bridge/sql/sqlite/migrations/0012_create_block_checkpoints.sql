@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS block_checkpoints (
+	contract  TEXT PRIMARY KEY,
+	block     BIGINT NOT NULL,
+	updatedAt VARCHAR(35) NOT NULL
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS mediations (
+	orderId        TEXT PRIMARY KEY,
+	requestor      TEXT NOT NULL,
+	orderNumber    BIGINT NOT NULL,
+	state          SMALLINT NOT NULL,
+	reason         TEXT NOT NULL,
+	jobId          TEXT NOT NULL,
+	verdict        TEXT NOT NULL,
+	attempts       INTEGER NOT NULL,
+	error          TEXT NOT NULL,
+	originalFailed BOOLEAN NOT NULL,
+	originalResult TEXT NOT NULL,
+	mediatorFailed BOOLEAN NOT NULL,
+	mediatorResult TEXT NOT NULL,
+	updatedAt      VARCHAR(35) NOT NULL,
+	event          TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS mediations_state ON mediations (state);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS verifications (
+	orderId        TEXT PRIMARY KEY,
+	status         SMALLINT NOT NULL,
+	jobId          TEXT NOT NULL,
+	originalResult TEXT NOT NULL,
+	verifierResult TEXT NOT NULL,
+	error          TEXT NOT NULL,
+	updatedAt      VARCHAR(35) NOT NULL,
+	event          TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS verifications_status ON verifications (status);
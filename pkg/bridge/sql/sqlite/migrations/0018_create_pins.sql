@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS pins (
+	cid       TEXT PRIMARY KEY,
+	orderId   TEXT NOT NULL,
+	requestId TEXT NOT NULL,
+	status    SMALLINT NOT NULL,
+	attempts  INTEGER NOT NULL,
+	error     TEXT NOT NULL,
+	updatedAt VARCHAR(35) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pins_status ON pins (status);
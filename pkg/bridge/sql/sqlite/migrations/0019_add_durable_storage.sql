@@ -0,0 +1,12 @@
+ALTER TABLE events ADD COLUMN durableStorage BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE events ADD COLUMN jobDealId TEXT NOT NULL DEFAULT '';
+
+DROP VIEW IF EXISTS latest_events;
+
+CREATE VIEW latest_events AS
+    WITH events_with_max AS (
+        SELECT *, LAST_VALUE(eventId) OVER (PARTITION BY orderId ORDER BY eventId RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) AS maxEventId FROM events
+    )
+    SELECT *
+    FROM events_with_max
+    WHERE eventId = maxEventId;
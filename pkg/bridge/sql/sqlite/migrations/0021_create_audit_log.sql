@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+	auditId    INTEGER PRIMARY KEY AUTOINCREMENT,
+	orderId    TEXT NOT NULL,
+	action     SMALLINT NOT NULL,
+	jobId      TEXT NOT NULL,
+	txHash     TEXT NOT NULL,
+	reason     TEXT NOT NULL,
+	error      TEXT NOT NULL,
+	results    TEXT NOT NULL,
+	recordedAt VARCHAR(35) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_order ON audit_log (orderId);
+CREATE INDEX IF NOT EXISTS audit_log_recorded ON audit_log (recordedAt);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS posting_locks (
+	orderId   TEXT PRIMARY KEY,
+	owner     TEXT NOT NULL,
+	expiresAt BIGINT NOT NULL,
+	posted    BOOLEAN NOT NULL DEFAULT FALSE
+);
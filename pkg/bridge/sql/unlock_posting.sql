@@ -0,0 +1,3 @@
+UPDATE posting_locks
+SET expiresAt = 0, posted = (posted OR :posted)
+WHERE orderId = :orderId AND owner = :owner;
@@ -0,0 +1,3 @@
+UPDATE dead_letters
+SET status = :status
+WHERE orderId = :orderId;
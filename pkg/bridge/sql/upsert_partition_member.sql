@@ -0,0 +1,4 @@
+INSERT INTO partition_members
+	(owner, expiresAt)
+    VALUES (:owner, :expiresAt)
+    ON CONFLICT (owner) DO UPDATE SET expiresAt = excluded.expiresAt;
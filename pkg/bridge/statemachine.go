@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIllegalTransition is matched by the error returned when an order is asked
+// to move into a state that its lifecycle doesn't allow from where it is.
+var ErrIllegalTransition = errors.New("illegal order state transition")
+
+// An IllegalTransitionError says which transition an order was refused.
+type IllegalTransitionError struct {
+	From OrderState
+	To   OrderState
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("order can't move from %s to %s", e.From, e.To)
+}
+
+// Is makes errors.Is match ErrIllegalTransition.
+func (e *IllegalTransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// The lifecycle of an order. An order is submitted on the contract, runs as a
+// job, and either completes and is paid for, or fails and is refunded. A job
+// that errors may be submitted again. An order may fail from any state that
+// isn't final, for example when it is removed from the chain by a reorg.
+var orderTransitions = map[OrderState][]OrderState{
+	OrderStateSubmitted: {OrderStateRunning, OrderStateFailed},
+	OrderStateRunning:   {OrderStateCompleted, OrderStateJobError, OrderStateFailed},
+	OrderStateCompleted: {OrderStatePaid, OrderStateFailed},
+	OrderStateJobError:  {OrderStateSubmitted, OrderStateFailed},
+	OrderStateFailed:    {OrderStateRefunded},
+	OrderStatePaid:      {},
+	OrderStateRefunded:  {},
+}
+
+// CanTransitionTo returns whether an order in this state may move to the
+// passed state. Staying in the same state is always allowed.
+func (state OrderState) CanTransitionTo(to OrderState) bool {
+	if state == to {
+		return true
+	}
+	for _, next := range orderTransitions[state] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal returns whether the state is the end of an order's lifecycle, from
+// which it never moves.
+func (state OrderState) Terminal() bool {
+	return len(orderTransitions[state]) == 0
+}
+
+// CheckTransition returns an IllegalTransitionError if an order can't move
+// between the passed states.
+func CheckTransition(from, to OrderState) error {
+	if !from.CanTransitionTo(to) {
+		return &IllegalTransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// activeStates returns the states of orders that are still being worked on.
+func activeStates() []OrderState {
+	states := make([]OrderState, 0, len(orderTransitions))
+	for _, state := range OrderStates() {
+		if !state.Terminal() {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// transition moves the event into the passed state, returning whether it was
+// allowed to. If it wasn't, the event stays where it is and remembers the
+// first refused transition, so that it isn't saved.
+func (e *event) transition(to OrderState) bool {
+	if err := CheckTransition(e.state, to); err != nil {
+		if e.transitionErr == nil {
+			e.transitionErr = err
+		}
+		return false
+	}
+	e.state = to
+	return true
+}
+
+// transitionError returns the transition the event was refused since it was
+// created or loaded, if any.
+func transitionError(e Event) error {
+	if e, ok := e.(*event); ok {
+		return e.transitionErr
+	}
+	return nil
+}
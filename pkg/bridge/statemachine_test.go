@@ -0,0 +1,46 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderLifecycle(t *testing.T) {
+	require.NoError(t, CheckTransition(OrderStateSubmitted, OrderStateRunning))
+	require.NoError(t, CheckTransition(OrderStateJobError, OrderStateSubmitted))
+	require.NoError(t, CheckTransition(OrderStatePaid, OrderStatePaid))
+
+	err := CheckTransition(OrderStateRefunded, OrderStateSubmitted)
+	require.ErrorIs(t, err, ErrIllegalTransition)
+	require.Equal(t, "order can't move from Refunded to Submitted", err.Error())
+
+	require.True(t, OrderStatePaid.Terminal())
+	require.False(t, OrderStateFailed.Terminal())
+	require.NotContains(t, activeStates(), OrderStateRefunded)
+}
+
+func TestIllegalTransitionsAreNotSaved(t *testing.T) {
+	repo := repository(t)
+	workflow := NewWorkflow(&mockRunner{}, &mockContract{}, repo)
+
+	// A submitted order can't complete without a job having run.
+	e := exampleEvent()
+	completed := e.(*event).Completed(cid.Cid{}, "out", "", 0)
+	require.Equal(t, OrderStateSubmitted, completed.OrderState())
+	require.Empty(t, completed.StdOut(), "a refused transition shouldn't change the event")
+	require.ErrorIs(t, transitionError(completed), ErrIllegalTransition)
+
+	result, _ := workflow.settle(context.Background(), e, completed, 0, nil)
+	require.Nil(t, result)
+	exists, err := repo.Exists(e)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	paid := exampleEvent().JobCreated(model.NewJob()).Completed(cid.Cid{}, "", "", 0).Paid()
+	require.NoError(t, transitionError(paid))
+	require.Equal(t, OrderStatePaid, paid.OrderState())
+}
@@ -0,0 +1,130 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadEventsUpgradeable.sol"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// How often the contract is polled for events, depending on whether a
+// websocket subscription is also watching for them.
+const (
+	defaultPollInterval    = 15 * time.Second
+	subscribedPollInterval = time.Minute
+)
+
+// How often a live subscription checks that its connection is still up, and
+// how long to wait between attempts to reconnect one that isn't.
+const (
+	subscriptionHeartbeat = 30 * time.Second
+	minReconnectWait      = time.Second
+	maxReconnectWait      = time.Minute
+)
+
+// pollInterval returns how long to wait before polling for events again.
+// Whilst events are being subscribed to, polling only needs to catch anything
+// the subscription missed, unless events have to wait for confirmations, which
+// the subscription doesn't know about.
+func (r *realContract) pollInterval() time.Duration {
+	if r.subscribed.Load() && r.confirmations == 0 {
+		return subscribedPollInterval
+	}
+	return defaultPollInterval
+}
+
+// subscribe keeps a subscription to the contract's events open over the
+// websocket endpoint until the passed context is cancelled, triggering a read
+// of the logs whenever an event arrives. If the connection fails, it is made
+// again with a backoff, and events are polled for in the meantime.
+func (r *realContract) subscribe(ctx context.Context, triggered chan<- struct{}) {
+	wait := minReconnectWait
+	for {
+		start := time.Now()
+		err := r.subscribeOnce(ctx, triggered)
+		r.subscribed.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A subscription that stayed up for a while was a success, so the
+		// next one can be tried straight away.
+		if time.Since(start) > maxReconnectWait {
+			wait = minReconnectWait
+		}
+		log.Ctx(ctx).Warn().Err(err).Dur("wait", wait).Msg("Contract subscription lost, polling until reconnected")
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		if wait *= 2; wait > maxReconnectWait {
+			wait = maxReconnectWait
+		}
+	}
+}
+
+// subscribeOnce subscribes to the contract's events and blocks until the
+// subscription fails or the passed context is cancelled.
+func (r *realContract) subscribeOnce(ctx context.Context, triggered chan<- struct{}) error {
+	client, err := ethclient.DialContext(ctx, r.websocket)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	filterer, err := LilypadEventsUpgradeable.NewLilypadEventsUpgradeableFilterer(r.address, client)
+	if err != nil {
+		return err
+	}
+
+	sink := make(chan *LilypadEventsUpgradeable.LilypadEventsUpgradeableNewLilypadJobSubmitted)
+	sub, err := filterer.WatchNewLilypadJobSubmitted(&bind.WatchOpts{Context: ctx}, sink)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	r.subscribed.Store(true)
+	log.Ctx(ctx).Info().Str("endpoint", r.websocket).Msg("Subscribed to contract events")
+
+	// The subscription only sends new events, so read everything since the
+	// last block seen to pick up any events made whilst it was down. Events
+	// themselves are always read from the logs, so that they are checkpointed
+	// and confirmed in the same way however they are noticed.
+	trigger(triggered)
+
+	heartbeat := time.NewTicker(subscriptionHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-sink:
+			trigger(triggered)
+		case err := <-sub.Err():
+			return err
+		case <-heartbeat.C:
+			pingCtx, cancel := context.WithTimeout(ctx, subscriptionHeartbeat)
+			_, err := client.BlockNumber(pingCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("heartbeat: %w", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// trigger asks for the logs to be read, unless a read has already been asked
+// for and not yet started.
+func trigger(triggered chan<- struct{}) {
+	select {
+	case triggered <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,360 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// A JobTemplate is a named Bacalhau job spec kept by the bridge, so that an
+// order only has to say which template to run and with what parameters rather
+// than putting a whole spec on-chain.
+//
+// Parameters are substituted into the strings of the spec wherever ${name}
+// appears. They are only ever substituted into strings, so a parameter can't
+// change the shape of the spec.
+type JobTemplate struct {
+	Name        string              `yaml:"name" json:"name"`
+	Version     string              `yaml:"version" json:"version"`
+	Description string              `yaml:"description" json:"description,omitempty"`
+	Parameters  []TemplateParameter `yaml:"parameters" json:"parameters"`
+
+	// The job spec, using the same field names as a spec sent on-chain.
+	Spec map[string]any `yaml:"spec" json:"-"`
+}
+
+// A TemplateParameter is a value that an order passes to a JobTemplate.
+type TemplateParameter struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+
+	// Parameters without a default must be passed by the order.
+	Default *string `yaml:"default" json:"default,omitempty"`
+
+	// If set, the value must match this regular expression in full.
+	Pattern string `yaml:"pattern" json:"pattern,omitempty"`
+	pattern *regexp.Regexp
+}
+
+// A TemplateRequest is the spec of an order that asks for a template to be run,
+// such as
+//
+//	{"template": "stable-diffusion", "version": "1.2", "parameters": {"prompt": "a frog"}}
+//
+// The version can also be given as part of the name, as in
+// "stable-diffusion@1.2". If the version is left out the latest version is
+// used.
+type TemplateRequest struct {
+	Template   string            `json:"template"`
+	Version    string            `json:"version,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// parseTemplateRequest returns the template request in the passed spec, or
+// false if the spec is a whole job spec.
+func parseTemplateRequest(spec []byte) (TemplateRequest, bool) {
+	var request TemplateRequest
+	if err := json.Unmarshal(spec, &request); err != nil || request.Template == "" {
+		return TemplateRequest{}, false
+	}
+	if name, version, found := strings.Cut(request.Template, "@"); found && request.Version == "" {
+		request.Template, request.Version = name, version
+	}
+	return request, true
+}
+
+// validate checks that the template is complete and that every placeholder in
+// its spec is a declared parameter.
+func (t *JobTemplate) validate() error {
+	if t.Name == "" || t.Version == "" {
+		return fmt.Errorf("templates must have a name and version")
+	} else if _, err := parseTemplateVersion(t.Version); err != nil {
+		return err
+	} else if len(t.Spec) == 0 {
+		return fmt.Errorf("template %s has no spec", t)
+	}
+
+	declared := map[string]bool{}
+	for i := range t.Parameters {
+		param := &t.Parameters[i]
+		if !placeholder.MatchString("${" + param.Name + "}") {
+			return fmt.Errorf("template %s: invalid parameter name %q", t, param.Name)
+		} else if declared[param.Name] {
+			return fmt.Errorf("template %s: parameter %s declared twice", t, param.Name)
+		}
+		declared[param.Name] = true
+
+		if param.Pattern != "" {
+			pattern, err := regexp.Compile("^(?:" + param.Pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("template %s: parameter %s: %w", t, param.Name, err)
+			}
+			param.pattern = pattern
+		}
+		if param.Default != nil && param.pattern != nil && !param.pattern.MatchString(*param.Default) {
+			return fmt.Errorf("template %s: default of parameter %s does not match its pattern", t, param.Name)
+		}
+	}
+
+	var undeclared error
+	walkStrings(t.Spec, func(s string) string {
+		for _, match := range placeholder.FindAllStringSubmatch(s, -1) {
+			if !declared[match[1]] && undeclared == nil {
+				undeclared = fmt.Errorf("template %s uses undeclared parameter %s", t, match[1])
+			}
+		}
+		return s
+	})
+	if undeclared != nil {
+		return undeclared
+	}
+
+	// Parameters only change strings, so a spec that reads now always will.
+	data, err := json.Marshal(t.Spec)
+	if err == nil {
+		err = json.Unmarshal(data, new(model.Spec))
+	}
+	if err != nil {
+		return fmt.Errorf("template %s: invalid spec: %w", t, err)
+	}
+	return nil
+}
+
+func (t *JobTemplate) String() string {
+	return t.Name + "@" + t.Version
+}
+
+// Render returns the template's job spec with the passed parameters
+// substituted in. Parameters that are missing, unknown or don't match their
+// pattern cause a *Rejection.
+func (t *JobTemplate) Render(params map[string]string) (model.Spec, error) {
+	values := make(map[string]string, len(t.Parameters))
+	for _, param := range t.Parameters {
+		value, ok := params[param.Name]
+		switch {
+		case !ok && param.Default == nil:
+			return model.Spec{}, reject("template %s needs parameter %s", t, param.Name)
+		case !ok:
+			value = *param.Default
+		case param.pattern != nil && !param.pattern.MatchString(value):
+			return model.Spec{}, reject("parameter %s of template %s must match %s", param.Name, t, param.Pattern)
+		}
+		values[param.Name] = value
+	}
+	for name := range params {
+		if _, ok := values[name]; !ok {
+			return model.Spec{}, reject("template %s has no parameter %s", t, name)
+		}
+	}
+
+	rendered := walkStrings(t.Spec, func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(match string) string {
+			return values[match[2:len(match)-1]]
+		})
+	})
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return model.Spec{}, err
+	}
+
+	var spec model.Spec
+	if err = json.Unmarshal(data, &spec); err != nil {
+		return model.Spec{}, fmt.Errorf("template %s: invalid spec: %w", t, err)
+	}
+	return spec, nil
+}
+
+// walkStrings returns a copy of the YAML value with every string passed
+// through the function.
+func walkStrings(value any, f func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		return f(v)
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = walkStrings(item, f)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = walkStrings(item, f)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// parseTemplateVersion splits a dotted version such as 1.2.0 into its numbers.
+func parseTemplateVersion(version string) ([]int, error) {
+	parts := strings.Split(version, ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid template version %q", version)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// newerTemplateVersion returns whether version a is newer than version b.
+func newerTemplateVersion(a, b string) bool {
+	x, _ := parseTemplateVersion(a)
+	y, _ := parseTemplateVersion(b)
+	for i := 0; i < len(x) || i < len(y); i++ {
+		var m, n int
+		if i < len(x) {
+			m = x[i]
+		}
+		if i < len(y) {
+			n = y[i]
+		}
+		if m != n {
+			return m > n
+		}
+	}
+	return false
+}
+
+// A TemplateLibrary holds every version of the job templates the bridge will
+// run.
+type TemplateLibrary struct {
+	templates map[string][]*JobTemplate // by name, newest version first
+}
+
+// LoadTemplates reads every .yaml file in the passed directory as a
+// JobTemplate, and checks that each is valid.
+func LoadTemplates(dir string) (*TemplateLibrary, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	library := &TemplateLibrary{templates: map[string][]*JobTemplate{}}
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		template := new(JobTemplate)
+		if err = yaml.Unmarshal(contents, template); err != nil {
+			return nil, errors.Wrapf(err, "invalid template file %s", path)
+		}
+		if err = library.Add(template); err != nil {
+			return nil, errors.Wrapf(err, "invalid template file %s", path)
+		}
+	}
+	return library, nil
+}
+
+// Add checks the template and adds it to the library.
+func (l *TemplateLibrary) Add(template *JobTemplate) error {
+	if err := template.validate(); err != nil {
+		return err
+	}
+	for _, existing := range l.templates[template.Name] {
+		if existing.Version == template.Version {
+			return fmt.Errorf("template %s defined twice", template)
+		}
+	}
+
+	versions := append(l.templates[template.Name], template)
+	sort.Slice(versions, func(i, j int) bool {
+		return newerTemplateVersion(versions[i].Version, versions[j].Version)
+	})
+	l.templates[template.Name] = versions
+	return nil
+}
+
+// Template returns the template with the passed name and version, or its
+// latest version if the version is empty.
+func (l *TemplateLibrary) Template(name, version string) (*JobTemplate, bool) {
+	versions := l.templates[name]
+	if len(versions) > 0 && version == "" {
+		return versions[0], true
+	}
+	for _, template := range versions {
+		if template.Version == version {
+			return template, true
+		}
+	}
+	return nil, false
+}
+
+// Templates returns every template in the library, by name and then newest
+// version first.
+func (l *TemplateLibrary) Templates() []*JobTemplate {
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	all := make([]*JobTemplate, 0, len(names))
+	for _, name := range names {
+		all = append(all, l.templates[name]...)
+	}
+	return all
+}
+
+// WithTemplates lets orders ask for one of the templates in the source to be
+// run instead of passing a whole job spec. Otherwise such orders are rejected.
+func WithTemplates(templates TemplateSource) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Templates = templates
+	}
+}
+
+// expandTemplate replaces the spec of an order that asks for a template with
+// the rendered template, so that the job that is run and saved is the whole
+// spec. Orders for templates that don't exist or with bad parameters are
+// rejected.
+func (workflow *Workflow) expandTemplate(e ContractSubmittedEvent) error {
+	request, ok := parseTemplateRequest(e.RawSpec())
+	if !ok {
+		return nil
+	}
+
+	spec, err := renderTemplateRequest(workflow.Templates, request)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	e.WithSpec(data)
+	return nil
+}
+
+// renderTemplateRequest returns the job spec that the request asks for, or a
+// *Rejection if the template can't be rendered.
+func renderTemplateRequest(templates TemplateSource, request TemplateRequest) (model.Spec, error) {
+	if templates == nil {
+		return model.Spec{}, reject("job templates can't be run by this bridge")
+	}
+
+	template, ok := templates.Template(request.Template, request.Version)
+	if !ok && request.Version == "" {
+		return model.Spec{}, reject("unknown job template %s", request.Template)
+	} else if !ok {
+		return model.Spec{}, reject("unknown job template %s@%s", request.Template, request.Version)
+	}
+	return template.Render(request.Parameters)
+}
@@ -0,0 +1,95 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExampleTemplates(t *testing.T) {
+	library, err := LoadTemplates(filepath.Join("..", "..", "examples", "templates"))
+	require.NoError(t, err)
+
+	template, ok := library.Template("stable-diffusion", "")
+	require.True(t, ok)
+	spec, err := template.Render(map[string]string{"prompt": `a "quoted" frog`})
+	require.NoError(t, err)
+	require.Equal(t, `a "quoted" frog`, spec.Docker.Entrypoint[len(spec.Docker.Entrypoint)-1])
+	require.NoError(t, validateSpec(&spec))
+}
+
+func TestTemplateVersionsAndParameters(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"echo-1.yaml": `
+name: echo
+version: "1.9"
+parameters: [{name: message}]
+spec: {Engine: docker, Docker: {Image: ubuntu, Entrypoint: [echo, "${message}"]}}
+`,
+		"echo-2.yaml": `
+name: echo
+version: "1.10"
+parameters: [{name: message, default: hello, pattern: "[a-z]+"}]
+spec: {Engine: docker, Docker: {Image: ubuntu, Entrypoint: [echo, "v2 ${message}"]}}
+`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+
+	library, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	latest, ok := library.Template("echo", "")
+	require.True(t, ok)
+	require.Equal(t, "1.10", latest.Version)
+	spec, err := latest.Render(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo", "v2 hello"}, spec.Docker.Entrypoint)
+
+	var rejection *Rejection
+	_, err = latest.Render(map[string]string{"message": "Not Allowed"})
+	require.True(t, errors.As(err, &rejection))
+	_, err = latest.Render(map[string]string{"other": "x"})
+	require.True(t, errors.As(err, &rejection))
+
+	old, ok := library.Template("echo", "1.9")
+	require.True(t, ok)
+	_, err = old.Render(nil)
+	require.True(t, errors.As(err, &rejection))
+
+	require.Error(t, library.Add(&JobTemplate{
+		Name:    "broken",
+		Version: "1",
+		Spec:    map[string]any{"Engine": "docker", "Docker": map[string]any{"Image": "${image}"}},
+	}))
+}
+
+func TestTemplateOrdersAreExpanded(t *testing.T) {
+	library, err := LoadTemplates(filepath.Join("..", "..", "examples", "templates"))
+	require.NoError(t, err)
+
+	request, err := json.Marshal(TemplateRequest{Template: "stable-diffusion", Parameters: map[string]string{"prompt": "a frog"}})
+	require.NoError(t, err)
+	e := exampleEvent()
+	e.WithSpec(request)
+
+	workflow := NewWorkflow(&mockRunner{CreateHandler: SuccessfulCreate}, &mockContract{}, repository(t), WithTemplates(library))
+	_, err = workflow.create(context.Background(), e)
+	require.NoError(t, err)
+
+	spec, err := e.Spec()
+	require.NoError(t, err)
+	require.Equal(t, "ghcr.io/bacalhau-project/examples/stable-diffusion-gpu:0.0.1", spec.Docker.Image)
+
+	e = exampleEvent()
+	e.WithSpec(request)
+	_, err = NewWorkflow(&mockRunner{CreateHandler: SuccessfulCreate}, &mockContract{}, repository(t)).create(context.Background(), e)
+	var rejection *Rejection
+	require.True(t, errors.As(err, &rejection))
+}
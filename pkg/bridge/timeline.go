@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+)
+
+// A Timeline records when an order reached each milestone on its way through
+// the bridge, so that how long each step took can be reported on. Milestones
+// that haven't been reached are the zero time.
+type Timeline struct {
+	// When the order was seen on the contract.
+	Observed time.Time
+	// When the job for the order was submitted to Bacalhau. If the order is
+	// resubmitted, this is when the latest job was submitted.
+	Submitted time.Time
+	// When a node was first seen executing the job.
+	Started time.Time
+	// When the job was seen to have completed.
+	Completed time.Time
+	// When the results of the job were fetched.
+	ResultFetched time.Time
+	// When the transaction settling the order was posted to the contract.
+	TxPosted time.Time
+}
+
+// timelineJSON is how a Timeline is written, leaving out the milestones that
+// haven't been reached.
+type timelineJSON struct {
+	Observed      *time.Time `json:"observed,omitempty"`
+	Submitted     *time.Time `json:"submitted,omitempty"`
+	Started       *time.Time `json:"started,omitempty"`
+	Completed     *time.Time `json:"completed,omitempty"`
+	ResultFetched *time.Time `json:"resultFetched,omitempty"`
+	TxPosted      *time.Time `json:"txPosted,omitempty"`
+}
+
+func milestone(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func reached(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Timeline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timelineJSON{
+		Observed:      milestone(t.Observed),
+		Submitted:     milestone(t.Submitted),
+		Started:       milestone(t.Started),
+		Completed:     milestone(t.Completed),
+		ResultFetched: milestone(t.ResultFetched),
+		TxPosted:      milestone(t.TxPosted),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Timeline) UnmarshalJSON(data []byte) error {
+	var j timelineJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*t = Timeline{
+		Observed:      reached(j.Observed),
+		Submitted:     reached(j.Submitted),
+		Started:       reached(j.Started),
+		Completed:     reached(j.Completed),
+		ResultFetched: reached(j.ResultFetched),
+		TxPosted:      reached(j.TxPosted),
+	}
+	return nil
+}
+
+// Between returns how long the order took to get from one milestone to the
+// other, or false if it hasn't reached both.
+func Between(from, to time.Time) (time.Duration, bool) {
+	if from.IsZero() || to.IsZero() {
+		return 0, false
+	}
+	return to.Sub(from), true
+}
+
+// The states of executions in which a node has started running the job.
+var executingStates = map[string]bool{
+	model.ExecutionStateBidAccepted.String():    true,
+	model.ExecutionStateResultProposed.String(): true,
+	model.ExecutionStateResultAccepted.String(): true,
+	model.ExecutionStateCompleted.String():      true,
+	model.ExecutionStateFailed.String():         true,
+}
+
+// executing returns whether any of the executions show that a node has
+// started running the job.
+func executing(executions []Execution) bool {
+	for _, execution := range executions {
+		if executingStates[execution.State] {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeline implements ContractSubmittedEvent
+func (e *event) Timeline() Timeline {
+	return e.timeline
+}
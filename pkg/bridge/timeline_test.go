@@ -0,0 +1,71 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimelineRecordsMilestones(t *testing.T) {
+	result := cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
+	running := exampleEvent().JobCreated(model.NewJob())
+	require.False(t, running.Timeline().Submitted.IsZero())
+
+	// Nodes bidding on the job haven't started it yet.
+	running = running.WithExecutions([]Execution{{NodeID: "QmNode", State: model.ExecutionStateAskForBid.String()}})
+	require.True(t, running.Timeline().Started.IsZero())
+	running = running.WithExecutions([]Execution{{NodeID: "QmNode", State: model.ExecutionStateBidAccepted.String()}})
+	started := running.Timeline().Started
+	require.False(t, started.IsZero())
+	running = running.WithExecutions([]Execution{{NodeID: "QmNode", State: model.ExecutionStateCompleted.String()}})
+	require.Equal(t, started, running.Timeline().Started, "the job should only start once")
+
+	paid := running.Completed(result, "", "", 0).WithResults([]cid.Cid{result}).PaidIn(common.Hash{0x01})
+	timeline := paid.Timeline()
+	require.False(t, timeline.Completed.IsZero())
+	require.False(t, timeline.ResultFetched.IsZero())
+	require.False(t, timeline.TxPosted.IsZero())
+
+	took, ok := Between(timeline.Submitted, timeline.TxPosted)
+	require.True(t, ok)
+	require.GreaterOrEqual(t, took, time.Duration(0))
+	_, ok = Between(timeline.Observed, timeline.TxPosted)
+	require.False(t, ok, "the order wasn't observed on the contract")
+
+	// Resubmitting the order starts the job's milestones again.
+	resubmitted := exampleEvent().JobCreated(model.NewJob()).JobError("failed").Retry().JobCreated(model.NewJob())
+	require.True(t, resubmitted.Timeline().Completed.IsZero())
+}
+
+func TestTimelineLeavesOutUnreachedMilestones(t *testing.T) {
+	timeline := Timeline{Observed: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)}
+	data, err := json.Marshal(timeline)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"observed":"2023-01-02T03:04:05Z"}`, string(data))
+
+	var decoded Timeline
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, timeline, decoded)
+}
+
+func TestTimelineIsReloaded(t *testing.T) {
+	repo := repository(t)
+	e := exampleEvent().JobCreated(model.NewJob())
+	require.NoError(t, repo.Save(e))
+
+	events, err := Reload[BacalhauJobRunningEvent](repo, OrderStateRunning)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.True(t, e.Timeline().Submitted.Equal(events[0].Timeline().Submitted))
+
+	order, err := repo.(OrderStore).Order(context.Background(), e.OrderId())
+	require.NoError(t, err)
+	require.True(t, e.Timeline().Submitted.Equal(order.Timeline.Submitted))
+}
@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/bacalhau-project/lilypad/pkg/bridge")
+
+// StartTracing exports spans to the OTLP collector named by the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables,
+// over the protocol named by OTEL_EXPORTER_OTLP_PROTOCOL, if either is set.
+// The returned function flushes any spans not yet exported and stops.
+func StartTracing(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	_, endpoint := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	_, tracesEndpoint := os.LookupEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if !endpoint && !tracesEndpoint {
+		log.Ctx(ctx).Debug().Msg("Not exporting traces, as no OTLP endpoint is set")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	protocol := "http/protobuf"
+	for _, env := range []string{"OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"} {
+		if value := os.Getenv(env); value != "" {
+			protocol = value
+		}
+	}
+
+	var client otlptrace.Client
+	switch protocol {
+	case "grpc":
+		client = otlptracegrpc.NewClient()
+	case "http/protobuf":
+		client = otlptracehttp.NewClient()
+	default:
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL: unsupported protocol %q", protocol)
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("lilypad")),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	log.Ctx(ctx).Info().Str("protocol", protocol).Msg("Exporting traces")
+	return provider.Shutdown, nil
+}
+
+// The span attribute that every span relating to a single order carries, so
+// that the order can be followed through its whole lifecycle.
+const orderIdAttribute = attribute.Key("lilypad.order_id")
+
+// startOrderSpan starts a new span for an action taken on the passed order.
+func startOrderSpan(ctx context.Context, name string, e Event) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		orderIdAttribute.String(e.OrderId().Hex()),
+		attribute.Stringer("lilypad.order_state", e.OrderState()),
+	))
+}
+
+// endSpan records the result of the action on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
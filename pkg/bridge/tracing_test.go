@@ -0,0 +1,20 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingIsOnlyStartedWithAnEndpoint(t *testing.T) {
+	ctx := context.Background()
+	shutdown, err := StartTracing(ctx)
+	require.NoError(t, err)
+	require.NoError(t, shutdown(ctx))
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "carrier-pigeon")
+	_, err = StartTracing(ctx)
+	require.ErrorContains(t, err, "unsupported protocol")
+}
@@ -0,0 +1,51 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// NewVaultSigner returns a Signer that signs with a private key read from a
+// HashiCorp Vault KV secret at startup, so that the key is never written in
+// the config or to disk. Vault's transit engine doesn't support the secp256k1
+// curve, so the key itself has to be read. The path is that of the secret in
+// the API, such as secret/data/lilypad for a KV version 2 engine, and the field
+// is the key within the secret that holds the hex encoded private key.
+func NewVaultSigner(ctx context.Context, address, token, path, field string) (Signer, error) {
+	return newVaultSigner(ctx, http.DefaultClient, address, token, path, field)
+}
+
+func newVaultSigner(ctx context.Context, client *http.Client, address, token, path, field string) (Signer, error) {
+	url := strings.TrimSuffix(address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	// Version 2 of the KV engine nests the secret inside another data field.
+	var secret struct {
+		Data map[string]any
+	}
+	if err := doJSONRequest(client, req, &secret); err != nil {
+		return nil, err
+	}
+	data := secret.Data
+	if nested, isV2 := data["data"].(map[string]any); isV2 {
+		data = nested
+	}
+
+	hexKey, _ := data[field].(string)
+	if hexKey == "" {
+		return nil, fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("vault secret %s: %w", path, err)
+	}
+	return NewPrivateKeySigner(key), nil
+}
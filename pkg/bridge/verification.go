@@ -0,0 +1,318 @@
+package bridge
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// A VerificationStatus is what re-running an order's job found.
+//
+//go:generate stringer -type=VerificationStatus --trimprefix=VerificationStatus
+type VerificationStatus int
+
+const (
+	// The job is being run again on the verifying cluster.
+	VerificationStatusPending VerificationStatus = iota
+	// The job gave the same result when run again.
+	VerificationStatusMatched
+	// The job gave a different result when run again.
+	VerificationStatusMismatched
+	// The job couldn't be run again, so the original result is trusted.
+	VerificationStatusInconclusive
+)
+
+// A Verification is the re-running of a completed order's job on a second
+// cluster, to check that the result it returned can be trusted.
+type Verification struct {
+	OrderID        string             `json:"orderId"`
+	Status         VerificationStatus `json:"status"`
+	JobID          string             `json:"jobId,omitempty"`
+	OriginalResult string             `json:"originalResult"`
+	VerifierResult string             `json:"verifierResult,omitempty"`
+	Error          string             `json:"error,omitempty"`
+	Time           time.Time          `json:"time"`
+
+	// The job on the verifying cluster, encoded by MarshalEvent.
+	Event json.RawMessage `json:"event"`
+}
+
+var ErrVerificationNotFound = errors.New("verification not found")
+
+// A VerificationStore keeps track of the orders whose results are being
+// verified.
+type VerificationStore interface {
+	// SaveVerification saves the verification, replacing any for the same
+	// order.
+	SaveVerification(ctx context.Context, v Verification) error
+
+	// Verification returns the verification of the passed order, or
+	// ErrVerificationNotFound.
+	Verification(ctx context.Context, orderID string) (Verification, error)
+
+	// Verifications returns every verification with the passed status.
+	Verifications(ctx context.Context, status VerificationStatus) ([]Verification, error)
+}
+
+// A ResultDisputer is a SmartContract that can announce that the result of an
+// order is disputed, rather than returning it.
+type ResultDisputer interface {
+	// DisputeResult emits a dispute event for the order, with the result that
+	// was expected instead of the one the job returned.
+	DisputeResult(ctx context.Context, event BacalhauJobCompletedEvent, expected string) error
+}
+
+// A Verifier re-runs a sample of completed jobs on a second cluster, so that a
+// cluster returning wrong results is caught out.
+type Verifier struct {
+	// Runs jobs on the verifying cluster, which must not be one the workflow
+	// submits jobs to.
+	Runner JobRunner
+	Store  VerificationStore
+
+	// The fraction of completed orders that are verified, from 0 to 1.
+	rate float64
+}
+
+// NewVerifier returns a Verifier that re-runs the passed percentage of
+// completed jobs using the runner.
+func NewVerifier(runner JobRunner, store VerificationStore, percent float64) *Verifier {
+	return &Verifier{Runner: runner, Store: store, rate: percent / 100}
+}
+
+// WithVerifier makes the workflow verify a sample of the results of completed
+// jobs before returning them. Orders whose results don't match are disputed
+// and refunded instead.
+func WithVerifier(verifier *Verifier) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Verifier = verifier
+	}
+}
+
+// sampled returns whether the order should be verified. Order IDs are hashes,
+// so their last bytes pick a fair sample, and the same orders are picked
+// after a restart.
+func (v *Verifier) sampled(orderID common.Hash) bool {
+	if v.rate >= 1 {
+		return true
+	}
+	position := float64(binary.BigEndian.Uint64(orderID[common.HashLength-8:])) / math.MaxUint64
+	return position < v.rate
+}
+
+// verify checks the completed order against its verification. Orders that
+// haven't been verified, or that are still being verified, are held back
+// until the verification has finished, when they are put back on the queue.
+// Orders whose results don't match are disputed and failed.
+//
+// If held is false, the order should be completed as normal. Otherwise the
+// result is the event to carry on with, if any.
+func (workflow *Workflow) verify(ctx context.Context, event BacalhauJobCompletedEvent) (result Event, wait time.Duration, held bool) {
+	verifier := workflow.Verifier
+	v, err := verifier.Store.Verification(ctx, event.OrderId().Hex())
+	if errors.Is(err, ErrVerificationNotFound) {
+		if !verifier.sampled(event.OrderId()) {
+			return nil, 0, false
+		}
+		return nil, 0, workflow.startVerification(ctx, event)
+	} else if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to check verification")
+		return event, workflow.getRetryTime(event), true
+	}
+
+	switch v.Status {
+	case VerificationStatusPending:
+		return nil, 0, true
+	case VerificationStatusMismatched:
+		if disputer, ok := workflow.Contract.(ResultDisputer); ok && !synthetic(event) {
+			err = disputer.DisputeResult(ctx, event, v.VerifierResult)
+			log.Ctx(ctx).WithLevel(level(err)).Err(err).Msg("Disputing result")
+			if err != nil {
+				result, wait = workflow.settle(ctx, event, nil, 0, err)
+				return result, wait, true
+			}
+		}
+
+		failed := event.FailedWith(FailureReasonVerificationFailure, fmt.Sprintf(
+			"result %s did not match %s when the job was run again", v.OriginalResult, v.VerifierResult,
+		))
+		result, wait = workflow.settle(ctx, event, failed, 0, nil)
+		return result, wait, true
+	default:
+		return nil, 0, false
+	}
+}
+
+// startVerification runs the order's job again on the verifying cluster,
+// returning whether the order should wait for it to finish. If the job can't
+// be run the original result is trusted.
+func (workflow *Workflow) startVerification(ctx context.Context, event BacalhauJobCompletedEvent) bool {
+	v := Verification{
+		OrderID:        event.OrderId().Hex(),
+		Status:         VerificationStatusPending,
+		OriginalResult: event.Result(ctx).String(),
+		Time:           time.Now().UTC(),
+	}
+
+	// The runner changes the event it is passed, which is still the order.
+	data, err := MarshalEvent(event)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to copy order for verification")
+		return false
+	}
+	order, err := UnmarshalEvent(data)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to copy order for verification")
+		return false
+	}
+
+	running, err := workflow.Verifier.Runner.Create(ctx, order.(ContractSubmittedEvent))
+	if err == nil && running == nil {
+		err = errors.New("verifying runner did not submit a job")
+	}
+	if err == nil {
+		v.JobID = running.JobID()
+		v.Event, err = MarshalEvent(running)
+	}
+	if err != nil {
+		v.Status = VerificationStatusInconclusive
+		v.Error = err.Error()
+	}
+
+	saveErr := workflow.Verifier.Store.SaveVerification(ctx, v)
+	if saveErr != nil {
+		log.Ctx(ctx).Error().Err(saveErr).Msg("Unable to save verification")
+		return false
+	}
+	verificationsTotal.WithLabelValues(v.Status.String()).Inc()
+	log.Ctx(ctx).Info().
+		Err(err).
+		Str("job", v.JobID).
+		Stringer("status", v.Status).
+		Msg("Verifying result by running the job again")
+	return v.Status == VerificationStatusPending
+}
+
+// checkVerifications finds the verifying jobs that have finished, records
+// whether they matched, and puts their orders back on the queue.
+func (workflow *Workflow) checkVerifications(ctx context.Context, out chan<- Event) {
+	verifier := workflow.Verifier
+	pending, err := verifier.Store.Verifications(ctx, VerificationStatusPending)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to reload verifications")
+		return
+	} else if len(pending) == 0 {
+		return
+	}
+
+	byOrder := make(map[common.Hash]Verification, len(pending))
+	jobs := make([]BacalhauJobRunningEvent, 0, len(pending))
+	for _, v := range pending {
+		e, err := UnmarshalEvent(v.Event)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", v.OrderID).Msg("Unable to decode verifying job")
+			continue
+		}
+		byOrder[e.OrderId()] = v
+		jobs = append(jobs, e.(BacalhauJobRunningEvent))
+	}
+
+	completed, failed := verifier.Runner.FindCompleted(ctx, jobs)
+	finished := map[common.Hash]bool{}
+	for _, e := range completed {
+		v := byOrder[e.OrderId()]
+		v.VerifierResult = e.Result(ctx).String()
+		if v.VerifierResult == v.OriginalResult {
+			v.Status = VerificationStatusMatched
+		} else {
+			v.Status = VerificationStatusMismatched
+		}
+		finished[e.OrderId()] = workflow.finishVerification(ctx, v)
+	}
+	for _, e := range failed {
+		v := byOrder[e.OrderId()]
+		v.Status = VerificationStatusInconclusive
+		v.Error = e.Error()
+		finished[e.OrderId()] = workflow.finishVerification(ctx, v)
+	}
+	if len(finished) == 0 {
+		return
+	}
+
+	orders, err := Reload[BacalhauJobCompletedEvent](workflow.Repo, OrderStateCompleted)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to reload verified orders")
+		return
+	}
+	for _, order := range orders {
+		if !finished[order.OrderId()] {
+			continue
+		}
+		select {
+		case out <- order:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// finishVerification saves the outcome of the verification, returning whether
+// it was saved.
+func (workflow *Workflow) finishVerification(ctx context.Context, v Verification) bool {
+	v.Time = time.Now().UTC()
+	err := workflow.Verifier.Store.SaveVerification(ctx, v)
+	if err == nil {
+		verificationsTotal.WithLabelValues(v.Status.String()).Inc()
+	}
+
+	lvl := level(err)
+	if err == nil && v.Status == VerificationStatusMismatched {
+		lvl = zerolog.WarnLevel
+	}
+	log.Ctx(ctx).WithLevel(lvl).Err(err).
+		Str("id", v.OrderID).
+		Stringer("status", v.Status).
+		Str("original", v.OriginalResult).
+		Str("verifier", v.VerifierResult).
+		Msg("Verified result")
+	return err == nil
+}
+
+// DisputeResult implements ResultDisputer
+func (r *realContract) DisputeResult(ctx context.Context, event BacalhauJobCompletedEvent, expected string) (err error) {
+	ctx, span := startOrderSpan(ctx, "contract.DisputeResult", event)
+	defer func() { endSpan(span, err) }()
+
+	if err = r.supports(ContractVersion2, "disputes"); err != nil {
+		return err
+	}
+
+	hash, err := r.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return r.contract.LilypadEventsUpgradeableTransactor.DisputeLilypadResult(
+			opts,
+			event.OrderRequestor(),
+			big.NewInt(event.OrderNumber()),
+			event.Result(ctx).String(),
+			expected,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Stringer("txn", hash).Msg("Result disputed")
+	return nil
+}
+
+var _ ResultDisputer = (*realContract)(nil)
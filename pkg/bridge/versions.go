@@ -0,0 +1,87 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bacalhau-project/lilypad/hardhat/artifacts/contracts/LilypadEventsUpgradeable.sol"
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// A ContractVersion is the version of the events and methods that a deployed
+// contract offers the bridge, as returned by its version() method. The bridge
+// works with the latest version and the one before it, so that it can be
+// upgraded before the contracts it watches are.
+type ContractVersion uint64
+
+const (
+	// Contracts from before the contract said its version. Results are
+	// returned one at a time, and orders emit nothing but the job itself.
+	ContractVersion1 ContractVersion = 1
+
+	// Contracts that can return results in batches and with an attested
+	// output hash, decline orders, take part in mediation and disputes, and
+	// emit the encryption key, storage request and price of orders in
+	// events of their own.
+	ContractVersion2 ContractVersion = 2
+
+	// Contracts that keep what was paid for each order, so that it can be
+	// given back when the order fails.
+	ContractVersion3 ContractVersion = 3
+
+	// Contracts that can be paid for orders in an ERC-20 token, and emit the
+	// token and amount paid in an event of their own.
+	ContractVersion4 ContractVersion = 4
+
+	// Contracts that refuse to return the result or error of an order more
+	// than once, and say whether each order has been settled.
+	ContractVersion5 ContractVersion = 5
+)
+
+// The oldest and newest contract versions the bridge works with.
+const (
+	MinContractVersion = ContractVersion1
+	MaxContractVersion = ContractVersion5
+)
+
+var (
+	ErrUnsupportedContractVersion = errors.New("contract version is not supported by this bridge")
+	ErrNotSupportedByContract     = bridgeerrors.MarkTerminal(errors.New("not supported by the deployed contract version"))
+)
+
+// detectContractVersion asks the contract for its version. Contracts without a
+// version() method are version 1.
+func detectContractVersion(ctx context.Context, contract *LilypadEventsUpgradeable.LilypadEventsUpgradeable) (ContractVersion, error) {
+	number, err := contract.LilypadEventsUpgradeableCaller.Version(&bind.CallOpts{Context: ctx})
+	if err != nil && isMissingMethod(err) {
+		return ContractVersion1, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if !number.IsUint64() || ContractVersion(number.Uint64()) > MaxContractVersion || ContractVersion(number.Uint64()) < MinContractVersion {
+		return 0, fmt.Errorf("%w: contract is version %s, but versions %d to %d are supported",
+			ErrUnsupportedContractVersion, number, MinContractVersion, MaxContractVersion)
+	}
+	return ContractVersion(number.Uint64()), nil
+}
+
+// isMissingMethod returns whether the error came from calling a method that
+// the contract doesn't have, which either reverts or returns nothing.
+func isMissingMethod(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "execution reverted") ||
+		strings.Contains(message, "attempting to unmarshall an empty string")
+}
+
+// supports returns an error wrapping ErrNotSupportedByContract if the contract
+// is older than the passed version, which is needed for what is described.
+func (r *realContract) supports(version ContractVersion, what string) error {
+	if r.version < version {
+		return fmt.Errorf("%s: %w (%d, needs %d)", what, ErrNotSupportedByContract, r.version, version)
+	}
+	return nil
+}
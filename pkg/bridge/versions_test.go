@@ -0,0 +1,32 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingVersionMethodMeansVersionOne(t *testing.T) {
+	require.True(t, isMissingMethod(errors.New("execution reverted")))
+	require.True(t, isMissingMethod(errors.New("abi: attempting to unmarshall an empty string while arguments are expected")))
+	require.False(t, isMissingMethod(errors.New("dial tcp 127.0.0.1:8545: connect: connection refused")))
+}
+
+func TestOlderContractsOnlyGetWhatTheySupport(t *testing.T) {
+	old := &realContract{version: ContractVersion1}
+	_, err := old.CompleteBatch(context.Background(), []BacalhauJobCompletedEvent{walEvent(0x01), walEvent(0x02)})
+	require.ErrorIs(t, err, ErrNotSupportedByContract)
+	require.ErrorIs(t, old.DisputeResult(context.Background(), walEvent(0x01), "expected"), ErrNotSupportedByContract)
+	require.ErrorIs(t, old.RequestMediation(context.Background(), Mediation{}), ErrNotSupportedByContract)
+
+	previous := &realContract{version: ContractVersion2}
+	require.ErrorIs(t, previous.supports(ContractVersion3, "refunding payments"), ErrNotSupportedByContract)
+
+	current := &realContract{version: MaxContractVersion}
+	require.NoError(t, current.supports(ContractVersion2, "batched results"))
+	require.NoError(t, current.supports(ContractVersion3, "refunding payments"))
+	require.NoError(t, current.supports(ContractVersion4, "token payments"))
+	require.NoError(t, current.supports(ContractVersion5, "checking settlement"))
+}
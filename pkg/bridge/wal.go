@@ -0,0 +1,321 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A WriteAheadLog durably records every state transition before it is acted
+// on, so that if the bridge stops between an order changing state and that
+// change being saved, the change can be recovered when the bridge restarts.
+type WriteAheadLog interface {
+	// Append records that the event has moved into its current state. The
+	// returned function must be called once the event has been saved to the
+	// repository, after which the record is no longer needed.
+	Append(e Event) (done func(), err error)
+
+	// Intend records that the event is about to be moved into its current
+	// state by something the bridge can't take back, such as a transaction
+	// settling the order. The returned function must be called once the
+	// outcome has been saved to the repository.
+	Intend(e Event) (done func(), err error)
+
+	// Replay returns the most recently recorded version of each order.
+	Replay() ([]WALRecord, error)
+
+	// Checkpoint discards every record. It should only be called once all of
+	// the replayed events have been saved.
+	Checkpoint() error
+
+	Close() error
+}
+
+// A WALRecord is the most recently recorded version of an order.
+type WALRecord struct {
+	Event
+
+	// Whether the order was only about to move into this state, and so may
+	// never have.
+	Intended bool
+}
+
+// Records of intended transitions carry this marker before the event.
+const walIntentMarker = "intent "
+
+// Once the log is bigger than this and every record has been saved, it is
+// emptied so that it doesn't grow forever.
+const defaultWALCompactSize = 16 << 20
+
+type fileWAL struct {
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+	outstanding int
+	compactSize int64
+}
+
+// NewFileWAL opens, or creates, a write-ahead log in the file at the passed
+// path. Each record is a line holding a checksum and the JSON encoding of the
+// event, and is synced to disk before Append or Intend returns.
+func NewFileWAL(path string) (WriteAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &fileWAL{file: file, size: info.Size(), compactSize: defaultWALCompactSize}, nil
+}
+
+// Append implements WriteAheadLog
+func (wal *fileWAL) Append(e Event) (func(), error) {
+	return wal.write(e, "")
+}
+
+// Intend implements WriteAheadLog
+func (wal *fileWAL) Intend(e Event) (func(), error) {
+	return wal.write(e, walIntentMarker)
+}
+
+func (wal *fileWAL) write(e Event, marker string) (func(), error) {
+	event, err := MarshalEvent(e)
+	if err != nil {
+		return func() {}, err
+	}
+
+	data := append([]byte(marker), event...)
+	line := make([]byte, 0, len(data)+10)
+	line = append(line, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(data))...)
+	line = append(line, data...)
+	line = append(line, '\n')
+
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	n, err := wal.file.Write(line)
+	wal.size += int64(n)
+	if err == nil {
+		err = wal.file.Sync()
+	}
+	if err != nil {
+		return func() {}, err
+	}
+
+	wal.outstanding++
+	var once sync.Once
+	return func() { once.Do(wal.saved) }, nil
+}
+
+// saved notes that a record has been saved elsewhere, and empties the log if
+// it has grown too big and nothing in it is still needed.
+func (wal *fileWAL) saved() {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	wal.outstanding--
+	if wal.outstanding == 0 && wal.size > wal.compactSize {
+		if err := wal.truncate(); err != nil {
+			log.Error().Err(err).Str("file", wal.file.Name()).Msg("Unable to compact write-ahead log")
+		}
+	}
+}
+
+// Replay implements WriteAheadLog
+func (wal *fileWAL) Replay() ([]WALRecord, error) {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	if _, err := wal.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	order := []string{}
+	latest := map[string]WALRecord{}
+	var corrupt error
+
+	reader := bufio.NewReader(wal.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A final line without a newline was being written when the
+			// bridge stopped, so its transition was never acted on.
+			break
+		} else if err != nil {
+			return nil, err
+		} else if corrupt != nil {
+			// Only the last record can have been damaged by the bridge
+			// stopping whilst writing it. A damaged record followed by
+			// others means the log itself can't be trusted.
+			return nil, corrupt
+		}
+
+		record, err := parseWALRecord(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			corrupt = err
+			continue
+		}
+
+		id := record.OrderId().Hex()
+		if _, seen := latest[id]; !seen {
+			order = append(order, id)
+		}
+		latest[id] = record
+	}
+
+	if corrupt != nil {
+		log.Warn().Err(corrupt).Str("file", wal.file.Name()).Msg("Skipping corrupt last record of write-ahead log")
+	}
+
+	records := make([]WALRecord, 0, len(order))
+	for _, id := range order {
+		records = append(records, latest[id])
+	}
+	return records, nil
+}
+
+func parseWALRecord(line []byte) (WALRecord, error) {
+	checksum, data, found := bytes.Cut(line, []byte(" "))
+	if !found || len(checksum) != 8 {
+		return WALRecord{}, fmt.Errorf("malformed write-ahead log record")
+	}
+
+	if actual := fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)); actual != string(checksum) {
+		return WALRecord{}, fmt.Errorf("write-ahead log record has checksum %s, expected %s", actual, checksum)
+	}
+
+	intended := bytes.HasPrefix(data, []byte(walIntentMarker))
+	e, err := UnmarshalEvent(bytes.TrimPrefix(data, []byte(walIntentMarker)))
+	return WALRecord{Event: e, Intended: intended}, err
+}
+
+// Checkpoint implements WriteAheadLog
+func (wal *fileWAL) Checkpoint() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	return wal.truncate()
+}
+
+func (wal *fileWAL) truncate() error {
+	if err := wal.file.Truncate(0); err != nil {
+		return err
+	}
+	wal.size = 0
+	return wal.file.Sync()
+}
+
+// Close implements WriteAheadLog
+func (wal *fileWAL) Close() error {
+	return wal.file.Close()
+}
+
+var _ WriteAheadLog = (*fileWAL)(nil)
+
+// writeAhead records the passed transition in the write-ahead log, if there is
+// one, returning the function to call once the event has been saved.
+func (workflow *Workflow) writeAhead(ctx context.Context, e Event) (done func()) {
+	if workflow.WAL == nil {
+		return func() {}
+	}
+
+	done, err := workflow.WAL.Append(e)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Stringer("id", e.OrderId()).Msg("Unable to write ahead transition")
+	}
+	return done
+}
+
+// writeIntent records in the write-ahead log, if there is one, that the order
+// is about to be settled into the passed state, returning the function to call
+// once the outcome has been saved.
+func (workflow *Workflow) writeIntent(ctx context.Context, e Event) (done func()) {
+	if workflow.WAL == nil {
+		return func() {}
+	}
+
+	done, err := workflow.WAL.Intend(e)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Stringer("id", e.OrderId()).Msg("Unable to write ahead intended transition")
+	}
+	return done
+}
+
+// replayWAL saves the latest version of each order found in the write-ahead
+// log to the repository, so that orders whose last transition was never saved
+// are picked up from where they left off. Orders already saved in that state
+// are left alone, and orders that were only about to be settled are only
+// saved as settled if the contract says that they were.
+func (workflow *Workflow) replayWAL(ctx context.Context) error {
+	if workflow.WAL == nil {
+		return nil
+	}
+
+	records, err := workflow.WAL.Replay()
+	if err != nil {
+		return err
+	}
+
+	saved := 0
+	for _, record := range records {
+		stored, err := workflow.stored(ctx, record.Event)
+		if err != nil {
+			return err
+		} else if stored {
+			continue
+		}
+
+		if record.Intended {
+			if err := workflow.checkSettled(ctx, record.Event); !errors.Is(err, ErrAlreadySettled) {
+				log.Ctx(ctx).Warn().Err(err).
+					Stringer("id", record.OrderId()).
+					Stringer("state", record.OrderState()).
+					Msg("Not replaying settlement that may not have happened")
+				continue
+			}
+		}
+
+		if err := workflow.Repo.Save(record.Event); err != nil {
+			return err
+		}
+		saved++
+	}
+
+	log.Ctx(ctx).Info().Int("count", len(records)).Int("saved", saved).Msg("Replayed write-ahead log")
+	return workflow.WAL.Checkpoint()
+}
+
+// stored returns whether the order has already been saved as it is in the
+// passed event, so that saving it again would only repeat its history.
+// Repositories that can't say are taken not to have saved it.
+func (workflow *Workflow) stored(ctx context.Context, e Event) (bool, error) {
+	orders, ok := workflow.Repo.(OrderStore)
+	if !ok {
+		return false, nil
+	}
+
+	order, err := orders.Order(ctx, e.OrderId())
+	if errors.Is(err, ErrOrderNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if order.State != e.OrderState().String() {
+		return false, nil
+	}
+	submitted, ok := e.(ContractSubmittedEvent)
+	return !ok || order.Resubmissions == submitted.Resubmissions(), nil
+}
@@ -0,0 +1,174 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/bacalhau/pkg/model"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func walEvent(id byte) *event {
+	e := goldenEvent()
+	e.orderId = bytes.Repeat([]byte{id}, 32)
+	return e
+}
+
+func fileWALForTest(t *testing.T) (*fileWAL, string) {
+	path := filepath.Join(t.TempDir(), "test.wal")
+	wal, err := NewFileWAL(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { wal.Close() })
+	return wal.(*fileWAL), path
+}
+
+func TestWALReplaysLatestVersionOfEachOrder(t *testing.T) {
+	wal, _ := fileWALForTest(t)
+
+	first, second := walEvent(1), walEvent(2)
+	_, err := wal.Append(first)
+	require.NoError(t, err)
+	_, err = wal.Append(second)
+	require.NoError(t, err)
+	_, err = wal.Append(first.JobCreated(model.NewJob()))
+	require.NoError(t, err)
+	_, err = wal.Append(first.Completed(cid.Cid{}, "", "", 0))
+	require.NoError(t, err)
+
+	events, err := wal.Replay()
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, first.OrderId(), events[0].OrderId())
+	require.Equal(t, OrderStateCompleted, events[0].OrderState())
+	require.Equal(t, second.OrderId(), events[1].OrderId())
+	require.Equal(t, OrderStateSubmitted, events[1].OrderState())
+
+	require.NoError(t, wal.Checkpoint())
+	events, err = wal.Replay()
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestWALIgnoresTornRecord(t *testing.T) {
+	wal, path := fileWALForTest(t)
+	_, err := wal.Append(walEvent(1))
+	require.NoError(t, err)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`01234567 {"version":1,"sta`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	events, err := wal.Replay()
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestWALSkipsCorruptLastRecord(t *testing.T) {
+	wal, path := fileWALForTest(t)
+	_, err := wal.Append(walEvent(1))
+	require.NoError(t, err)
+	_, err = wal.Append(walEvent(2))
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	last := bytes.IndexByte(contents, '\n') + 1
+	contents[last] ^= 1
+	require.NoError(t, os.WriteFile(path, contents, 0600))
+
+	events, err := wal.Replay()
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, walEvent(1).OrderId(), events[0].OrderId())
+
+	contents[0] ^= 1
+	contents[last] ^= 1
+	require.NoError(t, os.WriteFile(path, contents, 0600))
+
+	_, err = wal.Replay()
+	require.Error(t, err, "a corrupt record followed by others should not be skipped")
+}
+
+func TestWALRecordsIntendedTransitions(t *testing.T) {
+	wal, _ := fileWALForTest(t)
+	completed := walEvent(1).JobCreated(model.NewJob()).Completed(cid.Cid{}, "", "", 0)
+	_, err := wal.Append(completed)
+	require.NoError(t, err)
+	_, err = wal.Intend(completed.Paid())
+	require.NoError(t, err)
+
+	events, err := wal.Replay()
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, OrderStatePaid, events[0].OrderState())
+	require.True(t, events[0].Intended)
+
+	_, err = wal.Append(completed)
+	require.NoError(t, err)
+	events, err = wal.Replay()
+	require.NoError(t, err)
+	require.Equal(t, OrderStateCompleted, events[0].OrderState())
+	require.False(t, events[0].Intended)
+}
+
+func TestWALReplayOnlySavesWhatIsMissing(t *testing.T) {
+	ctx := context.Background()
+	wal, _ := fileWALForTest(t)
+	repo := repository(t)
+
+	stored := walEvent(1)
+	require.NoError(t, repo.Save(stored))
+	_, err := wal.Append(stored)
+	require.NoError(t, err)
+
+	missing := walEvent(2)
+	_, err = wal.Append(missing)
+	require.NoError(t, err)
+
+	unsettled := walEvent(3).JobCreated(model.NewJob()).Completed(cid.Cid{}, "", "", 0)
+	require.NoError(t, repo.Save(unsettled))
+	_, err = wal.Intend(unsettled.Paid())
+	require.NoError(t, err)
+
+	workflow := NewWorkflow(&mockRunner{}, mockContract{}, repo, WithWriteAheadLog(wal))
+	require.NoError(t, workflow.replayWAL(ctx))
+
+	orders := repo.(OrderStore)
+	order, err := orders.Order(ctx, stored.OrderId())
+	require.NoError(t, err)
+	require.Len(t, order.Transitions, 1, "orders already saved should not be saved again")
+
+	order, err = orders.Order(ctx, missing.OrderId())
+	require.NoError(t, err)
+	require.Equal(t, OrderStateSubmitted.String(), order.State)
+
+	order, err = orders.Order(ctx, unsettled.OrderId())
+	require.NoError(t, err)
+	require.Equal(t, OrderStateCompleted.String(), order.State, "settlements the contract can't vouch for should not be replayed")
+}
+
+func TestWALIsCompactedOnceSaved(t *testing.T) {
+	wal, path := fileWALForTest(t)
+	wal.compactSize = 0
+
+	first, err := wal.Append(walEvent(1))
+	require.NoError(t, err)
+	second, err := wal.Append(walEvent(2))
+	require.NoError(t, err)
+
+	first()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NotZero(t, info.Size(), "should not compact while a record is unsaved")
+
+	second()
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+}
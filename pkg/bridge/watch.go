@@ -0,0 +1,126 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// A JobWatcher is a JobRunner that can tell the workflow as soon as a job
+// changes state, so that finished jobs are picked up without waiting for the
+// next poll. Polling still happens as normal, so a watcher that misses an event
+// or loses its connection only makes the bridge slower to notice a change.
+type JobWatcher interface {
+	// Watch sends the ID of each job that changes state to the passed channel.
+	// It blocks until the context is cancelled.
+	Watch(ctx context.Context, changed chan<- string) error
+}
+
+// The requester API endpoint that streams job events over a websocket.
+const jobEventsPath = "/requester/websocket/events"
+
+// How long to wait before reconnecting a dropped websocket at most.
+const maxWatchBackoff = time.Minute
+
+var watchPolicy = BackoffPolicy{
+	Backoff: time.Second,
+	Jitter:  0.2,
+}
+
+// Watch implements JobWatcher
+func (runner *bacalhauRunner) Watch(ctx context.Context, changed chan<- string) error {
+	if !runner.watch {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, ep := range runner.endpoints {
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+			runner.watchEndpoint(ctx, ep, changed)
+		}(ep)
+	}
+	wg.Wait()
+	return nil
+}
+
+// watchEndpoint follows the job event stream of a single endpoint, reconnecting
+// whenever the connection drops, until the context is cancelled.
+func (runner *bacalhauRunner) watchEndpoint(ctx context.Context, ep *endpoint, changed chan<- string) {
+	url := strings.Replace(ep.URL, "http", "ws", 1) + jobEventsPath
+	for attempt := uint(0); ; attempt++ {
+		connected, err := runner.watchOnce(ctx, url, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			attempt = 0
+		}
+
+		wait := watchPolicy.Wait(attempt + 1)
+		if wait > maxWatchBackoff {
+			wait = maxWatchBackoff
+		}
+		log.Ctx(ctx).Warn().Err(err).Str("endpoint", ep.URL).Dur("wait", wait).Msg("Bacalhau job event stream lost, falling back to polling")
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchOnce connects to the job event stream and forwards events until the
+// connection drops, returning whether the connection was ever made.
+func (runner *bacalhauRunner) watchOnce(ctx context.Context, url string, changed chan<- string) (bool, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = runner.tlsConfig
+	conn, _, err := dialer.DialContext(ctx, url, runner.header)
+	observeAPICall("watch", err)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	log.Ctx(ctx).Info().Str("url", url).Msg("Watching Bacalhau job events")
+	for {
+		var event struct {
+			JobID string `json:"JobID"`
+		}
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+
+		if err := json.Unmarshal(msg, &event); err != nil || event.JobID == "" {
+			log.Ctx(ctx).Debug().Err(err).Msg("Ignoring unrecognised job event")
+			continue
+		}
+
+		select {
+		case changed <- event.JobID:
+		case <-ctx.Done():
+			return true, nil
+		}
+	}
+}
+
+var _ JobWatcher = (*bacalhauRunner)(nil)
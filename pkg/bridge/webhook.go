@@ -0,0 +1,293 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers sent with every webhook request. The signature is only sent if the
+// webhook has a secret.
+const (
+	WebhookDeliveryHeader  = "X-Lilypad-Delivery"
+	WebhookTimestampHeader = "X-Lilypad-Timestamp"
+	WebhookSignatureHeader = "X-Lilypad-Signature"
+)
+
+// A DeliveryStatus describes how far a webhook delivery has got.
+//
+//go:generate stringer -type=DeliveryStatus --trimprefix=DeliveryStatus
+type DeliveryStatus int
+
+const (
+	// The webhook has not accepted the notification yet but will be retried.
+	DeliveryStatusPending DeliveryStatus = iota
+	// The webhook accepted the notification.
+	DeliveryStatusDelivered
+	// The webhook did not accept the notification and won't be retried.
+	DeliveryStatusFailed
+)
+
+// A Delivery records an attempt to notify a webhook of an order state change.
+type Delivery struct {
+	ID           string         `json:"id"`
+	URL          string         `json:"url"`
+	OrderID      string         `json:"orderId"`
+	State        string         `json:"state"`
+	Status       DeliveryStatus `json:"status"`
+	Attempts     uint           `json:"attempts"`
+	ResponseCode int            `json:"responseCode,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	Time         time.Time      `json:"time"`
+}
+
+// A DeliveryStore keeps the status of webhook deliveries so that failed
+// notifications can be found and investigated.
+type DeliveryStore interface {
+	// RecordDelivery saves the delivery, replacing any with the same ID.
+	RecordDelivery(ctx context.Context, d Delivery) error
+
+	// Deliveries returns every delivery made for the passed order.
+	Deliveries(ctx context.Context, orderID string) ([]Delivery, error)
+}
+
+// SignWebhook returns the signature sent with a webhook request with the
+// passed timestamp and body: the hex HMAC-SHA256 of "<timestamp>.<body>"
+// using the shared secret, prefixed by "sha256=". Receivers should compute the
+// same and compare it with hmac.Equal.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var defaultWebhookPolicy = BackoffPolicy{
+	MaxAttempts: 5,
+	Backoff:     time.Second,
+	Jitter:      0.2,
+}
+
+type webhookSubscriber struct {
+	url    string
+	client *http.Client
+	secret []byte
+	policy BackoffPolicy
+	states map[string]bool
+	store  DeliveryStore
+}
+
+// A WebhookOption configures the subscriber returned by NewWebhookSubscriber.
+type WebhookOption func(*webhookSubscriber)
+
+// WithWebhookSecret signs every request with the passed shared secret, so
+// that the receiver can check that the notification came from this bridge.
+func WithWebhookSecret(secret []byte) WebhookOption {
+	return func(w *webhookSubscriber) {
+		w.secret = secret
+	}
+}
+
+// WithWebhookRetry sets how many times, and how often, a notification is sent
+// before the delivery is given up on.
+func WithWebhookRetry(policy BackoffPolicy) WebhookOption {
+	return func(w *webhookSubscriber) {
+		w.policy = policy
+	}
+}
+
+// WithWebhookStates only sends notifications of orders moving into one of the
+// passed states. By default, every state change is sent.
+func WithWebhookStates(states ...OrderState) WebhookOption {
+	return func(w *webhookSubscriber) {
+		w.states = map[string]bool{}
+		for _, state := range states {
+			w.states[state.String()] = true
+		}
+	}
+}
+
+// WithDeliveryStore records the status of every delivery in the passed store.
+func WithDeliveryStore(store DeliveryStore) WebhookOption {
+	return func(w *webhookSubscriber) {
+		w.store = store
+	}
+}
+
+// NewWebhookSubscriber returns a Subscriber that POSTs each notification as
+// JSON to the passed URL, retrying if the webhook is unavailable.
+func NewWebhookSubscriber(url string, opts ...WebhookOption) Subscriber {
+	w := &webhookSubscriber{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		policy: defaultWebhookPolicy,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Notify implements Subscriber
+func (w *webhookSubscriber) Notify(ctx context.Context, n Notification) error {
+	// Webhooks are for changes of state, so heartbeats aren't delivered.
+	if n.Progress != nil || (w.states != nil && !w.states[n.State]) {
+		return nil
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	delivery := Delivery{
+		ID:      newDeliveryID(),
+		URL:     w.url,
+		OrderID: n.OrderID,
+		State:   n.State,
+		Status:  DeliveryStatusPending,
+	}
+
+	maxAttempts := w.policy.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 1
+	}
+
+	for attempt := uint(0); delivery.Status == DeliveryStatusPending; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.policy.Wait(attempt)):
+		}
+
+		delivery.Attempts = attempt + 1
+		delivery.ResponseCode, err = w.send(ctx, delivery.ID, body)
+		delivery.Time = time.Now().UTC()
+		delivery.Error = ""
+
+		if err == nil {
+			delivery.Status = DeliveryStatusDelivered
+		} else {
+			delivery.Error = err.Error()
+			if !retryableResponse(delivery.ResponseCode) || delivery.Attempts >= maxAttempts {
+				delivery.Status = DeliveryStatusFailed
+			}
+		}
+
+		w.record(ctx, delivery)
+	}
+	return err
+}
+
+// send makes a single request to the webhook, returning the response code if
+// there was a response.
+func (w *webhookSubscriber) send(ctx context.Context, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.secret, timestamp, body))
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("webhook %s returned %s", w.url, res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func (w *webhookSubscriber) record(ctx context.Context, d Delivery) {
+	if w.store == nil {
+		return
+	}
+	if err := w.store.RecordDelivery(ctx, d); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("delivery", d.ID).Msg("Unable to record webhook delivery")
+	}
+}
+
+// retryableResponse returns whether a request that got the passed response
+// code, or no response at all, could succeed if made again.
+func retryableResponse(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+func newDeliveryID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// SubscribersFromEnv returns a webhook subscriber for each of the URLs in the
+// comma separated WEBHOOK_URLS. Requests are signed with WEBHOOK_SECRET, made
+// up to WEBHOOK_MAX_ATTEMPTS times, and only sent for the comma separated
+// WEBHOOK_STATES if set. Deliveries are recorded in the passed store, which
+// may be nil.
+func SubscribersFromEnv(store DeliveryStore) ([]Subscriber, error) {
+	opts := []WebhookOption{WithDeliveryStore(store)}
+
+	if secret, found := os.LookupEnv("WEBHOOK_SECRET"); found && secret != "" {
+		opts = append(opts, WithWebhookSecret([]byte(secret)))
+	}
+
+	if str, found := os.LookupEnv("WEBHOOK_MAX_ATTEMPTS"); found && str != "" {
+		value, err := strconv.ParseUint(str, 10, 32)
+		if err != nil || value == 0 {
+			return nil, errors.Errorf("WEBHOOK_MAX_ATTEMPTS: must be a positive integer, got %q", str)
+		}
+		policy := defaultWebhookPolicy
+		policy.MaxAttempts = uint(value)
+		opts = append(opts, WithWebhookRetry(policy))
+	}
+
+	if str, found := os.LookupEnv("WEBHOOK_STATES"); found && str != "" {
+		states, err := parseOrderStates(str)
+		if err != nil {
+			return nil, errors.Wrap(err, "WEBHOOK_STATES")
+		}
+		opts = append(opts, WithWebhookStates(states...))
+	}
+
+	subscribers := []Subscriber{}
+	for _, url := range parseEndpoints(os.Getenv("WEBHOOK_URLS")) {
+		subscribers = append(subscribers, NewWebhookSubscriber(url, opts...))
+	}
+	return subscribers, nil
+}
+
+// parseOrderStates parses a comma separated list of order state names.
+func parseOrderStates(str string) ([]OrderState, error) {
+	states := []OrderState{}
+	for _, name := range strings.Split(str, ",") {
+		state, err := ParseOrderState(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
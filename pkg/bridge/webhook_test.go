@@ -0,0 +1,133 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var immediateWebhookPolicy = BackoffPolicy{MaxAttempts: 3}
+
+func TestWebhookSubscriber(t *testing.T) {
+	received := make(chan Notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		received <- n
+	}))
+	defer server.Close()
+
+	bus := NewEventBus()
+	defer bus.Subscribe(NewWebhookSubscriber(server.URL))()
+
+	e := exampleEvent()
+	bus.Publish(context.Background(), e)
+	require.Equal(t, e.OrderId().Hex(), receive(t, received).OrderID)
+}
+
+func TestWebhookIsSigned(t *testing.T) {
+	secret := []byte("shared secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NotEmpty(t, r.Header.Get(WebhookDeliveryHeader))
+
+		expected := SignWebhook(secret, r.Header.Get(WebhookTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	sub := NewWebhookSubscriber(server.URL, WithWebhookSecret(secret))
+	require.NoError(t, sub.Notify(context.Background(), newNotification(context.Background(), exampleEvent())))
+
+	sub = NewWebhookSubscriber(server.URL, WithWebhookSecret([]byte("wrong secret")))
+	require.Error(t, sub.Notify(context.Background(), newNotification(context.Background(), exampleEvent())))
+}
+
+func TestWebhookDeliveriesAreRetriedAndRecorded(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	store := repository(t).(DeliveryStore)
+	sub := NewWebhookSubscriber(server.URL, WithWebhookRetry(immediateWebhookPolicy), WithDeliveryStore(store))
+
+	n := newNotification(context.Background(), exampleEvent())
+	require.NoError(t, sub.Notify(context.Background(), n))
+
+	deliveries, err := store.Deliveries(context.Background(), n.OrderID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, DeliveryStatusDelivered, deliveries[0].Status)
+	require.Equal(t, uint(3), deliveries[0].Attempts)
+	require.Equal(t, http.StatusOK, deliveries[0].ResponseCode)
+	require.Equal(t, "Submitted", deliveries[0].State)
+}
+
+func TestWebhookClientErrorsAreNotRetried(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	store := repository(t).(DeliveryStore)
+	sub := NewWebhookSubscriber(server.URL, WithWebhookRetry(immediateWebhookPolicy), WithDeliveryStore(store))
+
+	n := newNotification(context.Background(), exampleEvent())
+	require.Error(t, sub.Notify(context.Background(), n))
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	deliveries, err := store.Deliveries(context.Background(), n.OrderID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, DeliveryStatusFailed, deliveries[0].Status)
+	require.NotEmpty(t, deliveries[0].Error)
+}
+
+func TestWebhookStates(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	sub := NewWebhookSubscriber(server.URL, WithWebhookStates(OrderStatePaid, OrderStateRefunded))
+	require.NoError(t, sub.Notify(context.Background(), newNotification(context.Background(), exampleEvent())))
+	require.Equal(t, int32(0), atomic.LoadInt32(&requests))
+
+	require.NoError(t, sub.Notify(context.Background(), Notification{State: "Paid"}))
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestSubscribersFromEnvironment(t *testing.T) {
+	t.Setenv("WEBHOOK_URLS", "http://a.example.com, http://b.example.com")
+	t.Setenv("WEBHOOK_STATES", "paid,Refunded")
+
+	subscribers, err := SubscribersFromEnv(nil)
+	require.NoError(t, err)
+	require.Len(t, subscribers, 2)
+	require.Equal(t, map[string]bool{"Paid": true, "Refunded": true}, subscribers[0].(*webhookSubscriber).states)
+
+	t.Setenv("WEBHOOK_STATES", "Finished")
+	_, err = SubscribersFromEnv(nil)
+	require.Error(t, err)
+
+	t.Setenv("WEBHOOK_STATES", "")
+	t.Setenv("WEBHOOK_MAX_ATTEMPTS", "0")
+	_, err = SubscribersFromEnv(nil)
+	require.Error(t, err)
+}
@@ -3,12 +3,18 @@ package bridge
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	bridgeerrors "github.com/bacalhau-project/lilypad/pkg/bridge/errors"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-co-op/gocron"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.ptx.dk/multierrgroup"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
 // A Workflow is an active component that runs the entire process of an order
@@ -37,30 +43,296 @@ type Workflow struct {
 	Contract SmartContract
 	Repo     Repository
 
+	// If set, results of completed jobs are downloaded and kept off-chain,
+	// no more than resultFetchConcurrency at once.
+	Results  ResultFetcher
+	fetching chan struct{}
+
+	// If set, every change in the state of an order is published here.
+	Events *EventBus
+
+	// If set, every change in the state of an order is written here before
+	// it is saved, and replayed when the workflow starts.
+	WAL WriteAheadLog
+
+	// If set, the jobs submitted for each order are recorded here, so that an
+	// order seen twice is only submitted once.
+	Submissions SubmissionStore
+
+	// If set, orders that can't be refunded are retried and then moved here,
+	// rather than being dropped after a single attempt.
+	DeadLetters DeadLetterQueue
+
+	// If set, only orders in partitions claimed by this bridge are worked on,
+	// so that the orders can be shared between several bridges.
+	Partitioner *Partitioner
+
+	// If set, the mediator is run alongside the workflow, and may be sent
+	// the orders whose jobs fail for good.
+	Mediator        *Mediator
+	mediateFailures bool
+
+	// If set, a sample of completed jobs are run again on another cluster,
+	// and their results are only returned if they match.
+	Verifier *Verifier
+
+	// If set, the canonical hash of the output of each completed job is
+	// worked out and returned on-chain with its result.
+	Hasher OutputHasher
+
+	// If set, orders can ask for their results to be encrypted with their
+	// creator's public key. Otherwise such orders are rejected.
+	Encryption *ResultEncryption
+
+	// If set, orders can ask for a Filecoin storage deal to be made for their
+	// results. Otherwise such orders are rejected.
+	Deals DealMaker
+
+	// If set, the IPFS inputs of each job are checked before it is
+	// submitted, and orders whose inputs can't be retrieved are failed.
+	Inputs InputChecker
+
+	// If set, orders can ask for one of these job templates to be run
+	// instead of passing a whole spec. Otherwise such orders are rejected.
+	Templates TemplateSource
+
+	// If set, orders that offer less than the minimum price for their job
+	// are declined.
+	Pricing *PricingPolicy
+
+	// If set, orders from addresses that it doesn't permit are refused.
+	Addresses *AddressList
+
+	// If set, the results of completed jobs are pinned so that they stay
+	// available on IPFS.
+	Pins *PinManager
+
+	// If set, the transactions that settle orders are followed until they
+	// are final.
+	Postings *PostingTracker
+
+	// If set, each order is locked in a store shared with other bridges
+	// before it is settled, so that it can't be settled twice.
+	Locks *PostingLocker
+
+	// If set, every decision made about an order is recorded here.
+	Audit AuditLog
+
+	// If set, orders for the same spec as a job that completed recently are
+	// given its result rather than run again.
+	JobCache    JobCache
+	jobCacheTTL time.Duration
+
+	// If set, the workflow abuses itself so that soak tests can check that
+	// it recovers.
+	Chaos *Chaos
+
 	scheduler        *gocron.Scheduler
 	getRetryTime     RetryStrategy
 	jobCheckInterval time.Duration
+	resubmitPolicy   BackoffPolicy
+	submitLimiter    *rate.Limiter
+	priorities       PriorityPolicy
+
+	// If the contract can return several results in one transaction, how
+	// long to wait for more results to send with the first, and how many to
+	// send at most.
+	batchWindow time.Duration
+	batchSize   int
+
+	// How long events being processed when the workflow is stopped are given
+	// to finish before they are cancelled.
+	shutdownGracePeriod time.Duration
+
+	// Events added from outside the contract, such as requeued dead letters
+	// and synthetic orders, waiting to go on the queue.
+	injected chan Event
+
+	// When maintenance mode was entered, or nil if the workflow isn't in
+	// maintenance mode.
+	maintenance atomic.Pointer[time.Time]
+
+	// Held whilst submitting an order, so that it can't be submitted twice.
+	orderLocks orderLocks
+
+	// A slot for each running job, if only so many can run at once.
+	slots jobSlots
+
+	// How many orders each client may have running and submit an hour, and
+	// when each client's orders were submitted in the last hour.
+	clientQuotas ClientQuotas
+	clientRates  clientRates
+
+	// Held whilst checking running jobs, so that jobs aren't found to be
+	// finished twice by checks triggered from different places.
+	checkMu sync.Mutex
+
+	// The progress of the running jobs as of the last check, and how often
+	// it is published.
+	progress          jobProgress
+	heartbeatInterval time.Duration
+
+	// The scheduled check of running jobs, and a lock held whilst it or the
+	// other settings that can be reloaded are being changed.
+	checkJob *gocron.Job
+	reloadMu sync.Mutex
 }
 
+// How many submitted events can wait for the rate limiter at once, and how long
+// to wait before trying again if there are more.
+const (
+	submitQueueSize      = 256
+	submitQueueRetryTime = time.Second
+)
+
+// How often running jobs are checked for orders that another bridge has
+// settled, and how many orders the contract is asked about at once.
+var (
+	settledCheckInterval    = time.Minute
+	settledCheckConcurrency = 4
+)
+
+// How many results are downloaded at once, across every order.
+const resultFetchConcurrency = 4
+
+// How long to wait before trying again to save a new order whilst the
+// repository can't be used.
+var repoRetryTime = 5 * time.Second
+
+// How long to wait before trying again to post an order whilst the daily gas
+// budget is spent or the wallet is low on funds.
+var gasBudgetRetryTime = 5 * time.Minute
+
 var (
-	defaultJobCheckInterval time.Duration = 5 * time.Second
-	defaultRetryStrategy    RetryStrategy = Exponential
+	defaultJobCheckInterval    time.Duration = 5 * time.Second
+	defaultShutdownGracePeriod time.Duration = 30 * time.Second
+	defaultRetryStrategy       RetryStrategy = Exponential
+	defaultResubmitPolicy      BackoffPolicy = BackoffPolicy{
+		MaxAttempts: 3,
+		Backoff:     30 * time.Second,
+		Jitter:      0.2,
+	}
 )
 
-func NewWorkflow(jr JobRunner, sc SmartContract, repo Repository) *Workflow {
-	return &Workflow{
+// A WorkflowOption configures the workflow returned by NewWorkflow.
+type WorkflowOption func(*Workflow)
+
+// WithJobCheckInterval sets how often the workflow polls for the state of
+// running jobs.
+func WithJobCheckInterval(interval time.Duration) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.jobCheckInterval = interval
+	}
+}
+
+// WithResultFetcher sets where the workflow keeps copies of job results.
+func WithResultFetcher(fetcher ResultFetcher) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Results = fetcher
+	}
+}
+
+// WithResubmitPolicy sets how many jobs are run for an order, and how long to
+// wait between them, before a failing order is given up on and refunded.
+func WithResubmitPolicy(policy BackoffPolicy) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.resubmitPolicy = policy
+	}
+}
+
+// WithSubmitRateLimit stops the workflow submitting more than perSecond jobs a
+// second, averaged over bursts of up to burst jobs. A rate of zero or less
+// means there is no limit.
+func WithSubmitRateLimit(perSecond float64, burst int) WorkflowOption {
+	return func(workflow *Workflow) {
+		if perSecond <= 0 {
+			workflow.submitLimiter = nil
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		workflow.submitLimiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+}
+
+// WithEventBus sets where the workflow publishes changes in order state.
+func WithEventBus(bus *EventBus) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Events = bus
+	}
+}
+
+// WithSubmissionStore sets where the workflow records submitted jobs, so that
+// duplicate orders return the existing job rather than submitting another.
+func WithSubmissionStore(store SubmissionStore) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Submissions = store
+	}
+}
+
+// WithDeadLetterQueue sets where the workflow keeps orders it has given up on.
+func WithDeadLetterQueue(dlq DeadLetterQueue) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.DeadLetters = dlq
+	}
+}
+
+// WithPartitioner makes the workflow only work on the orders in the partitions
+// claimed by this bridge. Orders in partitions claimed from another bridge are
+// reloaded from the shared repository and carried on from where they were.
+func WithPartitioner(partitioner *Partitioner) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.Partitioner = partitioner
+	}
+}
+
+// WithWriteAheadLog sets where the workflow records changes in order state
+// before acting on them.
+func WithWriteAheadLog(wal WriteAheadLog) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.WAL = wal
+	}
+}
+
+// WithShutdownGracePeriod sets how long events that are being processed when
+// the workflow is stopped are given to finish before they are cancelled.
+func WithShutdownGracePeriod(grace time.Duration) WorkflowOption {
+	return func(workflow *Workflow) {
+		workflow.shutdownGracePeriod = grace
+	}
+}
+
+func NewWorkflow(jr JobRunner, sc SmartContract, repo Repository, opts ...WorkflowOption) *Workflow {
+	workflow := &Workflow{
 		Bacalhau:         jr,
 		Contract:         sc,
 		Repo:             repo,
 		scheduler:        gocron.NewScheduler(time.UTC),
 		getRetryTime:     defaultRetryStrategy,
 		jobCheckInterval: defaultJobCheckInterval,
+		resubmitPolicy:   defaultResubmitPolicy,
+		injected:         make(chan Event, 256),
+		fetching:         make(chan struct{}, resultFetchConcurrency),
+
+		shutdownGracePeriod: defaultShutdownGracePeriod,
 	}
+
+	for _, opt := range opts {
+		opt(workflow)
+	}
+	return workflow
 }
 
 // Start spins up all of the goroutines that will generate and process items in
-// the workflow. It will block until the passed context is cancelled.
+// the workflow. It will block until the passed context is cancelled and any
+// events that were being processed have finished or been given up on.
 func (workflow *Workflow) Start(ctx context.Context) error {
+	if err := workflow.replayWAL(ctx); err != nil {
+		return err
+	}
+	defer workflow.Chaos.skewClock()()
+
 	wg := multierrgroup.Group{}
 
 	submittedEvents := make(chan ContractSubmittedEvent)
@@ -71,6 +343,37 @@ func (workflow *Workflow) Start(ctx context.Context) error {
 	wg.Go(func() error { return workflow.Run(ctx, newEvents) })
 	wg.Go(func() error { return workflow.Contract.Listen(ctx, submittedEvents) })
 	wg.Go(func() error { return workflow.deduplicateSubmittedEvents(ctx, submittedEvents, newEvents) })
+	wg.Go(func() error {
+		for {
+			select {
+			case e := <-workflow.injected:
+				select {
+				case newEvents <- e:
+				case <-ctx.Done():
+					return nil
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	if workflow.Partitioner != nil {
+		// Keep hold of the partitions until the events being worked on have
+		// finished, so that no other bridge picks them up in the meantime.
+		partitionCtx, stopPartitioning := context.WithCancel(detachedContext{parent: ctx})
+		partitioning := make(chan struct{})
+		go func() {
+			defer close(partitioning)
+			_ = workflow.Partitioner.Run(partitionCtx, func(partitions []uint) {
+				workflow.reloadPartitions(ctx, partitions, newEvents)
+			})
+		}()
+		defer func() {
+			stopPartitioning()
+			<-partitioning
+		}()
+	}
+
 	wg.Go(func() error {
 		workflow.scheduler.StartAsync()
 		<-ctx.Done()
@@ -79,24 +382,79 @@ func (workflow *Workflow) Start(ctx context.Context) error {
 		return nil
 	})
 
-	_, err := workflow.scheduler.Every(workflow.jobCheckInterval).Do(func() {
+	workflow.reloadMu.Lock()
+	checkJob, err := workflow.scheduler.Every(workflow.jobCheckInterval).Do(func() {
 		workflow.checkRunningEvents(ctx, newEvents)
 	})
+	workflow.checkJob = checkJob
+	workflow.reloadMu.Unlock()
 	if err != nil {
 		return err
 	}
 
+	if _, ok := workflow.Contract.(SettlementChecker); ok {
+		_, err = workflow.scheduler.Every(settledCheckInterval).SingletonMode().Do(func() {
+			workflow.cancelSettledElsewhere(ctx, newEvents)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if workflow.Verifier != nil {
+		_, err = workflow.scheduler.Every(workflow.jobCheckInterval).Do(func() {
+			workflow.checkVerifications(ctx, newEvents)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if workflow.Pins != nil {
+		_, err = workflow.scheduler.Every(workflow.jobCheckInterval).Do(func() {
+			workflow.Pins.check(ctx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if workflow.Postings != nil {
+		_, err = workflow.scheduler.Every(workflow.jobCheckInterval).Do(func() {
+			workflow.Postings.check(ctx, workflow.Events)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if watcher, ok := workflow.Bacalhau.(JobWatcher); ok {
+		changed := make(chan string, 256)
+		wg.Go(func() error { return watcher.Watch(ctx, changed) })
+		wg.Go(func() error { return workflow.checkChangedEvents(ctx, changed, newEvents) })
+	}
+
+	if watcher, ok := workflow.Contract.(ReorgWatcher); ok {
+		removed := make(chan common.Hash, 256)
+		wg.Go(func() error { return watcher.WatchReorgs(ctx, removed) })
+		wg.Go(func() error { return workflow.abandonReorgedOrders(ctx, removed) })
+	}
+
+	if workflow.Mediator != nil {
+		wg.Go(func() error { return workflow.Mediator.Run(ctx) })
+	}
+
 	wg.Go(func() error {
-		return ReloadToChan[ContractSubmittedEvent](workflow.Repo, OrderStateSubmitted, newEvents)
+		return ReloadToChan[ContractSubmittedEvent](ctx, workflow.Repo, OrderStateSubmitted, newEvents)
 	})
 	wg.Go(func() error {
-		return ReloadToChan[ContractFailedEvent](workflow.Repo, OrderStateFailed, newEvents)
+		return ReloadToChan[ContractFailedEvent](ctx, workflow.Repo, OrderStateFailed, newEvents)
 	})
 	wg.Go(func() error {
-		return ReloadToChan[BacalhauJobCompletedEvent](workflow.Repo, OrderStateCompleted, newEvents)
+		return ReloadToChan[BacalhauJobCompletedEvent](ctx, workflow.Repo, OrderStateCompleted, newEvents)
 	})
 	wg.Go(func() error {
-		return ReloadToChan[BacalhauJobFailedEvent](workflow.Repo, OrderStateJobError, newEvents)
+		return ReloadToChan[BacalhauJobFailedEvent](ctx, workflow.Repo, OrderStateJobError, newEvents)
 	})
 
 	log.Ctx(ctx).Info().Msg("Bridge ready")
@@ -106,38 +464,166 @@ func (workflow *Workflow) Start(ctx context.Context) error {
 }
 
 // Run processes events on the work queue, transitioning them through the state
-// machine. It will block until the passed context is cancelled.
+// machine. It will block until the passed context is cancelled and the events
+// being processed at the time have finished.
+//
+// Events still on the queue when the context is cancelled are left alone, as
+// they have already been saved in their current state and will be reloaded when
+// the workflow is next started.
 func (workflow *Workflow) Run(ctx context.Context, newEvents <-chan Event) (err error) {
+	workCtx, cancelWork := workflow.drainContext(ctx)
+	defer cancelWork()
+
 	processedEvents := make(chan Event, 256)
 
+	// If submissions are rate limited, new jobs are handed to a separate
+	// queue so that waiting to submit doesn't hold up every other event.
+	// Orders wait in a lane for their priority.
+	submissions := newSubmissionQueue(submitQueueSize, workflow.priorities.MaxWait, workflow.clientQuotas.Weights)
+	submitting := make(chan struct{})
+	if workflow.submitLimiter != nil {
+		go func() {
+			defer close(submitting)
+			workflow.runSubmissions(ctx, workCtx, submissions, processedEvents)
+		}()
+	} else {
+		close(submitting)
+	}
+	defer func() { <-submitting }()
+
+	// If results are posted in batches, completed events are handed to a
+	// separate queue to wait for the rest of their batch.
+	completions := make(chan Event, workflow.batchSize)
+	batching := make(chan struct{})
+	batcher, canBatch := workflow.Contract.(BatchCompleter)
+	if canBatch && workflow.batchSize > 1 {
+		go func() {
+			defer close(batching)
+			workflow.runBatches(ctx, workCtx, batcher, completions, processedEvents)
+		}()
+	} else {
+		batcher = nil
+		close(batching)
+	}
+	defer func() { <-batching }()
+
 	for {
-		var result Event
-		var wait time.Duration
+		var event Event
 		select {
-		case event := <-processedEvents:
-			result, wait = workflow.ProcessEvent(ctx, event)
-		case event := <-newEvents:
-			result, wait = workflow.ProcessEvent(ctx, event)
+		case event = <-processedEvents:
+		case event = <-newEvents:
 		case <-ctx.Done():
 			return
 		}
 
-		if result != nil && wait == 0 {
-			// We got an event result, so put it back on the queue to be
-			// processed into the next state.
-			processedEvents <- result
-		} else if result != nil && wait > 0 {
-			// The result has come with a wait time. Ask the scheduler to put
-			// the result back on the queue after the wait time has elapsed.
-			_, err = workflow.scheduler.WaitForSchedule().
-				Every(1).
-				LimitRunsTo(1).
-				StartAt(time.Now().Add(wait)).
-				Do(func() {
-					processedEvents <- result
-				})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !workflow.owns(event.OrderId()) {
+			log.Ctx(ctx).Debug().Stringer("id", event.OrderId()).Msg("Skipping order owned by another bridge")
+			continue
+		}
+
+		if workflow.Chaos.dropEvent(ctx, event) {
+			continue
+		}
+
+		if workflow.submitLimiter != nil && event.OrderState() == OrderStateSubmitted {
+			if !submissions.push(event, workflow.priority(event)) {
+				log.Ctx(ctx).Warn().Stringer("id", event.OrderId()).Msg("Submission queue full, trying again later")
+				workflow.requeue(ctx, event, submitQueueRetryTime, processedEvents)
+			}
+			continue
+		}
+
+		if workflow.Hasher != nil && event.OrderState() == OrderStateCompleted {
+			result, wait, held := workflow.hashOutput(orderContext(workCtx, event), event.(BacalhauJobCompletedEvent))
+			if held {
+				workflow.requeue(ctx, result, wait, processedEvents)
+				continue
+			}
+		}
+
+		if workflow.Verifier != nil && event.OrderState() == OrderStateCompleted {
+			result, wait, held := workflow.verify(orderContext(workCtx, event), event.(BacalhauJobCompletedEvent))
+			if held {
+				workflow.requeue(ctx, result, wait, processedEvents)
+				continue
+			}
+		}
+
+		if event.OrderState() == OrderStateCompleted {
+			result, wait, held := workflow.encryptResult(orderContext(workCtx, event), event.(BacalhauJobCompletedEvent))
+			if held {
+				workflow.requeue(ctx, result, wait, processedEvents)
+				continue
+			}
+
+			result, wait, held = workflow.makeDeal(orderContext(workCtx, event), event.(BacalhauJobCompletedEvent))
+			if held {
+				workflow.requeue(ctx, result, wait, processedEvents)
+				continue
+			}
+		}
+
+		if batcher != nil && event.OrderState() == OrderStateCompleted && !synthetic(event) {
+			select {
+			case completions <- event:
+				continue
+			default:
+				// The batch is being sent, so return this result on its own.
+			}
+		}
+
+		result, wait := workflow.ProcessEvent(workCtx, event)
+		if ctx.Err() != nil {
+			return
+		}
+		workflow.requeue(ctx, result, wait, processedEvents)
+	}
+}
+
+// runSubmissions processes submitted events no faster than the submission rate
+// limit allows, using workCtx for the submissions themselves. It will block
+// until the passed context is cancelled and any submission in progress has
+// finished.
+func (workflow *Workflow) runSubmissions(ctx, workCtx context.Context, submissions *submissionQueue, processedEvents chan<- Event) {
+	for {
+		event, ok := submissions.pop(ctx)
+		if !ok {
+			return
 		}
 
+		if err := workflow.submitLimiter.Wait(ctx); err != nil {
+			return
+		}
+
+		result, wait := workflow.ProcessEvent(workCtx, event)
+		if ctx.Err() != nil {
+			return
+		}
+		workflow.requeue(ctx, result, wait, processedEvents)
+	}
+}
+
+// requeue puts the result of processing an event back on the queue, after the
+// passed wait time if there is one.
+func (workflow *Workflow) requeue(ctx context.Context, result Event, wait time.Duration, queue chan<- Event) {
+	if result != nil && wait == 0 {
+		// We got an event result, so put it back on the queue to be
+		// processed into the next state.
+		queue <- result
+	} else if result != nil && wait > 0 {
+		// The result has come with a wait time. Ask the scheduler to put
+		// the result back on the queue after the wait time has elapsed.
+		_, err := workflow.scheduler.WaitForSchedule().
+			Every(1).
+			LimitRunsTo(1).
+			StartAt(now().Add(wait)).
+			Do(func() {
+				queue <- result
+			})
 		if err != nil {
 			log.Ctx(ctx).Error().Err(err).Send()
 		}
@@ -152,25 +638,90 @@ func (workflow *Workflow) Run(ctx context.Context, newEvents <-chan Event) (err
 // be delayed until that time has elapsed.
 func (workflow *Workflow) ProcessEvent(ctx context.Context, event Event) (result Event, wait time.Duration) {
 	var err error
-	ctx = log.Ctx(ctx).With().
-		Stringer("id", event.OrderId()).
-		Stringer("state", event.OrderState()).
-		Logger().WithContext(ctx)
+	ctx = orderContext(ctx, event)
+	ctx, span := startOrderSpan(ctx, "workflow.ProcessEvent", event)
+	defer func() { endSpan(span, err) }()
 
 	log.Ctx(ctx).Trace().Msg("Process event")
+	eventsProcessed.WithLabelValues(event.OrderState().String()).Inc()
 
 	currentState := event.OrderState()
 	switch currentState {
 	case OrderStateSubmitted:
-		result, err = workflow.Bacalhau.Create(ctx, event.(ContractSubmittedEvent))
+		if err = workflow.checkClient(ctx, event.(ContractSubmittedEvent)); err != nil {
+			break
+		}
+		if workflow.maintenance.Load() != nil {
+			log.Ctx(ctx).Debug().Msg("Holding order whilst in maintenance mode")
+			return event, maintenanceRetryTime
+		}
+		client := event.(ContractSubmittedEvent).OrderRequestor()
+		if hold := workflow.clientRates.wait(client, workflow.clientQuotas.MaxPerHour); hold > 0 {
+			log.Ctx(ctx).Debug().Stringer("client", client).Dur("wait", hold).Msg("Holding order until the client is under its hourly quota")
+			return event, hold
+		}
+		if !workflow.slots.take(event.OrderId(), client) {
+			log.Ctx(ctx).Debug().Msg("Holding order until a running job finishes")
+			return event, workflow.jobCheckInterval
+		}
+		result, err = workflow.create(ctx, event.(ContractSubmittedEvent))
+		if err != nil || result == nil || result.OrderState() != OrderStateRunning {
+			workflow.slots.free(event.OrderId())
+		}
+		if err == nil && result != nil && result.OrderState() != OrderStateFailed {
+			workflow.clientRates.record(client)
+		}
 	case OrderStateCompleted:
-		result, err = workflow.Contract.Complete(ctx, event.(BacalhauJobCompletedEvent))
+		event := event.(BacalhauJobCompletedEvent)
+		workflow.fetchResults(ctx, event)
+		workflow.pinResults(ctx, event)
+		if synthetic(event) {
+			log.Ctx(ctx).Info().Msg("Not returning the result of a synthetic order on-chain")
+			result = event.Paid()
+			break
+		}
+
+		var release func(posted bool)
+		release, err = workflow.claimPostings(ctx, event)
+		if isContended(err) {
+			return workflow.contended(ctx, event, err)
+		} else if err != nil {
+			break
+		}
+		intended := workflow.writeIntent(ctx, event.Paid())
+		defer intended()
+		result, err = workflow.Contract.Complete(ctx, event)
+		release(err == nil || errors.Is(err, bridgeerrors.ErrTxReverted))
 	case OrderStateJobError:
 		event := event.(BacalhauJobFailedEvent)
-		if ShouldRetry(event) {
-			result = event.Retry()
+
+		// Whether we retry or give up, the old job is no use to us anymore, so
+		// make sure it isn't left running on the network.
+		cancelErr := workflow.Bacalhau.Cancel(ctx, event)
+		log.Ctx(ctx).WithLevel(level(cancelErr)).
+			Err(cancelErr).
+			Str("job", event.JobID()).
+			Msg("Cancelling errored job")
+
+		// A job that ran out of time would only run out of time again, and
+		// resubmitting it would keep the order open for several times its
+		// limit, so it fails straight away.
+		if event.FailureReason() != FailureReasonTimeout && event.Resubmissions()+1 < workflow.resubmitPolicy.MaxAttempts {
+			reason := event.FailureReason()
+			resubmitted := event.Retry()
+			result = resubmitted
+			wait = workflow.resubmitPolicy.Wait(resubmitted.Resubmissions())
+			log.Ctx(ctx).Warn().
+				Str("error", event.Error()).
+				Stringer("reason", reason).
+				Uint("attempt", resubmitted.Resubmissions()+1).
+				Dur("wait", wait).
+				Msg("Resubmitting errored job")
 		} else {
-			result = event.Failed(event.Error())
+			reason := event.FailureReason()
+			failed := event.Failed(event.Error())
+			workflow.mediate(ctx, failed, reason)
+			result = failed
 		}
 	case OrderStateFailed:
 		// if we have failed we need to deal with the error that happens here
@@ -181,62 +732,342 @@ func (workflow *Workflow) ProcessEvent(ctx context.Context, event Event) (result
 		// TODO: we should absolutely do something if we have failed to refund the
 		// user - but we don't have time to do that thing right now so let's at least
 		// only log this error once and not loop infinitely
-		innerResult, refundError := workflow.Contract.Refund(ctx, event.(ContractFailedEvent))
+		// Unless there is a dead-letter queue, in which case the refund is
+		// retried a few times and then the order is moved there.
+		if event.(ContractFailedEvent).FailureReason() == FailureReasonReorged {
+			log.Ctx(ctx).Debug().Msg("Skipping order removed by a reorg")
+			return nil, 0
+		}
+		if event.(ContractFailedEvent).FailureReason() == FailureReasonBlocked && !workflow.Addresses.rejectsOnChain() {
+			log.Ctx(ctx).Debug().Msg("Skipping order from blocked address")
+			return nil, 0
+		}
+		if event.(ContractFailedEvent).FailureReason() == FailureReasonResolved {
+			log.Ctx(ctx).Debug().Msg("Skipping order resolved on chain")
+			return nil, 0
+		}
+
+		if workflow.deadLettered(ctx, event) {
+			log.Ctx(ctx).Debug().Msg("Skipping dead-lettered order")
+			return nil, 0
+		}
+		if synthetic(event) {
+			log.Ctx(ctx).Info().Msg("Not returning the error of a synthetic order on-chain")
+			result = event.(ContractFailedEvent).Refunded()
+			break
+		}
+
+		release, claimError := workflow.claimPostings(ctx, event)
+		if isContended(claimError) {
+			return workflow.contended(ctx, event, claimError)
+		}
+		var innerResult ContractRefundedEvent
+		refundError := claimError
+		if claimError == nil {
+			intended := workflow.writeIntent(ctx, event.(ContractFailedEvent).Refunded())
+			defer intended()
+			innerResult, refundError = workflow.refund(ctx, event.(ContractFailedEvent))
+			release(refundError == nil || errors.Is(refundError, bridgeerrors.ErrTxReverted))
+		}
+		if postingPaused(refundError) {
+			log.Ctx(ctx).Debug().Err(refundError).Msg("Waiting for on-chain posting to resume")
+			return event, gasBudgetRetryTime
+		} else if errors.Is(refundError, bridgeerrors.ErrTxReverted) {
+			log.Ctx(ctx).Warn().Err(refundError).Msg("Not refunding order resolved on chain")
+			return nil, 0
+		}
 		log.Ctx(ctx).WithLevel(level(refundError)).
 			Err(refundError).
 			Msg("Refunding failed job")
 		result = innerResult
 
+		if refundError != nil && workflow.DeadLetters != nil {
+			result, wait = workflow.retryOrDeadLetter(ctx, event.(ContractFailedEvent), refundError)
+		}
+
 	default:
 		result = nil
 	}
 
-	if err != nil && !errors.Is(err, context.Canceled) {
-		log.Ctx(ctx).Error().Err(err).Msg("Error processing event")
+	return workflow.settle(ctx, event, result, wait, err)
+}
+
+// settle deals with the outcome of the action taken on an event, turning an
+// error into a retry or a refund, and saves the event in its new state.
+func (workflow *Workflow) settle(ctx context.Context, event Event, result Event, wait time.Duration, err error) (Event, time.Duration) {
+	currentState := event.OrderState()
+	if postingPaused(err) {
+		// Posting is paused rather than broken, so wait without using up
+		// any of the order's attempts.
+		log.Ctx(ctx).Debug().Err(err).Msg("Waiting for on-chain posting to resume")
+		return event, gasBudgetRetryTime
+	} else if err != nil && !errors.Is(err, context.Canceled) {
+		class := bridgeerrors.Classify(err)
+		log.Ctx(ctx).Error().Err(err).Str("class", string(class)).Msg("Error processing event")
+		eventErrors.WithLabelValues(currentState.String(), string(class)).Inc()
 
 		// The processing action failed. If we can retry the action, do that,
-		// else if we are beyond our limit send the order for a refund.
-		if e, retryable := event.(Retryable); retryable && ShouldRetry(e) {
+		// else if we are beyond our limit send the order for a refund. Terminal
+		// errors, such as jobs refused by policy or specs that can't be run,
+		// would fail the same way every time, so aren't retried.
+		var rejection *Rejection
+		if errors.As(err, &rejection) {
+			reason := rejection.FailureReason
+			if reason == FailureReasonUnknown {
+				reason = FailureReasonRejected
+			}
+			result = event.(ContractSubmittedEvent).FailedWith(reason, rejection.Error())
+		} else if errors.Is(err, bridgeerrors.ErrTxReverted) {
+			// The contract won't take anything more for the order, so
+			// trying again would only spend gas.
+			result = event.(ContractSubmittedEvent).FailedWith(FailureReasonResolved, err.Error())
+		} else if e, retryable := event.(Retryable); retryable && class == bridgeerrors.Retryable && ShouldRetry(e) {
 			e.AddAttempt()
 			result = e
 			wait = workflow.getRetryTime(e)
+			workflow.auditRetry(ctx, event, err)
+		} else if currentState == OrderStateSubmitted && errors.Is(err, bridgeerrors.ErrSpecInvalid) {
+			result = event.(ContractSubmittedEvent).FailedWith(FailureReasonRejected, err.Error())
+		} else if currentState == OrderStateSubmitted {
+			result = event.(ContractSubmittedEvent).FailedWith(FailureReasonSubmitError, err.Error())
 		} else {
 			result = event.(ContractSubmittedEvent).Failed(err.Error())
 		}
 	}
 
+	// An order that was asked to move somewhere its lifecycle doesn't allow is
+	// a bug, so stop working on it rather than save it in a state that makes
+	// no sense. It is left as it was last saved.
+	if err := transitionError(result); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Refusing to save order")
+		illegalTransitions.Inc()
+		return nil, 0
+	}
+
 	// If we have a non-nil result, we are changing the state of something. So
 	// save the new state.
 	if result != nil {
+		done := workflow.writeAhead(ctx, result)
 		saveError := workflow.Repo.Save(result)
+		if saveError == nil {
+			done()
+		}
 		log.Ctx(ctx).WithLevel(level(saveError)).
 			Err(saveError).
 			Stringer("old", currentState).
 			Stringer("new", result.OrderState()).
 			Msg("Saving result")
+
+		if saveError == nil && result.OrderState() != currentState {
+			workflow.Events.Publish(ctx, result)
+			workflow.auditEvent(ctx, currentState, result)
+			workflow.trackPosting(ctx, result)
+		}
 	}
 
-	return
+	return result, wait
+}
+
+// orderContext returns a context that logs which order, and in which state,
+// is being worked on.
+func orderContext(ctx context.Context, event Event) context.Context {
+	return log.Ctx(ctx).With().
+		Stringer("id", event.OrderId()).
+		Stringer("state", event.OrderState()).
+		Logger().WithContext(ctx)
+}
+
+// fetchResults downloads the results of the passed event in the background, if
+// the workflow has been configured to keep copies of results. Failing to fetch
+// a result doesn't stop it being returned to the smart contract.
+func (workflow *Workflow) fetchResults(ctx context.Context, event BacalhauJobCompletedEvent) {
+	if workflow.Results == nil {
+		return
+	}
+
+	// The results of an order are fetched one after another, and only so
+	// many are fetched at once, so that a burst of completed orders can't
+	// start an unbounded number of downloads.
+	go func() {
+		for _, result := range event.Results(ctx) {
+			select {
+			case workflow.fetching <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			err := workflow.Results.Fetch(ctx, result)
+			<-workflow.fetching
+			log.Ctx(ctx).WithLevel(level(err)).Err(err).Stringer("cid", result).Msg("Fetching result")
+		}
+	}()
 }
 
 // checkRunningEvents reloads the Bacalhau jobs that should be running and finds
 // the ones that have finished, pushing them back onto the state machine queue
 // for the result of the job to be processed.
 func (workflow *Workflow) checkRunningEvents(ctx context.Context, out chan<- Event) {
+	workflow.checkMu.Lock()
+	defer workflow.checkMu.Unlock()
+
+	loaded := now()
 	jobs, err := Reload[BacalhauJobRunningEvent](workflow.Repo, OrderStateRunning)
 	log.Ctx(ctx).WithLevel(level(err)).Err(err).Int("count", len(jobs)).Msg("Reloaded running events")
 
+	if workflow.Partitioner != nil {
+		owned := jobs[:0]
+		for _, job := range jobs {
+			if workflow.owns(job.OrderId()) {
+				owned = append(owned, job)
+			}
+		}
+		jobs = owned
+	}
+
+	workflow.Chaos.delayPoll(ctx)
+	if err := workflow.Chaos.disconnected(ctx); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to check running jobs")
+		return
+	}
+
 	completed, failed := workflow.Bacalhau.FindCompleted(ctx, jobs)
 	log.Ctx(ctx).Debug().
 		Int("completed", len(completed)).
 		Int("failed", len(failed)).
 		Msg("Queried Bacalhau job status")
 
+	finished := make(map[common.Hash]bool, len(completed)+len(failed))
 	for _, event := range completed {
-		out <- event
+		finished[event.OrderId()] = true
+		workflow.found(ctx, event, out)
 	}
 	for _, event := range failed {
-		out <- event
+		finished[event.OrderId()] = true
+		workflow.found(ctx, event, out)
+	}
+
+	running := make([]BacalhauJobRunningEvent, 0, len(jobs))
+	for _, job := range jobs {
+		if !finished[job.OrderId()] {
+			running = append(running, job)
+		}
+	}
+	workflow.heartbeat(ctx, running)
+	if err == nil {
+		workflow.slots.sync(running, loaded)
+	}
+}
+
+// cancelSettledElsewhere cancels the jobs of orders that another bridge has
+// already settled on-chain, such as one that wrongly believed it owned them,
+// so that they don't keep running for nothing. The orders are failed without
+// being refunded. Nothing but a bridge settles an order, and contracts older
+// than version 5 can't say whether an order has been, so on them this does
+// nothing.
+//
+// The contract is asked about a few orders at a time without holding checkMu,
+// so that a slow endpoint doesn't hold up finding finished jobs. The lock is
+// only taken to fail the orders that are settled and still running.
+func (workflow *Workflow) cancelSettledElsewhere(ctx context.Context, out chan<- Event) {
+	jobs, err := Reload[BacalhauJobRunningEvent](workflow.Repo, OrderStateRunning)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to reload running events to check for settled orders")
+		return
+	}
+
+	var mu sync.Mutex
+	settled := map[common.Hash]bool{}
+	var group errgroup.Group
+	group.SetLimit(settledCheckConcurrency)
+	for _, job := range jobs {
+		job := job
+		if !workflow.owns(job.OrderId()) {
+			continue
+		}
+		group.Go(func() error {
+			err := workflow.checkSettled(ctx, job)
+			if errors.Is(err, ErrAlreadySettled) {
+				mu.Lock()
+				settled[job.OrderId()] = true
+				mu.Unlock()
+			} else if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Stringer("id", job.OrderId()).Msg("Unable to check whether order was settled")
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+	if len(settled) == 0 {
+		return
+	}
+
+	workflow.checkMu.Lock()
+	defer workflow.checkMu.Unlock()
+
+	// The jobs may have been found to have finished whilst the contract was
+	// being asked, in which case they are left to be settled as usual.
+	running, err := Reload[BacalhauJobRunningEvent](workflow.Repo, OrderStateRunning)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to reload running events to cancel settled orders")
+		return
+	}
+	for _, job := range running {
+		if !settled[job.OrderId()] {
+			continue
+		}
+
+		workflow.found(ctx, job.FailedWith(FailureReasonResolved, "order settled on chain by another bridge"), out)
+		cancelErr := workflow.Bacalhau.Cancel(ctx, job)
+		log.Ctx(ctx).WithLevel(level(cancelErr)).
+			Err(cancelErr).
+			Stringer("id", job.OrderId()).
+			Str("job", job.JobID()).
+			Msg("Cancelling job of order settled by another bridge")
+	}
+}
+
+// found saves a job that has been found to have finished and pushes it onto
+// the state machine queue.
+func (workflow *Workflow) found(ctx context.Context, event Event, out chan<- Event) {
+	done := workflow.writeAhead(ctx, event)
+	if err := workflow.Repo.Save(event); err != nil {
+		log.Ctx(ctx).Error().Err(err).Stringer("id", event.OrderId()).Msg("Saving finished job")
+	} else {
+		done()
+	}
+
+	workflow.Events.Publish(ctx, event)
+	workflow.auditEvent(ctx, OrderStateRunning, event)
+	if event.OrderState() == OrderStateCompleted {
+		workflow.cacheJob(ctx, event.(BacalhauJobCompletedEvent))
+	}
+	select {
+	case out <- event:
+	case <-ctx.Done():
+	}
+}
+
+// checkChangedEvents checks the running jobs whenever the job runner tells us
+// that a job has changed state. Changes that arrive while a check is already
+// happening are coalesced into a single further check.
+func (workflow *Workflow) checkChangedEvents(ctx context.Context, changed <-chan string, out chan<- Event) error {
+	for {
+		select {
+		case jobID := <-changed:
+			log.Ctx(ctx).Debug().Str("job", jobID).Msg("Job changed state")
+		case <-ctx.Done():
+			return nil
+		}
+
+		// Drain any other changes that have arrived in the meantime.
+		for drained := false; !drained; {
+			select {
+			case <-changed:
+			default:
+				drained = true
+			}
+		}
+
+		workflow.checkRunningEvents(ctx, out)
 	}
 }
 
@@ -252,24 +1083,93 @@ func (workflow *Workflow) deduplicateSubmittedEvents(ctx context.Context, in <-c
 	for {
 		select {
 		case e := <-in:
-			var exists bool
-			exists, err = workflow.Repo.Exists(e)
-			if err != nil {
-				break
+			exists, saved := workflow.saveSubmitted(ctx, e)
+			if !saved {
+				return
+			}
+			if acknowledger, ok := workflow.Contract.(OrderAcknowledger); ok {
+				acknowledger.Acknowledge(e)
 			}
 			if exists {
 				log.Ctx(ctx).Debug().Stringer("id", e.OrderId()).Msg("Dropping new event because already seen")
 				continue
 			}
 
-			err = workflow.Repo.Save(e)
-			out <- e
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
 		case <-ctx.Done():
 			return
 		}
+	}
+}
+
+// saveSubmitted saves a new order unless it has been seen before, returning
+// whether it had been. An order that can't be saved would be lost once the
+// block it was read from is checkpointed, so it is tried again until it is
+// saved or the context is cancelled, when saved is false.
+func (workflow *Workflow) saveSubmitted(ctx context.Context, e ContractSubmittedEvent) (exists, saved bool) {
+	for {
+		_, span := startOrderSpan(ctx, "contract.Submitted", e)
+		var err error
+		exists, err = workflow.Repo.Exists(e)
+		if err == nil && !exists {
+			done := workflow.writeAhead(ctx, e)
+			if err = workflow.Repo.Save(e); err == nil {
+				done()
+				workflow.Events.Publish(ctx, e)
+				workflow.auditEvent(ctx, OrderStateSubmitted, e)
+			}
+		}
+		endSpan(span, err)
+		if err == nil {
+			return exists, true
+		}
 
+		log.Ctx(ctx).Error().Err(err).
+			Stringer("id", e.OrderId()).
+			Dur("wait", repoRetryTime).
+			Msg("Unable to save new order, will try again")
+		select {
+		case <-time.After(repoRetryTime):
+		case <-ctx.Done():
+			return false, false
+		}
+	}
+}
+
+// owns returns whether this bridge should work on the passed order.
+func (workflow *Workflow) owns(orderID common.Hash) bool {
+	return workflow.Partitioner == nil || workflow.Partitioner.Owns(orderID)
+}
+
+// reloadPartitions puts the saved orders in newly claimed partitions on the
+// queue, as the bridge that owned them before may have left them part way
+// through. Running jobs are picked up by the next check of running jobs.
+func (workflow *Workflow) reloadPartitions(ctx context.Context, partitions []uint, out chan<- Event) {
+	claimed := map[uint]bool{}
+	for _, partition := range partitions {
+		claimed[partition] = true
+	}
+
+	for _, state := range []OrderState{OrderStateSubmitted, OrderStateFailed, OrderStateCompleted, OrderStateJobError} {
+		events, err := workflow.Repo.Reload(state)
 		if err != nil {
-			log.Ctx(ctx).Error().Err(err)
+			log.Ctx(ctx).Error().Err(err).Stringer("state", state).Msg("Unable to reload claimed partitions")
+			continue
+		}
+
+		for _, e := range events {
+			if !claimed[PartitionOf(e.OrderId(), workflow.Partitioner.count)] {
+				continue
+			}
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
@@ -3,12 +3,17 @@ package bridge
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/bacalhau-project/bacalhau/pkg/model"
 	"github.com/bacalhau-project/bacalhau/pkg/system"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/sync/errgroup"
@@ -33,8 +38,14 @@ func TestWorkflowSuite(t *testing.T) {
 
 func (suite *WorkflowTestSuite) SetupSuite() {
 	system.InitConfigForTesting(suite.T())
+	suite.Require().NoError(Initialize(SystemConfig{Initialized: true}))
 	defaultRetryStrategy = Immediate
 	defaultJobCheckInterval = 20 * time.Millisecond
+	defaultResubmitPolicy.Backoff = 0
+	defaultShutdownGracePeriod = time.Second
+	maintenanceRetryTime = 20 * time.Millisecond
+	repoRetryTime = 0
+	settledCheckInterval = 20 * time.Millisecond
 }
 
 func (suite *WorkflowTestSuite) SetupTest() {
@@ -102,7 +113,7 @@ func (suite *WorkflowTestSuite) EmitNone() ContractListenHandler {
 	}
 }
 
-func (suite *WorkflowTestSuite) TestHappyPath() {
+func (suite *WorkflowTestSuite) HappyPathTest(opts ...WorkflowOption) {
 	e := exampleEvent()
 
 	suite.RunWorkflow(NewWorkflow(
@@ -116,6 +127,7 @@ func (suite *WorkflowTestSuite) TestHappyPath() {
 			ListenHandler:   suite.EmitOne(e),
 		},
 		suite.Repository(),
+		opts...,
 	))
 
 	select {
@@ -128,11 +140,83 @@ func (suite *WorkflowTestSuite) TestHappyPath() {
 	}
 }
 
+func (suite *WorkflowTestSuite) TestHappyPath() {
+	suite.HappyPathTest()
+}
+
+// flakyRepository fails the first few checks for whether an order exists.
+type flakyRepository struct {
+	Repository
+	failures atomic.Int32
+}
+
+func (r *flakyRepository) Exists(e Event) (bool, error) {
+	if r.failures.Add(-1) >= 0 {
+		return false, errors.New("database is locked")
+	}
+	return r.Repository.Exists(e)
+}
+
+func (suite *WorkflowTestSuite) TestNewOrdersAreNotDroppedOnRepositoryErrors() {
+	repo := &flakyRepository{Repository: suite.Repository()}
+	repo.failures.Store(2)
+	suite.HappyPathTest(func(workflow *Workflow) { workflow.Repo = repo })
+}
+
+func (suite *WorkflowTestSuite) TestRateLimitedHappyPath() {
+	suite.HappyPathTest(WithSubmitRateLimit(100, 1))
+}
+
+func (suite *WorkflowTestSuite) TestMaintenanceHoldsSubmissions() {
+	e := exampleEvent()
+	var created atomic.Int32
+	repo := suite.Repository()
+
+	w := NewWorkflow(
+		&mockRunner{
+			CreateHandler: func(ctx context.Context, e ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+				created.Add(1)
+				return SuccessfulCreate(ctx, e)
+			},
+			FindCompletedHandler: SuccssfulFind,
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		repo,
+	)
+	w.SetMaintenance(suite.workflowCtx, true)
+	suite.True(w.Maintenance().Enabled)
+	suite.RunWorkflow(w)
+
+	select {
+	case <-suite.completed:
+		suite.Fail("Should not have submitted in maintenance mode")
+	case <-suite.Timeout():
+	}
+	suite.Zero(created.Load())
+	saved, err := repo.Reload(OrderStateSubmitted)
+	suite.NoError(err)
+	suite.Len(saved, 1)
+
+	w.SetMaintenance(suite.workflowCtx, false)
+	suite.False(w.Maintenance().Enabled)
+	select {
+	case result := <-suite.completed:
+		suite.Equal(e.OrderId(), result.OrderId())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+	suite.Equal(int32(1), created.Load())
+}
+
 func (suite *WorkflowTestSuite) RefundOnFailTest(
 	create RunnerCreateHandler,
 	find RunnerFindCompletedHandler,
 	complete ContractCompleteHandler,
-) {
+) (refunded ContractRefundedEvent) {
 	e := exampleEvent()
 	w := NewWorkflow(
 		&mockRunner{
@@ -152,11 +236,12 @@ func (suite *WorkflowTestSuite) RefundOnFailTest(
 	select {
 	case <-suite.completed:
 		suite.Fail("Should not have got a completed event")
-	case result := <-suite.refunded:
-		suite.Equal(e.OrderId(), result.OrderId())
+	case refunded = <-suite.refunded:
+		suite.Equal(e.OrderId(), refunded.OrderId())
 	case <-suite.Timeout():
 		suite.Fail("Timed out")
 	}
+	return refunded
 }
 
 func (suite *WorkflowTestSuite) TestCreateRefunded() {
@@ -217,3 +302,779 @@ func (suite *WorkflowTestSuite) TestErroredEventsAreReloaded() {
 func (suite *WorkflowTestSuite) TestFailedEventsAreReloaded() {
 	suite.ReloadEventTest(exampleEvent().JobCreated(model.NewJob()).Failed(""))
 }
+
+func (suite *WorkflowTestSuite) TestWriteAheadLogIsReplayed() {
+	wal, err := NewFileWAL(filepath.Join(suite.T().TempDir(), "test.wal"))
+	suite.Require().NoError(err)
+	defer wal.Close()
+
+	// The order was found to be completed but the bridge stopped before the
+	// change was saved, so the repository has never heard of it.
+	event := walEvent(1).JobCreated(model.NewJob()).Completed(cid.Cid{}, "", "", 0)
+	_, err = wal.Append(event)
+	suite.Require().NoError(err)
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: SuccssfulFind,
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitNone(),
+		},
+		suite.Repository(),
+		WithWriteAheadLog(wal),
+	))
+
+	select {
+	case e := <-suite.completed:
+		suite.Equal(event.OrderId(), e.OrderId())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestUnrefundableOrdersAreDeadLettered() {
+	var refundable atomic.Bool
+	repo := suite.Repository()
+	dlq := repo.(DeadLetterQueue)
+	e := exampleEvent()
+
+	w := NewWorkflow(
+		&mockRunner{
+			CreateHandler:        ErrorCreate,
+			FindCompletedHandler: SuccssfulFind,
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler: func(ctx context.Context, cfe ContractFailedEvent) (ContractRefundedEvent, error) {
+				if !refundable.Load() {
+					return nil, errors.New("failed to refund the smart contract")
+				}
+				return suite.SuccessfulRefund()(ctx, cfe)
+			},
+			ListenHandler: suite.EmitOne(e),
+		},
+		repo,
+		WithDeadLetterQueue(dlq),
+	)
+	suite.RunWorkflow(w)
+
+	suite.Eventually(func() bool {
+		d, err := dlq.DeadLetter(suite.workflowCtx, e.OrderId().Hex())
+		return err == nil && d.Status == DeadLetterStatusQueued
+	}, time.Second, 10*time.Millisecond)
+
+	refundable.Store(true)
+	suite.NoError(w.Requeue(suite.workflowCtx, e.OrderId().Hex()))
+	suite.ErrorIs(w.Requeue(suite.workflowCtx, e.OrderId().Hex()), ErrDeadLetterResolved)
+
+	select {
+	case refunded := <-suite.refunded:
+		suite.Equal(e.OrderId(), refunded.OrderId())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestDuplicateOrdersAreNotResubmitted() {
+	repo := suite.Repository()
+	e := exampleEvent()
+	suite.Require().NoError(repo.(SubmissionStore).RecordSubmission(suite.workflowCtx, Submission{
+		OrderID:  e.OrderId().Hex(),
+		JobID:    "existing-job",
+		Endpoint: "http://bacalhau.example.com:1234",
+	}))
+
+	created := 0
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler: func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+				created++
+				return SuccessfulCreate(ctx, cse)
+			},
+			FindCompletedHandler: SuccssfulFind,
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		repo,
+		WithSubmissionStore(repo.(SubmissionStore)),
+	))
+
+	select {
+	case paid := <-suite.completed:
+		suite.Equal("existing-job", paid.JobID())
+		suite.Equal("http://bacalhau.example.com:1234", paid.Endpoint())
+		suite.Equal(0, created)
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestErroredJobsAreCancelled() {
+	cancelled := make(chan BacalhauJobRunningEvent, defaultResubmitPolicy.MaxAttempts)
+	e := exampleEvent()
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: FailedFind,
+			CancelHandler: func(ctx context.Context, job BacalhauJobRunningEvent) error {
+				cancelled <- job
+				return nil
+			},
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		suite.Repository(),
+	))
+
+	select {
+	case job := <-cancelled:
+		suite.Equal(e.OrderId(), job.OrderId())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+type settledContract struct {
+	mockContract
+}
+
+// Settled implements SettlementChecker
+func (settledContract) Settled(ctx context.Context, e Event) (bool, error) {
+	return true, nil
+}
+
+func (suite *WorkflowTestSuite) TestJobsOfSettledOrdersAreCancelled() {
+	cancelled := make(chan BacalhauJobRunningEvent, 1)
+	repo := suite.Repository()
+	e := exampleEvent()
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler: SuccessfulCreate,
+			FindCompletedHandler: func(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+				return nil, nil
+			},
+			CancelHandler: func(ctx context.Context, job BacalhauJobRunningEvent) error {
+				cancelled <- job
+				return nil
+			},
+		},
+		settledContract{mockContract{
+			RefundHandler: func(ctx context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+				suite.Fail("orders settled on chain shouldn't be refunded")
+				return e.Refunded(), nil
+			},
+			ListenHandler: suite.EmitOne(e),
+		}},
+		repo,
+	))
+
+	select {
+	case job := <-cancelled:
+		suite.Equal(e.OrderId(), job.OrderId())
+	case <-suite.Timeout():
+		suite.FailNow("Timed out")
+	}
+
+	suite.Eventually(func() bool {
+		failed, err := Reload[ContractFailedEvent](repo, OrderStateFailed)
+		return err == nil && len(failed) == 1 && failed[0].FailureReason() == FailureReasonResolved
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *WorkflowTestSuite) TestRejectedRefunded() {
+	attempts := 0
+	refunded := suite.RefundOnFailTest(
+		func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+			attempts++
+			return nil, &Rejection{Reason: "not allowed"}
+		},
+		SuccssfulFind,
+		suite.SuccessfulComplete(),
+	)
+	suite.Equal(1, attempts, "rejected jobs should not be retried")
+	suite.Require().NotNil(refunded)
+	suite.Equal(FailureReasonRejected, refunded.FailureReason())
+}
+
+func (suite *WorkflowTestSuite) TestFailedJobsAreResubmitted() {
+	resubmissions := make(chan uint, defaultResubmitPolicy.MaxAttempts+1)
+	suite.RefundOnFailTest(
+		func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+			resubmissions <- cse.Resubmissions()
+			return SuccessfulCreate(ctx, cse)
+		},
+		FailedFind,
+		suite.SuccessfulComplete(),
+	)
+
+	close(resubmissions)
+	seen := []uint{}
+	for resubmission := range resubmissions {
+		seen = append(seen, resubmission)
+	}
+	suite.Equal([]uint{0, 1, 2}, seen)
+}
+
+func (suite *WorkflowTestSuite) TestTimedOutJobsAreNotResubmitted() {
+	resubmissions := make(chan uint, defaultResubmitPolicy.MaxAttempts+1)
+	refunded := suite.RefundOnFailTest(
+		func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+			resubmissions <- cse.Resubmissions()
+			return SuccessfulCreate(ctx, cse)
+		},
+		func(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+			failed := []BacalhauJobFailedEvent{}
+			for _, job := range jobs {
+				failed = append(failed, job.JobFailed(FailureReasonTimeout, "job ran for longer than its timeout", "InProgress"))
+			}
+			return nil, failed
+		},
+		suite.SuccessfulComplete(),
+	)
+
+	close(resubmissions)
+	seen := []uint{}
+	for resubmission := range resubmissions {
+		seen = append(seen, resubmission)
+	}
+	suite.Equal([]uint{0}, seen, "timed out jobs should not be resubmitted")
+	suite.Require().NotNil(refunded)
+	suite.Equal(FailureReasonTimeout, refunded.FailureReason())
+}
+
+func (suite *WorkflowTestSuite) TestFailureReasons() {
+	refunded := suite.RefundOnFailTest(ErrorCreate, SuccssfulFind, suite.SuccessfulComplete())
+	suite.Require().NotNil(refunded)
+	suite.Equal(FailureReasonSubmitError, refunded.FailureReason())
+}
+
+func (suite *WorkflowTestSuite) TestLifecycleIsPublished() {
+	bus := NewEventBus()
+	notifications, unsubscribe := bus.Channel()
+	defer unsubscribe()
+
+	suite.HappyPathTest(WithEventBus(bus))
+
+	states := []string{}
+	for len(states) < 4 {
+		select {
+		case n := <-notifications:
+			states = append(states, n.State)
+		case <-suite.Timeout():
+			suite.FailNow("Timed out", "got %v", states)
+		}
+	}
+	suite.Equal([]string{"Submitted", "Running", "Completed", "Paid"}, states)
+}
+
+func (suite *WorkflowTestSuite) TestInFlightSubmissionIsSavedOnShutdown() {
+	repo := suite.Repository()
+	creating := make(chan struct{})
+	release := make(chan struct{})
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler: func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+				close(creating)
+				<-release
+				return SuccessfulCreate(ctx, cse)
+			},
+		},
+		mockContract{ListenHandler: suite.EmitOne(exampleEvent())},
+		repo,
+	))
+
+	select {
+	case <-creating:
+	case <-suite.Timeout():
+		suite.FailNow("Timed out waiting for submission")
+	}
+
+	suite.workflowCancel()
+	close(release)
+	suite.NoError(suite.workflowGroup.Wait())
+
+	running, err := Reload[BacalhauJobRunningEvent](repo, OrderStateRunning)
+	suite.NoError(err)
+	suite.Len(running, 1, "the in-flight submission should have been saved")
+}
+
+func (suite *WorkflowTestSuite) TestInFlightSubmissionIsCancelledAfterGracePeriod() {
+	repo := suite.Repository()
+	creating := make(chan struct{})
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler: func(ctx context.Context, cse ContractSubmittedEvent) (BacalhauJobRunningEvent, error) {
+				close(creating)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+		mockContract{ListenHandler: suite.EmitOne(exampleEvent())},
+		repo,
+		WithShutdownGracePeriod(10*time.Millisecond),
+	))
+
+	select {
+	case <-creating:
+	case <-suite.Timeout():
+		suite.FailNow("Timed out waiting for submission")
+	}
+
+	suite.workflowCancel()
+	suite.NoError(suite.workflowGroup.Wait())
+
+	submitted, err := Reload[ContractSubmittedEvent](repo, OrderStateSubmitted)
+	suite.NoError(err)
+	suite.Len(submitted, 1, "the cancelled submission should be left to be retried")
+}
+
+type batchingContract struct {
+	mockContract
+	batches chan []BacalhauJobCompletedEvent
+	err     error
+}
+
+// CompleteBatch implements BatchCompleter
+func (c batchingContract) CompleteBatch(ctx context.Context, events []BacalhauJobCompletedEvent) ([]ContractPaidEvent, error) {
+	c.batches <- events
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	paid := make([]ContractPaidEvent, len(events))
+	for i, event := range events {
+		paid[i] = event.Paid()
+	}
+	return paid, nil
+}
+
+func (suite *WorkflowTestSuite) BatchTest(batchErr error) (batch []BacalhauJobCompletedEvent, completed int32) {
+	events := []ContractSubmittedEvent{exampleEvent(), exampleEvent(), exampleEvent()}
+	var individually atomic.Int32
+	contract := batchingContract{
+		mockContract: mockContract{
+			CompleteHandler: func(ctx context.Context, e BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+				individually.Add(1)
+				return e.Paid(), nil
+			},
+			ListenHandler: func(ctx context.Context, c chan<- ContractSubmittedEvent) error {
+				for _, e := range events {
+					c <- e
+				}
+				return nil
+			},
+		},
+		batches: make(chan []BacalhauJobCompletedEvent, 1),
+		err:     batchErr,
+	}
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: SuccssfulFind,
+		},
+		contract,
+		suite.Repository(),
+		WithResultBatching(time.Second, len(events)),
+	))
+
+	select {
+	case batch = <-contract.batches:
+	case <-time.After(2 * time.Second):
+		suite.FailNow("Timed out")
+	}
+	suite.Eventually(func() bool {
+		return batchErr == nil || individually.Load() == int32(len(events))
+	}, time.Second, 10*time.Millisecond)
+	return batch, individually.Load()
+}
+
+func (suite *WorkflowTestSuite) TestResultsAreBatched() {
+	batch, completed := suite.BatchTest(nil)
+	suite.Len(batch, 3, "every result should be returned in one transaction")
+	suite.Zero(completed)
+}
+
+func (suite *WorkflowTestSuite) TestFailedBatchesAreReturnedIndividually() {
+	batch, completed := suite.BatchTest(errors.New("execution reverted"))
+	suite.Len(batch, 3)
+	suite.Equal(int32(3), completed)
+}
+
+var exampleResult = cid.MustParse("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
+// findWith returns a handler that completes every job with the passed result.
+func findWith(result cid.Cid) RunnerFindCompletedHandler {
+	return func(ctx context.Context, jobs []BacalhauJobRunningEvent) ([]BacalhauJobCompletedEvent, []BacalhauJobFailedEvent) {
+		completed := []BacalhauJobCompletedEvent{}
+		for _, job := range jobs {
+			completed = append(completed, job.Completed(result, "", "", 0))
+		}
+		return completed, nil
+	}
+}
+
+type disputingContract struct {
+	mockContract
+	disputes chan string
+}
+
+// DisputeResult implements ResultDisputer
+func (c disputingContract) DisputeResult(ctx context.Context, event BacalhauJobCompletedEvent, expected string) error {
+	c.disputes <- expected
+	return nil
+}
+
+func (suite *WorkflowTestSuite) VerificationTest(find RunnerFindCompletedHandler) (contract disputingContract, store VerificationStore) {
+	e := exampleEvent()
+	contract = disputingContract{
+		mockContract: mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		disputes: make(chan string, 1),
+	}
+	repo := suite.Repository()
+	store = repo.(VerificationStore)
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		contract,
+		repo,
+		WithVerifier(NewVerifier(&mockRunner{FindCompletedHandler: find}, store, 100)),
+	))
+	return contract, store
+}
+
+func (suite *WorkflowTestSuite) TestVerifiedResultsAreCompleted() {
+	contract, store := suite.VerificationTest(findWith(exampleResult))
+
+	select {
+	case result := <-suite.completed:
+		v, err := store.Verification(suite.workflowCtx, result.OrderId().Hex())
+		suite.NoError(err)
+		suite.Equal(VerificationStatusMatched, v.Status)
+	case <-suite.refunded:
+		suite.Fail("Should not have got a refunded event")
+	case <-contract.disputes:
+		suite.Fail("Should not have disputed the result")
+	case <-time.After(2 * time.Second):
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestMismatchedResultsAreDisputed() {
+	other, err := cid.Decode("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG")
+	suite.Require().NoError(err)
+	contract, store := suite.VerificationTest(findWith(other))
+
+	select {
+	case expected := <-contract.disputes:
+		suite.Equal(other.String(), expected)
+	case <-suite.completed:
+		suite.FailNow("Should not have completed the order")
+	case <-time.After(2 * time.Second):
+		suite.FailNow("Timed out")
+	}
+
+	select {
+	case refunded := <-suite.refunded:
+		v, err := store.Verification(suite.workflowCtx, refunded.OrderId().Hex())
+		suite.NoError(err)
+		suite.Equal(VerificationStatusMismatched, v.Status)
+		suite.Equal(FailureReasonVerificationFailure, refunded.FailureReason())
+	case <-time.After(2 * time.Second):
+		suite.Fail("Timed out")
+	}
+}
+
+type fixedHasher common.Hash
+
+// HashOutput implements OutputHasher
+func (h fixedHasher) HashOutput(ctx context.Context, result cid.Cid) (common.Hash, error) {
+	return common.Hash(h), nil
+}
+
+func (suite *WorkflowTestSuite) TestOutputHashIsReturned() {
+	e := exampleEvent()
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		suite.Repository(),
+		WithOutputHasher(fixedHasher{0x12}),
+	))
+
+	select {
+	case result := <-suite.completed:
+		suite.Equal(common.Hash{0x12}, result.OutputHash())
+	case <-suite.refunded:
+		suite.Fail("Should not have got a refunded event")
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestEncryptedOrdersAreRejectedWithoutEncryption() {
+	key, err := crypto.GenerateKey()
+	suite.Require().NoError(err)
+	e := exampleEvent()
+	e.(*event).encryptionKey = crypto.CompressPubkey(&key.PublicKey)
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		suite.Repository(),
+	))
+
+	select {
+	case <-suite.completed:
+		suite.Fail("Should not have returned an unencrypted result")
+	case refunded := <-suite.refunded:
+		suite.Equal(FailureReasonRejected, refunded.FailureReason())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+// fixedDealMaker makes every deal with the same ID.
+type fixedDealMaker string
+
+func (d fixedDealMaker) MakeDeal(ctx context.Context, result cid.Cid) (string, error) {
+	return string(d), nil
+}
+
+func (suite *WorkflowTestSuite) TestDealIsMadeForDurableOrders() {
+	e := exampleEvent()
+	e.(*event).durableStorage = true
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		suite.Repository(),
+		WithDealMaker(fixedDealMaker("81234")),
+	))
+
+	select {
+	case result := <-suite.completed:
+		suite.Equal("81234", result.DealID())
+	case <-suite.refunded:
+		suite.Fail("Should not have got a refunded event")
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestDurableOrdersAreRejectedWithoutDealMaker() {
+	e := exampleEvent()
+	e.(*event).durableStorage = true
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		suite.Repository(),
+	))
+
+	select {
+	case <-suite.completed:
+		suite.Fail("Should not have returned a result without a storage deal")
+	case refunded := <-suite.refunded:
+		suite.Equal(FailureReasonRejected, refunded.FailureReason())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+type decliningContract struct {
+	mockContract
+	declined chan ContractFailedEvent
+}
+
+// Decline implements DecliningContract
+func (c decliningContract) Decline(ctx context.Context, event ContractFailedEvent) (ContractRefundedEvent, error) {
+	c.declined <- event
+	return event.Refunded(), nil
+}
+
+func (suite *WorkflowTestSuite) TestUnderpricedOrdersAreDeclined() {
+	e := exampleEvent()
+	e.(*event).orderPrice = "1000"
+
+	contract := decliningContract{
+		mockContract: mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		declined: make(chan ContractFailedEvent, 1),
+	}
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		contract,
+		suite.Repository(),
+		WithPricing(&PricingPolicy{Profiles: []PriceProfile{{Name: "any", MinPrice: 0.01}}}),
+	))
+
+	select {
+	case <-suite.completed:
+		suite.Fail("Should not have run an underpriced job")
+	case <-suite.refunded:
+		suite.Fail("Should have declined rather than refunded")
+	case declined := <-contract.declined:
+		suite.Equal(FailureReasonUnderpriced, declined.FailureReason())
+	case <-suite.Timeout():
+		suite.Fail("Timed out")
+	}
+}
+
+func (suite *WorkflowTestSuite) TestEveryOrderSettlesOnceWithAFaultyRunner() {
+	events := make([]ContractSubmittedEvent, 10)
+	for i := range events {
+		events[i] = exampleEvent()
+		events[i].(*event).orderId = []byte{byte(i + 1)}
+	}
+
+	var mu sync.Mutex
+	settled := map[common.Hash]int{}
+	settle := func(id common.Hash) {
+		mu.Lock()
+		defer mu.Unlock()
+		settled[id]++
+	}
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: SuccssfulFind,
+			Faults: &RunnerFaults{
+				Latency:           time.Millisecond,
+				Jitter:            5 * time.Millisecond,
+				CreateFailureRate: 0.2,
+				JobFailureRate:    0.1,
+				HoldBackRate:      0.5,
+				Reorder:           true,
+				CancelRate:        0.1,
+				Rand:              rand.New(rand.NewSource(1)),
+			},
+		},
+		&mockContract{
+			CompleteHandler: func(ctx context.Context, e BacalhauJobCompletedEvent) (ContractPaidEvent, error) {
+				settle(e.OrderId())
+				return e.Paid(), nil
+			},
+			RefundHandler: func(ctx context.Context, e ContractFailedEvent) (ContractRefundedEvent, error) {
+				settle(e.OrderId())
+				return e.Refunded(), nil
+			},
+			ListenHandler: func(ctx context.Context, c chan<- ContractSubmittedEvent) error {
+				for _, e := range events {
+					c <- e
+				}
+				return nil
+			},
+		},
+		suite.Repository(),
+	))
+
+	suite.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(settled) == len(events)
+	}, 5*time.Second, 10*time.Millisecond, "every order should be paid or refunded")
+
+	// Give any order that would be settled twice the chance to be.
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for id, times := range settled {
+		suite.Equal(1, times, "order %s was settled more than once", id)
+	}
+}
+
+func (suite *WorkflowTestSuite) TestDecisionsAreAudited() {
+	e := exampleEvent()
+	repo := suite.Repository()
+	audit := repo.(AuditLog)
+
+	suite.RunWorkflow(NewWorkflow(
+		&mockRunner{
+			CreateHandler:        SuccessfulCreate,
+			FindCompletedHandler: findWith(exampleResult),
+		},
+		&mockContract{
+			CompleteHandler: suite.SuccessfulComplete(),
+			RefundHandler:   suite.SuccessfulRefund(),
+			ListenHandler:   suite.EmitOne(e),
+		},
+		repo,
+		WithAuditLog(audit),
+	))
+
+	select {
+	case <-suite.completed:
+	case <-suite.refunded:
+		suite.Fail("Should not have got a refunded event")
+	case <-suite.Timeout():
+		suite.FailNow("Timed out")
+	}
+
+	var actions []AuditAction
+	suite.Eventually(func() bool {
+		entries, err := audit.AuditEntries(suite.workflowCtx, AuditFilter{OrderID: e.OrderId().Hex(), Limit: 10})
+		suite.NoError(err)
+		actions = actions[:0]
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+		}
+		return len(actions) == 4
+	}, time.Second, 10*time.Millisecond)
+	suite.Equal([]AuditAction{AuditActionAccepted, AuditActionSubmitted, AuditActionCompleted, AuditActionPosted}, actions)
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bacalhau-project/bacalhau/pkg/logger"
+	"github.com/bacalhau-project/lilypad/pkg/bridge"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func serveCommand() *cobra.Command {
+	var dryRun, chaos bool
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the bridge until interrupted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			config, err := bridge.LoadConfig(path)
+			if err != nil {
+				return err
+			}
+			if err = config.Validate(); err != nil {
+				return err
+			}
+			return serve(cmd.Context(), path, config, dryRun, chaos)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "read and check contract events without submitting jobs or sending transactions")
+	cmd.Flags().BoolVar(&chaos, "chaos", false, "randomly delay, drop and disconnect, as set in the chaos config, to soak test recovery")
+	return cmd
+}
+
+func serve(ctx context.Context, configFile string, config bridge.Config, dryRun, chaos bool) error {
+	// Parts of the bridge read their own settings from the environment.
+	if err := config.Export(); err != nil {
+		return err
+	}
+
+	logType, err := logger.ParseLogMode(config.Log.Mode)
+	if err != nil {
+		return err
+	}
+	logger.ConfigureLogging(logType)
+
+	lvl, err := zerolog.ParseLevel(config.Log.Level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(lvl)
+
+	ctx = log.Logger.WithContext(ctx)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err = bridge.Initialize(bridge.SystemConfig{}); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := bridge.StartTracing(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The serving context is cancelled by now, so the last spans are
+		// given a little time of their own to be sent.
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to flush traces")
+		}
+	}()
+
+	// A dry run keeps its state in memory so that it can't affect a real
+	// deployment sharing the same database.
+	var repo bridge.Repository
+	if dryRun {
+		log.Ctx(ctx).Warn().Msg("Dry run: no jobs will be submitted and no transactions will be sent")
+		repo, err = bridge.NewSQLiteRepository(ctx, "file:lilypad-dry-run?mode=memory&cache=shared")
+	} else if config.Storage.PostgresDSN != "" {
+		repo, err = bridge.NewPostgresRepository(ctx, config.Storage.PostgresDSN)
+	} else {
+		repo, err = bridge.NewSQLiteRepository(ctx, config.Storage.SQLiteFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	contractAddresses, err := config.Chain.ContractAddresses()
+	if err != nil {
+		return err
+	}
+	signer, err := bridge.NewSigner(ctx, config)
+	if err != nil {
+		return err
+	}
+	log.Ctx(ctx).Info().Str("signer", config.Signer.Backend).Stringer("wallet", signer.Address()).Msg("Signing transactions")
+
+	contractOpts := []bridge.ContractOption{
+		bridge.WithStartBlock(config.Chain.StartBlock),
+		bridge.WithConfirmations(config.Chain.Confirmations),
+		bridge.WithWebsocket(config.Chain.WebsocketEndpoint),
+	}
+	if checkpoints, ok := repo.(bridge.BlockCheckpointStore); ok {
+		contractOpts = append(contractOpts, bridge.WithBlockCheckpoints(checkpoints))
+	}
+
+	spends, _ := repo.(bridge.GasSpendStore)
+	budget, err := bridge.GasBudgetFromEnv(spends)
+	if err != nil {
+		return err
+	}
+	contractOpts = append(contractOpts, bridge.WithGasBudget(budget))
+
+	funds := config.Gas.FundsMonitor()
+	contractOpts = append(contractOpts, bridge.WithFundsMonitor(funds))
+
+	if relayer := config.Relayer; relayer.URL != "" {
+		contractOpts = append(contractOpts, bridge.WithRelayer(
+			bridge.NewHTTPRelayer(relayer.URL, relayer.APIKey),
+			common.HexToAddress(relayer.Forwarder),
+			relayer.ForwarderName,
+			relayer.ForwarderVersion,
+		))
+	}
+
+	contract, err := bridge.NewContracts(contractAddresses, signer, contractOpts...)
+	if err != nil {
+		return err
+	}
+
+	if wallet, ok := contract.(bridge.BalanceReporter); ok {
+		go funds.Run(ctx, wallet)
+	}
+
+	runnerName := config.Bacalhau.Runner
+	if dryRun {
+		contract = bridge.NewDryRunContract(contract)
+		runnerName = bridge.DryRunRunner
+	}
+
+	runner, err := bridge.NewRunner(runnerName)
+	if err != nil {
+		return err
+	}
+
+	runnerConfig, err := bridge.RunnerConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	fetcher, err := bridge.ResultFetcherFromEnv()
+	if err != nil {
+		return err
+	}
+
+	deliveries, _ := repo.(bridge.DeliveryStore)
+	subscribers, err := bridge.SubscribersFromEnv(deliveries)
+	if err != nil {
+		return err
+	}
+
+	events := bridge.NewEventBus()
+	for _, subscriber := range subscribers {
+		events.Subscribe(subscriber)
+	}
+
+	quotas, err := config.Limits.ClientQuotas()
+	if err != nil {
+		return fmt.Errorf("CLIENT_WEIGHTS: %w", err)
+	}
+	addresses, err := config.Limits.AddressList()
+	if err != nil {
+		return fmt.Errorf("BLOCKED_ADDRESSES or ALLOWED_ADDRESSES: %w", err)
+	}
+
+	workflowOpts := []bridge.WorkflowOption{
+		bridge.WithJobCheckInterval(runnerConfig.PollInterval),
+		bridge.WithResultFetcher(fetcher),
+		bridge.WithSubmitRateLimit(config.Limits.SubmitRateLimit, config.Limits.SubmitBurst),
+		bridge.WithPriorityLanes(config.Limits.Priorities()),
+		bridge.WithMaxRunningJobs(config.Bacalhau.MaxRunningJobs),
+		bridge.WithClientQuotas(quotas),
+		bridge.WithAddressList(addresses),
+		bridge.WithEventBus(events),
+		bridge.WithHeartbeat(config.Bacalhau.HeartbeatInterval),
+		bridge.WithShutdownGracePeriod(config.Limits.ShutdownGracePeriod),
+		bridge.WithResultBatching(config.Gas.BatchWindow, config.Gas.BatchSize),
+	}
+
+	if submissions, ok := repo.(bridge.SubmissionStore); ok {
+		workflowOpts = append(workflowOpts, bridge.WithSubmissionStore(submissions))
+	}
+
+	audit, _ := repo.(bridge.AuditLog)
+	if audit != nil {
+		workflowOpts = append(workflowOpts, bridge.WithAuditLog(audit))
+	}
+
+	if ttl := config.Cache.TTL; ttl > 0 {
+		cache, err := config.Cache.JobCache(repo)
+		if err != nil {
+			return fmt.Errorf("JOB_CACHE: %w", err)
+		}
+		if closer, ok := cache.(io.Closer); ok && config.Cache.Backend != bridge.JobCacheDatabase {
+			defer closer.Close()
+		}
+		workflowOpts = append(workflowOpts, bridge.WithJobCache(cache, ttl))
+	}
+
+	deadLetters, _ := repo.(bridge.DeadLetterQueue)
+	if deadLetters != nil {
+		workflowOpts = append(workflowOpts, bridge.WithDeadLetterQueue(deadLetters))
+	}
+
+	if partitions := config.Storage.Partitions; partitions > 0 && !dryRun {
+		store, ok := repo.(bridge.PartitionStore)
+		if !ok {
+			return fmt.Errorf("PARTITIONS: %T can't be partitioned", repo)
+		}
+		workflowOpts = append(workflowOpts, bridge.WithPartitioner(bridge.NewPartitioner(store, partitions)))
+	}
+
+	// Replicas that could both believe they own an order lock it before
+	// settling it, so that it isn't settled twice.
+	if (config.Storage.LeaderElection || config.Storage.Partitions > 0) && !dryRun {
+		store, ok := repo.(bridge.PostingLockStore)
+		if !ok {
+			return fmt.Errorf("%T can't lock orders shared with other replicas", repo)
+		}
+		workflowOpts = append(workflowOpts, bridge.WithPostingLocker(bridge.NewPostingLocker(store)))
+	}
+
+	if walFile := config.Storage.WALFile; walFile != "" && !dryRun {
+		wal, err := bridge.NewFileWAL(walFile)
+		if err != nil {
+			return fmt.Errorf("WAL_FILE: %w", err)
+		}
+		defer wal.Close()
+		workflowOpts = append(workflowOpts, bridge.WithWriteAheadLog(wal))
+	}
+
+	var templates bridge.TemplateSource
+	if repo := config.Bacalhau.TemplatesRepo; repo != "" {
+		registry := bridge.NewTemplateRegistry(repo, config.Bacalhau.TemplatesDir, config.Bacalhau.TemplatesCache)
+		if err = registry.Sync(ctx, config.Bacalhau.TemplatesCommit); err != nil {
+			return fmt.Errorf("JOB_TEMPLATES_REPO: %w", err)
+		}
+		templates = registry
+	} else if dir := config.Bacalhau.TemplatesDir; dir != "" {
+		templates, err = bridge.LoadTemplates(dir)
+		if err != nil {
+			return fmt.Errorf("JOB_TEMPLATES_DIR: %w", err)
+		}
+	}
+	if templates != nil {
+		workflowOpts = append(workflowOpts, bridge.WithTemplates(templates))
+	}
+	oracle, err := config.Pricing.Oracle()
+	if err != nil {
+		return fmt.Errorf("PRICING_TOKENS_FILE: %w", err)
+	}
+	if path := config.Pricing.ProfilesFile; path != "" {
+		pricing, err := bridge.LoadPricingPolicy(path)
+		if err != nil {
+			return fmt.Errorf("PRICING_PROFILES_FILE: %w", err)
+		}
+		pricing.Oracle = oracle
+		workflowOpts = append(workflowOpts, bridge.WithPricing(pricing))
+	}
+	if config.Bacalhau.CheckInputs {
+		checker := bridge.NewGatewayInputChecker(config.Storage.IPFSGateway, config.Bacalhau.InputCheckTimeout)
+		workflowOpts = append(workflowOpts, bridge.WithInputChecker(checker))
+	}
+	if config.Storage.HashOutputs {
+		workflowOpts = append(workflowOpts, bridge.WithOutputHasher(bridge.NewGatewayHasher(config.Storage.IPFSGateway)))
+	}
+	if api := config.Storage.IPFSAPI; api != "" {
+		encryption := bridge.NewResultEncryption(config.Storage.IPFSGateway, bridge.NewIPFSAPIUploader(api))
+		workflowOpts = append(workflowOpts, bridge.WithResultEncryption(encryption))
+	}
+	if api := config.Storage.DealAPI; api != "" {
+		deals := bridge.NewDealMakingAPI(api, config.Storage.DealToken, config.Storage.DealDuration)
+		workflowOpts = append(workflowOpts, bridge.WithDealMaker(deals))
+	}
+
+	var mediator *bridge.Mediator
+	if mediation := config.Mediation; len(mediation.Endpoints) > 0 && !dryRun {
+		mediating, ok := contract.(bridge.MediationContract)
+		if !ok {
+			return fmt.Errorf("MEDIATOR_API_ENDPOINTS: %T can't post mediation verdicts", contract)
+		}
+		store, ok := repo.(bridge.MediationStore)
+		if !ok {
+			return fmt.Errorf("MEDIATOR_API_ENDPOINTS: %T can't keep track of mediations", repo)
+		}
+		mediatorRunner, err := bridge.NewJobRunner(bridge.WithEndpoints(mediation.Endpoints...))
+		if err != nil {
+			return err
+		}
+		mediator = bridge.NewMediator(mediatorRunner, mediating, repo, store,
+			bridge.WithMediationPollInterval(mediation.PollInterval),
+			bridge.WithMediationAttempts(mediation.MaxAttempts),
+		)
+		workflowOpts = append(workflowOpts, bridge.WithMediator(mediator, mediation.FailedJobs))
+	}
+
+	if verification := config.Verification; verification.Percent > 0 && !dryRun {
+		store, ok := repo.(bridge.VerificationStore)
+		if !ok {
+			return fmt.Errorf("VERIFICATION_PERCENT: %T can't keep track of verifications", repo)
+		}
+		verifierRunner, err := bridge.NewJobRunner(bridge.WithEndpoints(verification.Endpoints...))
+		if err != nil {
+			return err
+		}
+		verifier := bridge.NewVerifier(verifierRunner, store, verification.Percent)
+		workflowOpts = append(workflowOpts, bridge.WithVerifier(verifier))
+	}
+
+	var pins *bridge.PinManager
+	if pinning := config.Pinning; pinning.Service != "" && !dryRun {
+		store, ok := repo.(bridge.PinStore)
+		if !ok {
+			return fmt.Errorf("PINNING_SERVICE: %T can't keep track of pins", repo)
+		}
+		pinner, err := bridge.NewPinner(pinning.Service, pinning.Endpoint, pinning.Token)
+		if err != nil {
+			return fmt.Errorf("PINNING_SERVICE: %w", err)
+		}
+		pins = bridge.NewPinManager(pinner, store, pinning.MaxAttempts)
+		workflowOpts = append(workflowOpts, bridge.WithPinning(pins))
+	}
+
+	if confirmations := config.Chain.ResultConfirmations; confirmations > 0 && !dryRun {
+		store, ok := repo.(bridge.PostingStore)
+		if !ok {
+			return fmt.Errorf("RESULT_CONFIRMATIONS: %T can't keep track of transactions", repo)
+		}
+		receipts, ok := contract.(bridge.ReceiptReader)
+		if !ok {
+			return fmt.Errorf("RESULT_CONFIRMATIONS: %T can't look up transactions", contract)
+		}
+		workflowOpts = append(workflowOpts, bridge.WithPostingTracker(bridge.NewPostingTracker(store, receipts, confirmations)))
+	}
+
+	if chaos {
+		log.Ctx(ctx).Warn().Interface("chaos", config.Chaos).Msg("Chaos mode: the bridge will abuse itself")
+		workflowOpts = append(workflowOpts, bridge.WithChaos(bridge.NewChaos(config.Chaos)))
+	}
+
+	workflow := bridge.NewWorkflow(runner, contract, repo, workflowOpts...)
+
+	// Settings that can be changed without interrupting orders are reloaded
+	// from the config file on SIGHUP or when asked to over HTTP.
+	reload := func(ctx context.Context) error {
+		config, err := bridge.LoadConfig(configFile)
+		if err == nil {
+			err = config.Validate()
+		}
+		if err != nil {
+			return err
+		}
+
+		lvl, err := zerolog.ParseLevel(config.Log.Level)
+		if err != nil {
+			return err
+		}
+		if err = workflow.Reload(ctx, config); err != nil {
+			return err
+		}
+		if err = config.Export(); err != nil {
+			return err
+		}
+		zerolog.SetGlobalLevel(lvl)
+
+		log.Ctx(ctx).Info().Msg("Reloaded config")
+		return nil
+	}
+	go reloadOnHangup(ctx, reload)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if checker, ok := runner.(bridge.HealthChecker); ok {
+		mux.Handle("/healthz", bridge.HealthHandler(checker))
+	} else {
+		mux.Handle("/healthz", bridge.HealthHandler())
+	}
+
+	// Everything but the metrics, health and estimates can change or reveal
+	// how the bridge is run, so needs the admin token.
+	admin := http.NewServeMux()
+	if logger, ok := runner.(bridge.JobLogger); ok {
+		admin.Handle(bridge.JobLogsPath, bridge.LogsHandler(logger))
+	}
+	if deliveries != nil {
+		admin.Handle(bridge.WebhookDeliveriesPath, bridge.DeliveriesHandler(deliveries))
+	}
+	admin.Handle(bridge.DeadLettersPath, bridge.DeadLettersHandler(workflow))
+	admin.Handle(bridge.ReloadPath, bridge.ReloadHandler(reload))
+	admin.Handle(bridge.MaintenancePath, bridge.MaintenanceHandler(workflow))
+	admin.Handle(bridge.AddressesPath, bridge.AddressesHandler(addresses))
+	admin.Handle(bridge.GasSpendPath, bridge.GasSpendHandler(budget))
+	if audit != nil {
+		admin.Handle(bridge.AuditPath, bridge.AuditHandler(audit))
+	}
+	if mediator != nil {
+		admin.Handle(bridge.MediationsPath, bridge.MediationsHandler(mediator))
+	}
+	if pins != nil {
+		admin.Handle(bridge.PinsPath, bridge.PinsHandler(pins))
+	}
+	if templates != nil {
+		admin.Handle(bridge.TemplatesPath, bridge.TemplatesHandler(templates))
+	}
+	estimator := bridge.NewEstimator(config.Pricing.Prices(), config.Bacalhau.MaxJobDuration, templates)
+	mux.Handle(bridge.EstimatePath, bridge.EstimateHandler(estimator))
+	if timelines, ok := repo.(bridge.TimelineStore); ok {
+		windows, err := config.SLA.WindowDurations()
+		if err != nil {
+			return fmt.Errorf("SLA_WINDOWS: %w", err)
+		}
+		tracker := bridge.NewSLATracker(timelines, windows, config.SLA.Target)
+		admin.Handle(bridge.SLAPath, bridge.SLAHandler(tracker))
+		if dir := config.SLA.ReportDir; dir != "" {
+			go func() {
+				err := tracker.Run(ctx, dir, config.SLA.ReportInterval)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+				}
+			}()
+		}
+	}
+	if payments, ok := repo.(bridge.PaymentStore); ok {
+		admin.Handle(bridge.AccountingPath, bridge.AccountingHandler(payments, oracle))
+	}
+	if orders, ok := repo.(bridge.OrderStore); ok {
+		admin.Handle(bridge.OrdersPath, bridge.OrdersHandler(orders, workflow))
+		admin.Handle(bridge.OrdersPath+"/", bridge.OrdersHandler(orders, workflow))
+	}
+	if token := config.Server.AdminToken; token != "" {
+		guarded := bridge.AdminAuth(token, admin)
+		mux.Handle("/admin/", guarded)
+		mux.Handle(bridge.OrdersPath, guarded)
+		mux.Handle(bridge.OrdersPath+"/", guarded)
+	} else {
+		log.Ctx(ctx).Warn().Msg("Not serving the admin endpoints, as ADMIN_TOKEN isn't set")
+	}
+
+	go func() {
+		err := bridge.ListenAndServe(ctx, config.Server.MetricsAddress, mux)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}()
+
+	if counts, ok := repo.(bridge.OrderCountStore); ok {
+		go bridge.NewQueueMonitor(counts, config.Server.QueueMetricsInterval).Run(ctx)
+	}
+
+	if notifiers := config.Alerts.Notifiers(); len(notifiers) > 0 {
+		alerter := bridge.NewAlerter(config.Alerts.Rules(), config.Alerts.CheckInterval, notifiers...)
+		alerter.Orders, _ = repo.(bridge.OrderStore)
+		alerter.Wallet, _ = contract.(bridge.BalanceReporter)
+		go alerter.Run(ctx)
+	}
+
+	if grpcAddr := config.Server.GRPCAddress; grpcAddr != "" {
+		orders, _ := repo.(bridge.OrderStore)
+		go func() {
+			err := bridge.ServeControl(ctx, grpcAddr, config.Server.AdminToken, workflow, orders)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+			}
+		}()
+	}
+
+	// Replicas sharing a database wait for their turn to run the workflow.
+	elector := bridge.AlwaysLeader
+	if config.Storage.LeaderElection && !dryRun {
+		elector, err = bridge.NewPostgresLeaderElector(config.Storage.PostgresDSN, contractAddresses[0])
+		if err != nil {
+			return err
+		}
+		if closer, ok := elector.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+
+	err = bridge.RunAsLeader(ctx, elector, workflow.Start)
+
+	if closer, ok := repo.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			fmt.Fprintln(os.Stderr, closeErr.Error())
+		}
+	}
+	return err
+}
+
+// reloadOnHangup calls reload every time the process receives SIGHUP, until
+// the context is cancelled.
+func reloadOnHangup(ctx context.Context, reload func(context.Context) error) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-hangups:
+			if err := reload(ctx); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("Unable to reload config")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}